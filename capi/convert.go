@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// Status codes matching the P2B_* constants in poly2block.h.
+const (
+	codeInvalidArgument  = 1
+	codeInvalidInput     = 2
+	codeGridTooLarge     = 3
+	codePaletteTooLarge  = 4
+	codeConversionFailed = 5
+	codeAborted          = 6
+	codeInternal         = 7
+)
+
+// libraryError is an error carrying the status code returned to C.
+type libraryError struct {
+	Code   int
+	Detail string
+}
+
+func (e *libraryError) Error() string {
+	return e.Detail
+}
+
+func newError(code int, format string, args ...interface{}) *libraryError {
+	return &libraryError{Code: code, Detail: fmt.Sprintf(format, args...)}
+}
+
+// classify maps an error from a conversion to its status code.
+func classify(err error) *libraryError {
+	var le *libraryError
+	if errors.As(err, &le) {
+		return le
+	}
+
+	var formatErr *core.FormatError
+	code := codeConversionFailed
+	switch {
+	case errors.Is(err, core.ErrGridTooLarge):
+		code = codeGridTooLarge
+	case errors.Is(err, core.ErrPaletteTooLarge):
+		code = codePaletteTooLarge
+	case errors.Is(err, core.ErrInvalidConfig):
+		code = codeInvalidArgument
+	case errors.Is(err, context.Canceled):
+		code = codeAborted
+	case errors.As(err, &formatErr), errors.Is(err, core.ErrEmptyMesh), errors.Is(err, core.ErrUnsupportedFormat):
+		code = codeInvalidInput
+	}
+	return &libraryError{Code: code, Detail: err.Error()}
+}
+
+// conversionFunc converts input with a pipeline built from the options.
+type conversionFunc func(ctx context.Context, input []byte, opts options) ([]byte, error)
+
+// convert parses the options JSON and runs a conversion, turning panics into
+// internal errors so they never cross into the host process.
+func convert(ctx context.Context, run conversionFunc, input []byte, optionsJSON string, progress core.ProgressReporter) (result []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, newError(codeInternal, "unexpected panic: %v", r)
+		}
+	}()
+
+	if len(input) == 0 {
+		return nil, newError(codeInvalidInput, "input data is empty")
+	}
+	opts, err := parseOptions(optionsJSON)
+	if err != nil {
+		return nil, newError(codeInvalidArgument, "options: %v", err)
+	}
+	opts.Progress = progress
+	return run(ctx, input, opts)
+}
+
+func meshToVox(ctx context.Context, input []byte, opts options) ([]byte, error) {
+	return runPipeline(ctx, input, opts.pipelineOptions("vox", nil))
+}
+
+func meshToSchematic(ctx context.Context, input []byte, opts options) ([]byte, error) {
+	palette, err := opts.resolvePalette()
+	if err != nil {
+		return nil, newError(codeInvalidArgument, "options: %v", err)
+	}
+	return runPipeline(ctx, input, opts.pipelineOptions("schematic", palette))
+}
+
+func runPipeline(ctx context.Context, input []byte, pipelineOptions []core.PipelineOption) ([]byte, error) {
+	pipeline, err := core.NewPipeline(pipelineOptions...)
+	if err != nil {
+		return nil, newError(codeInvalidArgument, "options: %v", err)
+	}
+	var output bytes.Buffer
+	if err := pipeline.ConvertCtx(ctx, bytes.NewReader(input), &output); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// generatePalette encodes the vanilla block palette.
+func generatePalette() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := core.ExportPalette(core.GenerateMinecraftPalette(core.GetVanillaMinecraftBlocks()), &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// options is the JSON options object accepted by the conversion functions. Field
+// names follow the WASM bindings.
+type options struct {
+	Format       string         `json:"format"`
+	Resolution   int            `json:"resolution"`
+	MaxCells     int            `json:"maxCells"`
+	Voxelizer    string         `json:"voxelizer"`
+	Matcher      string         `json:"matcher"`
+	Conservative bool           `json:"conservative"`
+	Fill         bool           `json:"fill"`
+	Dithering    ditherOption   `json:"dithering"`
+	Palette      []byte         `json:"palette"` // Base64-encoded msgpack palette
+	Filters      *filterOptions `json:"filters"`
+
+	Progress core.ProgressReporter `json:"-"`
+}
+
+type filterOptions struct {
+	Include      []string `json:"include"`
+	Exclude      []string `json:"exclude"`
+	SurvivalOnly bool     `json:"survivalOnly"`
+	OpaqueOnly   bool     `json:"opaqueOnly"`
+}
+
+// ditherOption accepts either a boolean or an {"enabled", "algorithm"} object.
+type ditherOption struct {
+	Enabled   bool   `json:"enabled"`
+	Algorithm string `json:"algorithm"`
+}
+
+func (d *ditherOption) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &d.Enabled); err == nil {
+		return nil
+	}
+	d.Enabled = true
+	type plain ditherOption
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return fmt.Errorf("dithering must be a boolean or an object")
+	}
+	return nil
+}
+
+// defaultOptions returns the options used when a field is omitted.
+func defaultOptions() options {
+	return options{
+		Format:       "gltf",
+		Resolution:   128,
+		MaxCells:     1 << 26,
+		Voxelizer:    "surface",
+		Matcher:      "cielab",
+		Conservative: true,
+		Dithering:    ditherOption{Algorithm: "floyd-steinberg"},
+	}
+}
+
+// parseOptions decodes an options object over the defaults. An empty string
+// yields the defaults; unknown fields are rejected.
+func parseOptions(data string) (options, error) {
+	opts := defaultOptions()
+	if strings.TrimSpace(data) == "" {
+		return opts, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&opts); err != nil {
+		return opts, err
+	}
+	if opts.Resolution <= 0 {
+		return opts, fmt.Errorf("resolution must be positive, got %d", opts.Resolution)
+	}
+	if opts.MaxCells < 0 {
+		return opts, fmt.Errorf("maxCells must not be negative, got %d", opts.MaxCells)
+	}
+	return opts, nil
+}
+
+// resolvePalette returns the configured palette (or the vanilla palette) with block filters applied.
+func (o options) resolvePalette() (*core.Palette, error) {
+	palette := core.GenerateMinecraftPalette(core.GetVanillaMinecraftBlocks())
+	if o.Palette != nil {
+		var err error
+		if palette, err = core.ImportPalette(bytes.NewReader(o.Palette)); err != nil {
+			return nil, fmt.Errorf("palette: %v", err)
+		}
+	}
+	if o.Filters == nil {
+		return palette, nil
+	}
+
+	filter := core.PaletteFilter{
+		Include:      o.Filters.Include,
+		Exclude:      o.Filters.Exclude,
+		SurvivalOnly: o.Filters.SurvivalOnly,
+		OpaqueOnly:   o.Filters.OpaqueOnly,
+	}
+	if filter.IsEmpty() {
+		return palette, nil
+	}
+	filtered, err := filter.Apply(palette)
+	if err != nil {
+		return nil, fmt.Errorf("filters: %v", err)
+	}
+	return filtered, nil
+}
+
+// pipelineOptions converts the options into core pipeline options for the named
+// exporter. Matching and dithering only apply when a palette is given.
+func (o options) pipelineOptions(exporter string, palette *core.Palette) []core.PipelineOption {
+	opts := []core.PipelineOption{
+		core.WithFormat(o.Format),
+		core.WithVoxelizerName(o.Voxelizer),
+		core.WithVoxelization(core.VoxelizationConfig{
+			Resolution:   o.Resolution,
+			Conservative: o.Conservative,
+			Fill:         o.Fill,
+			MaxCells:     o.MaxCells,
+		}),
+		core.WithExporterName(exporter),
+		core.WithProgress(o.Progress),
+	}
+	if palette != nil {
+		opts = append(opts,
+			core.WithPalette(palette),
+			core.WithMatcherName(o.Matcher),
+			core.WithDithering(core.DitherConfig{
+				Enabled:   o.Dithering.Enabled,
+				Algorithm: o.Dithering.Algorithm,
+			}),
+		)
+	}
+	return opts
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/billstark001/poly2block/core"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// newTriangleGLB encodes a single-triangle mesh as GLB.
+func newTriangleGLB(t *testing.T) []byte {
+	t.Helper()
+
+	doc := gltf.NewDocument()
+	positions := modeler.WritePosition(doc, [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 1}})
+	indices := modeler.WriteIndices(doc, []uint16{0, 1, 2})
+	doc.Meshes = []*gltf.Mesh{{
+		Primitives: []*gltf.Primitive{{
+			Indices:    gltf.Index(indices),
+			Attributes: gltf.PrimitiveAttributes{gltf.POSITION: positions},
+		}},
+	}}
+
+	var buf bytes.Buffer
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseOptions(t *testing.T) {
+	opts, err := parseOptions(`{"resolution": 16, "dithering": {"algorithm": "atkinson"}, "filters": {"exclude": ["*glass*"]}}`)
+	if err != nil {
+		t.Fatalf("parseOptions failed: %v", err)
+	}
+	if opts.Resolution != 16 || opts.Voxelizer != "surface" || !opts.Conservative {
+		t.Errorf("unexpected options %+v", opts)
+	}
+	if !opts.Dithering.Enabled || opts.Dithering.Algorithm != "atkinson" {
+		t.Errorf("dithering object = %+v, want enabled atkinson", opts.Dithering)
+	}
+
+	if opts, err = parseOptions(`{"dithering": true}`); err != nil || !opts.Dithering.Enabled || opts.Dithering.Algorithm != "floyd-steinberg" {
+		t.Errorf("dithering bool: %+v, %v", opts.Dithering, err)
+	}
+	if opts, err = parseOptions(""); err != nil || opts.Resolution != 128 {
+		t.Errorf("empty options: %+v, %v", opts, err)
+	}
+
+	for _, bad := range []string{`{"resolution": 0}`, `{"maxCells": -1}`, `{"unknown": 1}`, `{"dithering": "yes"}`, `[`} {
+		if _, err := parseOptions(bad); err == nil {
+			t.Errorf("parseOptions(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	mesh := newTriangleGLB(t)
+
+	vox, err := convert(context.Background(), meshToVox, mesh, `{"resolution": 8}`, nil)
+	if err != nil {
+		t.Fatalf("meshToVox failed: %v", err)
+	}
+	if !bytes.HasPrefix(vox, []byte("VOX ")) {
+		t.Errorf("output is not a VOX file")
+	}
+
+	var stages []string
+	progress := core.ProgressFunc(func(e core.ProgressEvent) {
+		if e.Type == core.StageStarted {
+			stages = append(stages, e.Stage)
+		}
+	})
+	if _, err := convert(context.Background(), meshToSchematic, mesh, `{"resolution": 8, "dithering": true}`, progress); err != nil {
+		t.Fatalf("meshToSchematic failed: %v", err)
+	}
+	if len(stages) == 0 || stages[0] != core.StageImport {
+		t.Errorf("progress stages = %v", stages)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	mesh := newTriangleGLB(t)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		input   []byte
+		options string
+		want    int
+	}{
+		{"empty input", context.Background(), nil, "", codeInvalidInput},
+		{"bad options", context.Background(), mesh, `{"resolution": "high"}`, codeInvalidArgument},
+		{"unknown algorithm", context.Background(), mesh, `{"dithering": {"algorithm": "none"}}`, codeInvalidArgument},
+		{"malformed input", context.Background(), []byte("not a mesh"), "", codeInvalidInput},
+		{"grid too large", context.Background(), mesh, `{"resolution": 64, "maxCells": 10}`, codeGridTooLarge},
+		{"aborted", canceled, mesh, "", codeAborted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := convert(tt.ctx, meshToSchematic, tt.input, tt.options, nil)
+			if err == nil {
+				t.Fatal("convert succeeded")
+			}
+			if got := classify(err).Code; got != tt.want {
+				t.Errorf("code = %d, want %d (%v)", got, tt.want, err)
+			}
+		})
+	}
+}
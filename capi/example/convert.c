@@ -0,0 +1,71 @@
+/*
+ * Converts a glTF/GLB file to a schematic through the poly2block C API.
+ *
+ *   make capi
+ *   cc -I capi -I dist example/convert.c -L dist -lpoly2block -o convert
+ *   ./convert model.glb model.schem
+ */
+#include <stdio.h>
+#include <stdlib.h>
+
+#include "libpoly2block.h"
+
+static int on_progress(void *user_data, const char *stage, int type, int64_t current, int64_t total) {
+	(void)user_data;
+	if (type == P2B_STAGE_FINISHED) {
+		fprintf(stderr, "%s done\n", stage);
+	} else if (total > 0) {
+		fprintf(stderr, "%s %.0f%%\r", stage, 100.0 * (double)current / (double)total);
+	}
+	return 0; /* nonzero aborts */
+}
+
+static unsigned char *read_file(const char *path, size_t *len) {
+	FILE *f = fopen(path, "rb");
+	if (!f) {
+		return NULL;
+	}
+	fseek(f, 0, SEEK_END);
+	long size = ftell(f);
+	fseek(f, 0, SEEK_SET);
+	unsigned char *data = malloc(size > 0 ? (size_t)size : 1);
+	*len = fread(data, 1, (size_t)size, f);
+	fclose(f);
+	return data;
+}
+
+int main(int argc, char **argv) {
+	if (argc != 3) {
+		fprintf(stderr, "usage: %s <input.glb> <output.schem>\n", argv[0]);
+		return 2;
+	}
+
+	size_t len = 0;
+	unsigned char *mesh = read_file(argv[1], &len);
+	if (!mesh) {
+		perror(argv[1]);
+		return 1;
+	}
+
+	p2b_result result;
+	int status = p2b_mesh_to_schematic(mesh, len, "{\"resolution\": 64, \"dithering\": true}",
+		on_progress, NULL, &result);
+	free(mesh);
+	if (status != P2B_OK) {
+		fprintf(stderr, "conversion failed (%d): %s\n", status, result.error);
+		p2b_result_free(&result);
+		return 1;
+	}
+
+	FILE *out = fopen(argv[2], "wb");
+	if (!out) {
+		perror(argv[2]);
+		p2b_result_free(&result);
+		return 1;
+	}
+	fwrite(result.data, 1, result.len, out);
+	fclose(out);
+	printf("wrote %zu bytes with poly2block %s\n", result.len, p2b_version());
+	p2b_result_free(&result);
+	return 0;
+}
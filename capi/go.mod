@@ -0,0 +1,18 @@
+module github.com/billstark001/poly2block/capi
+
+go 1.24.11
+
+require (
+	github.com/billstark001/poly2block/core v0.0.0
+	github.com/qmuntal/gltf v0.28.0
+)
+
+require (
+	github.com/Tnze/go-mc v1.20.2 // indirect
+	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/image v0.25.0 // indirect
+)
+
+replace github.com/billstark001/poly2block/core => ../core
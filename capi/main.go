@@ -0,0 +1,136 @@
+// Command capi builds poly2block as a C library (c-shared or c-archive). Each
+// conversion takes the input bytes, options as a JSON object and an optional
+// progress callback, and returns the output bytes in a p2b_result.
+package main
+
+/*
+#include <stdlib.h>
+#include "poly2block.h"
+*/
+import "C"
+
+import (
+	"context"
+	"unsafe"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// libraryVersion is the version string returned by p2b_version.
+const libraryVersion = "0.1.0"
+
+var cVersion = C.CString(libraryVersion)
+
+func main() {}
+
+// p2b_version returns the library version. The string is owned by the library.
+//
+//export p2b_version
+func p2b_version() *C.char {
+	return cVersion
+}
+
+// p2b_mesh_to_vox converts a glTF/GLB mesh to MagicaVoxel VOX.
+//
+//export p2b_mesh_to_vox
+func p2b_mesh_to_vox(data *C.uint8_t, length C.size_t, options *C.char,
+	progress C.p2b_progress_fn, userData unsafe.Pointer, out *C.p2b_result) C.int {
+	return convertC(meshToVox, data, length, options, progress, userData, out)
+}
+
+// p2b_mesh_to_schematic converts a glTF/GLB mesh to a Sponge schematic.
+//
+//export p2b_mesh_to_schematic
+func p2b_mesh_to_schematic(data *C.uint8_t, length C.size_t, options *C.char,
+	progress C.p2b_progress_fn, userData unsafe.Pointer, out *C.p2b_result) C.int {
+	return convertC(meshToSchematic, data, length, options, progress, userData, out)
+}
+
+// p2b_generate_palette writes the vanilla block palette in msgpack format.
+//
+//export p2b_generate_palette
+func p2b_generate_palette(out *C.p2b_result) C.int {
+	if out == nil {
+		return C.P2B_INVALID_ARGUMENT
+	}
+	result, err := generatePalette()
+	return setResult(out, result, err)
+}
+
+// p2b_result_free releases the buffers of a result and clears it.
+//
+//export p2b_result_free
+func p2b_result_free(r *C.p2b_result) {
+	if r == nil {
+		return
+	}
+	C.free(unsafe.Pointer(r.data))
+	C.free(unsafe.Pointer(r.error))
+	*r = C.p2b_result{}
+}
+
+// convertC adapts the C arguments of a conversion entry point to convert.
+func convertC(run conversionFunc, data *C.uint8_t, length C.size_t, options *C.char,
+	progress C.p2b_progress_fn, userData unsafe.Pointer, out *C.p2b_result) C.int {
+	if out == nil {
+		return C.P2B_INVALID_ARGUMENT
+	}
+	*out = C.p2b_result{}
+	if data == nil && length > 0 {
+		return setResult(out, nil, newError(codeInvalidArgument, "input data is NULL"))
+	}
+
+	var input []byte
+	if length > 0 {
+		input = C.GoBytes(unsafe.Pointer(data), C.int(length))
+	}
+	var optionsJSON string
+	if options != nil {
+		optionsJSON = C.GoString(options)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var reporter core.ProgressReporter
+	if progress != nil {
+		var release func()
+		reporter, release = cProgressReporter(progress, userData, cancel)
+		defer release()
+	}
+
+	result, err := convert(ctx, run, input, optionsJSON, reporter)
+	return setResult(out, result, err)
+}
+
+// cProgressReporter forwards progress events to a C callback, canceling the
+// conversion when it returns nonzero. release frees the stage name strings.
+func cProgressReporter(fn C.p2b_progress_fn, userData unsafe.Pointer, cancel context.CancelFunc) (reporter core.ProgressReporter, release func()) {
+	stages := make(map[string]*C.char)
+	reporter = core.ProgressFunc(func(e core.ProgressEvent) {
+		stage, ok := stages[e.Stage]
+		if !ok {
+			stage = C.CString(e.Stage)
+			stages[e.Stage] = stage
+		}
+		if C.p2b_call_progress(fn, userData, stage, C.int(e.Type), C.int64_t(e.Current), C.int64_t(e.Total)) != 0 {
+			cancel()
+		}
+	})
+	return reporter, func() {
+		for _, stage := range stages {
+			C.free(unsafe.Pointer(stage))
+		}
+	}
+}
+
+// setResult copies the output or error message into C memory and returns the status code.
+func setResult(out *C.p2b_result, result []byte, err error) C.int {
+	if err != nil {
+		be := classify(err)
+		out.error = C.CString(be.Error())
+		return C.int(be.Code)
+	}
+	out.data = (*C.uint8_t)(C.CBytes(result))
+	out.len = C.size_t(len(result))
+	return C.P2B_OK
+}
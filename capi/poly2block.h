@@ -0,0 +1,51 @@
+/*
+ * poly2block C API
+ *
+ * Build libpoly2block with `make capi` (shared) or `make capi-static` (archive)
+ * and link against it. All buffers returned by the library are owned by the
+ * caller and must be released with p2b_result_free.
+ */
+#ifndef POLY2BLOCK_H
+#define POLY2BLOCK_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+/* Status codes returned by the conversion functions. */
+enum {
+	P2B_OK = 0,
+	P2B_INVALID_ARGUMENT = 1,  /* Bad options JSON or option values */
+	P2B_INVALID_INPUT = 2,     /* Input data missing, unsupported or malformed */
+	P2B_GRID_TOO_LARGE = 3,    /* Voxel grid would exceed maxCells */
+	P2B_PALETTE_TOO_LARGE = 4, /* Too many colors for the output format */
+	P2B_CONVERSION_FAILED = 5, /* Any other conversion failure */
+	P2B_ABORTED = 6,           /* The progress callback returned nonzero */
+	P2B_INTERNAL = 7           /* Unexpected internal error */
+};
+
+/* Progress event types passed to p2b_progress_fn. */
+enum {
+	P2B_STAGE_STARTED = 0,
+	P2B_STAGE_PROGRESS = 1,
+	P2B_STAGE_FINISHED = 2
+};
+
+/*
+ * Called on the converting thread as each stage ("import", "voxelize",
+ * "match", "export") advances. total is 0 when unknown. stage is only valid
+ * during the call. Return nonzero to abort the conversion.
+ */
+typedef int (*p2b_progress_fn)(void *user_data, const char *stage, int type, int64_t current, int64_t total);
+
+/* Output of a conversion. On failure data is NULL and error describes the problem. */
+typedef struct {
+	uint8_t *data;
+	size_t len;
+	char *error;
+} p2b_result;
+
+static inline int p2b_call_progress(p2b_progress_fn fn, void *user_data, const char *stage, int type, int64_t current, int64_t total) {
+	return fn(user_data, stage, type, current, total);
+}
+
+#endif /* POLY2BLOCK_H */
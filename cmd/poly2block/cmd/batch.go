@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
+	"github.com/billstark001/poly2block/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	batchOutDir   string
+	batchExt      string
+	batchParallel int
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <input>... --out-dir <dir>",
+	Short: "Convert many meshes at once",
+	Long: `Convert every mesh matching the given files or glob patterns (quote them, e.g.
+'models/*.glb') into the output directory, several at a time. Each output takes
+its input's name with the --ext extension, which selects the format. The palette
+is loaded once for all files; a failed file is reported in the summary table
+without stopping the others.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runBatch,
+}
+
+func init() {
+	addVoxelizationFlags(batchCmd)
+	addDitheringFlags(batchCmd)
+	addPaletteFlags(batchCmd)
+	addDetailFlags(batchCmd)
+	batchCmd.Flags().StringVar(&batchOutDir, "out-dir", "", "Output directory (required)")
+	batchCmd.Flags().StringVar(&batchExt, "ext", ".schem", "Output extension selecting the format ("+strings.Join(core.ExporterExtensions(), ", ")+")")
+	batchCmd.Flags().IntVarP(&batchParallel, "parallel", "P", 0, "Files converted at once (0 = one per CPU)")
+	batchCmd.Flags().IntVar(&schemVersion, "schem-version", 2, "Sponge schematic format version (2, 3)")
+	addAirModeFlag(batchCmd)
+	addPasteOriginFlag(batchCmd)
+	batchCmd.MarkFlagRequired("out-dir")
+}
+
+// batchResult is one row of the batch summary.
+type batchResult struct {
+	input, output string
+	size          [3]int
+	voxels        int
+	elapsed       time.Duration
+	err           error
+}
+
+func runBatch(cmd *cobra.Command, args []string) error {
+	inputs, err := batchInputs(args)
+	if err != nil {
+		return err
+	}
+	ext := strings.ToLower(batchExt)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if !slices.Contains(core.ExporterExtensions(), ext) {
+		return fmt.Errorf("%w: no exporter for %q (supported: %s)", core.ErrInvalidConfig, ext, strings.Join(core.ExporterExtensions(), ", "))
+	}
+	if detail != "" && (ext == ".schematic" || ext == ".mts") {
+		return fmt.Errorf("--detail is only supported for the sponge format")
+	}
+	if ext == ".schem" || ext == ".nbt" {
+		if err := checkFlattened(strings.TrimPrefix(ext, ".")); err != nil {
+			return err
+		}
+	}
+	if storage.IsRemote(batchOutDir) {
+		return fmt.Errorf("output directory must be local, got %q", batchOutDir)
+	}
+	if err := os.MkdirAll(batchOutDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	palette, err := loadPalette(cmd.Context())
+	if err != nil {
+		return err
+	}
+	options := []core.PipelineOption{
+		core.WithVoxelizerName(voxelizerFor("")),
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithVoxelization(core.VoxelizationConfig{
+			Resolution:   resolution,
+			TargetSize:   targetSize,
+			UpAxis:       upAxis,
+			FlipZ:        mirror,
+			Conservative: conservative,
+			Fill:         fill,
+			Hollow:       hollow,
+			IsoLevel:     isoLevel,
+			MinThickness: minThickness,
+			Samples:      samples,
+			MinPoints:    minPoints,
+			HoleFill:     holeFill,
+			Lighting:     lighting,
+			Workers:      jobs,
+			Storage:      core.StorageConfig{Mode: storageMode},
+		}),
+		core.WithTransform(transform),
+		animationOption(),
+		core.WithMorphology(morphology...),
+		core.WithDetailRegions(detailRegions...),
+		core.WithIslandRemoval(minIsland),
+		core.WithPostScale(postScale),
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithDithering(core.DitherConfig{
+			Enabled:   ditherEnable,
+			Algorithm: ditherAlgo,
+		}),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithDetail(detail),
+		schematicOption(),
+	}
+	// Check the shared configuration once rather than failing every file
+	if _, err := core.NewPipeline(append(options, core.WithOutputFile("batch"+ext))...); err != nil {
+		return err
+	}
+
+	results := make([]batchResult, len(inputs))
+	outputs := make(map[string]string)
+	for i, input := range inputs {
+		results[i].input = input
+		name := filepath.Base(input)
+		results[i].output = filepath.Join(batchOutDir, strings.TrimSuffix(name, filepath.Ext(name))+ext)
+		if other, ok := outputs[results[i].output]; ok {
+			results[i].err = fmt.Errorf("output name clashes with %s", other)
+		}
+		outputs[results[i].output] = input
+	}
+
+	parallel := batchParallel
+	if parallel <= 0 {
+		parallel = runtime.GOMAXPROCS(0)
+	}
+	fmt.Printf("Converting %d files into %s...\n", len(inputs), batchOutDir)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i := range results {
+		if results[i].err != nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *batchResult) {
+			defer func() { <-sem; wg.Done() }()
+			start := time.Now()
+			r.err = convertBatchFile(cmd.Context(), r, options)
+			r.elapsed = time.Since(start)
+			status := "done"
+			if r.err != nil {
+				status = "failed"
+			}
+			fmt.Printf("  %s %s\n", status, r.input)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	failed := printBatchSummary(os.Stdout, results)
+	if err := cmd.Context().Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(results))
+	}
+	return nil
+}
+
+// batchInputs expands glob patterns, keeping remote URIs and plain paths as given,
+// and drops duplicates.
+func batchInputs(args []string) ([]string, error) {
+	var inputs []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		matches := []string{arg}
+		if !storage.IsRemote(arg) && strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("%w: bad pattern %q: %v", core.ErrInvalidConfig, arg, err)
+			}
+			if len(matches) == 0 {
+				fmt.Printf("Warning: %s matches no files\n", arg)
+			}
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				inputs = append(inputs, m)
+			}
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%w: no input files", core.ErrInvalidConfig)
+	}
+	return inputs, nil
+}
+
+// convertBatchFile converts one input with its own pipeline, filling in the grid
+// size and voxel count of r.
+func convertBatchFile(ctx context.Context, r *batchResult, options []core.PipelineOption) error {
+	pipeline, err := core.NewPipeline(append(options, core.WithInputFile(r.input), core.WithOutputFile(r.output), core.WithVoxelizerName(voxelizerFor(r.input)))...)
+	if err != nil {
+		return err
+	}
+	meshReader, err := storage.Open(ctx, r.input)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer meshReader.Close()
+
+	grid, err := pipeline.MeshToVoxelGridCtx(ctx, meshReader, pipeline.Config)
+	if err != nil {
+		return err
+	}
+	r.size = [3]int{grid.SizeX, grid.SizeY, grid.SizeZ}
+	r.voxels = grid.Count()
+	return writeOutput(ctx, r.output, func(w io.Writer) error {
+		return pipeline.ExportGridCtx(ctx, grid, w)
+	})
+}
+
+// printBatchSummary writes the results as a table and returns the number of failures.
+func printBatchSummary(w io.Writer, results []batchResult) int {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "INPUT\tOUTPUT\tSIZE\tVOXELS\tTIME\tSTATUS")
+	for _, r := range results {
+		size, voxels, status := "-", "-", "ok"
+		if r.err != nil {
+			failed++
+			status = r.err.Error()
+		} else {
+			size = fmt.Sprintf("%dx%dx%d", r.size[0], r.size[1], r.size[2])
+			voxels = fmt.Sprint(r.voxels)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.input, r.output, size, voxels, r.elapsed.Round(time.Millisecond), status)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d converted, %d failed\n", len(results)-failed, failed)
+	return failed
+}
@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
+	"github.com/billstark001/poly2block/core"
+	"github.com/spf13/cobra"
+)
+
+var composeCmd = &cobra.Command{
+	Use:   "compose <layout.json> <output>",
+	Short: "Combine several models into one schematic",
+	Long: `Voxelize every part listed in a JSON layout file and combine them into one
+schematic. Parts are meshes, MagicaVoxel VOX, Qubicle .qb or .binvox files or Sponge schematics, with
+relative paths taken from the layout's directory:
+
+  {
+    "parts": [
+      {"input": "castle.glb"},
+      {"input": "tower.vox", "offset": [40, 0, 12]},
+      {"input": "gate.glb", "offset": [18, 0, 0], "op": "subtract", "resolution": 16}
+    ]
+  }
+
+The first part is the base. Each later part is placed with its minimum corner
+at offset, in voxels from the base's corner, and combined with everything
+before it by op: merge (default, the part's colors win), union (earlier colors
+win), subtract or intersect. Meshes are voxelized with the voxelization flags,
+a part's resolution overriding --resolution; --post-scale rescales the result.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCompose,
+}
+
+func init() {
+	addVoxelizationFlags(composeCmd)
+	addDitheringFlags(composeCmd)
+	addPaletteFlags(composeCmd)
+	addDetailFlags(composeCmd)
+	addSchematicFlags(composeCmd)
+}
+
+// composeLayout is the layout file of the compose command.
+type composeLayout struct {
+	Parts []composePart `json:"parts"`
+}
+
+// composePart is one model of a composeLayout.
+type composePart struct {
+	Input      string `json:"input"`
+	Offset     [3]int `json:"offset"`
+	Op         string `json:"op"`
+	Resolution int    `json:"resolution"`
+}
+
+func runCompose(cmd *cobra.Command, args []string) error {
+	layoutFile := args[0]
+	outputFile := args[1]
+
+	layout, err := readLayout(cmd.Context(), layoutFile)
+	if err != nil {
+		return err
+	}
+	exporter, err := schematicExporter()
+	if err != nil {
+		return err
+	}
+	if err := checkMaterialList(); err != nil {
+		return err
+	}
+
+	palette, err := loadPalette(cmd.Context())
+	if err != nil {
+		return err
+	}
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithDetail(detail),
+		core.WithDithering(core.DitherConfig{
+			Enabled:   ditherEnable,
+			Algorithm: ditherAlgo,
+		}),
+		schematicOption(),
+		core.WithExporterName(exporter),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return err
+	}
+
+	var grid *core.VoxelGrid
+	for i, part := range layout.Parts {
+		fmt.Printf("Adding %s...\n", part.Input)
+		partGrid, err := loadPart(cmd.Context(), part, palette, progress)
+		if err != nil {
+			endProgressLine(progress)
+			return fmt.Errorf("part %d (%s): %w", i+1, part.Input, err)
+		}
+		if grid == nil {
+			grid = partGrid
+			continue
+		}
+		op := part.Op
+		if op == "" {
+			op = core.GridMerge
+		}
+		if grid, err = grid.Combine(op, partGrid, part.Offset); err != nil {
+			return fmt.Errorf("part %d (%s): %w", i+1, part.Input, err)
+		}
+	}
+	if grid, err = grid.Rescale(postScale); err != nil {
+		return err
+	}
+	if _, err := grid.AmbientOcclusionCtx(cmd.Context(), pipeline.Config.AmbientOcclusion); err != nil {
+		return err
+	}
+	fmt.Printf("Composed %d parts into %dx%dx%d voxels\n", len(layout.Parts), grid.SizeX, grid.SizeY, grid.SizeZ)
+
+	if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+		return pipeline.ExportGridCtx(cmd.Context(), grid, w)
+	}); err != nil {
+		endProgressLine(progress)
+		return err
+	}
+
+	fmt.Printf("Successfully converted to %s\n", outputFile)
+	return reportMaterials(cmd.Context(), pipeline.Exporter)
+}
+
+// readLayout loads and checks a compose layout, resolving part paths against
+// the layout's directory.
+func readLayout(ctx context.Context, layoutFile string) (*composeLayout, error) {
+	r, err := storage.Open(ctx, layoutFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open layout file: %w", err)
+	}
+	defer r.Close()
+	var layout composeLayout
+	if err := json.NewDecoder(r).Decode(&layout); err != nil {
+		return nil, fmt.Errorf("%w: bad layout file %s: %v", core.ErrInvalidConfig, layoutFile, err)
+	}
+	if len(layout.Parts) == 0 {
+		return nil, fmt.Errorf("%w: layout file %s lists no parts", core.ErrInvalidConfig, layoutFile)
+	}
+
+	for i := range layout.Parts {
+		part := &layout.Parts[i]
+		if part.Input == "" {
+			return nil, fmt.Errorf("%w: part %d has no input", core.ErrInvalidConfig, i+1)
+		}
+		if i == 0 && (part.Op != "" || part.Offset != [3]int{}) {
+			return nil, fmt.Errorf("%w: part 1 is the base the others are placed on and takes no op or offset",
+				core.ErrInvalidConfig)
+		}
+		if part.Op != "" && !slices.Contains(core.GridOps(), part.Op) {
+			return nil, fmt.Errorf("%w: part %d: unknown operation %q (supported: %s)",
+				core.ErrInvalidConfig, i+1, part.Op, strings.Join(core.GridOps(), ", "))
+		}
+		if part.Resolution < 0 {
+			return nil, fmt.Errorf("%w: part %d: resolution must not be negative, got %d",
+				core.ErrInvalidConfig, i+1, part.Resolution)
+		}
+		if !storage.IsRemote(part.Input) && !storage.IsRemote(layoutFile) && !filepath.IsAbs(part.Input) {
+			part.Input = filepath.Join(filepath.Dir(layoutFile), part.Input)
+		}
+	}
+	return &layout, nil
+}
+
+// loadPart reads a VOX, Qubicle, binvox, schematic or Litematica part as is and
+// voxelizes any other input as a mesh. Schematic blocks take their colors from
+// palette.
+func loadPart(ctx context.Context, part composePart, palette *core.Palette, progress core.ProgressReporter) (*core.VoxelGrid, error) {
+	r, err := storage.Open(ctx, part.Input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer r.Close()
+
+	if importer, ok := voxelFileImporter(part.Input); ok {
+		return importer.Import(r)
+	}
+	switch strings.ToLower(filepath.Ext(part.Input)) {
+	case ".schem":
+		return (&core.SchematicImporterImpl{Palette: palette}).Import(r)
+	case ".litematic":
+		return (&core.LitematicImporterImpl{Palette: palette}).Import(r)
+	}
+
+	config := core.VoxelizationConfig{
+		Resolution:   resolution,
+		TargetSize:   targetSize,
+		UpAxis:       upAxis,
+		FlipZ:        mirror,
+		Conservative: conservative,
+		Fill:         fill,
+		Hollow:       hollow,
+		IsoLevel:     isoLevel,
+		MinThickness: minThickness,
+		Samples:      samples,
+		MinPoints:    minPoints,
+		HoleFill:     holeFill,
+		Lighting:     lighting,
+		Workers:      jobs,
+		Storage:      core.StorageConfig{Mode: storageMode},
+	}
+	if part.Resolution > 0 {
+		config.Resolution, config.TargetSize = part.Resolution, [3]int{}
+	}
+	pipeline, err := core.NewPipeline(
+		core.WithInputFile(part.Input),
+		animationOption(),
+		core.WithVoxelizerName(voxelizerFor(part.Input)),
+		core.WithVoxelization(config),
+		core.WithTransform(transform),
+		core.WithMorphology(morphology...),
+		core.WithDetailRegions(detailRegions...),
+		core.WithIslandRemoval(minIsland),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.MeshToVoxelGridCtx(ctx, r, pipeline.Config)
+}
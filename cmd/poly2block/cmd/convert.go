@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io"
 	"path/filepath"
 	"strings"
 
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
 	"github.com/billstark001/poly2block/core"
 	"github.com/spf13/cobra"
 )
@@ -13,7 +15,8 @@ import (
 var meshToVoxCmd = &cobra.Command{
 	Use:   "mesh-to-vox <input> <output>",
 	Short: "Convert mesh to VOX format",
-	Long:  `Convert a polygon mesh (OBJ, glTF) to MagicaVoxel VOX format.`,
+	Long: `Convert a polygon mesh (OBJ, PLY, glTF, FBX, DAE) to MagicaVoxel VOX format, to
+Qubicle Binary when the output ends in .qb, or to binvox when it ends in .binvox.`,
 	Args:  cobra.ExactArgs(2),
 	RunE:  runMeshToVox,
 }
@@ -21,19 +24,86 @@ var meshToVoxCmd = &cobra.Command{
 var voxToSchematicCmd = &cobra.Command{
 	Use:   "vox-to-schematic <input> <output>",
 	Short: "Convert VOX to Minecraft schematic",
-	Long:  `Convert a MagicaVoxel VOX file to Minecraft schematic format.`,
+	Long:  `Convert a MagicaVoxel VOX, Qubicle Binary (.qb) or binvox file to Minecraft schematic format.`,
 	Args:  cobra.ExactArgs(2),
 	RunE:  runVoxToSchematic,
 }
 
+var schematicToVoxCmd = &cobra.Command{
+	Use:   "schematic-to-vox <input> <output>",
+	Short: "Convert Minecraft schematic to VOX",
+	Long: `Convert a Sponge schematic (.schem) or a Litematica schematic (.litematic) to
+MagicaVoxel VOX format, to Qubicle Binary when the output ends in .qb, or to binvox
+when it ends in .binvox. Blocks take their colors from the palette, and blocks it
+does not list come in gray.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSchematicToVox,
+}
+
+var litematicToVoxCmd = &cobra.Command{
+	Use:   "litematic-to-vox <input> <output>",
+	Short: "Convert Litematica schematic to VOX (alias)",
+	Long:  `Convert a Litematica schematic (.litematic) to VOX format (same as schematic-to-vox).`,
+	Args:  cobra.ExactArgs(2),
+	RunE:  runSchematicToVox,
+}
+
 var meshToSchematicCmd = &cobra.Command{
 	Use:   "mesh-to-schematic <input> <output>",
 	Short: "Convert mesh to Minecraft schematic",
-	Long:  `Convert a polygon mesh (OBJ, glTF) directly to Minecraft schematic format.`,
+	Long:  `Convert a polygon mesh (OBJ, PLY, glTF, FBX, DAE) directly to Minecraft schematic format.`,
 	Args:  cobra.ExactArgs(2),
 	RunE:  runMeshToSchematic,
 }
 
+var meshToStructureCmd = &cobra.Command{
+	Use:   "mesh-to-structure <input> <output>",
+	Short: "Convert mesh to Minecraft structure files",
+	Long: `Convert a polygon mesh (OBJ, PLY, glTF, FBX, DAE) to the vanilla structure block format (.nbt).
+Models larger than 48 blocks along any axis are split into 48x48x48 pieces written
+next to the output as <name>_<x>_<y>_<z>.nbt, named by the piece's block offset.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMeshToStructure,
+}
+
+var meshToCommandsCmd = &cobra.Command{
+	Use:   "mesh-to-commands <input> <output>",
+	Short: "Convert mesh to setblock/fill commands",
+	Long: `Convert a polygon mesh (OBJ, PLY, glTF, FBX, DAE) to setblock and fill commands that build
+it without mods. A .mcfunction output is a single function run where the model
+should appear; a .zip output is a datapack whose <namespace>:build function places
+the model in parts chained with schedule, for builds over the per-function limit.
+A .txt output is a WorldEdit script of //pos1, //pos2 and //set commands placing
+the model at --origin, for command blocks, macros or FAWE.
+
+With --boxes the grid is split into the largest boxes of one block (greedy
+meshing), each placed by one fill command, instead of runs along x.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMeshToCommands,
+}
+
+var meshToPreviewCmd = &cobra.Command{
+	Use:   "mesh-to-preview <input> <output>",
+	Short: "Convert mesh to a voxel preview mesh (OBJ, glTF)",
+	Long: `Voxelize a polygon mesh (OBJ, PLY, glTF, FBX, DAE) and write the result back as a
+greedy-meshed polygon mesh with per-face colors, to check the voxelization and
+block colors in any 3D viewer before exporting a schematic. The output format is
+chosen by extension: .obj, .gltf or .glb. One unit is one block.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMeshToPreview,
+}
+
+var meshToWorldCmd = &cobra.Command{
+	Use:   "mesh-to-world <input> <world-dir>",
+	Short: "Convert mesh straight into a Minecraft world",
+	Long: `Convert a polygon mesh (OBJ, PLY, glTF, FBX, DAE) and write the blocks straight into the
+region files of a local Minecraft 1.18+ world save, with the model's minimum corner
+at --origin. Existing chunks keep their other blocks; light is recomputed when the
+world is next opened. Close the world in the game first.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMeshToWorld,
+}
+
 var convertCmd = &cobra.Command{
 	Use:   "convert <input> <output>",
 	Short: "Convert mesh to schematic (alias)",
@@ -45,20 +115,72 @@ var convertCmd = &cobra.Command{
 func init() {
 	// mesh-to-vox flags
 	addVoxelizationFlags(meshToVoxCmd)
+	addFrameFlags(meshToVoxCmd)
+	addDitheringFlags(meshToVoxCmd)
+	meshToVoxCmd.Flags().StringVarP(&paletteFile, "palette", "p", "", "Palette file (.msgpack, .json or .csv) the model's colors are reduced to, such as one from generate-palette --from-image")
 	
 	// vox-to-schematic flags
+	addPostScaleFlag(voxToSchematicCmd)
+	addAOFlag(voxToSchematicCmd)
 	addDitheringFlags(voxToSchematicCmd)
 	addPaletteFlags(voxToSchematicCmd)
+	addDetailFlags(voxToSchematicCmd)
+	addSchematicFlags(voxToSchematicCmd)
+	addRotationsFlag(voxToSchematicCmd)
+	
+	// schematic-to-vox flags
+	for _, cmd := range []*cobra.Command{schematicToVoxCmd, litematicToVoxCmd} {
+		cmd.Flags().StringVarP(&paletteFile, "palette", "p", "", "Palette file (.msgpack, .json or .csv) coloring the blocks")
+		addPostScaleFlag(cmd)
+		addAOFlag(cmd)
+	}
 	
 	// mesh-to-schematic flags
 	addVoxelizationFlags(meshToSchematicCmd)
+	addFrameFlags(meshToSchematicCmd)
 	addDitheringFlags(meshToSchematicCmd)
 	addPaletteFlags(meshToSchematicCmd)
+	addDetailFlags(meshToSchematicCmd)
+	addSchematicFlags(meshToSchematicCmd)
+	addRotationsFlag(meshToSchematicCmd)
+	
+	// mesh-to-structure flags
+	addVoxelizationFlags(meshToStructureCmd)
+	addDitheringFlags(meshToStructureCmd)
+	addPaletteFlags(meshToStructureCmd)
+	addDetailFlags(meshToStructureCmd)
+	addAirModeFlag(meshToStructureCmd)
+	
+	// mesh-to-commands flags
+	addVoxelizationFlags(meshToCommandsCmd)
+	addDitheringFlags(meshToCommandsCmd)
+	addPaletteFlags(meshToCommandsCmd)
+	addDetailFlags(meshToCommandsCmd)
+	meshToCommandsCmd.Flags().StringVar(&namespace, "namespace", "poly2block", "Datapack namespace")
+	meshToCommandsCmd.Flags().IntVar(&maxCommands, "max-commands", 32768, "Commands per datapack function")
+	meshToCommandsCmd.Flags().BoolVar(&fillBoxes, "boxes", false, "Fill greedy boxes of one block instead of runs along x")
+	meshToCommandsCmd.Flags().IntSliceVar(&worldOrigin, "origin", []int{0, 64, 0}, "World position x,y,z of the model's minimum corner (WorldEdit scripts)")
+	
+	// mesh-to-preview flags
+	addVoxelizationFlags(meshToPreviewCmd)
+	addDitheringFlags(meshToPreviewCmd)
+	addPaletteFlags(meshToPreviewCmd)
+	meshToPreviewCmd.Flags().BoolVar(&previewMatch, "match", true, "Show colors matched against the palette instead of the voxelized colors")
+	
+	// mesh-to-world flags
+	addVoxelizationFlags(meshToWorldCmd)
+	addDitheringFlags(meshToWorldCmd)
+	addPaletteFlags(meshToWorldCmd)
+	addDetailFlags(meshToWorldCmd)
+	meshToWorldCmd.Flags().IntSliceVar(&worldOrigin, "origin", []int{0, 64, 0}, "World position x,y,z of the model's minimum corner")
 	
 	// convert flags (same as mesh-to-schematic)
 	addVoxelizationFlags(convertCmd)
+	addFrameFlags(convertCmd)
 	addDitheringFlags(convertCmd)
 	addPaletteFlags(convertCmd)
+	addDetailFlags(convertCmd)
+	addSchematicFlags(convertCmd)
 }
 
 func runMeshToVox(cmd *cobra.Command, args []string) error {
@@ -67,199 +189,972 @@ func runMeshToVox(cmd *cobra.Command, args []string) error {
 	
 	fmt.Printf("Converting %s to VOX format...\n", inputFile)
 	
+	exporter := "vox"
+	switch strings.ToLower(filepath.Ext(outputFile)) {
+	case ".qb":
+		exporter = "qubicle"
+	case ".binvox":
+		exporter = "binvox"
+	}
+	var palette *core.Palette
+	if paletteFile != "" {
+		fmt.Printf("Loading palette from %s\n", paletteFile)
+		var err error
+		if palette, err = readPalette(cmd.Context(), paletteFile); err != nil {
+			return err
+		}
+	}
+	
+	// Create pipeline (importer chosen by file extension)
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithInputFile(inputFile),
+		animationOption(),
+		core.WithVoxelizerName(voxelizerFor(inputFile)),
+		core.WithVoxelization(core.VoxelizationConfig{
+			Resolution:   resolution,
+			TargetSize:   targetSize,
+			UpAxis:       upAxis,
+			FlipZ:        mirror,
+			Conservative: conservative,
+			Fill:         fill,
+			Hollow:       hollow,
+			IsoLevel:     isoLevel,
+			MinThickness: minThickness,
+			Samples:      samples,
+			MinPoints:    minPoints,
+			HoleFill:     holeFill,
+			Lighting:     lighting,
+			Workers:      jobs,
+			Storage:      core.StorageConfig{Mode: storageMode},
+		}),
+		core.WithTransform(transform),
+		core.WithMorphology(morphology...),
+		core.WithDetailRegions(detailRegions...),
+		core.WithIslandRemoval(minIsland),
+		core.WithPostScale(postScale),
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithPalette(palette),
+		core.WithDithering(core.DitherConfig{
+			Enabled:   ditherEnable,
+			Algorithm: ditherAlgo,
+		}),
+		core.WithExporterName(exporter),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return err
+	}
+	if animationEnd >= 0 {
+		if err := convertFrames(cmd.Context(), pipeline, inputFile, outputFile); err != nil {
+			endProgressLine(progress)
+			return err
+		}
+		return nil
+	}
+	
 	// Open input file
-	meshReader, err := os.Open(inputFile)
+	meshReader, err := storage.Open(cmd.Context(), inputFile)
 	if err != nil {
 		return fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer meshReader.Close()
 	
-	// Create output file
-	voxWriter, err := os.Create(outputFile)
+	// Convert into the output file
+	if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+		return pipeline.ConvertCtx(cmd.Context(), meshReader, w)
+	}); err != nil {
+		endProgressLine(progress)
+		return err
+	}
+	
+	fmt.Printf("Successfully converted to %s\n", outputFile)
+	return nil
+}
+
+func runVoxToSchematic(cmd *cobra.Command, args []string) error {
+	inputFile := args[0]
+	outputFile := args[1]
+	
+	fmt.Printf("Converting %s to Minecraft schematic...\n", inputFile)
+	
+	exporter, err := schematicExporter()
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
+	}
+	if err := checkMaterialList(); err != nil {
+		return err
 	}
-	defer voxWriter.Close()
 	
-	// Determine importer based on file extension
-	importer, err := getImporter(inputFile)
+	// Load palette
+	palette, err := loadPalette(cmd.Context())
 	if err != nil {
 		return err
 	}
 	
 	// Create pipeline
-	pipeline := &core.Pipeline{
-		Importer:  importer,
-		Voxelizer: core.NewSurfaceVoxelizer(),
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithDetail(detail),
+		core.WithDithering(core.DitherConfig{
+			Enabled:   ditherEnable,
+			Algorithm: ditherAlgo,
+		}),
+		schematicOption(),
+		core.WithExporterName(exporter),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return err
 	}
 	
-	// Configure
-	config := core.PipelineConfig{
-		Voxelization: core.VoxelizationConfig{
-			Resolution:   resolution,
-			Conservative: conservative,
-		},
+	// Open input file
+	voxReader, err := storage.Open(cmd.Context(), inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
 	}
+	defer voxReader.Close()
 	
-	// Convert
-	if err := pipeline.MeshToVOX(meshReader, voxWriter, config); err != nil {
-		return fmt.Errorf("conversion failed: %w", err)
+	// Import VOX, or Qubicle or binvox by extension
+	voxImporter, ok := voxelFileImporter(inputFile)
+	if !ok {
+		voxImporter = core.NewVOXImporter()
+	}
+	voxelGrid, err := voxImporter.Import(voxReader)
+	if err != nil {
+		return fmt.Errorf("failed to import voxel file: %w", err)
+	}
+	if voxelGrid, err = voxelGrid.Rescale(postScale); err != nil {
+		return err
+	}
+	if _, err := voxelGrid.AmbientOcclusionCtx(cmd.Context(), pipeline.Config.AmbientOcclusion); err != nil {
+		return err
+	}
+	if rotations {
+		if err := exportRotations(cmd.Context(), pipeline, exporter, voxelGrid, outputFile); err != nil {
+			endProgressLine(progress)
+			return err
+		}
+		return reportMaterials(cmd.Context(), pipeline.Exporter)
+	}
+	
+	// Convert into the output file
+	if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+		return pipeline.ExportGridCtx(cmd.Context(), voxelGrid, w)
+	}); err != nil {
+		endProgressLine(progress)
+		return err
 	}
 	
 	fmt.Printf("Successfully converted to %s\n", outputFile)
-	return nil
+	return reportMaterials(cmd.Context(), pipeline.Exporter)
 }
 
-func runVoxToSchematic(cmd *cobra.Command, args []string) error {
+func runSchematicToVox(cmd *cobra.Command, args []string) error {
 	inputFile := args[0]
 	outputFile := args[1]
 	
-	fmt.Printf("Converting %s to Minecraft schematic...\n", inputFile)
+	fmt.Printf("Converting %s to VOX format...\n", inputFile)
 	
-	// Load palette
-	palette, err := loadPalette()
+	exporter := "vox"
+	switch strings.ToLower(filepath.Ext(outputFile)) {
+	case ".qb":
+		exporter = "qubicle"
+	case ".binvox":
+		exporter = "binvox"
+	}
+	palette, err := loadBlockColors(cmd.Context())
+	if err != nil {
+		return err
+	}
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithExporterName(exporter),
+		core.WithProgress(progress),
+	)
 	if err != nil {
 		return err
 	}
 	
 	// Open input file
-	voxReader, err := os.Open(inputFile)
+	schematicReader, err := storage.Open(cmd.Context(), inputFile)
 	if err != nil {
 		return fmt.Errorf("failed to open input file: %w", err)
 	}
-	defer voxReader.Close()
+	defer schematicReader.Close()
 	
-	// Import VOX
-	voxImporter := core.NewVOXImporter()
-	voxelGrid, err := voxImporter.Import(voxReader)
+	// Import the schematic, Sponge or Litematica by extension
+	var importer gridImporter = &core.SchematicImporterImpl{Palette: palette}
+	if strings.ToLower(filepath.Ext(inputFile)) == ".litematic" {
+		importer = &core.LitematicImporterImpl{Palette: palette}
+	}
+	voxelGrid, err := importer.Import(schematicReader)
 	if err != nil {
-		return fmt.Errorf("failed to import VOX file: %w", err)
+		return fmt.Errorf("failed to import schematic: %w", err)
+	}
+	if voxelGrid, err = voxelGrid.Rescale(postScale); err != nil {
+		return err
+	}
+	if _, err := voxelGrid.AmbientOcclusionCtx(cmd.Context(), pipeline.Config.AmbientOcclusion); err != nil {
+		return err
+	}
+	
+	// Convert into the output file
+	if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+		return pipeline.ExportGridCtx(cmd.Context(), voxelGrid, w)
+	}); err != nil {
+		endProgressLine(progress)
+		return err
 	}
 	
-	// Create output file
-	schematicWriter, err := os.Create(outputFile)
+	fmt.Printf("Successfully converted to %s\n", outputFile)
+	return nil
+}
+
+func runMeshToSchematic(cmd *cobra.Command, args []string) error {
+	inputFile := args[0]
+	outputFile := args[1]
+	
+	fmt.Printf("Converting %s to Minecraft schematic...\n", inputFile)
+	
+	exporter, err := schematicExporter()
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
+	}
+	if err := checkMaterialList(); err != nil {
+		return err
 	}
-	defer schematicWriter.Close()
 	
-	// Create pipeline
-	pipeline := &core.Pipeline{
-		Matcher: core.NewCIELABMatcher(palette),
+	// Load palette
+	palette, err := loadPalette(cmd.Context())
+	if err != nil {
+		return err
 	}
 	
-	// Configure
-	config := core.PipelineConfig{
-		Dithering: core.DitherConfig{
+	// Create pipeline (importer chosen by file extension)
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithInputFile(inputFile),
+		animationOption(),
+		core.WithVoxelizerName(voxelizerFor(inputFile)),
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithVoxelization(core.VoxelizationConfig{
+			Resolution:   resolution,
+			TargetSize:   targetSize,
+			UpAxis:       upAxis,
+			FlipZ:        mirror,
+			Conservative: conservative,
+			Fill:         fill,
+			Hollow:       hollow,
+			IsoLevel:     isoLevel,
+			MinThickness: minThickness,
+			Samples:      samples,
+			MinPoints:    minPoints,
+			HoleFill:     holeFill,
+			Lighting:     lighting,
+			Workers:      jobs,
+			Storage:      core.StorageConfig{Mode: storageMode},
+		}),
+		core.WithTransform(transform),
+		core.WithMorphology(morphology...),
+		core.WithDetailRegions(detailRegions...),
+		core.WithIslandRemoval(minIsland),
+		core.WithPostScale(postScale),
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithDithering(core.DitherConfig{
 			Enabled:   ditherEnable,
 			Algorithm: ditherAlgo,
-		},
-		Palette: palette,
+		}),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithDetail(detail),
+		schematicOption(),
+		core.WithExporterName(exporter),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return err
+	}
+	if animationEnd >= 0 {
+		if rotations {
+			return fmt.Errorf("--rotations cannot be combined with --animation-end")
+		}
+		if err := convertFrames(cmd.Context(), pipeline, inputFile, outputFile); err != nil {
+			endProgressLine(progress)
+			return err
+		}
+		return nil
 	}
 	
-	// Convert
-	if err := pipeline.VoxelGridToSchematic(voxelGrid, schematicWriter, config); err != nil {
-		return fmt.Errorf("conversion failed: %w", err)
+	// Open input file
+	meshReader, err := storage.Open(cmd.Context(), inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer meshReader.Close()
+	
+	if rotations {
+		voxelGrid, err := pipeline.MeshToVoxelGridCtx(cmd.Context(), meshReader, pipeline.Config)
+		if err == nil {
+			err = exportRotations(cmd.Context(), pipeline, exporter, voxelGrid, outputFile)
+		}
+		if err != nil {
+			endProgressLine(progress)
+			return err
+		}
+		return reportMaterials(cmd.Context(), pipeline.Exporter)
+	}
+	
+	// Convert into the output file
+	if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+		return pipeline.ConvertCtx(cmd.Context(), meshReader, w)
+	}); err != nil {
+		endProgressLine(progress)
+		return err
 	}
 	
 	fmt.Printf("Successfully converted to %s\n", outputFile)
-	return nil
+	return reportMaterials(cmd.Context(), pipeline.Exporter)
 }
 
-func runMeshToSchematic(cmd *cobra.Command, args []string) error {
+func runMeshToStructure(cmd *cobra.Command, args []string) error {
 	inputFile := args[0]
 	outputFile := args[1]
 	
-	fmt.Printf("Converting %s to Minecraft schematic...\n", inputFile)
+	fmt.Printf("Converting %s to Minecraft structure...\n", inputFile)
 	
+	if err := checkFlattened("structure"); err != nil {
+		return err
+	}
 	// Load palette
-	palette, err := loadPalette()
+	palette, err := loadPalette(cmd.Context())
+	if err != nil {
+		return err
+	}
+	
+	// Create pipeline (importer chosen by file extension)
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithInputFile(inputFile),
+		animationOption(),
+		core.WithVoxelizerName(voxelizerFor(inputFile)),
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithVoxelization(core.VoxelizationConfig{
+			Resolution:   resolution,
+			TargetSize:   targetSize,
+			UpAxis:       upAxis,
+			FlipZ:        mirror,
+			Conservative: conservative,
+			Fill:         fill,
+			Hollow:       hollow,
+			IsoLevel:     isoLevel,
+			MinThickness: minThickness,
+			Samples:      samples,
+			MinPoints:    minPoints,
+			HoleFill:     holeFill,
+			Lighting:     lighting,
+			Workers:      jobs,
+			Storage:      core.StorageConfig{Mode: storageMode},
+		}),
+		core.WithTransform(transform),
+		core.WithMorphology(morphology...),
+		core.WithDetailRegions(detailRegions...),
+		core.WithIslandRemoval(minIsland),
+		core.WithPostScale(postScale),
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithDithering(core.DitherConfig{
+			Enabled:   ditherEnable,
+			Algorithm: ditherAlgo,
+		}),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithDetail(detail),
+		core.WithSchematic(core.SchematicConfig{DataVersion: mcDataVersion(), AirMode: airMode}),
+		core.WithExporterName("structure"),
+		core.WithProgress(progress),
+	)
 	if err != nil {
 		return err
 	}
 	
 	// Open input file
-	meshReader, err := os.Open(inputFile)
+	meshReader, err := storage.Open(cmd.Context(), inputFile)
 	if err != nil {
 		return fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer meshReader.Close()
 	
-	// Create output file
-	schematicWriter, err := os.Create(outputFile)
+	// Voxelize and match once, then split the grid if a structure block cannot hold it
+	grid, err := pipeline.MeshToVoxelGridCtx(cmd.Context(), meshReader, pipeline.Config)
+	if err == nil {
+		grid, err = pipeline.MatchColorsCtx(cmd.Context(), grid, pipeline.Config)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		endProgressLine(progress)
+		return fmt.Errorf("conversion failed: %w", err)
+	}
+	
+	if grid.SizeX <= core.MaxStructureSize && grid.SizeY <= core.MaxStructureSize && grid.SizeZ <= core.MaxStructureSize {
+		if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+			return pipeline.Exporter.Export(grid, w)
+		}); err != nil {
+			endProgressLine(progress)
+			return err
+		}
+		fmt.Printf("Successfully converted to %s\n", outputFile)
+		return nil
 	}
-	defer schematicWriter.Close()
 	
-	// Determine importer
-	importer, err := getImporter(inputFile)
+	pieces := core.SplitStructure(grid, core.MaxStructureSize)
+	ext := filepath.Ext(outputFile)
+	for _, piece := range pieces {
+		if err := cmd.Context().Err(); err != nil {
+			endProgressLine(progress)
+			return err
+		}
+		pieceFile := fmt.Sprintf("%s_%d_%d_%d%s", strings.TrimSuffix(outputFile, ext), piece.X, piece.Y, piece.Z, ext)
+		if err := writeOutput(cmd.Context(), pieceFile, func(w io.Writer) error {
+			return pipeline.Exporter.Export(piece.Grid, w)
+		}); err != nil {
+			endProgressLine(progress)
+			return err
+		}
+	}
+	fmt.Printf("Successfully converted to %d structure pieces next to %s\n", len(pieces), outputFile)
+	return nil
+}
+
+func runMeshToCommands(cmd *cobra.Command, args []string) error {
+	inputFile := args[0]
+	outputFile := args[1]
+	
+	fmt.Printf("Converting %s to Minecraft commands...\n", inputFile)
+	
+	if len(worldOrigin) != 3 {
+		return fmt.Errorf("--origin needs three values x,y,z, got %d", len(worldOrigin))
+	}
+	
+	// Load palette
+	palette, err := loadPalette(cmd.Context())
 	if err != nil {
 		return err
 	}
 	
-	// Create pipeline
-	pipeline := &core.Pipeline{
-		Importer:  importer,
-		Voxelizer: core.NewSurfaceVoxelizer(),
-		Matcher:   core.NewCIELABMatcher(palette),
+	// Create pipeline (importer and exporter chosen by file extension)
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithInputFile(inputFile),
+		animationOption(),
+		core.WithOutputFile(outputFile),
+		core.WithVoxelizerName(voxelizerFor(inputFile)),
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithVoxelization(core.VoxelizationConfig{
+			Resolution:   resolution,
+			TargetSize:   targetSize,
+			UpAxis:       upAxis,
+			FlipZ:        mirror,
+			Conservative: conservative,
+			Fill:         fill,
+			Hollow:       hollow,
+			IsoLevel:     isoLevel,
+			MinThickness: minThickness,
+			Samples:      samples,
+			MinPoints:    minPoints,
+			HoleFill:     holeFill,
+			Lighting:     lighting,
+			Workers:      jobs,
+			Storage:      core.StorageConfig{Mode: storageMode},
+		}),
+		core.WithTransform(transform),
+		core.WithMorphology(morphology...),
+		core.WithDetailRegions(detailRegions...),
+		core.WithIslandRemoval(minIsland),
+		core.WithPostScale(postScale),
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithDithering(core.DitherConfig{
+			Enabled:   ditherEnable,
+			Algorithm: ditherAlgo,
+		}),
+		core.WithFunction(core.FunctionConfig{
+			Namespace:   namespace,
+			MaxCommands: maxCommands,
+			Boxes:       fillBoxes,
+			Origin:      [3]int{worldOrigin[0], worldOrigin[1], worldOrigin[2]},
+		}),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithDetail(detail),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return err
+	}
+	if ext := strings.ToLower(filepath.Ext(outputFile)); ext != ".mcfunction" && ext != ".zip" && ext != ".txt" {
+		return fmt.Errorf("output must be a .mcfunction file, a .zip datapack or a .txt WorldEdit script, got %q", outputFile)
+	}
+	
+	// Open input file
+	meshReader, err := storage.Open(cmd.Context(), inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer meshReader.Close()
+	
+	// Convert into the output file
+	if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+		return pipeline.ConvertCtx(cmd.Context(), meshReader, w)
+	}); err != nil {
+		endProgressLine(progress)
+		return err
+	}
+	
+	fmt.Printf("Successfully converted to %s\n", outputFile)
+	if reporter, ok := pipeline.Exporter.(core.CommandReporter); ok {
+		stats := reporter.CommandStats()
+		fmt.Printf("Placed %d blocks with %d commands\n", stats.Blocks, stats.Commands)
 	}
+	return nil
+}
+
+func runMeshToPreview(cmd *cobra.Command, args []string) error {
+	inputFile := args[0]
+	outputFile := args[1]
 	
-	// Configure
-	config := core.PipelineConfig{
-		Voxelization: core.VoxelizationConfig{
+	if ext := strings.ToLower(filepath.Ext(outputFile)); ext != ".obj" && ext != ".gltf" && ext != ".glb" {
+		return fmt.Errorf("output must be a .obj, .gltf or .glb file, got %q", outputFile)
+	}
+	
+	fmt.Printf("Converting %s to a voxel preview...\n", inputFile)
+	
+	// Load palette, unless previewing the unmatched colors
+	var palette *core.Palette
+	if previewMatch {
+		var err error
+		if palette, err = loadPalette(cmd.Context()); err != nil {
+			return err
+		}
+	}
+	
+	// Create pipeline (importer and exporter chosen by file extension)
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithInputFile(inputFile),
+		animationOption(),
+		core.WithOutputFile(outputFile),
+		core.WithVoxelizerName(voxelizerFor(inputFile)),
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithVoxelization(core.VoxelizationConfig{
 			Resolution:   resolution,
+			TargetSize:   targetSize,
+			UpAxis:       upAxis,
+			FlipZ:        mirror,
 			Conservative: conservative,
-		},
-		Dithering: core.DitherConfig{
+			Fill:         fill,
+			Hollow:       hollow,
+			IsoLevel:     isoLevel,
+			MinThickness: minThickness,
+			Samples:      samples,
+			MinPoints:    minPoints,
+			HoleFill:     holeFill,
+			Lighting:     lighting,
+			Workers:      jobs,
+			Storage:      core.StorageConfig{Mode: storageMode},
+		}),
+		core.WithTransform(transform),
+		core.WithMorphology(morphology...),
+		core.WithDetailRegions(detailRegions...),
+		core.WithIslandRemoval(minIsland),
+		core.WithPostScale(postScale),
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithDithering(core.DitherConfig{
 			Enabled:   ditherEnable,
 			Algorithm: ditherAlgo,
-		},
-		Palette: palette,
+		}),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return err
 	}
 	
-	// Convert
-	if err := pipeline.MeshToSchematic(meshReader, schematicWriter, config); err != nil {
-		return fmt.Errorf("conversion failed: %w", err)
+	// Open input file
+	meshReader, err := storage.Open(cmd.Context(), inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer meshReader.Close()
+	
+	// Convert into the output file
+	if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+		return pipeline.ConvertCtx(cmd.Context(), meshReader, w)
+	}); err != nil {
+		endProgressLine(progress)
+		return err
 	}
 	
 	fmt.Printf("Successfully converted to %s\n", outputFile)
 	return nil
 }
 
-func getImporter(filename string) (core.MeshImporter, error) {
-	ext := strings.ToLower(filepath.Ext(filename))
+func runMeshToWorld(cmd *cobra.Command, args []string) error {
+	inputFile := args[0]
+	worldDir := args[1]
+	
+	if len(worldOrigin) != 3 {
+		return fmt.Errorf("--origin needs three values x,y,z, got %d", len(worldOrigin))
+	}
+	if storage.IsRemote(worldDir) {
+		return fmt.Errorf("world directory must be local, got %q", worldDir)
+	}
+	
+	fmt.Printf("Converting %s into world %s...\n", inputFile, worldDir)
+	
+	// Load palette
+	palette, err := loadPalette(cmd.Context())
+	if err != nil {
+		return err
+	}
+	
+	// Create pipeline (importer chosen by file extension)
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithInputFile(inputFile),
+		animationOption(),
+		core.WithVoxelizerName(voxelizerFor(inputFile)),
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithVoxelization(core.VoxelizationConfig{
+			Resolution:   resolution,
+			TargetSize:   targetSize,
+			UpAxis:       upAxis,
+			FlipZ:        mirror,
+			Conservative: conservative,
+			Fill:         fill,
+			Hollow:       hollow,
+			IsoLevel:     isoLevel,
+			MinThickness: minThickness,
+			Samples:      samples,
+			MinPoints:    minPoints,
+			HoleFill:     holeFill,
+			Lighting:     lighting,
+			Workers:      jobs,
+			Storage:      core.StorageConfig{Mode: storageMode},
+		}),
+		core.WithTransform(transform),
+		core.WithMorphology(morphology...),
+		core.WithDetailRegions(detailRegions...),
+		core.WithIslandRemoval(minIsland),
+		core.WithPostScale(postScale),
+		core.WithAmbientOcclusion(bakeAO),
+		core.WithDithering(core.DitherConfig{
+			Enabled:   ditherEnable,
+			Algorithm: ditherAlgo,
+		}),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithDetail(detail),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return err
+	}
+	
+	// Open input file
+	meshReader, err := storage.Open(cmd.Context(), inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer meshReader.Close()
 	
-	switch ext {
-	case ".gltf", ".glb":
-		return core.NewGLTFImporter(), nil
-	case ".obj":
-		return nil, fmt.Errorf("OBJ importer not yet implemented")
-	default:
-		return nil, fmt.Errorf("unsupported file format: %s", ext)
+	// Voxelize and match, then write the chunks in place
+	grid, err := pipeline.MeshToVoxelGridCtx(cmd.Context(), meshReader, pipeline.Config)
+	if err == nil {
+		grid, err = pipeline.MatchColorsCtx(cmd.Context(), grid, pipeline.Config)
 	}
+	if err == nil {
+		exporter := core.NewWorldExporter([3]int{worldOrigin[0], worldOrigin[1], worldOrigin[2]})
+		exporter.Matcher = pipeline.Matcher
+		exporter.Detail = detail
+		exporter.Progress = progress
+		err = exporter.ExportCtx(cmd.Context(), grid, palette, worldDir)
+	}
+	if err != nil {
+		endProgressLine(progress)
+		return fmt.Errorf("conversion failed: %w", err)
+	}
+	
+	fmt.Printf("Successfully wrote %d blocks into %s\n", grid.Count(), worldDir)
+	return nil
 }
 
-func loadPalette() (*core.Palette, error) {
+func loadPalette(ctx context.Context) (*core.Palette, error) {
+	var palette *core.Palette
 	if paletteFile == "" {
 		// Use default vanilla palette
-		fmt.Println("Using default vanilla Minecraft palette")
-		blocks := core.GetVanillaMinecraftBlocks()
-		return core.GenerateMinecraftPalette(blocks), nil
+		if mcVersion == "" {
+			fmt.Println("Using default vanilla Minecraft palette")
+		} else {
+			fmt.Printf("Using default vanilla Minecraft palette for %s\n", mcVersion)
+		}
+		blocks, err := core.VanillaBlocks(mcVersion)
+		if err != nil {
+			return nil, err
+		}
+		palette = core.GenerateMinecraftPalette(blocks)
+	} else {
+		// Load from file
+		fmt.Printf("Loading palette from %s\n", paletteFile)
+		var err error
+		if palette, err = readPalette(ctx, paletteFile); err != nil {
+			return nil, err
+		}
 	}
 	
-	// Load from file
+	palette, err := filterPalette(palette)
+	if err != nil {
+		return nil, err
+	}
+	return weighPalette(ctx, palette)
+}
+
+// loadBlockColors loads the palette that colors imported blocks. Unlike
+// loadPalette it keeps every block, translucent ones included, since it only
+// looks colors up.
+func loadBlockColors(ctx context.Context) (*core.Palette, error) {
+	if paletteFile == "" {
+		return core.GenerateMinecraftPalette(core.GetVanillaMinecraftBlocks()), nil
+	}
 	fmt.Printf("Loading palette from %s\n", paletteFile)
-	f, err := os.Open(paletteFile)
+	return readPalette(ctx, paletteFile)
+}
+
+// filterPalette applies the --include-blocks, --exclude-blocks, --survival-only
+// and --allow-translucent flags. Translucent blocks are dropped unless allowed.
+func filterPalette(palette *core.Palette) (*core.Palette, error) {
+	filter := core.PaletteFilter{
+		Include:      includeBlocks,
+		Exclude:      excludeBlocks,
+		SurvivalOnly: survivalOnly,
+		OpaqueOnly:   !allowTranslucent,
+	}
+	if filter.IsEmpty() {
+		return palette, nil
+	}
+	filtered, err := filter.Apply(palette)
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter palette: %w", err)
+	}
+	if len(filtered.Colors) < len(palette.Colors) {
+		fmt.Printf("Palette filtered to %d of %d blocks\n", len(filtered.Colors), len(palette.Colors))
+	}
+	return filtered, nil
+}
+
+// weighPalette sets the weights and costs of the --block-weights file.
+func weighPalette(ctx context.Context, palette *core.Palette) (*core.Palette, error) {
+	if blockWeights == "" {
+		return palette, nil
+	}
+	r, err := storage.Open(ctx, blockWeights)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open palette file: %w", err)
+		return nil, fmt.Errorf("failed to open block weights: %w", err)
+	}
+	defer r.Close()
+	weights, err := core.LoadBlockWeightsJSON(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block weights: %w", err)
+	}
+	return weights.Apply(palette)
+}
+
+// gridImporter reads a voxel file as a grid.
+type gridImporter interface {
+	Import(r io.Reader) (*core.VoxelGrid, error)
+}
+
+// voxelFileImporter returns the importer for a MagicaVoxel (.vox), Qubicle
+// (.qb) or binvox file by its extension, and false for other files.
+func voxelFileImporter(file string) (gridImporter, bool) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".vox":
+		return core.NewVOXImporter(), true
+	case ".qb":
+		return core.NewQubicleImporter(), true
+	case ".binvox":
+		return core.NewBinvoxImporter(), true
+	}
+	return nil, false
+}
+
+// reportMaterials prints the blocks the exporter wrote and saves them to
+// --material-list, as JSON for a .json file and CSV otherwise.
+func reportMaterials(ctx context.Context, exporter core.GridExporter) error {
+	reporter, ok := exporter.(core.MaterialReporter)
+	if !ok || reporter.Materials() == nil {
+		return nil
 	}
-	defer f.Close()
+	report := reporter.Materials()
 	
-	palette, err := core.ImportPalette(f)
+	fmt.Printf("Materials (%d blocks):\n", report.Total)
+	for _, m := range report.Materials {
+		boxes := "shulker boxes"
+		if m.ShulkerBoxes == 1 {
+			boxes = "shulker box"
+		}
+		fmt.Printf("  %-36s %8d  (%d x 64 + %d, %d %s)", m.Block, m.Count, m.Stacks, m.Remainder, m.ShulkerBoxes, boxes)
+		if m.Cost > 0 {
+			fmt.Printf("  cost %g", m.Cost)
+		}
+		fmt.Println()
+	}
+	if report.TotalCost > 0 {
+		fmt.Printf("Estimated cost: %g\n", report.TotalCost)
+	}
+	
+	if materialList == "" {
+		return nil
+	}
+	if err := writeOutput(ctx, materialList, func(w io.Writer) error {
+		if strings.ToLower(filepath.Ext(materialList)) == ".json" {
+			return report.WriteJSON(w)
+		}
+		return report.WriteCSV(w)
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("Material list saved to %s\n", materialList)
+	return nil
+}
+
+// placementOptions returns the placement pass selected by --fix-gravity, or nil
+// when the flag is unset or colors are not matched against a palette.
+func placementOptions(palette *core.Palette) *core.PlacementOptions {
+	if fixGravity == "" || palette == nil {
+		return nil
+	}
+	return &core.PlacementOptions{Fix: fixGravity, OnIssues: printPlacementIssues}
+}
+
+// printPlacementIssues summarizes the blocks found unable to stay in place.
+func printPlacementIssues(issues []core.PlacementIssue) {
+	fixed := 0
+	for _, issue := range issues {
+		if issue.Fixed {
+			fixed++
+		}
+	}
+	if len(issues) == 0 {
+		fmt.Println("No floating or unsupported blocks found")
+		return
+	}
+	fmt.Printf("Fixed %d of %d floating or unsupported blocks\n", fixed, len(issues))
+}
+
+// convertFrames imports the model at each frame of --animation selected by the
+// frame flags, voxelizes the frames within their shared bounds so they line up,
+// and writes them as numbered copies of outputFile, <name>_000.<ext> first.
+func convertFrames(ctx context.Context, pipeline *core.Pipeline, inputFile, outputFile string) error {
+	animated, ok := pipeline.Importer.(core.AnimatedMeshImporter)
+	if !ok {
+		return fmt.Errorf("%s: format does not support animations", inputFile)
+	}
+	times, err := frameTimes()
 	if err != nil {
-		return nil, fmt.Errorf("failed to import palette: %w", err)
+		return err
 	}
 	
-	return palette, nil
+	meshes := make([]*core.Mesh, len(times))
+	for i, t := range times {
+		animated.SetAnimation(animationIndex, t)
+		meshReader, err := storage.Open(ctx, inputFile)
+		if err != nil {
+			return fmt.Errorf("failed to open input file: %w", err)
+		}
+		meshes[i], err = pipeline.ImportMeshCtx(ctx, meshReader, pipeline.Config)
+		meshReader.Close()
+		if err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+	}
+	grids, err := pipeline.VoxelizeFramesCtx(ctx, meshes, pipeline.Config)
+	if err != nil {
+		return err
+	}
+	
+	ext := filepath.Ext(outputFile)
+	base := strings.TrimSuffix(outputFile, ext)
+	for i, vg := range grids {
+		frameFile := fmt.Sprintf("%s_%03d%s", base, i, ext)
+		if err := writeOutput(ctx, frameFile, func(w io.Writer) error {
+			return pipeline.ExportGridCtx(ctx, vg, w)
+		}); err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+		fmt.Printf("Frame %d at %gs saved to %s\n", i, times[i], frameFile)
+	}
+	return nil
+}
+
+// exportRotations matches the grid against the palette once and writes it turned
+// to each side as <name>_north.<ext>, <name>_east.<ext> and so on, leaving the
+// last exporter in pipeline.Exporter for the material report.
+func exportRotations(ctx context.Context, pipeline *core.Pipeline, exporterName string, vg *core.VoxelGrid, outputFile string) error {
+	vg, err := pipeline.MatchColorsCtx(ctx, vg, pipeline.Config)
+	if err != nil {
+		return err
+	}
+	
+	ext := filepath.Ext(outputFile)
+	base := strings.TrimSuffix(outputFile, ext)
+	for turns, name := range core.RotationNames() {
+		config := pipeline.Config
+		config.Schematic.Rotation = turns
+		exporter, err := core.NewExporter(exporterName, config)
+		if err != nil {
+			return err
+		}
+		rotationFile := fmt.Sprintf("%s_%s%s", base, name, ext)
+		if err := writeOutput(ctx, rotationFile, func(w io.Writer) error {
+			return exporter.Export(vg, w)
+		}); err != nil {
+			return fmt.Errorf("%s rotation: %w", name, err)
+		}
+		pipeline.Exporter = exporter
+		fmt.Printf("Facing %s saved to %s\n", name, rotationFile)
+	}
+	return nil
+}
+
+// writeOutput runs convert against the output file or remote object, which is only
+// uploaded when convert succeeds.
+func writeOutput(ctx context.Context, outputFile string, convert func(w io.Writer) error) error {
+	var convertErr error
+	err := storage.Write(ctx, outputFile, func(w io.Writer) error {
+		convertErr = convert(w)
+		return convertErr
+	})
+	if convertErr != nil {
+		return fmt.Errorf("conversion failed: %w", convertErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+func TestMeshToVoxManyColors(t *testing.T) {
+	// A plane with a color gradient across it, far more colors than VOX holds
+	const n = 16
+	var ply strings.Builder
+	fmt.Fprintf(&ply, "ply\nformat ascii 1.0\nelement vertex %d\n", (n+1)*(n+1))
+	ply.WriteString("property float x\nproperty float y\nproperty float z\n")
+	ply.WriteString("property uchar red\nproperty uchar green\nproperty uchar blue\n")
+	fmt.Fprintf(&ply, "element face %d\nproperty list uchar int vertex_indices\nend_header\n", n*n)
+	for z := 0; z <= n; z++ {
+		for x := 0; x <= n; x++ {
+			fmt.Fprintf(&ply, "%d 0 %d %d %d 128\n", x, z, x*255/n, z*255/n)
+		}
+	}
+	for z := 0; z < n; z++ {
+		for x := 0; x < n; x++ {
+			i := z*(n+1) + x
+			fmt.Fprintf(&ply, "4 %d %d %d %d\n", i, i+1, i+n+2, i+n+1)
+		}
+	}
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "gradient.ply"), filepath.Join(dir, "gradient.vox")
+	if err := os.WriteFile(input, []byte(ply.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd.SetArgs([]string{"mesh-to-vox", input, output, "-r", "64", "--no-progress"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("mesh-to-vox: %v", err)
+	}
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	vg, err := core.NewVOXImporter().Import(f)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	colors := make(map[[3]uint8]bool)
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		colors[color] = true
+		return true
+	})
+	if vg.Count() != 64*64 || len(colors) < 200 || len(colors) > 255 {
+		t.Errorf("got %d voxels of %d colors, want %d voxels of up to 255 colors", vg.Count(), len(colors), 64*64)
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// Process exit codes returned by ExitCode.
+const (
+	exitFailure      = 1   // Conversion or I/O failure
+	exitUsage        = 2   // Bad arguments, flags or configuration
+	exitInvalidInput = 3   // Input file missing, unsupported or malformed
+	exitTooLarge     = 4   // Output would exceed a grid or palette limit
+	exitInterrupted  = 130 // Cancelled with Ctrl-C
+)
+
+// argsParsed is set once cobra has validated the command line, so errors returned
+// before that point are reported as usage errors.
+var argsParsed bool
+
+// ExitCode maps an error returned by Execute to a process exit code.
+func ExitCode(err error) int {
+	var formatErr *core.FormatError
+	switch {
+	case err == nil:
+		return 0
+	case !argsParsed, errors.Is(err, core.ErrInvalidConfig):
+		return exitUsage
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.Is(err, core.ErrGridTooLarge), errors.Is(err, core.ErrPaletteTooLarge):
+		return exitTooLarge
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, core.ErrUnsupportedFormat),
+		errors.Is(err, core.ErrEmptyMesh), errors.As(err, &formatErr):
+		return exitInvalidInput
+	}
+	return exitFailure
+}
+
+// ErrorMessage returns the text printed for an error returned by Execute, with a hint
+// on how to fix it where one applies.
+func ErrorMessage(err error) string {
+	msg := "Error: " + err.Error()
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "Interrupted"
+	case errors.Is(err, core.ErrGridTooLarge):
+		return msg + "\nHint: lower --resolution"
+	case errors.Is(err, core.ErrPaletteTooLarge):
+		return msg + "\nHint: reduce the colors with --palette, or write a schematic instead"
+	case errors.Is(err, core.ErrUnsupportedFormat):
+		return msg + fmt.Sprintf("\nHint: supported input formats are %s; output formats are %s",
+			strings.Join(core.ImporterExtensions(), ", "), strings.Join(core.ExporterExtensions(), ", "))
+	case errors.Is(err, core.ErrEmptyMesh):
+		return msg + "\nHint: the input contains no triangles to voxelize"
+	}
+	return msg
+}
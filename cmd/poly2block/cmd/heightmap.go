@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
+	"github.com/billstark001/poly2block/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	heightOverlay   string
+	heightRes       int
+	heightMax       int
+	heightNormalize bool
+	heightDepth     int
+)
+
+var heightmapToSchematicCmd = &cobra.Command{
+	Use:   "heightmap-to-schematic <heightmap> <output>",
+	Short: "Build terrain from a heightmap image",
+	Long: `Build a Minecraft schematic of terrain from a grayscale heightmap (PNG, JPEG,
+or 8- or 16-bit TIFF/GeoTIFF), one column per pixel with white highest, colored
+by an optional overlay image such as satellite imagery. Floating-point or signed
+elevation rasters must be converted to unsigned 16-bit first, for example with
+gdal_translate -ot UInt16 -scale.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runHeightmapToSchematic,
+}
+
+func init() {
+	heightmapToSchematicCmd.Flags().StringVar(&heightOverlay, "overlay", "", "Color image stretched over the terrain")
+	heightmapToSchematicCmd.Flags().IntVarP(&heightRes, "resolution", "r", 0, "Columns along the image's longer side (0 = one per pixel)")
+	heightmapToSchematicCmd.Flags().IntVar(&heightMax, "max-height", 64, "Height in blocks of the highest value")
+	heightmapToSchematicCmd.Flags().BoolVar(&heightNormalize, "normalize", false, "Stretch the image's lowest to highest value over --max-height")
+	heightmapToSchematicCmd.Flags().IntVar(&heightDepth, "depth", 0, "Blocks kept below the surface (0 = solid down to the bottom)")
+	addPostScaleFlag(heightmapToSchematicCmd)
+	addDitheringFlags(heightmapToSchematicCmd)
+	addPaletteFlags(heightmapToSchematicCmd)
+	addDetailFlags(heightmapToSchematicCmd)
+	addSchematicFlags(heightmapToSchematicCmd)
+}
+
+func runHeightmapToSchematic(cmd *cobra.Command, args []string) error {
+	inputFile := args[0]
+	outputFile := args[1]
+
+	fmt.Printf("Building terrain from %s...\n", inputFile)
+
+	exporter, err := schematicExporter()
+	if err != nil {
+		return err
+	}
+	if err := checkMaterialList(); err != nil {
+		return err
+	}
+	palette, err := loadPalette(cmd.Context())
+	if err != nil {
+		return err
+	}
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithDetail(detail),
+		core.WithDithering(core.DitherConfig{
+			Enabled:   ditherEnable,
+			Algorithm: ditherAlgo,
+		}),
+		schematicOption(),
+		core.WithExporterName(exporter),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return err
+	}
+
+	heightReader, err := storage.Open(cmd.Context(), inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer heightReader.Close()
+	var overlay io.Reader
+	if heightOverlay != "" {
+		overlayReader, err := storage.Open(cmd.Context(), heightOverlay)
+		if err != nil {
+			return fmt.Errorf("failed to open overlay: %w", err)
+		}
+		defer overlayReader.Close()
+		overlay = overlayReader
+	}
+
+	importer := core.NewHeightmapImporter()
+	importer.Resolution = heightRes
+	importer.MaxHeight = heightMax
+	importer.Normalize = heightNormalize
+	importer.Depth = heightDepth
+	importer.Progress = progress
+	grid, err := importer.Import(heightReader, overlay)
+	if err != nil {
+		endProgressLine(progress)
+		return fmt.Errorf("failed to import heightmap: %w", err)
+	}
+	if grid, err = grid.Rescale(postScale); err != nil {
+		return err
+	}
+	fmt.Printf("Terrain is %dx%dx%d blocks\n", grid.SizeX, grid.SizeY, grid.SizeZ)
+
+	if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+		return pipeline.ExportGridCtx(cmd.Context(), grid, w)
+	}); err != nil {
+		endProgressLine(progress)
+		return err
+	}
+
+	fmt.Printf("Successfully converted to %s\n", outputFile)
+	return reportMaterials(cmd.Context(), pipeline.Exporter)
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
+	"github.com/billstark001/poly2block/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	imageOrientation string
+	imageWidth       int
+	mapArt           string
+)
+
+var imageToSchematicCmd = &cobra.Command{
+	Use:   "image-to-schematic <image> <output>",
+	Short: "Build pixel art or map art from an image",
+	Long: `Build a one-block-thick picture from a PNG or JPEG image, one block per pixel,
+matched against the palette like a model's voxels (with dithering when enabled).
+Transparent pixels are left empty.
+
+With --map-art the picture lies flat and is matched against the colors the
+palette's blocks show on a Minecraft map; --map-art=staircase raises and lowers
+blocks against their northern neighbors so maps show each color in three
+shades. Map art gets an extra row to the north that the map does not show.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runImageToSchematic,
+}
+
+func init() {
+	imageToSchematicCmd.Flags().StringVar(&imageOrientation, "orientation", core.ImageWall, "Picture orientation ("+strings.Join(core.ImageOrientations(), ", ")+"); map art is always flat")
+	imageToSchematicCmd.Flags().IntVar(&imageWidth, "width", 0, "Blocks across, scaling the image (0 = one per pixel; 128 fills a map)")
+	imageToSchematicCmd.Flags().StringVar(&mapArt, "map-art", "", "Build map art: flat, or staircase for three shades of every map color")
+	imageToSchematicCmd.Flags().Lookup("map-art").NoOptDefVal = "flat"
+	addDitheringFlags(imageToSchematicCmd)
+	addPaletteFlags(imageToSchematicCmd)
+	addSchematicFlags(imageToSchematicCmd)
+}
+
+func runImageToSchematic(cmd *cobra.Command, args []string) error {
+	inputFile := args[0]
+	outputFile := args[1]
+
+	if mapArt != "" && mapArt != "flat" && mapArt != "staircase" {
+		return fmt.Errorf("unsupported map art mode %q (supported: flat, staircase)", mapArt)
+	}
+	fmt.Printf("Converting %s to Minecraft schematic...\n", inputFile)
+
+	exporter, err := schematicExporter()
+	if err != nil {
+		return err
+	}
+	if err := checkMaterialList(); err != nil {
+		return err
+	}
+	palette, err := loadPalette(cmd.Context())
+	if err != nil {
+		return err
+	}
+	config := core.ImageGridConfig{Orientation: imageOrientation, Width: imageWidth}
+	if mapArt != "" {
+		config.Orientation = core.ImageFloor
+		if palette, err = core.MapArtPalette(palette, mapArt == "staircase"); err != nil {
+			return err
+		}
+		fmt.Printf("Map art palette has %d colors\n", len(palette.Colors))
+	}
+	return exportImage(cmd, exporter, inputFile, outputFile, palette, config, mapArt != "")
+}
+
+// exportImage builds a picture from an image file and exports it. Map art is
+// matched against a MapArtPalette and built with core.BuildMapArt.
+func exportImage(cmd *cobra.Command, exporter, inputFile, outputFile string, palette *core.Palette, config core.ImageGridConfig, mapArt bool) error {
+	progress := newProgressReporter()
+	pipeline, err := core.NewPipeline(
+		core.WithMatcherName(matcher),
+		core.WithMatchWeights(matchWeights),
+		core.WithPalette(palette),
+		core.WithPlacement(placementOptions(palette)),
+		core.WithLightBlocks(lightBlocks),
+		core.WithDithering(core.DitherConfig{
+			Enabled:   ditherEnable,
+			Algorithm: ditherAlgo,
+		}),
+		schematicOption(),
+		core.WithExporterName(exporter),
+		core.WithProgress(progress),
+	)
+	if err != nil {
+		return err
+	}
+
+	r, err := storage.Open(cmd.Context(), inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer r.Close()
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	grid, err := core.NewImageGrid(img, config)
+	if err != nil {
+		return err
+	}
+
+	if mapArt {
+		// Match first, as the shades matched decide the heights; placement is
+		// checked once the staircase is built
+		matchConfig := pipeline.Config
+		matchConfig.Placement = nil
+		if grid, err = pipeline.MatchColorsCtx(cmd.Context(), grid, matchConfig); err != nil {
+			endProgressLine(progress)
+			return err
+		}
+		if grid, err = core.BuildMapArt(grid, palette); err != nil {
+			return err
+		}
+		// Colors are palette entries now, and must not be dithered again
+		pipeline.Config.Dithering.Enabled = false
+	}
+	fmt.Printf("Picture is %dx%dx%d blocks\n", grid.SizeX, grid.SizeY, grid.SizeZ)
+
+	if err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+		return pipeline.ExportGridCtx(cmd.Context(), grid, w)
+	}); err != nil {
+		endProgressLine(progress)
+		return err
+	}
+
+	fmt.Printf("Successfully converted to %s\n", outputFile)
+	return reportMaterials(cmd.Context(), pipeline.Exporter)
+}
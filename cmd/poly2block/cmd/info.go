@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
+	"github.com/billstark001/poly2block/core"
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info <file>",
+	Short: "Show the contents of a mesh, VOX, Qubicle, binvox or schematic file",
+	Long: `Print vertex and triangle counts, bounds and materials of a mesh (OBJ, PLY,
+glTF, FBX, DAE); the dimensions and voxel count of a MagicaVoxel VOX, Qubicle (.qb) or
+binvox file; or the dimensions, palette and block counts of a Sponge schematic (.schem).`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInfo,
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	file := args[0]
+	if importer, ok := voxelFileImporter(file); ok {
+		return voxInfo(cmd.Context(), file, importer)
+	}
+	if strings.ToLower(filepath.Ext(file)) == ".schem" {
+		return schematicInfo(cmd.Context(), file)
+	}
+	return meshInfo(cmd.Context(), file)
+}
+
+func meshInfo(ctx context.Context, file string) error {
+	// The pipeline picks the importer by extension
+	pipeline, err := core.NewPipeline(core.WithInputFile(file))
+	if err != nil {
+		return err
+	}
+	r, err := storage.Open(ctx, file)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer r.Close()
+	mesh, err := pipeline.ImportMeshCtx(ctx, r, pipeline.Config)
+	if err != nil {
+		return err
+	}
+	mesh.CalculateBounds()
+	lo, hi := mesh.Bounds.Min, mesh.Bounds.Max
+
+	fmt.Printf("File:       %s (mesh)\n", file)
+	fmt.Printf("Vertices:   %d\n", len(mesh.Vertices))
+	fmt.Printf("Triangles:  %d\n", len(mesh.Faces))
+	fmt.Printf("Bounds:     (%g, %g, %g) to (%g, %g, %g)\n", lo[0], lo[1], lo[2], hi[0], hi[1], hi[2])
+	fmt.Printf("Size:       %g x %g x %g\n", hi[0]-lo[0], hi[1]-lo[1], hi[2]-lo[2])
+	if mesh.HasVertexColors {
+		fmt.Println("Colors:     per vertex")
+	}
+	fmt.Printf("Materials (%d):\n", len(mesh.Materials))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, m := range mesh.Materials {
+		name := m.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		texture := m.TexturePath
+		if texture == "" && m.Texture != nil {
+			texture = "(embedded texture)"
+		}
+		c := m.DiffuseColor
+		fmt.Fprintf(tw, "  %s\t#%02x%02x%02x\t%s\n", name, uint8(c[0]*255), uint8(c[1]*255), uint8(c[2]*255), texture)
+	}
+	return tw.Flush()
+}
+
+func voxInfo(ctx context.Context, file string, importer gridImporter) error {
+	r, err := storage.Open(ctx, file)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer r.Close()
+	grid, err := importer.Import(r)
+	if err != nil {
+		return err
+	}
+
+	colors := make(map[[3]uint8]bool)
+	grid.Range(func(_, _, _ int, color [3]uint8) bool {
+		colors[color] = true
+		return true
+	})
+	format := "VOX"
+	switch importer.(type) {
+	case *core.QubicleImporterImpl:
+		format = "Qubicle"
+	case *core.BinvoxImporterImpl:
+		format = "binvox"
+	}
+	fmt.Printf("File:       %s (%s)\n", file, format)
+	fmt.Printf("Size:       %d x %d x %d (width x height x length)\n", grid.SizeX, grid.SizeY, grid.SizeZ)
+	fmt.Printf("Voxels:     %d\n", grid.Count())
+	fmt.Printf("Colors:     %d\n", len(colors))
+	return nil
+}
+
+func schematicInfo(ctx context.Context, file string) error {
+	r, err := storage.Open(ctx, file)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer r.Close()
+	importer := core.NewSchematicImporter()
+	grid, err := importer.Import(r)
+	if err != nil {
+		return err
+	}
+
+	report := importer.Materials()
+	fmt.Printf("File:       %s (schematic)\n", file)
+	fmt.Printf("Size:       %d x %d x %d (width x height x length)\n", grid.SizeX, grid.SizeY, grid.SizeZ)
+	fmt.Printf("Blocks:     %d\n", report.Total)
+	fmt.Printf("Palette (%d in use):\n", len(report.Materials))
+	for _, m := range report.Materials {
+		fmt.Printf("  %-36s %8d\n", m.Block, m.Count)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/billstark001/poly2block/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mapTiles string
+	mapFlat  bool
+)
+
+var mapArtCmd = &cobra.Command{
+	Use:   "map-art <image> <output>",
+	Short: "Build staircased map art from an image",
+	Long: `Build map art from a PNG or JPEG image, scaled to fill whole maps of 128x128
+blocks. Blocks are raised and lowered against their northern neighbors in a
+staircase, so a map shows every color in three shades; --flat builds level map
+art with one shade per color instead.
+
+Without --palette the image is matched against a bundled palette of one block
+for every map color. A palette file limits the blocks to those it holds that
+maps show in a known color.
+
+The picture has an extra row to the north that the map does not show. Unless
+--paste-origin is given, //paste places the picture's north-west corner at the
+player, so paste standing on the north-west corner of a map's area.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMapArt,
+}
+
+func init() {
+	mapArtCmd.Flags().StringVar(&mapTiles, "maps", "1x1", "Maps across and down, as WxH; the image is stretched to fill them")
+	mapArtCmd.Flags().BoolVar(&mapFlat, "flat", false, "Build flat map art, one shade per map color")
+	addDitheringFlags(mapArtCmd)
+	addPaletteFlags(mapArtCmd)
+	addSchematicFlags(mapArtCmd)
+}
+
+func runMapArt(cmd *cobra.Command, args []string) error {
+	inputFile := args[0]
+	outputFile := args[1]
+
+	across, down, err := parseMapTiles(mapTiles)
+	if err != nil {
+		return err
+	}
+	if !cmd.Flags().Changed("paste-origin") {
+		// Keep the unmapped north row off the map's area
+		pasteOrigin = "0,0,-1"
+	}
+	if !cmd.Flags().Changed("allow-translucent") {
+		// A map shows translucent blocks such as leaves in their map color too
+		allowTranslucent = true
+	}
+	fmt.Printf("Converting %s to %dx%d maps of map art...\n", inputFile, across, down)
+
+	exporter, err := schematicExporter()
+	if err != nil {
+		return err
+	}
+	if err := checkMaterialList(); err != nil {
+		return err
+	}
+	var palette *core.Palette
+	if paletteFile == "" {
+		fmt.Println("Using the bundled map color palette")
+		if palette, err = filterPalette(core.MapColorPalette()); err == nil {
+			palette, err = weighPalette(cmd.Context(), palette)
+		}
+	} else {
+		palette, err = loadPalette(cmd.Context())
+	}
+	if err != nil {
+		return err
+	}
+	if palette, err = core.MapArtPalette(palette, !mapFlat); err != nil {
+		return err
+	}
+	fmt.Printf("Map art palette has %d colors\n", len(palette.Colors))
+
+	config := core.ImageGridConfig{
+		Orientation: core.ImageFloor,
+		Width:       across * core.MapSize,
+		Height:      down * core.MapSize,
+	}
+	return exportImage(cmd, exporter, inputFile, outputFile, palette, config, true)
+}
+
+// parseMapTiles parses the --maps flag: the number of maps across and down,
+// as WxH.
+func parseMapTiles(s string) (int, int, error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	across, errW := strconv.Atoi(w)
+	down, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil || across < 1 || down < 1 {
+		return 0, 0, fmt.Errorf("invalid --maps %q: want WxH with at least one map each way, such as 2x1", s)
+	}
+	return across, down, nil
+}
@@ -1,9 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
 	"github.com/billstark001/poly2block/core"
 	"github.com/spf13/cobra"
 )
@@ -14,13 +24,26 @@ var (
 	resourcePack    string
 	jarFile         string
 	exportJSON      string
+	swatchFile      string
+	swatchColumns   int
+	mergePolicy     string
+	biome           string
+	fromImage       string
+	paletteSize     int
+	paletteColors   []string
+	reduceMax       int
 )
 
 var generatePaletteCmd = &cobra.Command{
 	Use:   "generate-palette",
 	Short: "Generate CIELAB color palette for Minecraft blocks",
 	Long: `Generate a CIELAB color space palette file for Minecraft blocks.
-The palette can be used for color matching when converting meshes to schematics.`,
+The palette can be used for color matching when converting meshes to schematics.
+
+With --from-image or --colors an abstract palette without blocks is generated
+instead: the --size colors best representing an image, found by k-means
+clustering, or the colors listed. Such palettes reduce the colors of VOX output,
+such as mesh-to-vox --palette.`,
 	RunE: runGeneratePalette,
 }
 
@@ -28,29 +51,106 @@ var extractPaletteCmd = &cobra.Command{
 	Use:   "extract-palette",
 	Short: "Extract palette from Minecraft resource pack or jar",
 	Long: `Extract block colors from Minecraft resource pack (zip or directory) or jar file.
-This analyzes textures and generates accurate color information.`,
+This analyzes textures and generates accurate color information. Grass, leaves
+and water are tinted as they appear in the --biome biome.`,
 	RunE: runExtractPalette,
 }
 
+var paletteCmd = &cobra.Command{
+	Use:   "palette",
+	Short: "Inspect palette files",
+}
+
+var paletteShowCmd = &cobra.Command{
+	Use:   "show <palette>",
+	Short: "List the blocks of a palette",
+	Long: `List the blocks of a palette file with their RGB and CIELAB values, and
+optionally render them as a PNG grid of color swatches in the same order.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPaletteShow,
+}
+
+var paletteMergeCmd = &cobra.Command{
+	Use:   "merge <palette>... -o <output>",
+	Short: "Combine palettes into one",
+	Long: `Combine palettes, in order, into one with a single entry per block. Entries
+repeating a block with the same color are dropped; --on-conflict decides which
+entry stays when the colors differ.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runPaletteMerge,
+}
+
+var paletteReduceCmd = &cobra.Command{
+	Use:   "reduce <palette> -o <output>",
+	Short: "Keep the blocks that best represent a palette",
+	Long: `Reduce a palette to at most --max blocks, for palettes such as a full jar
+extraction whose hundreds of blocks slow matching and make builds noisy. Blocks
+are clustered by color in CIELAB, and each cluster keeps the block most typical
+of it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPaletteReduce,
+}
+
+var paletteDiffCmd = &cobra.Command{
+	Use:   "diff <palette> <other>",
+	Short: "Show the blocks two palettes differ in",
+	Long: `List the blocks the second palette adds (+), lacks (-) and gives another
+color (~) compared to the first, such as a resource pack's palette against vanilla.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runPaletteDiff,
+}
+
 func init() {
-	generatePaletteCmd.Flags().StringVarP(&outputFile, "output", "o", "palette.msgpack", "Output palette file")
+	paletteShowCmd.Flags().StringVar(&swatchFile, "swatch", "", "Also render the colors as a PNG swatch grid")
+	paletteShowCmd.Flags().IntVar(&swatchColumns, "columns", 16, "Swatches per row in the PNG")
+	paletteCmd.AddCommand(paletteShowCmd)
+	
+	paletteMergeCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output palette file (.msgpack, .json or .csv)")
+	paletteMergeCmd.Flags().StringVar(&mergePolicy, "on-conflict", core.MergeKeepFirst, "Entry kept when palettes give a block different colors ("+strings.Join(core.MergePolicies(), ", ")+")")
+	paletteMergeCmd.MarkFlagRequired("output")
+	paletteCmd.AddCommand(paletteMergeCmd)
+	paletteCmd.AddCommand(paletteDiffCmd)
+	
+	paletteReduceCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output palette file (.msgpack, .json or .csv)")
+	paletteReduceCmd.Flags().IntVar(&reduceMax, "max", 128, "Blocks kept at most")
+	paletteReduceCmd.MarkFlagRequired("output")
+	paletteCmd.AddCommand(paletteReduceCmd)
+	
+	generatePaletteCmd.Flags().StringVarP(&outputFile, "output", "o", "palette.msgpack", "Output palette file (.msgpack, .json or .csv)")
 	generatePaletteCmd.Flags().BoolVar(&vanillaBlocks, "vanilla", true, "Include vanilla Minecraft blocks")
+	addMCVersionFlag(generatePaletteCmd)
 	generatePaletteCmd.Flags().StringVar(&customBlocks, "custom", "", "Custom blocks definition file (JSON)")
+	generatePaletteCmd.Flags().StringVar(&fromImage, "from-image", "", "Generate an abstract palette of an image's colors instead (PNG or JPEG)")
+	generatePaletteCmd.Flags().IntVar(&paletteSize, "size", 64, "Colors picked from --from-image")
+	generatePaletteCmd.Flags().StringSliceVar(&paletteColors, "colors", nil, "Generate an abstract palette of these #rrggbb colors instead")
+	generatePaletteCmd.MarkFlagsMutuallyExclusive("from-image", "colors")
 	
-	extractPaletteCmd.Flags().StringVarP(&outputFile, "output", "o", "palette.msgpack", "Output palette file")
+	extractPaletteCmd.Flags().StringVarP(&outputFile, "output", "o", "palette.msgpack", "Output palette file (.msgpack, .json or .csv)")
 	extractPaletteCmd.Flags().StringVar(&resourcePack, "resource-pack", "", "Path to resource pack (zip or directory)")
 	extractPaletteCmd.Flags().StringVar(&jarFile, "jar", "", "Path to Minecraft jar file")
 	extractPaletteCmd.Flags().StringVar(&exportJSON, "export-json", "", "Also export blocks as JSON")
+	extractPaletteCmd.Flags().StringVar(&biome, "biome", core.BiomePlains, "Biome whose grass, foliage and water colors tint textures")
 }
 
 func runGeneratePalette(cmd *cobra.Command, args []string) error {
+	if fromImage != "" || len(paletteColors) > 0 {
+		return generateAbstractPalette(cmd.Context())
+	}
 	fmt.Println("Generating Minecraft block palette...")
 	
 	var blocks []core.MinecraftBlock
 	
 	if vanillaBlocks {
-		fmt.Println("Including vanilla Minecraft blocks")
-		blocks = append(blocks, core.GetVanillaMinecraftBlocks()...)
+		if mcVersion == "" {
+			fmt.Println("Including vanilla Minecraft blocks")
+		} else {
+			fmt.Printf("Including vanilla Minecraft blocks for %s\n", mcVersion)
+		}
+		vanilla, err := core.VanillaBlocks(mcVersion)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, vanilla...)
 	}
 	
 	if customBlocks != "" {
@@ -70,13 +170,9 @@ func runGeneratePalette(cmd *cobra.Command, args []string) error {
 	palette := core.GenerateMinecraftPalette(blocks)
 	
 	// Export to file
-	outFile, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer outFile.Close()
-	
-	if err := core.ExportPalette(palette, outFile); err != nil {
+	if err := storage.Write(cmd.Context(), outputFile, func(w io.Writer) error {
+		return exportPalette(outputFile, palette, w)
+	}); err != nil {
 		return fmt.Errorf("failed to export palette: %w", err)
 	}
 	
@@ -86,12 +182,56 @@ func runGeneratePalette(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// generateAbstractPalette writes a palette of the --from-image image's colors
+// or of the --colors list.
+func generateAbstractPalette(ctx context.Context) error {
+	var palette *core.Palette
+	if fromImage != "" {
+		fmt.Printf("Picking %d colors from %s...\n", paletteSize, fromImage)
+		r, err := storage.Open(ctx, fromImage)
+		if err != nil {
+			return fmt.Errorf("failed to open image: %w", err)
+		}
+		defer r.Close()
+		img, _, err := image.Decode(r)
+		if err != nil {
+			return fmt.Errorf("failed to decode image: %w", err)
+		}
+		if palette, err = core.GeneratePaletteFromImage(img, paletteSize); err != nil {
+			return err
+		}
+	} else {
+		colors := make([][3]uint8, len(paletteColors))
+		for i, s := range paletteColors {
+			rgb, err := parseHexColor(s)
+			if err != nil {
+				return err
+			}
+			colors[i] = rgb
+		}
+		var err error
+		if palette, err = core.GeneratePaletteFromColors(colors); err != nil {
+			return err
+		}
+	}
+	
+	if err := storage.Write(ctx, outputFile, func(w io.Writer) error {
+		return exportPalette(outputFile, palette, w)
+	}); err != nil {
+		return fmt.Errorf("failed to export palette: %w", err)
+	}
+	fmt.Printf("Successfully generated palette with %d colors\n", len(palette.Colors))
+	fmt.Printf("Saved to %s\n", outputFile)
+	return nil
+}
+
 func runExtractPalette(cmd *cobra.Command, args []string) error {
 	if resourcePack == "" && jarFile == "" {
 		return fmt.Errorf("must specify either --resource-pack or --jar")
 	}
 	
 	extractor := core.NewTextureExtractor()
+	extractor.Biome = biome
 	var blocks []core.MinecraftBlock
 	var err error
 	
@@ -127,18 +267,200 @@ func runExtractPalette(cmd *cobra.Command, args []string) error {
 	palette := core.GenerateMinecraftPalette(blocks)
 	
 	// Export to file
-	outFile, err := os.Create(outputFile)
+	if err := storage.Write(cmd.Context(), outputFile, func(w io.Writer) error {
+		return exportPalette(outputFile, palette, w)
+	}); err != nil {
+		return fmt.Errorf("failed to export palette: %w", err)
+	}
+	
+	fmt.Printf("Successfully generated palette with %d colors\n", len(palette.Colors))
+	fmt.Printf("Saved to %s\n", outputFile)
+	
+	return nil
+}
+
+func runPaletteShow(cmd *cobra.Command, args []string) error {
+	if swatchColumns <= 0 {
+		return fmt.Errorf("%w: --columns must be positive, got %d", core.ErrInvalidConfig, swatchColumns)
+	}
+	
+	palette, err := readPalette(cmd.Context(), args[0])
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return err
 	}
-	defer outFile.Close()
 	
-	if err := core.ExportPalette(palette, outFile); err != nil {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tBLOCK\tRGB\tL\ta\tb")
+	for i, c := range palette.Colors {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%.3f\t%.3f\t%.3f\n",
+			i, paletteBlock(&c), hexColor(c.RGB), c.LAB.L, c.LAB.A, c.LAB.B)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d colors\n", len(palette.Colors))
+	
+	if swatchFile == "" {
+		return nil
+	}
+	if err := storage.Write(cmd.Context(), swatchFile, func(w io.Writer) error {
+		return png.Encode(w, renderSwatches(palette, swatchColumns))
+	}); err != nil {
+		return fmt.Errorf("failed to write swatches: %w", err)
+	}
+	fmt.Printf("Saved swatches to %s\n", swatchFile)
+	return nil
+}
+
+func runPaletteMerge(cmd *cobra.Command, args []string) error {
+	var palettes []*core.Palette
+	for _, name := range args {
+		palette, err := readPalette(cmd.Context(), name)
+		if err != nil {
+			return err
+		}
+		palettes = append(palettes, palette)
+	}
+	merged, err := core.MergePalettes(mergePolicy, palettes...)
+	if err != nil {
+		return err
+	}
+	
+	if err := storage.Write(cmd.Context(), outputFile, func(w io.Writer) error {
+		return exportPalette(outputFile, merged, w)
+	}); err != nil {
 		return fmt.Errorf("failed to export palette: %w", err)
 	}
+	total := 0
+	for _, palette := range palettes {
+		total += len(palette.Colors)
+	}
+	fmt.Printf("Merged %d palettes (%d colors) into %d colors\n", len(palettes), total, len(merged.Colors))
+	fmt.Printf("Saved to %s\n", outputFile)
+	return nil
+}
+
+func runPaletteReduce(cmd *cobra.Command, args []string) error {
+	palette, err := readPalette(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	reduced, err := core.ReducePalette(palette, reduceMax)
+	if err != nil {
+		return err
+	}
 	
-	fmt.Printf("Successfully generated palette with %d colors\n", len(palette.Colors))
+	if err := storage.Write(cmd.Context(), outputFile, func(w io.Writer) error {
+		return exportPalette(outputFile, reduced, w)
+	}); err != nil {
+		return fmt.Errorf("failed to export palette: %w", err)
+	}
+	fmt.Printf("Reduced %d colors to %d\n", len(palette.Colors), len(reduced.Colors))
 	fmt.Printf("Saved to %s\n", outputFile)
+	return nil
+}
+
+func runPaletteDiff(cmd *cobra.Command, args []string) error {
+	a, err := readPalette(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	b, err := readPalette(cmd.Context(), args[1])
+	if err != nil {
+		return err
+	}
 	
+	diff := core.DiffPalettes(a, b)
+	for _, c := range diff.Added {
+		fmt.Printf("+ %-40s %s\n", paletteBlock(&c), hexColor(c.RGB))
+	}
+	for _, c := range diff.Removed {
+		fmt.Printf("- %-40s %s\n", paletteBlock(&c), hexColor(c.RGB))
+	}
+	for _, c := range diff.Changed {
+		fmt.Printf("~ %-40s %s -> %s\n", paletteBlock(&c.Old), hexColor(c.Old.RGB), hexColor(c.New.RGB))
+	}
+	fmt.Printf("%d added, %d removed, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
 	return nil
 }
+
+// readPalette opens and imports a palette file in the format of its extension.
+func readPalette(ctx context.Context, name string) (*core.Palette, error) {
+	f, err := storage.Open(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open palette file: %w", err)
+	}
+	defer f.Close()
+	palette, err := importPalette(name, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import palette: %w", err)
+	}
+	return palette, nil
+}
+
+// paletteBlock returns the block ID of a palette entry, or its name without one.
+func paletteBlock(c *core.PaletteColor) string {
+	if id, ok := c.Metadata["block_id"].(string); ok {
+		return id
+	}
+	return c.Name
+}
+
+func hexColor(rgb [3]uint8) string {
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+// parseHexColor parses a "#rrggbb" color; the # is optional.
+func parseHexColor(s string) ([3]uint8, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return [3]uint8{}, fmt.Errorf("invalid color %q: want #rrggbb", s)
+	}
+	return [3]uint8{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// importPalette reads a palette in the format named by the file extension: JSON
+// for .json, CSV for .csv and msgpack otherwise.
+func importPalette(name string, r io.Reader) (*core.Palette, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return core.ImportPaletteJSON(r)
+	case ".csv":
+		return core.ImportPaletteCSV(r)
+	}
+	return core.ImportPalette(r)
+}
+
+// exportPalette writes a palette in the format named by the file extension, like
+// importPalette.
+func exportPalette(name string, palette *core.Palette, w io.Writer) error {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return core.ExportPaletteJSON(palette, w)
+	case ".csv":
+		return core.ExportPaletteCSV(palette, w)
+	}
+	return core.ExportPalette(palette, w)
+}
+
+// swatchSize is the side of one palette color in the swatch PNG, in pixels.
+const swatchSize = 16
+
+// renderSwatches draws the palette colors as squares, row by row, with a
+// one-pixel gap between them.
+func renderSwatches(palette *core.Palette, columns int) image.Image {
+	columns = min(columns, max(len(palette.Colors), 1))
+	rows := (len(palette.Colors) + columns - 1) / columns
+	img := image.NewRGBA(image.Rect(0, 0, columns*(swatchSize+1)+1, max(rows, 1)*(swatchSize+1)+1))
+	for i, c := range palette.Colors {
+		x0, y0 := i%columns*(swatchSize+1)+1, i/columns*(swatchSize+1)+1
+		fill := color.RGBA{c.RGB[0], c.RGB[1], c.RGB[2], 255}
+		for y := y0; y < y0+swatchSize; y++ {
+			for x := x0; x < x0+swatchSize; x++ {
+				img.SetRGBA(x, y, fill)
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+const progressBarWidth = 30
+
+// progressBar renders pipeline progress events as a single-line bar per stage.
+type progressBar struct {
+	w    io.Writer
+	open bool // a bar has been drawn without its terminating newline
+}
+
+// newProgressReporter returns a terminal progress bar, or nil when progress output is
+// disabled or stderr is not a terminal (pipes and CI logs would collect every redraw).
+func newProgressReporter() core.ProgressReporter {
+	if noProgress {
+		return nil
+	}
+	if info, err := os.Stderr.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return &progressBar{w: os.Stderr}
+}
+
+// Report implements core.ProgressReporter.
+func (p *progressBar) Report(e core.ProgressEvent) {
+	percent := e.Percent()
+	if percent < 0 {
+		fmt.Fprintf(p.w, "\r%-9s %d", e.Stage, e.Current)
+	} else {
+		filled := int(percent / 100 * progressBarWidth)
+		bar := strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled)
+		fmt.Fprintf(p.w, "\r%-9s [%s] %3.0f%%", e.Stage, bar, percent)
+	}
+	p.open = true
+	if e.Type == core.StageFinished {
+		fmt.Fprintln(p.w)
+		p.open = false
+	}
+}
+
+// endProgressLine terminates a partially drawn bar so a following error message
+// starts on its own line.
+func endProgressLine(reporter core.ProgressReporter) {
+	if p, ok := reporter.(*progressBar); ok && p.open {
+		fmt.Fprintln(p.w)
+		p.open = false
+	}
+}
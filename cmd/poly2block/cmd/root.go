@@ -1,8 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/billstark001/poly2block/core"
 	"github.com/spf13/cobra"
 )
 
@@ -13,50 +21,505 @@ var (
 var rootCmd = &cobra.Command{
 	Use:   "poly2block",
 	Short: "Convert polygon meshes to voxels and Minecraft schematics",
-	Long: `poly2block is a tool for converting 3D polygon meshes (OBJ, glTF) to voxel formats
+	Long: `poly2block is a tool for converting 3D polygon meshes (OBJ, PLY, glTF, FBX, DAE) to voxel formats
 and Minecraft schematics using CIELAB color matching for accurate block selection.`,
 	Version: version,
+	// Execute's caller prints errors; usage is only shown for command-line mistakes.
+	SilenceErrors: true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		argsParsed = true
+		cmd.SilenceUsage = true
+	},
 }
 
-// Execute runs the root command
+// Execute runs the root command, cancelling running conversions on Ctrl-C
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "Disable progress output")
 	
 	// Add subcommands
 	rootCmd.AddCommand(meshToVoxCmd)
 	rootCmd.AddCommand(voxToSchematicCmd)
+	rootCmd.AddCommand(schematicToVoxCmd)
+	rootCmd.AddCommand(litematicToVoxCmd)
+	rootCmd.AddCommand(heightmapToSchematicCmd)
+	rootCmd.AddCommand(imageToSchematicCmd)
+	rootCmd.AddCommand(mapArtCmd)
 	rootCmd.AddCommand(meshToSchematicCmd)
+	rootCmd.AddCommand(meshToStructureCmd)
+	rootCmd.AddCommand(meshToCommandsCmd)
+	rootCmd.AddCommand(meshToWorldCmd)
+	rootCmd.AddCommand(meshToPreviewCmd)
 	rootCmd.AddCommand(generatePaletteCmd)
 	rootCmd.AddCommand(extractPaletteCmd)
+	rootCmd.AddCommand(paletteCmd)
 	rootCmd.AddCommand(convertCmd)
+	rootCmd.AddCommand(batchCmd)
+	rootCmd.AddCommand(composeCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(serveCmd)
 }
 
 // Common flags
 var (
-	resolution   int
-	conservative bool
-	ditherEnable bool
-	ditherAlgo   string
-	paletteFile  string
-	outputFile   string
+	resolution       int
+	targetSize       [3]int
+	upAxis           string
+	mirror           bool
+	transform        core.TransformConfig
+	morphology       []core.MorphStep
+	detailRegions    []core.DetailRegion
+	minIsland        int
+	postScale        float64
+	bakeAO           float64
+	conservative     bool
+	fill             bool
+	hollow           int
+	isoLevel         float64
+	minThickness     int
+	samples          int
+	jobs             int
+	storageMode      core.StorageMode
+	voxelizer        string
+	lightBlocks      bool
+	minPoints        int
+	animationIndex   int
+	animationTime    float64
+	animationEnd     float64
+	animationFPS     float64
+	holeFill         int
+	lighting         core.LightingConfig
+	matcher          string
+	matchWeights     core.MatchWeights
+	ditherEnable     bool
+	ditherAlgo       string
+	paletteFile      string
+	mcVersion        string
+	excludeBlocks    []string
+	includeBlocks    []string
+	survivalOnly     bool
+	allowTranslucent bool
+	blockWeights     string
+	fixGravity       string
+	detail           string
+	schemVersion     int
+	schemFormat      string
+	airMode          string
+	pasteOrigin      string
+	rotations        bool
+	materialList     string
+	namespace        string
+	maxCommands      int
+	fillBoxes        bool
+	worldOrigin      []int
+	previewMatch     bool
+	outputFile       string
+	noProgress       bool
 )
 
 func addVoxelizationFlags(cmd *cobra.Command) {
 	cmd.Flags().IntVarP(&resolution, "resolution", "r", 128, "Voxel resolution (voxels along longest axis)")
+	cmd.Flags().Var(sizeValue{&targetSize}, "size", "Fit the model within x,y,z voxels, 0 leaving an axis uncapped (e.g. 100,255,0); overrides --resolution")
+	cmd.Flags().StringVar(&upAxis, "up-axis", core.UpAxisY, "Model axis pointing up ("+strings.Join(core.UpAxes(), ", ")+"); use z for models from Z-up tools that come out lying on their side")
+	cmd.Flags().BoolVar(&mirror, "mirror", false, "Mirror the model front to back, for left-handed models that come out reversed")
+	cmd.Flags().Var(vec3Value{&transform.Scale, true}, "scale", "Scale the model by one factor or by x,y,z factors before voxelizing")
+	cmd.Flags().Var(vec3Value{&transform.Rotate, false}, "rotate", "Rotate the model by x,y,z degrees, about x first and z last")
+	cmd.Flags().Var(vec3Value{&transform.Translate, false}, "translate", "Move the model by x,y,z units, which shifts the grid origin")
+	cmd.Flags().Var(morphValue{&morphology}, "morph", "Morphology run after voxelizing, as op[:radius[:shape]] ("+strings.Join(core.MorphOps(), ", ")+"; shapes "+strings.Join(core.ElementShapes(), ", ")+"), e.g. close:1 to fill pinholes; repeat for several")
+	cmd.Flags().Var(regionValue{&detailRegions}, "refine", "Voxelize part of the model finer, resolving its voxels into stairs and slabs with --detail, as x0,y0,z0,x1,y1,z1[:scale] in voxels or group[:scale] naming an OBJ object or glTF node (scale 2 to "+strconv.Itoa(core.MaxDetailScale)+", default 4); repeat for several")
+	cmd.Flags().IntVar(&minIsland, "remove-islands", 0, "Delete floating fragments of fewer than this many voxels, keeping the largest part (0 = keep all)")
+	addPostScaleFlag(cmd)
+	addAOFlag(cmd)
 	cmd.Flags().BoolVar(&conservative, "conservative", true, "Use conservative voxelization")
+	cmd.Flags().BoolVar(&fill, "fill", false, "Fill the interior of watertight meshes")
+	cmd.Flags().IntVar(&hollow, "hollow", 0, "Keep only a shell this many voxels thick, removing the hidden interior (0 = keep everything)")
+	cmd.Flags().Float64Var(&isoLevel, "iso-level", 0, "Voxels the sdf voxelizer offsets the surface by, growing the model when positive and shrinking it when negative")
+	cmd.Flags().IntVar(&minThickness, "min-thickness", 0, "Minimum thickness in voxels of every surface, so thin walls and sails stay closed (0 = as voxelized)")
+	cmd.Flags().IntVar(&samples, "samples", 1, "Color samples per voxel, averaging every triangle covering it for clean material boundaries (1 = last triangle at the voxel center)")
+	cmd.Flags().IntVar(&minPoints, "min-points", 1, "Points a voxel needs with the points voxelizer, dropping sparse scan noise")
+	cmd.Flags().IntVar(&holeFill, "hole-fill", 0, "Passes of the points voxelizer filling gaps between points on opposite sides (0 = none)")
+	cmd.Flags().BoolVar(&lighting.Enabled, "bake-lighting", false, "Darken voxel colors by surface normal against a light, so the build reads with depth in game")
+	cmd.Flags().Var(vec3Value{&lighting.Direction, false}, "light-direction", "Direction toward the light as x,y,z, y up (default -1,2,1: above, front left)")
+	cmd.Flags().Float64Var(&lighting.Ambient, "ambient", 0.4, "Brightness of surfaces facing away from the light with --bake-lighting, from 0 to 1")
+	cmd.Flags().IntVar(&animationIndex, "animation", 0, "Index of the glTF animation posed by --animation-time")
+	cmd.Flags().Float64Var(&animationTime, "animation-time", -1, "Pose the model this many seconds into its animation before voxelizing (-1 = rest pose)")
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", 0, "Goroutines voxelizing in parallel (0 = one per CPU)")
+	cmd.Flags().TextVar(&storageMode, "storage", core.StorageAuto, "Voxel storage ("+strings.Join(core.StorageModes(), ", ")+"); octree keeps resolutions of 1024 and above in memory")
+	cmd.Flags().StringVar(&voxelizer, "voxelizer", "", "Voxelization algorithm ("+strings.Join(core.VoxelizerNames(), ", ")+"; default points for .xyz and .las point clouds, surface otherwise)")
+}
+
+// voxelizerFor returns the --voxelizer algorithm, or when unset the one suiting
+// inputFile: points for point cloud formats and surface for meshes.
+func voxelizerFor(inputFile string) string {
+	if voxelizer != "" {
+		return voxelizer
+	}
+	switch strings.ToLower(filepath.Ext(inputFile)) {
+	case ".xyz", ".las":
+		return "points"
+	}
+	return "surface"
+}
+
+// animationOption returns the pipeline option posing models at --animation-time,
+// or keeping their rest pose when it is unset.
+func animationOption() core.PipelineOption {
+	if animationTime < 0 {
+		return core.WithAnimation(-1, 0)
+	}
+	return core.WithAnimation(animationIndex, animationTime)
+}
+
+// addFrameFlags adds the flags exporting an animation as a series of models.
+func addFrameFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&animationEnd, "animation-end", -1, "Export frames from --animation-time up to this many seconds as <name>_000.<ext>, <name>_001.<ext>, ..., sharing one grid (-1 = one model)")
+	cmd.Flags().Float64Var(&animationFPS, "animation-fps", 10, "Frames per second exported up to --animation-end")
+}
+
+// frameTimes returns the times of the frames selected by --animation-time,
+// --animation-end and --animation-fps.
+func frameTimes() ([]float64, error) {
+	start := math.Max(animationTime, 0)
+	if animationFPS <= 0 {
+		return nil, fmt.Errorf("--animation-fps must be positive, got %g", animationFPS)
+	}
+	if animationEnd < start {
+		return nil, fmt.Errorf("--animation-end %g is before the first frame at %g s", animationEnd, start)
+	}
+	count := int(math.Floor((animationEnd-start)*animationFPS+1e-9)) + 1
+	times := make([]float64, count)
+	for i := range times {
+		times[i] = start + float64(i)/animationFPS
+	}
+	return times, nil
+}
+
+func addPostScaleFlag(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&postScale, "post-scale", 1, "Rescale the voxels by a whole factor or its reciprocal (e.g. 2 or 0.5) instead of voxelizing again")
+}
+
+func addAOFlag(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&bakeAO, "bake-ao", 0, "Darken crevices and inner corners by up to this strength, from 0 (off) to 1, before matching blocks")
+}
+
+// vec3Value is a flag taking comma-separated x,y,z values, or a single value
+// for all three when uniform is set.
+type vec3Value struct {
+	v       *[3]float64
+	uniform bool
+}
+
+func (f vec3Value) String() string {
+	if f.v == nil || *f.v == ([3]float64{}) {
+		return ""
+	}
+	return fmt.Sprintf("%g,%g,%g", f.v[0], f.v[1], f.v[2])
+}
+
+func (f vec3Value) Set(s string) error {
+	parts := strings.Split(s, ",")
+	if len(parts) == 1 && f.uniform {
+		parts = []string{parts[0], parts[0], parts[0]}
+	}
+	if len(parts) != 3 {
+		if f.uniform {
+			return fmt.Errorf("expected one value or x,y,z, got %q", s)
+		}
+		return fmt.Errorf("expected x,y,z, got %q", s)
+	}
+	var v [3]float64
+	for i, part := range parts {
+		x, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", part)
+		}
+		v[i] = x
+	}
+	*f.v = v
+	return nil
+}
+
+func (f vec3Value) Type() string {
+	return "x,y,z"
+}
+
+// sizeValue is a flag taking comma-separated x,y,z voxel counts.
+type sizeValue struct {
+	v *[3]int
+}
+
+func (f sizeValue) String() string {
+	if f.v == nil || *f.v == ([3]int{}) {
+		return ""
+	}
+	return fmt.Sprintf("%d,%d,%d", f.v[0], f.v[1], f.v[2])
+}
+
+func (f sizeValue) Set(s string) error {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return fmt.Errorf("expected x,y,z, got %q", s)
+	}
+	var v [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid size %q", part)
+		}
+		v[i] = n
+	}
+	*f.v = v
+	return nil
+}
+
+func (f sizeValue) Type() string {
+	return "x,y,z"
+}
+
+// weightsValue is a flag taking the lightness and color weights of matching.
+type weightsValue struct {
+	w *core.MatchWeights
+}
+
+func (f weightsValue) String() string {
+	if f.w == nil || *f.w == (core.MatchWeights{}) {
+		return ""
+	}
+	return fmt.Sprintf("%g,%g", f.w.Lightness, f.w.Color)
+}
+
+func (f weightsValue) Set(s string) error {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return fmt.Errorf("expected lightness,color, got %q", s)
+	}
+	var v [2]float64
+	for i, part := range parts {
+		x, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || x <= 0 {
+			return fmt.Errorf("invalid weight %q", part)
+		}
+		v[i] = x
+	}
+	*f.w = core.MatchWeights{Lightness: v[0], Color: v[1]}
+	return nil
+}
+
+func (f weightsValue) Type() string {
+	return "L,ab"
+}
+
+// morphValue is a repeatable flag taking morphology steps.
+type morphValue struct {
+	steps *[]core.MorphStep
+}
+
+func (f morphValue) String() string {
+	if f.steps == nil {
+		return ""
+	}
+	specs := make([]string, len(*f.steps))
+	for i, step := range *f.steps {
+		specs[i] = fmt.Sprintf("%s:%d", step.Op, step.Element.Radius)
+		if step.Element.Shape != "" {
+			specs[i] += ":" + step.Element.Shape
+		}
+	}
+	return strings.Join(specs, ",")
+}
+
+func (f morphValue) Set(s string) error {
+	for _, spec := range strings.Split(s, ",") {
+		step, err := core.ParseMorphStep(strings.TrimSpace(spec))
+		if err != nil {
+			return err
+		}
+		*f.steps = append(*f.steps, step)
+	}
+	return nil
+}
+
+func (f morphValue) Type() string {
+	return "op:radius"
+}
+
+// regionValue is a repeatable flag taking detail regions.
+type regionValue struct {
+	regions *[]core.DetailRegion
+}
+
+func (f regionValue) String() string {
+	if f.regions == nil {
+		return ""
+	}
+	specs := make([]string, len(*f.regions))
+	for i, region := range *f.regions {
+		target := strings.Join(region.Groups, ",")
+		if len(region.Groups) == 0 {
+			target = fmt.Sprintf("%d,%d,%d,%d,%d,%d", region.Min[0], region.Min[1], region.Min[2], region.Max[0], region.Max[1], region.Max[2])
+		}
+		specs[i] = fmt.Sprintf("%s:%d", target, region.Scale)
+	}
+	return strings.Join(specs, " ")
+}
+
+func (f regionValue) Set(s string) error {
+	region, err := core.ParseDetailRegion(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	*f.regions = append(*f.regions, region)
+	return nil
+}
+
+func (f regionValue) Type() string {
+	return "region"
 }
 
 func addDitheringFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&ditherEnable, "dither", false, "Enable error diffusion dithering")
-	cmd.Flags().StringVar(&ditherAlgo, "dither-algorithm", "floyd-steinberg", "Dithering algorithm (floyd-steinberg)")
+	cmd.Flags().StringVar(&ditherAlgo, "dither-algorithm", "floyd-steinberg", "Dithering algorithm ("+strings.Join(core.DitherAlgorithms(), ", ")+")")
 }
 
 func addPaletteFlags(cmd *cobra.Command) {
-	cmd.Flags().StringVarP(&paletteFile, "palette", "p", "", "Palette file (msgpack format)")
+	cmd.Flags().StringVarP(&paletteFile, "palette", "p", "", "Palette file (.msgpack, .json or .csv)")
+	addMCVersionFlag(cmd)
+	cmd.Flags().StringVar(&matcher, "matcher", "cielab", "Color matching algorithm ("+strings.Join(core.MatcherNames(), ", ")+")")
+	cmd.Flags().Var(weightsValue{&matchWeights}, "match-weights", "Weights of lightness and color differences in matching (e.g. 2,1 favors correct brightness)")
+	cmd.Flags().StringSliceVar(&excludeBlocks, "exclude-blocks", nil, "Drop palette blocks matching these IDs, globs, #tags ("+strings.Join(core.BlockTagNames(), ", ")+") or key=value properties")
+	cmd.Flags().StringSliceVar(&includeBlocks, "include-blocks", nil, "Keep only palette blocks matching these IDs, globs, #tags or key=value properties")
+	cmd.Flags().BoolVar(&survivalOnly, "survival-only", false, "Drop blocks that cannot be obtained in survival")
+	cmd.Flags().BoolVar(&allowTranslucent, "allow-translucent", false, "Keep see-through blocks such as glass and leaves in the palette")
+	cmd.Flags().StringVar(&blockWeights, "block-weights", "", "JSON file of block weights biasing matching and block costs for the material list (see README)")
+	cmd.Flags().BoolVar(&lightBlocks, "light-blocks", false, "Match emissive surfaces with light-emitting blocks such as glowstone, sea lanterns and froglights")
+	cmd.Flags().StringVar(&fixGravity, "fix-gravity", "", "Fix blocks that would fall or break in game ("+strings.Join(core.PlacementFixes(), ", ")+")")
+	cmd.Flags().Lookup("fix-gravity").NoOptDefVal = core.FixSubstitute
+}
+
+func addMCVersionFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&mcVersion, "mc-version", "", "Minecraft version (1.12 or later, or latest) whose bundled vanilla blocks make the default palette and whose data version schematics and structures record (default: every block, data version 2975)")
+}
+
+func addDetailFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&detail, "detail", "", "Surface detail pass ("+strings.Join(core.DetailModes(), ", ")+"); stairs-slabs smooths one-block steps with the palette's stairs and slabs")
+}
+
+func addSchematicFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&schemFormat, "format", "sponge", "Schematic format (sponge, mcedit for Minecraft 1.12 and earlier, minetest for Minetest/Luanti .mts)")
+	cmd.Flags().IntVar(&schemVersion, "schem-version", 2, "Sponge schematic format version (2, 3)")
+	cmd.Flags().StringVar(&materialList, "material-list", "", "Also save the block counts as a .csv or .json material list")
+	addAirModeFlag(cmd)
+	addPasteOriginFlag(cmd)
+}
+
+func addAirModeFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&airMode, "air-mode", "", "How empty cells are written ("+strings.Join(core.AirModes(), ", ")+"); air clears the pasted box, void writes structure voids so pasting keeps the terrain (default: air in schematics, nothing in structures)")
+}
+
+func addPasteOriginFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&pasteOrigin, "paste-origin", "corner", "Where //paste places the model: corner (its minimum corner at the player), "+strings.Join(core.PasteOrigins(), ", ")+", or the minimum corner's offset x,y,z from the player (sponge and mcedit formats)")
+}
+
+func addRotationsFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&rotations, "rotations", false, "Write four copies turned a quarter turn apart as <name>_north.<ext>, <name>_east.<ext>, ..., turning stairs, logs and other oriented blocks with them (sponge format)")
+}
+
+// schematicOption returns the schematic settings from --schem-version,
+// --air-mode, --paste-origin and --mc-version.
+func schematicOption() core.PipelineOption {
+	config := core.SchematicConfig{Version: schemVersion, DataVersion: mcDataVersion(), AirMode: airMode, PasteOrigin: pasteOrigin}
+	if pasteOrigin == "corner" {
+		config.PasteOrigin = core.PasteOriginCorner
+	} else if parts := strings.Split(pasteOrigin, ","); len(parts) == 3 {
+		var offset [3]int
+		for i, part := range parts {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return core.WithSchematic(config) // Reported as an unknown paste origin
+			}
+			offset[i] = n
+		}
+		config.PasteOrigin, config.Offset = core.PasteOriginCorner, offset
+	}
+	return core.WithSchematic(config)
+}
+
+// mcDataVersion returns the data version of --mc-version, or 0 for the
+// exporters' default when it is not set. Malformed versions are reported by
+// checkFlattened or when the palette is loaded.
+func mcDataVersion() int {
+	if mcVersion == "" {
+		return 0
+	}
+	dataVersion, _ := core.MinecraftDataVersion(mcVersion)
+	return dataVersion
+}
+
+// checkFlattened rejects --mc-version values older than 1.13 for formats that
+// hold its block IDs, which earlier versions cannot read.
+func checkFlattened(format string) error {
+	if mcVersion == "" {
+		return nil
+	}
+	dataVersion, err := core.MinecraftDataVersion(mcVersion)
+	if err != nil {
+		return err
+	}
+	if dataVersion < core.FlatteningDataVersion {
+		return fmt.Errorf("%w: %s files hold Minecraft 1.13+ block IDs, which --mc-version %s cannot read", core.ErrInvalidConfig, format, mcVersion)
+	}
+	return nil
+}
+
+// schematicExporter returns the exporter name for the --format flag.
+func schematicExporter() (string, error) {
+	switch schemFormat {
+	case "", "sponge":
+		if err := checkFlattened("Sponge schematic"); err != nil {
+			return "", fmt.Errorf("%w; use --format mcedit", err)
+		}
+		return "schematic", nil
+	case "mcedit":
+		if detail != "" {
+			return "", fmt.Errorf("--detail is only supported for the sponge format")
+		}
+		if rotations {
+			return "", fmt.Errorf("--rotations is only supported for the sponge format")
+		}
+		return "mcedit", nil
+	case "minetest":
+		if detail != "" {
+			return "", fmt.Errorf("--detail is only supported for the sponge format")
+		}
+		if rotations {
+			return "", fmt.Errorf("--rotations is only supported for the sponge format")
+		}
+		return "minetest", nil
+	}
+	return "", fmt.Errorf("unsupported schematic format %q (supported: sponge, mcedit, minetest)", schemFormat)
+}
+
+// checkMaterialList validates --material-list before any work is done.
+func checkMaterialList() error {
+	if materialList == "" {
+		return nil
+	}
+	if animationEnd >= 0 {
+		return fmt.Errorf("--material-list cannot be combined with --animation-end")
+	}
+	if schemFormat == "mcedit" || schemFormat == "minetest" {
+		return fmt.Errorf("--material-list is only supported for the sponge format")
+	}
+	if ext := strings.ToLower(filepath.Ext(materialList)); ext != ".csv" && ext != ".json" {
+		return fmt.Errorf("material list must be a .csv or .json file, got %q", materialList)
+	}
+	return nil
 }
 
 func addOutputFlags(cmd *cobra.Command) {
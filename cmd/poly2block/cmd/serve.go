@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/billstark001/poly2block/cmd/poly2block/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr       string
+	serveWorkers    int
+	serveMaxPending int
+	serveRetention  time.Duration
+	serveMaxUpload  int64
+	serveMaxCells   int
+	serveRemote     bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an HTTP server for conversion jobs",
+	Long: `Run an HTTP server that accepts mesh uploads as asynchronous conversion jobs.
+Submit with POST /jobs, follow progress with GET /jobs/{id} or the server-sent
+events at GET /jobs/{id}/events, and download the output from GET /jobs/{id}/result.
+
+POST /convert/schematic and POST /convert/vox convert an upload while the client
+waits and stream the result in the response; POST /palette/extract builds a
+palette from an uploaded resource pack or client jar.`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", ":8080", "Address to listen on")
+	serveCmd.Flags().IntVar(&serveWorkers, "workers", 1, "Conversions run at once")
+	serveCmd.Flags().IntVar(&serveMaxPending, "max-pending", 64, "Jobs waiting for a worker before new submissions are rejected")
+	serveCmd.Flags().DurationVar(&serveRetention, "retention", time.Hour, "How long finished jobs and their results are kept")
+	serveCmd.Flags().Int64Var(&serveMaxUpload, "max-upload", 256<<20, "Largest accepted upload in bytes")
+	serveCmd.Flags().IntVar(&serveMaxCells, "max-cells", 0, "Voxel grid cell limit per conversion (0 = default)")
+	serveCmd.Flags().BoolVar(&serveRemote, "remote-storage", false, "Let requests read inputs and palettes from and write outputs to s3:// and gs:// URIs")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	srv := server.New(server.Config{
+		Queue: server.QueueConfig{
+			Workers:    serveWorkers,
+			MaxPending: serveMaxPending,
+			Retention:  serveRetention,
+		},
+		MaxUpload:     serveMaxUpload,
+		MaxCells:      serveMaxCells,
+		RemoteStorage: serveRemote,
+	})
+	defer srv.Close()
+
+	httpServer := &http.Server{Addr: serveAddr, Handler: srv}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+	fmt.Printf("Listening on %s\n", serveAddr)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-cmd.Context().Done():
+	}
+
+	fmt.Println("Shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
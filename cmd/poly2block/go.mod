@@ -6,6 +6,7 @@ replace github.com/billstark001/poly2block/core => ../../core
 
 require (
 	github.com/billstark001/poly2block/core v0.0.0-00010101000000-000000000000
+	github.com/qmuntal/gltf v0.28.0
 	github.com/spf13/cobra v1.10.2
 )
 
@@ -13,8 +14,8 @@ require (
 	github.com/Tnze/go-mc v1.20.2 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
-	github.com/qmuntal/gltf v0.28.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
 	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/image v0.25.0 // indirect
 )
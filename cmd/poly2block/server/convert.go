@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
+	"github.com/billstark001/poly2block/core"
+)
+
+// Output targets accepted in the target query parameter.
+var targets = map[string]struct {
+	exporter    string
+	extension   string
+	contentType string
+}{
+	"schematic": {"schematic", ".schem", "application/octet-stream"},
+	"vox":       {"vox", ".vox", "application/octet-stream"},
+}
+
+var (
+	vanillaPaletteOnce sync.Once
+	vanillaPalette     *core.Palette
+)
+
+// defaultPalette returns the vanilla palette, generated once and shared by all requests.
+func defaultPalette() *core.Palette {
+	vanillaPaletteOnce.Do(func() {
+		vanillaPalette = core.GenerateMinecraftPalette(core.GetVanillaMinecraftBlocks())
+	})
+	return vanillaPalette
+}
+
+// conversion is a validated conversion request.
+type conversion struct {
+	target   string
+	filename string
+	mesh     []byte // Uploaded input, or nil when read from input
+	input    string // Remote input URI
+	output   string // Remote URI the result is also written to
+	options  []core.PipelineOption
+}
+
+// requestError is a client error reported with HTTP 400.
+type requestError struct {
+	msg string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &requestError{msg: fmt.Sprintf(format, args...)}
+}
+
+// parseConversion reads the multipart upload ("file" and optional "palette") or the
+// input and palette URIs, and the query-parameter configuration, and checks that
+// they form a valid pipeline. An empty target means schematic.
+func parseConversion(w http.ResponseWriter, r *http.Request, config Config, target string) (*conversion, error) {
+	query := r.URL.Query()
+	c := &conversion{target: target}
+	if c.target == "" {
+		c.target = "schematic"
+	}
+	if _, ok := targets[c.target]; !ok {
+		return nil, badRequest("unsupported target %q (supported: schematic, vox)", c.target)
+	}
+
+	c.input, c.output = query.Get("input"), query.Get("output")
+	paletteURI := query.Get("palette")
+	for _, uri := range []string{c.input, c.output, paletteURI} {
+		if uri == "" {
+			continue
+		}
+		if !config.RemoteStorage {
+			return nil, badRequest("remote storage is disabled on this server")
+		}
+		if !storage.IsRemote(uri) {
+			return nil, badRequest("%q is not a supported storage URI (schemes: %s)", uri, strings.Join(storage.Schemes(), ", "))
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, config.MaxUpload)
+	if c.input == "" || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, err
+			}
+			return nil, badRequest("invalid multipart upload: %v", err)
+		}
+	}
+	if c.input != "" {
+		c.filename = path.Base(c.input)
+	} else {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, badRequest("missing \"file\" upload or input URI")
+		}
+		defer file.Close()
+		if c.mesh, err = io.ReadAll(file); err != nil {
+			return nil, badRequest("failed to read upload: %v", err)
+		}
+		c.filename = filepath.Base(header.Filename)
+	}
+
+	var palette *core.Palette
+	if c.target == "schematic" {
+		var err error
+		if palette, err = requestPalette(r, paletteURI); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	voxelization := core.VoxelizationConfig{Resolution: 128, Conservative: true, MaxCells: config.MaxCells}
+	if voxelization.Resolution, err = queryInt(query, "resolution", voxelization.Resolution); err != nil {
+		return nil, err
+	}
+	if voxelization.Conservative, err = queryBool(query, "conservative", voxelization.Conservative); err != nil {
+		return nil, err
+	}
+	if voxelization.Fill, err = queryBool(query, "fill", false); err != nil {
+		return nil, err
+	}
+	dithering := core.DitherConfig{Algorithm: query.Get("ditherAlgorithm")}
+	if dithering.Enabled, err = queryBool(query, "dither", false); err != nil {
+		return nil, err
+	}
+
+	c.options = []core.PipelineOption{
+		core.WithInputFile(c.filename),
+		core.WithVoxelization(voxelization),
+		core.WithExporterName(targets[c.target].exporter),
+	}
+	if name := query.Get("voxelizer"); name != "" {
+		c.options = append(c.options, core.WithVoxelizerName(name))
+	}
+	if palette != nil {
+		c.options = append(c.options, core.WithPalette(palette), core.WithDithering(dithering))
+		if name := query.Get("matcher"); name != "" {
+			c.options = append(c.options, core.WithMatcherName(name))
+		}
+	}
+
+	// Build once up front so bad configurations fail the request, not the job
+	if _, err := core.NewPipeline(c.options...); err != nil {
+		return nil, badRequest("%v", err)
+	}
+	return c, nil
+}
+
+// requestPalette returns the palette uploaded as "palette", read from uri, or the
+// vanilla palette when neither is given.
+func requestPalette(r *http.Request, uri string) (*core.Palette, error) {
+	var source io.ReadCloser
+	if uri != "" {
+		f, err := storage.Open(r.Context(), uri)
+		if err != nil {
+			return nil, badRequest("failed to read palette: %v", err)
+		}
+		source = f
+	} else if r.MultipartForm != nil {
+		f, _, err := r.FormFile("palette")
+		if err != nil {
+			return defaultPalette(), nil
+		}
+		source = f
+	} else {
+		return defaultPalette(), nil
+	}
+	defer source.Close()
+
+	palette, err := core.ImportPalette(source)
+	if err != nil {
+		return nil, badRequest("invalid palette: %v", err)
+	}
+	return palette, nil
+}
+
+// convert runs the pipeline on the input mesh, reporting progress to reporter,
+// and writes the result to w.
+func (c *conversion) convert(ctx context.Context, reporter core.ProgressReporter, w io.Writer) error {
+	options := append(c.options[:len(c.options):len(c.options)], core.WithProgress(reporter))
+	pipeline, err := core.NewPipeline(options...)
+	if err != nil {
+		return err
+	}
+
+	var input io.Reader = bytes.NewReader(c.mesh)
+	if c.input != "" {
+		f, err := storage.Open(ctx, c.input)
+		if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+		defer f.Close()
+		input = f
+	}
+	return pipeline.ConvertCtx(ctx, input, w)
+}
+
+// run converts the input mesh, reporting progress to reporter, and writes the
+// result to the output URI if one was given.
+func (c *conversion) run(ctx context.Context, reporter core.ProgressReporter) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.convert(ctx, reporter, &buf); err != nil {
+		return nil, err
+	}
+	if c.output != "" {
+		if err := storage.Write(ctx, c.output, func(w io.Writer) error {
+			_, err := w.Write(buf.Bytes())
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("failed to write output: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// outputName returns the download file name for the conversion's result.
+func (c *conversion) outputName() string {
+	return strings.TrimSuffix(c.filename, filepath.Ext(c.filename)) + targets[c.target].extension
+}
+
+func queryInt(query url.Values, key string, def int) (int, error) {
+	v := query.Get(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def, badRequest("%s must be an integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+func queryBool(query url.Values, key string, def bool) (bool, error) {
+	v := query.Get(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, badRequest("%s must be true or false, got %q", key, v)
+	}
+	return b, nil
+}
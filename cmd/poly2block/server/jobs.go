@@ -0,0 +1,332 @@
+// Package server implements the HTTP API behind `poly2block serve`.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// ErrQueueFull is returned by Submit when the pending queue has no room.
+var ErrQueueFull = errors.New("job queue is full")
+
+// JobState is the lifecycle state of a job.
+type JobState string
+
+const (
+	JobQueued   JobState = "queued"
+	JobRunning  JobState = "running"
+	JobDone     JobState = "done"
+	JobFailed   JobState = "failed"
+	JobCanceled JobState = "canceled"
+)
+
+// Terminal reports whether a job in this state will not change again.
+func (s JobState) Terminal() bool {
+	return s == JobDone || s == JobFailed || s == JobCanceled
+}
+
+// RunFunc performs a job's conversion, reporting progress through reporter and
+// returning the output bytes.
+type RunFunc func(ctx context.Context, reporter core.ProgressReporter) ([]byte, error)
+
+// Output describes the file a job produces.
+type Output struct {
+	Filename    string
+	ContentType string
+}
+
+// JobStatus is a snapshot of a job, serialized as the status endpoint's response
+// and each progress event.
+type JobStatus struct {
+	ID       string     `json:"id"`
+	State    JobState   `json:"state"`
+	Stage    string     `json:"stage,omitempty"`
+	Current  int64      `json:"current"`
+	Total    int64      `json:"total"`
+	Percent  float64    `json:"percent"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// Job is a conversion submitted to a Queue.
+type Job struct {
+	id     string
+	run    RunFunc
+	ctx    context.Context
+	cancel context.CancelFunc
+	output Output
+
+	mu          sync.Mutex
+	status      JobStatus
+	result      []byte
+	subscribers map[chan JobStatus]struct{}
+}
+
+// ID returns the job's identifier.
+func (j *Job) ID() string {
+	return j.id
+}
+
+// Status returns a snapshot of the job.
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// Result returns the output once the job is done.
+func (j *Job) Result() ([]byte, Output, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status.State != JobDone {
+		return nil, j.output, false
+	}
+	return j.result, j.output, true
+}
+
+// Cancel stops a queued or running job. A queued job is marked canceled at once;
+// a running one when its conversion returns.
+func (j *Job) Cancel() {
+	j.cancel()
+	if j.Status().State == JobQueued {
+		j.finish(nil, context.Canceled)
+	}
+}
+
+// Subscribe returns a channel receiving status updates, starting with the current
+// status. Updates are conflated: a slow reader sees the latest status rather than
+// every event. The channel is closed after the terminal status has been delivered
+// or when unsubscribe is called.
+func (j *Job) Subscribe() (updates <-chan JobStatus, unsubscribe func()) {
+	ch := make(chan JobStatus, 1)
+	j.mu.Lock()
+	ch <- j.status
+	if j.status.State.Terminal() {
+		close(ch)
+		j.mu.Unlock()
+		return ch, func() {}
+	}
+	j.subscribers[ch] = struct{}{}
+	j.mu.Unlock()
+
+	return ch, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.subscribers[ch]; ok {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Report implements core.ProgressReporter for the job's conversion.
+func (j *Job) Report(e core.ProgressEvent) {
+	j.update(func(s *JobStatus) {
+		s.Stage = e.Stage
+		s.Current = e.Current
+		s.Total = e.Total
+		s.Percent = e.Percent()
+	})
+}
+
+// finish records the job's outcome unless it already has one.
+func (j *Job) finish(result []byte, err error) {
+	j.cancel()
+	now := time.Now()
+	j.update(func(s *JobStatus) {
+		if s.State.Terminal() {
+			return
+		}
+		s.Finished = &now
+		switch {
+		case err == nil:
+			s.State = JobDone
+			j.result = result
+		case errors.Is(err, context.Canceled):
+			s.State = JobCanceled
+			s.Error = "canceled"
+		default:
+			s.State = JobFailed
+			s.Error = err.Error()
+		}
+	})
+}
+
+// update applies fn to the status and broadcasts the result to subscribers.
+func (j *Job) update(fn func(*JobStatus)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.status)
+
+	terminal := j.status.State.Terminal()
+	for ch := range j.subscribers {
+		// Replace an unread update with the newer one
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- j.status
+		if terminal {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// QueueConfig configures a Queue.
+type QueueConfig struct {
+	Workers    int           // Conversions run at once (default 1)
+	MaxPending int           // Jobs waiting for a worker before Submit fails (default 64)
+	Retention  time.Duration // How long finished jobs and results are kept (default 1h)
+}
+
+// Queue runs submitted jobs on a fixed number of workers and forgets finished
+// jobs after the retention period.
+type Queue struct {
+	config  QueueConfig
+	pending chan *Job
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewQueue creates a queue and starts its workers.
+func NewQueue(config QueueConfig) *Queue {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.MaxPending <= 0 {
+		config.MaxPending = 64
+	}
+	if config.Retention <= 0 {
+		config.Retention = time.Hour
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	q := &Queue{
+		config:  config,
+		pending: make(chan *Job, config.MaxPending),
+		ctx:     ctx,
+		stop:    stop,
+		jobs:    make(map[string]*Job),
+	}
+	for i := 0; i < config.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	q.wg.Add(1)
+	go q.janitor()
+	return q
+}
+
+// Submit queues a job producing the described output.
+func (q *Queue) Submit(output Output, run RunFunc) (*Job, error) {
+	ctx, cancel := context.WithCancel(q.ctx)
+	job := &Job{
+		id:          newJobID(),
+		run:         run,
+		ctx:         ctx,
+		cancel:      cancel,
+		output:      output,
+		status:      JobStatus{State: JobQueued, Created: time.Now()},
+		subscribers: make(map[chan JobStatus]struct{}),
+	}
+	job.status.ID = job.id
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.pending <- job:
+		q.jobs[job.id] = job
+		return job, nil
+	default:
+		cancel()
+		return nil, ErrQueueFull
+	}
+}
+
+// Get returns the job with the given ID if it is still retained.
+func (q *Queue) Get(id string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	return job, ok
+}
+
+// Close cancels all jobs and waits for the workers to exit.
+func (q *Queue) Close() {
+	q.stop()
+	q.wg.Wait()
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case job := <-q.pending:
+			q.execute(job)
+		}
+	}
+}
+
+// execute runs a job and records its outcome.
+func (q *Queue) execute(job *Job) {
+	started := false
+	job.update(func(s *JobStatus) {
+		if s.State == JobQueued {
+			s.State = JobRunning
+			started = true
+		}
+	})
+	if !started {
+		return // canceled while queued
+	}
+	result, err := job.run(job.ctx, job)
+	job.run = nil // release the input held by the closure
+	job.finish(result, err)
+}
+
+// janitor drops finished jobs once they are older than the retention period.
+func (q *Queue) janitor() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.config.Retention / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case now := <-ticker.C:
+			q.expire(now)
+		}
+	}
+}
+
+// expire removes jobs that finished before now minus the retention period.
+func (q *Queue) expire(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, job := range q.jobs {
+		status := job.Status()
+		if status.Finished != nil && now.Sub(*status.Finished) > q.config.Retention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+func newJobID() string {
+	var b [12]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
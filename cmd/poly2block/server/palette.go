@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// extractPalette builds a palette from the resource pack or client jar uploaded as
+// "file" and returns it as msgpack, or as the block list when format=json. The
+// biome parameter selects the tint of grass, leaves and water.
+func (s *Server) extractPalette(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "msgpack" && format != "json" {
+		writeError(w, badRequest("unsupported format %q (supported: msgpack, json)", format))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxUpload)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if !errors.As(err, &tooLarge) {
+			err = badRequest("invalid multipart upload: %v", err)
+		}
+		writeError(w, err)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, badRequest("missing \"file\" upload"))
+		return
+	}
+	defer file.Close()
+
+	extractor := core.NewTextureExtractor()
+	if biome := r.URL.Query().Get("biome"); biome != "" {
+		extractor.Biome = biome
+	}
+	blocks, err := extractor.ExtractFromZip(file, header.Size)
+	if err != nil {
+		writeError(w, badRequest("%v", err))
+		return
+	}
+	if len(blocks) == 0 {
+		writeError(w, badRequest("no blocks found in the resource pack/jar"))
+		return
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "blocks.json"}))
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(blocks)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "palette.msgpack"}))
+	core.ExportPalette(core.GenerateMinecraftPalette(blocks), w)
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
+)
+
+// Config configures a Server.
+type Config struct {
+	Queue     QueueConfig
+	MaxUpload int64 // Largest accepted request body in bytes (default 256MB)
+	MaxCells  int   // Voxel grid cell limit passed to every conversion (0 = core default)
+
+	// RemoteStorage lets requests name s3:// and gs:// URIs in the input, palette
+	// and output query parameters, accessed with the server's own credentials.
+	RemoteStorage bool
+}
+
+// Server serves the conversion job API:
+//
+//	POST   /jobs              submit a conversion, returns 202 and the job status
+//	GET    /jobs/{id}         current job status
+//	GET    /jobs/{id}/events  status updates as server-sent events
+//	GET    /jobs/{id}/result  converted file once the job is done
+//	DELETE /jobs/{id}         cancel the job
+//
+// and the synchronous endpoints, which stream their result in the response:
+//
+//	POST   /convert/{target}  convert an upload to schematic or vox
+//	POST   /palette/extract   build a palette from a resource pack or client jar
+//
+// Synchronous conversions run beside queued jobs, at most Queue.Workers at once.
+type Server struct {
+	config Config
+	queue  *Queue
+	mux    *http.ServeMux
+	slots  chan struct{} // Held by running synchronous conversions
+}
+
+// New creates a server and starts its job workers. Call Close to stop them.
+func New(config Config) *Server {
+	if config.MaxUpload <= 0 {
+		config.MaxUpload = 256 << 20
+	}
+	s := &Server{
+		config: config,
+		queue:  NewQueue(config.Queue),
+		mux:    http.NewServeMux(),
+		slots:  make(chan struct{}, max(config.Queue.Workers, 1)),
+	}
+	s.mux.HandleFunc("POST /jobs", s.submitJob)
+	s.mux.HandleFunc("GET /jobs/{id}", s.jobStatus)
+	s.mux.HandleFunc("GET /jobs/{id}/events", s.jobEvents)
+	s.mux.HandleFunc("GET /jobs/{id}/result", s.jobResult)
+	s.mux.HandleFunc("DELETE /jobs/{id}", s.cancelJob)
+	s.mux.HandleFunc("POST /convert/{target}", s.convert)
+	s.mux.HandleFunc("POST /palette/extract", s.extractPalette)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Close cancels outstanding jobs and stops the workers.
+func (s *Server) Close() {
+	s.queue.Close()
+}
+
+func (s *Server) submitJob(w http.ResponseWriter, r *http.Request) {
+	c, err := parseConversion(w, r, s.config, r.URL.Query().Get("target"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	output := Output{Filename: c.outputName(), ContentType: targets[c.target].contentType}
+	job, err := s.queue.Submit(output, c.run)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID())
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+func (s *Server) jobStatus(w http.ResponseWriter, r *http.Request) {
+	if job := s.lookup(w, r); job != nil {
+		writeJSON(w, http.StatusOK, job.Status())
+	}
+}
+
+// jobEvents streams "status" events until the job finishes or the client disconnects.
+func (s *Server) jobEvents(w http.ResponseWriter, r *http.Request) {
+	job := s.lookup(w, r)
+	if job == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, errorBody{"streaming not supported"})
+		return
+	}
+
+	updates, unsubscribe := job.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case status, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(status)
+			if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) jobResult(w http.ResponseWriter, r *http.Request) {
+	job := s.lookup(w, r)
+	if job == nil {
+		return
+	}
+	data, output, ok := job.Result()
+	if !ok {
+		writeJSON(w, http.StatusConflict, errorBody{fmt.Sprintf("job is %s", job.Status().State)})
+		return
+	}
+	w.Header().Set("Content-Type", output.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": output.Filename}))
+	w.Write(data)
+}
+
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request) {
+	if job := s.lookup(w, r); job != nil {
+		job.Cancel()
+		writeJSON(w, http.StatusAccepted, job.Status())
+	}
+}
+
+// convert runs a conversion while the client waits and streams the result.
+func (s *Server) convert(w http.ResponseWriter, r *http.Request) {
+	c, err := parseConversion(w, r, s.config, r.PathValue("target"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-r.Context().Done():
+		return
+	}
+
+	out := &resultWriter{w: w, filename: c.outputName(), contentType: targets[c.target].contentType}
+	if c.output != "" {
+		err = storage.Write(r.Context(), c.output, func(remote io.Writer) error {
+			return c.convert(r.Context(), nil, io.MultiWriter(out, remote))
+		})
+	} else {
+		err = c.convert(r.Context(), nil, out)
+	}
+	if err != nil {
+		if out.started {
+			// The status line is gone; drop the connection so the client sees a
+			// broken response rather than a truncated file
+			panic(http.ErrAbortHandler)
+		}
+		writeError(w, err)
+	}
+}
+
+// resultWriter sends the download headers before the first byte of a result,
+// so failures before any output can still be reported as JSON errors.
+type resultWriter struct {
+	w           http.ResponseWriter
+	filename    string
+	contentType string
+	started     bool
+}
+
+func (rw *resultWriter) Write(p []byte) (int, error) {
+	if !rw.started {
+		rw.started = true
+		rw.w.Header().Set("Content-Type", rw.contentType)
+		rw.w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": rw.filename}))
+		rw.w.WriteHeader(http.StatusOK)
+	}
+	return rw.w.Write(p)
+}
+
+// lookup returns the job named in the path, or writes 404 and returns nil.
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) *Job {
+	job, ok := s.queue.Get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorBody{"job not found"})
+		return nil
+	}
+	return job
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// writeError reports err with the status code matching its kind.
+func writeError(w http.ResponseWriter, err error) {
+	var reqErr *requestError
+	var maxBytesErr *http.MaxBytesError
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		status = http.StatusRequestEntityTooLarge
+	case errors.As(err, &reqErr):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrQueueFull):
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, errorBody{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
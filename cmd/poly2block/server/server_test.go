@@ -0,0 +1,389 @@
+package server
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/billstark001/poly2block/cmd/poly2block/storage"
+	"github.com/billstark001/poly2block/core"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// newTriangleGLB encodes a single-triangle mesh as GLB.
+func newTriangleGLB(t *testing.T) []byte {
+	t.Helper()
+
+	doc := gltf.NewDocument()
+	positions := modeler.WritePosition(doc, [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 1}})
+	indices := modeler.WriteIndices(doc, []uint16{0, 1, 2})
+	doc.Meshes = []*gltf.Mesh{{
+		Primitives: []*gltf.Primitive{{
+			Indices:    gltf.Index(indices),
+			Attributes: gltf.PrimitiveAttributes{gltf.POSITION: positions},
+		}},
+	}}
+
+	var buf bytes.Buffer
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// submit uploads mesh as triangle.glb to POST /jobs with the given query.
+func submit(t *testing.T, srv *httptest.Server, query string, mesh []byte) *http.Response {
+	t.Helper()
+	return upload(t, srv, "/jobs?"+query, "triangle.glb", mesh)
+}
+
+// upload posts data as the multipart "file" field named filename.
+func upload(t *testing.T, srv *httptest.Server, target, filename string, data []byte) *http.Response {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write(data)
+	form.Close()
+
+	resp, err := http.Post(srv.URL+target, form.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", target, err)
+	}
+	return resp
+}
+
+func decodeStatus(t *testing.T, resp *http.Response) JobStatus {
+	t.Helper()
+	defer resp.Body.Close()
+	var status JobStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("decoding status failed: %v", err)
+	}
+	return status
+}
+
+func TestJobLifecycle(t *testing.T) {
+	s := New(Config{})
+	defer s.Close()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp := submit(t, srv, "target=vox&resolution=8", newTriangleGLB(t))
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("submit returned %d", resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	status := decodeStatus(t, resp)
+	if location != "/jobs/"+status.ID {
+		t.Errorf("Location = %q, want /jobs/%s", location, status.ID)
+	}
+
+	// Follow the event stream to the terminal status
+	events, err := http.Get(srv.URL + location + "/events")
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	defer events.Body.Close()
+	if ct := events.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("events Content-Type = %q", ct)
+	}
+	var last JobStatus
+	scanner := bufio.NewScanner(events.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			if err := json.Unmarshal([]byte(data), &last); err != nil {
+				t.Fatalf("bad event data %q: %v", data, err)
+			}
+		}
+	}
+	if last.State != JobDone {
+		t.Fatalf("final event state = %q (%s), want done", last.State, last.Error)
+	}
+
+	result, err := http.Get(srv.URL + location + "/result")
+	if err != nil {
+		t.Fatalf("GET result failed: %v", err)
+	}
+	defer result.Body.Close()
+	data, _ := io.ReadAll(result.Body)
+	if result.StatusCode != http.StatusOK {
+		t.Fatalf("result returned %d: %s", result.StatusCode, data)
+	}
+	if !strings.Contains(result.Header.Get("Content-Disposition"), "triangle.vox") {
+		t.Errorf("Content-Disposition = %q", result.Header.Get("Content-Disposition"))
+	}
+	if !bytes.HasPrefix(data, []byte("VOX ")) {
+		t.Errorf("result is not a VOX file: % x", data[:min(len(data), 8)])
+	}
+}
+
+func TestSubmitErrors(t *testing.T) {
+	s := New(Config{MaxUpload: 1 << 10})
+	defer s.Close()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	mesh := newTriangleGLB(t)
+
+	tests := []struct {
+		name  string
+		query string
+		mesh  []byte
+		want  int
+	}{
+		{"unknown target", "target=obj", mesh, http.StatusBadRequest},
+		{"bad resolution", "resolution=abc", mesh, http.StatusBadRequest},
+		{"invalid config", "resolution=-1", mesh, http.StatusBadRequest},
+		{"unknown dither algorithm", "dither=true&ditherAlgorithm=none", mesh, http.StatusBadRequest},
+		{"upload too large", "", make([]byte, 2<<10), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := submit(t, srv, tt.query, tt.mesh)
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	resp, _ := http.Get(srv.URL + "/jobs/missing")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown job status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestConvertEndpoint(t *testing.T) {
+	s := New(Config{})
+	defer s.Close()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	resp := upload(t, srv, "/convert/vox?resolution=8", "triangle.glb", newTriangleGLB(t))
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("convert returned %d: %s", resp.StatusCode, data)
+	}
+	if !strings.Contains(resp.Header.Get("Content-Disposition"), "triangle.vox") {
+		t.Errorf("Content-Disposition = %q", resp.Header.Get("Content-Disposition"))
+	}
+	if !bytes.HasPrefix(data, []byte("VOX ")) {
+		t.Errorf("result is not a VOX file: % x", data[:min(len(data), 8)])
+	}
+
+	for _, target := range []string{"/convert/obj", "/convert/schematic?resolution=-1"} {
+		resp := upload(t, srv, target, "triangle.glb", newTriangleGLB(t))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want 400", target, resp.StatusCode)
+		}
+	}
+}
+
+func TestExtractPaletteEndpoint(t *testing.T) {
+	s := New(Config{})
+	defer s.Close()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// A resource pack with one red block
+	var pack bytes.Buffer
+	archive := zip.NewWriter(&pack)
+	texture := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(texture, texture.Bounds(), image.NewUniform(color.RGBA{200, 20, 20, 255}), image.Point{}, draw.Src)
+	f, _ := archive.Create("assets/minecraft/textures/block/red_block.png")
+	png.Encode(f, texture)
+	f, _ = archive.Create("assets/minecraft/models/block/red_block.json")
+	f.Write([]byte(`{"parent": "block/cube_all", "textures": {"all": "block/red_block"}}`))
+	archive.Close()
+
+	resp := upload(t, srv, "/palette/extract", "pack.zip", pack.Bytes())
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		t.Fatalf("extract returned %d: %s", resp.StatusCode, data)
+	}
+	palette, err := core.ImportPalette(resp.Body)
+	if err != nil {
+		t.Fatalf("response is not a palette: %v", err)
+	}
+	if len(palette.Colors) != 1 || palette.Colors[0].RGB != [3]uint8{200, 20, 20} {
+		t.Errorf("palette colors = %+v, want one red block", palette.Colors)
+	}
+
+	resp = upload(t, srv, "/palette/extract?format=json", "pack.zip", pack.Bytes())
+	var blocks []core.MinecraftBlock
+	json.NewDecoder(resp.Body).Decode(&blocks)
+	resp.Body.Close()
+	if len(blocks) != 1 || blocks[0].ID != "minecraft:red_block" {
+		t.Errorf("JSON blocks = %+v, want minecraft:red_block", blocks)
+	}
+
+	resp = upload(t, srv, "/palette/extract", "pack.zip", []byte("not a zip"))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid zip status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestQueueCancelAndFull(t *testing.T) {
+	q := NewQueue(QueueConfig{Workers: 1, MaxPending: 1})
+	defer q.Close()
+
+	started := make(chan struct{})
+	blocking := func(ctx context.Context, reporter core.ProgressReporter) ([]byte, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	running, err := q.Submit(Output{}, blocking)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+
+	queued, err := q.Submit(Output{}, func(context.Context, core.ProgressReporter) ([]byte, error) {
+		return []byte("never"), nil
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if _, err := q.Submit(Output{}, nil); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("third Submit error = %v, want ErrQueueFull", err)
+	}
+
+	// A queued job is canceled at once and never runs
+	queued.Cancel()
+	if state := queued.Status().State; state != JobCanceled {
+		t.Errorf("queued job state after Cancel = %q", state)
+	}
+
+	updates, unsubscribe := running.Subscribe()
+	defer unsubscribe()
+	running.Cancel()
+	timeout := time.After(5 * time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-updates:
+		case <-timeout:
+			t.Fatal("running job did not finish after Cancel")
+		}
+	}
+	if got := running.Status().State; got != JobCanceled {
+		t.Errorf("running job state after Cancel = %q, want canceled", got)
+	}
+	if _, _, ok := queued.Result(); ok {
+		t.Error("canceled job has a result")
+	}
+}
+
+func TestQueueExpire(t *testing.T) {
+	q := NewQueue(QueueConfig{Retention: time.Minute})
+	defer q.Close()
+
+	job, err := q.Submit(Output{}, func(context.Context, core.ProgressReporter) ([]byte, error) {
+		return []byte("ok"), nil
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	updates, _ := job.Subscribe()
+	for range updates {
+	}
+
+	q.expire(time.Now())
+	if _, ok := q.Get(job.ID()); !ok {
+		t.Fatal("job expired before the retention period")
+	}
+	q.expire(time.Now().Add(2 * time.Minute))
+	if _, ok := q.Get(job.ID()); ok {
+		t.Error("job retained after the retention period")
+	}
+}
+
+// memoryBackend is a storage.Backend holding objects in memory.
+type memoryBackend struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *memoryBackend) Get(ctx context.Context, u *url.URL) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[u.String()]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryBackend) Put(ctx context.Context, u *url.URL, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[u.String()] = data
+	return nil
+}
+
+func TestRemoteStorage(t *testing.T) {
+	backend := &memoryBackend{objects: map[string][]byte{"mem://bucket/triangle.glb": newTriangleGLB(t)}}
+	storage.Register("mem", backend)
+
+	query := "target=vox&resolution=8&input=mem://bucket/triangle.glb&output=mem://bucket/out/triangle.vox"
+	post := func(s *Server) *http.Response {
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+		resp, err := http.Post(srv.URL+"/jobs?"+query, "", nil)
+		if err != nil {
+			t.Fatalf("POST /jobs failed: %v", err)
+		}
+		return resp
+	}
+
+	disabled := New(Config{})
+	defer disabled.Close()
+	if resp := post(disabled); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("remote URIs with storage disabled: status = %d, want 400", resp.StatusCode)
+	}
+
+	s := New(Config{RemoteStorage: true})
+	defer s.Close()
+	resp := post(s)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("submit returned %d", resp.StatusCode)
+	}
+	status := decodeStatus(t, resp)
+	job, _ := s.queue.Get(status.ID)
+	updates, _ := job.Subscribe()
+	for status = range updates {
+	}
+	if status.State != JobDone {
+		t.Fatalf("job state = %q (%s), want done", status.State, status.Error)
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if out := backend.objects["mem://bucket/out/triangle.vox"]; !bytes.HasPrefix(out, []byte("VOX ")) {
+		t.Errorf("output object not written: %q", out)
+	}
+}
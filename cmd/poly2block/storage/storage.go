@@ -0,0 +1,148 @@
+// Package storage opens inputs and writes outputs named by local paths or by
+// URIs such as s3://bucket/key and gs://bucket/object.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Backend reads and writes objects for one URI scheme.
+type Backend interface {
+	// Get opens the object at u. A missing object is reported as fs.ErrNotExist.
+	Get(ctx context.Context, u *url.URL) (io.ReadCloser, error)
+
+	// Put stores data as the object at u, replacing any existing object.
+	Put(ctx context.Context, u *url.URL, data []byte) error
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+// Register makes a backend available for URIs with the given scheme, replacing any
+// previous registration.
+func Register(scheme string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[strings.ToLower(scheme)] = backend
+}
+
+// Schemes returns the registered URI schemes in sorted order.
+func Schemes() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	schemes := make([]string, 0, len(backends))
+	for scheme := range backends {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+func init() {
+	Register("s3", NewS3FromEnv())
+	Register("gs", NewGCSFromEnv())
+}
+
+// IsRemote reports whether location is a URI handled by a registered backend
+// rather than a local path.
+func IsRemote(location string) bool {
+	_, _, ok := lookup(location)
+	return ok
+}
+
+// lookup returns the backend and parsed URI for a remote location.
+func lookup(location string) (Backend, *url.URL, bool) {
+	scheme, _, ok := strings.Cut(location, "://")
+	if !ok {
+		return nil, nil, false
+	}
+	backendsMu.RLock()
+	backend, ok := backends[strings.ToLower(scheme)]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, nil, false
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, nil, false
+	}
+	return backend, u, true
+}
+
+// Open opens a local file or remote object for reading.
+func Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	backend, u, ok := lookup(location)
+	if !ok {
+		return os.Open(location)
+	}
+	if err := checkObjectURI(u); err != nil {
+		return nil, err
+	}
+	return backend.Get(ctx, u)
+}
+
+// Write calls fn with a writer for location and stores what it writes. Local files
+// are written as fn runs; remote objects are buffered and uploaded only if fn
+// succeeds, so a failed conversion never replaces an existing object.
+func Write(ctx context.Context, location string, fn func(w io.Writer) error) error {
+	backend, u, ok := lookup(location)
+	if !ok {
+		f, err := os.Create(location)
+		if err != nil {
+			return err
+		}
+		if err := fn(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	if err := checkObjectURI(u); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := fn(&buf); err != nil {
+		return err
+	}
+	return backend.Put(ctx, u, buf.Bytes())
+}
+
+// checkObjectURI rejects URIs without both a bucket and an object name.
+func checkObjectURI(u *url.URL) error {
+	if u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		return fmt.Errorf("invalid storage URI %q: want %s://bucket/object", u.String(), u.Scheme)
+	}
+	return nil
+}
+
+// objectKey returns the object name of a bucket URI.
+func objectKey(u *url.URL) string {
+	return strings.TrimPrefix(u.Path, "/")
+}
+
+// responseError turns a failed HTTP response into an error, reporting 404 as
+// fs.ErrNotExist so callers treat it like a missing local file.
+func responseError(op string, u *url.URL, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if resp.StatusCode == http.StatusNotFound {
+		return &fs.PathError{Op: op, Path: u.String(), Err: fs.ErrNotExist}
+	}
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = resp.Status
+	}
+	return fmt.Errorf("%s %s: %s: %s", op, u.String(), resp.Status, msg)
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"slices"
+	"strings"
+)
+
+// BiomePlains is the default biome of TextureExtractor.
+const BiomePlains = "plains"
+
+// biome holds the climate that places a biome on the grass and foliage
+// colormaps, and the colors it sets outright.
+type biome struct {
+	temperature, downfall float64
+	water                 [3]uint8
+	grass, foliage        [3]uint8 // Fixed colors replacing the colormaps, if set
+}
+
+// defaultWater is the water color of most biomes.
+var defaultWater = [3]uint8{0x3f, 0x76, 0xe4}
+
+// biomes lists the vanilla biomes tints can be taken from.
+var biomes = map[string]biome{
+	"plains":           {temperature: 0.8, downfall: 0.4, water: defaultWater},
+	"sunflower_plains": {temperature: 0.8, downfall: 0.4, water: defaultWater},
+	"snowy_plains":     {temperature: 0.0, downfall: 0.5, water: defaultWater},
+	"desert":           {temperature: 2.0, downfall: 0.0, water: defaultWater},
+	"savanna":          {temperature: 2.0, downfall: 0.0, water: defaultWater},
+	"badlands": {temperature: 2.0, downfall: 0.0, water: defaultWater,
+		grass: [3]uint8{0x90, 0x81, 0x4d}, foliage: [3]uint8{0x9e, 0x81, 0x4d}},
+	"forest":        {temperature: 0.7, downfall: 0.8, water: defaultWater},
+	"flower_forest": {temperature: 0.7, downfall: 0.8, water: defaultWater},
+	"birch_forest":  {temperature: 0.6, downfall: 0.6, water: defaultWater},
+	"dark_forest":   {temperature: 0.7, downfall: 0.8, water: defaultWater},
+	"taiga":         {temperature: 0.25, downfall: 0.8, water: defaultWater},
+	"snowy_taiga":   {temperature: -0.5, downfall: 0.4, water: [3]uint8{0x3d, 0x57, 0xd6}},
+	"jungle":        {temperature: 0.95, downfall: 0.9, water: defaultWater},
+	"swamp": {temperature: 0.8, downfall: 0.9, water: [3]uint8{0x61, 0x7b, 0x64},
+		grass: [3]uint8{0x6a, 0x70, 0x39}, foliage: [3]uint8{0x6a, 0x70, 0x39}},
+	"mangrove_swamp": {temperature: 0.8, downfall: 0.9, water: [3]uint8{0x3a, 0x7a, 0x6a},
+		grass: [3]uint8{0x6a, 0x70, 0x39}, foliage: [3]uint8{0x8d, 0xb1, 0x27}},
+	"meadow": {temperature: 0.5, downfall: 0.8, water: [3]uint8{0x0e, 0x4e, 0xcf}},
+	"cherry_grove": {temperature: 0.5, downfall: 0.8, water: [3]uint8{0x5d, 0xb7, 0xef},
+		grass: [3]uint8{0xb6, 0xdb, 0x61}, foliage: [3]uint8{0xb6, 0xdb, 0x61}},
+	"beach":        {temperature: 0.8, downfall: 0.4, water: defaultWater},
+	"river":        {temperature: 0.5, downfall: 0.5, water: defaultWater},
+	"ocean":        {temperature: 0.5, downfall: 0.5, water: defaultWater},
+	"warm_ocean":   {temperature: 0.5, downfall: 0.5, water: [3]uint8{0x43, 0xd5, 0xee}},
+	"cold_ocean":   {temperature: 0.5, downfall: 0.5, water: [3]uint8{0x3d, 0x57, 0xd6}},
+	"frozen_ocean": {temperature: 0.0, downfall: 0.5, water: [3]uint8{0x39, 0x38, 0xc9}},
+}
+
+// Biomes returns the biome names accepted by TextureExtractor.Biome, sorted.
+func Biomes() []string {
+	names := make([]string, 0, len(biomes))
+	for name := range biomes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Colormap tints of the plains biome, used when a resource pack has no
+// colormap textures.
+var (
+	plainsGrass   = [3]uint8{0x91, 0xbd, 0x59}
+	plainsFoliage = [3]uint8{0x77, 0xab, 0x2f}
+)
+
+// Tint sources of textureTints.
+const (
+	tintGrass   = "grass"
+	tintFoliage = "foliage"
+	tintWater   = "water"
+)
+
+// textureTints maps the grayscale block textures the game tints by biome to
+// the color they take.
+var textureTints = map[string]string{
+	"block/grass_block_top":   tintGrass,
+	"block/short_grass":       tintGrass,
+	"block/grass":             tintGrass, // Before 1.20.3
+	"block/tall_grass_top":    tintGrass,
+	"block/tall_grass_bottom": tintGrass,
+	"block/fern":              tintGrass,
+	"block/large_fern_top":    tintGrass,
+	"block/large_fern_bottom": tintGrass,
+	"block/sugar_cane":        tintGrass,
+	"block/oak_leaves":        tintFoliage,
+	"block/jungle_leaves":     tintFoliage,
+	"block/acacia_leaves":     tintFoliage,
+	"block/dark_oak_leaves":   tintFoliage,
+	"block/mangrove_leaves":   tintFoliage,
+	"block/vine":              tintFoliage,
+	"block/water_still":       tintWater,
+	"block/water_flow":        tintWater,
+}
+
+// fixedTints are textures tinted with the same color in every biome.
+var fixedTints = map[string][3]uint8{
+	"block/birch_leaves":  {0x80, 0xa7, 0x55},
+	"block/spruce_leaves": {0x61, 0x99, 0x61},
+	"block/lily_pad":      {0x20, 0x80, 0x30},
+}
+
+// biomeTints are the colors tinted textures take in one biome.
+type biomeTints struct {
+	grass, foliage, water [3]uint8
+}
+
+// tints looks up the tints of te.Biome, sampling the grass and foliage
+// colormaps of the loaded textures when present.
+func (te *TextureExtractor) tints() (biomeTints, error) {
+	name := te.Biome
+	if name == "" {
+		name = BiomePlains
+	}
+	b, ok := biomes[name]
+	if !ok {
+		return biomeTints{}, fmt.Errorf("%w: unknown biome %q (supported: %s)",
+			ErrInvalidConfig, name, strings.Join(Biomes(), ", "))
+	}
+
+	tints := biomeTints{grass: b.grass, foliage: b.foliage, water: b.water}
+	if tints.grass == [3]uint8{} {
+		tints.grass = sampleColormap(te.textures["colormap/grass"], b, plainsGrass)
+	}
+	if tints.foliage == [3]uint8{} {
+		tints.foliage = sampleColormap(te.textures["colormap/foliage"], b, plainsFoliage)
+	}
+	return tints, nil
+}
+
+// forTexture returns the tint of a texture, if the game tints it.
+func (t biomeTints) forTexture(texture string) ([3]uint8, bool) {
+	if tint, ok := fixedTints[texture]; ok {
+		return tint, true
+	}
+	switch textureTints[texture] {
+	case tintGrass:
+		return t.grass, true
+	case tintFoliage:
+		return t.foliage, true
+	case tintWater:
+		return t.water, true
+	}
+	return [3]uint8{}, false
+}
+
+// sampleColormap picks the color of a biome from a colormap texture the way
+// the game does: temperature rises from right to left and downfall, scaled by
+// temperature, bottom to top. It returns fallback without a colormap.
+func sampleColormap(colormap image.Image, b biome, fallback [3]uint8) [3]uint8 {
+	if colormap == nil {
+		return fallback
+	}
+	temperature := math.Min(math.Max(b.temperature, 0), 1)
+	downfall := math.Min(math.Max(b.downfall, 0), 1) * temperature
+	bounds := colormap.Bounds()
+	x := bounds.Min.X + int((1-temperature)*float64(bounds.Dx()-1))
+	y := bounds.Min.Y + int((1-downfall)*float64(bounds.Dy()-1))
+	r, g, bl, _ := colormap.At(x, y).RGBA()
+	return [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8)}
+}
+
+// multiplyColor tints a color the way the game tints textures, channel by channel.
+func multiplyColor(c, tint [3]uint8) [3]uint8 {
+	var out [3]uint8
+	for i := range c {
+		out[i] = uint8(uint16(c[i]) * uint16(tint[i]) / 255)
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+// blockEntityKey is the palette metadata key holding a block entity template: an
+// SNBT compound, such as a player head's {profile:{...}}, written with every block
+// placed for the entry. Many entries may share one block ID, each with its own
+// template, so heads with different skins can stand for different colors.
+const blockEntityKey = "block_entity"
+
+// blockEntity is a parsed block entity template.
+type blockEntity struct {
+	id     string                         // Block entity type, such as minecraft:skull
+	fields sortedCompound[nbt.RawMessage] // Data other than the type and position
+}
+
+// paletteBlockEntity returns the block entity template of a palette entry, or ""
+// when it has none.
+func paletteBlockEntity(color *PaletteColor) string {
+	snbt, _ := color.Metadata[blockEntityKey].(string)
+	return strings.TrimSpace(snbt)
+}
+
+// parseBlockEntity parses the SNBT compound template of a block entity placed
+// with blockID. Its type comes from the template's id field or, without one,
+// from the block. Position fields are dropped, since each placed block sets its
+// own.
+func parseBlockEntity(snbt, blockID string) (*blockEntity, error) {
+	var buf bytes.Buffer
+	if err := nbt.NewEncoder(&buf).Encode(nbt.StringifiedMessage(snbt), ""); err != nil {
+		return nil, fmt.Errorf("%w: block entity of %s: %v", ErrInvalidConfig, blockID, err)
+	}
+	var fields map[string]nbt.RawMessage
+	if _, err := nbt.NewDecoder(&buf).Decode(&fields); err != nil {
+		return nil, fmt.Errorf("%w: block entity of %s is not a compound: %v", ErrInvalidConfig, blockID, err)
+	}
+
+	entity := &blockEntity{id: blockEntityID(parseBlockState(blockID).Name), fields: make(sortedCompound[nbt.RawMessage])}
+	for key, value := range fields {
+		switch key {
+		case "id", "Id":
+			if err := value.Unmarshal(&entity.id); err != nil {
+				return nil, fmt.Errorf("%w: block entity of %s has a non-string %s", ErrInvalidConfig, blockID, key)
+			}
+		case "x", "y", "z", "Pos":
+		default:
+			entity.fields[key] = value
+		}
+	}
+	return entity, nil
+}
+
+// blockEntityID returns the block entity type of a block, which differs from the
+// block's own name for the kinds that share one type.
+func blockEntityID(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_head") || strings.HasSuffix(name, "_skull"):
+		return "minecraft:skull"
+	case strings.HasSuffix(name, "_hanging_sign"):
+		return "minecraft:hanging_sign"
+	case strings.HasSuffix(name, "_sign"):
+		return "minecraft:sign"
+	case strings.HasSuffix(name, "_banner"):
+		return "minecraft:banner"
+	}
+	return name
+}
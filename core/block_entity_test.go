@@ -0,0 +1,75 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSchematicBlockEntities(t *testing.T) {
+	head := func(rgb [3]uint8, skin string) PaletteColor {
+		return PaletteColor{RGB: rgb, Metadata: map[string]interface{}{
+			"block_id":     "minecraft:player_head",
+			"block_entity": `{profile:{properties:[{name:"textures",value:"` + skin + `"}]},x:9}`,
+		}}
+	}
+	palette := &Palette{Colors: []PaletteColor{
+		head([3]uint8{200, 40, 40}, "red"),
+		head([3]uint8{40, 40, 200}, "blue"),
+		{RGB: [3]uint8{90, 60, 30}, Metadata: map[string]interface{}{"block_id": "minecraft:chest", "block_entity": `{id:"minecraft:chest",CustomName:'"Loot"'}`}},
+		{RGB: [3]uint8{120, 120, 120}, Metadata: map[string]interface{}{"block_id": "minecraft:stone"}},
+	}}
+	vg := NewVoxelGrid(3, 2, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{40, 40, 200})
+	vg.SetVoxel(2, 0, 0, [3]uint8{200, 40, 40})
+	vg.SetVoxel(1, 1, 0, [3]uint8{90, 60, 30})
+	vg.SetVoxel(1, 0, 0, [3]uint8{120, 120, 120})
+
+	for _, version := range []int{2, 3} {
+		var buf bytes.Buffer
+		if err := NewSchematicExporter(version).Export(vg, palette, DitherConfig{}, &buf); err != nil {
+			t.Fatalf("v%d: export: %v", version, err)
+		}
+		container := decodeSchematic(t, buf.Bytes())
+		if version == 3 {
+			container, _ = container["Schematic"].(map[string]interface{})
+			container, _ = container["Blocks"].(map[string]interface{})
+		}
+		entities, _ := container["BlockEntities"].([]interface{})
+		if len(entities) != 3 {
+			t.Fatalf("v%d: %d block entities, want 3", version, len(entities))
+		}
+		// Ordered as block data runs: (0, 0, 0), (2, 0, 0), (1, 1, 0)
+		wantPos := [][]int32{{0, 0, 0}, {2, 0, 0}, {1, 1, 0}}
+		wantID := []string{"minecraft:skull", "minecraft:skull", "minecraft:chest"}
+		for i, raw := range entities {
+			entity, _ := raw.(map[string]interface{})
+			pos, _ := entity["Pos"].([]int32)
+			if len(pos) != 3 || pos[0] != wantPos[i][0] || pos[1] != wantPos[i][1] || pos[2] != wantPos[i][2] || entity["Id"] != wantID[i] {
+				t.Errorf("v%d: entity %d at %v with Id %v, want %v and %s", version, i, pos, entity["Id"], wantPos[i], wantID[i])
+			}
+			data := entity
+			if version == 3 {
+				data, _ = entity["Data"].(map[string]interface{})
+			}
+			if _, ok := data["x"]; ok {
+				t.Errorf("v%d: entity %d kept the template's position", version, i)
+			}
+			if i < 2 {
+				profile, _ := data["profile"].(map[string]interface{})
+				properties, _ := profile["properties"].([]interface{})
+				texture, _ := properties[0].(map[string]interface{})
+				if want := []string{"blue", "red"}[i]; texture["value"] != want {
+					t.Errorf("v%d: head %d skin = %v, want %s", version, i, texture["value"], want)
+				}
+			} else if data["CustomName"] != `"Loot"` || data["id"] != nil {
+				t.Errorf("v%d: chest data = %v", version, data)
+			}
+		}
+	}
+
+	palette.Colors[0].Metadata["block_entity"] = `{profile:`
+	if err := NewSchematicExporter(2).Export(vg, palette, DitherConfig{}, &bytes.Buffer{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for a malformed template, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Rotations are quarter turns about y, clockwise seen from above, so a turn
+// moves what faced north to face east. RotationNames gives each the direction
+// the grid's north side faces after it.
+var rotationNames = []string{"north", "east", "south", "west"}
+
+// RotationNames returns the names of 0 to 3 quarter turns, as used for the file
+// names of rotation variants.
+func RotationNames() []string {
+	return append([]string(nil), rotationNames...)
+}
+
+// checkRotation returns an ErrInvalidConfig error for a rotation outside 0 to 3
+// quarter turns.
+func checkRotation(turns int) error {
+	if turns < 0 || turns > 3 {
+		return fmt.Errorf("%w: rotation must be 0 to 3 quarter turns, got %d", ErrInvalidConfig, turns)
+	}
+	return nil
+}
+
+// horizontalTurn maps each horizontal direction to the one a quarter turn
+// takes it to.
+var horizontalTurn = map[string]string{"north": "east", "east": "south", "south": "west", "west": "north"}
+
+// railShapeTurn maps each rail shape to the one a quarter turn gives.
+var railShapeTurn = map[string]string{
+	"north_south":     "east_west",
+	"east_west":       "north_south",
+	"ascending_north": "ascending_east",
+	"ascending_east":  "ascending_south",
+	"ascending_south": "ascending_west",
+	"ascending_west":  "ascending_north",
+	"north_east":      "south_east",
+	"south_east":      "south_west",
+	"south_west":      "north_west",
+	"north_west":      "north_east",
+}
+
+// RotateBlockState turns a block state such as "minecraft:oak_stairs[facing=north]"
+// by quarter turns about y, clockwise seen from above, updating the properties
+// that depend on it: horizontal facing, log axis, sign and banner rotation, fence
+// and wall connections and rail shapes. States without such properties are
+// returned unchanged.
+func RotateBlockState(id string, turns int) string {
+	turns = (turns%4 + 4) % 4
+	if turns == 0 {
+		return id
+	}
+	state := parseBlockState(id)
+	if len(state.Properties) == 0 {
+		return id
+	}
+	rotated := make(sortedCompound[string], len(state.Properties))
+	changed := false
+	for key, value := range state.Properties {
+		newKey, newValue := key, value
+		for i := 0; i < turns; i++ {
+			newKey, newValue = turnProperty(newKey, newValue)
+		}
+		rotated[newKey] = newValue
+		changed = changed || newKey != key || newValue != value
+	}
+	if !changed {
+		return id
+	}
+	return blockStateKey(structureBlockState{Name: state.Name, Properties: rotated})
+}
+
+// turnProperty returns a block state property after a quarter turn.
+func turnProperty(key, value string) (string, string) {
+	switch key {
+	case "facing":
+		if turned, ok := horizontalTurn[value]; ok {
+			return key, turned
+		}
+	case "axis":
+		switch value {
+		case "x":
+			return key, "z"
+		case "z":
+			return key, "x"
+		}
+	case "rotation":
+		if n, err := strconv.Atoi(value); err == nil {
+			return key, strconv.Itoa((n + 4) % 16)
+		}
+	case "shape":
+		if turned, ok := railShapeTurn[value]; ok {
+			return key, turned
+		}
+	case "north", "east", "south", "west":
+		return horizontalTurn[key], value
+	}
+	return key, value
+}
+
+// rotateBlockIDs turns every block ID by quarter turns, merging IDs that turn
+// into the same state. It returns the turned IDs and the index of each original
+// ID among them; air stays at index 0.
+func rotateBlockIDs(ids []string, turns int) ([]string, []int32) {
+	rotated := make([]string, 0, len(ids))
+	remap := make([]int32, len(ids))
+	index := make(map[string]int32, len(ids))
+	for i, id := range ids {
+		id = RotateBlockState(id, turns)
+		j, ok := index[id]
+		if !ok {
+			j = int32(len(rotated))
+			index[id] = j
+			rotated = append(rotated, id)
+		}
+		remap[i] = j
+	}
+	return rotated, remap
+}
+
+// rotatedSize returns a grid's x and z extents after quarter turns about y.
+func rotatedSize(sizeX, sizeZ, turns int) (int, int) {
+	if turns%2 == 1 {
+		return sizeZ, sizeX
+	}
+	return sizeX, sizeZ
+}
+
+// rotatePosition returns where a cell of a grid with the given x and z extents
+// lands after quarter turns about y, clockwise seen from above.
+func rotatePosition(x, z, sizeX, sizeZ, turns int) (int, int) {
+	for i := 0; i < turns; i++ {
+		x, z = sizeZ-1-z, x
+		sizeX, sizeZ = sizeZ, sizeX
+	}
+	return x, z
+}
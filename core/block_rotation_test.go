@@ -0,0 +1,119 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+func TestRotateBlockState(t *testing.T) {
+	tests := []struct {
+		id    string
+		turns int
+		want  string
+	}{
+		{"minecraft:stone", 1, "minecraft:stone"},
+		{"minecraft:oak_log[axis=y]", 1, "minecraft:oak_log[axis=y]"},
+		{"minecraft:oak_log[axis=x]", 1, "minecraft:oak_log[axis=z]"},
+		{"minecraft:oak_log[axis=x]", 2, "minecraft:oak_log[axis=x]"},
+		{"minecraft:oak_stairs[facing=north,half=top,shape=inner_left]", 1, "minecraft:oak_stairs[facing=east,half=top,shape=inner_left]"},
+		{"minecraft:oak_stairs[facing=north]", 3, "minecraft:oak_stairs[facing=west]"},
+		{"minecraft:oak_stairs[facing=west]", -1, "minecraft:oak_stairs[facing=south]"},
+		{"minecraft:oak_sign[rotation=14]", 1, "minecraft:oak_sign[rotation=2]"},
+		{"minecraft:oak_fence[east=false,north=true,south=false,west=true]", 1, "minecraft:oak_fence[east=true,north=true,south=false,west=false]"},
+		{"minecraft:rail[shape=north_west]", 1, "minecraft:rail[shape=north_east]"},
+		{"minecraft:rail[shape=ascending_south]", 2, "minecraft:rail[shape=ascending_north]"},
+		{"minecraft:piston[facing=up]", 1, "minecraft:piston[facing=up]"},
+	}
+	for _, tt := range tests {
+		if got := RotateBlockState(tt.id, tt.turns); got != tt.want {
+			t.Errorf("RotateBlockState(%q, %d) = %q, want %q", tt.id, tt.turns, got, tt.want)
+		}
+	}
+
+	ids, remap := rotateBlockIDs([]string{"minecraft:air", "minecraft:oak_log[axis=x]", "minecraft:oak_log[axis=z]"}, 1)
+	if len(ids) != 3 || ids[0] != "minecraft:air" || remap[1] != 1 || remap[2] != 2 || ids[1] != "minecraft:oak_log[axis=z]" {
+		t.Errorf("rotateBlockIDs = %v, %v", ids, remap)
+	}
+}
+
+// rotationTestGrid returns a 3x1x2 grid holding a north-facing stair at (0, 0, 0)
+// and a stone block at (2, 0, 1), with its palette.
+func rotationTestGrid() (*VoxelGrid, *Palette) {
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{160, 128, 76}, Metadata: map[string]interface{}{"block_id": "minecraft:oak_stairs[facing=north,half=bottom]"}},
+		{RGB: [3]uint8{120, 120, 120}, Metadata: map[string]interface{}{"block_id": "minecraft:stone"}},
+	}}
+	vg := NewVoxelGrid(3, 1, 2)
+	vg.SetVoxel(0, 0, 0, [3]uint8{160, 128, 76})
+	vg.SetVoxel(2, 0, 1, [3]uint8{120, 120, 120})
+	return vg, palette
+}
+
+func TestSchematicRotation(t *testing.T) {
+	vg, palette := rotationTestGrid()
+	exporter := NewSchematicExporter(2)
+	exporter.Rotation = 1
+	var buf bytes.Buffer
+	if err := exporter.Export(vg, palette, DitherConfig{}, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	schematic := decodeSchematic(t, buf.Bytes())
+	if schematic["Width"] != int16(2) || schematic["Length"] != int16(3) {
+		t.Fatalf("size = %v x %v, want 2 x 3", schematic["Width"], schematic["Length"])
+	}
+	schemPalette, _ := schematic["Palette"].(map[string]interface{})
+	stairs, ok := schemPalette["minecraft:oak_stairs[facing=east,half=bottom]"].(int32)
+	if !ok {
+		t.Fatalf("palette = %v, want east-facing stairs", schemPalette)
+	}
+	stone, _ := schemPalette["minecraft:stone"].(int32)
+	data, _ := schematic["BlockData"].([]byte)
+	// (0, 0, 0) turns to (1, 0, 0) and (2, 0, 1) to (0, 0, 2)
+	if data[schematicIndex(1, 0, 0, 2, 3)] != byte(stairs) || data[schematicIndex(0, 0, 2, 2, 3)] != byte(stone) {
+		t.Errorf("BlockData = %v, want stairs at (1, 0, 0) and stone at (0, 0, 2)", data)
+	}
+
+	exporter.Rotation = 4
+	if err := exporter.Export(vg, palette, DitherConfig{}, &bytes.Buffer{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for 4 turns, got %v", err)
+	}
+}
+
+func TestStructureRotation(t *testing.T) {
+	vg, palette := rotationTestGrid()
+	exporter := NewStructureExporter()
+	exporter.Rotation = 2
+	var buf bytes.Buffer
+	if err := exporter.Export(vg, palette, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	var structure structureFile
+	if _, err := nbt.NewDecoder(gz).Decode(&structure); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if structure.Size[0] != 3 || structure.Size[2] != 2 {
+		t.Errorf("size = %v, want [3 1 2]", structure.Size)
+	}
+	for _, block := range structure.Blocks {
+		state := structure.Palette[block.State]
+		pos := [3]int32{block.Pos[0], block.Pos[1], block.Pos[2]}
+		switch state.Name {
+		case "minecraft:oak_stairs":
+			if pos != [3]int32{2, 0, 1} || state.Properties["facing"] != "south" {
+				t.Errorf("stairs at %v facing %s, want (2, 0, 1) facing south", pos, state.Properties["facing"])
+			}
+		case "minecraft:stone":
+			if pos != [3]int32{0, 0, 0} {
+				t.Errorf("stone at %v, want (0, 0, 0)", pos)
+			}
+		}
+	}
+}
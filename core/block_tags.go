@@ -0,0 +1,138 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Built-in block tags usable in PaletteFilter patterns as "#name".
+const (
+	TagFlammable    = "flammable"     // Blocks that catch fire and burn away
+	TagGravity      = "gravity"       // Blocks that fall when unsupported
+	TagUnobtainable = "unobtainable"  // Blocks that cannot be obtained in survival
+	TagNeedsSupport = "needs_support" // Blocks that break without a block beneath or beside them
+	TagTranslucent  = "translucent"   // Blocks that can be seen through, such as glass and leaves
+	TagLightSource  = "light_source"  // Full blocks that emit light, such as glowstone and froglights
+)
+
+// blockTags maps each built-in tag to the vanilla block ID patterns it covers.
+var blockTags = map[string][]string{
+	TagFlammable: {
+		"minecraft:*_wool", "minecraft:*_carpet", "minecraft:*_planks",
+		"minecraft:*_log", "minecraft:*_wood", "minecraft:stripped_*_log",
+		"minecraft:stripped_*_wood", "minecraft:*_leaves", "minecraft:bamboo_block",
+		"minecraft:bamboo_mosaic", "minecraft:bookshelf", "minecraft:hay_block",
+		"minecraft:dried_kelp_block", "minecraft:tnt", "minecraft:target",
+		"minecraft:*_fence", "minecraft:*_fence_gate", "minecraft:scaffolding",
+	},
+	TagGravity: {
+		"minecraft:sand", "minecraft:red_sand", "minecraft:gravel",
+		"minecraft:suspicious_sand", "minecraft:suspicious_gravel",
+		"minecraft:*_concrete_powder", "minecraft:anvil", "minecraft:chipped_anvil",
+		"minecraft:damaged_anvil", "minecraft:dragon_egg", "minecraft:scaffolding",
+		"minecraft:pointed_dripstone",
+	},
+	TagNeedsSupport: {
+		"minecraft:torch", "minecraft:*_torch", "minecraft:wall_torch", "minecraft:lantern",
+		"minecraft:soul_lantern", "minecraft:*_carpet", "minecraft:moss_carpet",
+		"minecraft:rail", "minecraft:*_rail", "minecraft:*_pressure_plate",
+		"minecraft:*_button", "minecraft:lever", "minecraft:ladder", "minecraft:*_sign",
+		"minecraft:*_banner", "minecraft:redstone_wire", "minecraft:repeater",
+		"minecraft:comparator", "minecraft:snow", "minecraft:*_sapling",
+		"minecraft:flower_pot", "minecraft:*_candle", "minecraft:candle",
+	},
+	TagTranslucent: {
+		"minecraft:glass", "minecraft:*_glass", "minecraft:glass_pane",
+		"minecraft:*_glass_pane", "minecraft:*_leaves", "minecraft:ice",
+		"minecraft:frosted_ice", "minecraft:slime_block", "minecraft:honey_block",
+		"minecraft:water", "minecraft:spawner", "minecraft:iron_bars",
+	},
+	TagLightSource: {
+		"minecraft:glowstone", "minecraft:sea_lantern", "minecraft:*_froglight",
+		"minecraft:shroomlight", "minecraft:jack_o_lantern", "minecraft:magma_block",
+		"minecraft:crying_obsidian",
+	},
+	TagUnobtainable: {
+		"minecraft:bedrock", "minecraft:barrier", "minecraft:light",
+		"minecraft:structure_block", "minecraft:structure_void", "minecraft:jigsaw",
+		"minecraft:*command_block", "minecraft:spawner", "minecraft:end_portal_frame",
+		"minecraft:end_portal", "minecraft:nether_portal", "minecraft:end_gateway",
+		"minecraft:reinforced_deepslate", "minecraft:budding_amethyst",
+		"minecraft:petrified_oak_slab", "minecraft:infested_*", "minecraft:farmland",
+		"minecraft:dirt_path", "minecraft:frogspawn", "minecraft:player_head",
+	},
+}
+
+// BlockTagNames returns the names of the built-in block tags in sorted order.
+func BlockTagNames() []string {
+	names := make([]string, 0, len(blockTags))
+	for name := range blockTags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasBlockTag reports whether a palette entry carries tag, either through the
+// built-in tag table or its "tags" metadata. Entries with translucency metadata
+// above zero are translucent.
+func hasBlockTag(color *PaletteColor, tag string) bool {
+	if patterns, ok := blockTags[tag]; ok && matchesBlockID(color.Name, patterns) {
+		return true
+	}
+	if tag == TagTranslucent && color.Translucency() > 0 {
+		return true
+	}
+	return slices.Contains(metadataTags(color), tag)
+}
+
+// paletteHasTag reports whether any palette entry lists tag in its "tags" metadata.
+func paletteHasTag(palette *Palette, tag string) bool {
+	for i := range palette.Colors {
+		if slices.Contains(metadataTags(&palette.Colors[i]), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataTags returns the entry's "tags" metadata, which is a []string when
+// built in Go and a []interface{} after a msgpack round trip.
+func metadataTags(color *PaletteColor) []string {
+	switch tags := color.Metadata["tags"].(type) {
+	case []string:
+		return tags
+	case []interface{}:
+		out := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// blockProperties returns the block state properties of a palette entry, taken
+// from its "properties" metadata and any "[key=value,...]" suffix of its block_id.
+func blockProperties(color *PaletteColor) map[string]string {
+	props := make(map[string]string)
+	switch meta := color.Metadata["properties"].(type) {
+	case map[string]string:
+		for key, value := range meta {
+			props[key] = value
+		}
+	case map[string]interface{}:
+		for key, value := range meta {
+			props[key] = fmt.Sprint(value)
+		}
+	}
+	if id, ok := color.Metadata["block_id"].(string); ok {
+		for key, value := range parseBlockState(id).Properties {
+			props[key] = value
+		}
+	}
+	return props
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// BlockWeight sets the matching weight and cost of palette blocks. Unset fields
+// leave the entries' values as they are.
+type BlockWeight struct {
+	Weight *float64 `json:"weight,omitempty"` // See PaletteColor.Weight
+	Cost   *float64 `json:"cost,omitempty"`   // See PaletteColor.Cost
+}
+
+// BlockWeights maps block patterns, as PaletteFilter takes them, to the weights
+// and costs of the palette entries they match. Broader patterns apply first, so
+// more specific ones override them: tags, then key=value properties, then globs,
+// then exact block IDs, each group in sorted order.
+type BlockWeights map[string]BlockWeight
+
+// LoadBlockWeightsJSON reads block weights from a JSON object mapping block
+// patterns to {"weight": w, "cost": c} objects.
+func LoadBlockWeightsJSON(r io.Reader) (BlockWeights, error) {
+	var weights BlockWeights
+	counter := &countingReader{r: r}
+	decoder := json.NewDecoder(counter)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&weights); err != nil {
+		return nil, &FormatError{Format: "block weights", Offset: counter.n, Err: err}
+	}
+	return weights, nil
+}
+
+// Apply returns a copy of palette with the weights and costs of the entries
+// the patterns match set. It fails on malformed patterns, unknown tags, and
+// weights that are not positive or costs that are negative.
+func (w BlockWeights) Apply(palette *Palette) (*Palette, error) {
+	patterns := make([]string, 0, len(w))
+	for pattern, weight := range w {
+		if err := validateBlockPattern(pattern, palette); err != nil {
+			return nil, err
+		}
+		if weight.Weight != nil && *weight.Weight <= 0 {
+			return nil, fmt.Errorf("%w: weight of %q must be positive, got %g", ErrInvalidConfig, pattern, *weight.Weight)
+		}
+		if weight.Cost != nil && *weight.Cost < 0 {
+			return nil, fmt.Errorf("%w: cost of %q must not be negative, got %g", ErrInvalidConfig, pattern, *weight.Cost)
+		}
+		patterns = append(patterns, pattern)
+	}
+	slices.SortFunc(patterns, func(a, b string) int {
+		if ra, rb := patternSpecificity(a), patternSpecificity(b); ra != rb {
+			return ra - rb
+		}
+		return strings.Compare(a, b)
+	})
+
+	weighted := &Palette{Colors: append([]PaletteColor(nil), palette.Colors...)}
+	for _, pattern := range patterns {
+		weight := w[pattern]
+		for i := range weighted.Colors {
+			color := &weighted.Colors[i]
+			if !matchesBlock(color, []string{pattern}) {
+				continue
+			}
+			if weight.Weight != nil {
+				color.Weight = *weight.Weight
+			}
+			if weight.Cost != nil {
+				color.Cost = *weight.Cost
+			}
+		}
+	}
+	return weighted, nil
+}
+
+// patternSpecificity ranks block patterns from the broadest, tags, to exact
+// block IDs.
+func patternSpecificity(pattern string) int {
+	switch {
+	case strings.HasPrefix(pattern, "#"):
+		return 0
+	case strings.Contains(pattern, "="):
+		return 1
+	case strings.ContainsAny(pattern, "*?["):
+		return 2
+	}
+	return 3
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBlockWeights(t *testing.T) {
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:white_wool", RGB: [3]uint8{233, 236, 236}},
+		{ID: "minecraft:white_concrete", RGB: [3]uint8{207, 213, 214}},
+		{ID: "minecraft:diamond_block", RGB: [3]uint8{98, 237, 228}},
+	})
+	weights, err := LoadBlockWeightsJSON(strings.NewReader(`{
+		"minecraft:white_concrete": {"weight": 4},
+		"minecraft:white_*": {"weight": 0.5, "cost": 1},
+		"diamond_block": {"cost": 80}
+	}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	weighted, err := weights.Apply(palette)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	want := []struct{ weight, cost float64 }{{0.5, 1}, {4, 1}, {0, 80}} // The exact ID overrides the glob's weight
+	for i, w := range want {
+		if c := weighted.Colors[i]; c.Weight != w.weight || c.Cost != w.cost {
+			t.Errorf("%s has weight %g and cost %g, want %g and %g", c.Name, c.Weight, c.Cost, w.weight, w.cost)
+		}
+	}
+	if palette.Colors[0].Weight != 0 {
+		t.Error("Apply changed the original palette")
+	}
+
+	// A color nearer the wool goes to the favored concrete
+	gray := [3]uint8{225, 228, 229}
+	if got := NewCIELABMatcher(palette).Match(gray); got.Name != "minecraft:white_wool" {
+		t.Fatalf("unweighted match = %s, want white_wool", got.Name)
+	}
+	if got := NewCIELABMatcher(weighted).Match(gray); got.Name != "minecraft:white_concrete" {
+		t.Errorf("weighted match = %s, want white_concrete", got.Name)
+	}
+
+	// Weights and costs survive a JSON round trip
+	var buf bytes.Buffer
+	if err := ExportPaletteJSON(weighted, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	imported, err := ImportPaletteJSON(&buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if imported.Colors[1].Weight != 4 || imported.Colors[2].Cost != 80 {
+		t.Errorf("imported = %+v", imported.Colors)
+	}
+
+	for _, bad := range []string{`{"stone": {"weight": 0}}`, `{"stone": {"cost": -1}}`, `{"#no_such_tag": {"cost": 1}}`} {
+		weights, err := LoadBlockWeightsJSON(strings.NewReader(bad))
+		if err == nil {
+			_, err = weights.Apply(palette)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+	if _, err := LoadBlockWeightsJSON(strings.NewReader(`{"stone": {"price": 1}}`)); !errors.As(err, new(*FormatError)) {
+		t.Errorf("expected a FormatError for an unknown field, got %v", err)
+	}
+}
@@ -21,9 +21,120 @@ type PaletteColor struct {
 	Name     string
 	RGB      [3]uint8
 	LAB      LABColor
+	Weight   float64                // Matching preference: color differences are divided by it, so above 1 favors the block and below 1 avoids it (0 = 1)
+	Cost     float64                // Price of one block in any unit, summed into the material report (0 = none)
 	Metadata map[string]interface{} // For Minecraft-specific data (block ID, etc.)
 }
 
+// BlockFace names a group of block faces that can carry their own texture.
+type BlockFace string
+
+// Block faces distinguished by per-face palette colors.
+const (
+	FaceTop    BlockFace = "top"
+	FaceSide   BlockFace = "side"
+	FaceBottom BlockFace = "bottom"
+)
+
+// blockFaces lists the faces in the order per-face colors are indexed.
+var blockFaces = [...]BlockFace{FaceTop, FaceSide, FaceBottom}
+
+// FaceRGB returns the average color of one face of the block, read from the
+// "face_colors" metadata, or RGB when the face has no color of its own.
+func (c *PaletteColor) FaceRGB(face BlockFace) [3]uint8 {
+	var faces map[string]interface{}
+	switch meta := c.Metadata["face_colors"].(type) {
+	case map[string][3]uint8:
+		if rgb, ok := meta[string(face)]; ok {
+			return rgb
+		}
+		return c.RGB
+	case map[string]interface{}:
+		faces = meta
+	default:
+		return c.RGB
+	}
+	
+	// After a msgpack round trip colors are byte strings or arrays of numbers
+	var rgb [3]uint8
+	switch v := faces[string(face)].(type) {
+	case [3]uint8:
+		return v
+	case []byte:
+		if len(v) != 3 {
+			return c.RGB
+		}
+		copy(rgb[:], v)
+	case []interface{}:
+		if len(v) != 3 {
+			return c.RGB
+		}
+		for i, n := range v {
+			f, ok := metadataNumber(n)
+			if !ok || f < 0 || f > 255 {
+				return c.RGB
+			}
+			rgb[i] = uint8(f)
+		}
+	default:
+		return c.RGB
+	}
+	return rgb
+}
+
+// HasFaceColors reports whether any face of the block has a color of its own.
+func (c *PaletteColor) HasFaceColors() bool {
+	for _, face := range blockFaces {
+		if c.FaceRGB(face) != c.RGB {
+			return true
+		}
+	}
+	return false
+}
+
+// Noise returns how much the block's texture varies around its average color,
+// from its "noise" metadata; blocks without it count as smooth.
+func (c *PaletteColor) Noise() float64 {
+	n, _ := metadataNumber(c.Metadata["noise"])
+	return n
+}
+
+// Translucency returns the fraction of light the block lets through, from its
+// "translucency" metadata; blocks without it are opaque.
+func (c *PaletteColor) Translucency() float64 {
+	t, _ := metadataNumber(c.Metadata["translucency"])
+	return t
+}
+
+// metadataNumber converts a decoded msgpack number to float64.
+func metadataNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
 // ColorMatcher is the interface for finding the closest color match.
 type ColorMatcher interface {
 	// Match finds the best matching palette color for the given RGB color.
@@ -36,10 +147,54 @@ type ColorMatcher interface {
 	SetPalette(palette *Palette)
 }
 
+// WeightedMatcher is implemented by matchers whose color difference can weight
+// lightness against color.
+type WeightedMatcher interface {
+	// SetWeights sets the weights used by later matches.
+	SetWeights(weights MatchWeights)
+}
+
+// MatchWeights weights the lightness (L*) and color (a*, b*) differences of
+// CIELAB matching. Both scale differences linearly: {Lightness: 2, Color: 1}
+// counts a lightness error twice as much as a hue or saturation error of the
+// same size, favoring blocks of the right brightness. Zero weights count as 1.
+type MatchWeights struct {
+	Lightness float64
+	Color     float64
+}
+
+// factors returns the multipliers of the L*, a* and b* differences.
+func (w MatchWeights) factors() [3]float64 {
+	l, c := w.Lightness, w.Color
+	if l == 0 {
+		l = 1
+	}
+	if c == 0 {
+		c = 1
+	}
+	return [3]float64{l, c, c}
+}
+
+// FaceMatcher is implemented by matchers that can match against the color of a
+// single block face, for blocks whose faces look different.
+type FaceMatcher interface {
+	// MatchFace finds the palette color whose given face best matches rgb.
+	MatchFace(rgb [3]uint8, face BlockFace) *PaletteColor
+}
+
+// NoiseMatcher is implemented by matchers that weigh how noisy a block's texture
+// is against how noisy the colors around a voxel are.
+type NoiseMatcher interface {
+	// MatchNoise finds the palette color best matching rgb, as seen from face
+	// ("" for the whole block), for a voxel whose surroundings have the given
+	// noise (see PaletteColor.Noise).
+	MatchNoise(rgb [3]uint8, face BlockFace, noise float64) *PaletteColor
+}
+
 // DitherConfig holds parameters for error diffusion dithering.
 type DitherConfig struct {
 	Enabled   bool
-	Algorithm string // "floyd-steinberg", "jarvis", "stucki", etc.
+	Algorithm string // See DitherAlgorithms: error diffusion ("floyd-steinberg", "jarvis", "stucki", "atkinson", "sierra") or ordered ("bayer4", "bayer8")
 }
 
 // RGBToLAB converts an RGB color to CIELAB color space.
@@ -82,3 +237,15 @@ func DeltaE(lab1, lab2 LABColor) float64 {
 	// Use CIEDE2000 distance
 	return c1.DistanceCIEDE2000(c2)
 }
+
+// weightedDeltaE is DeltaE with lightness and color differences scaled by w,
+// through the kL, kC and kH parametric factors of CIEDE2000.
+func weightedDeltaE(lab1, lab2 LABColor, w MatchWeights) float64 {
+	f := w.factors()
+	if f == [3]float64{1, 1, 1} {
+		return DeltaE(lab1, lab2)
+	}
+	c1 := colorful.Lab(lab1.L, lab1.A, lab1.B)
+	c2 := colorful.Lab(lab2.L, lab2.A, lab2.B)
+	return c1.DistanceCIEDE2000klch(c2, 1/f[0], 1/f[1], 1/f[1])
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// labIndex is a k-d tree over the CIELAB coordinates of a palette. CIEDE2000 is
+// not a Euclidean metric, so the tree finds every color within a Euclidean
+// margin of the Euclidean nearest and ranks those candidates by CIEDE2000.
+// Coordinates are scaled by the match weights, so both distances weight alike.
+type labIndex struct {
+	colors  []PaletteColor
+	points  []labPoint // Tree order: each range's median splits its subranges
+	weights MatchWeights
+	factors [3]float64
+}
+
+type labPoint struct {
+	lab   [3]float64
+	index int
+}
+
+// Candidates farther than max(nearest*labCandidateRatio, nearest+labCandidateSlack)
+// in Euclidean distance are assumed not to be the CIEDE2000 nearest. CIEDE2000
+// shrinks chroma differences for saturated colors, so the margin must be generous.
+const (
+	labCandidateRatio = 2.0
+	labCandidateSlack = 12.0
+)
+
+func newLabIndex(colors []PaletteColor, weights MatchWeights) *labIndex {
+	idx := &labIndex{
+		colors:  colors,
+		points:  make([]labPoint, len(colors)),
+		weights: weights,
+		factors: weights.factors(),
+	}
+	for i, c := range colors {
+		idx.points[i] = labPoint{lab: idx.scaled(c.LAB), index: i}
+	}
+	idx.build(idx.points, 0)
+	return idx
+}
+
+func (idx *labIndex) build(points []labPoint, axis int) {
+	if len(points) <= 1 {
+		return
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].lab[axis] < points[j].lab[axis] })
+	mid := len(points) / 2
+	idx.build(points[:mid], (axis+1)%3)
+	idx.build(points[mid+1:], (axis+1)%3)
+}
+
+// scaled returns the weighted tree coordinates of a color.
+func (idx *labIndex) scaled(lab LABColor) [3]float64 {
+	return [3]float64{lab.L * idx.factors[0], lab.A * idx.factors[1], lab.B * idx.factors[2]}
+}
+
+// nearest returns the index of the palette color closest to lab by CIEDE2000.
+func (idx *labIndex) nearest(lab LABColor) int {
+	return idx.nearestBy(lab, nil)
+}
+
+// nearestBy is like nearest, adding penalty(i), if set, to the difference of each
+// candidate color i. Candidates are found by color difference alone, and their
+// differences divided by their palette weights.
+func (idx *labIndex) nearestBy(lab LABColor, penalty func(i int) float64) int {
+	target := idx.scaled(lab)
+	
+	best, bestDist2 := -1, math.MaxFloat64
+	idx.searchNearest(idx.points, 0, target, &best, &bestDist2)
+	
+	d := math.Sqrt(bestDist2)
+	radius := math.Max(d*labCandidateRatio, d+labCandidateSlack)
+	bestDelta := math.MaxFloat64
+	idx.searchRadius(idx.points, 0, target, radius*radius, func(i int) {
+		delta := weightedDeltaE(lab, idx.colors[i].LAB, idx.weights)
+		if w := idx.colors[i].Weight; w > 0 {
+			delta /= w
+		}
+		if penalty != nil {
+			delta += penalty(i)
+		}
+		if delta < bestDelta || (delta == bestDelta && i < best) {
+			bestDelta, best = delta, i
+		}
+	})
+	return best
+}
+
+func (idx *labIndex) searchNearest(points []labPoint, axis int, target [3]float64, best *int, bestDist2 *float64) {
+	if len(points) == 0 {
+		return
+	}
+	mid := len(points) / 2
+	p := points[mid]
+	if d2 := dist2(p.lab, target); d2 < *bestDist2 {
+		*best, *bestDist2 = p.index, d2
+	}
+	diff := target[axis] - p.lab[axis]
+	near, far := points[:mid], points[mid+1:]
+	if diff > 0 {
+		near, far = far, near
+	}
+	idx.searchNearest(near, (axis+1)%3, target, best, bestDist2)
+	if diff*diff < *bestDist2 {
+		idx.searchNearest(far, (axis+1)%3, target, best, bestDist2)
+	}
+}
+
+func (idx *labIndex) searchRadius(points []labPoint, axis int, target [3]float64, radius2 float64, visit func(int)) {
+	if len(points) == 0 {
+		return
+	}
+	mid := len(points) / 2
+	p := points[mid]
+	if dist2(p.lab, target) <= radius2 {
+		visit(p.index)
+	}
+	diff := target[axis] - p.lab[axis]
+	if diff <= 0 || diff*diff <= radius2 {
+		idx.searchRadius(points[:mid], (axis+1)%3, target, radius2, visit)
+	}
+	if diff >= 0 || diff*diff <= radius2 {
+		idx.searchRadius(points[mid+1:], (axis+1)%3, target, radius2, visit)
+	}
+}
+
+func dist2(a, b [3]float64) float64 {
+	d0, d1, d2 := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return d0*d0 + d1*d1 + d2*d2
+}
+
+// matchCacheSize is the number of slots in a matchCache.
+const matchCacheSize = 1 << 16
+
+// matchCache memoizes match results for exact RGB colors in a fixed-size,
+// direct-mapped table, so memory stays bounded however many colors a grid holds.
+// Each slot packs a valid bit, the 24-bit color and the palette index into one
+// word, which makes the cache safe for concurrent use without locks.
+type matchCache struct {
+	slots [matchCacheSize]atomic.Uint64
+}
+
+func cacheKey(rgb [3]uint8) uint64 {
+	return uint64(rgb[0])<<16 | uint64(rgb[1])<<8 | uint64(rgb[2])
+}
+
+func (c *matchCache) slot(key uint64) *atomic.Uint64 {
+	// Fibonacci hashing spreads neighboring colors across the table
+	return &c.slots[(key*0x9E3779B97F4A7C15)>>48]
+}
+
+func (c *matchCache) get(rgb [3]uint8) (int, bool) {
+	key := cacheKey(rgb)
+	v := c.slot(key).Load()
+	if v>>63 == 0 || (v>>32)&0xFFFFFF != key {
+		return 0, false
+	}
+	return int(uint32(v)), true
+}
+
+func (c *matchCache) put(rgb [3]uint8, index int) {
+	key := cacheKey(rgb)
+	c.slot(key).Store(1<<63 | key<<32 | uint64(uint32(index)))
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// bruteForceMatch returns the index of the CIEDE2000-nearest palette color.
+func bruteForceMatch(palette *Palette, rgb [3]uint8) int {
+	lab := RGBToLAB(rgb)
+	best, bestDelta := -1, math.MaxFloat64
+	for i := range palette.Colors {
+		if d := DeltaE(lab, palette.Colors[i].LAB); d < bestDelta {
+			best, bestDelta = i, d
+		}
+	}
+	return best
+}
+
+func randomPalette(r *rand.Rand, n int) *Palette {
+	palette := &Palette{}
+	for i := 0; i < n; i++ {
+		rgb := [3]uint8{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256))}
+		palette.Colors = append(palette.Colors, PaletteColor{RGB: rgb, LAB: RGBToLAB(rgb)})
+	}
+	return palette
+}
+
+func TestLabIndexMatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	palettes := map[string]*Palette{
+		"vanilla": GenerateMinecraftPalette(GetVanillaMinecraftBlocks()),
+		"random":  randomPalette(r, 200),
+	}
+	for name, palette := range palettes {
+		idx := newLabIndex(palette.Colors, MatchWeights{})
+		for n := 0; n < 200; n++ {
+			rgb := [3]uint8{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256))}
+			if got, want := idx.nearest(RGBToLAB(rgb)), bruteForceMatch(palette, rgb); got != want {
+				t.Errorf("%s palette, color %v: got %v, want %v", name, rgb, palette.Colors[got].RGB, palette.Colors[want].RGB)
+			}
+		}
+	}
+}
+
+func TestCIELABMatcherCache(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	palette := randomPalette(r, 64)
+	matcher := NewCIELABMatcher(palette)
+	
+	colors := make([][3]uint8, 100)
+	for i := range colors {
+		colors[i] = [3]uint8{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256))}
+	}
+	want := make([]*PaletteColor, len(colors))
+	for i, c := range colors {
+		want[i] = &palette.Colors[bruteForceMatch(palette, c)]
+	}
+	
+	// Concurrent lookups hit the cache after the first round and must agree
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for round := 0; round < 3; round++ {
+				for i, c := range colors {
+					if got := matcher.Match(c); got != want[i] {
+						t.Errorf("color %v: got %v, want %v", c, got.RGB, want[i].RGB)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	
+	// A new palette must not be answered from the old palette's cache
+	single := &Palette{Colors: []PaletteColor{{Name: "only", RGB: [3]uint8{1, 2, 3}, LAB: RGBToLAB([3]uint8{1, 2, 3})}}}
+	matcher.SetPalette(single)
+	if got := matcher.Match(colors[0]); got == nil || got.Name != "only" {
+		t.Errorf("after SetPalette got %v, want the new palette's only color", got)
+	}
+	matcher.SetPalette(nil)
+	if got := matcher.Match(colors[0]); got != nil {
+		t.Errorf("without a palette got %v, want nil", got)
+	}
+}
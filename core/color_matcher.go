@@ -1,37 +1,56 @@
 package core
 
-import "math"
-
-// CIELABMatcher implements ColorMatcher using CIELAB color space.
+// CIELABMatcher implements ColorMatcher using CIELAB color space. Palette colors
+// are searched through a k-d tree and results are memoized per RGB color, so
+// large palettes and grids with many voxels of the same color stay fast. A
+// matcher is safe for concurrent use, except that SetPalette and SetWeights must
+// not run concurrently with matching.
 type CIELABMatcher struct {
 	palette *Palette
+	weights MatchWeights
+	index   *labIndex
+	cache   *matchCache
+	
+	// Per-face indexes, built only for palettes with per-face colors
+	faceIndex [len(blockFaces)]*labIndex
+	faceCache [len(blockFaces)]*matchCache
 }
 
 // NewCIELABMatcher creates a new CIELAB color matcher.
 func NewCIELABMatcher(palette *Palette) *CIELABMatcher {
-	return &CIELABMatcher{palette: palette}
+	m := &CIELABMatcher{}
+	m.SetPalette(palette)
+	return m
 }
 
 // Match finds the best matching palette color for the given RGB color.
 func (m *CIELABMatcher) Match(rgb [3]uint8) *PaletteColor {
-	if m.palette == nil || len(m.palette.Colors) == 0 {
+	if m.index == nil {
 		return nil
 	}
 	
-	targetLAB := RGBToLAB(rgb)
-	
-	var bestMatch *PaletteColor
-	bestDistance := math.MaxFloat64
-	
-	for i := range m.palette.Colors {
-		distance := DeltaE(targetLAB, m.palette.Colors[i].LAB)
-		if distance < bestDistance {
-			bestDistance = distance
-			bestMatch = &m.palette.Colors[i]
-		}
+	if i, ok := m.cache.get(rgb); ok {
+		return &m.palette.Colors[i]
+	}
+	i := m.index.nearest(RGBToLAB(rgb))
+	m.cache.put(rgb, i)
+	return &m.palette.Colors[i]
+}
+
+// MatchFace finds the palette color whose given face best matches rgb. Palettes
+// without per-face colors match as Match does.
+func (m *CIELABMatcher) MatchFace(rgb [3]uint8, face BlockFace) *PaletteColor {
+	f := faceSlot(face)
+	if f < 0 || m.faceIndex[f] == nil {
+		return m.Match(rgb)
 	}
 	
-	return bestMatch
+	if i, ok := m.faceCache[f].get(rgb); ok {
+		return &m.palette.Colors[i]
+	}
+	i := m.faceIndex[f].nearest(RGBToLAB(rgb))
+	m.faceCache[f].put(rgb, i)
+	return &m.palette.Colors[i]
 }
 
 // MatchWithDithering finds the best match considering dithering error.
@@ -59,9 +78,65 @@ func (m *CIELABMatcher) MatchWithDithering(rgb [3]uint8, error [3]float64) (*Pal
 	return matched, quantError
 }
 
-// SetPalette updates the palette used for matching.
+// SetPalette updates the palette used for matching and rebuilds the search index.
 func (m *CIELABMatcher) SetPalette(palette *Palette) {
 	m.palette = palette
+	m.index, m.cache = nil, nil
+	m.faceIndex, m.faceCache = [len(blockFaces)]*labIndex{}, [len(blockFaces)]*matchCache{}
+	if palette == nil || len(palette.Colors) == 0 {
+		return
+	}
+	m.index = newLabIndex(palette.Colors, m.weights)
+	m.cache = new(matchCache)
+	
+	if !paletteHasFaceColors(palette) {
+		return
+	}
+	for f, face := range blockFaces {
+		// The index keeps palette positions, so results map back to palette entries
+		colors := make([]PaletteColor, len(palette.Colors))
+		for i := range palette.Colors {
+			rgb := palette.Colors[i].FaceRGB(face)
+			colors[i] = PaletteColor{RGB: rgb, LAB: palette.Colors[i].LAB, Weight: palette.Colors[i].Weight}
+			if rgb != palette.Colors[i].RGB {
+				colors[i].LAB = RGBToLAB(rgb)
+			}
+		}
+		m.faceIndex[f] = newLabIndex(colors, m.weights)
+		m.faceCache[f] = new(matchCache)
+	}
+}
+
+// SetWeights sets how lightness and color differences are weighted and rebuilds
+// the search index.
+func (m *CIELABMatcher) SetWeights(weights MatchWeights) {
+	m.weights = weights
+	m.SetPalette(m.palette)
+}
+
+// Weights returns the weights set by SetWeights.
+func (m *CIELABMatcher) Weights() MatchWeights {
+	return m.weights
+}
+
+// faceSlot returns the position of face in blockFaces, or -1 for unknown faces.
+func faceSlot(face BlockFace) int {
+	for i, f := range blockFaces {
+		if f == face {
+			return i
+		}
+	}
+	return -1
+}
+
+// paletteHasFaceColors reports whether any palette entry has per-face colors.
+func paletteHasFaceColors(palette *Palette) bool {
+	for i := range palette.Colors {
+		if palette.Colors[i].HasFaceColors() {
+			return true
+		}
+	}
+	return false
 }
 
 // clampUint8 clamps a float64 value to uint8 range [0, 255].
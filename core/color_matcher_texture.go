@@ -0,0 +1,65 @@
+package core
+
+import "math"
+
+// TextureMatcher is a CIELABMatcher that also compares texture noise. Among the
+// palette colors close to a voxel's color it prefers blocks whose textures are
+// about as noisy as the voxel's surroundings, so smooth regions get smooth blocks
+// such as concrete and noisy regions get granite or gravel. Palettes need the
+// "noise" metadata recorded by TextureExtractor; without it the pipeline matches
+// as with a CIELABMatcher.
+type TextureMatcher struct {
+	*CIELABMatcher
+
+	// NoiseWeight scales the noise difference against the color difference.
+	NoiseWeight float64
+
+	noise []float64 // Noise of each palette entry
+}
+
+// NewTextureMatcher creates a texture-aware matcher with a NoiseWeight of 1.
+func NewTextureMatcher(palette *Palette) *TextureMatcher {
+	m := &TextureMatcher{CIELABMatcher: &CIELABMatcher{}, NoiseWeight: 1}
+	m.SetPalette(palette)
+	return m
+}
+
+// SetPalette updates the palette used for matching and rebuilds the search index.
+func (m *TextureMatcher) SetPalette(palette *Palette) {
+	m.CIELABMatcher.SetPalette(palette)
+	m.noise = nil
+	if palette == nil {
+		return
+	}
+	m.noise = make([]float64, len(palette.Colors))
+	for i := range palette.Colors {
+		m.noise[i] = palette.Colors[i].Noise()
+	}
+}
+
+// MatchNoise finds the palette color whose color, seen from face, and texture
+// noise best match rgb and the noise around the voxel. Results are not cached,
+// since noise varies from voxel to voxel.
+func (m *TextureMatcher) MatchNoise(rgb [3]uint8, face BlockFace, noise float64) *PaletteColor {
+	index := m.index
+	if f := faceSlot(face); f >= 0 && m.faceIndex[f] != nil {
+		index = m.faceIndex[f]
+	}
+	if index == nil {
+		return nil
+	}
+	i := index.nearestBy(RGBToLAB(rgb), func(i int) float64 {
+		return m.NoiseWeight * math.Abs(m.noise[i]-noise)
+	})
+	return &m.palette.Colors[i]
+}
+
+// paletteHasNoise reports whether any palette entry records texture noise.
+func paletteHasNoise(palette *Palette) bool {
+	for i := range palette.Colors {
+		if palette.Colors[i].Noise() > 0 {
+			return true
+		}
+	}
+	return false
+}
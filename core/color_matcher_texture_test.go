@@ -0,0 +1,41 @@
+package core
+
+import "testing"
+
+func TestTextureMatcher(t *testing.T) {
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:light_gray_concrete", RGB: [3]uint8{125, 125, 115}},
+		{ID: "minecraft:andesite", RGB: [3]uint8{136, 136, 136}, Noise: 0.08},
+	})
+	m := NewTextureMatcher(palette)
+	gray := [3]uint8{136, 136, 136}
+
+	if got := m.MatchNoise(gray, "", 0.08).Name; got != "minecraft:andesite" {
+		t.Errorf("noisy region matched %s, want andesite", got)
+	}
+	if got := m.MatchNoise(gray, "", 0).Name; got != "minecraft:light_gray_concrete" {
+		t.Errorf("smooth region matched %s, want concrete", got)
+	}
+	if got := m.Match(gray).Name; got != "minecraft:andesite" {
+		t.Errorf("Match = %s, want the closest color", got)
+	}
+}
+
+func TestRegionNoise(t *testing.T) {
+	vg := NewVoxelGrid(3, 3, 3)
+	for z := 0; z < 3; z++ {
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 3; x++ {
+				vg.SetVoxel(x, y, z, [3]uint8{100, 100, 100})
+			}
+		}
+	}
+	labs := make(map[[3]uint8]LABColor)
+	if got := regionNoise(vg, 1, 1, 1, labs); got > 1e-9 {
+		t.Errorf("uniform region noise = %v, want 0", got)
+	}
+	vg.SetVoxel(0, 0, 0, [3]uint8{250, 250, 250})
+	if got := regionNoise(vg, 1, 1, 1, labs); got <= 0 {
+		t.Errorf("mixed region noise = %v, want above 0", got)
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"io"
+)
+
+// ContextMeshImporter is implemented by importers that can be cancelled while parsing.
+type ContextMeshImporter interface {
+	MeshImporter
+
+	// ImportCtx reads and parses a mesh, stopping early if ctx is done.
+	ImportCtx(ctx context.Context, r io.Reader) (*Mesh, error)
+}
+
+// ContextVoxelizer is implemented by voxelizers that can be cancelled mid-run.
+type ContextVoxelizer interface {
+	Voxelizer
+
+	// VoxelizeCtx converts a mesh to a voxel grid, stopping early if ctx is done.
+	VoxelizeCtx(ctx context.Context, mesh *Mesh, config VoxelizationConfig) (*VoxelGrid, error)
+}
+
+// ContextGridExporter is implemented by exporters that can be cancelled mid-export.
+type ContextGridExporter interface {
+	GridExporter
+
+	// ExportCtx writes the voxel grid to w, stopping early if ctx is done.
+	ExportCtx(ctx context.Context, vg *VoxelGrid, w io.Writer) error
+}
+
+// ctxCheckInterval is how many loop iterations run between context checks in hot loops.
+const ctxCheckInterval = 1024
+
+// importMesh runs an importer, using ImportCtx when available.
+// Other importers read through a reader that fails once ctx is done.
+func importMesh(ctx context.Context, importer MeshImporter, r io.Reader) (*Mesh, error) {
+	if ci, ok := importer.(ContextMeshImporter); ok {
+		return ci.ImportCtx(ctx, r)
+	}
+	return importer.Import(&contextReader{ctx: ctx, r: r})
+}
+
+// voxelizeMesh runs a voxelizer, using VoxelizeCtx when available.
+func voxelizeMesh(ctx context.Context, voxelizer Voxelizer, mesh *Mesh, config VoxelizationConfig) (*VoxelGrid, error) {
+	if cv, ok := voxelizer.(ContextVoxelizer); ok {
+		return cv.VoxelizeCtx(ctx, mesh, config)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return voxelizer.Voxelize(mesh, config)
+}
+
+// exportGrid runs an exporter, using ExportCtx when available.
+// Other exporters write through a writer that fails once ctx is done.
+func exportGrid(ctx context.Context, exporter GridExporter, vg *VoxelGrid, w io.Writer) error {
+	if ce, ok := exporter.(ContextGridExporter); ok {
+		return ce.ExportCtx(ctx, vg, w)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return exporter.Export(vg, &contextWriter{ctx: ctx, w: w})
+}
+
+// contextReader wraps a reader so reads fail with ctx.Err() once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// contextWriter wraps a writer so writes fail with ctx.Err() once ctx is done.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
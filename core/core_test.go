@@ -1,7 +1,20 @@
 package core
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/Tnze/go-mc/nbt"
 )
 
 func TestRGBToLAB(t *testing.T) {
@@ -87,6 +100,206 @@ func TestPaletteGeneration(t *testing.T) {
 	}
 }
 
+func TestPaletteFilter(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{Name: "minecraft:stone"},
+		{Name: "minecraft:redstone_block"},
+		{Name: "minecraft:white_wool"},
+		{Name: "minecraft:red_wool"},
+		{Name: "mymod:stone"},
+	}}
+	
+	tests := []struct {
+		name   string
+		filter PaletteFilter
+		want   []string
+	}{
+		{"ExactBareID", PaletteFilter{Exclude: []string{"stone"}},
+			[]string{"minecraft:redstone_block", "minecraft:white_wool", "minecraft:red_wool"}},
+		{"ExactNamespacedID", PaletteFilter{Exclude: []string{"minecraft:stone"}},
+			[]string{"minecraft:redstone_block", "minecraft:white_wool", "minecraft:red_wool", "mymod:stone"}},
+		{"GlobInclude", PaletteFilter{Include: []string{"*_wool"}},
+			[]string{"minecraft:white_wool", "minecraft:red_wool"}},
+		{"IncludeAndExclude", PaletteFilter{Include: []string{"*_wool"}, Exclude: []string{"red_*"}},
+			[]string{"minecraft:white_wool"}},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, err := tt.filter.Apply(palette)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			var got []string
+			for _, color := range filtered.Colors {
+				got = append(got, color.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	
+	if _, err := (PaletteFilter{Include: []string{"glass"}}).Apply(palette); err == nil {
+		t.Error("Expected error when every block is removed")
+	}
+	if _, err := (PaletteFilter{Exclude: []string{"[wool"}}).Apply(palette); err == nil {
+		t.Error("Expected error for malformed pattern")
+	}
+}
+
+func TestPaletteFilterTagsAndProperties(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{Name: "minecraft:stone"},
+		{Name: "minecraft:white_wool"},
+		{Name: "minecraft:sand"},
+		{Name: "minecraft:bedrock"},
+		{Name: "minecraft:oak_log", Metadata: map[string]interface{}{"properties": map[string]string{"axis": "y"}}},
+		{Name: "minecraft:birch_log", Metadata: map[string]interface{}{"block_id": "minecraft:birch_log[axis=x]"}},
+		{Name: "mymod:glow_stone", Metadata: map[string]interface{}{"tags": []interface{}{"emissive"}}},
+	}}
+	
+	tests := []struct {
+		name   string
+		filter PaletteFilter
+		want   []string
+	}{
+		{"ExcludeTags", PaletteFilter{Exclude: []string{"#flammable", "#gravity"}},
+			[]string{"minecraft:stone", "minecraft:bedrock", "mymod:glow_stone"}},
+		{"SurvivalOnly", PaletteFilter{Include: []string{"minecraft:*"}, Exclude: []string{"*_log"}, SurvivalOnly: true},
+			[]string{"minecraft:stone", "minecraft:white_wool", "minecraft:sand"}},
+		{"MetadataTag", PaletteFilter{Include: []string{"#emissive"}},
+			[]string{"mymod:glow_stone"}},
+		{"ExcludeProperty", PaletteFilter{Include: []string{"*_log"}, Exclude: []string{"axis=x"}},
+			[]string{"minecraft:oak_log"}},
+		{"PropertyGlob", PaletteFilter{Include: []string{"axis=*"}},
+			[]string{"minecraft:oak_log", "minecraft:birch_log"}},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, err := tt.filter.Apply(palette)
+			if err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+			var got []string
+			for _, color := range filtered.Colors {
+				got = append(got, color.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	
+	if _, err := (PaletteFilter{Exclude: []string{"#flamable"}}).Apply(palette); err == nil {
+		t.Error("Expected error for unknown tag")
+	}
+	if (PaletteFilter{SurvivalOnly: true}).IsEmpty() {
+		t.Error("SurvivalOnly filter reported empty")
+	}
+}
+
+func TestPaletteFilterOpaqueOnly(t *testing.T) {
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:stone", RGB: [3]uint8{125, 125, 125}},
+		{ID: "minecraft:light_blue_stained_glass", RGB: [3]uint8{102, 153, 216}},
+		{ID: "mymod:frosted_panel", RGB: [3]uint8{220, 230, 240}, Translucency: 0.4},
+	})
+	
+	filtered, err := PaletteFilter{OpaqueOnly: true}.Apply(palette)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(filtered.Colors) != 1 || filtered.Colors[0].Name != "minecraft:stone" {
+		t.Errorf("OpaqueOnly kept %v, want only stone", filtered.Colors)
+	}
+	if got := palette.Colors[2].Translucency(); got != 0.4 {
+		t.Errorf("Translucency() = %v, want 0.4", got)
+	}
+	
+	// The translucency survives a msgpack round trip
+	var buf bytes.Buffer
+	if err := ExportPalette(palette, &buf); err != nil {
+		t.Fatalf("ExportPalette failed: %v", err)
+	}
+	imported, err := ImportPalette(&buf)
+	if err != nil {
+		t.Fatalf("ImportPalette failed: %v", err)
+	}
+	if got := imported.Colors[2].Translucency(); got != 0.4 {
+		t.Errorf("imported Translucency() = %v, want 0.4", got)
+	}
+}
+
+func TestPaletteTextFormats(t *testing.T) {
+	grass := [3]uint8{95, 159, 53}
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:grass_block", RGB: [3]uint8{134, 96, 67}, Faces: map[BlockFace][3]uint8{FaceTop: grass}},
+		{ID: "minecraft:oak_slab", RGB: [3]uint8{162, 130, 78}, Properties: map[string]string{"type": "top"}},
+	})
+	palette.Colors[1].Metadata["tags"] = []string{"wooden", "flammable"}
+	
+	// JSON keeps everything, including face colors
+	var buf bytes.Buffer
+	if err := ExportPaletteJSON(palette, &buf); err != nil {
+		t.Fatalf("ExportPaletteJSON failed: %v", err)
+	}
+	imported, err := ImportPaletteJSON(&buf)
+	if err != nil {
+		t.Fatalf("ImportPaletteJSON failed: %v", err)
+	}
+	if len(imported.Colors) != 2 || imported.Colors[1].LAB != palette.Colors[1].LAB {
+		t.Fatalf("JSON round trip = %+v", imported.Colors)
+	}
+	if got := imported.Colors[0].FaceRGB(FaceTop); got != grass {
+		t.Errorf("FaceRGB(top) after JSON round trip = %v, want %v", got, grass)
+	}
+	if got := paletteBlockID(&imported.Colors[1]); got != "minecraft:oak_slab[type=top]" {
+		t.Errorf("JSON block ID = %q", got)
+	}
+	edited := `{"colors": [{"name": "a", "rgb": "#ff8000"}, {"name": "b", "rgb": [1, 2, 3]}]}`
+	imported, err = ImportPaletteJSON(strings.NewReader(edited))
+	if err != nil {
+		t.Fatalf("ImportPaletteJSON failed on hand-written JSON: %v", err)
+	}
+	if imported.Colors[0].RGB != [3]uint8{255, 128, 0} || imported.Colors[1].RGB != [3]uint8{1, 2, 3} {
+		t.Errorf("hand-written colors = %v, %v", imported.Colors[0].RGB, imported.Colors[1].RGB)
+	}
+	if _, err := ImportPaletteJSON(strings.NewReader(`{"colors": [{"rgb": "orange"}]}`)); err == nil {
+		t.Error("ImportPaletteJSON accepted a color name")
+	}
+	
+	// CSV keeps block states and tags
+	buf.Reset()
+	if err := ExportPaletteCSV(palette, &buf); err != nil {
+		t.Fatalf("ExportPaletteCSV failed: %v", err)
+	}
+	want := "block_id,red,green,blue,tags\n" +
+		"minecraft:grass_block,134,96,67,\n" +
+		"minecraft:oak_slab[type=top],162,130,78,wooden flammable\n"
+	if buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+	imported, err = ImportPaletteCSV(&buf)
+	if err != nil {
+		t.Fatalf("ImportPaletteCSV failed: %v", err)
+	}
+	if len(imported.Colors) != 2 || imported.Colors[1].RGB != palette.Colors[1].RGB || imported.Colors[1].LAB != palette.Colors[1].LAB {
+		t.Fatalf("CSV round trip = %+v", imported.Colors)
+	}
+	if !slices.Contains(metadataTags(&imported.Colors[1]), "flammable") || paletteBlockID(&imported.Colors[1]) != "minecraft:oak_slab[type=top]" {
+		t.Errorf("CSV entry lost its tags or state: %+v", imported.Colors[1])
+	}
+	
+	for _, bad := range []string{"id,r,g,b\n", "block_id,red,green,blue\nstone,1,2\n", "block_id,red,green,blue\nstone,1,2,300\n"} {
+		var formatErr *FormatError
+		if _, err := ImportPaletteCSV(strings.NewReader(bad)); !errors.As(err, &formatErr) {
+			t.Errorf("ImportPaletteCSV(%q): expected FormatError, got %v", bad, err)
+		}
+	}
+}
+
 func TestCIELABMatcher(t *testing.T) {
 	blocks := GetVanillaMinecraftBlocks()
 	palette := GenerateMinecraftPalette(blocks)
@@ -138,6 +351,203 @@ func TestVoxelGrid(t *testing.T) {
 	}
 }
 
+func TestVoxelStores(t *testing.T) {
+	tests := []struct {
+		name  string
+		store VoxelStore
+	}{
+		{"sparse", newSparseStore()},
+		{"dense", newDenseStore(5, 4, 3)},
+		{"octree", newOctreeStore(5, 4, 3)},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vg := NewVoxelGridWithStore(5, 4, 3, tt.store)
+			vg.SetVoxel(0, 0, 0, [3]uint8{1, 2, 3})
+			vg.SetVoxel(4, 3, 2, [3]uint8{4, 5, 6})
+			vg.SetVoxel(4, 3, 2, [3]uint8{7, 8, 9}) // overwrite
+			vg.SetVoxel(2, 1, 1, [3]uint8{10, 11, 12})
+			vg.SetVoxel(5, 0, 0, [3]uint8{1, 1, 1})  // out of bounds
+			vg.DeleteVoxel(2, 1, 1)
+			
+			if vg.Count() != 2 {
+				t.Fatalf("Count = %d, want 2", vg.Count())
+			}
+			if color, ok := vg.ColorAt(4, 3, 2); !ok || color != [3]uint8{7, 8, 9} {
+				t.Errorf("ColorAt(4,3,2) = %v, %v", color, ok)
+			}
+			if vg.HasVoxel(2, 1, 1) || vg.HasVoxel(-1, 0, 0) {
+				t.Error("Deleted or out-of-bounds cell reported as filled")
+			}
+			
+			seen := map[[3]int][3]uint8{}
+			vg.Range(func(x, y, z int, color [3]uint8) bool {
+				seen[[3]int{x, y, z}] = color
+				return true
+			})
+			want := map[[3]int][3]uint8{{0, 0, 0}: {1, 2, 3}, {4, 3, 2}: {7, 8, 9}}
+			if len(seen) != len(want) || seen[[3]int{0, 0, 0}] != want[[3]int{0, 0, 0}] || seen[[3]int{4, 3, 2}] != want[[3]int{4, 3, 2}] {
+				t.Errorf("Range visited %v, want %v", seen, want)
+			}
+		})
+	}
+}
+
+func TestReproducibleExports(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{160, 120, 70}, Metadata: map[string]interface{}{"block_id": "minecraft:oak_stairs[facing=east,half=top,shape=straight,waterlogged=false]"}},
+		{RGB: [3]uint8{120, 120, 120}, Metadata: map[string]interface{}{"block_id": "minecraft:stone"}},
+	}}
+	// The same voxels, inserted into sparse grids in different orders
+	var cells [][3]int
+	for i := 0; i < 200; i++ {
+		cells = append(cells, [3]int{i % 7, i / 7 % 5, i / 35})
+	}
+	grid := func(seed int64) *VoxelGrid {
+		vg := NewVoxelGridWithStore(7, 5, 6, newSparseStore())
+		rng := rand.New(rand.NewSource(seed))
+		for _, i := range rng.Perm(len(cells)) {
+			c := cells[i]
+			vg.SetVoxel(c[0], c[1], c[2], [3]uint8{uint8(i), 120, uint8(i % 2 * 120)})
+		}
+		return vg
+	}
+	exports := map[string]func(vg *VoxelGrid, w io.Writer) error{
+		"vox": func(vg *VoxelGrid, w io.Writer) error { return NewVOXExporter().Export(vg, w) },
+		"structure": func(vg *VoxelGrid, w io.Writer) error {
+			return NewStructureExporter().Export(vg, palette, w)
+		},
+		"mcedit": func(vg *VoxelGrid, w io.Writer) error { return NewMCEditExporter().Export(vg, palette, w) },
+	}
+	for name, export := range exports {
+		var first []byte
+		for seed := int64(0); seed < 5; seed++ {
+			var buf bytes.Buffer
+			if err := export(grid(seed), &buf); err != nil {
+				t.Fatalf("%s: export: %v", name, err)
+			}
+			if seed == 0 {
+				first = buf.Bytes()
+			} else if !bytes.Equal(buf.Bytes(), first) {
+				t.Errorf("%s: export %d differs from the first", name, seed)
+			}
+		}
+	}
+}
+
+func TestStorageSelection(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		expected  int64
+		config    StorageConfig
+		wantStore string
+	}{
+		{"small grid stays sparse", 16, 4096, StorageConfig{}, "*core.sparseStore"},
+		{"sparse fill", 256, 1000, StorageConfig{}, "*core.sparseStore"},
+		{"dense fill", 256, 1 << 20, StorageConfig{}, "*core.denseStore"},
+		{"over budget", 256, 1 << 20, StorageConfig{MemoryBudget: 1 << 20}, "*core.sparseStore"},
+		{"forced dense", 16, 0, StorageConfig{Mode: StorageDense}, "*core.denseStore"},
+		{"forced sparse", 256, 1 << 24, StorageConfig{Mode: StorageSparse}, "*core.sparseStore"},
+		{"forced octree", 16, 0, StorageConfig{Mode: StorageOctree}, "*core.octreeStore"},
+		{"huge surface", 4096, 1 << 25, StorageConfig{}, "*core.octreeStore"},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vg := NewVoxelGridFor(tt.size, tt.size, tt.size, tt.expected, tt.config)
+			if got := fmt.Sprintf("%T", vg.Store()); got != tt.wantStore {
+				t.Errorf("got %s, want %s", got, tt.wantStore)
+			}
+		})
+	}
+}
+
+func TestOctreeStore(t *testing.T) {
+	// Matches a sparse grid under random edits, across brick and node boundaries
+	const size = 70
+	octree := NewVoxelGridWithStore(size, size/2, size, newOctreeStore(size, size/2, size))
+	sparse := NewVoxelGrid(size, size/2, size)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		x, y, z := rng.Intn(size), rng.Intn(size/2), rng.Intn(size)
+		if rng.Intn(4) == 0 {
+			octree.DeleteVoxel(x, y, z)
+			sparse.DeleteVoxel(x, y, z)
+			continue
+		}
+		color := [3]uint8{uint8(x), uint8(y), uint8(z)}
+		octree.SetVoxel(x, y, z, color)
+		sparse.SetVoxel(x, y, z, color)
+	}
+	
+	if octree.Count() != sparse.Count() {
+		t.Fatalf("Count = %d, want %d", octree.Count(), sparse.Count())
+	}
+	visited := 0
+	octree.Range(func(x, y, z int, color [3]uint8) bool {
+		visited++
+		if want, ok := sparse.ColorAt(x, y, z); !ok || want != color {
+			t.Errorf("Range gave (%d,%d,%d) = %v, sparse has %v, %v", x, y, z, color, want, ok)
+		}
+		return true
+	})
+	if visited != sparse.Count() {
+		t.Errorf("Range visited %d voxels, want %d", visited, sparse.Count())
+	}
+	
+	// Round trip through a flat copy
+	flat := octree.WithStorage(StorageConfig{Mode: StorageDense})
+	back := flat.WithStorage(StorageConfig{Mode: StorageOctree})
+	if _, ok := back.Store().(*octreeStore); !ok || back.Count() != octree.Count() {
+		t.Fatalf("round trip gave %T with %d voxels, want an octree with %d", back.Store(), back.Count(), octree.Count())
+	}
+	sparse.Range(func(x, y, z int, color [3]uint8) bool {
+		if got, ok := back.ColorAt(x, y, z); !ok || got != color {
+			t.Errorf("round trip lost (%d,%d,%d)", x, y, z)
+			return false
+		}
+		return true
+	})
+	if _, ok := octree.emptyLike().Store().(*octreeStore); !ok {
+		t.Error("emptyLike of an octree grid is not an octree")
+	}
+}
+
+func TestVoxelizeOctree(t *testing.T) {
+	want, err := NewSurfaceVoxelizer().Voxelize(newTriangleMesh(), VoxelizationConfig{Resolution: 64})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	got, err := NewSurfaceVoxelizer().Voxelize(newTriangleMesh(), VoxelizationConfig{
+		Resolution: 64,
+		Storage:    StorageConfig{Mode: StorageOctree},
+	})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	if _, ok := got.Store().(*octreeStore); !ok {
+		t.Fatalf("grid store is %T, want an octree", got.Store())
+	}
+	if got.Count() != want.Count() {
+		t.Errorf("octree grid has %d voxels, want %d", got.Count(), want.Count())
+	}
+}
+
+func TestStorageModeText(t *testing.T) {
+	for _, name := range StorageModes() {
+		var mode StorageMode
+		if err := mode.UnmarshalText([]byte(name)); err != nil || mode.String() != name {
+			t.Errorf("%q parsed as %v, %v", name, mode, err)
+		}
+	}
+	var mode StorageMode
+	if err := mode.UnmarshalText([]byte("quadtree")); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
 func TestMeshBounds(t *testing.T) {
 	mesh := &Mesh{
 		Vertices: []Vertex{
@@ -158,3 +568,848 @@ func TestMeshBounds(t *testing.T) {
 		t.Errorf("Bounds mismatch: expected %v, got %v", expected, mesh.Bounds)
 	}
 }
+
+func TestVoxelizeCellLimit(t *testing.T) {
+	mesh := newTriangleMesh()
+	
+	config := VoxelizationConfig{Resolution: 64, MaxCells: 1000}
+	if _, err := NewSurfaceVoxelizer().Voxelize(mesh, config); !errors.Is(err, ErrGridTooLarge) {
+		t.Fatalf("Expected ErrGridTooLarge, got %v", err)
+	}
+	
+	config.MaxCells = 64 * 64 * 64
+	if _, err := NewSurfaceVoxelizer().Voxelize(mesh, config); err != nil {
+		t.Fatalf("Unexpected error within cell limit: %v", err)
+	}
+}
+
+func TestVoxelizeTargetSize(t *testing.T) {
+	// 3 wide, 10 tall and 1 deep
+	mesh := &Mesh{
+		Vertices: []Vertex{
+			{Position: [3]float64{0, 0, 0}},
+			{Position: [3]float64{3, 0, 0}},
+			{Position: [3]float64{0, 10, 1}},
+		},
+		Faces: []Face{{VertexIndices: []int{0, 1, 2}, MaterialIndex: -1}},
+	}
+	
+	tests := []struct {
+		target [3]int
+		want   [3]int
+	}{
+		{[3]int{30, 0, 0}, [3]int{30, 100, 10}}, // Exact width; height is free
+		{[3]int{30, 50, 0}, [3]int{15, 50, 5}},  // The height cap binds
+		{[3]int{0, 0, 7}, [3]int{21, 70, 7}},    // Resolution is ignored
+		{[3]int{0, 0, 0}, [3]int{10, 32, 4}},    // Back to Resolution
+	}
+	for _, tt := range tests {
+		vg, err := NewSurfaceVoxelizer().Voxelize(mesh, VoxelizationConfig{Resolution: 32, TargetSize: tt.target})
+		if err != nil {
+			t.Fatalf("target %v: %v", tt.target, err)
+		}
+		if got := [3]int{vg.SizeX, vg.SizeY, vg.SizeZ}; got != tt.want {
+			t.Errorf("target %v: grid %v, want %v", tt.target, got, tt.want)
+		}
+	}
+	
+	if _, err := NewPipeline(WithVoxelization(VoxelizationConfig{TargetSize: [3]int{100, 255, 0}})); err != nil {
+		t.Errorf("target size without a resolution rejected: %v", err)
+	}
+	if _, err := NewPipeline(WithVoxelization(VoxelizationConfig{TargetSize: [3]int{100, -1, 0}})); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for a negative size, got %v", err)
+	}
+}
+
+func TestTriangleIntersectsBox(t *testing.T) {
+	// A triangle in the plane x = 3.5, parallel to both Y and Z
+	a, b, c := [3]float64{3.5, 0, 0}, [3]float64{3.5, 4, 0}, [3]float64{3.5, 0, 4}
+	
+	tests := []struct {
+		center [3]float64
+		half   float64
+		want   bool
+	}{
+		{[3]float64{3.5, 0.5, 0.5}, 0.5, true},
+		{[3]float64{3.5, 1.5, 2.5}, 0.5, true},  // Partly below the hypotenuse y + z = 4
+		{[3]float64{3.5, 3.5, 3.5}, 0.5, false}, // Beyond the hypotenuse y + z = 4
+		{[3]float64{4.5, 0.5, 0.5}, 0.5, false}, // Off the plane
+		{[3]float64{4.5, 0.5, 0.5}, 1.0, true},  // Reached by a larger box
+		{[3]float64{3.0, 4.5, 0.5}, 0.5, true},  // Touches the vertex (3.5, 4, 0)
+		{[3]float64{3.5, 2.6, 2.6}, 0.5, false}, // Only the box's bounds overlap the triangle's
+	}
+	for _, tt := range tests {
+		if got := triangleIntersectsBox(tt.center, tt.half, a, b, c); got != tt.want {
+			t.Errorf("box at %v (half %g) = %v, want %v", tt.center, tt.half, got, tt.want)
+		}
+		// Without an offset the prism is the triangle itself
+		if got := prismIntersectsBox(tt.center, tt.half, a, b, c, [3]float64{}); got != tt.want {
+			t.Errorf("prism box at %v (half %g) = %v, want %v", tt.center, tt.half, got, tt.want)
+		}
+	}
+	
+	// Swept a voxel to either side, the triangle reaches the box off its plane
+	if !prismIntersectsBox([3]float64{4.5, 0.5, 0.5}, 0.5, a, b, c, [3]float64{1, 0, 0}) {
+		t.Error("swept triangle missed the box beside it")
+	}
+	if prismIntersectsBox([3]float64{3.5, 3.5, 3.5}, 0.5, a, b, c, [3]float64{1, 0, 0}) {
+		t.Error("swept triangle hit the box beyond its hypotenuse")
+	}
+}
+
+func TestVoxelizeCubeShell(t *testing.T) {
+	mesh := &Mesh{}
+	for i := 0; i < 8; i++ {
+		mesh.Vertices = append(mesh.Vertices, Vertex{Position: [3]float64{float64(i & 1), float64(i >> 1 & 1), float64(i >> 2 & 1)}})
+	}
+	for _, quad := range [][4]int{{0, 2, 3, 1}, {4, 5, 7, 6}, {0, 1, 5, 4}, {2, 6, 7, 3}, {0, 4, 6, 2}, {1, 3, 7, 5}} {
+		mesh.Faces = append(mesh.Faces,
+			Face{VertexIndices: []int{quad[0], quad[1], quad[2]}, MaterialIndex: -1},
+			Face{VertexIndices: []int{quad[0], quad[2], quad[3]}, MaterialIndex: -1})
+	}
+	
+	for _, conservative := range []bool{false, true} {
+		vg, err := NewSurfaceVoxelizer().Voxelize(mesh, VoxelizationConfig{Resolution: 8, Conservative: conservative})
+		if err != nil {
+			t.Fatalf("Voxelize failed: %v", err)
+		}
+		// Every face of the shell and nothing inside it
+		if got, want := vg.Count(), 8*8*8-6*6*6; got != want {
+			t.Errorf("conservative %v: %d voxels, want %d", conservative, got, want)
+		}
+		if vg.HasVoxel(3, 4, 4) {
+			t.Errorf("conservative %v: interior voxel set", conservative)
+		}
+	}
+}
+
+func TestVoxelizeMinThickness(t *testing.T) {
+	// A flat square, then one tilted 45 degrees about z
+	flat := &Mesh{Vertices: []Vertex{
+		{Position: [3]float64{0, 0, 0}}, {Position: [3]float64{8, 0, 0}},
+		{Position: [3]float64{8, 0, 8}}, {Position: [3]float64{0, 0, 8}},
+	}}
+	flat.Faces = []Face{
+		{VertexIndices: []int{0, 1, 2}, MaterialIndex: -1},
+		{VertexIndices: []int{0, 2, 3}, MaterialIndex: -1},
+	}
+	vg, err := NewSurfaceVoxelizer().Voxelize(flat, VoxelizationConfig{Resolution: 8, MinThickness: 3})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	// The grid grows to hold the sheet three voxels thick
+	if vg.SizeX != 8 || vg.SizeY != 3 || vg.SizeZ != 8 || vg.Count() != 8*3*8 {
+		t.Errorf("flat sheet: %dx%dx%d grid with %d voxels, want 8x3x8 full", vg.SizeX, vg.SizeY, vg.SizeZ, vg.Count())
+	}
+	
+	tilted := &Mesh{Vertices: []Vertex{
+		{Position: [3]float64{0, 0, 0}}, {Position: [3]float64{8, 8, 0}},
+		{Position: [3]float64{8, 8, 8}}, {Position: [3]float64{0, 0, 8}},
+	}, Faces: flat.Faces}
+	for _, thickness := range []int{0, 3} {
+		vg, err := NewSurfaceVoxelizer().Voxelize(tilted, VoxelizationConfig{Resolution: 8, MinThickness: thickness})
+		if err != nil {
+			t.Fatalf("Voxelize failed: %v", err)
+		}
+		// Rows along x, away from the sheet's edges, cross it at 45 degrees and so
+		// at least sqrt(2) times as many voxels as it is thick
+		for y := 2; y < 6; y++ {
+			row := 0
+			for x := 0; x < vg.SizeX; x++ {
+				if vg.HasVoxel(x, y, 4) {
+					row++
+				}
+			}
+			if float64(row) < float64(thickness)*math.Sqrt2 {
+				t.Errorf("thickness %d: row y=%d crosses %d voxels", thickness, y, row)
+			}
+		}
+	}
+	
+	if _, err := NewPipeline(WithVoxelization(VoxelizationConfig{Resolution: 8, MinThickness: -1})); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for a negative thickness, got %v", err)
+	}
+}
+
+func TestParallelVoxelization(t *testing.T) {
+	// Overlapping flat triangles in the z=0.5 plane, so later faces overwrite earlier
+	// ones and the merge order matters
+	r := rand.New(rand.NewSource(1))
+	mesh := &Mesh{
+		Vertices: []Vertex{{Position: [3]float64{0, 0, 0}}, {Position: [3]float64{32, 32, 32}}},
+	}
+	for i := 0; i < 8; i++ {
+		mesh.Materials = append(mesh.Materials, Material{DiffuseColor: [3]float64{float64(i) / 8, 0.5, 1 - float64(i)/8}})
+	}
+	for i := 0; i < 3*minFacesPerWorker; i++ {
+		base := len(mesh.Vertices)
+		x, y := r.Float64()*28, r.Float64()*28
+		mesh.Vertices = append(mesh.Vertices,
+			Vertex{Position: [3]float64{x, y, 0.5}},
+			Vertex{Position: [3]float64{x + 4, y, 0.5}},
+			Vertex{Position: [3]float64{x, y + 4, 0.5}})
+		mesh.Faces = append(mesh.Faces, Face{VertexIndices: []int{base, base + 1, base + 2}, MaterialIndex: i % 8})
+	}
+	mesh.CalculateBounds()
+	
+	config := VoxelizationConfig{Scale: 1, Workers: 1}
+	sequential, err := NewSurfaceVoxelizer().Voxelize(mesh, config)
+	if err != nil {
+		t.Fatalf("sequential: %v", err)
+	}
+	config.Workers = 4
+	parallel, err := NewSurfaceVoxelizer().Voxelize(mesh, config)
+	if err != nil {
+		t.Fatalf("parallel: %v", err)
+	}
+	
+	if sequential.Count() == 0 || parallel.Count() != sequential.Count() {
+		t.Fatalf("parallel filled %d voxels, sequential %d", parallel.Count(), sequential.Count())
+	}
+	sequential.Range(func(x, y, z int, color [3]uint8) bool {
+		if c, _ := parallel.ColorAt(x, y, z); c != color {
+			t.Errorf("voxel (%d,%d,%d): parallel %v, sequential %v", x, y, z, c, color)
+			return false
+		}
+		return true
+	})
+}
+
+func TestSupersampledColors(t *testing.T) {
+	// A red and a blue rectangle in the z=0.5 plane meeting at x=2.5, halfway
+	// through the voxels of column 2
+	mesh := &Mesh{
+		Vertices: []Vertex{{Position: [3]float64{0, 0, 0}}, {Position: [3]float64{4, 4, 4}}},
+		Materials: []Material{{DiffuseColor: [3]float64{1, 0, 0}}, {DiffuseColor: [3]float64{0, 0, 1}}},
+	}
+	for i, x := range [][2]float64{{0, 2.5}, {2.5, 4}} {
+		base := len(mesh.Vertices)
+		mesh.Vertices = append(mesh.Vertices,
+			Vertex{Position: [3]float64{x[0], 0, 0.5}},
+			Vertex{Position: [3]float64{x[1], 0, 0.5}},
+			Vertex{Position: [3]float64{x[1], 4, 0.5}},
+			Vertex{Position: [3]float64{x[0], 4, 0.5}})
+		mesh.Faces = append(mesh.Faces,
+			Face{VertexIndices: []int{base, base + 1, base + 2}, MaterialIndex: i},
+			Face{VertexIndices: []int{base, base + 2, base + 3}, MaterialIndex: i})
+	}
+	mesh.CalculateBounds()
+	
+	single, err := NewSurfaceVoxelizer().Voxelize(mesh, VoxelizationConfig{Scale: 1})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	if c, _ := single.ColorAt(2, 1, 0); c != [3]uint8{0, 0, 255} {
+		t.Errorf("single sample: boundary voxel = %v, want the last triangle's blue", c)
+	}
+	
+	sampled, err := NewSurfaceVoxelizer().Voxelize(mesh, VoxelizationConfig{Scale: 1, Samples: 16})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	if sampled.Count() != single.Count() {
+		t.Errorf("supersampling filled %d voxels, want %d", sampled.Count(), single.Count())
+	}
+	if c, _ := sampled.ColorAt(2, 1, 0); c[0] < 96 || c[0] > 160 || c[2] < 96 || c[2] > 160 {
+		t.Errorf("boundary voxel = %v, want an even red and blue blend", c)
+	}
+	if c, _ := sampled.ColorAt(0, 1, 0); c != [3]uint8{255, 0, 0} {
+		t.Errorf("red voxel = %v", c)
+	}
+	
+	if _, err := NewPipeline(WithVoxelization(VoxelizationConfig{Resolution: 8, Samples: -1})); err == nil {
+		t.Error("NewPipeline accepted negative samples")
+	}
+}
+
+func TestVoxelMaterials(t *testing.T) {
+	// A plain red triangle and an emissive blue one
+	mesh := &Mesh{
+		Vertices: []Vertex{
+			{Position: [3]float64{0, 0, 0.5}}, {Position: [3]float64{4, 0, 0.5}}, {Position: [3]float64{0, 4, 0.5}},
+			{Position: [3]float64{0, 0, 3.5}}, {Position: [3]float64{4, 0, 3.5}}, {Position: [3]float64{0, 4, 3.5}},
+		},
+		Faces: []Face{
+			{VertexIndices: []int{0, 1, 2}, MaterialIndex: 0},
+			{VertexIndices: []int{3, 4, 5}, MaterialIndex: 1},
+		},
+		Materials: []Material{
+			{DiffuseColor: [3]float64{1, 0, 0}, Opacity: 1},
+			{DiffuseColor: [3]float64{0, 0, 1}, EmissiveColor: [3]float64{0, 0, 0.8}, Opacity: 1},
+		},
+	}
+	mesh.CalculateBounds()
+	
+	for _, samples := range []int{1, 4} {
+		vg, err := NewSurfaceVoxelizer().Voxelize(mesh, VoxelizationConfig{Scale: 1, Samples: samples})
+		if err != nil {
+			t.Fatalf("Voxelize failed: %v", err)
+		}
+		if len(vg.Materials) != 1 || vg.Materials[[3]uint8{0, 0, 255}].Emission != 0.8 {
+			t.Errorf("samples %d: materials = %v, want blue emitting 0.8", samples, vg.Materials)
+		}
+	}
+}
+
+func TestLightBlocks(t *testing.T) {
+	entry := func(name string, rgb [3]uint8) PaletteColor {
+		return PaletteColor{Name: name, RGB: rgb, LAB: RGBToLAB(rgb), Metadata: map[string]interface{}{"block_id": name}}
+	}
+	palette := &Palette{Colors: []PaletteColor{
+		entry("minecraft:orange_wool", [3]uint8{240, 120, 20}),
+		entry("minecraft:glowstone", [3]uint8{170, 130, 80}),
+	}}
+	lamp, wall := [3]uint8{245, 125, 25}, [3]uint8{235, 115, 15}
+	vg := NewVoxelGrid(2, 1, 1)
+	vg.SetVoxel(0, 0, 0, lamp)
+	vg.SetVoxel(1, 0, 0, wall)
+	vg.setMaterial(lamp, VoxelMaterial{Emission: 1})
+	
+	for _, enabled := range []bool{false, true} {
+		p, err := NewPipeline(WithPalette(palette), WithLightBlocks(enabled))
+		if err != nil {
+			t.Fatalf("NewPipeline failed: %v", err)
+		}
+		result, err := p.MatchColorsCtx(context.Background(), vg, p.Config)
+		if err != nil {
+			t.Fatalf("MatchColorsCtx failed: %v", err)
+		}
+		want := palette.Colors[0].RGB
+		if enabled {
+			want = palette.Colors[1].RGB
+		}
+		if c, _ := result.ColorAt(0, 0, 0); c != want {
+			t.Errorf("light blocks %v: emissive voxel = %v, want %v", enabled, c, want)
+		}
+		if c, _ := result.ColorAt(1, 0, 0); c != palette.Colors[0].RGB {
+			t.Errorf("light blocks %v: plain voxel = %v, want orange wool", enabled, c)
+		}
+	}
+}
+
+func TestPipelineCancellation(t *testing.T) {
+	mesh := newTriangleMesh()
+	
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	
+	_, err := NewSurfaceVoxelizer().VoxelizeCtx(ctx, mesh, VoxelizationConfig{Resolution: 16})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled from voxelizer, got %v", err)
+	}
+	
+	pipeline, err := NewPipeline()
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	_, err = pipeline.MeshToVoxelGridCtx(ctx, strings.NewReader("{}"), pipeline.Config)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled from pipeline, got %v", err)
+	}
+	
+	vg := NewVoxelGrid(2, 2, 2)
+	vg.SetVoxel(0, 0, 0, [3]uint8{255, 0, 0})
+	for _, exporter := range []GridExporter{NewVOXExporter(), NewOBJExporter()} {
+		if err := exportGrid(ctx, exporter, vg, io.Discard); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled from %T, got %v", exporter, err)
+		}
+	}
+	if err := NewWorldExporter([3]int{}).ExportCtx(ctx, vg, nil, t.TempDir()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from world exporter, got %v", err)
+	}
+}
+
+func TestVoxelizeFrames(t *testing.T) {
+	// The second frame is the triangle moved one unit along x
+	moved := newTriangleMesh()
+	for i := range moved.Vertices {
+		moved.Vertices[i].Position[0]++
+	}
+	pipeline, err := NewPipeline(WithVoxelization(VoxelizationConfig{Resolution: 16}))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	grids, err := pipeline.VoxelizeFramesCtx(context.Background(), []*Mesh{newTriangleMesh(), moved}, pipeline.Config)
+	if err != nil {
+		t.Fatalf("VoxelizeFramesCtx failed: %v", err)
+	}
+	first, second := grids[0], grids[1]
+	if first.SizeX != second.SizeX || first.SizeY != second.SizeY || first.SizeZ != second.SizeZ ||
+		first.Origin != second.Origin || first.Scale != second.Scale {
+		t.Fatalf("frames differ: %dx%dx%d at %v and %dx%dx%d at %v",
+			first.SizeX, first.SizeY, first.SizeZ, first.Origin, second.SizeX, second.SizeY, second.SizeZ, second.Origin)
+	}
+	if first.SizeX < 16 || !first.HasVoxel(0, 0, 0) || second.HasVoxel(0, 0, 0) {
+		t.Errorf("frames are not placed in shared bounds")
+	}
+}
+
+func TestPipelineProgress(t *testing.T) {
+	mesh := newTriangleMesh()
+	
+	started := map[string]bool{}
+	finished := map[string]bool{}
+	reporter := ProgressFunc(func(e ProgressEvent) {
+		switch e.Type {
+		case StageStarted:
+			started[e.Stage] = true
+		case StageFinished:
+			finished[e.Stage] = true
+			if e.Total > 0 && e.Percent() != 100 {
+				t.Errorf("Stage %s finished at %.1f%%", e.Stage, e.Percent())
+			}
+		}
+	})
+	
+	vg, err := NewSurfaceVoxelizer().Voxelize(mesh, VoxelizationConfig{Resolution: 16, Progress: reporter})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	
+	palette := GenerateMinecraftPalette(GetVanillaMinecraftBlocks())
+	pipeline, err := NewPipeline(WithPalette(palette), WithProgress(reporter))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	if err := pipeline.ExportGrid(vg, io.Discard); err != nil {
+		t.Fatalf("ExportGrid failed: %v", err)
+	}
+	
+	for _, stage := range []string{StageVoxelize, StageMatch, StageExport} {
+		if !started[stage] || !finished[stage] {
+			t.Errorf("Stage %s: started=%v finished=%v", stage, started[stage], finished[stage])
+		}
+	}
+}
+
+func TestCIELABMatcherWeights(t *testing.T) {
+	target := [3]uint8{119, 119, 119}
+	lab := RGBToLAB(target)
+	palette := &Palette{Colors: []PaletteColor{
+		{Name: "lighter", LAB: LABColor{L: lab.L + 0.06}},
+		{Name: "tinted", LAB: LABColor{L: lab.L, A: 0.1}},
+	}}
+	
+	matcher := NewCIELABMatcher(palette)
+	if got := matcher.Match(target).Name; got != "lighter" {
+		t.Errorf("unweighted match = %s, want lighter", got)
+	}
+	matcher.SetWeights(MatchWeights{Lightness: 3})
+	if got := matcher.Match(target).Name; got != "tinted" {
+		t.Errorf("lightness-weighted match = %s, want tinted", got)
+	}
+	matcher.SetWeights(MatchWeights{Lightness: 1, Color: 3})
+	if got := matcher.Match(target).Name; got != "lighter" {
+		t.Errorf("color-weighted match = %s, want lighter", got)
+	}
+}
+
+func TestNewPipeline(t *testing.T) {
+	palette := GenerateMinecraftPalette(GetVanillaMinecraftBlocks())
+	
+	tests := []struct {
+		name    string
+		opts    []PipelineOption
+		wantErr bool
+	}{
+		{"defaults", nil, false},
+		{"palette and dithering", []PipelineOption{WithPalette(palette), WithDithering(DitherConfig{Enabled: true})}, false},
+		{"dithering without palette", []PipelineOption{WithDithering(DitherConfig{Enabled: true})}, true},
+		{"unknown dither algorithm", []PipelineOption{WithPalette(palette), WithDithering(DitherConfig{Algorithm: "bogus"})}, true},
+		{"zero resolution", []PipelineOption{WithVoxelization(VoxelizationConfig{})}, true},
+		{"empty palette", []PipelineOption{WithPalette(&Palette{})}, true},
+		{"unknown voxelizer", []PipelineOption{WithVoxelizerName("missing")}, true},
+		{"unknown input extension", []PipelineOption{WithInputFile("model.abc")}, true},
+		{"output extension", []PipelineOption{WithOutputFile("out.vox")}, false},
+		{"match weights", []PipelineOption{WithPalette(palette), WithMatchWeights(MatchWeights{Lightness: 2})}, false},
+		{"negative match weight", []PipelineOption{WithPalette(palette), WithMatchWeights(MatchWeights{Color: -1})}, true},
+		{"animation", []PipelineOption{WithAnimation(0, 1.5)}, false},
+		{"animation of a still format", []PipelineOption{WithFormat("obj"), WithAnimation(0, 0)}, true},
+		{"ambient light above 1", []PipelineOption{WithVoxelization(VoxelizationConfig{Resolution: 64, Lighting: LightingConfig{Enabled: true, Ambient: 2}})}, true},
+		{"ambient occlusion above 1", []PipelineOption{WithAmbientOcclusion(2)}, true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPipeline(tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (p.Importer == nil || p.Voxelizer == nil || p.Exporter == nil) {
+				t.Errorf("NewPipeline() left components unset: %+v", p)
+			}
+		})
+	}
+}
+
+func TestNewPipelineDefaults(t *testing.T) {
+	p, err := NewPipeline()
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	if p.Matcher != nil {
+		t.Errorf("Expected no matcher without a palette, got %T", p.Matcher)
+	}
+	if _, ok := p.Exporter.(*VOXExporterImpl); !ok {
+		t.Errorf("Expected VOX exporter without a palette, got %T", p.Exporter)
+	}
+	
+	palette := GenerateMinecraftPalette(GetVanillaMinecraftBlocks())
+	p, err = NewPipeline(WithPalette(palette))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	if _, ok := p.Matcher.(*CIELABMatcher); !ok {
+		t.Errorf("Expected CIELAB matcher with a palette, got %T", p.Matcher)
+	}
+	if _, ok := p.Exporter.(*schematicGridExporter); !ok {
+		t.Errorf("Expected schematic exporter with a palette, got %T", p.Exporter)
+	}
+}
+
+func TestPipelineReuse(t *testing.T) {
+	palette := GenerateMinecraftPalette(GetVanillaMinecraftBlocks())
+	p, err := NewPipeline(WithPalette(palette), WithDithering(DitherConfig{Enabled: true}))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	
+	vg := NewVoxelGrid(8, 8, 4)
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			for z := 0; z < 4; z++ {
+				vg.SetVoxel(x, y, z, [3]uint8{uint8(x * 30), uint8(y * 30), uint8(z * 60)})
+			}
+		}
+	}
+	
+	// NBT compounds are written in map order, so compare decoded contents.
+	var outputs [3]map[string]interface{}
+	for i := range outputs {
+		var buf bytes.Buffer
+		if err := p.ExportGrid(vg, &buf); err != nil {
+			t.Fatalf("ExportGrid #%d failed: %v", i, err)
+		}
+		gz, err := gzip.NewReader(&buf)
+		if err != nil {
+			t.Fatalf("Export #%d is not gzip: %v", i, err)
+		}
+		if _, err := nbt.NewDecoder(gz).Decode(&outputs[i]); err != nil {
+			t.Fatalf("Export #%d is not NBT: %v", i, err)
+		}
+	}
+	for i := 1; i < len(outputs); i++ {
+		if !reflect.DeepEqual(outputs[0], outputs[i]) {
+			t.Errorf("Export #%d differs from the first export", i)
+		}
+	}
+}
+
+func TestDitherBuffer(t *testing.T) {
+	var b ditherBuffer
+	b.reset(4, 4, 2)
+	b.add(1, 1, 0, [3]float64{16, 0, 0}, 0.5)
+	b.add(1, 1, 1, [3]float64{0, 8, 0}, 1)
+	b.add(-1, 0, 0, [3]float64{1, 1, 1}, 1) // outside the grid
+	b.add(0, 0, 2, [3]float64{1, 1, 1}, 1)  // outside the window
+	
+	if got := b.at(1, 1, 0); got != [3]float64{8, 0, 0} {
+		t.Errorf("at(1,1,0) = %v", got)
+	}
+	b.clearPlane(0)
+	if got := b.at(1, 1, 1); got != [3]float64{0, 8, 0} {
+		t.Errorf("at(1,1,1) = %v after clearing plane 0", got)
+	}
+	if got := b.at(1, 1, 2); got != ([3]float64{}) {
+		t.Errorf("at(1,1,2) = %v, want zero for the recycled plane", got)
+	}
+	
+	b.reset(4, 4, 2)
+	if got := b.at(1, 1, 1); got != ([3]float64{}) {
+		t.Errorf("at(1,1,1) = %v after reset", got)
+	}
+}
+
+func TestDitherKernels(t *testing.T) {
+	black, white := [3]uint8{0, 0, 0}, [3]uint8{255, 255, 255}
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: black, LAB: RGBToLAB(black)},
+		{RGB: white, LAB: RGBToLAB(white)},
+	}}
+	vg := NewVoxelGrid(16, 16, 4)
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			for z := 0; z < 4; z++ {
+				vg.SetVoxel(x, y, z, [3]uint8{128, 128, 128})
+			}
+		}
+	}
+	
+	for name, kernel := range ditherKernels {
+		sum := 0.0
+		for _, tap := range kernel {
+			sum += tap.weight
+		}
+		want := 1.0
+		if name == "atkinson" {
+			want = 0.75
+		}
+		if math.Abs(sum-want) > 1e-9 {
+			t.Errorf("%s: weights sum to %g, want %g", name, sum, want)
+		}
+		
+		p, err := NewPipeline(WithPalette(palette), WithDithering(DitherConfig{Enabled: true, Algorithm: name}))
+		if err != nil {
+			t.Fatalf("%s: NewPipeline failed: %v", name, err)
+		}
+		result, err := p.MatchColorsCtx(context.Background(), vg, p.Config)
+		if err != nil {
+			t.Fatalf("%s: MatchColorsCtx failed: %v", name, err)
+		}
+		whites := 0
+		result.Range(func(x, y, z int, color [3]uint8) bool {
+			if color == white {
+				whites++
+			}
+			return true
+		})
+		if total := vg.Count(); whites < total/4 || whites > total*3/4 {
+			t.Errorf("%s: %d of %d voxels are white, want an even mix", name, whites, total)
+		}
+	}
+}
+
+func TestDistributeError3D(t *testing.T) {
+	var b ditherBuffer
+	b.reset(4, 4, 2)
+	distributeError(&b, 1, 1, 0, [3]float64{16, 16, 16}, ditherKernels["floyd-steinberg"])
+	
+	// Row taps are shared between the next row and the next plane
+	if got := b.at(2, 1, 0); got[0] != 7 {
+		t.Errorf("next in row got %g, want 7", got[0])
+	}
+	if got := b.at(1, 2, 0); got[0] != 2.5 {
+		t.Errorf("next row got %g, want 2.5", got[0])
+	}
+	if got := b.at(1, 1, 1); got[0] != 2.5 {
+		t.Errorf("next plane got %g, want 2.5", got[0])
+	}
+}
+
+func TestUnknownDitherAlgorithm(t *testing.T) {
+	palette := GenerateMinecraftPalette(GetVanillaMinecraftBlocks())
+	p := &Pipeline{Matcher: NewCIELABMatcher(palette)}
+	config := PipelineConfig{Palette: palette, Dithering: DitherConfig{Enabled: true, Algorithm: "bogus"}}
+	_, err := p.MatchColorsCtx(context.Background(), NewVoxelGrid(1, 1, 1), config)
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "floyd-steinberg") {
+		t.Errorf("Expected ErrInvalidConfig listing the algorithms, got %v", err)
+	}
+}
+
+func TestBayerMatrix3D(t *testing.T) {
+	for _, size := range []int{4, 8} {
+		thresholds := bayerMatrix3D(size)
+		seen := make(map[float64]bool)
+		for _, v := range thresholds {
+			if v <= 0 || v >= 1 || seen[v] {
+				t.Fatalf("size %d: threshold %g out of range or repeated", size, v)
+			}
+			seen[v] = true
+		}
+		if len(seen) != size*size*size {
+			t.Errorf("size %d: %d distinct thresholds", size, len(seen))
+		}
+	}
+	
+	// Every 2x2x2 block of a 4x4x4 matrix holds one value from each eighth
+	thresholds := bayerMatrix3D(4)
+	var eighths [8]int
+	for z := 0; z < 2; z++ {
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 2; x++ {
+				eighths[int(thresholds[(z*4+y)*4+x]*8)]++
+			}
+		}
+	}
+	if eighths != [8]int{1, 1, 1, 1, 1, 1, 1, 1} {
+		t.Errorf("2x2x2 block covers eighths %v", eighths)
+	}
+}
+
+func TestVisibleFace(t *testing.T) {
+	vg := NewVoxelGrid(3, 3, 3)
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			for z := 0; z < 3; z++ {
+				vg.SetVoxel(x, y, z, [3]uint8{1, 2, 3})
+			}
+		}
+	}
+	
+	tests := []struct {
+		pos  [3]int
+		want BlockFace
+	}{
+		{[3]int{1, 2, 1}, FaceTop},
+		{[3]int{1, 0, 1}, FaceBottom},
+		{[3]int{0, 1, 1}, FaceSide},
+		{[3]int{0, 2, 1}, FaceTop}, // Top edge: up ties with a side
+		{[3]int{0, 2, 0}, FaceTop}, // Top corner: up ties with both sides
+		{[3]int{1, 1, 1}, ""},
+	}
+	for _, tt := range tests {
+		if got := visibleFace(vg, tt.pos[0], tt.pos[1], tt.pos[2]); got != tt.want {
+			t.Errorf("visibleFace(%v) = %q, want %q", tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestFaceMatching(t *testing.T) {
+	dirt, grass := [3]uint8{134, 96, 67}, [3]uint8{95, 159, 53}
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:grass_block", RGB: dirt, Faces: map[BlockFace][3]uint8{FaceTop: grass}},
+		{ID: "minecraft:lime_concrete", RGB: [3]uint8{94, 168, 24}},
+		{ID: "minecraft:brown_concrete", RGB: [3]uint8{96, 59, 31}},
+	})
+	
+	// Face colors survive a msgpack round trip
+	var buf bytes.Buffer
+	if err := ExportPalette(palette, &buf); err != nil {
+		t.Fatalf("ExportPalette failed: %v", err)
+	}
+	imported, err := ImportPalette(&buf)
+	if err != nil {
+		t.Fatalf("ImportPalette failed: %v", err)
+	}
+	if got := imported.Colors[0].FaceRGB(FaceTop); got != grass {
+		t.Errorf("FaceRGB(top) after round trip = %v, want %v", got, grass)
+	}
+	if got := imported.Colors[0].FaceRGB(FaceSide); got != dirt {
+		t.Errorf("FaceRGB(side) after round trip = %v, want %v", got, dirt)
+	}
+	
+	p, err := NewPipeline(WithPalette(imported))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	
+	// The middle of a grass-green slab shows its top, so grass blocks match it
+	// better than lime
+	vg := NewVoxelGrid(3, 1, 3)
+	for x := 0; x < 3; x++ {
+		for z := 0; z < 3; z++ {
+			vg.SetVoxel(x, 0, z, grass)
+		}
+	}
+	result, err := p.MatchColorsCtx(context.Background(), vg, p.Config)
+	if err != nil {
+		t.Fatalf("MatchColorsCtx failed: %v", err)
+	}
+	if got, _ := result.ColorAt(1, 0, 1); got != dirt {
+		t.Errorf("top voxel matched %v, want grass_block", got)
+	}
+	
+	// Seen from the side, the same green matches lime concrete
+	wall := NewVoxelGrid(1, 3, 1)
+	for y := 0; y < 3; y++ {
+		wall.SetVoxel(0, y, 0, grass)
+	}
+	result, err = p.MatchColorsCtx(context.Background(), wall, p.Config)
+	if err != nil {
+		t.Fatalf("MatchColorsCtx failed: %v", err)
+	}
+	if got, _ := result.ColorAt(0, 1, 0); got != [3]uint8{94, 168, 24} {
+		t.Errorf("side voxel matched %v, want lime_concrete", got)
+	}
+}
+
+func TestOrderedDithering(t *testing.T) {
+	black, white := [3]uint8{0, 0, 0}, [3]uint8{255, 255, 255}
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: black, LAB: RGBToLAB(black)},
+		{RGB: white, LAB: RGBToLAB(white)},
+	}}
+	p, err := NewPipeline(WithPalette(palette), WithDithering(DitherConfig{Enabled: true, Algorithm: "bayer4"}))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	
+	// A flat mid-gray wall becomes an even mix of black and white
+	vg := NewVoxelGrid(8, 8, 1)
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			vg.SetVoxel(x, y, 0, [3]uint8{128, 128, 128})
+		}
+	}
+	result, err := p.MatchColorsCtx(context.Background(), vg, p.Config)
+	if err != nil {
+		t.Fatalf("MatchColorsCtx failed: %v", err)
+	}
+	whites := 0
+	result.Range(func(x, y, z int, color [3]uint8) bool {
+		if color == white {
+			whites++
+		}
+		return true
+	})
+	if whites < 16 || whites > 48 {
+		t.Errorf("%d of 64 voxels are white, want an even mix", whites)
+	}
+	
+	// Thresholds depend only on position, so a voxel matches the same alone
+	single := NewVoxelGrid(8, 8, 1)
+	single.SetVoxel(5, 3, 0, [3]uint8{128, 128, 128})
+	alone, err := p.MatchColorsCtx(context.Background(), single, p.Config)
+	if err != nil {
+		t.Fatalf("MatchColorsCtx failed: %v", err)
+	}
+	got, _ := alone.ColorAt(5, 3, 0)
+	want, _ := result.ColorAt(5, 3, 0)
+	if got != want {
+		t.Errorf("lone voxel matched %v, in the wall %v", got, want)
+	}
+}
+
+func TestErrorValues(t *testing.T) {
+	_, err := NewImporterForFile("model.abc")
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("NewImporterForFile: expected ErrUnsupportedFormat, got %v", err)
+	}
+	
+	_, err = NewPipeline(WithDithering(DitherConfig{Enabled: true}))
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewPipeline: expected ErrInvalidConfig, got %v", err)
+	}
+	
+	_, err = NewSurfaceVoxelizer().Voxelize(&Mesh{}, VoxelizationConfig{Resolution: 16})
+	if !errors.Is(err, ErrEmptyMesh) {
+		t.Errorf("Voxelize: expected ErrEmptyMesh, got %v", err)
+	}
+
+	
+	var formatErr *FormatError
+	_, err = NewVOXImporter().Import(strings.NewReader("NOPE\x96\x00\x00\x00"))
+	if !errors.As(err, &formatErr) || formatErr.Format != "vox" || formatErr.Offset != 0 {
+		t.Errorf("VOX import: expected vox FormatError at offset 0, got %v", err)
+	}
+	_, err = NewGLTFImporter().Import(strings.NewReader("not gltf"))
+	if !errors.As(err, &formatErr) || formatErr.Format != "gltf" {
+		t.Errorf("glTF import: expected gltf FormatError, got %v", err)
+	}
+	_, err = ImportPalette(strings.NewReader("\xc1"))
+	if !errors.As(err, &formatErr) || formatErr.Format != "palette" {
+		t.Errorf("ImportPalette: expected palette FormatError, got %v", err)
+	}
+}
+
+// newTriangleMesh returns a single-triangle mesh spanning the unit cube.
+func newTriangleMesh() *Mesh {
+	return &Mesh{
+		Vertices: []Vertex{
+			{Position: [3]float64{0, 0, 0}},
+			{Position: [3]float64{1, 0, 0}},
+			{Position: [3]float64{0, 1, 1}},
+		},
+		Faces: []Face{{VertexIndices: []int{0, 1, 2}, MaterialIndex: -1}},
+	}
+}
@@ -0,0 +1,174 @@
+package core
+
+import "strings"
+
+// Surface detail modes selected by PipelineConfig.Detail.
+const (
+	DetailNone        = ""             // Export full blocks only
+	DetailStairsSlabs = "stairs-slabs" // Replace the edges of one-block steps with stairs and slabs
+)
+
+// detailModes lists the accepted PipelineConfig.Detail values besides DetailNone.
+var detailModes = []string{DetailStairsSlabs}
+
+// DetailModes returns the names accepted by PipelineConfig.Detail.
+func DetailModes() []string {
+	return append([]string(nil), detailModes...)
+}
+
+// Shapes a voxel can take at the edge of a step.
+const (
+	shapeFull = iota
+	shapeStairs
+	shapeSlab
+)
+
+// stepDirections holds the horizontal neighbor offsets (x, z) in the order of
+// stairFacings: north is -z and east is +x.
+var stepDirections = [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+
+var (
+	stairFacings = [4]string{"north", "east", "south", "west"}
+	blockHalves  = [2]string{"bottom", "top"} // Stair "half" and slab "type" values
+)
+
+// blockShapes holds the block palette indices of the stairs and slabs made of a
+// full block; 0 where the palette has none.
+type blockShapes struct {
+	stairs [2][4]int32 // By half, then facing
+	slabs  [2]int32    // By half
+}
+
+// shapeVariants returns the IDs of the stairs and slab made of a full block, such
+// as "minecraft:oak_stairs" and "minecraft:oak_slab" for "minecraft:oak_planks"
+// or "minecraft:stone_brick_stairs" for "minecraft:stone_bricks". Stairs and
+// slabs themselves have none and return empty IDs.
+func shapeVariants(id string) (stairs, slab string) {
+	namespace, name, ok := strings.Cut(parseBlockState(id).Name, ":")
+	if !ok {
+		namespace, name = "minecraft", namespace
+	}
+	if strings.HasSuffix(name, "_stairs") || strings.HasSuffix(name, "_slab") {
+		return "", ""
+	}
+	switch {
+	case strings.HasSuffix(name, "_planks"):
+		name = strings.TrimSuffix(name, "_planks")
+	case strings.HasSuffix(name, "_block"):
+		name = strings.TrimSuffix(name, "_block")
+	case name == "bricks" || strings.HasSuffix(name, "_bricks") || strings.HasSuffix(name, "_tiles"):
+		name = strings.TrimSuffix(name, "s")
+	}
+	prefix := namespace + ":" + name
+	return prefix + "_stairs", prefix + "_slab"
+}
+
+// voxelShape is the shape of a voxel with its stair or slab half and stair
+// facing, as stepShape returns them.
+type voxelShape struct {
+	shape, half, facing int
+}
+
+// shapeAt returns the shape of the voxel at (x, y, z): the one a detail region
+// resolved from its sub-voxels, or else the one stepShape finds.
+func shapeAt(vg *VoxelGrid, x, y, z int) (shape, half, facing int) {
+	if s, ok := vg.shapes[[3]int{x, y, z}]; ok {
+		return s.shape, s.half, s.facing
+	}
+	return stepShape(vg, x, y, z)
+}
+
+// stepShape returns the shape of the voxel at (x, y, z) at the edge of a
+// one-block step, with the stair or slab half and the stair facing. The voxel
+// must be open above and filled below (a bottom half) or the reverse (a top
+// half, under overhangs); the bottom layer counts as filled below. A side is
+// open when its neighbor is empty and the cell past it one step down, or up for
+// a top half, is filled. One open side makes stairs whose full-height back faces
+// away from it, more make a slab, and none keep the full block.
+func stepShape(vg *VoxelGrid, x, y, z int) (shape, half, facing int) {
+	up := !vg.HasVoxel(x, y+1, z)
+	down := y > 0 && !vg.HasVoxel(x, y-1, z)
+	step := -1
+	switch {
+	case up && !down:
+		half = 0
+	case down && !up:
+		half, step = 1, 1
+	default:
+		return shapeFull, 0, 0
+	}
+
+	open := 0
+	for i, dir := range stepDirections {
+		nx, nz := x+dir[0], z+dir[1]
+		if !vg.HasVoxel(nx, y, nz) && vg.HasVoxel(nx, y+step, nz) {
+			open++
+			facing = (i + 2) % 4
+		}
+	}
+	switch open {
+	case 0:
+		return shapeFull, 0, 0
+	case 1:
+		return shapeStairs, half, facing
+	}
+	return shapeSlab, half, 0
+}
+
+// addShapes adds the stair and slab states of every full block in the palette
+// whose stairs or slab the palette also lists, recording them in l.shapes.
+func (l *blockLookup) addShapes(palette *Palette) {
+	names := make(map[string]bool)
+	for i := range palette.Colors {
+		names[parseBlockState(paletteBlockID(&palette.Colors[i])).Name] = true
+	}
+	l.shapes = make(map[int32]*blockShapes)
+	for i := range palette.Colors {
+		id := paletteBlockID(&palette.Colors[i])
+		idx := l.blocks[id]
+		if _, done := l.shapes[idx]; done {
+			continue
+		}
+		stairs, slab := shapeVariants(id)
+		shapes := &blockShapes{}
+		found := false
+		if names[stairs] {
+			for half, halfName := range blockHalves {
+				for facing, facingName := range stairFacings {
+					state := structureBlockState{Name: stairs, Properties: map[string]string{"facing": facingName, "half": halfName}}
+					shapes.stairs[half][facing] = l.add(blockStateKey(state))
+				}
+			}
+			found = true
+		}
+		if names[slab] {
+			for half, halfName := range blockHalves {
+				state := structureBlockState{Name: slab, Properties: map[string]string{"type": halfName}}
+				shapes.slabs[half] = l.add(blockStateKey(state))
+			}
+			found = true
+		}
+		if found {
+			l.shapes[idx] = shapes
+		}
+	}
+}
+
+// indexAt is like index, but with a detail mode returns the stairs or slab the
+// voxel at (x, y, z) becomes at the edge of a step. Stairs fall back to the slab
+// when the palette lists only the latter.
+func (l *blockLookup) indexAt(vg *VoxelGrid, x, y, z int, color [3]uint8) int32 {
+	idx := l.index(color)
+	shapes := l.shapes[idx]
+	if shapes == nil {
+		return idx
+	}
+	shape, half, facing := shapeAt(vg, x, y, z)
+	if shape == shapeStairs && shapes.stairs[half][facing] != 0 {
+		return shapes.stairs[half][facing]
+	}
+	if shape != shapeFull && shapes.slabs[half] != 0 {
+		return shapes.slabs[half]
+	}
+	return idx
+}
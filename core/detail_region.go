@@ -0,0 +1,379 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxDetailScale bounds DetailRegion.Scale; each voxel of a region costs the
+// cube of its scale in sub-voxels.
+const MaxDetailScale = 16
+
+// DetailRegion refines part of a model, such as a statue's face and hands,
+// beyond the grid's resolution. The region's faces are voxelized again Scale
+// times finer, and each of its voxels the finer surface passes through is
+// resolved from the sub-voxels it holds: kept full when most of them are
+// filled, dropped when few are, and given the stairs or slab shape the filled
+// ones form otherwise. Block exporters with a detail mode such as
+// DetailStairsSlabs use these shapes in place of their step analysis.
+type DetailRegion struct {
+	Min, Max [3]int   // Voxels refined, inclusive, when Groups is empty
+	Groups   []string // Names of the mesh groups refined, such as OBJ objects and glTF nodes
+	Scale    int      // Sub-voxels per voxel along each axis, from 2 to MaxDetailScale
+}
+
+// ParseDetailRegion parses a detail region written target[:scale], the target
+// being a box of voxels, x0,y0,z0,x1,y1,z1, or else the name of a mesh group,
+// and the scale 4 when omitted.
+func ParseDetailRegion(spec string) (DetailRegion, error) {
+	region := DetailRegion{Scale: 4}
+	target := spec
+	if i := strings.LastIndexByte(spec, ':'); i >= 0 {
+		scale, err := strconv.Atoi(spec[i+1:])
+		if err != nil {
+			return DetailRegion{}, fmt.Errorf("%w: invalid scale %q in detail region %q", ErrInvalidConfig, spec[i+1:], spec)
+		}
+		target, region.Scale = spec[:i], scale
+	}
+	if corners := strings.Split(target, ","); len(corners) == 6 {
+		for i, corner := range corners {
+			n, err := strconv.Atoi(strings.TrimSpace(corner))
+			if err != nil {
+				return DetailRegion{}, fmt.Errorf("%w: invalid corner %q in detail region %q", ErrInvalidConfig, corner, spec)
+			}
+			if i < 3 {
+				region.Min[i] = n
+			} else {
+				region.Max[i-3] = n
+			}
+		}
+	} else if target != "" {
+		region.Groups = []string{target}
+	} else {
+		return DetailRegion{}, fmt.Errorf("%w: detail region %q has no box or group", ErrInvalidConfig, spec)
+	}
+	if err := region.validate(); err != nil {
+		return DetailRegion{}, fmt.Errorf("%w: detail region %q: %v", ErrInvalidConfig, spec, err)
+	}
+	return region, nil
+}
+
+// validate checks the region's scale and box.
+func (r DetailRegion) validate() error {
+	if r.Scale < 2 || r.Scale > MaxDetailScale {
+		return fmt.Errorf("scale must be between 2 and %d, got %d", MaxDetailScale, r.Scale)
+	}
+	if len(r.Groups) > 0 {
+		return nil
+	}
+	for a := range r.Min {
+		if r.Min[a] > r.Max[a] {
+			return fmt.Errorf("box minimum %v exceeds maximum %v", r.Min, r.Max)
+		}
+	}
+	return nil
+}
+
+// refineRegion voxelizes the faces of region in mesh, as placed by placeMesh,
+// region.Scale times finer than vg and resolves vg's voxels in the region from
+// the result.
+func (p *Pipeline) refineRegion(ctx context.Context, vg *VoxelGrid, mesh *Mesh, region DetailRegion, config VoxelizationConfig) error {
+	k := region.Scale
+	toVoxel := func(pos [3]float64) [3]float64 {
+		return [3]float64{(pos[0] - vg.Origin[0]) * vg.Scale, (pos[1] - vg.Origin[1]) * vg.Scale, (pos[2] - vg.Origin[2]) * vg.Scale}
+	}
+	groups := make(map[string]bool, len(region.Groups))
+	for _, name := range region.Groups {
+		groups[name] = true
+	}
+
+	// Collect the region's faces, and for groups the voxels they cover
+	lo, hi := region.Min, region.Max
+	if len(groups) > 0 {
+		lo, hi = [3]int{math.MaxInt, math.MaxInt, math.MaxInt}, [3]int{math.MinInt, math.MinInt, math.MinInt}
+	}
+	sub := &Mesh{Materials: mesh.Materials, HasVertexColors: mesh.HasVertexColors, Groups: mesh.Groups}
+	vertices := make(map[int]int)
+	for _, face := range mesh.Faces {
+		if len(face.VertexIndices) < 3 {
+			continue
+		}
+		if len(groups) > 0 && (face.Group < 0 || face.Group >= len(mesh.Groups) || !groups[mesh.Groups[face.Group]]) {
+			continue
+		}
+		faceLo, faceHi := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}, [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+		for _, v := range face.VertexIndices[:3] {
+			pos := toVoxel(mesh.Vertices[v].Position)
+			for a := range pos {
+				faceLo[a], faceHi[a] = math.Min(faceLo[a], pos[a]), math.Max(faceHi[a], pos[a])
+			}
+		}
+		inside := true
+		for a := range lo {
+			if len(groups) > 0 {
+				lo[a], hi[a] = min(lo[a], int(math.Floor(faceLo[a]))), max(hi[a], int(math.Floor(faceHi[a])))
+			} else if faceHi[a] < float64(lo[a]) || faceLo[a] > float64(hi[a]+1) {
+				inside = false
+			}
+		}
+		if !inside {
+			continue
+		}
+		indices := make([]int, 3)
+		for i, v := range face.VertexIndices[:3] {
+			index, ok := vertices[v]
+			if !ok {
+				index = len(sub.Vertices)
+				sub.Vertices = append(sub.Vertices, mesh.Vertices[v])
+				vertices[v] = index
+			}
+			indices[i] = index
+		}
+		face.VertexIndices = indices
+		sub.Faces = append(sub.Faces, face)
+	}
+	if len(sub.Faces) == 0 {
+		if len(groups) > 0 {
+			return fmt.Errorf("%w: detail region: no faces in groups %s", ErrInvalidConfig, strings.Join(region.Groups, ", "))
+		}
+		return nil
+	}
+	size := [3]int{vg.SizeX, vg.SizeY, vg.SizeZ}
+	for a := range lo {
+		lo[a], hi[a] = max(lo[a], 0), min(hi[a], size[a]-1)
+		if lo[a] > hi[a] {
+			return nil
+		}
+		sub.Bounds.Min[a] = vg.Origin[a] + float64(lo[a])/vg.Scale
+		sub.Bounds.Max[a] = vg.Origin[a] + float64(hi[a]+1)/vg.Scale
+	}
+
+	// Voxelize the faces alone on a grid of sub-voxels lined up with vg's
+	config.Scale, config.TargetSize = vg.Scale*float64(k), [3]int{}
+	config.Fill, config.Hollow = false, 0
+	config.MinThickness *= k
+	config.IsoLevel *= float64(k)
+	config.Progress = nil
+	fine, err := voxelizeMesh(ctx, p.Voxelizer, sub, config)
+	if err != nil {
+		return fmt.Errorf("detail region: %w", err)
+	}
+	var offset [3]int
+	for a := range offset {
+		offset[a] = int(math.Round((fine.Origin[a] - vg.Origin[a]) * vg.Scale * float64(k)))
+	}
+	coarse := func(x, y, z int) [3]int {
+		return [3]int{floorDiv(x+offset[0], k), floorDiv(y+offset[1], k), floorDiv(z+offset[2], k)}
+	}
+
+	inner, err := innerSubVoxels(ctx, vg, fine, coarse)
+	if err != nil {
+		return err
+	}
+
+	// Tally the filled sub-voxels of each voxel the finer surface passes through
+	// by octant, lower halves holding the sub-voxels centered below the middle
+	type coverage struct {
+		octants [8]int
+		rgb     [3]float64
+		n       int
+	}
+	cells := make(map[[3]int]*coverage)
+	fx, fy, fz := fine.SizeX, fine.SizeY, fine.SizeZ
+	for z := 0; z < fz; z++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for y := 0; y < fy; y++ {
+			for x := 0; x < fx; x++ {
+				c := coarse(x, y, z)
+				if c[0] < lo[0] || c[0] > hi[0] || c[1] < lo[1] || c[1] > hi[1] || c[2] < lo[2] || c[2] > hi[2] {
+					continue
+				}
+				i := x + fx*(y+fy*z)
+				color, surface := fine.ColorAt(x, y, z)
+				if !surface && inner[i/64]&(1<<(i%64)) == 0 {
+					continue
+				}
+				cell := cells[c]
+				if cell == nil {
+					cell = &coverage{}
+					cells[c] = cell
+				}
+				octant := 0
+				for a, f := range [3]int{x, y, z} {
+					if 2*(f+offset[a]-c[a]*k)+1 > k {
+						octant |= 1 << a
+					}
+				}
+				cell.octants[octant]++
+				if surface {
+					for ch := range color {
+						cell.rgb[ch] += float64(color[ch])
+					}
+					cell.n++
+				}
+			}
+		}
+	}
+
+	// Resolve the voxels the surface passes through; the rest keep their state
+	lower := (k + 1) / 2
+	extent := [2]int{lower, k - lower}
+	if vg.shapes == nil {
+		vg.shapes = make(map[[3]int]voxelShape)
+	}
+	for c, cell := range cells {
+		if cell.n == 0 {
+			continue
+		}
+		var filled [8]bool
+		for o, count := range cell.octants {
+			filled[o] = 2*count >= extent[o&1]*extent[o>>1&1]*extent[o>>2&1]
+		}
+		shape, ok := octantShape(filled)
+		if !ok {
+			vg.DeleteVoxel(c[0], c[1], c[2])
+			delete(vg.shapes, c)
+			continue
+		}
+		var color [3]uint8
+		for ch := range color {
+			color[ch] = uint8(math.Round(cell.rgb[ch] / float64(cell.n)))
+		}
+		vg.SetVoxel(c[0], c[1], c[2], color)
+		vg.shapes[c] = shape
+	}
+	vg.addMaterials(fine.Materials)
+	return nil
+}
+
+// innerSubVoxels returns a bitset, indexed like outsideCells, of the empty
+// sub-voxels of fine that lie inside the model: those not connected to the
+// voxels vg leaves empty, or to those beyond its bounds, through other empty
+// sub-voxels. coarse maps a sub-voxel to its voxel in vg.
+func innerSubVoxels(ctx context.Context, vg *VoxelGrid, fine *VoxelGrid, coarse func(x, y, z int) [3]int) ([]uint64, error) {
+	fx, fy, fz := fine.SizeX, fine.SizeY, fine.SizeZ
+	outside := make([]uint64, (fx*fy*fz+63)/64)
+	var stack []int
+	visit := func(x, y, z int) {
+		i := x + fx*(y+fy*z)
+		if outside[i/64]&(1<<(i%64)) != 0 || fine.HasVoxel(x, y, z) {
+			return
+		}
+		outside[i/64] |= 1 << (i % 64)
+		stack = append(stack, i)
+	}
+	open := func(x, y, z int) bool {
+		c := coarse(x, y, z)
+		return !vg.HasVoxel(c[0], c[1], c[2])
+	}
+
+	// Seed the sub-voxels of empty voxels, and those facing one past fine's edge
+	for z := 0; z < fz; z++ {
+		for y := 0; y < fy; y++ {
+			for x := 0; x < fx; x++ {
+				if open(x, y, z) ||
+					x == 0 && open(x-1, y, z) || x == fx-1 && open(x+1, y, z) ||
+					y == 0 && open(x, y-1, z) || y == fy-1 && open(x, y+1, z) ||
+					z == 0 && open(x, y, z-1) || z == fz-1 && open(x, y, z+1) {
+					visit(x, y, z)
+				}
+			}
+		}
+	}
+	for n := 0; len(stack) > 0; n++ {
+		if n%(ctxCheckInterval*64) == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		x, y, z := i%fx, i/fx%fy, i/(fx*fy)
+		if x > 0 {
+			visit(x-1, y, z)
+		}
+		if x < fx-1 {
+			visit(x+1, y, z)
+		}
+		if y > 0 {
+			visit(x, y-1, z)
+		}
+		if y < fy-1 {
+			visit(x, y+1, z)
+		}
+		if z > 0 {
+			visit(x, y, z-1)
+		}
+		if z < fz-1 {
+			visit(x, y, z+1)
+		}
+	}
+
+	// Invert, leaving the empty sub-voxels no flood reached
+	for i := range outside {
+		outside[i] = ^outside[i]
+	}
+	fine.Range(func(x, y, z int, _ [3]uint8) bool {
+		i := x + fx*(y+fy*z)
+		outside[i/64] &^= 1 << (i % 64)
+		return true
+	})
+	return outside, nil
+}
+
+// octantShape returns the shape of a voxel whose octants, indexed by x, then y
+// (up), then z in bits 0 to 2, are filled as given, and false for an empty one.
+// Full lower or upper halves make slabs, and one with a filled pair of octants
+// on top (or below) makes stairs facing that side. Other voxels are kept full
+// when at least half their octants are filled.
+func octantShape(filled [8]bool) (voxelShape, bool) {
+	var halves [2]int
+	for o, f := range filled {
+		if f {
+			halves[o>>1&1]++
+		}
+	}
+	total := halves[0] + halves[1]
+	switch {
+	case total == 8:
+		return voxelShape{shape: shapeFull}, true
+	case halves[1] == 0 && halves[0] >= 3:
+		return voxelShape{shape: shapeSlab, half: 0}, true
+	case halves[0] == 0 && halves[1] >= 3:
+		return voxelShape{shape: shapeSlab, half: 1}, true
+	}
+	for half := 0; half < 2; half++ {
+		if halves[half] != 4 || halves[1-half] != 2 {
+			continue
+		}
+		// The filled octants of the other half, by their x and z bits
+		other := 1 - half
+		for facing, pair := range stairPairs {
+			if filled[pair[0]|other<<1] && filled[pair[1]|other<<1] {
+				return voxelShape{shape: shapeStairs, half: half, facing: facing}, true
+			}
+		}
+	}
+	if total >= 4 {
+		return voxelShape{shape: shapeFull}, true
+	}
+	return voxelShape{}, false
+}
+
+// stairPairs holds, in the order of stairFacings, the octants (x | z<<2) along
+// the side a stair's full-height back faces.
+var stairPairs = [4][2]int{{0, 1}, {1, 5}, {4, 5}, {0, 4}}
+
+// floorDiv divides a by b, rounding toward negative infinity.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOctantShape(t *testing.T) {
+	// octants lists filled octants as x | y<<1 | z<<2
+	tests := []struct {
+		octants []int
+		want    voxelShape
+		ok      bool
+	}{
+		{[]int{0, 1, 2, 3, 4, 5, 6, 7}, voxelShape{shape: shapeFull}, true},
+		{[]int{0, 1, 4, 5}, voxelShape{shape: shapeSlab, half: 0}, true},
+		{[]int{2, 3, 6, 7}, voxelShape{shape: shapeSlab, half: 1}, true},
+		{[]int{0, 1, 4, 5, 2, 3}, voxelShape{shape: shapeStairs, half: 0, facing: 0}, true}, // Back to the north
+		{[]int{2, 3, 6, 7, 1, 5}, voxelShape{shape: shapeStairs, half: 1, facing: 1}, true}, // Upside down, back to the east
+		{[]int{0, 1, 4, 5, 2, 7}, voxelShape{shape: shapeFull}, true},                       // Diagonal top octants
+		{[]int{0, 3}, voxelShape{}, false},
+	}
+	for _, tt := range tests {
+		var filled [8]bool
+		for _, o := range tt.octants {
+			filled[o] = true
+		}
+		if got, ok := octantShape(filled); got != tt.want || ok != tt.ok {
+			t.Errorf("octants %v: shape %+v, %v; want %+v, %v", tt.octants, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// wedge returns a closed 8x8x4 wedge whose top slopes up at 45 degrees toward
+// +x, in group "ramp".
+func wedge() *Mesh {
+	mesh := &Mesh{Groups: []string{"ramp"}}
+	for _, z := range []float64{0, 4} {
+		for _, p := range [][2]float64{{0, 0}, {8, 0}, {8, 8}} {
+			mesh.Vertices = append(mesh.Vertices, Vertex{Position: [3]float64{p[0], p[1], z}})
+		}
+	}
+	for _, tri := range [][3]int{{0, 2, 1}, {3, 4, 5}, {0, 1, 4}, {0, 4, 3}, {1, 2, 5}, {1, 5, 4}, {0, 3, 5}, {0, 5, 2}} {
+		mesh.Faces = append(mesh.Faces, Face{VertexIndices: []int{tri[0], tri[1], tri[2]}, MaterialIndex: -1})
+	}
+	return mesh
+}
+
+func TestDetailRegions(t *testing.T) {
+	for _, region := range []DetailRegion{
+		{Min: [3]int{0, 0, 0}, Max: [3]int{7, 7, 3}, Scale: 4},
+		{Groups: []string{"ramp"}, Scale: 4},
+	} {
+		p, err := NewPipeline(
+			WithVoxelization(VoxelizationConfig{Resolution: 8, Fill: true}),
+			WithDetailRegions(region),
+		)
+		if err != nil {
+			t.Fatalf("NewPipeline failed: %v", err)
+		}
+		vg, err := p.VoxelizeMeshCtx(context.Background(), wedge(), p.Config)
+		if err != nil {
+			t.Fatalf("voxelize: %v", err)
+		}
+		// Voxels along the slope become stairs with their backs to the high side
+		for i := 1; i < 7; i++ {
+			want := voxelShape{shape: shapeStairs, half: 0, facing: 1}
+			if got := vg.shapes[[3]int{i, i, 1}]; !vg.HasVoxel(i, i, 1) || got != want {
+				t.Errorf("region %+v: slope voxel %d filled %v with shape %+v, want %+v", region, i, vg.HasVoxel(i, i, 1), got, want)
+			}
+		}
+		if !vg.HasVoxel(6, 2, 1) || vg.HasVoxel(2, 6, 1) {
+			t.Errorf("region %+v: wedge interior or the space above it changed", region)
+		}
+	}
+
+	// The schematic exporters use the resolved shapes
+	planks := [3]uint8{162, 130, 78}
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:oak_planks", RGB: planks},
+		{ID: "minecraft:oak_stairs", RGB: [3]uint8{160, 128, 76}},
+	})
+	vg := NewVoxelGrid(1, 1, 1)
+	vg.SetVoxel(0, 0, 0, planks)
+	vg.shapes = map[[3]int]voxelShape{{0, 0, 0}: {shape: shapeStairs, half: 1, facing: 2}}
+	exporter := NewFunctionExporter()
+	exporter.Detail = DetailStairsSlabs
+	commands := strings.Join(exporter.Commands(vg, palette), "\n")
+	if want := "minecraft:oak_stairs[facing=south,half=top]"; !strings.Contains(commands, want) {
+		t.Errorf("commands missing %q:\n%s", want, commands)
+	}
+
+	p, err := NewPipeline(WithDetailRegions(DetailRegion{Groups: []string{"head"}, Scale: 4}))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	if _, err := p.VoxelizeMeshCtx(context.Background(), wedge(), p.Config); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for a group without faces, got %v", err)
+	}
+	parses := []struct {
+		spec string
+		want DetailRegion
+	}{
+		{"head", DetailRegion{Groups: []string{"head"}, Scale: 4}},
+		{"left hand:8", DetailRegion{Groups: []string{"left hand"}, Scale: 8}},
+		{"0,40,0,20,60,20:2", DetailRegion{Min: [3]int{0, 40, 0}, Max: [3]int{20, 60, 20}, Scale: 2}},
+	}
+	for _, tt := range parses {
+		got, err := ParseDetailRegion(tt.spec)
+		if err != nil || got.Min != tt.want.Min || got.Max != tt.want.Max || got.Scale != tt.want.Scale || strings.Join(got.Groups, ",") != strings.Join(tt.want.Groups, ",") {
+			t.Errorf("ParseDetailRegion(%q) = %+v, %v; want %+v", tt.spec, got, err, tt.want)
+		}
+	}
+	for _, spec := range []string{"head:x", ":4", "0,0,0,1,1,z", "5,0,0,4,4,4"} {
+		if _, err := ParseDetailRegion(spec); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("ParseDetailRegion(%q): expected ErrInvalidConfig, got %v", spec, err)
+		}
+	}
+
+	for _, region := range []DetailRegion{
+		{Max: [3]int{4, 4, 4}, Scale: 1},
+		{Max: [3]int{4, 4, 4}, Scale: MaxDetailScale + 1},
+		{Min: [3]int{5, 0, 0}, Max: [3]int{4, 4, 4}, Scale: 2},
+	} {
+		if _, err := NewPipeline(WithDetailRegions(region)); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("region %+v: expected ErrInvalidConfig, got %v", region, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestShapeVariants(t *testing.T) {
+	tests := []struct {
+		id, stairs, slab string
+	}{
+		{"minecraft:oak_planks", "minecraft:oak_stairs", "minecraft:oak_slab"},
+		{"minecraft:stone_bricks", "minecraft:stone_brick_stairs", "minecraft:stone_brick_slab"},
+		{"minecraft:bricks", "minecraft:brick_stairs", "minecraft:brick_slab"},
+		{"minecraft:deepslate_tiles", "minecraft:deepslate_tile_stairs", "minecraft:deepslate_tile_slab"},
+		{"minecraft:quartz_block", "minecraft:quartz_stairs", "minecraft:quartz_slab"},
+		{"cobblestone", "minecraft:cobblestone_stairs", "minecraft:cobblestone_slab"},
+		{"minecraft:oak_slab[type=top]", "", ""},
+	}
+	for _, tt := range tests {
+		if stairs, slab := shapeVariants(tt.id); stairs != tt.stairs || slab != tt.slab {
+			t.Errorf("shapeVariants(%q) = %q, %q; want %q, %q", tt.id, stairs, slab, tt.stairs, tt.slab)
+		}
+	}
+}
+
+func TestStepShape(t *testing.T) {
+	// Steps rising towards +x, each one block higher, and the same upside down
+	// hanging from the top of the grid
+	vg := NewVoxelGrid(3, 8, 1)
+	for x := 0; x < 3; x++ {
+		for y := 0; y <= x; y++ {
+			vg.SetVoxel(x, y, 0, [3]uint8{1, 1, 1})
+			vg.SetVoxel(x, 7-y, 0, [3]uint8{1, 1, 1})
+		}
+	}
+	tests := []struct {
+		x, y                int
+		shape, half, facing int
+	}{
+		{0, 0, shapeFull, 0, 0}, // Lowest step: nothing lower beside it
+		{1, 0, shapeFull, 0, 0}, // Covered
+		{1, 1, shapeStairs, 0, 1},
+		{2, 2, shapeStairs, 0, 1},
+		{1, 6, shapeStairs, 1, 1},
+	}
+	for _, tt := range tests {
+		shape, half, facing := stepShape(vg, tt.x, tt.y, 0)
+		if shape != tt.shape || half != tt.half || facing != tt.facing {
+			t.Errorf("stepShape(%d, %d) = %d, %d, %d; want %d, %d, %d",
+				tt.x, tt.y, shape, half, facing, tt.shape, tt.half, tt.facing)
+		}
+	}
+
+	// A single block on top of a 3x3 base steps down on every side
+	peak := NewVoxelGrid(3, 2, 3)
+	for x := 0; x < 3; x++ {
+		for z := 0; z < 3; z++ {
+			peak.SetVoxel(x, 0, z, [3]uint8{1, 1, 1})
+		}
+	}
+	peak.SetVoxel(1, 1, 1, [3]uint8{1, 1, 1})
+	if shape, half, _ := stepShape(peak, 1, 1, 1); shape != shapeSlab || half != 0 {
+		t.Errorf("peak shape = %d, half %d; want a bottom slab", shape, half)
+	}
+}
+
+func TestDetailStairsSlabs(t *testing.T) {
+	planks := [3]uint8{162, 130, 78}
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:oak_planks", RGB: planks},
+		{ID: "minecraft:oak_stairs", RGB: [3]uint8{160, 128, 76}},
+		{ID: "minecraft:oak_slab", RGB: [3]uint8{158, 126, 74}},
+	})
+	vg := NewVoxelGrid(3, 2, 3)
+	for x := 0; x < 3; x++ {
+		for z := 0; z < 3; z++ {
+			vg.SetVoxel(x, 0, z, planks)
+		}
+	}
+	vg.SetVoxel(1, 1, 1, planks)
+	vg.SetVoxel(2, 1, 1, planks)
+
+	exporter := NewFunctionExporter()
+	exporter.Detail = DetailStairsSlabs
+	commands := strings.Join(exporter.Commands(vg, palette), "\n")
+	for _, want := range []string{
+		"fill ~1 ~1 ~1 ~2 ~1 ~1 minecraft:oak_slab[type=bottom]",
+		"fill ~0 ~0 ~0 ~2 ~0 ~0 minecraft:oak_planks",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("commands missing %q:\n%s", want, commands)
+		}
+	}
+
+	// A ramp along x: the top block has one open side and becomes stairs
+	ramp := NewVoxelGrid(2, 2, 1)
+	ramp.SetVoxel(0, 0, 0, planks)
+	ramp.SetVoxel(1, 0, 0, planks)
+	ramp.SetVoxel(1, 1, 0, planks)
+	commands = strings.Join(exporter.Commands(ramp, palette), "\n")
+	if want := "setblock ~1 ~1 ~0 minecraft:oak_stairs[facing=east,half=bottom]"; !strings.Contains(commands, want) {
+		t.Errorf("commands missing %q:\n%s", want, commands)
+	}
+
+	// Without detail the same grid stays full blocks
+	exporter.Detail = DetailNone
+	var buf bytes.Buffer
+	if err := exporter.Export(ramp, palette, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if strings.Contains(buf.String(), "stairs") {
+		t.Errorf("stairs exported without detail:\n%s", buf.String())
+	}
+}
+
+func TestDetailValidation(t *testing.T) {
+	palette := GenerateMinecraftPalette(GetVanillaMinecraftBlocks())
+	if _, err := NewPipeline(WithPalette(palette), WithDetail("bevels")); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unknown mode, got %v", err)
+	}
+	if _, err := NewPipeline(WithDetail(DetailStairsSlabs)); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for detail without a palette, got %v", err)
+	}
+	p, err := NewPipeline(WithPalette(palette), WithDetail(DetailStairsSlabs), WithExporterName("schematic"))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	if got := p.Exporter.(*schematicGridExporter).exporter.Detail; got != DetailStairsSlabs {
+		t.Errorf("schematic exporter detail = %q, want %q", got, DetailStairsSlabs)
+	}
+}
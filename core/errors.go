@@ -0,0 +1,64 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Sentinel errors returned (wrapped) by the pipeline; test for them with errors.Is.
+var (
+	// ErrUnsupportedFormat is returned when no importer or exporter handles a file extension.
+	ErrUnsupportedFormat = errors.New("unsupported format")
+
+	// ErrInvalidConfig is returned by NewPipeline when the configuration is inconsistent.
+	ErrInvalidConfig = errors.New("invalid pipeline configuration")
+
+	// ErrEmptyMesh is returned when a mesh has no geometry to voxelize.
+	ErrEmptyMesh = errors.New("mesh is empty")
+
+	// ErrPaletteTooLarge is returned when a grid uses more colors than the output format can store.
+	ErrPaletteTooLarge = errors.New("too many colors for output format")
+
+	// ErrGridTooLarge is returned when a voxel grid's bounding box would exceed the configured cell limit.
+	ErrGridTooLarge = errors.New("voxel grid exceeds cell limit")
+)
+
+// FormatError reports malformed input data. Test for it with errors.As.
+type FormatError struct {
+	Format string // Input format, e.g. "vox", "gltf", "schematic", "palette"
+	Offset int64  // Input bytes consumed when decoding failed (decoders may read ahead), or -1 if unknown
+	Msg    string // Description of the problem
+	Err    error  // Underlying decoder error, if any
+}
+
+func (e *FormatError) Error() string {
+	msg := e.Msg
+	if e.Err != nil {
+		if msg == "" {
+			msg = e.Err.Error()
+		} else {
+			msg += ": " + e.Err.Error()
+		}
+	}
+	if e.Offset < 0 {
+		return fmt.Sprintf("invalid %s data: %s", e.Format, msg)
+	}
+	return fmt.Sprintf("invalid %s data at offset %d: %s", e.Format, e.Offset, msg)
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// countingReader tracks how many bytes have been read so decoders can report offsets.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
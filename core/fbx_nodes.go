@@ -0,0 +1,553 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// fbxNode is a node of an FBX document, binary or ASCII. Properties hold int64,
+// float64, bool, string and []byte scalars, and []int64, []float64 and []bool
+// arrays; ASCII documents store every number array as []float64.
+type fbxNode struct {
+	name     string
+	props    []interface{}
+	children []*fbxNode
+}
+
+// child returns the first child with the given name, or nil.
+func (n *fbxNode) child(name string) *fbxNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// prop returns property i, or nil when there is none.
+func (n *fbxNode) prop(i int) interface{} {
+	if n == nil || i >= len(n.props) {
+		return nil
+	}
+	return n.props[i]
+}
+
+// fbxInt returns a numeric property as an integer.
+func fbxInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// fbxFloat returns a numeric property as a float.
+func fbxFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// fbxString returns a string property, or "".
+func fbxString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// fbxFloats returns a numeric array property as floats.
+func fbxFloats(v interface{}) []float64 {
+	switch v := v.(type) {
+	case []float64:
+		return v
+	case []int64:
+		out := make([]float64, len(v))
+		for i, x := range v {
+			out[i] = float64(x)
+		}
+		return out
+	}
+	return nil
+}
+
+// fbxInts returns a numeric array property as integers.
+func fbxInts(v interface{}) []int64 {
+	switch v := v.(type) {
+	case []int64:
+		return v
+	case []float64:
+		out := make([]int64, len(v))
+		for i, x := range v {
+			out[i] = int64(x)
+		}
+		return out
+	}
+	return nil
+}
+
+// fbxBinaryMagic starts every binary FBX file.
+const fbxBinaryMagic = "Kaydara FBX Binary  \x00"
+
+// fbxMaxDepth bounds node nesting, which real files keep to a handful of levels.
+const fbxMaxDepth = 64
+
+// fbxMaxArray bounds the decoded size of one array property.
+const fbxMaxArray = 1 << 30
+
+// parseFBX parses a binary or ASCII FBX document into its top-level nodes.
+func parseFBX(data []byte) ([]*fbxNode, error) {
+	if bytes.HasPrefix(data, []byte(fbxBinaryMagic)) {
+		return parseBinaryFBX(data)
+	}
+	return parseASCIIFBX(data)
+}
+
+// fbxBinaryReader decodes the node records of a binary FBX file.
+type fbxBinaryReader struct {
+	data []byte
+	pos  int
+	wide bool // Version 7.5 and later use 64-bit record offsets
+}
+
+func (r *fbxBinaryReader) fail(format string, args ...interface{}) error {
+	return &FormatError{Format: "fbx", Offset: int64(r.pos), Msg: fmt.Sprintf(format, args...)}
+}
+
+func parseBinaryFBX(data []byte) ([]*fbxNode, error) {
+	if len(data) < 27 {
+		return nil, &FormatError{Format: "fbx", Offset: int64(len(data)), Msg: "truncated header"}
+	}
+	version := binary.LittleEndian.Uint32(data[23:])
+	if version < 7000 || version >= 8000 {
+		return nil, &FormatError{Format: "fbx", Offset: 23, Msg: fmt.Sprintf("FBX version %d is not supported (7.x needed)", version)}
+	}
+	r := &fbxBinaryReader{data: data, pos: 27, wide: version >= 7500}
+	var nodes []*fbxNode
+	for {
+		node, err := r.readNode(0)
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			return nodes, nil
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+// uint reads a record header field, 32 or 64 bits wide.
+func (r *fbxBinaryReader) uint() (uint64, error) {
+	if r.wide {
+		if r.pos+8 > len(r.data) {
+			return 0, r.fail("truncated node record")
+		}
+		v := binary.LittleEndian.Uint64(r.data[r.pos:])
+		r.pos += 8
+		return v, nil
+	}
+	if r.pos+4 > len(r.data) {
+		return 0, r.fail("truncated node record")
+	}
+	v := binary.LittleEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return uint64(v), nil
+}
+
+// readNode reads one node record and its children. It returns nil at the null
+// record ending a node list, or at the end of the data.
+func (r *fbxBinaryReader) readNode(depth int) (*fbxNode, error) {
+	if r.pos >= len(r.data) {
+		return nil, nil
+	}
+	if depth > fbxMaxDepth {
+		return nil, r.fail("nodes nested too deeply")
+	}
+	start := r.pos
+	endOffset, err := r.uint()
+	if err != nil {
+		return nil, err
+	}
+	numProps, err := r.uint()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.uint(); err != nil { // Property list length
+		return nil, err
+	}
+	if r.pos >= len(r.data) {
+		return nil, r.fail("truncated node record")
+	}
+	nameLen := int(r.data[r.pos])
+	r.pos++
+	if endOffset == 0 {
+		return nil, nil // Null record
+	}
+	if endOffset <= uint64(start) || endOffset > uint64(len(r.data)) || r.pos+nameLen > len(r.data) {
+		r.pos = start
+		return nil, r.fail("invalid node record")
+	}
+	node := &fbxNode{name: string(r.data[r.pos : r.pos+nameLen])}
+	r.pos += nameLen
+
+	end := int(endOffset)
+	for i := uint64(0); i < numProps; i++ {
+		prop, err := r.readProperty(end)
+		if err != nil {
+			return nil, err
+		}
+		node.props = append(node.props, prop)
+	}
+	for r.pos < end {
+		child, err := r.readNode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			break
+		}
+		node.children = append(node.children, child)
+	}
+	r.pos = end
+	return node, nil
+}
+
+// readProperty reads one property, which must end by end.
+func (r *fbxBinaryReader) readProperty(end int) (interface{}, error) {
+	if r.pos >= end {
+		return nil, r.fail("truncated property")
+	}
+	code := r.data[r.pos]
+	r.pos++
+	le := binary.LittleEndian
+	scalar := func(size int) ([]byte, error) {
+		if r.pos+size > end {
+			return nil, r.fail("truncated %q property", code)
+		}
+		b := r.data[r.pos : r.pos+size]
+		r.pos += size
+		return b, nil
+	}
+	switch code {
+	case 'Y':
+		b, err := scalar(2)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int16(le.Uint16(b))), nil
+	case 'C':
+		b, err := scalar(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case 'I':
+		b, err := scalar(4)
+		if err != nil {
+			return nil, err
+		}
+		return int64(int32(le.Uint32(b))), nil
+	case 'L':
+		b, err := scalar(8)
+		if err != nil {
+			return nil, err
+		}
+		return int64(le.Uint64(b)), nil
+	case 'F':
+		b, err := scalar(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(le.Uint32(b))), nil
+	case 'D':
+		b, err := scalar(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(le.Uint64(b)), nil
+	case 'S', 'R':
+		b, err := scalar(4)
+		if err != nil {
+			return nil, err
+		}
+		data, err := scalar(int(le.Uint32(b)))
+		if err != nil {
+			return nil, err
+		}
+		if code == 'S' {
+			return string(data), nil
+		}
+		return append([]byte(nil), data...), nil
+	case 'f', 'd', 'l', 'i', 'b':
+		return r.readArray(code, end)
+	}
+	r.pos--
+	return nil, r.fail("unknown property type %q", code)
+}
+
+// fbxArrayElemSizes gives the element size of each array property type.
+var fbxArrayElemSizes = map[byte]int{'f': 4, 'd': 8, 'l': 8, 'i': 4, 'b': 1}
+
+// readArray reads an array property, zlib-compressed or not.
+func (r *fbxBinaryReader) readArray(code byte, end int) (interface{}, error) {
+	if r.pos+12 > end {
+		return nil, r.fail("truncated array header")
+	}
+	le := binary.LittleEndian
+	length := int64(le.Uint32(r.data[r.pos:]))
+	encoding := le.Uint32(r.data[r.pos+4:])
+	stored := int(le.Uint32(r.data[r.pos+8:]))
+	r.pos += 12
+	size := length * int64(fbxArrayElemSizes[code])
+	if size > fbxMaxArray {
+		return nil, r.fail("array of %d elements too large", length)
+	}
+	if stored < 0 || r.pos+stored > end {
+		return nil, r.fail("truncated array")
+	}
+	raw := r.data[r.pos : r.pos+stored]
+	switch encoding {
+	case 0:
+		if int64(len(raw)) != size {
+			return nil, r.fail("array of %d elements stores %d bytes", length, len(raw))
+		}
+	case 1:
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, r.fail("invalid compressed array: %v", err)
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(zr, buf); err != nil {
+			return nil, r.fail("invalid compressed array: %v", err)
+		}
+		raw = buf
+	default:
+		return nil, r.fail("unknown array encoding %d", encoding)
+	}
+	r.pos += stored
+
+	switch code {
+	case 'f':
+		out := make([]float64, length)
+		for i := range out {
+			out[i] = float64(math.Float32frombits(le.Uint32(raw[4*i:])))
+		}
+		return out, nil
+	case 'd':
+		out := make([]float64, length)
+		for i := range out {
+			out[i] = math.Float64frombits(le.Uint64(raw[8*i:]))
+		}
+		return out, nil
+	case 'l':
+		out := make([]int64, length)
+		for i := range out {
+			out[i] = int64(le.Uint64(raw[8*i:]))
+		}
+		return out, nil
+	case 'i':
+		out := make([]int64, length)
+		for i := range out {
+			out[i] = int64(int32(le.Uint32(raw[4*i:])))
+		}
+		return out, nil
+	}
+	out := make([]bool, length)
+	for i := range out {
+		out[i] = raw[i] != 0
+	}
+	return out, nil
+}
+
+// fbxASCIIParser parses the text encoding of FBX: "Name: value, value {" nodes,
+// with arrays written as "Name: *count { a: value, value }".
+type fbxASCIIParser struct {
+	data []byte
+	pos  int
+}
+
+// fbxToken is a lexical token of ASCII FBX.
+type fbxToken struct {
+	kind  byte // 'k' key ("Name:"), 's' string, 'n' number, 'w' bare word, '*' array count, or the punctuation itself
+	text  string
+	start int
+}
+
+func parseASCIIFBX(data []byte) ([]*fbxNode, error) {
+	p := &fbxASCIIParser{data: data}
+	nodes, err := p.parseNodes(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok.kind != 0 {
+		return nil, &FormatError{Format: "fbx", Offset: int64(tok.start), Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	if len(nodes) == 0 {
+		return nil, &FormatError{Format: "fbx", Offset: 0, Msg: "not an FBX file"}
+	}
+	return nodes, nil
+}
+
+// next returns the next token, or one of kind 0 at the end of the data.
+func (p *fbxASCIIParser) next() fbxToken {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case c == ';':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.pos++
+		default:
+			return p.token()
+		}
+	}
+	return fbxToken{start: p.pos}
+}
+
+// peek returns the next token without consuming it.
+func (p *fbxASCIIParser) peek() fbxToken {
+	pos := p.pos
+	tok := p.next()
+	p.pos = pos
+	return tok
+}
+
+func (p *fbxASCIIParser) token() fbxToken {
+	start := p.pos
+	c := p.data[p.pos]
+	switch c {
+	case '{', '}', ',':
+		p.pos++
+		return fbxToken{kind: c, text: string(c), start: start}
+	case '"':
+		end := bytes.IndexByte(p.data[p.pos+1:], '"')
+		if end < 0 {
+			p.pos = len(p.data)
+			return fbxToken{kind: '?', text: "unterminated string", start: start}
+		}
+		p.pos += end + 2
+		s := string(p.data[start+1 : p.pos-1])
+		return fbxToken{kind: 's', text: strings.ReplaceAll(s, "&quot;", "\""), start: start}
+	case '*':
+		p.pos++
+		for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+			p.pos++
+		}
+		return fbxToken{kind: '*', text: string(p.data[start+1 : p.pos]), start: start}
+	}
+	for p.pos < len(p.data) && !strings.ContainsRune(" \t\r\n,{}:;\"", rune(p.data[p.pos])) {
+		p.pos++
+	}
+	text := string(p.data[start:p.pos])
+	if p.pos < len(p.data) && p.data[p.pos] == ':' {
+		p.pos++
+		return fbxToken{kind: 'k', text: text, start: start}
+	}
+	if text == "" {
+		p.pos++
+		return fbxToken{kind: '?', text: string(c), start: start}
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return fbxToken{kind: 'n', text: text, start: start}
+	}
+	return fbxToken{kind: 'w', text: text, start: start}
+}
+
+// parseNodes parses nodes until a closing brace or the end of the data.
+func (p *fbxASCIIParser) parseNodes(depth int) ([]*fbxNode, error) {
+	if depth > fbxMaxDepth {
+		return nil, &FormatError{Format: "fbx", Offset: int64(p.pos), Msg: "nodes nested too deeply"}
+	}
+	var nodes []*fbxNode
+	for {
+		tok := p.peek()
+		if tok.kind != 'k' {
+			return nodes, nil
+		}
+		p.next()
+		node, err := p.parseNode(tok.text, depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+// parseNode parses the properties and children following a node's key.
+func (p *fbxASCIIParser) parseNode(name string, depth int) (*fbxNode, error) {
+	node := &fbxNode{name: name}
+	array := false
+	for {
+		tok := p.peek()
+		switch tok.kind {
+		case 's', 'w':
+			p.next()
+			node.props = append(node.props, tok.text)
+		case 'n':
+			p.next()
+			node.props = append(node.props, fbxNumber(tok.text))
+		case '*':
+			p.next()
+			array = true
+		default:
+			if tok.kind == '{' {
+				p.next()
+				children, err := p.parseNodes(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				if end := p.next(); end.kind != '}' {
+					return nil, &FormatError{Format: "fbx", Offset: int64(end.start), Msg: fmt.Sprintf("expected } closing %s", name)}
+				}
+				node.children = children
+			}
+			if array {
+				// The values are those of the "a" child
+				values := []float64{}
+				for _, v := range node.child("a").values() {
+					if f, ok := fbxFloat(v); ok {
+						values = append(values, f)
+					}
+				}
+				node.props, node.children = []interface{}{values}, nil
+			}
+			return node, nil
+		}
+		if p.peek().kind == ',' {
+			p.next()
+		}
+	}
+}
+
+// values returns all of a node's properties, or nil for a missing node.
+func (n *fbxNode) values() []interface{} {
+	if n == nil {
+		return nil
+	}
+	return n.props
+}
+
+// fbxNumber parses an ASCII number as an integer when it has no fraction or
+// exponent, and as a float otherwise.
+func fbxNumber(text string) interface{} {
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i
+	}
+	f, _ := strconv.ParseFloat(text, 64)
+	return f
+}
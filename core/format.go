@@ -1,6 +1,10 @@
 package core
 
-import "io"
+import (
+	"fmt"
+	"io"
+	"strings"
+)
 
 // VOXFormat handles MagicaVoxel .vox file format.
 type VOXFormat struct{}
@@ -17,17 +21,144 @@ type VOXImporter interface {
 	Import(r io.Reader) (*VoxelGrid, error)
 }
 
+// QubicleExporter is the interface for exporting voxel grids to Qubicle .qb format.
+type QubicleExporter interface {
+	// Export writes a voxel grid to Qubicle Binary format.
+	Export(vg *VoxelGrid, w io.Writer) error
+}
+
+// QubicleImporter is the interface for importing Qubicle .qb files.
+type QubicleImporter interface {
+	// Import reads a Qubicle Binary file and returns a voxel grid.
+	Import(r io.Reader) (*VoxelGrid, error)
+}
+
+// BinvoxExporter is the interface for exporting voxel grids to binvox format.
+type BinvoxExporter interface {
+	// Export writes a voxel grid's occupancy to binvox format.
+	Export(vg *VoxelGrid, w io.Writer) error
+}
+
+// BinvoxImporter is the interface for importing binvox files.
+type BinvoxImporter interface {
+	// Import reads a binvox file and returns a voxel grid.
+	Import(r io.Reader) (*VoxelGrid, error)
+}
+
+// HeightmapImporter is the interface for building terrain from heightmap images.
+type HeightmapImporter interface {
+	// Import reads a grayscale heightmap and an optional color overlay (nil for
+	// none) and returns the terrain as a voxel grid.
+	Import(heightmap, overlay io.Reader) (*VoxelGrid, error)
+}
+
 // SchematicFormat handles Minecraft schematic format.
 type SchematicFormat struct {
 	Version string // "1.13+", "1.12" for different Minecraft versions
 }
 
+// SchematicConfig holds parameters for schematic export.
+type SchematicConfig struct {
+	Version     int    // Sponge schematic format version, 2 or 3 (0 = 2)
+	DataVersion int    // Minecraft data version recorded in schematic and structure files (0 = 2975, Minecraft 1.19)
+	AirMode     string // How empty cells are written in schematic and structure files, such as AirModeVoid (default AirModeDefault)
+	PasteOrigin string // Where the grid lands relative to the paste position, such as PasteOriginCenterBottom (default PasteOriginCorner)
+	Offset      [3]int // Grid's minimum corner relative to the paste position, when PasteOrigin is PasteOriginCorner
+	Rotation    int    // Quarter turns about y, clockwise seen from above, of Sponge schematics and structure files (0-3)
+}
+
+// Air modes selected by SchematicConfig.AirMode. Commands and world saves only
+// ever place filled cells, whatever the mode.
+const (
+	AirModeDefault = ""     // Air in Sponge and MCEdit schematics; left out of structure files and Minetest schematics
+	AirModeAir     = "air"  // Air everywhere, so pasting clears what was in the grid's box
+	AirModeVoid    = "void" // Structure voids in Sponge and MCEdit schematics, left out elsewhere, so pasting keeps the terrain
+)
+
+// structureVoidID is the block that structure blocks and schematic tools can
+// skip when pasting, unlike air.
+const structureVoidID = "minecraft:structure_void"
+
+// airModes lists the accepted SchematicConfig.AirMode values besides AirModeDefault.
+var airModes = []string{AirModeAir, AirModeVoid}
+
+// AirModes returns the names accepted by SchematicConfig.AirMode.
+func AirModes() []string {
+	return append([]string(nil), airModes...)
+}
+
+// checkAirMode returns an ErrInvalidConfig error for an unknown air mode.
+func checkAirMode(mode string) error {
+	if mode != AirModeDefault && !containsString(airModes, mode) {
+		return fmt.Errorf("%w: unknown air mode %q (supported: %s)", ErrInvalidConfig, mode, strings.Join(airModes, ", "))
+	}
+	return nil
+}
+
+// Paste origins selected by SchematicConfig.PasteOrigin. They set the offset that
+// WorldEdit and similar tools apply when pasting Sponge and MCEdit schematics.
+const (
+	PasteOriginCorner       = ""              // Minimum corner at the paste position, moved by SchematicConfig.Offset
+	PasteOriginCenterBottom = "center-bottom" // Center of the bottom layer at the paste position
+	PasteOriginCenter       = "center"        // Center of the grid at the paste position
+)
+
+// pasteOrigins lists the accepted SchematicConfig.PasteOrigin values besides PasteOriginCorner.
+var pasteOrigins = []string{PasteOriginCenterBottom, PasteOriginCenter}
+
+// PasteOrigins returns the names accepted by SchematicConfig.PasteOrigin.
+func PasteOrigins() []string {
+	return append([]string(nil), pasteOrigins...)
+}
+
+// pasteOffset returns the position of the minimum corner of a grid of the given
+// size relative to the paste position, or an ErrInvalidConfig error for an
+// unknown paste origin or an offset given with a named one.
+func pasteOffset(size [3]int, origin string, offset [3]int) ([3]int, error) {
+	switch origin {
+	case PasteOriginCorner:
+		return offset, nil
+	case PasteOriginCenterBottom, PasteOriginCenter:
+		if offset != [3]int{} {
+			return offset, fmt.Errorf("%w: an offset cannot be combined with the %q paste origin", ErrInvalidConfig, origin)
+		}
+		if origin == PasteOriginCenter {
+			return [3]int{-size[0] / 2, -size[1] / 2, -size[2] / 2}, nil
+		}
+		return [3]int{-size[0] / 2, 0, -size[2] / 2}, nil
+	}
+	return offset, fmt.Errorf("%w: unknown paste origin %q (supported: %s)", ErrInvalidConfig, origin, strings.Join(pasteOrigins, ", "))
+}
+
+// FunctionConfig holds parameters for command (.mcfunction, datapack and WorldEdit) export.
+type FunctionConfig struct {
+	Namespace   string // Datapack namespace (default "poly2block")
+	MaxCommands int    // Commands per function before a datapack is split (0 = 32768)
+	Boxes       bool   // Fill the largest boxes of one block (greedy meshing) rather than runs along x
+	Origin      [3]int // World position of the grid's minimum corner in WorldEdit scripts
+}
+
+// Sponge schematic defaults used when SchematicConfig fields are zero.
+const (
+	defaultSchematicVersion     = 2
+	defaultSchematicDataVersion = 2975
+)
+
 // MinecraftBlock represents a Minecraft block with its properties.
 type MinecraftBlock struct {
 	ID         string
 	Properties map[string]string
 	RGB        [3]uint8
 	LAB        LABColor
+	Faces      map[BlockFace][3]uint8 `json:",omitempty"` // Average colors of faces that differ from RGB
+	
+	// Translucency is the fraction of light the block's texture lets through,
+	// from 0 for opaque blocks to 1; glass and leaves are above 0.
+	Translucency float64 `json:",omitempty"`
+	
+	// Noise is how much the texture varies around its average color: the RMS
+	// CIELAB distance of its pixels from the average, high for granite or gravel.
+	Noise float64 `json:",omitempty"`
 }
 
 // SchematicExporter is the interface for exporting to Minecraft schematic format.
@@ -36,8 +167,56 @@ type SchematicExporter interface {
 	Export(vg *VoxelGrid, palette *Palette, config DitherConfig, w io.Writer) error
 }
 
+// StructureExporter is the interface for exporting to the vanilla structure block format.
+type StructureExporter interface {
+	// Export writes a voxel grid as a structure file.
+	Export(vg *VoxelGrid, palette *Palette, w io.Writer) error
+}
+
+// MCEditExporter is the interface for exporting to the classic MCEdit schematic format.
+type MCEditExporter interface {
+	// Export writes a voxel grid as an MCEdit schematic.
+	Export(vg *VoxelGrid, palette *Palette, w io.Writer) error
+}
+
+// MinetestExporter is the interface for exporting to the Minetest (Luanti) schematic format.
+type MinetestExporter interface {
+	// Export writes a voxel grid as a Minetest .mts schematic.
+	Export(vg *VoxelGrid, palette *Palette, w io.Writer) error
+}
+
+// FunctionExporter is the interface for exporting to Minecraft commands.
+type FunctionExporter interface {
+	// Export writes a voxel grid as a single .mcfunction file.
+	Export(vg *VoxelGrid, palette *Palette, w io.Writer) error
+
+	// ExportDatapack writes a voxel grid as a datapack of chained functions.
+	ExportDatapack(vg *VoxelGrid, palette *Palette, w io.Writer) error
+}
+
+// MeshExporter is the interface for exporting a voxel grid as a polygon mesh.
+type MeshExporter interface {
+	// Export writes the grid's greedy-meshed surface.
+	Export(vg *VoxelGrid, w io.Writer) error
+
+	// ExportMesh writes a mesh, such as one built by GreedyMesh.
+	ExportMesh(mesh *Mesh, w io.Writer) error
+}
+
+// WorldExporter is the interface for writing into a Minecraft world save.
+type WorldExporter interface {
+	// Export writes a voxel grid into the region files of the world in worldDir.
+	Export(vg *VoxelGrid, palette *Palette, worldDir string) error
+}
+
 // SchematicImporter is the interface for importing Minecraft schematics.
 type SchematicImporter interface {
 	// Import reads a schematic file and returns a voxel grid.
 	Import(r io.Reader) (*VoxelGrid, error)
 }
+
+// LitematicImporter is the interface for importing Litematica schematics.
+type LitematicImporter interface {
+	// Import reads a .litematic file and returns a voxel grid.
+	Import(r io.Reader) (*VoxelGrid, error)
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// binvoxMaxExtent bounds each dimension of an imported binvox grid.
+const binvoxMaxExtent = 1 << 16
+
+// BinvoxExporterImpl writes voxel grids in the binvox format used by binvox,
+// viewvox and academic voxelization pipelines. binvox stores occupancy only, so
+// colors are dropped. Voxels are written with x slowest, then z, then y, and
+// the dim line lists the sizes in that order (depth, height, width), matching
+// binvox and binvox-rw-py.
+type BinvoxExporterImpl struct {
+	Progress ProgressReporter // Optional progress callback
+}
+
+// NewBinvoxExporter creates a new binvox exporter.
+func NewBinvoxExporter() *BinvoxExporterImpl {
+	return &BinvoxExporterImpl{}
+}
+
+// Export writes a voxel grid to binvox format. The translate and scale lines
+// map the grid back to mesh space when it was voxelized from a mesh.
+func (e *BinvoxExporterImpl) Export(vg *VoxelGrid, w io.Writer) error {
+	extent := max(vg.SizeX, max(vg.SizeY, vg.SizeZ))
+	scale := float64(extent)
+	if vg.Scale > 0 {
+		scale /= vg.Scale
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#binvox 1\ndim %d %d %d\n", vg.SizeX, vg.SizeZ, vg.SizeY)
+	fmt.Fprintf(bw, "translate %g %g %g\nscale %g\ndata\n", vg.Origin[0], vg.Origin[1], vg.Origin[2], scale)
+
+	// Runs of one value, at most 255 long, may cross into the next column
+	value, run := byte(0), 0
+	flush := func() {
+		if run > 0 {
+			bw.Write([]byte{value, byte(run)})
+		}
+	}
+	tracker := startStage(e.Progress, StageExport, int64(vg.SizeX))
+	for x := 0; x < vg.SizeX; x++ {
+		for z := 0; z < vg.SizeZ; z++ {
+			for y := 0; y < vg.SizeY; y++ {
+				v := byte(0)
+				if vg.HasVoxel(x, y, z) {
+					v = 1
+				}
+				if v != value || run == 255 {
+					flush()
+					value, run = v, 0
+				}
+				run++
+			}
+		}
+		tracker.add(1)
+	}
+	flush()
+	tracker.finish()
+	return bw.Flush()
+}
+
+// BinvoxImporterImpl reads binvox files. Filled voxels take Color, since binvox
+// has none; translate and scale set the grid's Origin and Scale.
+type BinvoxImporterImpl struct {
+	Color [3]uint8 // Color of every imported voxel (NewBinvoxImporter sets light gray)
+}
+
+// NewBinvoxImporter creates a new binvox importer coloring voxels light gray.
+func NewBinvoxImporter() *BinvoxImporterImpl {
+	return &BinvoxImporterImpl{Color: [3]uint8{200, 200, 200}}
+}
+
+// Import reads a binvox file and returns a voxel grid.
+func (imp *BinvoxImporterImpl) Import(r io.Reader) (*VoxelGrid, error) {
+	br := bufio.NewReader(r)
+	offset := int64(0)
+	errorf := func(err error, format string, args ...interface{}) error {
+		return &FormatError{Format: "binvox", Offset: offset, Msg: fmt.Sprintf(format, args...), Err: err}
+	}
+	readLine := func() (string, error) {
+		line, err := br.ReadSlice('\n')
+		offset += int64(len(line))
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return "", errorf(nil, "header line too long")
+		}
+		if err != nil {
+			return "", errorf(io.ErrUnexpectedEOF, "truncated header")
+		}
+		return string(bytes.TrimSpace(line)), nil
+	}
+
+	line, err := readLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "#binvox") {
+		return nil, errorf(nil, "missing #binvox signature")
+	}
+	var dims [3]int // Depth (x), height (z), width (y)
+	var translate [3]float64
+	scale := 1.0
+	hasDims := false
+	for {
+		if line, err = readLine(); err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "data" {
+			break
+		}
+		switch fields[0] {
+		case "dim":
+			if len(fields) != 4 {
+				return nil, errorf(nil, "dim needs 3 values")
+			}
+			for i := range dims {
+				if dims[i], err = strconv.Atoi(fields[1+i]); err != nil || dims[i] <= 0 || dims[i] > binvoxMaxExtent {
+					return nil, errorf(nil, "invalid dimension %q", fields[1+i])
+				}
+			}
+			hasDims = true
+		case "translate":
+			if len(fields) != 4 {
+				return nil, errorf(nil, "translate needs 3 values")
+			}
+			for i := range translate {
+				if translate[i], err = strconv.ParseFloat(fields[1+i], 64); err != nil {
+					return nil, errorf(err, "invalid translation %q", fields[1+i])
+				}
+			}
+		case "scale":
+			if len(fields) != 2 {
+				return nil, errorf(nil, "scale needs 1 value")
+			}
+			if scale, err = strconv.ParseFloat(fields[1], 64); err != nil || scale <= 0 {
+				return nil, errorf(err, "invalid scale %q", fields[1])
+			}
+		default:
+			return nil, errorf(nil, "unknown header line %q", fields[0])
+		}
+	}
+	if !hasDims {
+		return nil, errorf(nil, "missing dim line")
+	}
+
+	sizeX, sizeY, sizeZ := dims[0], dims[2], dims[1]
+	vg := NewVoxelGridFor(sizeX, sizeY, sizeZ, 0, StorageConfig{})
+	vg.Origin = translate
+	vg.Scale = float64(max(sizeX, max(sizeY, sizeZ))) / scale
+
+	// Voxel i is at x = i / (width*height), z = i / width % height, y = i % width
+	total := int64(sizeX) * int64(sizeY) * int64(sizeZ)
+	var pair [2]byte
+	for i := int64(0); i < total; {
+		if _, err := io.ReadFull(br, pair[:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, errorf(err, "truncated voxel data")
+		}
+		offset += 2
+		value, run := pair[0], int64(pair[1])
+		if i+run > total {
+			return nil, errorf(nil, "run overflows the grid")
+		}
+		if value != 0 {
+			for j := i; j < i+run; j++ {
+				y := int(j % int64(sizeY))
+				z := int(j / int64(sizeY) % int64(sizeZ))
+				x := int(j / (int64(sizeY) * int64(sizeZ)))
+				vg.SetVoxel(x, y, z, imp.Color)
+			}
+		}
+		i += run
+	}
+	return vg, nil
+}
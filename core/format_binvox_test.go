@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBinvoxRoundTrip(t *testing.T) {
+	vg := NewVoxelGrid(3, 600, 2) // A column longer than one run
+	for y := 0; y < 600; y++ {
+		vg.SetVoxel(1, y, 1, [3]uint8{10, 20, 30})
+	}
+	vg.SetVoxel(2, 5, 0, [3]uint8{10, 20, 30})
+	vg.Scale, vg.Origin = 2, [3]float64{-1, 0.5, 3}
+
+	var buf bytes.Buffer
+	if err := NewBinvoxExporter().Export(vg, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "#binvox 1\ndim 3 2 600\ntranslate -1 0.5 3\nscale 300\ndata\n") {
+		t.Errorf("unexpected header:\n%s", buf.String()[:60])
+	}
+	got, err := NewBinvoxImporter().Import(&buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if got.SizeX != 3 || got.SizeY != 600 || got.SizeZ != 2 {
+		t.Fatalf("size = %dx%dx%d, want 3x600x2", got.SizeX, got.SizeY, got.SizeZ)
+	}
+	if got.Scale != 2 || got.Origin != vg.Origin {
+		t.Errorf("scale %v, origin %v; want 2, %v", got.Scale, got.Origin, vg.Origin)
+	}
+	if got.Count() != vg.Count() {
+		t.Errorf("imported %d voxels, want %d", got.Count(), vg.Count())
+	}
+	vg.Range(func(x, y, z int, _ [3]uint8) bool {
+		if c, ok := got.ColorAt(x, y, z); !ok || c != [3]uint8{200, 200, 200} {
+			t.Errorf("voxel (%d,%d,%d) = %v, %v; want light gray", x, y, z, c, ok)
+		}
+		return true
+	})
+}
+
+func TestBinvoxImportOrder(t *testing.T) {
+	// dim 2 3 4 is x=2, z=3, y=4; y runs fastest, then z
+	data := "#binvox 1\ndim 2 3 4\ntranslate 0 0 0\nscale 1\ndata\n" +
+		"\x00\x05\x01\x01\x00\x12"
+	vg, err := NewBinvoxImporter().Import(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if vg.SizeX != 2 || vg.SizeY != 4 || vg.SizeZ != 3 || vg.Count() != 1 {
+		t.Fatalf("size %dx%dx%d with %d voxels", vg.SizeX, vg.SizeY, vg.SizeZ, vg.Count())
+	}
+	if !vg.HasVoxel(0, 1, 1) { // Index 5 = z 1, y 1
+		t.Errorf("voxel 5 is not at (0,1,1)")
+	}
+}
+
+func TestBinvoxMalformed(t *testing.T) {
+	header := "#binvox 1\ndim 1 1 2\ndata\n"
+	tests := map[string]string{
+		"empty":     "",
+		"signature": "#vox 1\n",
+		"no dim":    "#binvox 1\ndata\n",
+		"bad dim":   "#binvox 1\ndim 1 0 1\ndata\n",
+		"scale":     "#binvox 1\ndim 1 1 1\nscale -2\ndata\n",
+		"unknown":   "#binvox 1\ncolor 1\ndata\n",
+		"truncated": header + "\x01\x01",
+		"overflow":  header + "\x01\x03",
+	}
+	for name, data := range tests {
+		_, err := NewBinvoxImporter().Import(strings.NewReader(data))
+		var formatErr *FormatError
+		if !errors.As(err, &formatErr) || formatErr.Format != "binvox" {
+			t.Errorf("%s: expected binvox FormatError, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import "sort"
+
+// CommandStats counts the blocks a command exporter placed and the commands it
+// took to place them.
+type CommandStats struct {
+	Blocks   int // Non-air blocks placed
+	Commands int // Commands placing them
+}
+
+// CommandReporter is implemented by exporters that write commands instead of
+// block data, such as FunctionExporterImpl and WorldEditExporterImpl.
+type CommandReporter interface {
+	// CommandStats returns the counts of the last export, or zeros before the first.
+	CommandStats() CommandStats
+}
+
+// blockCell is a non-air block of a grid, by index into a blockLookup.
+type blockCell struct {
+	pos   [3]int
+	block int32
+}
+
+// blockBox is an axis-aligned box of one block, both corners inclusive.
+type blockBox struct {
+	min, max [3]int
+	block    int32
+}
+
+// volume returns the number of blocks in the box.
+func (b blockBox) volume() int {
+	return (b.max[0] - b.min[0] + 1) * (b.max[1] - b.min[1] + 1) * (b.max[2] - b.min[2] + 1)
+}
+
+// collectBlockCells looks up the block of every voxel, skipping air, and sorts
+// them bottom up, then by z and x, so blocks that need support have it.
+func collectBlockCells(vg *VoxelGrid, lookup *blockLookup, progress ProgressReporter) []blockCell {
+	cells := make([]blockCell, 0, vg.Count())
+	tracker := startStage(progress, StageExport, int64(vg.Count()))
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		tracker.add(1)
+		if block := lookup.indexAt(vg, x, y, z, color); block != 0 {
+			cells = append(cells, blockCell{[3]int{x, y, z}, block})
+		}
+		return true
+	})
+	tracker.finish()
+	sort.Slice(cells, func(i, j int) bool {
+		a, b := cells[i].pos, cells[j].pos
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		if a[2] != b[2] {
+			return a[2] < b[2]
+		}
+		return a[0] < b[0]
+	})
+	return cells
+}
+
+// runBoxes merges runs of the same block along x, of at most maxVolume blocks,
+// into boxes. cells must be sorted as collectBlockCells sorts them.
+func runBoxes(cells []blockCell, maxVolume int) []blockBox {
+	var boxes []blockBox
+	for i := 0; i < len(cells); {
+		start := cells[i]
+		end := i + 1
+		for end < len(cells) && end-i < maxVolume {
+			next := cells[end]
+			if next.pos[1] != start.pos[1] || next.pos[2] != start.pos[2] || next.pos[0] != start.pos[0]+end-i || next.block != start.block {
+				break
+			}
+			end++
+		}
+		last := start.pos
+		last[0] += end - i - 1
+		boxes = append(boxes, blockBox{min: start.pos, max: last, block: start.block})
+		i = end
+	}
+	return boxes
+}
+
+// greedyBoxes covers the cells with boxes of one block each, of at most
+// maxVolume blocks (0 = unlimited), greedily: from each uncovered cell in order
+// a box grows along x, then z, then y as far as the same block continues.
+// cells must be sorted as collectBlockCells sorts them, and boxes come out in
+// the order of their first cell.
+func greedyBoxes(cells []blockCell, maxVolume int) []blockBox {
+	blocks := make(map[[3]int]int32, len(cells))
+	for _, c := range cells {
+		blocks[c.pos] = c.block
+	}
+	covered := make(map[[3]int]bool, len(cells))
+	// fits reports whether every cell from lo to hi holds block and is uncovered
+	fits := func(lo, hi [3]int, block int32) bool {
+		for x := lo[0]; x <= hi[0]; x++ {
+			for y := lo[1]; y <= hi[1]; y++ {
+				for z := lo[2]; z <= hi[2]; z++ {
+					p := [3]int{x, y, z}
+					if b, ok := blocks[p]; !ok || b != block || covered[p] {
+						return false
+					}
+				}
+			}
+		}
+		return true
+	}
+
+	var boxes []blockBox
+	for _, c := range cells {
+		if covered[c.pos] {
+			continue
+		}
+		box := blockBox{min: c.pos, max: c.pos, block: c.block}
+		for _, axis := range [3]int{0, 2, 1} {
+			for {
+				grown := box
+				grown.max[axis]++
+				if maxVolume > 0 && grown.volume() > maxVolume {
+					break
+				}
+				// Only the new slab needs checking
+				slab := grown.min
+				slab[axis] = grown.max[axis]
+				if !fits(slab, grown.max, c.block) {
+					break
+				}
+				box = grown
+			}
+		}
+		for x := box.min[0]; x <= box.max[0]; x++ {
+			for y := box.min[1]; y <= box.max[1]; y++ {
+				for z := box.min[2]; z <= box.max[2]; z++ {
+					covered[[3]int{x, y, z}] = true
+				}
+			}
+		}
+		boxes = append(boxes, box)
+	}
+	return boxes
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+// litematicMaxExtent bounds the blocks a Litematica schematic's regions may span
+// along each axis.
+const litematicMaxExtent = 1 << 16
+
+// LitematicImporterImpl reads Litematica schematics (.litematic). Every region is
+// placed at its position, and the grid spans them all, starting at their minimum
+// corner; where regions overlap, the later region by name wins.
+type LitematicImporterImpl struct {
+	// Palette colors imported blocks by block ID; blocks it does not list, or all
+	// blocks when it is nil, are imported gray.
+	Palette *Palette
+
+	materials *MaterialReport
+}
+
+// NewLitematicImporter creates a new Litematica schematic importer.
+func NewLitematicImporter() *LitematicImporterImpl {
+	return &LitematicImporterImpl{}
+}
+
+type litematicFile struct {
+	Version int32                      `nbt:"Version"`
+	Regions map[string]litematicRegion `nbt:"Regions"`
+}
+
+type litematicRegion struct {
+	Position          litematicVec          `nbt:"Position"`
+	Size              litematicVec          `nbt:"Size"`
+	BlockStatePalette []structureBlockState `nbt:"BlockStatePalette"`
+	BlockStates       []int64               `nbt:"BlockStates"`
+}
+
+type litematicVec struct {
+	X int32 `nbt:"x"`
+	Y int32 `nbt:"y"`
+	Z int32 `nbt:"z"`
+}
+
+// litematicBox is a region's block data with its minimum corner and size, both
+// positive along every axis.
+type litematicBox struct {
+	name   string
+	region litematicRegion
+	min    [3]int
+	size   [3]int
+	bits   int
+}
+
+// Import reads a Litematica schematic and returns a voxel grid.
+func (imp *LitematicImporterImpl) Import(r io.Reader) (*VoxelGrid, error) {
+	counter := &countingReader{r: r}
+	gzipReader, err := gzip.NewReader(counter)
+	if err != nil {
+		return nil, &FormatError{Format: "litematic", Offset: counter.n, Msg: "not gzip compressed", Err: err}
+	}
+	defer gzipReader.Close()
+
+	var file litematicFile
+	if _, err := nbt.NewDecoder(gzipReader).Decode(&file); err != nil {
+		return nil, &FormatError{Format: "litematic", Offset: -1, Msg: "failed to decode NBT", Err: err}
+	}
+	if len(file.Regions) == 0 {
+		return nil, &FormatError{Format: "litematic", Offset: -1, Msg: "no regions"}
+	}
+
+	// A negative size extends the region from its position toward lower
+	// coordinates; block data always starts at the minimum corner
+	var boxes []litematicBox
+	lo := [3]int{math.MaxInt, math.MaxInt, math.MaxInt}
+	hi := [3]int{math.MinInt, math.MinInt, math.MinInt}
+	for _, name := range sortedKeys(file.Regions) {
+		region := file.Regions[name]
+		box := litematicBox{name: name, region: region}
+		pos := [3]int32{region.Position.X, region.Position.Y, region.Position.Z}
+		for axis, size := range [3]int32{region.Size.X, region.Size.Y, region.Size.Z} {
+			box.min[axis], box.size[axis] = int(pos[axis]), int(size)
+			if size < 0 {
+				box.min[axis], box.size[axis] = int(pos[axis])+int(size)+1, -int(size)
+			}
+			if box.size[axis] == 0 || box.size[axis] > litematicMaxExtent {
+				return nil, &FormatError{Format: "litematic", Offset: -1, Msg: fmt.Sprintf("region %q has size %d along axis %d", name, size, axis)}
+			}
+			lo[axis] = min(lo[axis], box.min[axis])
+			hi[axis] = max(hi[axis], box.min[axis]+box.size[axis])
+		}
+		if len(region.BlockStatePalette) == 0 {
+			return nil, &FormatError{Format: "litematic", Offset: -1, Msg: fmt.Sprintf("region %q has no block palette", name)}
+		}
+		box.bits = max(2, bits.Len(uint(len(region.BlockStatePalette)-1)))
+		volume := int64(box.size[0]) * int64(box.size[1]) * int64(box.size[2])
+		if need := (volume*int64(box.bits) + 63) / 64; int64(len(region.BlockStates)) < need {
+			return nil, &FormatError{Format: "litematic", Offset: -1, Msg: fmt.Sprintf("region %q has %d of %d block state longs", name, len(region.BlockStates), need)}
+		}
+		boxes = append(boxes, box)
+	}
+	for axis := 0; axis < 3; axis++ {
+		if hi[axis]-lo[axis] > litematicMaxExtent {
+			return nil, &FormatError{Format: "litematic", Offset: -1, Msg: fmt.Sprintf("regions span %d blocks along axis %d", hi[axis]-lo[axis], axis)}
+		}
+	}
+
+	// Count the blocks, checking every palette index, to size the grid and
+	// report the materials
+	var ids []string
+	var counts []int
+	index := make(map[string]int)
+	filled := int64(0)
+	for _, box := range boxes {
+		palette := box.region.BlockStatePalette
+		used := make([]int, len(palette))
+		if err := box.rangeStates(func(_, _, _ int, state int) {
+			used[state]++
+		}); err != nil {
+			return nil, err
+		}
+		for state, n := range used {
+			id := blockStateKey(palette[state])
+			if n == 0 || isAirBlock(palette[state].Name) {
+				continue
+			}
+			i, ok := index[id]
+			if !ok {
+				i = len(ids)
+				index[id] = i
+				ids = append(ids, id)
+				counts = append(counts, 0)
+			}
+			counts[i] += n
+			filled += int64(n)
+		}
+	}
+	imp.materials = newMaterialReport(ids, counts)
+
+	vg := NewVoxelGridFor(hi[0]-lo[0], hi[1]-lo[1], hi[2]-lo[2], filled, StorageConfig{})
+	colors := blockColors(imp.Palette)
+	for _, box := range boxes {
+		palette := box.region.BlockStatePalette
+		stateColors := make([][3]uint8, len(palette))
+		for state := range palette {
+			stateColors[state] = blockColor(colors, blockStateKey(palette[state]))
+		}
+		box.rangeStates(func(x, y, z int, state int) {
+			if !isAirBlock(palette[state].Name) {
+				vg.SetVoxel(box.min[0]-lo[0]+x, box.min[1]-lo[1]+y, box.min[2]-lo[2]+z, stateColors[state])
+			}
+		})
+	}
+	return vg, nil
+}
+
+// Materials returns the blocks of the last imported schematic with their counts,
+// or nil before the first import.
+func (imp *LitematicImporterImpl) Materials() *MaterialReport {
+	return imp.materials
+}
+
+// rangeStates calls fn with the palette index of every block in the box, at its
+// position from the box's minimum corner. Block data runs along x, then z, then
+// y, and entries are packed tightly, spanning two longs where they cross one.
+func (b *litematicBox) rangeStates(fn func(x, y, z int, state int)) error {
+	longs := b.region.BlockStates
+	mask := uint64(1)<<b.bits - 1
+	i := 0
+	for y := 0; y < b.size[1]; y++ {
+		for z := 0; z < b.size[2]; z++ {
+			for x := 0; x < b.size[0]; x++ {
+				start := i * b.bits
+				word, offset := start/64, start%64
+				v := uint64(longs[word]) >> offset
+				if offset+b.bits > 64 {
+					v |= uint64(longs[word+1]) << (64 - offset)
+				}
+				state := int(v & mask)
+				if state >= len(b.region.BlockStatePalette) {
+					return &FormatError{Format: "litematic", Offset: -1, Msg: fmt.Sprintf("region %q: block %d uses palette index %d of %d", b.name, i, state, len(b.region.BlockStatePalette))}
+				}
+				fn(x, y, z, state)
+				i++
+			}
+		}
+	}
+	return nil
+}
+
+// isAirBlock reports whether a block name is one of the kinds of air.
+func isAirBlock(name string) bool {
+	switch name {
+	case "minecraft:air", "minecraft:cave_air", "minecraft:void_air":
+		return true
+	}
+	return false
+}
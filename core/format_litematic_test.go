@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"math/bits"
+	"testing"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+// packLitematic packs palette indices as Litematica does, spanning longs.
+func packLitematic(states []int, paletteLen int) []int64 {
+	n := max(2, bits.Len(uint(paletteLen-1)))
+	longs := make([]int64, (len(states)*n+63)/64)
+	for i, state := range states {
+		start := i * n
+		longs[start/64] |= int64(uint64(state) << (start % 64))
+		if start%64+n > 64 {
+			longs[start/64+1] |= int64(uint64(state) >> (64 - start%64))
+		}
+	}
+	return longs
+}
+
+// encodeLitematic returns a gzipped .litematic file holding regions.
+func encodeLitematic(t *testing.T, regions map[string]litematicRegion) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := nbt.NewEncoder(gz).Encode(litematicFile{Version: 6, Regions: regions}, ""); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestLitematicImport(t *testing.T) {
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:stone", RGB: [3]uint8{120, 120, 120}},
+		{ID: "minecraft:oak_stairs[facing=east,half=bottom]", RGB: [3]uint8{160, 128, 76}},
+	})
+	// A 3x2x4 region whose palette needs three bits, so entry 21 crosses into
+	// the second long, and a 2x1x1 region extending toward -x from (0, 0, 0)
+	blockPalette := []structureBlockState{
+		{Name: "minecraft:air"},
+		{Name: "minecraft:stone"},
+		{Name: "minecraft:oak_stairs", Properties: sortedCompound[string]{"half": "bottom", "facing": "east"}},
+		{Name: "minecraft:red_wool"},
+		{Name: "minecraft:cave_air"},
+	}
+	// Runs along x, then z, then y
+	states := []int{
+		1, 0, 2, 4, 0, 0, 0, 0, 0, 0, 0, 0,
+		3, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0,
+	}
+	data := encodeLitematic(t, map[string]litematicRegion{
+		"main": {
+			Position:          litematicVec{0, 0, 0},
+			Size:              litematicVec{3, 2, 4},
+			BlockStatePalette: blockPalette,
+			BlockStates:       packLitematic(states, len(blockPalette)),
+		},
+		"wing": {
+			Position:          litematicVec{0, 0, 0},
+			Size:              litematicVec{-2, 1, 1},
+			BlockStatePalette: blockPalette[:2],
+			BlockStates:       packLitematic([]int{1, 0}, 2),
+		},
+	})
+
+	importer := &LitematicImporterImpl{Palette: palette}
+	vg, err := importer.Import(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if vg.SizeX != 4 || vg.SizeY != 2 || vg.SizeZ != 4 {
+		t.Fatalf("size = %dx%dx%d, want 4x2x4", vg.SizeX, vg.SizeY, vg.SizeZ)
+	}
+	stone, stairs, gray := [3]uint8{120, 120, 120}, [3]uint8{160, 128, 76}, [3]uint8{128, 128, 128}
+	want := map[[3]int][3]uint8{
+		{0, 0, 0}: stone,  // The wing's first block, at x = -1
+		{1, 0, 0}: stone,  // Main (0, 0, 0); the wing's air does not clear it
+		{3, 0, 0}: stairs, // Properties in another order still match
+		{1, 1, 0}: gray,   // Wool is not in the palette
+		{3, 1, 1}: stone,
+		{1, 1, 3}: stairs, // Split between two longs
+	}
+	if vg.Count() != len(want) {
+		t.Errorf("%d voxels, want %d", vg.Count(), len(want))
+	}
+	for pos, color := range want {
+		if got, ok := vg.ColorAt(pos[0], pos[1], pos[2]); !ok || got != color {
+			t.Errorf("voxel %v = %v, %v; want %v", pos, got, ok, color)
+		}
+	}
+	report := importer.Materials()
+	if report.Total != 6 || report.Materials[0].Block != "minecraft:stone" || report.Materials[0].Count != 3 {
+		t.Errorf("materials = %+v, want 3 stone of 6 blocks first", report)
+	}
+
+	var formatErr *FormatError
+	if _, err := NewLitematicImporter().Import(bytes.NewReader([]byte("not gzip"))); !errors.As(err, &formatErr) || formatErr.Format != "litematic" {
+		t.Errorf("expected litematic FormatError for non-gzip input, got %v", err)
+	}
+	for name, region := range map[string]litematicRegion{
+		"short data": {Size: litematicVec{4, 4, 4}, BlockStatePalette: blockPalette, BlockStates: make([]int64, 2)},
+		"bad index":  {Size: litematicVec{1, 1, 1}, BlockStatePalette: blockPalette[:2], BlockStates: []int64{3}},
+		"empty size": {Size: litematicVec{0, 1, 1}, BlockStatePalette: blockPalette, BlockStates: []int64{0}},
+	} {
+		data := encodeLitematic(t, map[string]litematicRegion{"r": region})
+		if _, err := NewLitematicImporter().Import(bytes.NewReader(data)); !errors.As(err, &formatErr) {
+			t.Errorf("%s: expected FormatError, got %v", name, err)
+		}
+	}
+}
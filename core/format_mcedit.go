@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+// MCEditExporterImpl implements MCEditExporter for the classic MCEdit schematic
+// format read by Minecraft 1.12 and earlier tools. Modern block IDs are mapped to
+// numeric id:data pairs with a bundled table, and colors are only matched against
+// palette entries that have a mapping. The lookup is kept across exports with the
+// same palette, and an exporter must not be used by several goroutines at once.
+type MCEditExporterImpl struct {
+	Matcher     ColorMatcher     // Matches colors that are not palette entries (default: CIELAB)
+	AirMode     string           // How empty cells are written: air (default) or, with AirModeVoid, structure voids
+	PasteOrigin string           // Where the grid lands when pasted, such as PasteOriginCenterBottom (default PasteOriginCorner)
+	Offset      [3]int           // Minimum corner relative to the paste position with PasteOriginCorner
+	Progress    ProgressReporter // Optional progress callback
+
+	source *Palette      // Palette passed to the last export
+	legacy *Palette      // Entries of source with a legacy mapping
+	blocks []legacyBlock // Legacy block for each lookup index
+	lookup blockLookup
+}
+
+// legacyStructureVoid is the 1.12 block ID of minecraft:structure_void.
+const legacyStructureVoid = 217
+
+// NewMCEditExporter creates a new MCEdit schematic exporter.
+func NewMCEditExporter() *MCEditExporterImpl {
+	return &MCEditExporterImpl{}
+}
+
+// Export writes a voxel grid as a gzip-compressed MCEdit schematic.
+func (e *MCEditExporterImpl) Export(vg *VoxelGrid, palette *Palette, w io.Writer) error {
+	if err := checkAirMode(e.AirMode); err != nil {
+		return err
+	}
+	offset, err := pasteOffset([3]int{vg.SizeX, vg.SizeY, vg.SizeZ}, e.PasteOrigin, e.Offset)
+	if err != nil {
+		return err
+	}
+	if err := e.preparePalette(palette); err != nil {
+		return err
+	}
+
+	cells := vg.SizeX * vg.SizeY * vg.SizeZ
+	blocks := make([]byte, cells)
+	data := make([]byte, cells)
+	if e.AirMode == AirModeVoid {
+		for i := range blocks {
+			blocks[i] = legacyStructureVoid
+		}
+	}
+	tracker := startStage(e.Progress, StageExport, int64(vg.Count()))
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		tracker.add(1)
+		block := e.blocks[e.lookup.index(color)]
+		i := schematicIndex(x, y, z, vg.SizeX, vg.SizeZ)
+		blocks[i], data[i] = block.ID, block.Data
+		return true
+	})
+	tracker.finish()
+
+	schematic := sortedCompound[interface{}]{
+		"Width":        int16(vg.SizeX),
+		"Height":       int16(vg.SizeY),
+		"Length":       int16(vg.SizeZ),
+		"Materials":    "Alpha",
+		"Blocks":       blocks,
+		"Data":         data,
+		"Entities":     []map[string]interface{}{},
+		"TileEntities": []map[string]interface{}{},
+		"WEOffsetX":    int32(offset[0]),
+		"WEOffsetY":    int32(offset[1]),
+		"WEOffsetZ":    int32(offset[2]),
+	}
+
+	buf := getScratchBuffer()
+	defer putScratchBuffer(buf)
+	if err := nbt.NewEncoder(buf).Encode(schematic, "Schematic"); err != nil {
+		return fmt.Errorf("failed to encode NBT: %w", err)
+	}
+
+	gzipWriter := getGzipWriter(w)
+	defer putGzipWriter(gzipWriter)
+	if _, err := gzipWriter.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to compress schematic: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to compress schematic: %w", err)
+	}
+	return nil
+}
+
+// preparePalette restricts palette to the blocks with a legacy mapping and builds
+// the lookup for it, reusing the previous tables when the palette has not changed.
+func (e *MCEditExporterImpl) preparePalette(palette *Palette) error {
+	if e.blocks != nil && e.source == palette {
+		return nil
+	}
+	e.source, e.legacy = palette, nil
+	if palette != nil {
+		e.legacy = &Palette{}
+		for _, color := range palette.Colors {
+			if _, ok := legacyBlockFor(paletteBlockID(&color)); ok {
+				e.legacy.Colors = append(e.legacy.Colors, color)
+			}
+		}
+		if len(e.legacy.Colors) == 0 {
+			e.blocks = nil
+			return fmt.Errorf("%w: no palette block exists in Minecraft 1.12", ErrInvalidConfig)
+		}
+	}
+
+	e.lookup.prepare(e.legacy, e.Matcher, DetailNone)
+	ids := e.lookup.blockIDs()
+	e.blocks = make([]legacyBlock, len(ids))
+	for i, id := range ids {
+		e.blocks[i], _ = legacyBlockFor(id)
+	}
+	return nil
+}
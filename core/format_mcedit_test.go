@@ -0,0 +1,101 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMCEditExport(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{240, 118, 19}, Metadata: map[string]interface{}{"block_id": "minecraft:orange_wool"}},
+		{RGB: [3]uint8{100, 80, 50}, Metadata: map[string]interface{}{"block_id": "minecraft:spruce_log[axis=y]"}},
+		{RGB: [3]uint8{200, 200, 200}, Metadata: map[string]interface{}{"block_id": "minecraft:light_gray_concrete"}},
+		// Added after 1.12, so never used
+		{RGB: [3]uint8{10, 10, 10}, Metadata: map[string]interface{}{"block_id": "minecraft:blackstone"}},
+	}}
+	for i := range palette.Colors {
+		palette.Colors[i].LAB = RGBToLAB(palette.Colors[i].RGB)
+	}
+	vg := NewVoxelGrid(2, 3, 2)
+	vg.SetVoxel(0, 0, 0, [3]uint8{240, 118, 19})
+	vg.SetVoxel(1, 2, 1, [3]uint8{100, 80, 50})
+	vg.SetVoxel(1, 0, 1, [3]uint8{200, 200, 200})
+	vg.SetVoxel(0, 1, 0, [3]uint8{10, 10, 10})
+
+	var buf bytes.Buffer
+	if err := NewMCEditExporter().Export(vg, palette, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	schematic := decodeSchematic(t, buf.Bytes())
+	if schematic["Materials"] != "Alpha" {
+		t.Errorf("Materials = %v, want Alpha", schematic["Materials"])
+	}
+	blocks, _ := schematic["Blocks"].([]byte)
+	data, _ := schematic["Data"].([]byte)
+	if len(blocks) != 12 || len(data) != 12 {
+		t.Fatalf("len(Blocks), len(Data) = %d, %d; want 12", len(blocks), len(data))
+	}
+
+	tests := []struct {
+		x, y, z  int
+		id, data byte
+	}{
+		{0, 0, 0, 35, 1},  // orange wool
+		{1, 2, 1, 17, 1},  // spruce log
+		{1, 0, 1, 251, 8}, // light gray concrete
+		{0, 1, 0, 17, 1},  // blackstone's color falls back to the darkest 1.12 block
+		{1, 1, 0, 0, 0},   // air
+	}
+	for _, tt := range tests {
+		i := schematicIndex(tt.x, tt.y, tt.z, 2, 2)
+		if blocks[i] != tt.id || data[i] != tt.data {
+			t.Errorf("block (%d,%d,%d) = %d:%d, want %d:%d", tt.x, tt.y, tt.z, blocks[i], data[i], tt.id, tt.data)
+		}
+	}
+}
+
+func TestMCEditNoLegacyBlocks(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{10, 10, 10}, Metadata: map[string]interface{}{"block_id": "minecraft:blackstone"}},
+	}}
+	err := NewMCEditExporter().Export(NewVoxelGrid(1, 1, 1), palette, &bytes.Buffer{})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestMCEditStructureVoid(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{240, 118, 19}, Metadata: map[string]interface{}{"block_id": "minecraft:orange_wool"}},
+	}}
+	vg := NewVoxelGrid(2, 1, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{240, 118, 19})
+
+	exporter := NewMCEditExporter()
+	exporter.AirMode = AirModeVoid
+	var buf bytes.Buffer
+	if err := exporter.Export(vg, palette, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	blocks, _ := decodeSchematic(t, buf.Bytes())["Blocks"].([]byte)
+	if len(blocks) != 2 || blocks[0] != 35 || blocks[1] != legacyStructureVoid {
+		t.Errorf("Blocks = %v, want [35 %d]", blocks, legacyStructureVoid)
+	}
+}
+
+func TestMCEditPasteOffset(t *testing.T) {
+	vg := NewVoxelGrid(4, 2, 6)
+	vg.SetVoxel(0, 0, 0, [3]uint8{128, 128, 128})
+
+	exporter := NewMCEditExporter()
+	exporter.PasteOrigin = PasteOriginCenterBottom
+	var buf bytes.Buffer
+	if err := exporter.Export(vg, nil, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	schematic := decodeSchematic(t, buf.Bytes())
+	if schematic["WEOffsetX"] != int32(-2) || schematic["WEOffsetY"] != int32(0) || schematic["WEOffsetZ"] != int32(-3) {
+		t.Errorf("WEOffset = %v, %v, %v; want -2, 0, -3", schematic["WEOffsetX"], schematic["WEOffsetY"], schematic["WEOffsetZ"])
+	}
+}
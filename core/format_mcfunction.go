@@ -0,0 +1,191 @@
+package core
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Defaults used when FunctionExporterImpl fields are zero.
+const (
+	defaultFunctionNamespace = "poly2block"
+	defaultFunctionCommands  = 32768 // Half of the default maxCommandChainLength game rule
+	defaultPackFormat        = 48    // Minecraft 1.21
+	maxFillVolume            = 32768 // Largest region a single fill command accepts
+)
+
+// validNamespace matches the characters Minecraft allows in a resource namespace.
+var validNamespace = regexp.MustCompile(`^[a-z0-9_.-]+$`)
+
+// FunctionExporterImpl writes a voxel grid as setblock and fill commands, either as
+// a single .mcfunction file or as a datapack whose functions are split to stay
+// under the per-tick command limit and chained with schedule. Positions are
+// relative to where the function runs. Like SchematicExporterImpl, the block
+// lookup is kept across exports with the same palette, and an exporter must not be
+// used by several goroutines at once.
+type FunctionExporterImpl struct {
+	Namespace   string           // Datapack namespace (default "poly2block")
+	MaxCommands int              // Commands per function (default 32768)
+	PackFormat  int              // Datapack pack_format (default 48, Minecraft 1.21)
+	Matcher     ColorMatcher     // Matches colors that are not palette entries (default: CIELAB)
+	Detail      string           // Surface detail mode, such as DetailStairsSlabs (default DetailNone)
+	Boxes       bool             // Merge blocks into the largest boxes (greedy meshing) rather than runs along x
+	Progress    ProgressReporter // Optional progress callback
+
+	lookup blockLookup
+	stats  CommandStats
+}
+
+// NewFunctionExporter creates a new command exporter.
+func NewFunctionExporter() *FunctionExporterImpl {
+	return &FunctionExporterImpl{}
+}
+
+// Commands returns setblock and fill commands that build the grid relative to the
+// executing position, bottom up. Runs of the same block along x are merged into
+// one fill, or with Boxes, boxes of the same block of up to 32768 blocks.
+func (e *FunctionExporterImpl) Commands(vg *VoxelGrid, palette *Palette) []string {
+	e.lookup.prepare(palette, e.Matcher, e.Detail)
+	blockIDs := e.lookup.blockIDs()
+
+	cells := collectBlockCells(vg, &e.lookup, e.Progress)
+	var boxes []blockBox
+	if e.Boxes {
+		boxes = greedyBoxes(cells, maxFillVolume)
+	} else {
+		boxes = runBoxes(cells, maxFillVolume)
+	}
+
+	commands := make([]string, len(boxes))
+	for i, box := range boxes {
+		lo, hi := box.min, box.max
+		if lo == hi {
+			commands[i] = fmt.Sprintf("setblock ~%d ~%d ~%d %s", lo[0], lo[1], lo[2], blockIDs[box.block])
+		} else {
+			commands[i] = fmt.Sprintf("fill ~%d ~%d ~%d ~%d ~%d ~%d %s",
+				lo[0], lo[1], lo[2], hi[0], hi[1], hi[2], blockIDs[box.block])
+		}
+	}
+	e.stats = CommandStats{Blocks: len(cells), Commands: len(commands)}
+	return commands
+}
+
+// CommandStats returns the block and command counts of the last Commands call.
+func (e *FunctionExporterImpl) CommandStats() CommandStats {
+	return e.stats
+}
+
+// Export writes the commands as a single .mcfunction file. Grids needing more than
+// MaxCommands commands must be exported as a datapack with ExportDatapack.
+func (e *FunctionExporterImpl) Export(vg *VoxelGrid, palette *Palette, w io.Writer) error {
+	commands := e.Commands(vg, palette)
+	if limit := e.maxCommands(); len(commands) > limit {
+		return fmt.Errorf("%w: %d commands exceed the %d command function limit; export a datapack instead",
+			ErrGridTooLarge, len(commands), limit)
+	}
+	return writeFunction(w, commands)
+}
+
+// ExportDatapack writes a datapack zip. Running the function <namespace>:build
+// places the grid at the executing position: a marker entity remembers the origin
+// and each part of the build is scheduled one tick after the previous one.
+func (e *FunctionExporterImpl) ExportDatapack(vg *VoxelGrid, palette *Palette, w io.Writer) error {
+	namespace := e.Namespace
+	if namespace == "" {
+		namespace = defaultFunctionNamespace
+	}
+	if !validNamespace.MatchString(namespace) {
+		return fmt.Errorf("%w: invalid datapack namespace %q", ErrInvalidConfig, namespace)
+	}
+	packFormat := e.PackFormat
+	if packFormat == 0 {
+		packFormat = defaultPackFormat
+	}
+	// Datapacks before 1.21 (pack format 45) use a plural directory name
+	functionDir := "data/" + namespace + "/function/"
+	if packFormat < 45 {
+		functionDir = "data/" + namespace + "/functions/"
+	}
+
+	commands := e.Commands(vg, palette)
+	limit := e.maxCommands()
+	parts := (len(commands) + limit - 1) / limit
+
+	tag := "p2b_" + strings.NewReplacer(".", "_", "-", "_").Replace(namespace)
+	marker := fmt.Sprintf("@e[type=minecraft:marker,tag=%s,limit=1]", tag)
+	files := []functionFile{
+		{"build", []string{
+			fmt.Sprintf("kill @e[type=minecraft:marker,tag=%s]", tag),
+			fmt.Sprintf("summon minecraft:marker ~ ~ ~ {Tags:[%q]}", tag),
+			fmt.Sprintf("function %s:step_0", namespace),
+		}},
+	}
+	for i := 0; i < parts; i++ {
+		end := (i + 1) * limit
+		if end > len(commands) {
+			end = len(commands)
+		}
+		files = append(files, functionFile{fmt.Sprintf("part_%d", i), commands[i*limit : end]})
+	}
+	for i := 0; i <= parts; i++ {
+		var step []string
+		if i < parts {
+			step = []string{
+				fmt.Sprintf("execute at %s run function %s:part_%d", marker, namespace, i),
+				fmt.Sprintf("schedule function %s:step_%d 1t", namespace, i+1),
+			}
+		} else {
+			step = []string{fmt.Sprintf("kill %s", marker)}
+		}
+		files = append(files, functionFile{fmt.Sprintf("step_%d", i), step})
+	}
+
+	archive := zip.NewWriter(w)
+	mcmeta, err := archive.Create("pack.mcmeta")
+	if err != nil {
+		return fmt.Errorf("failed to write datapack: %w", err)
+	}
+	fmt.Fprintf(mcmeta, "{\"pack\": {\"pack_format\": %d, \"description\": \"Built by poly2block\"}}\n", packFormat)
+	for _, file := range files {
+		f, err := archive.Create(functionDir + file.name + ".mcfunction")
+		if err != nil {
+			return fmt.Errorf("failed to write datapack: %w", err)
+		}
+		if err := writeFunction(f, file.commands); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write datapack: %w", err)
+	}
+	return nil
+}
+
+// functionFile is a function in a datapack.
+type functionFile struct {
+	name     string
+	commands []string
+}
+
+func (e *FunctionExporterImpl) maxCommands() int {
+	if e.MaxCommands > 0 {
+		return e.MaxCommands
+	}
+	return defaultFunctionCommands
+}
+
+// writeFunction writes commands one per line.
+func writeFunction(w io.Writer, commands []string) error {
+	buf := getScratchBuffer()
+	defer putScratchBuffer(buf)
+	for _, command := range commands {
+		buf.WriteString(command)
+		buf.WriteByte('\n')
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write function: %w", err)
+	}
+	return nil
+}
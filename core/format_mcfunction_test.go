@@ -0,0 +1,176 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFunctionCommands(t *testing.T) {
+	vg := NewVoxelGrid(4, 2, 1)
+	for x := 0; x < 3; x++ {
+		vg.SetVoxel(x, 0, 0, [3]uint8{1, 1, 1})
+	}
+	vg.SetVoxel(2, 1, 0, [3]uint8{1, 1, 1})
+
+	var buf bytes.Buffer
+	if err := NewFunctionExporter().Export(vg, nil, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	want := "fill ~0 ~0 ~0 ~2 ~0 ~0 minecraft:white_concrete\n" +
+		"setblock ~2 ~1 ~0 minecraft:white_concrete\n"
+	if buf.String() != want {
+		t.Errorf("function =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestFunctionCommandLimit(t *testing.T) {
+	vg := NewVoxelGrid(5, 1, 5)
+	for z := 0; z < 5; z++ {
+		vg.SetVoxel(0, 0, z, [3]uint8{1, 1, 1})
+	}
+	exporter := NewFunctionExporter()
+	exporter.MaxCommands = 2
+	if err := exporter.Export(vg, nil, &bytes.Buffer{}); !errors.Is(err, ErrGridTooLarge) {
+		t.Errorf("expected ErrGridTooLarge, got %v", err)
+	}
+}
+
+func TestFunctionDatapack(t *testing.T) {
+	vg := NewVoxelGrid(5, 1, 5)
+	for z := 0; z < 5; z++ {
+		vg.SetVoxel(0, 0, z, [3]uint8{1, 1, 1})
+	}
+	exporter := NewFunctionExporter()
+	exporter.Namespace = "castle"
+	exporter.MaxCommands = 2
+
+	var buf bytes.Buffer
+	if err := exporter.ExportDatapack(vg, nil, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		r, _ := f.Open()
+		data, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(data)
+	}
+
+	if !strings.Contains(files["pack.mcmeta"], `"pack_format": 48`) {
+		t.Errorf("pack.mcmeta = %q", files["pack.mcmeta"])
+	}
+	// 5 commands in parts of 2, and a step per part plus the final cleanup
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	for _, name := range []string{"build", "part_0", "part_1", "part_2", "step_0", "step_1", "step_2", "step_3"} {
+		if _, ok := files["data/castle/function/"+name+".mcfunction"]; !ok {
+			t.Errorf("missing function %s in %v", name, names)
+		}
+	}
+	if _, ok := files["data/castle/function/part_3.mcfunction"]; ok {
+		t.Error("unexpected part_3")
+	}
+
+	var placed []string
+	for _, part := range []string{"part_0", "part_1", "part_2"} {
+		placed = append(placed, strings.Fields(files["data/castle/function/"+part+".mcfunction"])...)
+	}
+	commands := strings.Fields(strings.Join(exporter.Commands(vg, nil), "\n"))
+	if !reflect.DeepEqual(placed, commands) {
+		t.Errorf("parts hold %v, want %v", placed, commands)
+	}
+	if step := files["data/castle/function/step_1.mcfunction"]; !strings.Contains(step, "run function castle:part_1") ||
+		!strings.Contains(step, "schedule function castle:step_2 1t") {
+		t.Errorf("step_1 = %q", step)
+	}
+}
+
+func TestFunctionDatapackNamespace(t *testing.T) {
+	exporter := NewFunctionExporter()
+	exporter.Namespace = "Bad Name"
+	err := exporter.ExportDatapack(NewVoxelGrid(1, 1, 1), nil, &bytes.Buffer{})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestFunctionBoxes(t *testing.T) {
+	vg := NewVoxelGrid(3, 3, 2)
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 2; y++ {
+			for z := 0; z < 2; z++ {
+				vg.SetVoxel(x, y, z, [3]uint8{1, 1, 1})
+			}
+		}
+	}
+	vg.SetVoxel(1, 2, 1, [3]uint8{1, 1, 1})
+
+	exporter := NewFunctionExporter()
+	if got := len(exporter.Commands(vg, nil)); got != 5 {
+		t.Errorf("runs along x: %d commands, want 5", got)
+	}
+	exporter.Boxes = true
+	want := []string{
+		"fill ~0 ~0 ~0 ~2 ~1 ~1 minecraft:white_concrete",
+		"setblock ~1 ~2 ~1 minecraft:white_concrete",
+	}
+	if got := exporter.Commands(vg, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("boxes = %q, want %q", got, want)
+	}
+	if stats := exporter.CommandStats(); stats != (CommandStats{Blocks: 13, Commands: 2}) {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestGreedyBoxesCoverCells(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var cells []blockCell
+	for y := 0; y < 6; y++ {
+		for z := 0; z < 6; z++ {
+			for x := 0; x < 6; x++ {
+				if r.Intn(4) > 0 {
+					cells = append(cells, blockCell{[3]int{x, y, z}, int32(1 + r.Intn(2))})
+				}
+			}
+		}
+	}
+	blocks := make(map[[3]int]int32)
+	for _, c := range cells {
+		blocks[c.pos] = c.block
+	}
+
+	for _, limit := range []int{0, 4} {
+		covered := make(map[[3]int]bool)
+		for _, box := range greedyBoxes(cells, limit) {
+			if limit > 0 && box.volume() > limit {
+				t.Errorf("box %v exceeds %d blocks", box, limit)
+			}
+			for x := box.min[0]; x <= box.max[0]; x++ {
+				for y := box.min[1]; y <= box.max[1]; y++ {
+					for z := box.min[2]; z <= box.max[2]; z++ {
+						p := [3]int{x, y, z}
+						if blocks[p] != box.block || covered[p] {
+							t.Fatalf("limit %d: box %v covers %v wrongly", limit, box, p)
+						}
+						covered[p] = true
+					}
+				}
+			}
+		}
+		if len(covered) != len(cells) {
+			t.Errorf("limit %d: boxes cover %d of %d cells", limit, len(covered), len(cells))
+		}
+	}
+}
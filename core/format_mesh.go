@@ -0,0 +1,157 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// OBJExporterImpl writes a voxel grid as a greedy-meshed Wavefront OBJ file for
+// previewing in 3D viewers. Colors are written as vertex colors after each vertex
+// position, an extension read by Blender, MeshLab and most other tools, so no
+// material library is needed.
+type OBJExporterImpl struct {
+	Progress ProgressReporter // Optional progress callback
+}
+
+// NewOBJExporter creates a new OBJ exporter.
+func NewOBJExporter() *OBJExporterImpl {
+	return &OBJExporterImpl{}
+}
+
+// Export writes the grid's visible faces as OBJ quads. See GreedyMesh.
+func (e *OBJExporterImpl) Export(vg *VoxelGrid, w io.Writer) error {
+	return e.ExportMesh(greedyMesh(vg, e.Progress), w)
+}
+
+// ExportMesh writes a mesh built by GreedyMesh, or any mesh with vertex colors and
+// normals, as an OBJ file.
+func (e *OBJExporterImpl) ExportMesh(mesh *Mesh, w io.Writer) error {
+	buf := getScratchBuffer()
+	defer putScratchBuffer(buf)
+	buf.WriteString("# Exported by poly2block\n")
+
+	var line []byte
+	for _, v := range mesh.Vertices {
+		line = append(line[:0], 'v')
+		for _, f := range v.Position {
+			line = append(line, ' ')
+			line = strconv.AppendFloat(line, f, 'g', -1, 64)
+		}
+		if mesh.HasVertexColors {
+			for _, f := range v.Color {
+				line = append(line, ' ')
+				line = strconv.AppendFloat(line, f, 'f', 4, 64)
+			}
+		}
+		line = append(line, '\n')
+		buf.Write(line)
+	}
+
+	// Faces share the normals of the six axis directions and any others found
+	normals := make(map[[3]float64]int)
+	for _, v := range mesh.Vertices {
+		if _, ok := normals[v.Normal]; !ok {
+			normals[v.Normal] = len(normals) + 1
+			fmt.Fprintf(buf, "vn %g %g %g\n", v.Normal[0], v.Normal[1], v.Normal[2])
+		}
+	}
+	for _, face := range mesh.Faces {
+		line = append(line[:0], 'f')
+		for _, i := range face.VertexIndices {
+			line = append(line, ' ')
+			line = strconv.AppendInt(line, int64(i+1), 10)
+			line = append(line, '/', '/')
+			line = strconv.AppendInt(line, int64(normals[mesh.Vertices[i].Normal]), 10)
+		}
+		line = append(line, '\n')
+		buf.Write(line)
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write OBJ: %w", err)
+	}
+	return nil
+}
+
+// GLTFExporterImpl writes a voxel grid as a greedy-meshed glTF 2.0 model for
+// previewing in 3D viewers and browsers. The mesh is a single primitive with
+// per-vertex COLOR_0 colors, and glTF's Y-up convention matches Minecraft's.
+type GLTFExporterImpl struct {
+	Binary   bool             // Write a .glb file instead of .gltf JSON with an embedded buffer
+	Progress ProgressReporter // Optional progress callback
+}
+
+// NewGLTFExporter creates a new glTF exporter writing .glb when binary is set.
+func NewGLTFExporter(binary bool) *GLTFExporterImpl {
+	return &GLTFExporterImpl{Binary: binary}
+}
+
+// Export writes the grid's visible faces as a glTF model. See GreedyMesh.
+func (e *GLTFExporterImpl) Export(vg *VoxelGrid, w io.Writer) error {
+	return e.ExportMesh(greedyMesh(vg, e.Progress), w)
+}
+
+// ExportMesh writes a mesh built by GreedyMesh, or any mesh with vertex colors and
+// normals, as a glTF model. Faces are triangulated as fans.
+func (e *GLTFExporterImpl) ExportMesh(mesh *Mesh, w io.Writer) error {
+	positions := make([][3]float32, len(mesh.Vertices))
+	normals := make([][3]float32, len(mesh.Vertices))
+	colors := make([][3]uint8, len(mesh.Vertices))
+	for i, v := range mesh.Vertices {
+		for j := 0; j < 3; j++ {
+			positions[i][j] = float32(v.Position[j])
+			normals[i][j] = float32(v.Normal[j])
+			colors[i][j] = 255
+			if mesh.HasVertexColors {
+				colors[i][j] = clampUint8(math.Round(v.Color[j] * 255))
+			}
+		}
+	}
+	var indices []uint32
+	for _, face := range mesh.Faces {
+		for i := 1; i+1 < len(face.VertexIndices); i++ {
+			indices = append(indices, uint32(face.VertexIndices[0]), uint32(face.VertexIndices[i]), uint32(face.VertexIndices[i+1]))
+		}
+	}
+
+	doc := gltf.NewDocument()
+	doc.Asset.Generator = "poly2block"
+	doc.Materials = []*gltf.Material{{
+		Name: "voxel",
+		PBRMetallicRoughness: &gltf.PBRMetallicRoughness{
+			BaseColorFactor: &[4]float64{1, 1, 1, 1},
+			MetallicFactor:  gltf.Float(0),
+			RoughnessFactor: gltf.Float(1),
+		},
+	}}
+	// An empty grid gives a valid model with an empty scene
+	if len(indices) > 0 {
+		primitive := &gltf.Primitive{
+			Material: gltf.Index(0),
+			Attributes: gltf.PrimitiveAttributes{
+				gltf.POSITION: modeler.WritePosition(doc, positions),
+				gltf.NORMAL:   modeler.WriteNormal(doc, normals),
+				gltf.COLOR_0:  modeler.WriteColor(doc, colors),
+			},
+			Indices: gltf.Index(modeler.WriteIndices(doc, indices)),
+		}
+		doc.Meshes = []*gltf.Mesh{{Name: "voxels", Primitives: []*gltf.Primitive{primitive}}}
+		doc.Nodes = []*gltf.Node{{Name: "voxels", Mesh: gltf.Index(0)}}
+		doc.Scenes[0].Nodes = []int{0}
+		if !e.Binary {
+			doc.Buffers[0].EmbeddedResource()
+		}
+	}
+
+	encoder := gltf.NewEncoder(w)
+	encoder.AsBinary = e.Binary
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write glTF: %w", err)
+	}
+	return nil
+}
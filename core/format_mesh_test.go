@@ -0,0 +1,88 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+func TestOBJExport(t *testing.T) {
+	vg := NewVoxelGrid(2, 1, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{255, 0, 0})
+	vg.SetVoxel(1, 0, 0, [3]uint8{255, 0, 0})
+
+	var buf bytes.Buffer
+	if err := NewOBJExporter().Export(vg, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if !strings.Contains(buf.String(), "v 0 0 0 1.0000 0.0000 0.0000\n") {
+		t.Errorf("missing colored vertex in\n%s", buf.String())
+	}
+
+	// The importer reads the file back, ignoring the vertex colors
+	mesh, err := NewOBJImporter().Import(&buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(mesh.Faces) != 12 {
+		t.Errorf("imported %d triangles, want 12", len(mesh.Faces))
+	}
+	if mesh.Bounds.Max != [3]float64{2, 1, 1} {
+		t.Errorf("bounds max = %v, want [2 1 1]", mesh.Bounds.Max)
+	}
+}
+
+func TestGLTFExport(t *testing.T) {
+	vg := NewVoxelGrid(1, 2, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{0, 128, 255})
+	vg.SetVoxel(0, 1, 0, [3]uint8{0, 128, 255})
+
+	for _, binary := range []bool{true, false} {
+		var buf bytes.Buffer
+		if err := NewGLTFExporter(binary).Export(vg, &buf); err != nil {
+			t.Fatalf("export (binary %v): %v", binary, err)
+		}
+		if got := bytes.HasPrefix(buf.Bytes(), []byte("glTF")); got != binary {
+			t.Errorf("binary %v: glb magic present = %v", binary, got)
+		}
+
+		var doc gltf.Document
+		if err := gltf.NewDecoder(bytes.NewReader(buf.Bytes())).Decode(&doc); err != nil {
+			t.Fatalf("decode (binary %v): %v", binary, err)
+		}
+		primitive := doc.Meshes[0].Primitives[0]
+		colors, err := modeler.ReadColor(&doc, doc.Accessors[primitive.Attributes[gltf.COLOR_0]], nil)
+		if err != nil {
+			t.Fatalf("read colors: %v", err)
+		}
+		// A 1x2x1 column merges into 6 quads of 4 vertices
+		if len(colors) != 24 {
+			t.Errorf("binary %v: %d vertices, want 24", binary, len(colors))
+		}
+		for _, c := range colors {
+			if c != [4]uint8{0, 128, 255, 255} {
+				t.Fatalf("binary %v: vertex color %v", binary, c)
+			}
+		}
+		if n := doc.Accessors[*primitive.Indices].Count; n != 36 {
+			t.Errorf("binary %v: %d indices, want 36", binary, n)
+		}
+	}
+}
+
+func TestGLTFExportEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewGLTFExporter(true).Export(NewVoxelGrid(1, 1, 1), &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	var doc gltf.Document
+	if err := gltf.NewDecoder(&buf).Decode(&doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(doc.Meshes) != 0 {
+		t.Errorf("got %d meshes, want none", len(doc.Meshes))
+	}
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Minetest schematic (.mts) header values and node probabilities.
+const (
+	mtsVersion     = 4
+	mtsProbNever   = 0x00 // Node is not placed, keeping what is in the world
+	mtsProbAlways  = 0x7f
+	mtsDefaultNode = "wool:white" // Node for grids exported without a palette
+)
+
+// MinetestExporterImpl implements MinetestExporter for the Minetest (Luanti)
+// schematic format placed by minetest.place_schematic and WorldEdit's //mtschemplace.
+// Minecraft block IDs are mapped to Minetest Game nodes with a bundled table,
+// which a palette entry's "minetest_node" metadata overrides, and colors are only
+// matched against palette entries that have a node. Air is written with zero
+// probability, so placing the schematic keeps the terrain around the model,
+// unless AirMode is AirModeAir. The
+// lookup is kept across exports with the same palette, and an exporter must not
+// be used by several goroutines at once.
+type MinetestExporterImpl struct {
+	Matcher  ColorMatcher     // Matches colors that are not palette entries (default: CIELAB)
+	AirMode  string           // With AirModeAir, air is always placed, clearing the terrain
+	Progress ProgressReporter // Optional progress callback
+
+	source  *Palette // Palette passed to the last export
+	mapped  *Palette // Entries of source with a Minetest node
+	names   []string // Node names by content ID, air first
+	content []uint16 // Content ID for each lookup index
+	lookup  blockLookup
+}
+
+// NewMinetestExporter creates a new Minetest schematic exporter.
+func NewMinetestExporter() *MinetestExporterImpl {
+	return &MinetestExporterImpl{}
+}
+
+// Export writes a voxel grid as a Minetest schematic.
+func (e *MinetestExporterImpl) Export(vg *VoxelGrid, palette *Palette, w io.Writer) error {
+	for _, size := range []int{vg.SizeX, vg.SizeY, vg.SizeZ} {
+		if size > math.MaxUint16 {
+			return fmt.Errorf("%w: Minetest schematics are at most %d nodes per axis, got %d", ErrInvalidConfig, math.MaxUint16, size)
+		}
+	}
+	if err := checkAirMode(e.AirMode); err != nil {
+		return err
+	}
+	if err := e.preparePalette(palette); err != nil {
+		return err
+	}
+
+	// Nodes run along x, then y, then z; content IDs and param1 are stored as
+	// separate arrays, air being content 0 with zero probability unless every
+	// cell is placed
+	cells := vg.SizeX * vg.SizeY * vg.SizeZ
+	content := getScratchBytes(2 * cells)
+	defer putScratchBytes(content)
+	param1 := getScratchBytes(cells)
+	defer putScratchBytes(param1)
+	if e.AirMode == AirModeAir {
+		for i := range param1 {
+			param1[i] = mtsProbAlways
+		}
+	}
+	tracker := startStage(e.Progress, StageExport, int64(vg.Count()))
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		tracker.add(1)
+		id := e.content[e.lookup.index(color)]
+		if id == 0 {
+			return true
+		}
+		i := x + vg.SizeX*(y+vg.SizeY*z)
+		binary.BigEndian.PutUint16(content[2*i:], id)
+		param1[i] = mtsProbAlways
+		return true
+	})
+	tracker.finish()
+
+	var header bytes.Buffer
+	header.WriteString("MTSM")
+	for _, v := range []int{mtsVersion, vg.SizeX, vg.SizeY, vg.SizeZ} {
+		binary.Write(&header, binary.BigEndian, uint16(v))
+	}
+	for y := 0; y < vg.SizeY; y++ {
+		header.WriteByte(mtsProbAlways)
+	}
+	binary.Write(&header, binary.BigEndian, uint16(len(e.names)))
+	for _, name := range e.names {
+		binary.Write(&header, binary.BigEndian, uint16(len(name)))
+		header.WriteString(name)
+	}
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	zw := zlib.NewWriter(w)
+	zw.Write(content)
+	zw.Write(param1)
+	for i := range param1 {
+		param1[i] = 0 // param2 (rotation) is zero for every node
+	}
+	zw.Write(param1)
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress schematic: %w", err)
+	}
+	return nil
+}
+
+// preparePalette restricts palette to the entries with a Minetest node and builds
+// the lookup and node table for it, reusing the previous tables when the palette
+// has not changed.
+func (e *MinetestExporterImpl) preparePalette(palette *Palette) error {
+	if e.content != nil && e.source == palette {
+		return nil
+	}
+	e.source, e.mapped = palette, nil
+	nodeOf := map[string]string{defaultBlockID: mtsDefaultNode}
+	if palette != nil {
+		e.mapped = &Palette{}
+		nodeOf = make(map[string]string)
+		for i := range palette.Colors {
+			color := &palette.Colors[i]
+			node, ok := minetestNodeFor(color)
+			if !ok {
+				continue
+			}
+			e.mapped.Colors = append(e.mapped.Colors, *color)
+			if _, seen := nodeOf[paletteBlockID(color)]; !seen {
+				nodeOf[paletteBlockID(color)] = node
+			}
+		}
+		if len(e.mapped.Colors) == 0 {
+			e.content = nil
+			return fmt.Errorf("%w: no palette block has a Minetest node", ErrInvalidConfig)
+		}
+	}
+
+	e.lookup.prepare(e.mapped, e.Matcher, DetailNone)
+	ids := e.lookup.blockIDs()
+	e.names = []string{"air"}
+	contentOf := map[string]uint16{"air": 0}
+	e.content = make([]uint16, len(ids))
+	for i, id := range ids {
+		node, ok := nodeOf[id]
+		if !ok {
+			continue // Air
+		}
+		c, seen := contentOf[node]
+		if !seen {
+			c = uint16(len(e.names))
+			contentOf[node] = c
+			e.names = append(e.names, node)
+		}
+		e.content[i] = c
+	}
+	return nil
+}
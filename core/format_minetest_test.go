@@ -0,0 +1,140 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// mtsFile is a decoded Minetest schematic.
+type mtsFile struct {
+	size    [3]int
+	names   []string
+	content []uint16
+	param1  []byte
+}
+
+func decodeMTS(t *testing.T, data []byte) mtsFile {
+	t.Helper()
+	r := bytes.NewReader(data)
+	var head struct {
+		Magic   [4]byte
+		Version uint16
+		Size    [3]uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &head); err != nil || string(head.Magic[:]) != "MTSM" || head.Version != mtsVersion {
+		t.Fatalf("bad header %+v: %v", head, err)
+	}
+	f := mtsFile{size: [3]int{int(head.Size[0]), int(head.Size[1]), int(head.Size[2])}}
+	r.Seek(int64(f.size[1]), io.SeekCurrent) // Slice probabilities
+	var count uint16
+	binary.Read(r, binary.BigEndian, &count)
+	for i := 0; i < int(count); i++ {
+		var n uint16
+		binary.Read(r, binary.BigEndian, &n)
+		name := make([]byte, n)
+		io.ReadFull(r, name)
+		f.names = append(f.names, string(name))
+	}
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	cells := f.size[0] * f.size[1] * f.size[2]
+	f.content = make([]uint16, cells)
+	f.param1 = make([]byte, cells)
+	param2 := make([]byte, cells)
+	if err := binary.Read(zr, binary.BigEndian, f.content); err != nil {
+		t.Fatalf("content: %v", err)
+	}
+	if _, err := io.ReadFull(zr, f.param1); err != nil {
+		t.Fatalf("param1: %v", err)
+	}
+	if _, err := io.ReadFull(zr, param2); err != nil {
+		t.Fatalf("param2: %v", err)
+	}
+	return f
+}
+
+func TestMinetestExport(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{240, 118, 19}, Metadata: map[string]interface{}{"block_id": "minecraft:orange_wool"}},
+		{RGB: [3]uint8{100, 80, 50}, Metadata: map[string]interface{}{"block_id": "minecraft:spruce_log[axis=y]"}},
+		{RGB: [3]uint8{0, 0, 200}, Metadata: map[string]interface{}{"block_id": "minecraft:blue_concrete", "minetest_node": "mymod:blue"}},
+		// No Minetest counterpart, so never used
+		{RGB: [3]uint8{10, 10, 10}, Metadata: map[string]interface{}{"block_id": "minecraft:blackstone"}},
+	}}
+	for i := range palette.Colors {
+		palette.Colors[i].LAB = RGBToLAB(palette.Colors[i].RGB)
+	}
+	vg := NewVoxelGrid(2, 3, 2)
+	vg.SetVoxel(0, 0, 0, [3]uint8{240, 118, 19})
+	vg.SetVoxel(1, 2, 1, [3]uint8{100, 80, 50})
+	vg.SetVoxel(1, 0, 1, [3]uint8{0, 0, 200})
+	vg.SetVoxel(0, 1, 0, [3]uint8{10, 10, 10})
+
+	var buf bytes.Buffer
+	if err := NewMinetestExporter().Export(vg, palette, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	f := decodeMTS(t, buf.Bytes())
+	if f.size != [3]int{2, 3, 2} {
+		t.Fatalf("size = %v, want [2 3 2]", f.size)
+	}
+	if f.names[0] != "air" {
+		t.Errorf("first node = %q, want air", f.names[0])
+	}
+
+	tests := []struct {
+		x, y, z int
+		node    string
+	}{
+		{0, 0, 0, "wool:orange"},
+		{1, 2, 1, "default:pine_tree"},
+		{1, 0, 1, "mymod:blue"},
+		{0, 1, 0, "default:pine_tree"}, // blackstone's color falls back to the darkest mapped block
+		{1, 1, 0, "air"},
+	}
+	for _, tt := range tests {
+		i := tt.x + 2*(tt.y+3*tt.z)
+		if node := f.names[f.content[i]]; node != tt.node {
+			t.Errorf("node (%d,%d,%d) = %s, want %s", tt.x, tt.y, tt.z, node, tt.node)
+		}
+		wantProb := byte(mtsProbAlways)
+		if tt.node == "air" {
+			wantProb = mtsProbNever
+		}
+		if f.param1[i] != wantProb {
+			t.Errorf("node (%d,%d,%d) probability = %#x, want %#x", tt.x, tt.y, tt.z, f.param1[i], wantProb)
+		}
+	}
+}
+
+func TestMinetestNoNodes(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{10, 10, 10}, Metadata: map[string]interface{}{"block_id": "minecraft:blackstone"}},
+	}}
+	err := NewMinetestExporter().Export(NewVoxelGrid(1, 1, 1), palette, &bytes.Buffer{})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestMinetestAirModeAir(t *testing.T) {
+	vg := NewVoxelGrid(2, 1, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{128, 128, 128})
+
+	exporter := NewMinetestExporter()
+	exporter.AirMode = AirModeAir
+	var buf bytes.Buffer
+	if err := exporter.Export(vg, nil, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	f := decodeMTS(t, buf.Bytes())
+	if f.names[f.content[1]] != "air" || f.param1[1] != mtsProbAlways {
+		t.Errorf("empty node = %s with probability %#x, want air always placed", f.names[f.content[1]], f.param1[1])
+	}
+}
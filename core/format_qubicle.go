@@ -0,0 +1,286 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Qubicle Binary (.qb) header values and run-length codes.
+const (
+	qbColorRGBA    = 0
+	qbColorBGRA    = 1
+	qbLeftHanded   = 0
+	qbRightHanded  = 1
+	qbCodeFlag     = 2 // Followed by a count and a color repeated count times
+	qbNextSlice    = 6 // Ends a compressed z slice
+	qbMaxExtent    = 1 << 16
+	qbMaxNameBytes = 255
+)
+
+// QubicleExporterImpl writes voxel grids in the Qubicle Binary (.qb) format as
+// a single matrix. Qubicle is Y-up like the grid; the file is marked
+// right-handed so no axis is mirrored.
+type QubicleExporterImpl struct {
+	Compress bool             // Run-length encode each z slice (NewQubicleExporter sets it)
+	Name     string           // Matrix name (default "poly2block")
+	Progress ProgressReporter // Optional progress callback
+}
+
+// NewQubicleExporter creates a new Qubicle exporter writing compressed matrices.
+func NewQubicleExporter() *QubicleExporterImpl {
+	return &QubicleExporterImpl{Compress: true}
+}
+
+// Export writes a voxel grid to Qubicle Binary format.
+func (e *QubicleExporterImpl) Export(vg *VoxelGrid, w io.Writer) error {
+	name := e.Name
+	if name == "" {
+		name = "poly2block"
+	}
+	if len(name) > qbMaxNameBytes {
+		return fmt.Errorf("%w: Qubicle matrix name longer than %d bytes", ErrInvalidConfig, qbMaxNameBytes)
+	}
+	compressed := uint32(0)
+	if e.Compress {
+		compressed = 1
+	}
+
+	bw := bufio.NewWriter(w)
+	header := []byte{1, 1, 0, 0} // Version 1.1.0.0
+	for _, v := range []uint32{qbColorRGBA, qbRightHanded, compressed, 0, 1} {
+		header = binary.LittleEndian.AppendUint32(header, v)
+	}
+	header = append(header, byte(len(name)))
+	header = append(header, name...)
+	for _, v := range []int{vg.SizeX, vg.SizeY, vg.SizeZ, 0, 0, 0} {
+		header = binary.LittleEndian.AppendUint32(header, uint32(v))
+	}
+	bw.Write(header)
+
+	// Voxels run along x, then y, then z; empty cells are zero
+	tracker := startStage(e.Progress, StageExport, int64(vg.SizeZ))
+	slice := make([]uint32, vg.SizeX*vg.SizeY)
+	var buf []byte
+	for z := 0; z < vg.SizeZ; z++ {
+		for y := 0; y < vg.SizeY; y++ {
+			for x := 0; x < vg.SizeX; x++ {
+				slice[x+y*vg.SizeX] = 0
+				if c, ok := vg.ColorAt(x, y, z); ok {
+					slice[x+y*vg.SizeX] = uint32(c[0]) | uint32(c[1])<<8 | uint32(c[2])<<16 | 0xff<<24
+				}
+			}
+		}
+		buf = appendQBSlice(buf[:0], slice, e.Compress)
+		bw.Write(buf)
+		tracker.add(1)
+	}
+	tracker.finish()
+	return bw.Flush()
+}
+
+// appendQBSlice appends a z slice, run-length encoded when compress is set.
+// Filled cells have full alpha, so no color is mistaken for a code.
+func appendQBSlice(b []byte, slice []uint32, compress bool) []byte {
+	if !compress {
+		for _, v := range slice {
+			b = binary.LittleEndian.AppendUint32(b, v)
+		}
+		return b
+	}
+	for i := 0; i < len(slice); {
+		run := 1
+		for i+run < len(slice) && slice[i+run] == slice[i] {
+			run++
+		}
+		if run > 2 {
+			b = binary.LittleEndian.AppendUint32(b, qbCodeFlag)
+			b = binary.LittleEndian.AppendUint32(b, uint32(run))
+			b = binary.LittleEndian.AppendUint32(b, slice[i])
+		} else {
+			for j := 0; j < run; j++ {
+				b = binary.LittleEndian.AppendUint32(b, slice[i])
+			}
+		}
+		i += run
+	}
+	return binary.LittleEndian.AppendUint32(b, qbNextSlice)
+}
+
+// QubicleImporterImpl reads Qubicle Binary (.qb) files. Files with several
+// matrices are merged into one grid by the matrices' positions; left-handed
+// files are mirrored along z so the model keeps its handedness.
+type QubicleImporterImpl struct{}
+
+// NewQubicleImporter creates a new Qubicle importer.
+func NewQubicleImporter() *QubicleImporterImpl {
+	return &QubicleImporterImpl{}
+}
+
+// qbMatrix is the filled voxels of one matrix, in its own coordinates.
+type qbMatrix struct {
+	size, pos [3]int
+	voxels    []qbVoxel
+}
+
+type qbVoxel struct {
+	pos   [3]int
+	color [3]uint8
+}
+
+// qbReader reads little-endian values, tracking the offset for error reports.
+type qbReader struct {
+	r   *bufio.Reader
+	n   int64
+	buf [4]byte
+}
+
+func (qr *qbReader) uint32() (uint32, error) {
+	k, err := io.ReadFull(qr.r, qr.buf[:])
+	qr.n += int64(k)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return binary.LittleEndian.Uint32(qr.buf[:]), err
+}
+
+func (qr *qbReader) errorf(err error, format string, args ...interface{}) error {
+	return &FormatError{Format: "qb", Offset: qr.n, Msg: fmt.Sprintf(format, args...), Err: err}
+}
+
+// Import reads a Qubicle Binary file and returns a voxel grid.
+func (imp *QubicleImporterImpl) Import(r io.Reader) (*VoxelGrid, error) {
+	qr := &qbReader{r: bufio.NewReader(r)}
+	var header [6]uint32
+	for i := range header {
+		v, err := qr.uint32()
+		if err != nil {
+			return nil, qr.errorf(err, "missing header")
+		}
+		header[i] = v
+	}
+	colorFormat, orientation, compressed, count := header[1], header[2], header[3] != 0, int(header[5])
+	if colorFormat != qbColorRGBA && colorFormat != qbColorBGRA {
+		return nil, qr.errorf(nil, "unknown color format %d", colorFormat)
+	}
+
+	matrices := make([]qbMatrix, 0, min(count, 1024))
+	for m := 0; m < count; m++ {
+		matrix, err := imp.readMatrix(qr, colorFormat, compressed)
+		if err != nil {
+			return nil, err
+		}
+		matrices = append(matrices, matrix)
+	}
+	if len(matrices) == 0 {
+		return nil, qr.errorf(nil, "no matrices")
+	}
+
+	lo := [3]int{math.MaxInt32, math.MaxInt32, math.MaxInt32}
+	hi := [3]int{math.MinInt32, math.MinInt32, math.MinInt32}
+	total := int64(0)
+	for _, m := range matrices {
+		for axis := 0; axis < 3; axis++ {
+			lo[axis] = min(lo[axis], m.pos[axis])
+			hi[axis] = max(hi[axis], m.pos[axis]+m.size[axis])
+		}
+		total += int64(len(m.voxels))
+	}
+	for axis := 0; axis < 3; axis++ {
+		if hi[axis]-lo[axis] > qbMaxExtent {
+			return nil, qr.errorf(nil, "matrices span %d voxels along axis %d", hi[axis]-lo[axis], axis)
+		}
+	}
+
+	vg := NewVoxelGridFor(hi[0]-lo[0], hi[1]-lo[1], hi[2]-lo[2], total, StorageConfig{})
+	for _, m := range matrices {
+		for _, v := range m.voxels {
+			x, y, z := m.pos[0]+v.pos[0]-lo[0], m.pos[1]+v.pos[1]-lo[1], m.pos[2]+v.pos[2]-lo[2]
+			if orientation == qbLeftHanded {
+				z = vg.SizeZ - 1 - z
+			}
+			vg.SetVoxel(x, y, z, v.color)
+		}
+	}
+	return vg, nil
+}
+
+// readMatrix reads one matrix, keeping its filled voxels.
+func (imp *QubicleImporterImpl) readMatrix(qr *qbReader, colorFormat uint32, compressed bool) (qbMatrix, error) {
+	var m qbMatrix
+	nameLen, err := qr.r.ReadByte()
+	if err != nil {
+		return m, qr.errorf(io.ErrUnexpectedEOF, "missing matrix")
+	}
+	qr.n++
+	if _, err := qr.r.Discard(int(nameLen)); err != nil {
+		return m, qr.errorf(io.ErrUnexpectedEOF, "truncated matrix name")
+	}
+	qr.n += int64(nameLen)
+	var fields [6]uint32
+	for i := range fields {
+		if fields[i], err = qr.uint32(); err != nil {
+			return m, qr.errorf(err, "truncated matrix header")
+		}
+	}
+	for axis := 0; axis < 3; axis++ {
+		m.size[axis] = int(fields[axis])
+		m.pos[axis] = int(int32(fields[3+axis]))
+		if m.size[axis] <= 0 || m.size[axis] > qbMaxExtent {
+			return m, qr.errorf(nil, "invalid matrix size %d along axis %d", m.size[axis], axis)
+		}
+	}
+
+	sizeX, sizeY := m.size[0], m.size[1]
+	sliceCells := sizeX * sizeY
+	put := func(i, z int, v uint32) {
+		if v>>24 == 0 { // Zero alpha (or visibility mask) is empty
+			return
+		}
+		c := [3]uint8{uint8(v), uint8(v >> 8), uint8(v >> 16)}
+		if colorFormat == qbColorBGRA {
+			c[0], c[2] = c[2], c[0]
+		}
+		m.voxels = append(m.voxels, qbVoxel{pos: [3]int{i % sizeX, i / sizeX, z}, color: c})
+	}
+	for z := 0; z < m.size[2]; z++ {
+		if !compressed {
+			for i := 0; i < sliceCells; i++ {
+				v, err := qr.uint32()
+				if err != nil {
+					return m, qr.errorf(err, "truncated matrix data")
+				}
+				put(i, z, v)
+			}
+			continue
+		}
+		for i := 0; ; {
+			v, err := qr.uint32()
+			if err != nil {
+				return m, qr.errorf(err, "truncated matrix data")
+			}
+			if v == qbNextSlice {
+				break
+			}
+			run := uint32(1)
+			if v == qbCodeFlag {
+				if run, err = qr.uint32(); err != nil {
+					return m, qr.errorf(err, "truncated run")
+				}
+				if v, err = qr.uint32(); err != nil {
+					return m, qr.errorf(err, "truncated run")
+				}
+			}
+			if uint64(i)+uint64(run) > uint64(sliceCells) {
+				return m, qr.errorf(nil, "run overflows slice %d", z)
+			}
+			for j := 0; j < int(run); j++ {
+				put(i, z, v)
+				i++
+			}
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestQubicleRoundTrip(t *testing.T) {
+	vg := NewVoxelGrid(5, 3, 4)
+	for x := 0; x < 5; x++ {
+		vg.SetVoxel(x, 0, 0, [3]uint8{200, 100, 50}) // A run the encoder compresses
+	}
+	vg.SetVoxel(4, 2, 3, [3]uint8{0, 128, 255})
+	vg.SetVoxel(1, 1, 2, [3]uint8{2, 0, 0}) // Same low bytes as the run code
+
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		exporter := NewQubicleExporter()
+		exporter.Compress = compress
+		if err := exporter.Export(vg, &buf); err != nil {
+			t.Fatalf("compress %v: export: %v", compress, err)
+		}
+		got, err := NewQubicleImporter().Import(&buf)
+		if err != nil {
+			t.Fatalf("compress %v: import: %v", compress, err)
+		}
+		if got.SizeX != 5 || got.SizeY != 3 || got.SizeZ != 4 {
+			t.Errorf("compress %v: size = %dx%dx%d, want 5x3x4", compress, got.SizeX, got.SizeY, got.SizeZ)
+		}
+		if got.Count() != vg.Count() {
+			t.Errorf("compress %v: imported %d voxels, want %d", compress, got.Count(), vg.Count())
+		}
+		vg.Range(func(x, y, z int, color [3]uint8) bool {
+			if c, ok := got.ColorAt(x, y, z); !ok || c != color {
+				t.Errorf("compress %v: voxel (%d,%d,%d) = %v, %v; want %v", compress, x, y, z, c, ok, color)
+			}
+			return true
+		})
+	}
+}
+
+// qbFile encodes an uncompressed .qb file; each matrix is a size, a position and
+// its cells along x, then y, then z.
+func qbFile(colorFormat, orientation uint32, matrices ...[]uint32) []byte {
+	out := []byte{1, 1, 0, 0}
+	for _, v := range []uint32{colorFormat, orientation, 0, 0, uint32(len(matrices))} {
+		out = binary.LittleEndian.AppendUint32(out, v)
+	}
+	for _, m := range matrices {
+		out = append(out, 1, 'm')
+		for _, v := range m {
+			out = binary.LittleEndian.AppendUint32(out, v)
+		}
+	}
+	return out
+}
+
+func TestQubicleImportMatrices(t *testing.T) {
+	// Two left-handed BGRA matrices: a 2x1x2 one at the origin, filled at z=0,
+	// and a single voxel at x=3
+	blue := uint32(0xff0000c8) // B=0xc8 in the low byte
+	neg := int32(-1)
+	data := qbFile(qbColorBGRA, qbLeftHanded,
+		[]uint32{2, 1, 2, 0, 0, 0, blue, 0, 0, 0},
+		[]uint32{1, 1, 1, 3, 0, uint32(neg), 0xff00ff00},
+	)
+	vg, err := NewQubicleImporter().Import(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if vg.SizeX != 4 || vg.SizeY != 1 || vg.SizeZ != 3 {
+		t.Fatalf("size = %dx%dx%d, want 4x1x3", vg.SizeX, vg.SizeY, vg.SizeZ)
+	}
+	// Scene z runs from -1 to 1 and is mirrored: z=0 becomes grid z 1, z=-1 grid z 2
+	if c, ok := vg.ColorAt(0, 0, 1); !ok || c != [3]uint8{0, 0, 0xc8} {
+		t.Errorf("first matrix voxel = %v, %v; want blue", c, ok)
+	}
+	if c, ok := vg.ColorAt(3, 0, 2); !ok || c != [3]uint8{0, 0xff, 0} {
+		t.Errorf("second matrix voxel = %v, %v; want green", c, ok)
+	}
+	if vg.Count() != 2 {
+		t.Errorf("%d voxels, want 2", vg.Count())
+	}
+}
+
+func TestQubicleMalformed(t *testing.T) {
+	valid := qbFile(qbColorRGBA, qbRightHanded, []uint32{1, 1, 1, 0, 0, 0, 0xff0000ff})
+	overflow := []byte{1, 1, 0, 0}
+	for _, v := range []uint32{qbColorRGBA, qbRightHanded, 1, 0, 1} {
+		overflow = binary.LittleEndian.AppendUint32(overflow, v)
+	}
+	overflow = append(overflow, 0)
+	for _, v := range []uint32{1, 1, 1, 0, 0, 0, qbCodeFlag, 5, 0xff0000ff} {
+		overflow = binary.LittleEndian.AppendUint32(overflow, v)
+	}
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"truncated": valid[:len(valid)-2],
+		"overflow":  overflow,
+		"no size":   qbFile(qbColorRGBA, qbRightHanded, []uint32{0, 1, 1, 0, 0, 0}),
+		"format":    qbFile(7, qbRightHanded),
+	}
+	for name, data := range tests {
+		_, err := NewQubicleImporter().Import(bytes.NewReader(data))
+		var formatErr *FormatError
+		if !errors.As(err, &formatErr) || formatErr.Format != "qb" {
+			t.Errorf("%s: expected qb FormatError, got %v", name, err)
+		}
+	}
+}
@@ -1,129 +1,380 @@
 package core
 
 import (
-	"bytes"
+	"cmp"
 	"compress/gzip"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
+	"slices"
+	"strings"
 
 	"github.com/Tnze/go-mc/nbt"
 )
 
 // SchematicExporterImpl implements SchematicExporter for Minecraft schematics.
+// The block lookup for a palette is built on first use and kept for later exports
+// with the same palette pointer, so one exporter can serve many conversions; pass a
+// new palette rather than editing one in place. An exporter must not be used by
+// several goroutines at once.
 type SchematicExporterImpl struct {
-	Version string
+	Version     int              // Sponge schematic format version, 2 or 3 (0 = 2)
+	DataVersion int              // Minecraft data version recorded in the file (0 = 2975)
+	Matcher     ColorMatcher     // Matches colors that are not palette entries (default: CIELAB)
+	Detail      string           // Surface detail mode, such as DetailStairsSlabs (default DetailNone)
+	AirMode     string           // How empty cells are written: air (default) or, with AirModeVoid, structure voids
+	PasteOrigin string           // Where the grid lands when pasted, such as PasteOriginCenterBottom (default PasteOriginCorner)
+	Offset      [3]int           // Minimum corner relative to the paste position with PasteOriginCorner
+	Rotation    int              // Quarter turns about y, clockwise seen from above, turning block states too (0-3)
+	Progress    ProgressReporter // Optional progress callback
+	
+	lookup    blockLookup
+	materials *MaterialReport
 }
 
-// NewSchematicExporter creates a new schematic exporter.
-func NewSchematicExporter(version string) *SchematicExporterImpl {
+// defaultBlockID is used for palette entries without a block_id and when exporting without a palette.
+const defaultBlockID = "minecraft:white_concrete"
+
+// NewSchematicExporter creates a new schematic exporter writing the given Sponge
+// schematic format version (0 = 2).
+func NewSchematicExporter(version int) *SchematicExporterImpl {
 	return &SchematicExporterImpl{Version: version}
 }
 
 // Export writes a voxel grid as a Minecraft schematic.
 func (e *SchematicExporterImpl) Export(vg *VoxelGrid, palette *Palette, config DitherConfig, w io.Writer) error {
-	// Create NBT structure for schematic
-	schematic := map[string]interface{}{
-		"Version":      int32(2), // Sponge Schematic version 2
-		"DataVersion":  int32(2975), // Minecraft 1.19
-		"Width":        int16(vg.SizeX),
-		"Height":       int16(vg.SizeY),
-		"Length":       int16(vg.SizeZ),
-		"Offset":       []int32{0, 0, 0},
-	}
-	
-	// Build palette mapping
-	blockPalette := make(map[string]int32)
-	paletteIndex := int32(0)
-	
-	// Default air block
-	blockPalette["minecraft:air"] = paletteIndex
-	paletteIndex++
-	
-	// Add blocks from palette
-	if palette != nil {
-		for _, color := range palette.Colors {
-			blockID := "minecraft:white_concrete" // Default
-			if id, ok := color.Metadata["block_id"].(string); ok {
-				blockID = id
-			}
-			if _, exists := blockPalette[blockID]; !exists {
-				blockPalette[blockID] = paletteIndex
-				paletteIndex++
-			}
+	version := e.Version
+	if version == 0 {
+		version = defaultSchematicVersion
+	}
+	if version != 2 && version != 3 {
+		return fmt.Errorf("%w: unsupported schematic version %d (supported: 2, 3)", ErrInvalidConfig, version)
+	}
+	if err := checkAirMode(e.AirMode); err != nil {
+		return err
+	}
+	if err := checkRotation(e.Rotation); err != nil {
+		return err
+	}
+	width, length := rotatedSize(vg.SizeX, vg.SizeZ, e.Rotation)
+	offset, err := pasteOffset([3]int{width, vg.SizeY, length}, e.PasteOrigin, e.Offset)
+	if err != nil {
+		return err
+	}
+	dataVersion := e.DataVersion
+	if dataVersion == 0 {
+		dataVersion = defaultSchematicDataVersion
+	}
+	
+	// Build palette mapping; blocks are looked up in the grid as it is, then
+	// turned with their cells
+	e.lookup.prepare(palette, e.Matcher, e.Detail)
+	blockIDs, remap := rotateBlockIDs(e.lookup.blockIDs(), e.Rotation)
+	
+	// Look up the filled cells only, ordered as block data runs; the air
+	// between them is written as it streams out. Air is index 0, a single
+	// varint byte, so the data length follows from the filled cells alone.
+	cells := vg.SizeX * vg.SizeY * vg.SizeZ
+	filled := make([]schematicCell, 0, vg.Count())
+	tracker := startStage(e.Progress, StageExport, int64(vg.Count()))
+	counts := make([]int, len(blockIDs))
+	dataLen := cells
+	var entityCells []schematicEntity
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		tracker.add(1)
+		lookupIdx := e.lookup.indexAt(vg, x, y, z, color)
+		idx := remap[lookupIdx]
+		counts[idx]++
+		dataLen += uvarintLen(uint64(idx)) - 1
+		rx, rz := rotatePosition(x, z, vg.SizeX, vg.SizeZ, e.Rotation)
+		cell := schematicCell{schematicIndex(rx, y, rz, width, length), idx}
+		filled = append(filled, cell)
+		if snbt := e.lookup.entity(color, lookupIdx); snbt != "" {
+			entityCells = append(entityCells, schematicEntity{cell, [3]int32{int32(rx), int32(y), int32(rz)}, snbt})
 		}
+		return true
+	})
+	slices.SortFunc(filled, func(a, b schematicCell) int { return cmp.Compare(a.index, b.index) })
+	tracker.finish()
+	entities, err := parseSchematicEntities(entityCells, blockIDs)
+	if err != nil {
+		return err
+	}
+	e.materials = newMaterialReport(blockIDs, counts)
+	e.materials.addCosts(paletteCosts(palette))
+	if dataLen > math.MaxInt32 {
+		return fmt.Errorf("schematic block data of %d bytes exceeds the NBT array limit", dataLen)
+	}
+	
+	// Stream NBT into gzip; version 3 nests the schematic in an unnamed root
+	// compound and moves the palette and block data into a Blocks container
+	gzipWriter := getGzipWriter(w)
+	defer putGzipWriter(gzipWriter)
+	s := newNBTStream(gzipWriter)
+	if version == 3 {
+		s.beginCompound("")
+	}
+	s.beginCompound("Schematic")
+	s.intTag("Version", int32(version))
+	s.intTag("DataVersion", int32(dataVersion))
+	s.shortTag("Width", int16(width))
+	s.shortTag("Height", int16(vg.SizeY))
+	s.shortTag("Length", int16(length))
+	// Version 3 pastes relative to Offset; WorldEdit reads version 2 offsets
+	// from the WEOffset metadata, taking Offset as the copied position
+	s.intArrayTag("Offset", []int32{int32(offset[0]), int32(offset[1]), int32(offset[2])})
+	s.beginCompound("Metadata")
+	s.stringTag("Name", "poly2block export")
+	s.stringTag("Author", "poly2block")
+	if version == 2 {
+		s.intTag("WEOffsetX", int32(offset[0]))
+		s.intTag("WEOffsetY", int32(offset[1]))
+		s.intTag("WEOffsetZ", int32(offset[2]))
+	}
+	s.endCompound()
+	
+	dataKey := "BlockData"
+	if version == 3 {
+		s.beginCompound("Blocks")
+		dataKey = "Data"
 	} else {
-		// Add a default block if no palette
-		blockPalette["minecraft:white_concrete"] = paletteIndex
-		paletteIndex++
-	}
-	
-	// Convert palette map to NBT format
-	paletteNBT := make(map[string]interface{})
-	for blockID, idx := range blockPalette {
-		paletteNBT[blockID] = idx
-	}
-	schematic["Palette"] = paletteNBT
-	schematic["PaletteMax"] = paletteIndex
-	
-	// Build block data array
-	blockData := make([]byte, vg.SizeX*vg.SizeY*vg.SizeZ)
-	
-	// Initialize with air (0)
-	for i := range blockData {
-		blockData[i] = 0
-	}
-	
-	// Fill voxels
-	matcher := NewCIELABMatcher(palette)
-	for _, voxel := range vg.Voxels {
-		// Calculate index (YZX order for Minecraft)
-		index := voxel.Y + voxel.Z*vg.SizeY + voxel.X*vg.SizeY*vg.SizeZ
-		
-		if palette != nil {
-			// Match color to palette
-			matched := matcher.Match(voxel.Color)
-			if matched != nil {
-				if blockID, ok := matched.Metadata["block_id"].(string); ok {
-					if idx, exists := blockPalette[blockID]; exists {
-						blockData[index] = byte(idx)
-					}
-				}
+		s.intTag("PaletteMax", int32(len(blockIDs)))
+	}
+	// Empty cells take index 0 either way, so structure voids only rename it
+	s.beginCompound("Palette")
+	for idx, blockID := range blockIDs {
+		if idx == 0 && e.AirMode == AirModeVoid {
+			blockID = structureVoidID
+		}
+		s.intTag(blockID, int32(idx))
+	}
+	s.endCompound()
+	s.beginByteArray(dataKey, dataLen)
+	next := 0
+	for _, cell := range filled {
+		s.zeros(cell.index - next)
+		s.uvarint(uint64(cell.block))
+		next = cell.index + 1
+	}
+	s.zeros(cells - next)
+	if len(entities) > 0 {
+		// Version 3 nests each block entity's data in a Data compound
+		s.beginList("BlockEntities", nbtCompound, len(entities))
+		for i, entity := range entities {
+			s.intArrayTag("Pos", entityCells[i].pos[:])
+			s.stringTag("Id", entity.id)
+			if version == 3 {
+				s.beginCompound("Data")
 			}
-		} else {
-			// Use default block
-			blockData[index] = 1
+			for _, key := range sortedKeys(entity.fields) {
+				s.rawTag(key, entity.fields[key])
+			}
+			if version == 3 {
+				s.endCompound() // Data
+			}
+			s.endCompound()
 		}
 	}
+	if version == 3 {
+		s.endCompound() // Blocks
+	}
+	s.endCompound() // Schematic
+	if version == 3 {
+		s.endCompound() // root
+	}
 	
-	schematic["BlockData"] = blockData
+	if err := s.flush(); err != nil {
+		return fmt.Errorf("failed to write schematic: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to compress schematic: %w", err)
+	}
 	
-	// Add metadata
-	metadata := map[string]interface{}{
-		"Name":   "poly2block export",
-		"Author": "poly2block",
+	return nil
+}
+
+// schematicCell is a filled cell of Sponge block data: its position in the data
+// and its block palette index.
+type schematicCell struct {
+	index int
+	block int32
+}
+
+// schematicEntity is a filled cell whose palette entry carries a block entity
+// template, with its position in the schematic.
+type schematicEntity struct {
+	schematicCell
+	pos  [3]int32
+	snbt string
+}
+
+// parseSchematicEntities sorts cells as block data runs and returns the parsed
+// block entity of each, parsing every template once per block.
+func parseSchematicEntities(cells []schematicEntity, blockIDs []string) ([]*blockEntity, error) {
+	slices.SortFunc(cells, func(a, b schematicEntity) int { return cmp.Compare(a.index, b.index) })
+	templates := make(map[[2]string]*blockEntity)
+	entities := make([]*blockEntity, len(cells))
+	for i, cell := range cells {
+		key := [2]string{cell.snbt, blockIDs[cell.block]}
+		entity, ok := templates[key]
+		if !ok {
+			var err error
+			if entity, err = parseBlockEntity(key[0], key[1]); err != nil {
+				return nil, err
+			}
+			templates[key] = entity
+		}
+		entities[i] = entity
 	}
-	schematic["Metadata"] = metadata
+	return entities, nil
+}
+
+// Materials returns the blocks written by the last export, or nil before the first.
+func (e *SchematicExporterImpl) Materials() *MaterialReport {
+	return e.materials
+}
+
+// blockLookup maps voxel colors to indices in a block palette built from a color
+// palette. Index 0 is air. The tables are kept for later exports with the same
+// palette pointer and detail mode.
+type blockLookup struct {
+	palette    *Palette               // Palette the tables below were built for
+	matcher    ColorMatcher           // Matches colors that are not palette entries
+	detail     string                 // Detail mode the tables below were built for
+	blocks     map[string]int32       // Block ID -> block palette index
+	colorIndex map[[3]uint8]int32     // Voxel color -> block palette index
+	entities   map[[3]uint8]string    // Voxel color -> block entity template of its palette entry, if any
+	shapes     map[int32]*blockShapes // Full block index -> its stairs and slabs (with DetailStairsSlabs)
+}
+
+// prepare builds the block palette and color lookup for palette, reusing the
+// previous tables when neither the palette nor the detail mode has changed. A nil
+// matcher defaults to CIELAB on first use. With DetailStairsSlabs the block
+// palette also holds the stair and slab states indexAt may pick.
+func (l *blockLookup) prepare(palette *Palette, matcher ColorMatcher, detail string) {
+	if l.blocks != nil && l.palette == palette && l.detail == detail {
+		return
+	}
+	l.palette = palette
+	l.matcher = matcher
+	l.detail = detail
+	l.blocks = map[string]int32{"minecraft:air": 0}
+	l.colorIndex = make(map[[3]uint8]int32)
+	l.entities = make(map[[3]uint8]string)
+	l.shapes = nil
 	
-	// Encode to NBT
-	var buf bytes.Buffer
-	encoder := nbt.NewEncoder(&buf)
-	if err := encoder.Encode(schematic, "Schematic"); err != nil {
-		return fmt.Errorf("failed to encode NBT: %w", err)
+	if palette == nil {
+		// Add a default block if no palette
+		l.blocks[defaultBlockID] = 1
+		return
 	}
 	
-	// Compress with gzip
-	gzipWriter := gzip.NewWriter(w)
-	defer gzipWriter.Close()
+	for _, color := range palette.Colors {
+		l.add(paletteBlockID(&color))
+	}
+	if detail == DetailStairsSlabs {
+		l.addShapes(palette)
+	}
+	// Colors that are already palette entries (the pipeline's matching output)
+	// need no matching; the first entry wins when two share an RGB value.
+	for i := len(palette.Colors) - 1; i >= 0; i-- {
+		color := &palette.Colors[i]
+		l.colorIndex[color.RGB] = l.blocks[paletteBlockID(color)]
+		if snbt := paletteBlockEntity(color); snbt != "" {
+			l.entities[color.RGB] = snbt
+		} else {
+			delete(l.entities, color.RGB)
+		}
+	}
 	
-	if _, err := gzipWriter.Write(buf.Bytes()); err != nil {
-		return fmt.Errorf("failed to compress schematic: %w", err)
+	if l.matcher != nil {
+		l.matcher.SetPalette(palette)
+	}
+}
+
+// add returns the block palette index of a block ID, appending it if it is new.
+func (l *blockLookup) add(blockID string) int32 {
+	idx, exists := l.blocks[blockID]
+	if !exists {
+		idx = int32(len(l.blocks))
+		l.blocks[blockID] = idx
+	}
+	return idx
+}
+
+// index returns the block palette index for a voxel color, matching and caching
+// colors that are not palette entries.
+func (l *blockLookup) index(color [3]uint8) int32 {
+	if l.palette == nil {
+		return 1
+	}
+	if idx, ok := l.colorIndex[color]; ok {
+		return idx
 	}
 	
-	return nil
+	if l.matcher == nil {
+		l.matcher = NewCIELABMatcher(l.palette)
+	}
+	idx := int32(0)
+	if matched := l.matcher.Match(color); matched != nil {
+		idx = l.blocks[paletteBlockID(matched)]
+		if snbt := paletteBlockEntity(matched); snbt != "" {
+			l.entities[color] = snbt
+		}
+	}
+	l.colorIndex[color] = idx
+	return idx
+}
+
+// entity returns the block entity template for a voxel color placed as block
+// palette index idx, or "" when its palette entry has none or the detail pass
+// placed a different block.
+func (l *blockLookup) entity(color [3]uint8, idx int32) string {
+	if len(l.entities) == 0 || idx != l.index(color) {
+		return ""
+	}
+	return l.entities[color]
+}
+
+// blockIDs returns the block IDs of the palette ordered by index.
+func (l *blockLookup) blockIDs() []string {
+	ids := make([]string, len(l.blocks))
+	for id, idx := range l.blocks {
+		ids[idx] = id
+	}
+	return ids
+}
+
+// schematicIndex returns the position of a block in Sponge block data, which
+// runs along x, then z, then y.
+func schematicIndex(x, y, z, width, length int) int {
+	return x + z*width + y*width*length
+}
+
+// paletteBlockID returns the block state a palette color stands for, with its
+// "properties" metadata and any bracketed properties of its block_id merged into
+// a Sponge palette key such as "minecraft:oak_slab[type=top]".
+func paletteBlockID(color *PaletteColor) string {
+	id, ok := color.Metadata["block_id"].(string)
+	if !ok {
+		return defaultBlockID
+	}
+	props := blockProperties(color)
+	if len(props) == 0 {
+		return id
+	}
+	return blockStateKey(structureBlockState{Name: parseBlockState(id).Name, Properties: props})
 }
 
 // SchematicImporterImpl implements SchematicImporter for Minecraft schematics.
-type SchematicImporterImpl struct{}
+type SchematicImporterImpl struct {
+	// Palette colors imported blocks by block ID; blocks it does not list, or all
+	// blocks when it is nil, are imported gray.
+	Palette *Palette
+	
+	materials *MaterialReport
+}
 
 // NewSchematicImporter creates a new schematic importer.
 func NewSchematicImporter() *SchematicImporterImpl {
@@ -133,9 +384,10 @@ func NewSchematicImporter() *SchematicImporterImpl {
 // Import reads a schematic file and returns a voxel grid.
 func (imp *SchematicImporterImpl) Import(r io.Reader) (*VoxelGrid, error) {
 	// Decompress gzip
-	gzipReader, err := gzip.NewReader(r)
+	counter := &countingReader{r: r}
+	gzipReader, err := gzip.NewReader(counter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		return nil, &FormatError{Format: "schematic", Offset: counter.n, Msg: "not gzip compressed", Err: err}
 	}
 	defer gzipReader.Close()
 	
@@ -144,45 +396,142 @@ func (imp *SchematicImporterImpl) Import(r io.Reader) (*VoxelGrid, error) {
 	decoder := nbt.NewDecoder(gzipReader)
 	_, err = decoder.Decode(&schematic)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode NBT: %w", err)
+		return nil, &FormatError{Format: "schematic", Offset: -1, Msg: "failed to decode NBT", Err: err}
+	}
+	// Version 3 wraps the schematic in an unnamed root compound
+	if inner, ok := schematic["Schematic"].(map[string]interface{}); ok {
+		schematic = inner
 	}
 	
-	// Extract dimensions
-	width := int(schematic["Width"].(int16))
-	height := int(schematic["Height"].(int16))
-	length := int(schematic["Length"].(int16))
+	// Version 3 keeps the palette and block data in a Blocks container
+	blocks := schematic
+	dataKey := "BlockData"
+	if container, ok := schematic["Blocks"].(map[string]interface{}); ok {
+		blocks, dataKey = container, "Data"
+	}
 	
-	// Create voxel grid
-	vg := NewVoxelGrid(width, height, length)
+	// Extract dimensions
+	width, okW := schematic["Width"].(int16)
+	height, okH := schematic["Height"].(int16)
+	length, okL := schematic["Length"].(int16)
+	if !okW || !okH || !okL {
+		return nil, &FormatError{Format: "schematic", Offset: -1, Msg: "missing Width, Height or Length"}
+	}
+	sizeX, sizeY, sizeZ := int(uint16(width)), int(uint16(height)), int(uint16(length))
 	
 	// Extract block data
-	blockData := schematic["BlockData"].([]byte)
-	palette := schematic["Palette"].(map[string]interface{})
+	blockData, ok := blocks[dataKey].([]byte)
+	if !ok {
+		return nil, &FormatError{Format: "schematic", Offset: -1, Msg: fmt.Sprintf("missing %s", dataKey)}
+	}
+	palette, ok := blocks["Palette"].(map[string]interface{})
+	if !ok {
+		return nil, &FormatError{Format: "schematic", Offset: -1, Msg: "missing Palette"}
+	}
 	
 	// Build reverse palette
 	reversePalette := make(map[int32]string)
 	for blockID, idx := range palette {
-		reversePalette[idx.(int32)] = blockID
-	}
-	
-	// Fill voxel grid
-	for y := 0; y < height; y++ {
-		for z := 0; z < length; z++ {
-			for x := 0; x < width; x++ {
-				index := y + z*height + x*height*length
-				blockIndex := int32(blockData[index])
-				
-				if blockIndex > 0 { // Skip air
-					// Get block ID
-					if blockID, ok := reversePalette[blockIndex]; ok && blockID != "minecraft:air" {
-						// Use a default color for now
-						// In a full implementation, we'd look up the actual block color
-						vg.SetVoxel(x, y, z, [3]uint8{128, 128, 128})
-					}
-				}
-			}
+		i, ok := idx.(int32)
+		if !ok {
+			return nil, &FormatError{Format: "schematic", Offset: -1, Msg: fmt.Sprintf("invalid palette index for %s", blockID)}
+		}
+		reversePalette[i] = blockID
+	}
+	
+	// Validate the varint block data and count the non-air blocks
+	cells := sizeX * sizeY * sizeZ
+	filled := int64(0)
+	counts := make(map[int32]int)
+	if err := rangeBlockData(blockData, cells, func(_ int, blockIndex int32) {
+		if blockIndex != 0 {
+			filled++
+			counts[blockIndex]++
+		}
+	}); err != nil {
+		return nil, &FormatError{Format: "schematic", Offset: -1, Msg: fmt.Sprintf("invalid %s", dataKey), Err: err}
+	}
+	var ids []string
+	var idCounts []int
+	for blockIndex, count := range counts {
+		if blockID, ok := reversePalette[blockIndex]; ok {
+			ids = append(ids, blockID)
+			idCounts = append(idCounts, count)
 		}
 	}
+	imp.materials = newMaterialReport(ids, idCounts)
+	
+	// Create voxel grid sized for the non-air blocks
+	vg := NewVoxelGridFor(sizeX, sizeY, sizeZ, filled, StorageConfig{})
+	colors := blockColors(imp.Palette)
+	
+	// Fill voxel grid; block data runs along x, then z, then y
+	rangeBlockData(blockData, cells, func(i int, blockIndex int32) {
+		if blockIndex == 0 { // Skip air
+			return
+		}
+		// Get block ID
+		if blockID, ok := reversePalette[blockIndex]; ok && blockID != "minecraft:air" {
+			x, z, y := i%sizeX, i/sizeX%sizeZ, i/(sizeX*sizeZ)
+			vg.SetVoxel(x, y, z, blockColor(colors, blockID))
+		}
+	})
 	
 	return vg, nil
 }
+
+// Materials returns the blocks of the last imported schematic with their counts,
+// or nil before the first import.
+func (imp *SchematicImporterImpl) Materials() *MaterialReport {
+	return imp.materials
+}
+
+// blockColors maps the block IDs of an importer's palette, with and without
+// block states, to their colors. The first entry wins when IDs repeat.
+func blockColors(palette *Palette) map[string][3]uint8 {
+	colors := make(map[string][3]uint8)
+	if palette == nil {
+		return colors
+	}
+	for i := range palette.Colors {
+		color := &palette.Colors[i]
+		id := paletteBlockID(color)
+		base, _, _ := strings.Cut(id, "[")
+		for _, key := range []string{id, base} {
+			if _, ok := colors[key]; !ok {
+				colors[key] = color.RGB
+			}
+		}
+	}
+	return colors
+}
+
+// blockColor returns the color of a schematic block ID, trying the exact state
+// in any property order first, then the block without its state, then gray.
+func blockColor(colors map[string][3]uint8, blockID string) [3]uint8 {
+	if rgb, ok := colors[blockStateKey(parseBlockState(blockID))]; ok {
+		return rgb
+	}
+	base, _, _ := strings.Cut(blockID, "[")
+	if rgb, ok := colors[base]; ok {
+		return rgb
+	}
+	return [3]uint8{128, 128, 128}
+}
+
+// rangeBlockData decodes cells varint palette indices from data, calling fn with
+// each cell's position in the data and its index.
+func rangeBlockData(data []byte, cells int, fn func(i int, blockIndex int32)) error {
+	for i := 0; i < cells; i++ {
+		v, n := binary.Uvarint(data)
+		switch {
+		case n == 0:
+			return fmt.Errorf("block data ends after %d of %d blocks", i, cells)
+		case n < 0 || v > math.MaxInt32:
+			return fmt.Errorf("block %d: palette index overflows", i)
+		}
+		fn(i, int32(v))
+		data = data[n:]
+	}
+	return nil
+}
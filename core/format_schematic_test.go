@@ -0,0 +1,383 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+func TestSchematicRoundTrip(t *testing.T) {
+	vg := NewVoxelGrid(4, 3, 2)
+	vg.SetVoxel(0, 0, 0, [3]uint8{255, 0, 0})
+	vg.SetVoxel(3, 2, 1, [3]uint8{0, 128, 255})
+	vg.SetVoxel(1, 2, 0, [3]uint8{255, 0, 0})
+
+	for _, version := range []int{0, 2, 3} {
+		var buf bytes.Buffer
+		if err := NewSchematicExporter(version).Export(vg, nil, DitherConfig{}, &buf); err != nil {
+			t.Fatalf("v%d export: %v", version, err)
+		}
+		got, err := NewSchematicImporter().Import(&buf)
+		if err != nil {
+			t.Fatalf("v%d import: %v", version, err)
+		}
+		if got.SizeX != 4 || got.SizeY != 3 || got.SizeZ != 2 {
+			t.Errorf("v%d size = %dx%dx%d, want 4x3x2", version, got.SizeX, got.SizeY, got.SizeZ)
+		}
+		if got.Count() != vg.Count() {
+			t.Errorf("v%d imported %d blocks, want %d", version, got.Count(), vg.Count())
+		}
+		vg.Range(func(x, y, z int, _ [3]uint8) bool {
+			if !got.HasVoxel(x, y, z) {
+				t.Errorf("v%d block (%d,%d,%d) missing", version, x, y, z)
+			}
+			return true
+		})
+	}
+}
+
+func TestSchematicImportColors(t *testing.T) {
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:red_wool", RGB: [3]uint8{160, 39, 34}},
+		{ID: "minecraft:oak_log", RGB: [3]uint8{100, 80, 50}},
+	})
+	vg := NewVoxelGrid(3, 1, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{160, 39, 34})
+	vg.SetVoxel(1, 0, 0, [3]uint8{100, 80, 50})
+
+	var buf bytes.Buffer
+	if err := NewSchematicExporter(2).Export(vg, palette, DitherConfig{}, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	// Drop the log from the importer's palette: it comes back gray
+	importer := &SchematicImporterImpl{Palette: &Palette{Colors: palette.Colors[:1]}}
+	got, err := importer.Import(&buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if color, _ := got.ColorAt(0, 0, 0); color != [3]uint8{160, 39, 34} {
+		t.Errorf("red_wool imported as %v", color)
+	}
+	if color, _ := got.ColorAt(1, 0, 0); color != [3]uint8{128, 128, 128} {
+		t.Errorf("block missing from the palette imported as %v, want gray", color)
+	}
+
+	colors := map[string][3]uint8{"minecraft:oak_log": {1, 2, 3}}
+	if got := blockColor(colors, "minecraft:oak_log[axis=x]"); got != [3]uint8{1, 2, 3} {
+		t.Errorf("block state not stripped: got %v", got)
+	}
+}
+
+func TestSchematicBlockStates(t *testing.T) {
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:oak_slab", Properties: map[string]string{"type": "top"}, RGB: [3]uint8{160, 130, 80}},
+		{ID: "minecraft:oak_slab", Properties: map[string]string{"type": "bottom"}, RGB: [3]uint8{150, 120, 70}},
+		{ID: "minecraft:oak_stairs[half=top]", Properties: map[string]string{"facing": "east"}, RGB: [3]uint8{140, 110, 60}},
+	})
+	// Properties must survive a msgpack round trip of the palette
+	var encoded bytes.Buffer
+	if err := ExportPalette(palette, &encoded); err != nil {
+		t.Fatalf("export palette: %v", err)
+	}
+	palette, err := ImportPalette(&encoded)
+	if err != nil {
+		t.Fatalf("import palette: %v", err)
+	}
+
+	vg := NewVoxelGrid(3, 1, 1)
+	for i, color := range palette.Colors {
+		vg.SetVoxel(i, 0, 0, color.RGB)
+	}
+	var buf bytes.Buffer
+	if err := NewSchematicExporter(2).Export(vg, palette, DitherConfig{}, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	keys := decodeSchematic(t, buf.Bytes())["Palette"].(map[string]interface{})
+	for _, want := range []string{
+		"minecraft:oak_slab[type=top]",
+		"minecraft:oak_slab[type=bottom]",
+		"minecraft:oak_stairs[facing=east,half=top]",
+	} {
+		if _, ok := keys[want]; !ok {
+			t.Errorf("palette key %q missing: %v", want, keys)
+		}
+	}
+
+	got, err := (&SchematicImporterImpl{Palette: palette}).Import(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	for i, color := range palette.Colors {
+		if rgb, _ := got.ColorAt(i, 0, 0); rgb != color.RGB {
+			t.Errorf("block %d imported as %v, want %v", i, rgb, color.RGB)
+		}
+	}
+
+	colors := map[string][3]uint8{"minecraft:oak_stairs[facing=east,half=top]": {1, 2, 3}}
+	if got := blockColor(colors, "minecraft:oak_stairs[half=top,facing=east]"); got != [3]uint8{1, 2, 3} {
+		t.Errorf("property order not normalized: got %v", got)
+	}
+}
+
+func TestSchematicV3Layout(t *testing.T) {
+	vg := NewVoxelGrid(3, 2, 2)
+	vg.SetVoxel(2, 1, 0, [3]uint8{255, 255, 255})
+
+	exporter := NewSchematicExporter(3)
+	exporter.DataVersion = 3700
+	var buf bytes.Buffer
+	if err := exporter.Export(vg, nil, DitherConfig{}, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	var root map[string]interface{}
+	name, err := nbt.NewDecoder(gz).Decode(&root)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if name != "" {
+		t.Errorf("root name = %q, want empty", name)
+	}
+	schematic, ok := root["Schematic"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("missing Schematic compound: %v", root)
+	}
+	if schematic["Version"] != int32(3) || schematic["DataVersion"] != int32(3700) {
+		t.Errorf("Version, DataVersion = %v, %v; want 3, 3700", schematic["Version"], schematic["DataVersion"])
+	}
+	if _, ok := schematic["BlockData"]; ok {
+		t.Error("v3 schematic has a top-level BlockData")
+	}
+	blocks, ok := schematic["Blocks"].(map[string]interface{})
+	if !ok {
+		t.Fatal("missing Blocks container")
+	}
+	if _, ok := blocks["Palette"].(map[string]interface{}); !ok {
+		t.Error("missing Blocks.Palette")
+	}
+	data, _ := blocks["Data"].([]byte)
+	if len(data) != 12 {
+		t.Fatalf("len(Data) = %d, want 12", len(data))
+	}
+	// Index x + z*Width + y*Width*Length
+	if data[2+1*3*2] == 0 {
+		t.Errorf("block (2,1,0) not at its x-z-y index: %v", data)
+	}
+}
+
+// decodeSchematic returns the schematic compound of an exported file.
+func decodeSchematic(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	var schematic map[string]interface{}
+	if _, err := nbt.NewDecoder(gz).Decode(&schematic); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return schematic
+}
+
+func TestSchematicVarintBlockData(t *testing.T) {
+	// 300 blocks need palette indices past 127, which take two varint bytes
+	palette := &Palette{}
+	vg := NewVoxelGrid(300, 1, 1)
+	for i := 0; i < 300; i++ {
+		rgb := [3]uint8{uint8(i), uint8(i >> 8), 0}
+		palette.Colors = append(palette.Colors, PaletteColor{
+			RGB:      rgb,
+			Metadata: map[string]interface{}{"block_id": fmt.Sprintf("test:block_%d", i)},
+		})
+		vg.SetVoxel(i, 0, 0, rgb)
+	}
+
+	var buf bytes.Buffer
+	if err := NewSchematicExporter(2).Export(vg, palette, DitherConfig{}, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	schematic := decodeSchematic(t, buf.Bytes())
+	names := make(map[int32]string)
+	for id, idx := range schematic["Palette"].(map[string]interface{}) {
+		names[idx.(int32)] = id
+	}
+	data := schematic["BlockData"].([]byte)
+	for i := 0; i < 300; i++ {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("block %d: bad varint", i)
+		}
+		data = data[n:]
+		if want := fmt.Sprintf("test:block_%d", i); names[int32(v)] != want {
+			t.Fatalf("block %d = %q, want %q", i, names[int32(v)], want)
+		}
+	}
+	if len(data) != 0 {
+		t.Errorf("%d trailing bytes after block data", len(data))
+	}
+
+	got, err := NewSchematicImporter().Import(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if got.Count() != 300 {
+		t.Errorf("imported %d blocks, want 300", got.Count())
+	}
+}
+
+func TestSchematicSparseBlockData(t *testing.T) {
+	// Two blocks in a large grid: the air around them is streamed, not allocated
+	vg := NewVoxelGrid(256, 64, 256)
+	vg.SetVoxel(255, 63, 255, [3]uint8{255, 0, 0})
+	vg.SetVoxel(3, 1, 2, [3]uint8{255, 0, 0})
+
+	var buf bytes.Buffer
+	if err := NewSchematicExporter(2).Export(vg, nil, DitherConfig{}, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	schematic := decodeSchematic(t, buf.Bytes())
+	data := schematic["BlockData"].([]byte)
+	if len(data) != 256*64*256 {
+		t.Fatalf("block data has %d bytes, want %d", len(data), 256*64*256)
+	}
+	filled := 0
+	for i, b := range data {
+		if b != 0 {
+			filled++
+			if i != schematicIndex(3, 1, 2, 256, 256) && i != len(data)-1 {
+				t.Errorf("block at data index %d", i)
+			}
+		}
+	}
+	if filled != 2 {
+		t.Errorf("%d filled blocks, want 2", filled)
+	}
+}
+
+func TestSchematicWriteError(t *testing.T) {
+	vg := NewVoxelGrid(64, 64, 64)
+	vg.SetVoxel(1, 1, 1, [3]uint8{255, 0, 0})
+	if err := NewSchematicExporter(3).Export(vg, nil, DitherConfig{}, failingWriter{}); err == nil {
+		t.Error("export to a failing writer succeeded")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestSchematicTruncatedBlockData(t *testing.T) {
+	schematic := map[string]interface{}{
+		"Version":   int32(2),
+		"Width":     int16(2),
+		"Height":    int16(1),
+		"Length":    int16(1),
+		"Palette":   map[string]interface{}{"minecraft:air": int32(0), "minecraft:stone": int32(200)},
+		"BlockData": []byte{0xc8, 0x01, 0x80}, // 200, then a varint cut short
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := nbt.NewEncoder(gz).Encode(schematic, "Schematic"); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	gz.Close()
+
+	_, err := NewSchematicImporter().Import(&buf)
+	var formatErr *FormatError
+	if !errors.As(err, &formatErr) || formatErr.Format != "schematic" {
+		t.Errorf("expected schematic FormatError, got %v", err)
+	}
+}
+
+func TestSchematicUnsupportedVersion(t *testing.T) {
+	err := NewSchematicExporter(1).Export(NewVoxelGrid(1, 1, 1), nil, DitherConfig{}, &bytes.Buffer{})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+	if _, err := NewPipeline(WithSchematic(SchematicConfig{Version: 4})); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig from pipeline, got %v", err)
+	}
+}
+
+func TestSchematicAirModes(t *testing.T) {
+	vg := NewVoxelGrid(2, 1, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{128, 128, 128})
+	for mode, want := range map[string]string{AirModeDefault: "minecraft:air", AirModeAir: "minecraft:air", AirModeVoid: structureVoidID} {
+		exporter := NewSchematicExporter(2)
+		exporter.AirMode = mode
+		var buf bytes.Buffer
+		if err := exporter.Export(vg, nil, DitherConfig{}, &buf); err != nil {
+			t.Fatalf("%q: export: %v", mode, err)
+		}
+		palette, _ := decodeSchematic(t, buf.Bytes())["Palette"].(map[string]interface{})
+		if palette[want] != int32(0) {
+			t.Errorf("%q: palette = %v, want %s at index 0", mode, palette, want)
+		}
+	}
+
+	exporter := NewSchematicExporter(2)
+	exporter.AirMode = "vacuum"
+	if err := exporter.Export(vg, nil, DitherConfig{}, &bytes.Buffer{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+	if _, err := NewPipeline(WithSchematic(SchematicConfig{AirMode: "vacuum"})); err == nil {
+		t.Error("expected an error for an unknown air mode from the pipeline")
+	}
+}
+
+func TestSchematicPasteOffset(t *testing.T) {
+	vg := NewVoxelGrid(5, 4, 3)
+	vg.SetVoxel(0, 0, 0, [3]uint8{128, 128, 128})
+	tests := []struct {
+		origin string
+		offset [3]int
+		want   []int32
+	}{
+		{PasteOriginCorner, [3]int{}, []int32{0, 0, 0}},
+		{PasteOriginCorner, [3]int{1, -2, 3}, []int32{1, -2, 3}},
+		{PasteOriginCenterBottom, [3]int{}, []int32{-2, 0, -1}},
+		{PasteOriginCenter, [3]int{}, []int32{-2, -2, -1}},
+	}
+	for _, tt := range tests {
+		for _, version := range []int{2, 3} {
+			exporter := NewSchematicExporter(version)
+			exporter.PasteOrigin, exporter.Offset = tt.origin, tt.offset
+			var buf bytes.Buffer
+			if err := exporter.Export(vg, nil, DitherConfig{}, &buf); err != nil {
+				t.Fatalf("%q v%d: export: %v", tt.origin, version, err)
+			}
+			schematic := decodeSchematic(t, buf.Bytes())
+			if version == 3 {
+				schematic, _ = schematic["Schematic"].(map[string]interface{})
+			}
+			if got, _ := schematic["Offset"].([]int32); !slices.Equal(got, tt.want) {
+				t.Errorf("%q v%d: Offset = %v, want %v", tt.origin, version, got, tt.want)
+			}
+			metadata, _ := schematic["Metadata"].(map[string]interface{})
+			weOffset := []interface{}{metadata["WEOffsetX"], metadata["WEOffsetY"], metadata["WEOffsetZ"]}
+			if version == 2 && (weOffset[0] != tt.want[0] || weOffset[1] != tt.want[1] || weOffset[2] != tt.want[2]) {
+				t.Errorf("%q v2: WEOffset = %v, want %v", tt.origin, weOffset, tt.want)
+			}
+		}
+	}
+
+	exporter := NewSchematicExporter(2)
+	exporter.PasteOrigin, exporter.Offset = PasteOriginCenter, [3]int{1, 0, 0}
+	if err := exporter.Export(vg, nil, DitherConfig{}, &bytes.Buffer{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an offset with a named origin, got %v", err)
+	}
+	if _, err := NewPipeline(WithSchematic(SchematicConfig{PasteOrigin: "top"})); err == nil {
+		t.Error("expected an error for an unknown paste origin from the pipeline")
+	}
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+// MaxStructureSize is the largest extent along each axis that a vanilla structure
+// block saves or loads.
+const MaxStructureSize = 48
+
+// StructureExporterImpl implements StructureExporter for the vanilla structure
+// format (.nbt) read by structure blocks and /place template. Grids larger than
+// MaxStructureSize along any axis must be split with SplitStructure first. Like
+// SchematicExporterImpl, the block lookup is kept across exports with the same
+// palette, and an exporter must not be used by several goroutines at once.
+type StructureExporterImpl struct {
+	DataVersion int              // Minecraft data version recorded in the file (0 = 2975)
+	Matcher     ColorMatcher     // Matches colors that are not palette entries (default: CIELAB)
+	Detail      string           // Surface detail mode, such as DetailStairsSlabs (default DetailNone)
+	AirMode     string           // With AirModeAir, empty cells are written as air
+	Rotation    int              // Quarter turns about y, clockwise seen from above, turning block states too (0-3)
+	Progress    ProgressReporter // Optional progress callback
+
+	lookup blockLookup
+}
+
+// NewStructureExporter creates a new structure exporter.
+func NewStructureExporter() *StructureExporterImpl {
+	return &StructureExporterImpl{}
+}
+
+// structureFile is the root compound of a structure file.
+type structureFile struct {
+	DataVersion int32                 `nbt:"DataVersion"`
+	Size        []int32               `nbt:"size,list"`
+	Palette     []structureBlockState `nbt:"palette"`
+	Blocks      []structureBlock      `nbt:"blocks"`
+	Entities    []struct{}            `nbt:"entities"`
+}
+
+type structureBlockState struct {
+	Name       string                 `nbt:"Name"`
+	Properties sortedCompound[string] `nbt:"Properties,omitempty"`
+}
+
+type structureBlock struct {
+	State int32   `nbt:"state"`
+	Pos   []int32 `nbt:"pos,list"`
+}
+
+// Export writes a voxel grid as a gzip-compressed structure file. Empty cells are
+// left out, so loading the structure keeps the blocks already there, unless
+// AirMode is AirModeAir.
+func (e *StructureExporterImpl) Export(vg *VoxelGrid, palette *Palette, w io.Writer) error {
+	if vg.SizeX > MaxStructureSize || vg.SizeY > MaxStructureSize || vg.SizeZ > MaxStructureSize {
+		return fmt.Errorf("%w: %dx%dx%d exceeds the %d block structure limit; split it with SplitStructure",
+			ErrGridTooLarge, vg.SizeX, vg.SizeY, vg.SizeZ, MaxStructureSize)
+	}
+	if err := checkAirMode(e.AirMode); err != nil {
+		return err
+	}
+	if err := checkRotation(e.Rotation); err != nil {
+		return err
+	}
+	dataVersion := e.DataVersion
+	if dataVersion == 0 {
+		dataVersion = defaultSchematicDataVersion
+	}
+
+	e.lookup.prepare(palette, e.Matcher, e.Detail)
+	blockIDs, remap := rotateBlockIDs(e.lookup.blockIDs(), e.Rotation)
+	sizeX, sizeZ := rotatedSize(vg.SizeX, vg.SizeZ, e.Rotation)
+
+	structure := structureFile{
+		DataVersion: int32(dataVersion),
+		Size:        []int32{int32(sizeX), int32(vg.SizeY), int32(sizeZ)},
+		Blocks:      make([]structureBlock, 0, vg.Count()),
+		Entities:    []struct{}{},
+	}
+
+	// The structure palette holds only the blocks that are used
+	states := make(map[int32]int32)
+	var placed []bool
+	if e.AirMode == AirModeAir {
+		placed = make([]bool, vg.SizeX*vg.SizeY*vg.SizeZ)
+	}
+	tracker := startStage(e.Progress, StageExport, int64(vg.Count()))
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		tracker.add(1)
+		idx := remap[e.lookup.indexAt(vg, x, y, z, color)]
+		if idx == 0 {
+			return true // air
+		}
+		x, z = rotatePosition(x, z, vg.SizeX, vg.SizeZ, e.Rotation)
+		state, ok := states[idx]
+		if !ok {
+			state = int32(len(structure.Palette))
+			states[idx] = state
+			structure.Palette = append(structure.Palette, parseBlockState(blockIDs[idx]))
+		}
+		structure.Blocks = append(structure.Blocks, structureBlock{State: state, Pos: []int32{int32(x), int32(y), int32(z)}})
+		if placed != nil {
+			placed[schematicIndex(x, y, z, sizeX, sizeZ)] = true
+		}
+		return true
+	})
+	tracker.finish()
+
+	if placed != nil {
+		air := int32(len(structure.Palette))
+		structure.Palette = append(structure.Palette, parseBlockState(blockIDs[0]))
+		for y := 0; y < vg.SizeY; y++ {
+			for z := 0; z < sizeZ; z++ {
+				for x := 0; x < sizeX; x++ {
+					if !placed[schematicIndex(x, y, z, sizeX, sizeZ)] {
+						structure.Blocks = append(structure.Blocks, structureBlock{State: air, Pos: []int32{int32(x), int32(y), int32(z)}})
+					}
+				}
+			}
+		}
+	}
+
+	buf := getScratchBuffer()
+	defer putScratchBuffer(buf)
+	if err := nbt.NewEncoder(buf).Encode(structure, ""); err != nil {
+		return fmt.Errorf("failed to encode NBT: %w", err)
+	}
+
+	gzipWriter := getGzipWriter(w)
+	defer putGzipWriter(gzipWriter)
+	if _, err := gzipWriter.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to compress structure: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to compress structure: %w", err)
+	}
+	return nil
+}
+
+// parseBlockState splits a block state string such as
+// "minecraft:oak_log[axis=y]" into its name and properties.
+func parseBlockState(s string) structureBlockState {
+	name, props, ok := strings.Cut(s, "[")
+	if !ok {
+		return structureBlockState{Name: s}
+	}
+	state := structureBlockState{Name: name, Properties: make(map[string]string)}
+	for _, prop := range strings.Split(strings.TrimSuffix(props, "]"), ",") {
+		if key, value, ok := strings.Cut(prop, "="); ok {
+			state.Properties[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return state
+}
+
+// StructurePiece is one part of a grid split by SplitStructure.
+type StructurePiece struct {
+	X, Y, Z int        // Position of the piece's origin in the source grid
+	Grid    *VoxelGrid // Cells of the piece, relative to its origin
+}
+
+// SplitStructure cuts a grid into pieces of at most size cells along each axis,
+// in x, then y, then z order. Pieces without filled cells are left out.
+func SplitStructure(vg *VoxelGrid, size int) []StructurePiece {
+	if size <= 0 {
+		size = MaxStructureSize
+	}
+	piecesX := (vg.SizeX + size - 1) / size
+	piecesY := (vg.SizeY + size - 1) / size
+	piecesZ := (vg.SizeZ + size - 1) / size
+
+	pieceIndex := func(x, y, z int) int {
+		return x/size + piecesX*(y/size+piecesY*(z/size))
+	}
+	pieceOrigin := func(i int) (x, y, z int) {
+		return i % piecesX * size, i / piecesX % piecesY * size, i / (piecesX * piecesY) * size
+	}
+	counts := make([]int64, piecesX*piecesY*piecesZ)
+	vg.Range(func(x, y, z int, _ [3]uint8) bool {
+		counts[pieceIndex(x, y, z)]++
+		return true
+	})
+
+	grids := make([]*VoxelGrid, len(counts))
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		px, py, pz := pieceOrigin(i)
+		grids[i] = NewVoxelGridFor(
+			pieceExtent(vg.SizeX, px, size), pieceExtent(vg.SizeY, py, size), pieceExtent(vg.SizeZ, pz, size),
+			count, StorageConfig{})
+	}
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		grids[pieceIndex(x, y, z)].SetVoxel(x%size, y%size, z%size, color)
+		return true
+	})
+
+	var pieces []StructurePiece
+	for i, grid := range grids {
+		if grid != nil {
+			x, y, z := pieceOrigin(i)
+			pieces = append(pieces, StructurePiece{X: x, Y: y, Z: z, Grid: grid})
+		}
+	}
+	return pieces
+}
+
+// pieceExtent returns the size of the piece starting at origin along an axis of
+// the given total size.
+func pieceExtent(total, origin, size int) int {
+	if origin+size > total {
+		return total - origin
+	}
+	return size
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+func TestStructureExport(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{100, 80, 50}, Metadata: map[string]interface{}{"block_id": "minecraft:oak_log[axis=y]"}},
+		{RGB: [3]uint8{120, 120, 120}, Metadata: map[string]interface{}{"block_id": "minecraft:stone"}},
+	}}
+	vg := NewVoxelGrid(3, 2, 4)
+	vg.SetVoxel(0, 0, 0, [3]uint8{120, 120, 120})
+	vg.SetVoxel(2, 1, 3, [3]uint8{100, 80, 50})
+	vg.SetVoxel(1, 0, 2, [3]uint8{120, 120, 120})
+
+	var buf bytes.Buffer
+	if err := NewStructureExporter().Export(vg, palette, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	var structure structureFile
+	name, err := nbt.NewDecoder(gz).Decode(&structure)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if name != "" {
+		t.Errorf("root name = %q, want empty", name)
+	}
+	if structure.DataVersion != 2975 {
+		t.Errorf("DataVersion = %d, want 2975", structure.DataVersion)
+	}
+	if len(structure.Size) != 3 || structure.Size[0] != 3 || structure.Size[1] != 2 || structure.Size[2] != 4 {
+		t.Errorf("size = %v, want [3 2 4]", structure.Size)
+	}
+	if len(structure.Palette) != 2 {
+		t.Fatalf("palette = %v, want the two used blocks", structure.Palette)
+	}
+	if len(structure.Blocks) != 3 {
+		t.Fatalf("%d blocks, want 3", len(structure.Blocks))
+	}
+	for _, block := range structure.Blocks {
+		state := structure.Palette[block.State]
+		color, ok := vg.ColorAt(int(block.Pos[0]), int(block.Pos[1]), int(block.Pos[2]))
+		if !ok {
+			t.Errorf("block at %v is not a filled voxel", block.Pos)
+			continue
+		}
+		switch color {
+		case [3]uint8{100, 80, 50}:
+			if state.Name != "minecraft:oak_log" || state.Properties["axis"] != "y" {
+				t.Errorf("block at %v = %+v, want oak_log[axis=y]", block.Pos, state)
+			}
+		default:
+			if state.Name != "minecraft:stone" || len(state.Properties) != 0 {
+				t.Errorf("block at %v = %+v, want stone", block.Pos, state)
+			}
+		}
+	}
+}
+
+func TestStructureAirModeAir(t *testing.T) {
+	vg := NewVoxelGrid(2, 2, 1)
+	vg.SetVoxel(1, 1, 0, [3]uint8{128, 128, 128})
+
+	exporter := NewStructureExporter()
+	exporter.AirMode = AirModeAir
+	var buf bytes.Buffer
+	if err := exporter.Export(vg, nil, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	var structure structureFile
+	if _, err := nbt.NewDecoder(gz).Decode(&structure); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(structure.Blocks) != 4 {
+		t.Fatalf("%d blocks, want every cell", len(structure.Blocks))
+	}
+	air := 0
+	for _, block := range structure.Blocks {
+		if structure.Palette[block.State].Name == "minecraft:air" {
+			air++
+		}
+	}
+	if air != 3 {
+		t.Errorf("%d air blocks, want 3", air)
+	}
+}
+
+func TestStructureTooLarge(t *testing.T) {
+	vg := NewVoxelGrid(MaxStructureSize+1, 1, 1)
+	err := NewStructureExporter().Export(vg, nil, &bytes.Buffer{})
+	if !errors.Is(err, ErrGridTooLarge) {
+		t.Errorf("expected ErrGridTooLarge, got %v", err)
+	}
+}
+
+func TestSplitStructure(t *testing.T) {
+	vg := NewVoxelGrid(100, 10, 50)
+	vg.SetVoxel(0, 0, 0, [3]uint8{1, 2, 3})
+	vg.SetVoxel(99, 9, 49, [3]uint8{4, 5, 6})
+	vg.SetVoxel(50, 5, 10, [3]uint8{7, 8, 9})
+
+	pieces := SplitStructure(vg, MaxStructureSize)
+	if len(pieces) != 3 {
+		t.Fatalf("%d pieces, want the 3 non-empty ones", len(pieces))
+	}
+	total := 0
+	for _, piece := range pieces {
+		g := piece.Grid
+		if g.SizeX > MaxStructureSize || g.SizeY > MaxStructureSize || g.SizeZ > MaxStructureSize {
+			t.Errorf("piece at (%d,%d,%d) is %dx%dx%d", piece.X, piece.Y, piece.Z, g.SizeX, g.SizeY, g.SizeZ)
+		}
+		g.Range(func(x, y, z int, color [3]uint8) bool {
+			if c, ok := vg.ColorAt(piece.X+x, piece.Y+y, piece.Z+z); !ok || c != color {
+				t.Errorf("piece cell (%d,%d,%d) does not match the source grid", piece.X+x, piece.Y+y, piece.Z+z)
+			}
+			total++
+			return true
+		})
+	}
+	if total != vg.Count() {
+		t.Errorf("pieces hold %d voxels, want %d", total, vg.Count())
+	}
+	// The last piece along x holds the 4 leftover columns
+	if last := pieces[len(pieces)-1]; last.X != 96 || last.Grid.SizeX != 4 || last.Z != 48 || last.Grid.SizeZ != 2 {
+		t.Errorf("last piece at x=%d z=%d is %d wide and %d long", last.X, last.Z, last.Grid.SizeX, last.Grid.SizeZ)
+	}
+}
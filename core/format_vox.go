@@ -1,13 +1,27 @@
 package core
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 )
 
-// VOXExporterImpl handles MagicaVoxel .vox file format export.
-type VOXExporterImpl struct{}
+// VOXExporterImpl handles MagicaVoxel .vox file format export. MagicaVoxel is
+// Z-up, so the grid's y axis is written as VOX z and its z axis, reversed to keep
+// the model from being mirrored, as VOX y. A VOX model holds at most 256 voxels
+// along each axis, so larger grids are split into models of up to 256^3 placed
+// side by side by a scene graph. The grid's Materials are written as MATL chunks
+// on the palette entries of their colors, so emissive parts glow in MagicaVoxel's
+// renderer. A VOX palette holds 255 colors; grids with more are written with the
+// 255 representing them best, found by k-means clustering in CIELAB.
+type VOXExporterImpl struct {
+	Progress ProgressReporter // Optional progress callback
+}
 
 // NewVOXExporter creates a new VOX exporter.
 func NewVOXExporter() *VOXExporterImpl {
@@ -24,84 +38,196 @@ func (e *VOXExporterImpl) Export(vg *VoxelGrid, w io.Writer) error {
 	// - XYZI chunk (voxel data)
 	// - RGBA chunk (palette)
 	
-	// Write magic number
-	if _, err := w.Write([]byte("VOX ")); err != nil {
-		return err
-	}
-	
-	// Write version (150)
-	if err := binary.Write(w, binary.LittleEndian, int32(150)); err != nil {
-		return err
+	// Grids with more colors than the palette holds are reduced to the ones
+	// representing them best
+	counts := make(map[[3]uint8]int)
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		counts[color]++
+		return true
+	})
+	if len(counts) > maxVOXColors {
+		vg = quantizeVOXGrid(vg, counts)
 	}
 	
 	// Create palette from voxels
 	palette := make(map[[3]uint8]uint8)
-	paletteIndex := uint8(1) // Index 0 is reserved for empty
-	
-	for _, voxel := range vg.Voxels {
-		if _, exists := palette[voxel.Color]; !exists {
-			palette[voxel.Color] = paletteIndex
+	paletteIndex := 1 // Index 0 is reserved for empty
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		if _, exists := palette[color]; !exists {
+			palette[color] = uint8(paletteIndex)
 			paletteIndex++
-			if paletteIndex == 0 { // Overflow (256 colors max)
-				break
-			}
 		}
+		return true
+	})
+	
+	// Buffer MAIN's children so its header carries their real size
+	children := getScratchBuffer()
+	defer putScratchBuffer(children)
+	tiles := e.splitModels(vg, palette)
+	for _, tile := range tiles {
+		writeVOXModel(children, tile)
+	}
+	if len(tiles) > 1 {
+		writeVOXScene(children, tiles)
 	}
+	e.writeRGBAChunk(children, palette)
+	writeVOXMaterials(children, palette, vg.Materials)
 	
-	// Write MAIN chunk
-	if err := e.writeChunk(w, "MAIN", []byte{}, func(w io.Writer) error {
-		// Write SIZE chunk
-		if err := e.writeSizeChunk(w, vg); err != nil {
-			return err
+	// Write magic number and version (150)
+	header := []byte("VOX ")
+	header = binary.LittleEndian.AppendUint32(header, 150)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	return writeVOXChunk(w, "MAIN", nil, children.Bytes())
+}
+
+// maxVOXModelSize is the largest model MagicaVoxel accepts along each axis.
+const maxVOXModelSize = 256
+
+// maxVOXColors is the number of colors a VOX palette holds; index 0 is empty.
+const maxVOXColors = 255
+
+// quantizeVOXGrid returns a copy of vg whose colors, counted in counts, are
+// reduced to maxVOXColors by k-means clustering in CIELAB. Materials move to
+// their colors' replacements, the most common color's winning where several
+// meet.
+func quantizeVOXGrid(vg *VoxelGrid, counts map[[3]uint8]int) *VoxelGrid {
+	remap := quantizeColors(counts, maxVOXColors)
+	quantized := vg.emptyLike()
+	quantized.Materials = nil
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		quantized.SetVoxel(x, y, z, remap[color])
+		return true
+	})
+	for _, color := range colorsByCount(counts) {
+		if m, ok := vg.Materials[color]; ok {
+			if _, taken := quantized.Materials[remap[color]]; !taken {
+				quantized.setMaterial(remap[color], m)
+			}
 		}
-		
-		// Write XYZI chunk
-		if err := e.writeXYZIChunk(w, vg, palette); err != nil {
-			return err
+	}
+	return quantized
+}
+
+// voxTile is one model of an exported grid: its position and size in VOX
+// coordinates and its XYZI content, the voxel count followed by x, y, z and
+// color index per voxel.
+type voxTile struct {
+	origin [3]int
+	size   [3]int
+	xyzi   []byte
+}
+
+// splitModels cuts the grid, in VOX coordinates, into tiles of at most
+// maxVOXModelSize per axis. Every tile is kept, even empty ones, so the models
+// span the whole grid.
+func (e *VOXExporterImpl) splitModels(vg *VoxelGrid, palette map[[3]uint8]uint8) []voxTile {
+	dims := [3]int{vg.SizeX, vg.SizeZ, vg.SizeY}
+	var counts [3]int
+	for axis, d := range dims {
+		counts[axis] = max(1, (d+maxVOXModelSize-1)/maxVOXModelSize)
+	}
+	tiles := make([]voxTile, 0, counts[0]*counts[1]*counts[2])
+	for tz := 0; tz < counts[2]; tz++ {
+		for ty := 0; ty < counts[1]; ty++ {
+			for tx := 0; tx < counts[0]; tx++ {
+				var tile voxTile
+				for axis, t := range [3]int{tx, ty, tz} {
+					tile.origin[axis] = t * maxVOXModelSize
+					tile.size[axis] = max(1, min(maxVOXModelSize, dims[axis]-tile.origin[axis]))
+				}
+				tile.xyzi = make([]byte, 4, 4+4*vg.Count()/cap(tiles))
+				tiles = append(tiles, tile)
+			}
 		}
-		
-		// Write RGBA chunk
-		return e.writeRGBAChunk(w, palette)
-	}); err != nil {
-		return err
 	}
 	
-	return nil
+	tracker := startStage(e.Progress, StageExport, int64(vg.Count()))
+	defer tracker.finish()
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		tracker.add(1)
+		vx, vy, vz := voxFromGrid(vg, x, y, z)
+		tx, ty, tz := vx/maxVOXModelSize, vy/maxVOXModelSize, vz/maxVOXModelSize
+		tile := &tiles[tx+counts[0]*(ty+counts[1]*tz)]
+		tile.xyzi = append(tile.xyzi, byte(vx%maxVOXModelSize), byte(vy%maxVOXModelSize), byte(vz%maxVOXModelSize), palette[color])
+		return true
+	})
+	for i := range tiles {
+		binary.LittleEndian.PutUint32(tiles[i].xyzi, uint32(len(tiles[i].xyzi)/4-1))
+	}
+	return tiles
 }
 
-// writeSizeChunk writes the SIZE chunk.
-func (e *VOXExporterImpl) writeSizeChunk(w io.Writer, vg *VoxelGrid) error {
-	sizeData := make([]byte, 12)
-	binary.LittleEndian.PutUint32(sizeData[0:4], uint32(vg.SizeX))
-	binary.LittleEndian.PutUint32(sizeData[4:8], uint32(vg.SizeY))
-	binary.LittleEndian.PutUint32(sizeData[8:12], uint32(vg.SizeZ))
-	
-	return e.writeChunk(w, "SIZE", sizeData, nil)
+// writeVOXModel writes the SIZE and XYZI chunks of a tile.
+func writeVOXModel(w *bytes.Buffer, tile voxTile) {
+	sizeData := make([]byte, 0, 12)
+	for _, n := range tile.size {
+		sizeData = binary.LittleEndian.AppendUint32(sizeData, uint32(n))
+	}
+	writeVOXChunk(w, "SIZE", sizeData, nil)
+	writeVOXChunk(w, "XYZI", tile.xyzi, nil)
 }
 
-// writeXYZIChunk writes the XYZI chunk.
-func (e *VOXExporterImpl) writeXYZIChunk(w io.Writer, vg *VoxelGrid, palette map[[3]uint8]uint8) error {
-	// Count voxels
-	numVoxels := len(vg.Voxels)
-	
-	// Create XYZI data
-	xyziData := make([]byte, 4+numVoxels*4)
-	binary.LittleEndian.PutUint32(xyziData[0:4], uint32(numVoxels))
-	
-	i := 4
-	for _, voxel := range vg.Voxels {
-		xyziData[i] = byte(voxel.X)
-		xyziData[i+1] = byte(voxel.Y)
-		xyziData[i+2] = byte(voxel.Z)
-		xyziData[i+3] = palette[voxel.Color]
-		i += 4
+// writeVOXScene writes the scene graph placing the tiles: a root transform
+// (node 0) over a group (node 1) holding a transform and shape node pair per
+// tile. MagicaVoxel positions a model by its center, rounded down.
+func writeVOXScene(w *bytes.Buffer, tiles []voxTile) {
+	writeVOXChunk(w, "nTRN", voxTransformNode(0, 1, nil), nil)
+	group := binary.LittleEndian.AppendUint32(nil, 1)
+	group = appendVOXDict(group)
+	group = binary.LittleEndian.AppendUint32(group, uint32(len(tiles)))
+	for i := range tiles {
+		group = binary.LittleEndian.AppendUint32(group, uint32(2+2*i))
 	}
+	writeVOXChunk(w, "nGRP", group, nil)
 	
-	return e.writeChunk(w, "XYZI", xyziData, nil)
+	for i, tile := range tiles {
+		var center [3]int
+		for axis := range center {
+			center[axis] = tile.origin[axis] + tile.size[axis]/2
+		}
+		frame := []string{"_t", fmt.Sprintf("%d %d %d", center[0], center[1], center[2])}
+		writeVOXChunk(w, "nTRN", voxTransformNode(2+2*i, 3+2*i, frame), nil)
+		
+		shape := binary.LittleEndian.AppendUint32(nil, uint32(3+2*i))
+		shape = appendVOXDict(shape)
+		shape = binary.LittleEndian.AppendUint32(shape, 1) // one model
+		shape = binary.LittleEndian.AppendUint32(shape, uint32(i))
+		shape = appendVOXDict(shape)
+		writeVOXChunk(w, "nSHP", shape, nil)
+	}
+}
+
+// voxTransformNode encodes an nTRN chunk with a single frame holding the given
+// key/value pairs.
+func voxTransformNode(id, child int, frame []string) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(id))
+	b = appendVOXDict(b)
+	b = binary.LittleEndian.AppendUint32(b, uint32(child))
+	b = binary.LittleEndian.AppendUint32(b, math.MaxUint32) // reserved, -1
+	b = binary.LittleEndian.AppendUint32(b, 0)              // layer
+	b = binary.LittleEndian.AppendUint32(b, 1)              // frames
+	return appendVOXDict(b, frame...)
+}
+
+// appendVOXDict appends a DICT of key/value pairs.
+func appendVOXDict(b []byte, pairs ...string) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(pairs)/2))
+	for _, s := range pairs {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+		b = append(b, s...)
+	}
+	return b
+}
+
+// voxFromGrid maps a Y-up grid position to Z-up VOX coordinates.
+func voxFromGrid(vg *VoxelGrid, x, y, z int) (int, int, int) {
+	return x, vg.SizeZ - 1 - z, y
 }
 
 // writeRGBAChunk writes the RGBA chunk.
-func (e *VOXExporterImpl) writeRGBAChunk(w io.Writer, palette map[[3]uint8]uint8) error {
+func (e *VOXExporterImpl) writeRGBAChunk(w *bytes.Buffer, palette map[[3]uint8]uint8) {
 	// Create RGBA data (256 colors)
 	rgbaData := make([]byte, 256*4)
 	
@@ -113,59 +239,93 @@ func (e *VOXExporterImpl) writeRGBAChunk(w io.Writer, palette map[[3]uint8]uint8
 		rgbaData[i*4+3] = 255
 	}
 	
-	// Fill in actual colors
+	// Fill in actual colors; chunk entry i holds palette index i+1
 	for color, index := range palette {
-		idx := int(index) * 4
+		idx := (int(index) - 1) * 4
 		rgbaData[idx] = color[0]
 		rgbaData[idx+1] = color[1]
 		rgbaData[idx+2] = color[2]
 		rgbaData[idx+3] = 255
 	}
 	
-	return e.writeChunk(w, "RGBA", rgbaData, nil)
+	writeVOXChunk(w, "RGBA", rgbaData, nil)
 }
 
-// writeChunk writes a VOX chunk.
-func (e *VOXExporterImpl) writeChunk(w io.Writer, id string, content []byte, childWriter func(io.Writer) error) error {
-	// Write chunk ID
-	if _, err := w.Write([]byte(id)); err != nil {
-		return err
+// writeVOXMaterials writes a MATL chunk for every palette entry whose color has a
+// material, and a NOTE chunk naming the palette rows of eight entries that hold
+// them by material type.
+func writeVOXMaterials(w *bytes.Buffer, palette map[[3]uint8]uint8, materials map[[3]uint8]VoxelMaterial) {
+	if len(materials) == 0 {
+		return
 	}
-	
-	// Calculate child content size
-	childSize := int32(0)
-	if childWriter != nil {
-		// For MAIN chunk, we need to calculate child size
-		// This is a simplification; proper implementation would buffer
-		childSize = 0 // Will be updated when children are written
+	var byIndex [256]*VoxelMaterial
+	found := false
+	for color, index := range palette {
+		if m, ok := materials[color]; ok {
+			byIndex[index] = &m
+			found = true
+		}
 	}
-	
-	// Write content size
-	if err := binary.Write(w, binary.LittleEndian, int32(len(content))); err != nil {
-		return err
+	if !found {
+		return
 	}
 	
-	// Write children size
-	if err := binary.Write(w, binary.LittleEndian, childSize); err != nil {
-		return err
+	var notes [32][]string
+	for index, m := range byIndex {
+		if m == nil {
+			continue
+		}
+		kind, props := voxMaterialProperties(*m)
+		content := binary.LittleEndian.AppendUint32(nil, uint32(index))
+		writeVOXChunk(w, "MATL", appendVOXDict(content, append([]string{"_type", "_" + kind}, props...)...), nil)
+		row := &notes[(index-1)/8]
+		if !slices.Contains(*row, kind) {
+			*row = append(*row, kind)
+		}
 	}
-	
-	// Write content
-	if len(content) > 0 {
-		if _, err := w.Write(content); err != nil {
+	note := binary.LittleEndian.AppendUint32(nil, uint32(len(notes)))
+	for _, kinds := range notes {
+		name := strings.Join(kinds, " ")
+		note = binary.LittleEndian.AppendUint32(note, uint32(len(name)))
+		note = append(note, name...)
+	}
+	writeVOXChunk(w, "NOTE", note, nil)
+}
+
+// voxMaterialProperties returns the MATL type of a material, emit, glass or
+// metal in that order of precedence, and its properties as key/value pairs.
+func voxMaterialProperties(m VoxelMaterial) (string, []string) {
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', 3, 64)
+	}
+	switch {
+	case m.Emissive():
+		return "emit", []string{"_emit", format(m.Emission), "_flux", "1"}
+	case m.Translucent():
+		return "glass", []string{"_trans", format(1 - m.Opacity), "_ior", "0.3"}
+	default:
+		return "metal", []string{"_metal", format(m.Metallic), "_rough", format(m.Roughness)}
+	}
+}
+
+// writeVOXChunk writes a VOX chunk whose children, already encoded, follow its
+// content. Writes to a bytes.Buffer cannot fail.
+func writeVOXChunk(w io.Writer, id string, content, children []byte) error {
+	header := make([]byte, 0, 12)
+	header = append(header, id...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(content)))
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(children)))
+	for _, b := range [][]byte{header, content, children} {
+		if _, err := w.Write(b); err != nil {
 			return err
 		}
 	}
-	
-	// Write children
-	if childWriter != nil {
-		return childWriter(w)
-	}
-	
 	return nil
 }
 
-// VOXImporterImpl imports voxel grids from MagicaVoxel .vox format.
+// VOXImporterImpl imports voxel grids from MagicaVoxel .vox format. Files with
+// several models are merged into one grid, placed by the scene graph's transforms
+// when the file has one.
 type VOXImporterImpl struct{}
 
 // NewVOXImporter creates a new VOX importer.
@@ -173,26 +333,464 @@ func NewVOXImporter() *VOXImporterImpl {
 	return &VOXImporterImpl{}
 }
 
+// maxVOXChunkSize bounds a chunk's content; the largest valid XYZI chunk for a
+// 256^3 model is 64MB.
+const maxVOXChunkSize = 1 << 28
+
+// maxVOXSceneExtent bounds the merged grid along each axis, rejecting scene
+// graphs that scatter models across absurd distances.
+const maxVOXSceneExtent = 1 << 16
+
+// voxModel is one SIZE/XYZI pair of a VOX file.
+type voxModel struct {
+	size   [3]int
+	voxels []byte // x, y, z, color index per voxel
+}
+
+// voxNode is a node of the VOX scene graph (nTRN, nGRP or nSHP chunk).
+type voxNode struct {
+	kind      string
+	children  []int
+	model     int
+	rotation  [3][3]int
+	translate [3]int
+}
+
+// voxTransform maps model coordinates to scene coordinates.
+type voxTransform struct {
+	rotation  [3][3]int
+	translate [3]int
+}
+
+var identityVOXTransform = voxTransform{rotation: [3][3]int{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}}
+
+func (t voxTransform) apply(p [3]int) [3]int {
+	var out [3]int
+	for i := 0; i < 3; i++ {
+		out[i] = t.rotation[i][0]*p[0] + t.rotation[i][1]*p[1] + t.rotation[i][2]*p[2] + t.translate[i]
+	}
+	return out
+}
+
+// then returns the transform applying child first, then t.
+func (t voxTransform) then(child voxTransform) voxTransform {
+	var out voxTransform
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				out.rotation[i][j] += t.rotation[i][k] * child.rotation[k][j]
+			}
+		}
+	}
+	out.translate = t.apply(child.translate)
+	return out
+}
+
+// voxReader reads VOX chunks, tracking the offset for error reports.
+type voxReader struct {
+	r         *countingReader
+	models    []voxModel
+	pack      int
+	colors    [256][3]uint8
+	nodes     map[int]*voxNode
+	materials map[int]VoxelMaterial // Non-diffuse MATL chunks by palette index
+}
+
 // Import reads a VOX file and returns a voxel grid.
 func (imp *VOXImporterImpl) Import(r io.Reader) (*VoxelGrid, error) {
+	vr := &voxReader{r: &countingReader{r: r}, colors: defaultVOXPalette(), nodes: make(map[int]*voxNode)}
+	
 	// Read magic number
 	magic := make([]byte, 4)
-	if _, err := io.ReadFull(r, magic); err != nil {
-		return nil, err
+	if _, err := io.ReadFull(vr.r, magic); err != nil {
+		return nil, &FormatError{Format: "vox", Offset: 0, Msg: "missing header", Err: err}
 	}
 	if string(magic) != "VOX " {
-		return nil, fmt.Errorf("invalid VOX file: wrong magic number")
+		return nil, &FormatError{Format: "vox", Offset: 0, Msg: "wrong magic number"}
 	}
 	
 	// Read version
 	var version int32
-	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+	if err := binary.Read(vr.r, binary.LittleEndian, &version); err != nil {
+		return nil, &FormatError{Format: "vox", Offset: 4, Msg: "missing version", Err: err}
+	}
+	
+	// The MAIN chunk holds every other chunk as a child
+	id, content, children, err := vr.readChunkHeader()
+	if err != nil {
+		return nil, err
+	}
+	if id != "MAIN" {
+		return nil, vr.errorf("expected MAIN chunk, got %q", id)
+	}
+	if _, err := io.CopyN(io.Discard, vr.r, content); err != nil {
+		return nil, vr.wrap("truncated MAIN chunk", err)
+	}
+	// Some writers leave MAIN's children size at 0; then children run to the end of the file
+	if err := vr.readChildren(children, children == 0); err != nil {
 		return nil, err
 	}
 	
-	// Read chunks
-	// This is a simplified implementation
-	// A full implementation would parse all chunks properly
+	if len(vr.models) == 0 {
+		return nil, vr.errorf("no models")
+	}
+	return vr.buildGrid()
+}
+
+func (vr *voxReader) errorf(format string, args ...interface{}) error {
+	return &FormatError{Format: "vox", Offset: vr.r.n, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (vr *voxReader) wrap(msg string, err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return &FormatError{Format: "vox", Offset: vr.r.n, Msg: msg, Err: err}
+}
+
+func (vr *voxReader) readChunkHeader() (id string, content, children int64, err error) {
+	var header [12]byte
+	if _, err := io.ReadFull(vr.r, header[:4]); err != nil {
+		return "", 0, 0, err
+	}
+	if _, err := io.ReadFull(vr.r, header[4:]); err != nil {
+		return "", 0, 0, vr.wrap("truncated chunk header", err)
+	}
+	content = int64(int32(binary.LittleEndian.Uint32(header[4:8])))
+	children = int64(int32(binary.LittleEndian.Uint32(header[8:12])))
+	if content < 0 || content > maxVOXChunkSize || children < 0 {
+		return "", 0, 0, vr.errorf("invalid size in %q chunk", header[:4])
+	}
+	return string(header[:4]), content, children, nil
+}
+
+// readChildren reads chunks totalling size bytes, or up to the end of the input
+// when toEOF is set.
+func (vr *voxReader) readChildren(size int64, toEOF bool) error {
+	end := vr.r.n + size
+	for toEOF || vr.r.n < end {
+		start := vr.r.n
+		id, content, children, err := vr.readChunkHeader()
+		if err != nil {
+			if toEOF && err == io.EOF && vr.r.n == start {
+				return nil
+			}
+			if _, ok := err.(*FormatError); ok {
+				return err
+			}
+			return vr.wrap("truncated chunk header", err)
+		}
+		data := make([]byte, content)
+		if _, err := io.ReadFull(vr.r, data); err != nil {
+			return vr.wrap(fmt.Sprintf("truncated %s chunk", id), err)
+		}
+		if err := vr.parseChunk(id, data); err != nil {
+			return &FormatError{Format: "vox", Offset: start, Msg: fmt.Sprintf("%s chunk", id), Err: err}
+		}
+		if err := vr.readChildren(children, false); err != nil {
+			return err
+		}
+	}
+	if vr.r.n != end {
+		return vr.errorf("chunk children overrun their parent")
+	}
+	return nil
+}
+
+func (vr *voxReader) parseChunk(id string, data []byte) error {
+	cr := &chunkReader{data: data}
+	switch id {
+	case "PACK":
+		vr.pack = int(cr.int32())
+	case "SIZE":
+		size := [3]int{int(cr.int32()), int(cr.int32()), int(cr.int32())}
+		for _, n := range size {
+			if n <= 0 || n > 1024 {
+				return fmt.Errorf("invalid model size %v", size)
+			}
+		}
+		vr.models = append(vr.models, voxModel{size: size})
+	case "XYZI":
+		if len(vr.models) == 0 || vr.models[len(vr.models)-1].voxels != nil {
+			return fmt.Errorf("XYZI without a preceding SIZE")
+		}
+		n := int64(cr.int32())
+		if n < 0 || n*4 > int64(len(cr.data)-cr.pos) {
+			return fmt.Errorf("voxel count %d exceeds chunk size", n)
+		}
+		vr.models[len(vr.models)-1].voxels = cr.bytes(int(n * 4))
+	case "RGBA":
+		// Chunk entry i is palette index i+1; the last entry is unused
+		for i := 0; i < 255 && cr.pos+4 <= len(cr.data); i++ {
+			c := cr.bytes(4)
+			vr.colors[i+1] = [3]uint8{c[0], c[1], c[2]}
+		}
+	case "nTRN":
+		node := &voxNode{kind: id, rotation: identityVOXTransform.rotation}
+		nodeID := int(cr.int32())
+		cr.dict()
+		node.children = []int{int(cr.int32())}
+		cr.int32() // reserved
+		cr.int32() // layer
+		if frames := cr.int32(); frames > 0 {
+			frame := cr.dict()
+			if t, ok := frame["_t"]; ok {
+				if _, err := fmt.Sscan(t, &node.translate[0], &node.translate[1], &node.translate[2]); err != nil {
+					return fmt.Errorf("invalid translation %q", t)
+				}
+			}
+			if r, ok := frame["_r"]; ok {
+				var err error
+				if node.rotation, err = parseVOXRotation(r); err != nil {
+					return err
+				}
+			}
+		}
+		vr.nodes[nodeID] = node
+	case "MATL":
+		index := int(cr.int32())
+		props := cr.dict()
+		if m, ok := parseVOXMaterial(props); ok && index > 0 && index < 256 {
+			if vr.materials == nil {
+				vr.materials = make(map[int]VoxelMaterial)
+			}
+			vr.materials[index] = m
+		}
+	case "nGRP":
+		node := &voxNode{kind: id}
+		nodeID := int(cr.int32())
+		cr.dict()
+		n := int(cr.int32())
+		for i := 0; i < n && cr.err == nil; i++ {
+			node.children = append(node.children, int(cr.int32()))
+		}
+		vr.nodes[nodeID] = node
+	case "nSHP":
+		node := &voxNode{kind: id}
+		nodeID := int(cr.int32())
+		cr.dict()
+		if cr.int32() > 0 {
+			node.model = int(cr.int32())
+		}
+		vr.nodes[nodeID] = node
+	}
+	// LAYR, rOBJ, rCAM, NOTE, IMAP and unknown chunks do not affect the grid
+	return cr.err
+}
+
+// parseVOXMaterial reads the properties of an emit, glass or metal MATL chunk;
+// other types, such as the default diffuse, report false.
+func parseVOXMaterial(props map[string]string) (VoxelMaterial, bool) {
+	value := func(key string, def float64) float64 {
+		if v, err := strconv.ParseFloat(props[key], 64); err == nil {
+			return v
+		}
+		return def
+	}
+	switch props["_type"] {
+	case "_emit":
+		return VoxelMaterial{Emission: value("_emit", 1)}, true
+	case "_glass":
+		return VoxelMaterial{Opacity: 1 - value("_trans", 0.5)}, true
+	case "_metal":
+		return VoxelMaterial{Metallic: value("_metal", 1), Roughness: value("_rough", 0.5)}, true
+	}
+	return VoxelMaterial{}, false
+}
+
+// parseVOXRotation decodes the packed rotation byte of a transform frame: bits 0-1
+// and 2-3 give the column of the nonzero entry in rows 0 and 1, and bits 4-6 the
+// signs of rows 0-2.
+func parseVOXRotation(s string) ([3][3]int, error) {
+	var rotation [3][3]int
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 || v > 127 {
+		return rotation, fmt.Errorf("invalid rotation %q", s)
+	}
+	c0, c1 := v&3, (v>>2)&3
+	if c0 > 2 || c1 > 2 || c0 == c1 {
+		return rotation, fmt.Errorf("invalid rotation %q", s)
+	}
+	cols := [3]int{c0, c1, 3 - c0 - c1}
+	for row, col := range cols {
+		rotation[row][col] = 1
+		if v&(1<<(4+row)) != 0 {
+			rotation[row][col] = -1
+		}
+	}
+	return rotation, nil
+}
+
+// placedModel is a model with its transform into the merged grid.
+type placedModel struct {
+	model     *voxModel
+	transform voxTransform
+}
+
+// placements returns every model with its scene transform. Without a scene graph
+// each model is placed at the origin.
+func (vr *voxReader) placements() []placedModel {
+	if _, ok := vr.nodes[0]; !ok {
+		placed := make([]placedModel, len(vr.models))
+		for i := range vr.models {
+			placed[i] = placedModel{model: &vr.models[i], transform: identityVOXTransform}
+		}
+		return placed
+	}
+	
+	var placed []placedModel
+	var visit func(id int, parent voxTransform, depth int)
+	visit = func(id int, parent voxTransform, depth int) {
+		node, ok := vr.nodes[id]
+		if !ok || depth > 64 {
+			return
+		}
+		switch node.kind {
+		case "nTRN":
+			t := parent.then(voxTransform{rotation: node.rotation, translate: node.translate})
+			for _, child := range node.children {
+				visit(child, t, depth+1)
+			}
+		case "nGRP":
+			for _, child := range node.children {
+				visit(child, parent, depth+1)
+			}
+		case "nSHP":
+			if node.model >= 0 && node.model < len(vr.models) {
+				m := &vr.models[node.model]
+				// Scene transforms position a model's center; shift its voxels to be centered
+				center := voxTransform{rotation: identityVOXTransform.rotation,
+					translate: [3]int{-m.size[0] / 2, -m.size[1] / 2, -m.size[2] / 2}}
+				placed = append(placed, placedModel{model: m, transform: parent.then(center)})
+			}
+		}
+	}
+	visit(0, identityVOXTransform, 0)
+	return placed
+}
+
+// buildGrid merges the placed models into one grid whose origin is the minimum
+// corner of their bounding boxes.
+func (vr *voxReader) buildGrid() (*VoxelGrid, error) {
+	placed := vr.placements()
+	if len(placed) == 0 {
+		return nil, vr.errorf("scene graph places no models")
+	}
+	
+	lo := [3]int{math.MaxInt32, math.MaxInt32, math.MaxInt32}
+	hi := [3]int{math.MinInt32, math.MinInt32, math.MinInt32}
+	var total int64
+	for _, p := range placed {
+		for corner := 0; corner < 8; corner++ {
+			c := [3]int{}
+			for axis := 0; axis < 3; axis++ {
+				if corner&(1<<axis) != 0 {
+					c[axis] = p.model.size[axis] - 1
+				}
+			}
+			w := p.transform.apply(c)
+			for axis := 0; axis < 3; axis++ {
+				lo[axis] = min(lo[axis], w[axis])
+				hi[axis] = max(hi[axis], w[axis])
+			}
+		}
+		total += int64(len(p.model.voxels) / 4)
+	}
+	for axis := 0; axis < 3; axis++ {
+		if hi[axis]-lo[axis] >= maxVOXSceneExtent {
+			return nil, vr.errorf("scene spans %d voxels along axis %d", hi[axis]-lo[axis]+1, axis)
+		}
+	}
 	
-	return nil, fmt.Errorf("VOX import not fully implemented yet")
+	// VOX is Z-up: its z becomes the grid's y and its y, reversed, the grid's z
+	depth := hi[1] - lo[1] + 1
+	vg := NewVoxelGridFor(hi[0]-lo[0]+1, hi[2]-lo[2]+1, depth, total, StorageConfig{})
+	for _, p := range placed {
+		v := p.model.voxels
+		for i := 0; i+4 <= len(v); i += 4 {
+			if v[i+3] == 0 {
+				continue
+			}
+			w := p.transform.apply([3]int{int(v[i]), int(v[i+1]), int(v[i+2])})
+			vg.SetVoxel(w[0]-lo[0], w[2]-lo[2], depth-1-(w[1]-lo[1]), vr.colors[v[i+3]])
+		}
+	}
+	for index, m := range vr.materials {
+		vg.setMaterial(vr.colors[index], m)
+	}
+	return vg, nil
+}
+
+// chunkReader decodes little-endian values from chunk content, recording the
+// first error instead of returning one from every call.
+type chunkReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (cr *chunkReader) bytes(n int) []byte {
+	if cr.err != nil {
+		return nil
+	}
+	if n < 0 || cr.pos+n > len(cr.data) {
+		cr.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := cr.data[cr.pos : cr.pos+n]
+	cr.pos += n
+	return b
+}
+
+func (cr *chunkReader) int32() int32 {
+	b := cr.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+func (cr *chunkReader) string() string {
+	return string(cr.bytes(int(cr.int32())))
+}
+
+func (cr *chunkReader) dict() map[string]string {
+	n := int(cr.int32())
+	d := make(map[string]string)
+	for i := 0; i < n && cr.err == nil; i++ {
+		key := cr.string()
+		d[key] = cr.string()
+	}
+	return d
+}
+
+// defaultVOXPalette returns MagicaVoxel's built-in palette, used when a file has
+// no RGBA chunk: the 6x6x6 color cube without black, then ten-step ramps of red,
+// green, blue and gray.
+func defaultVOXPalette() [256][3]uint8 {
+	var palette [256][3]uint8
+	i := 1
+	for b := 5; b >= 0; b-- {
+		for g := 5; g >= 0; g-- {
+			for r := 5; r >= 0; r-- {
+				if r == 0 && g == 0 && b == 0 {
+					continue
+				}
+				palette[i] = [3]uint8{uint8(r * 0x33), uint8(g * 0x33), uint8(b * 0x33)}
+				i++
+			}
+		}
+	}
+	ramp := []uint8{0xee, 0xdd, 0xbb, 0xaa, 0x88, 0x77, 0x55, 0x44, 0x22, 0x11}
+	for channel := 0; channel < 4; channel++ {
+		for _, v := range ramp {
+			if channel == 3 {
+				palette[i] = [3]uint8{v, v, v}
+			} else {
+				palette[i][channel] = v
+			}
+			i++
+		}
+	}
+	return palette
 }
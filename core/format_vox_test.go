@@ -0,0 +1,354 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// voxChunk encodes a chunk with the given content and children.
+func voxChunk(id string, content []byte, children ...[]byte) []byte {
+	var kids []byte
+	for _, c := range children {
+		kids = append(kids, c...)
+	}
+	out := []byte(id)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(content)))
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(kids)))
+	out = append(out, content...)
+	return append(out, kids...)
+}
+
+func voxFile(children ...[]byte) []byte {
+	return append([]byte("VOX \x96\x00\x00\x00"), voxChunk("MAIN", nil, children...)...)
+}
+
+func voxInts(values ...int32) []byte {
+	var out []byte
+	for _, v := range values {
+		out = binary.LittleEndian.AppendUint32(out, uint32(v))
+	}
+	return out
+}
+
+// voxDict encodes a DICT of key/value pairs.
+func voxDict(pairs ...string) []byte {
+	out := voxInts(int32(len(pairs) / 2))
+	for _, s := range pairs {
+		out = append(out, voxInts(int32(len(s)))...)
+		out = append(out, s...)
+	}
+	return out
+}
+
+func voxModelChunks(size [3]int32, voxels ...[4]byte) [][]byte {
+	xyzi := voxInts(int32(len(voxels)))
+	for _, v := range voxels {
+		xyzi = append(xyzi, v[:]...)
+	}
+	return [][]byte{voxChunk("SIZE", voxInts(size[0], size[1], size[2])), voxChunk("XYZI", xyzi)}
+}
+
+func TestVOXRoundTrip(t *testing.T) {
+	vg := NewVoxelGrid(4, 3, 2)
+	vg.SetVoxel(0, 0, 0, [3]uint8{255, 0, 0})
+	vg.SetVoxel(3, 2, 1, [3]uint8{0, 128, 255})
+	vg.SetVoxel(1, 1, 0, [3]uint8{255, 0, 0})
+
+	var buf bytes.Buffer
+	if err := NewVOXExporter().Export(vg, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	got, err := NewVOXImporter().Import(&buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+
+	if got.SizeX != 4 || got.SizeY != 3 || got.SizeZ != 2 {
+		t.Errorf("size = %dx%dx%d, want 4x3x2", got.SizeX, got.SizeY, got.SizeZ)
+	}
+	if got.Count() != vg.Count() {
+		t.Errorf("imported %d voxels, want %d", got.Count(), vg.Count())
+	}
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		if c, ok := got.ColorAt(x, y, z); !ok || c != color {
+			t.Errorf("voxel (%d,%d,%d) = %v, %v; want %v", x, y, z, c, ok, color)
+		}
+		return true
+	})
+}
+
+func TestVOXChunkSizes(t *testing.T) {
+	vg := NewVoxelGrid(3, 2, 2)
+	vg.SetVoxel(0, 0, 0, [3]uint8{255, 0, 0})
+	vg.SetVoxel(2, 1, 1, [3]uint8{0, 0, 255})
+	var buf bytes.Buffer
+	if err := NewVOXExporter().Export(vg, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[8:12]) != "MAIN" {
+		t.Fatalf("first chunk %q, want MAIN", data[8:12])
+	}
+	content := binary.LittleEndian.Uint32(data[12:])
+	children := binary.LittleEndian.Uint32(data[16:])
+	if content != 0 || int(children) != len(data)-20 {
+		t.Errorf("MAIN sizes = %d, %d; want 0, %d", content, children, len(data)-20)
+	}
+
+	// Walk MAIN's children by their sizes
+	want := []struct {
+		id   string
+		size int
+	}{{"SIZE", 12}, {"XYZI", 4 + 2*4}, {"RGBA", 256 * 4}}
+	rest := data[20:]
+	for _, w := range want {
+		if len(rest) < 12 || string(rest[:4]) != w.id {
+			t.Fatalf("expected %s chunk, got %q", w.id, rest[:min(4, len(rest))])
+		}
+		size := int(binary.LittleEndian.Uint32(rest[4:]))
+		if size != w.size || binary.LittleEndian.Uint32(rest[8:]) != 0 {
+			t.Errorf("%s sizes = %d, %d; want %d, 0", w.id, size, binary.LittleEndian.Uint32(rest[8:]), w.size)
+		}
+		rest = rest[12+size:]
+	}
+	if len(rest) != 0 {
+		t.Errorf("%d bytes after the last chunk", len(rest))
+	}
+}
+
+func TestVOXSplitModels(t *testing.T) {
+	// 300 along x and 520 along z split into 2x3 models of at most 256
+	vg := NewVoxelGrid(300, 10, 520)
+	red, blue := [3]uint8{255, 0, 0}, [3]uint8{0, 0, 255}
+	for _, p := range [][3]int{{0, 0, 0}, {255, 0, 0}, {256, 9, 0}, {299, 9, 519}, {128, 5, 263}, {0, 0, 264}} {
+		vg.SetVoxel(p[0], p[1], p[2], red)
+	}
+	vg.SetVoxel(257, 3, 300, blue)
+
+	var buf bytes.Buffer
+	if err := NewVOXExporter().Export(vg, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("SIZE")); n != 6 {
+		t.Errorf("%d models, want 6", n)
+	}
+	got, err := NewVOXImporter().Import(&buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if got.SizeX != 300 || got.SizeY != 10 || got.SizeZ != 520 {
+		t.Errorf("size = %dx%dx%d, want 300x10x520", got.SizeX, got.SizeY, got.SizeZ)
+	}
+	if got.Count() != vg.Count() {
+		t.Errorf("imported %d voxels, want %d", got.Count(), vg.Count())
+	}
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		if c, ok := got.ColorAt(x, y, z); !ok || c != color {
+			t.Errorf("voxel (%d,%d,%d) = %v, %v; want %v", x, y, z, c, ok, color)
+		}
+		return true
+	})
+}
+
+func TestVOXQuantize(t *testing.T) {
+	// 512 colors, twice what a VOX palette holds
+	vg := NewVoxelGrid(32, 16, 1)
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 16; y++ {
+			vg.SetVoxel(x, y, 0, [3]uint8{uint8(x * 8), uint8(y * 16), 128})
+		}
+	}
+	vg.Materials = map[[3]uint8]VoxelMaterial{{0, 0, 128}: {Emission: 1}}
+
+	var buf bytes.Buffer
+	if err := NewVOXExporter().Export(vg, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	got, err := NewVOXImporter().Import(&buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	colors := make(map[[3]uint8]bool)
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		c, ok := got.ColorAt(x, y, z)
+		if !ok {
+			t.Fatalf("voxel (%d,%d,%d) missing", x, y, z)
+		}
+		colors[c] = true
+		if d := DeltaE(RGBToLAB(color), RGBToLAB(c)); d > 10 {
+			t.Errorf("voxel (%d,%d,%d) = %v, %.1f from %v", x, y, z, c, d, color)
+		}
+		return true
+	})
+	if len(colors) > maxVOXColors {
+		t.Errorf("%d colors, want at most %d", len(colors), maxVOXColors)
+	}
+	if len(got.Materials) != 1 {
+		t.Errorf("imported materials = %v, want the emissive one", got.Materials)
+	}
+	if c, _ := vg.ColorAt(31, 15, 0); c != [3]uint8{248, 240, 128} {
+		t.Errorf("export changed the grid: %v", c)
+	}
+}
+
+func TestVOXMaterials(t *testing.T) {
+	red, green, blue, gray := [3]uint8{255, 0, 0}, [3]uint8{0, 255, 0}, [3]uint8{0, 0, 255}, [3]uint8{128, 128, 128}
+	vg := NewVoxelGrid(4, 1, 1)
+	for x, c := range [][3]uint8{red, green, blue, gray} {
+		vg.SetVoxel(x, 0, 0, c)
+	}
+	vg.Materials = map[[3]uint8]VoxelMaterial{
+		red:       {Emission: 0.5},
+		green:     {Opacity: 0.25},
+		blue:      {Metallic: 1, Roughness: 0.2},
+		{1, 2, 3}: {Emission: 1}, // No voxel has this color
+	}
+
+	var buf bytes.Buffer
+	if err := NewVOXExporter().Export(vg, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("MATL")); n != 3 {
+		t.Errorf("%d MATL chunks, want 3", n)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("NOTE")) {
+		t.Error("no NOTE chunk")
+	}
+	got, err := NewVOXImporter().Import(&buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	want := map[[3]uint8]VoxelMaterial{
+		red:   {Emission: 0.5},
+		green: {Opacity: 0.25},
+		blue:  {Metallic: 1, Roughness: 0.2},
+	}
+	if !reflect.DeepEqual(got.Materials, want) {
+		t.Errorf("imported materials = %v, want %v", got.Materials, want)
+	}
+
+	// Without materials the file has neither chunk
+	vg.Materials = nil
+	buf.Reset()
+	if err := NewVOXExporter().Export(vg, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("MATL")) || bytes.Contains(buf.Bytes(), []byte("NOTE")) {
+		t.Error("wrote materials for a grid without any")
+	}
+}
+
+func TestVOXAxes(t *testing.T) {
+	// A column rising along the grid's y axis stands along VOX z
+	vg := NewVoxelGrid(1, 3, 2)
+	for y := 0; y < 3; y++ {
+		vg.SetVoxel(0, y, 1, [3]uint8{200, 10, 10})
+	}
+	var buf bytes.Buffer
+	if err := NewVOXExporter().Export(vg, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	data := buf.Bytes()
+	size := bytes.Index(data, []byte("SIZE"))
+	if size < 0 {
+		t.Fatal("no SIZE chunk")
+	}
+	dims := [3]uint32{}
+	for i := range dims {
+		dims[i] = binary.LittleEndian.Uint32(data[size+12+4*i:])
+	}
+	if dims != [3]uint32{1, 2, 3} {
+		t.Errorf("VOX size = %v, want [1 2 3]", dims)
+	}
+	xyzi := bytes.Index(data, []byte("XYZI")) + 16
+	for i := 0; i < 3; i++ {
+		v := data[xyzi+4*i : xyzi+4*i+3]
+		if v[0] != 0 || v[1] != 0 {
+			t.Errorf("voxel %d at VOX %v, want x = y = 0", i, v)
+		}
+	}
+}
+
+func TestVOXDefaultPalette(t *testing.T) {
+	data := voxFile(voxModelChunks([3]int32{2, 1, 1}, [4]byte{0, 0, 0, 1}, [4]byte{1, 0, 0, 255})...)
+	vg, err := NewVOXImporter().Import(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if c, _ := vg.ColorAt(0, 0, 0); c != [3]uint8{255, 255, 255} {
+		t.Errorf("index 1 = %v, want white", c)
+	}
+	if c, _ := vg.ColorAt(1, 0, 0); c != [3]uint8{0x11, 0x11, 0x11} {
+		t.Errorf("index 255 = %v, want #111111", c)
+	}
+}
+
+func TestVOXSceneGraph(t *testing.T) {
+	// Two 2x2x2 models, the second rotated 90 degrees about z and moved 10 along x
+	model0 := voxModelChunks([3]int32{2, 2, 2}, [4]byte{0, 0, 0, 1})
+	model1 := voxModelChunks([3]int32{2, 2, 2}, [4]byte{1, 0, 0, 2})
+	rgba := make([]byte, 256*4)
+	copy(rgba, []byte{255, 0, 0, 255, 0, 255, 0, 255})
+
+	// _r = 0b0010001: row 0 takes column 1 with a negative sign, row 1 column 0
+	rotZ := "17"
+	chunks := append(model0, model1...)
+	chunks = append(chunks,
+		voxChunk("RGBA", rgba),
+		voxChunk("nTRN", append(append(voxInts(0), voxDict()...), append(voxInts(1, -1, -1, 1), voxDict()...)...)),
+		voxChunk("nGRP", append(append(voxInts(1), voxDict()...), voxInts(2, 2, 4)...)),
+		voxChunk("nTRN", append(append(voxInts(2), voxDict()...), append(voxInts(3, -1, 0, 1), voxDict("_t", "0 0 0")...)...)),
+		voxChunk("nSHP", append(append(voxInts(3), voxDict()...), append(voxInts(1, 0), voxDict()...)...)),
+		voxChunk("nTRN", append(append(voxInts(4), voxDict()...), append(voxInts(5, -1, 0, 1), voxDict("_t", "10 0 0", "_r", rotZ)...)...)),
+		voxChunk("nSHP", append(append(voxInts(5), voxDict()...), append(voxInts(1, 1), voxDict()...)...)),
+		voxChunk("MATL", voxInts(1, 0)),
+	)
+
+	vg, err := NewVOXImporter().Import(bytes.NewReader(voxFile(chunks...)))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	// Model 0 covers [-1,0] on every axis; model 1 covers x in [10,11]
+	if vg.SizeX != 13 || vg.SizeY != 2 || vg.SizeZ != 2 {
+		t.Fatalf("size = %dx%dx%d, want 13x2x2", vg.SizeX, vg.SizeY, vg.SizeZ)
+	}
+	// VOX (x, y, z) lands at grid (x, z, SizeZ-1-y)
+	if c, ok := vg.ColorAt(0, 0, 1); !ok || c != [3]uint8{255, 0, 0} {
+		t.Errorf("model 0 voxel = %v, %v", c, ok)
+	}
+	// Model 1's (1,0,0) is (0,-1,-1) after centering, rotated to (1,0,-1) and moved to (11,0,-1)
+	if c, ok := vg.ColorAt(12, 0, 0); !ok || c != [3]uint8{0, 255, 0} {
+		t.Errorf("model 1 voxel = %v, %v", c, ok)
+	}
+	if vg.Count() != 2 {
+		t.Errorf("imported %d voxels, want 2", vg.Count())
+	}
+}
+
+func TestVOXMalformed(t *testing.T) {
+	valid := voxModelChunks([3]int32{1, 1, 1}, [4]byte{0, 0, 0, 1})
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no models", voxFile()},
+		{"xyzi without size", voxFile(voxChunk("XYZI", voxInts(0)))},
+		{"voxel count overflow", voxFile(valid[0], voxChunk("XYZI", voxInts(1000)))},
+		{"zero size", voxFile(voxModelChunks([3]int32{0, 1, 1})...)},
+		{"truncated chunk", voxFile(valid...)[:30]},
+		{"not main", append([]byte("VOX \x96\x00\x00\x00"), valid[0]...)},
+		{"bad rotation", voxFile(append(valid, voxChunk("nTRN", append(append(voxInts(0), voxDict()...), append(voxInts(1, -1, 0, 1), voxDict("_r", "3")...)...)))...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewVOXImporter().Import(bytes.NewReader(tt.data))
+			var formatErr *FormatError
+			if !errors.As(err, &formatErr) || formatErr.Format != "vox" {
+				t.Errorf("expected vox FormatError, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Tnze/go-mc/nbt"
+	"github.com/Tnze/go-mc/save/region"
+)
+
+// Default world height of Minecraft 1.18 and later.
+const (
+	defaultWorldMinY   = -64
+	defaultWorldHeight = 384
+)
+
+// WorldExporterImpl implements WorldExporter by writing blocks straight into the
+// Anvil region files (.mca) of a Minecraft 1.18+ world save. Chunks that already
+// exist keep their other blocks, entities and biomes; missing chunks are created
+// empty. Light and heightmaps of every changed chunk are dropped so the game
+// recomputes them on load. Like SchematicExporterImpl, the block lookup is kept
+// across exports with the same palette, and an exporter must not be used by
+// several goroutines at once. Close the world in the game before exporting.
+type WorldExporterImpl struct {
+	Origin      [3]int           // World position of the grid's (0, 0, 0) cell
+	DataVersion int              // Minecraft data version recorded in new chunks (0 = 2975)
+	MinY        int              // Lowest block height of the world (default -64)
+	Height      int              // Number of block layers in the world (default 384)
+	Matcher     ColorMatcher     // Matches colors that are not palette entries (default: CIELAB)
+	Detail      string           // Surface detail mode, such as DetailStairsSlabs (default DetailNone)
+	Progress    ProgressReporter // Optional progress callback
+
+	lookup blockLookup
+}
+
+// NewWorldExporter creates a world exporter placing the grid at origin.
+func NewWorldExporter(origin [3]int) *WorldExporterImpl {
+	return &WorldExporterImpl{Origin: origin}
+}
+
+// sectionEdit holds the blocks written to one 16x16x16 section, as lookup index
+// plus one; 0 leaves the existing block.
+type sectionEdit [4096]int32
+
+// chunkEdit holds the sections written to one chunk, keyed by section y.
+type chunkEdit map[int]*sectionEdit
+
+// Export writes the grid into the region files of the world save in worldDir,
+// creating the region directory and files as needed.
+func (e *WorldExporterImpl) Export(vg *VoxelGrid, palette *Palette, worldDir string) error {
+	return e.ExportCtx(context.Background(), vg, palette, worldDir)
+}
+
+// ExportCtx is like Export but stops early when ctx is done. Chunks already
+// written stay in the world; a chunk is never left half written.
+func (e *WorldExporterImpl) ExportCtx(ctx context.Context, vg *VoxelGrid, palette *Palette, worldDir string) error {
+	minY, height := e.MinY, e.Height
+	if height == 0 {
+		minY, height = defaultWorldMinY, defaultWorldHeight
+	}
+	if bottom, top := e.Origin[1], e.Origin[1]+vg.SizeY; bottom < minY || top > minY+height {
+		return fmt.Errorf("%w: grid spans y %d to %d, outside the world's %d to %d",
+			ErrInvalidConfig, bottom, top-1, minY, minY+height-1)
+	}
+	dataVersion := e.DataVersion
+	if dataVersion == 0 {
+		dataVersion = defaultSchematicDataVersion
+	}
+
+	e.lookup.prepare(palette, e.Matcher, e.Detail)
+	states := e.lookup.blockIDs()
+
+	// Group the grid's blocks by region and chunk
+	regions := make(map[[2]int]map[[2]int]chunkEdit)
+	tracker := startStage(e.Progress, StageExport, int64(vg.Count()))
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		tracker.add(1)
+		wx, wy, wz := e.Origin[0]+x, e.Origin[1]+y, e.Origin[2]+z
+		cx, cz := wx>>4, wz>>4
+		rpos := [2]int{cx >> 5, cz >> 5}
+		chunks := regions[rpos]
+		if chunks == nil {
+			chunks = make(map[[2]int]chunkEdit)
+			regions[rpos] = chunks
+		}
+		chunk := chunks[[2]int{cx, cz}]
+		if chunk == nil {
+			chunk = make(chunkEdit)
+			chunks[[2]int{cx, cz}] = chunk
+		}
+		section := chunk[wy>>4]
+		if section == nil {
+			section = new(sectionEdit)
+			chunk[wy>>4] = section
+		}
+		section[(wy&15)<<8|(wz&15)<<4|wx&15] = e.lookup.indexAt(vg, x, y, z, color) + 1
+		return true
+	})
+	tracker.finish()
+
+	regionDir := filepath.Join(worldDir, "region")
+	if err := os.MkdirAll(regionDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create region directory: %w", err)
+	}
+	for _, rpos := range sortedPositions(regions) {
+		chunks := regions[rpos]
+		path := filepath.Join(regionDir, fmt.Sprintf("r.%d.%d.mca", rpos[0], rpos[1]))
+		if err := writeRegion(ctx, path, chunks, states, int32(dataVersion), minY); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRegion applies the chunk edits to the region file at path.
+func writeRegion(ctx context.Context, path string, chunks map[[2]int]chunkEdit, states []string, dataVersion int32, minY int) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open region file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open region file: %w", err)
+	}
+	var r *region.Region
+	if info.Size() == 0 {
+		r, err = region.CreateWriter(f)
+	} else {
+		r, err = region.Load(f)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to read region file %s: %w", filepath.Base(path), err)
+	}
+	defer r.Close()
+
+	for _, pos := range sortedPositions(chunks) {
+		edit := chunks[pos]
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		lx, lz := region.In(pos[0], pos[1])
+		var existing []byte
+		if r.ExistSector(lx, lz) {
+			if existing, err = r.ReadSector(lx, lz); err != nil {
+				return fmt.Errorf("failed to read chunk %d, %d: %w", pos[0], pos[1], err)
+			}
+		}
+		data, err := editChunk(existing, pos, edit, states, dataVersion, minY)
+		if err != nil {
+			return fmt.Errorf("chunk %d, %d: %w", pos[0], pos[1], err)
+		}
+		if err := r.WriteSector(lx, lz, data); err != nil {
+			return fmt.Errorf("failed to write chunk %d, %d: %w", pos[0], pos[1], err)
+		}
+	}
+	if err := r.PadToFullSector(); err != nil {
+		return fmt.Errorf("failed to write region file: %w", err)
+	}
+	return nil
+}
+
+// chunkSection is the part of a chunk section this exporter edits; other tags are
+// kept as they are.
+type chunkSection = sortedCompound[nbt.RawMessage]
+
+type blockStates struct {
+	Palette []structureBlockState `nbt:"palette"`
+	Data    []int64               `nbt:"data,omitempty"`
+}
+
+// editChunk applies edit to the stored chunk data (nil for a new chunk) and
+// returns the new zlib-compressed chunk.
+func editChunk(stored []byte, pos [2]int, edit chunkEdit, states []string, dataVersion int32, minY int) ([]byte, error) {
+	chunk := make(sortedCompound[nbt.RawMessage])
+	if stored != nil {
+		if err := decodeChunk(stored, &chunk); err != nil {
+			return nil, err
+		}
+	} else {
+		for key, value := range map[string]interface{}{
+			"DataVersion":    dataVersion,
+			"xPos":           int32(pos[0]),
+			"yPos":           int32(minY >> 4),
+			"zPos":           int32(pos[1]),
+			"Status":         "minecraft:full",
+			"LastUpdate":     int64(0),
+			"InhabitedTime":  int64(0),
+			"block_entities": []chunkSection{},
+		} {
+			if err := setRaw(chunk, key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var sections []chunkSection
+	if raw, ok := chunk["sections"]; ok {
+		if err := raw.Unmarshal(&sections); err != nil {
+			return nil, fmt.Errorf("invalid sections: %w", err)
+		}
+	}
+	bySectionY := make(map[int]chunkSection)
+	for _, section := range sections {
+		var y int8
+		if err := section["Y"].Unmarshal(&y); err != nil {
+			return nil, fmt.Errorf("invalid section: %w", err)
+		}
+		bySectionY[int(y)] = section
+	}
+
+	for sy, blocks := range edit {
+		section, ok := bySectionY[sy]
+		if !ok {
+			section = make(chunkSection)
+			if err := setRaw(section, "Y", int8(sy)); err != nil {
+				return nil, err
+			}
+			sections = append(sections, section)
+		}
+		if err := editSection(section, blocks, states); err != nil {
+			return nil, fmt.Errorf("section %d: %w", sy, err)
+		}
+		// Light is recomputed by the game
+		delete(section, "SkyLight")
+		delete(section, "BlockLight")
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		var a, b int8
+		sections[i]["Y"].Unmarshal(&a)
+		sections[j]["Y"].Unmarshal(&b)
+		return a < b
+	})
+
+	if err := setRaw(chunk, "sections", sections); err != nil {
+		return nil, err
+	}
+	if err := setRaw(chunk, "isLightOn", int8(0)); err != nil {
+		return nil, err
+	}
+	delete(chunk, "Heightmaps")
+
+	var buf bytes.Buffer
+	buf.WriteByte(2) // zlib compression
+	zw := zlib.NewWriter(&buf)
+	if err := nbt.NewEncoder(zw).Encode(chunk, ""); err != nil {
+		return nil, fmt.Errorf("failed to encode chunk: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress chunk: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeChunk decompresses and decodes stored chunk data into v.
+func decodeChunk(stored []byte, v interface{}) error {
+	if len(stored) == 0 {
+		return fmt.Errorf("empty chunk data")
+	}
+	var r io.Reader = bytes.NewReader(stored[1:])
+	switch stored[0] {
+	case 1:
+		return fmt.Errorf("gzip-compressed chunks are not supported")
+	case 2:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("invalid chunk compression: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	case 3:
+	default:
+		return fmt.Errorf("unknown chunk compression %d", stored[0])
+	}
+	if _, err := nbt.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("invalid chunk NBT: %w", err)
+	}
+	return nil
+}
+
+// editSection writes blocks into the section's block states.
+func editSection(section chunkSection, blocks *sectionEdit, states []string) error {
+	current := blockStates{Palette: []structureBlockState{{Name: "minecraft:air"}}}
+	if raw, ok := section["block_states"]; ok {
+		if err := raw.Unmarshal(&current); err != nil {
+			return fmt.Errorf("invalid block states: %w", err)
+		}
+	}
+	cells, err := unpackBlockStates(current)
+	if err != nil {
+		return err
+	}
+
+	// Rebuild the palette from the blocks that remain in use
+	var palette []structureBlockState
+	index := make(map[string]int)
+	paletteIndex := func(state structureBlockState) int {
+		key := blockStateKey(state)
+		i, ok := index[key]
+		if !ok {
+			i = len(palette)
+			index[key] = i
+			palette = append(palette, state)
+		}
+		return i
+	}
+	for i, old := range cells {
+		if blocks[i] != 0 {
+			cells[i] = paletteIndex(parseBlockState(states[blocks[i]-1]))
+		} else {
+			cells[i] = paletteIndex(current.Palette[old])
+		}
+	}
+
+	updated := blockStates{Palette: palette}
+	if len(palette) > 1 {
+		updated.Data = packBlockStates(cells, len(palette))
+	}
+	return setRaw(section, "block_states", updated)
+}
+
+// blockStateBits returns the bits per entry of a block state container with n
+// palette entries.
+func blockStateBits(n int) int {
+	if b := bits.Len(uint(n - 1)); b > 4 {
+		return b
+	}
+	return 4
+}
+
+// unpackBlockStates returns the palette index of each of the 4096 cells. Entries
+// do not span longs, as in Minecraft 1.16 and later.
+func unpackBlockStates(states blockStates) ([]int, error) {
+	cells := make([]int, 4096)
+	if len(states.Palette) == 0 {
+		return nil, fmt.Errorf("empty block state palette")
+	}
+	if len(states.Palette) == 1 {
+		return cells, nil
+	}
+	bitsPer := blockStateBits(len(states.Palette))
+	perLong := 64 / bitsPer
+	if len(states.Data) < (4096+perLong-1)/perLong {
+		return nil, fmt.Errorf("short block state data")
+	}
+	mask := uint64(1)<<bitsPer - 1
+	for i := range cells {
+		v := int(uint64(states.Data[i/perLong]) >> (i % perLong * bitsPer) & mask)
+		if v >= len(states.Palette) {
+			return nil, fmt.Errorf("block state index %d out of range", v)
+		}
+		cells[i] = v
+	}
+	return cells, nil
+}
+
+// packBlockStates packs palette indices into longs.
+func packBlockStates(cells []int, paletteSize int) []int64 {
+	bitsPer := blockStateBits(paletteSize)
+	perLong := 64 / bitsPer
+	data := make([]int64, (len(cells)+perLong-1)/perLong)
+	for i, v := range cells {
+		data[i/perLong] |= int64(uint64(v) << (i % perLong * bitsPer))
+	}
+	return data
+}
+
+// blockStateKey returns a string identifying a block state.
+func blockStateKey(state structureBlockState) string {
+	if len(state.Properties) == 0 {
+		return state.Name
+	}
+	props := make([]string, 0, len(state.Properties))
+	for key, value := range state.Properties {
+		props = append(props, key+"="+value)
+	}
+	sort.Strings(props)
+	return state.Name + "[" + strings.Join(props, ",") + "]"
+}
+
+// setRaw encodes value and stores it in compound under key.
+func setRaw(compound map[string]nbt.RawMessage, key string, value interface{}) error {
+	var buf bytes.Buffer
+	if err := nbt.NewEncoder(&buf).Encode(value, ""); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	// Strip the tag type and the empty name
+	data := buf.Bytes()
+	compound[key] = nbt.RawMessage{Type: data[0], Data: data[3:]}
+	return nil
+}
+
+// sortedPositions returns the region or chunk positions of m ordered by z, then
+// x, so files are written the same way on every run.
+func sortedPositions[V any](m map[[2]int]V) [][2]int {
+	positions := make([][2]int, 0, len(m))
+	for pos := range m {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i][1] != positions[j][1] {
+			return positions[i][1] < positions[j][1]
+		}
+		return positions[i][0] < positions[j][0]
+	})
+	return positions
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tnze/go-mc/save/region"
+)
+
+// readWorldBlock returns the block state at a world position.
+func readWorldBlock(t *testing.T, worldDir string, x, y, z int) string {
+	t.Helper()
+	cx, cz := x>>4, z>>4
+	f, err := os.Open(filepath.Join(worldDir, "region", fmt.Sprintf("r.%d.%d.mca", cx>>5, cz>>5)))
+	if err != nil {
+		t.Fatalf("open region: %v", err)
+	}
+	r, err := region.Load(f)
+	if err != nil {
+		t.Fatalf("load region: %v", err)
+	}
+	defer r.Close()
+	lx, lz := region.In(cx, cz)
+	stored, err := r.ReadSector(lx, lz)
+	if err != nil {
+		t.Fatalf("read chunk: %v", err)
+	}
+
+	var chunk struct {
+		IsLightOn byte `nbt:"isLightOn"`
+		Sections  []struct {
+			Y           int8        `nbt:"Y"`
+			BlockStates blockStates `nbt:"block_states"`
+		} `nbt:"sections"`
+	}
+	if err := decodeChunk(stored, &chunk); err != nil {
+		t.Fatalf("decode chunk: %v", err)
+	}
+	if chunk.IsLightOn != 0 {
+		t.Error("isLightOn should be cleared")
+	}
+	for _, section := range chunk.Sections {
+		if int(section.Y) != y>>4 {
+			continue
+		}
+		cells, err := unpackBlockStates(section.BlockStates)
+		if err != nil {
+			t.Fatalf("unpack section: %v", err)
+		}
+		return blockStateKey(section.BlockStates.Palette[cells[(y&15)<<8|(z&15)<<4|x&15]])
+	}
+	return "minecraft:air"
+}
+
+func TestWorldExport(t *testing.T) {
+	dir := t.TempDir()
+	vg := NewVoxelGrid(2, 2, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{1, 1, 1})
+	vg.SetVoxel(1, 1, 0, [3]uint8{1, 1, 1})
+
+	// The grid straddles chunks and regions on x and a section boundary on y
+	exporter := NewWorldExporter([3]int{-1, 15, 4})
+	if err := exporter.Export(vg, nil, dir); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	tests := []struct {
+		x, y, z int
+		want    string
+	}{
+		{-1, 15, 4, "minecraft:white_concrete"},
+		{0, 16, 4, "minecraft:white_concrete"},
+		{0, 15, 4, "minecraft:air"},
+		{-1, 16, 4, "minecraft:air"},
+	}
+	for _, tt := range tests {
+		if got := readWorldBlock(t, dir, tt.x, tt.y, tt.z); got != tt.want {
+			t.Errorf("block (%d,%d,%d) = %s, want %s", tt.x, tt.y, tt.z, got, tt.want)
+		}
+	}
+
+	// A second export into the same world keeps the blocks it does not cover
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{100, 80, 50}, Metadata: map[string]interface{}{"block_id": "minecraft:spruce_log[axis=y]"}},
+	}}
+	vg = NewVoxelGrid(1, 1, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{100, 80, 50})
+	if err := NewWorldExporter([3]int{0, 15, 4}).Export(vg, palette, dir); err != nil {
+		t.Fatalf("second export: %v", err)
+	}
+	if got := readWorldBlock(t, dir, 0, 15, 4); got != "minecraft:spruce_log[axis=y]" {
+		t.Errorf("merged block = %s", got)
+	}
+	if got := readWorldBlock(t, dir, 0, 16, 4); got != "minecraft:white_concrete" {
+		t.Errorf("existing block = %s", got)
+	}
+}
+
+func TestWorldExportHeight(t *testing.T) {
+	err := NewWorldExporter([3]int{0, 319, 0}).Export(NewVoxelGrid(1, 2, 1), nil, t.TempDir())
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestBlockStatePacking(t *testing.T) {
+	cells := make([]int, 4096)
+	for i := range cells {
+		cells[i] = i % 20
+	}
+	states := blockStates{Palette: make([]structureBlockState, 20)}
+	states.Data = packBlockStates(cells, 20)
+	// 5 bits per entry, 12 entries per long
+	if len(states.Data) != 342 {
+		t.Errorf("len(data) = %d, want 342", len(states.Data))
+	}
+	got, err := unpackBlockStates(states)
+	if err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	for i := range cells {
+		if got[i] != cells[i] {
+			t.Fatalf("cell %d = %d, want %d", i, got[i], cells[i])
+		}
+	}
+}
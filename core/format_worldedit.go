@@ -0,0 +1,58 @@
+package core
+
+import (
+	"fmt"
+	"io"
+)
+
+// WorldEditExporterImpl writes a voxel grid as WorldEdit commands: for every box
+// of one block found by greedy meshing, //pos1 and //pos2 select it and //set
+// fills it. The script runs line by line from chat, a macro or a FAWE script,
+// and needs far fewer operations than placing blocks one by one. Like
+// FunctionExporterImpl, an exporter must not be used by several goroutines at
+// once.
+type WorldEditExporterImpl struct {
+	Origin   [3]int           // World position of the grid's minimum corner
+	Matcher  ColorMatcher     // Matches colors that are not palette entries (default: CIELAB)
+	Detail   string           // Surface detail mode, such as DetailStairsSlabs (default DetailNone)
+	Progress ProgressReporter // Optional progress callback
+
+	lookup blockLookup
+	stats  CommandStats
+}
+
+// NewWorldEditExporter creates a new WorldEdit command exporter placing the grid
+// with its minimum corner at origin.
+func NewWorldEditExporter(origin [3]int) *WorldEditExporterImpl {
+	return &WorldEditExporterImpl{Origin: origin}
+}
+
+// Commands returns the WorldEdit commands that build the grid, bottom up.
+func (e *WorldEditExporterImpl) Commands(vg *VoxelGrid, palette *Palette) []string {
+	e.lookup.prepare(palette, e.Matcher, e.Detail)
+	blockIDs := e.lookup.blockIDs()
+
+	cells := collectBlockCells(vg, &e.lookup, e.Progress)
+	boxes := greedyBoxes(cells, 0)
+	commands := make([]string, 0, 3*len(boxes))
+	o := e.Origin
+	for _, box := range boxes {
+		lo, hi := box.min, box.max
+		commands = append(commands,
+			fmt.Sprintf("//pos1 %d,%d,%d", o[0]+lo[0], o[1]+lo[1], o[2]+lo[2]),
+			fmt.Sprintf("//pos2 %d,%d,%d", o[0]+hi[0], o[1]+hi[1], o[2]+hi[2]),
+			"//set "+blockIDs[box.block])
+	}
+	e.stats = CommandStats{Blocks: len(cells), Commands: len(commands)}
+	return commands
+}
+
+// Export writes the commands one per line.
+func (e *WorldEditExporterImpl) Export(vg *VoxelGrid, palette *Palette, w io.Writer) error {
+	return writeFunction(w, e.Commands(vg, palette))
+}
+
+// CommandStats returns the block and command counts of the last Commands call.
+func (e *WorldEditExporterImpl) CommandStats() CommandStats {
+	return e.stats
+}
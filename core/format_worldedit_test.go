@@ -0,0 +1,28 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWorldEditCommands(t *testing.T) {
+	vg := NewVoxelGrid(4, 2, 1)
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			vg.SetVoxel(x, y, 0, [3]uint8{1, 1, 1})
+		}
+	}
+
+	exporter := NewWorldEditExporter([3]int{100, 64, -20})
+	var buf bytes.Buffer
+	if err := exporter.Export(vg, nil, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	want := "//pos1 100,64,-20\n//pos2 103,65,-20\n//set minecraft:white_concrete\n"
+	if buf.String() != want {
+		t.Errorf("script =\n%s\nwant\n%s", buf.String(), want)
+	}
+	if stats := exporter.CommandStats(); stats != (CommandStats{Blocks: 8, Commands: 3}) {
+		t.Errorf("stats = %+v", stats)
+	}
+}
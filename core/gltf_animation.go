@@ -0,0 +1,238 @@
+package core
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// gltfPose is a node's local transform as translation, rotation quaternion and
+// scale, the properties animation channels target.
+type gltfPose struct {
+	translation [3]float64
+	rotation    [4]float64
+	scale       [3]float64
+}
+
+// gltfNodeLocals returns every node's local transform at rest.
+func gltfNodeLocals(doc *gltf.Document) []gltfMatrix {
+	locals := make([]gltfMatrix, len(doc.Nodes))
+	for i, n := range doc.Nodes {
+		locals[i] = nodeMatrix(n)
+	}
+	return locals
+}
+
+// gltfAnimatedLocals returns every node's local transform with the channels of
+// an animation sampled at the given time, which is clamped to the keyframes.
+// Nodes the animation does not target keep their rest transform.
+func gltfAnimatedLocals(doc *gltf.Document, animation int, seconds float64) ([]gltfMatrix, error) {
+	if animation < 0 || animation >= len(doc.Animations) {
+		return nil, fmt.Errorf("animation index %d out of range (the file has %d)", animation, len(doc.Animations))
+	}
+	anim := doc.Animations[animation]
+
+	poses := make([]gltfPose, len(doc.Nodes))
+	animated := make([]bool, len(doc.Nodes))
+	for i, n := range doc.Nodes {
+		poses[i] = gltfPose{n.TranslationOrDefault(), n.RotationOrDefault(), n.ScaleOrDefault()}
+	}
+	for c, channel := range anim.Channels {
+		// Morph target weights do not move vertices this importer reads
+		if channel.Target.Node == nil || channel.Target.Path == gltf.TRSWeights {
+			continue
+		}
+		node := *channel.Target.Node
+		if node < 0 || node >= len(doc.Nodes) {
+			return nil, fmt.Errorf("channel %d: node index %d out of range", c, node)
+		}
+		if channel.Sampler < 0 || channel.Sampler >= len(anim.Samplers) {
+			return nil, fmt.Errorf("channel %d: sampler index %d out of range", c, channel.Sampler)
+		}
+		sampler := anim.Samplers[channel.Sampler]
+		times, _, err := gltfAnimationFloats(doc, sampler.Input)
+		if err != nil {
+			return nil, fmt.Errorf("channel %d input: %w", c, err)
+		}
+		values, width, err := gltfAnimationFloats(doc, sampler.Output)
+		if err != nil {
+			return nil, fmt.Errorf("channel %d output: %w", c, err)
+		}
+		want := 3
+		if channel.Target.Path == gltf.TRSRotation {
+			want = 4
+		}
+		keys := len(times)
+		if sampler.Interpolation == gltf.InterpolationCubicSpline {
+			keys *= 3
+		}
+		if width != want || len(values) < keys*width || len(times) == 0 {
+			return nil, fmt.Errorf("channel %d: %d keyframes with %d values of %d components", c, len(times), len(values)/max(width, 1), width)
+		}
+
+		value := sampleGLTFChannel(times, values, want, sampler.Interpolation, seconds)
+		switch channel.Target.Path {
+		case gltf.TRSTranslation:
+			copy(poses[node].translation[:], value)
+		case gltf.TRSRotation:
+			copy(poses[node].rotation[:], normalizeQuaternion(value))
+		case gltf.TRSScale:
+			copy(poses[node].scale[:], value)
+		}
+		animated[node] = true
+	}
+
+	locals := gltfNodeLocals(doc)
+	for i, p := range poses {
+		// Nodes given by a matrix cannot be animated, so they keep it
+		if animated[i] {
+			locals[i] = trsMatrix(p.translation, p.rotation, p.scale)
+		}
+	}
+	return locals, nil
+}
+
+// gltfAnimationFloats reads a float or normalized integer accessor as a flat
+// list, returning the number of components per element.
+func gltfAnimationFloats(doc *gltf.Document, index int) ([]float64, int, error) {
+	accessor, err := gltfAccessor(doc, index)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := modeler.ReadAccessor(doc, accessor, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	var out []float64
+	switch data := data.(type) {
+	case []float32:
+		for _, v := range data {
+			out = append(out, float64(v))
+		}
+		return out, 1, nil
+	case [][3]float32:
+		for _, v := range data {
+			out = append(out, float64(v[0]), float64(v[1]), float64(v[2]))
+		}
+		return out, 3, nil
+	case [][4]float32:
+		for _, v := range data {
+			out = append(out, float64(v[0]), float64(v[1]), float64(v[2]), float64(v[3]))
+		}
+		return out, 4, nil
+	case [][4]int8:
+		for _, v := range data {
+			for _, c := range v {
+				out = append(out, math.Max(float64(c)/127, -1))
+			}
+		}
+		return out, 4, nil
+	case [][4]uint8:
+		for _, v := range data {
+			for _, c := range v {
+				out = append(out, float64(c)/255)
+			}
+		}
+		return out, 4, nil
+	case [][4]int16:
+		for _, v := range data {
+			for _, c := range v {
+				out = append(out, math.Max(float64(c)/32767, -1))
+			}
+		}
+		return out, 4, nil
+	case [][4]uint16:
+		for _, v := range data {
+			for _, c := range v {
+				out = append(out, float64(c)/65535)
+			}
+		}
+		return out, 4, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported accessor type %T", data)
+}
+
+// sampleGLTFChannel interpolates keyframe values of the given width at time t.
+// Cubic spline values come as in-tangent, value and out-tangent per keyframe.
+// Rotations (width 4) are interpolated spherically when linear.
+func sampleGLTFChannel(times, values []float64, width int, interpolation gltf.Interpolation, t float64) []float64 {
+	stride := width
+	offset := 0
+	if interpolation == gltf.InterpolationCubicSpline {
+		stride, offset = 3*width, width
+	}
+	key := func(k int) []float64 {
+		return values[k*stride+offset : k*stride+offset+width]
+	}
+
+	last := len(times) - 1
+	if t <= times[0] {
+		return key(0)
+	}
+	if t >= times[last] {
+		return key(last)
+	}
+	k := 0
+	for k < last-1 && times[k+1] <= t {
+		k++
+	}
+	dt := times[k+1] - times[k]
+	if dt <= 0 || interpolation == gltf.InterpolationStep {
+		return key(k)
+	}
+	s := (t - times[k]) / dt
+
+	a, b := key(k), key(k+1)
+	out := make([]float64, width)
+	switch {
+	case interpolation == gltf.InterpolationCubicSpline:
+		outTangent := values[k*stride+2*width : k*stride+3*width]
+		inTangent := values[(k+1)*stride : (k+1)*stride+width]
+		s2, s3 := s*s, s*s*s
+		for i := range out {
+			out[i] = (2*s3-3*s2+1)*a[i] + (s3-2*s2+s)*dt*outTangent[i] +
+				(-2*s3+3*s2)*b[i] + (s3-s2)*dt*inTangent[i]
+		}
+	case width == 4:
+		return slerpQuaternion(a, b, s)
+	default:
+		for i := range out {
+			out[i] = a[i] + (b[i]-a[i])*s
+		}
+	}
+	return out
+}
+
+// slerpQuaternion interpolates spherically between unit quaternions a and b
+// along the shorter arc.
+func slerpQuaternion(a, b []float64, s float64) []float64 {
+	dot := a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3]
+	sign := 1.0
+	if dot < 0 {
+		dot, sign = -dot, -1
+	}
+	wa, wb := 1-s, s
+	// Nearly equal rotations interpolate linearly to avoid dividing by ~0
+	if dot < 0.9995 {
+		theta := math.Acos(dot)
+		sin := math.Sin(theta)
+		wa, wb = math.Sin((1-s)*theta)/sin, math.Sin(s*theta)/sin
+	}
+	out := make([]float64, 4)
+	for i := range out {
+		out[i] = wa*a[i] + sign*wb*b[i]
+	}
+	return normalizeQuaternion(out)
+}
+
+// normalizeQuaternion scales q to unit length, leaving a zero quaternion as the
+// identity rotation.
+func normalizeQuaternion(q []float64) []float64 {
+	length := math.Sqrt(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3])
+	if length == 0 {
+		return []float64{0, 0, 0, 1}
+	}
+	return []float64{q[0] / length, q[1] / length, q[2] / length, q[3] / length}
+}
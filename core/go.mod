@@ -7,6 +7,7 @@ require (
 	github.com/lucasb-eyer/go-colorful v1.3.0
 	github.com/qmuntal/gltf v0.28.0
 	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/image v0.25.0
 )
 
 require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
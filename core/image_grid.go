@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Orientations of a grid built from an image by NewImageGrid.
+const (
+	ImageWall  = "wall"  // Upright in the x-y plane, one block thick, image top up
+	ImageFloor = "floor" // Flat in the x-z plane, image top to the north, as map art lies
+)
+
+// imageOrientations lists the accepted ImageGridConfig.Orientation values.
+var imageOrientations = []string{ImageWall, ImageFloor}
+
+// ImageOrientations returns the names accepted by ImageGridConfig.Orientation.
+func ImageOrientations() []string {
+	return append([]string(nil), imageOrientations...)
+}
+
+// ImageGridConfig holds parameters for building a grid from an image.
+type ImageGridConfig struct {
+	Orientation string // ImageWall (default) or ImageFloor
+	Width       int    // Voxels across, averaging or repeating pixels and keeping the aspect ratio (0 = one per pixel)
+	Height      int    // Voxels along the image's height; with Width too, the image is stretched to fit both
+}
+
+// NewImageGrid builds a one-voxel-thick grid from an image for pixel art: one
+// voxel per pixel, or per block of pixels when Width scales the image, in the
+// pixels' average color. Mostly transparent pixels are left empty.
+func NewImageGrid(img image.Image, config ImageGridConfig) (*VoxelGrid, error) {
+	orientation := config.Orientation
+	if orientation == "" {
+		orientation = ImageWall
+	}
+	if !containsString(imageOrientations, orientation) {
+		return nil, fmt.Errorf("%w: unknown image orientation %q (supported: %s)",
+			ErrInvalidConfig, config.Orientation, strings.Join(imageOrientations, ", "))
+	}
+	if config.Width < 0 {
+		return nil, fmt.Errorf("%w: image width must not be negative, got %d", ErrInvalidConfig, config.Width)
+	}
+	if config.Height < 0 {
+		return nil, fmt.Errorf("%w: image height must not be negative, got %d", ErrInvalidConfig, config.Height)
+	}
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, fmt.Errorf("%w: empty image", ErrInvalidConfig)
+	}
+	width, height := bounds.Dx(), bounds.Dy()
+	switch {
+	case config.Width > 0 && config.Height > 0:
+		width, height = config.Width, config.Height
+	case config.Width > 0:
+		height = max(1, int(math.Round(float64(height)*float64(config.Width)/float64(width))))
+		width = config.Width
+	case config.Height > 0:
+		width = max(1, int(math.Round(float64(width)*float64(config.Height)/float64(height))))
+		height = config.Height
+	}
+
+	var vg *VoxelGrid
+	if orientation == ImageWall {
+		vg = NewVoxelGridFor(width, height, 1, int64(width*height), StorageConfig{})
+	} else {
+		vg = NewVoxelGridFor(width, 1, height, int64(width*height), StorageConfig{})
+	}
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			var sum [4]float64
+			n := forEachCellPixel(img, col, row, width, height, func(c color.Color) {
+				r, g, b, a := c.RGBA()
+				sum[0], sum[1], sum[2], sum[3] = sum[0]+float64(r), sum[1]+float64(g), sum[2]+float64(b), sum[3]+float64(a)
+			})
+			if sum[3] < float64(n)*0x8000 {
+				continue
+			}
+			// Undo the premultiplied alpha of RGBA
+			var c [3]uint8
+			for i := range c {
+				c[i] = uint8(math.Round(math.Min(sum[i]/sum[3]*255, 255)))
+			}
+			if orientation == ImageWall {
+				vg.SetVoxel(col, height-1-row, 0, c)
+			} else {
+				vg.SetVoxel(col, 0, row, c)
+			}
+		}
+	}
+	return vg, nil
+}
@@ -0,0 +1,776 @@
+package core
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ColladaImporter implements MeshImporter for COLLADA (.dae) files, as written
+// by SketchUp, Blender and older tools. It reads the triangles, polylists and
+// polygons of every geometry the visual scene instantiates, placed by the node
+// hierarchy's transforms and turned Y up by the asset's up axis, with normals,
+// texture coordinates and vertex colors, and the bound materials' effect
+// colors and diffuse textures. Skinned geometries import in their bind pose;
+// animation is ignored.
+type ColladaImporter struct {
+	// Resources holds the image files effects use as textures, with paths
+	// relative to the DAE file. When nil, textures keep only
+	// Material.TexturePath.
+	Resources fs.FS
+}
+
+// NewColladaImporter creates a new COLLADA importer.
+func NewColladaImporter() *ColladaImporter {
+	return &ColladaImporter{}
+}
+
+// SetResources implements ResourceMeshImporter.
+func (imp *ColladaImporter) SetResources(fsys fs.FS) {
+	imp.Resources = fsys
+}
+
+// Import reads and parses a COLLADA mesh from the given reader.
+func (imp *ColladaImporter) Import(r io.Reader) (*Mesh, error) {
+	return imp.ImportCtx(context.Background(), r)
+}
+
+// daeDocument holds the parts of a COLLADA document the importer reads.
+type daeDocument struct {
+	UpAxis       string           `xml:"asset>up_axis"`
+	Images       []daeImage       `xml:"library_images>image"`
+	Effects      []daeEffect      `xml:"library_effects>effect"`
+	Materials    []daeMaterial    `xml:"library_materials>material"`
+	Geometries   []daeGeometry    `xml:"library_geometries>geometry"`
+	Controllers  []daeController  `xml:"library_controllers>controller"`
+	Nodes        []daeNode        `xml:"library_nodes>node"`
+	VisualScenes []daeVisualScene `xml:"library_visual_scenes>visual_scene"`
+	Scene        struct {
+		VisualScene daeURL `xml:"instance_visual_scene"`
+	} `xml:"scene"`
+}
+
+type daeURL struct {
+	URL string `xml:"url,attr"`
+}
+
+// daeImage is an image file; COLLADA 1.4 gives its path directly in init_from
+// and 1.5 in a ref element.
+type daeImage struct {
+	ID       string `xml:"id,attr"`
+	InitFrom struct {
+		Path string `xml:",chardata"`
+		Ref  string `xml:"ref"`
+	} `xml:"init_from"`
+}
+
+type daeEffect struct {
+	ID        string          `xml:"id,attr"`
+	Params    []daeNewParam   `xml:"profile_COMMON>newparam"`
+	Technique daeTechniqueSet `xml:"profile_COMMON>technique"`
+}
+
+// daeNewParam is an effect parameter: a surface naming an image (COLLADA 1.4)
+// or a sampler naming a surface or, in 1.5, an image.
+type daeNewParam struct {
+	SID             string `xml:"sid,attr"`
+	SurfaceImage    string `xml:"surface>init_from"`
+	SamplerSurface  string `xml:"sampler2D>source"`
+	SamplerInstance daeURL `xml:"sampler2D>instance_image"`
+}
+
+// daeTechniqueSet holds the shading model of an effect, one of four.
+type daeTechniqueSet struct {
+	Phong    *daeShading `xml:"phong"`
+	Lambert  *daeShading `xml:"lambert"`
+	Blinn    *daeShading `xml:"blinn"`
+	Constant *daeShading `xml:"constant"`
+}
+
+type daeShading struct {
+	Emission     *daeColorOrTexture `xml:"emission"`
+	Ambient      *daeColorOrTexture `xml:"ambient"`
+	Diffuse      *daeColorOrTexture `xml:"diffuse"`
+	Specular     *daeColorOrTexture `xml:"specular"`
+	Transparent  *daeColorOrTexture `xml:"transparent"`
+	Transparency *struct {
+		Float string `xml:"float"`
+	} `xml:"transparency"`
+}
+
+type daeColorOrTexture struct {
+	Opaque  string `xml:"opaque,attr"`
+	Color   string `xml:"color"`
+	Texture *struct {
+		Texture string `xml:"texture,attr"`
+	} `xml:"texture"`
+}
+
+type daeMaterial struct {
+	ID             string `xml:"id,attr"`
+	Name           string `xml:"name,attr"`
+	InstanceEffect daeURL `xml:"instance_effect"`
+}
+
+type daeGeometry struct {
+	ID   string   `xml:"id,attr"`
+	Mesh *daeMesh `xml:"mesh"`
+}
+
+type daeMesh struct {
+	Sources  []daeSource `xml:"source"`
+	Vertices struct {
+		ID     string     `xml:"id,attr"`
+		Inputs []daeInput `xml:"input"`
+	} `xml:"vertices"`
+	Triangles []daePrimitive `xml:"triangles"`
+	Polylists []daePrimitive `xml:"polylist"`
+	Polygons  []daePrimitive `xml:"polygons"`
+}
+
+type daeSource struct {
+	ID         string `xml:"id,attr"`
+	FloatArray string `xml:"float_array"`
+	Accessor   struct {
+		Count  int `xml:"count,attr"`
+		Offset int `xml:"offset,attr"`
+		Stride int `xml:"stride,attr"`
+	} `xml:"technique_common>accessor"`
+}
+
+type daeInput struct {
+	Semantic string `xml:"semantic,attr"`
+	Source   string `xml:"source,attr"`
+	Offset   int    `xml:"offset,attr"`
+	Set      int    `xml:"set,attr"`
+}
+
+// daePrimitive is a triangles, polylist or polygons element. Triangles and
+// polylists hold one p element; polygons hold one per polygon, those with holes
+// in ph elements.
+type daePrimitive struct {
+	Material string     `xml:"material,attr"`
+	Inputs   []daeInput `xml:"input"`
+	VCount   string     `xml:"vcount"`
+	P        []string   `xml:"p"`
+	PH       []struct {
+		P string `xml:"p"`
+	} `xml:"ph"`
+}
+
+type daeController struct {
+	ID   string `xml:"id,attr"`
+	Skin struct {
+		Source          string `xml:"source,attr"`
+		BindShapeMatrix string `xml:"bind_shape_matrix"`
+	} `xml:"skin"`
+}
+
+type daeVisualScene struct {
+	ID    string    `xml:"id,attr"`
+	Nodes []daeNode `xml:"node"`
+}
+
+// daeNode is a scene node. Its transform elements are kept in order in
+// Elements, which also collects the elements the importer ignores.
+type daeNode struct {
+	ID                  string                `xml:"id,attr"`
+	Nodes               []daeNode             `xml:"node"`
+	InstanceGeometries  []daeInstanceGeometry `xml:"instance_geometry"`
+	InstanceControllers []daeInstanceGeometry `xml:"instance_controller"`
+	InstanceNodes       []daeURL              `xml:"instance_node"`
+	Elements            []struct {
+		XMLName xml.Name
+		Text    string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+type daeInstanceGeometry struct {
+	URL       string                `xml:"url,attr"`
+	Materials []daeInstanceMaterial `xml:"bind_material>technique_common>instance_material"`
+}
+
+// daeInstanceMaterial binds a material symbol of a geometry's primitives to a
+// material.
+type daeInstanceMaterial struct {
+	Symbol string `xml:"symbol,attr"`
+	Target string `xml:"target,attr"`
+}
+
+// daeMaxDepth bounds node nesting, including through instance_node references.
+const daeMaxDepth = 256
+
+// ImportCtx is like Import but stops early when ctx is done.
+func (imp *ColladaImporter) ImportCtx(ctx context.Context, r io.Reader) (*Mesh, error) {
+	counter := &countingReader{r: r}
+	var doc daeDocument
+	if err := xml.NewDecoder(&contextReader{ctx: ctx, r: counter}).Decode(&doc); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, &FormatError{Format: "collada", Offset: counter.n, Err: err}
+	}
+
+	mesh := &Mesh{Vertices: []Vertex{}, Faces: []Face{}, Materials: []Material{}}
+	materials := make(map[string]int)
+	for _, mat := range doc.Materials {
+		materials[mat.ID] = len(mesh.Materials)
+		mesh.Materials = append(mesh.Materials, imp.material(&doc, mat))
+	}
+
+	instances, err := daeInstances(&doc)
+	if err != nil {
+		return nil, &FormatError{Format: "collada", Offset: -1, Msg: "invalid scene graph", Err: err}
+	}
+	axes := identityGLTFMatrix
+	switch strings.TrimSpace(doc.UpAxis) {
+	case "Z_UP":
+		axes = gltfMatrix{1, 0, 0, 0, 0, 0, -1, 0, 0, 1, 0, 0, 0, 0, 0, 1}
+	case "X_UP":
+		axes = gltfMatrix{0, 1, 0, 0, -1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+	}
+
+	// Vertex colors apply to the whole mesh, so geometries without them take
+	// their materials' colors instead
+	for _, inst := range instances {
+		if inst.geometry.hasColors() {
+			mesh.HasVertexColors = true
+		}
+	}
+	for _, inst := range instances {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Bound material symbols name materials by ID; unbound ones are tried as
+		// material IDs themselves
+		material := func(symbol string) int {
+			for _, m := range inst.materials {
+				if m.Symbol == symbol {
+					symbol = strings.TrimPrefix(m.Target, "#")
+					break
+				}
+			}
+			if index, ok := materials[symbol]; ok {
+				return index
+			}
+			return -1
+		}
+		if err := addDAEGeometry(mesh, inst.geometry, axes.mul(inst.transform), material); err != nil {
+			return nil, &FormatError{Format: "collada", Offset: -1, Msg: fmt.Sprintf("geometry %q", inst.geometry.ID), Err: err}
+		}
+	}
+
+	mesh.CalculateBounds()
+	return mesh, nil
+}
+
+// SupportedFormats returns the list of supported file extensions.
+func (imp *ColladaImporter) SupportedFormats() []string {
+	return []string{".dae"}
+}
+
+// daeInstance is a geometry placed in the scene by a node.
+type daeInstance struct {
+	geometry  *daeGeometry
+	transform gltfMatrix
+	materials []daeInstanceMaterial
+}
+
+// daeInstances walks the scene's visual scene (or the first one) and returns
+// each geometry instance with its node's world transform. Without a visual
+// scene, every geometry is returned untransformed.
+func daeInstances(doc *daeDocument) ([]daeInstance, error) {
+	geometries := make(map[string]*daeGeometry)
+	for i := range doc.Geometries {
+		geometries[doc.Geometries[i].ID] = &doc.Geometries[i]
+	}
+	if len(doc.VisualScenes) == 0 {
+		var instances []daeInstance
+		for i := range doc.Geometries {
+			instances = append(instances, daeInstance{geometry: &doc.Geometries[i], transform: identityGLTFMatrix})
+		}
+		return instances, nil
+	}
+	scene := &doc.VisualScenes[0]
+	for i := range doc.VisualScenes {
+		if "#"+doc.VisualScenes[i].ID == doc.Scene.VisualScene.URL {
+			scene = &doc.VisualScenes[i]
+		}
+	}
+	libraryNodes := make(map[string]*daeNode)
+	var index func(nodes []daeNode)
+	index = func(nodes []daeNode) {
+		for i := range nodes {
+			libraryNodes[nodes[i].ID] = &nodes[i]
+			index(nodes[i].Nodes)
+		}
+	}
+	index(doc.Nodes)
+	controllers := make(map[string]*daeController)
+	for i := range doc.Controllers {
+		controllers[doc.Controllers[i].ID] = &doc.Controllers[i]
+	}
+
+	var instances []daeInstance
+	var visit func(node *daeNode, parent gltfMatrix, depth int) error
+	visit = func(node *daeNode, parent gltfMatrix, depth int) error {
+		if depth > daeMaxDepth {
+			return fmt.Errorf("node %q nested too deeply", node.ID)
+		}
+		world, err := node.matrix()
+		if err != nil {
+			return fmt.Errorf("node %q: %w", node.ID, err)
+		}
+		world = parent.mul(world)
+		for _, inst := range node.InstanceGeometries {
+			if g := geometries[strings.TrimPrefix(inst.URL, "#")]; g != nil && g.Mesh != nil {
+				instances = append(instances, daeInstance{g, world, inst.Materials})
+			}
+		}
+		for _, inst := range node.InstanceControllers {
+			c := controllers[strings.TrimPrefix(inst.URL, "#")]
+			if c == nil {
+				continue
+			}
+			g := geometries[strings.TrimPrefix(c.Skin.Source, "#")]
+			if g == nil || g.Mesh == nil {
+				continue
+			}
+			bind := identityGLTFMatrix
+			if strings.TrimSpace(c.Skin.BindShapeMatrix) != "" {
+				if bind, err = daeMatrix(c.Skin.BindShapeMatrix); err != nil {
+					return fmt.Errorf("controller %q: %w", c.ID, err)
+				}
+			}
+			instances = append(instances, daeInstance{g, world.mul(bind), inst.Materials})
+		}
+		for i := range node.Nodes {
+			if err := visit(&node.Nodes[i], world, depth+1); err != nil {
+				return err
+			}
+		}
+		for _, inst := range node.InstanceNodes {
+			if n := libraryNodes[strings.TrimPrefix(inst.URL, "#")]; n != nil {
+				if err := visit(n, world, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for i := range scene.Nodes {
+		if err := visit(&scene.Nodes[i], identityGLTFMatrix, 0); err != nil {
+			return nil, err
+		}
+	}
+	return instances, nil
+}
+
+// matrix returns a node's local transform, the product of its transform
+// elements in order.
+func (n *daeNode) matrix() (gltfMatrix, error) {
+	m := identityGLTFMatrix
+	for _, e := range n.Elements {
+		var t gltfMatrix
+		switch e.XMLName.Local {
+		case "matrix":
+			var err error
+			if t, err = daeMatrix(e.Text); err != nil {
+				return m, err
+			}
+		case "translate", "scale", "rotate":
+			values, err := daeFloats(e.Text)
+			if err != nil || len(values) < 3 || (e.XMLName.Local == "rotate" && len(values) < 4) {
+				return m, fmt.Errorf("invalid %s %q", e.XMLName.Local, strings.TrimSpace(e.Text))
+			}
+			switch e.XMLName.Local {
+			case "translate":
+				t = fbxTranslation([3]float64{values[0], values[1], values[2]})
+			case "scale":
+				t = fbxScaling([3]float64{values[0], values[1], values[2]})
+			default:
+				t = daeRotation([3]float64{values[0], values[1], values[2]}, values[3])
+			}
+		default:
+			continue
+		}
+		m = m.mul(t)
+	}
+	return m, nil
+}
+
+// daeMatrix parses a row-major 4x4 matrix.
+func daeMatrix(text string) (gltfMatrix, error) {
+	values, err := daeFloats(text)
+	if err != nil || len(values) != 16 {
+		return identityGLTFMatrix, fmt.Errorf("invalid matrix %q", strings.TrimSpace(text))
+	}
+	var m gltfMatrix
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			m[col*4+row] = values[row*4+col]
+		}
+	}
+	return m, nil
+}
+
+// daeRotation returns the rotation by angle degrees about axis.
+func daeRotation(axis [3]float64, angle float64) gltfMatrix {
+	length := math.Sqrt(dot3(axis, axis))
+	if length == 0 {
+		return identityGLTFMatrix
+	}
+	x, y, z := axis[0]/length, axis[1]/length, axis[2]/length
+	s, c := math.Sincos(angle * math.Pi / 180)
+	t := 1 - c
+	return gltfMatrix{
+		t*x*x + c, t*x*y + s*z, t*x*z - s*y, 0,
+		t*x*y - s*z, t*y*y + c, t*y*z + s*x, 0,
+		t*x*z + s*y, t*y*z - s*x, t*z*z + c, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// daeFloats parses a list of whitespace-separated numbers.
+func daeFloats(text string) ([]float64, error) {
+	fields := strings.Fields(text)
+	return parseFloats(fields, 0, len(fields))
+}
+
+// material converts a COLLADA material from its effect's shading: diffuse,
+// emission and transparency, with a diffuse texture read from Resources.
+func (imp *ColladaImporter) material(doc *daeDocument, mat daeMaterial) Material {
+	material := Material{
+		Name:         mat.Name,
+		DiffuseColor: [3]float64{0.8, 0.8, 0.8},
+		Opacity:      1,
+	}
+	if material.Name == "" {
+		material.Name = mat.ID
+	}
+	var effect *daeEffect
+	for i := range doc.Effects {
+		if "#"+doc.Effects[i].ID == mat.InstanceEffect.URL {
+			effect = &doc.Effects[i]
+		}
+	}
+	if effect == nil {
+		return material
+	}
+	t := effect.Technique
+	shading := t.Phong
+	for _, s := range []*daeShading{t.Lambert, t.Blinn, t.Constant} {
+		if shading == nil {
+			shading = s
+		}
+	}
+	if shading == nil {
+		return material
+	}
+
+	color := func(c *daeColorOrTexture) ([4]float64, bool) {
+		if c == nil {
+			return [4]float64{}, false
+		}
+		values, err := daeFloats(c.Color)
+		if err != nil || len(values) < 3 {
+			return [4]float64{}, false
+		}
+		rgba := [4]float64{values[0], values[1], values[2], 1}
+		if len(values) > 3 {
+			rgba[3] = values[3]
+		}
+		return rgba, true
+	}
+	rgb := func(c [4]float64) [3]float64 { return [3]float64{c[0], c[1], c[2]} }
+	if c, ok := color(shading.Diffuse); ok {
+		material.DiffuseColor = rgb(c)
+	}
+	if c, ok := color(shading.Emission); ok {
+		material.EmissiveColor = rgb(c)
+	}
+	if c, ok := color(shading.Ambient); ok {
+		material.AmbientColor = rgb(c)
+	}
+	if c, ok := color(shading.Specular); ok {
+		material.SpecularColor = rgb(c)
+	}
+	// The transparent color and transparency factor give the opacity, by the
+	// color's alpha (A_ONE, the default) or its inverted luminance (RGB_ZERO)
+	if c, ok := color(shading.Transparent); ok {
+		factor := 1.0
+		if shading.Transparency != nil {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(shading.Transparency.Float), 64); err == nil {
+				factor = f
+			}
+		}
+		opacity := c[3] * factor
+		if shading.Transparent.Opaque == "RGB_ZERO" {
+			opacity = 1 - (0.2126*c[0]+0.7152*c[1]+0.0722*c[2])*factor
+		}
+		material.Opacity = math.Max(0, math.Min(1, opacity))
+	}
+
+	if shading.Diffuse != nil && shading.Diffuse.Texture != nil {
+		material.Texture, material.TexturePath = imp.texture(doc, effect, shading.Diffuse.Texture.Texture)
+		material.DiffuseColor = [3]float64{1, 1, 1}
+	}
+	return material
+}
+
+// texture resolves an effect's texture reference, a sampler parameter or an
+// image ID, to the image's path and reads it from Resources.
+func (imp *ColladaImporter) texture(doc *daeDocument, effect *daeEffect, ref string) (image.Image, string) {
+	param := func(sid string) *daeNewParam {
+		for i := range effect.Params {
+			if effect.Params[i].SID == sid {
+				return &effect.Params[i]
+			}
+		}
+		return nil
+	}
+	imageID := ref
+	if sampler := param(ref); sampler != nil {
+		imageID = strings.TrimPrefix(sampler.SamplerInstance.URL, "#")
+		if surface := param(strings.TrimSpace(sampler.SamplerSurface)); surface != nil {
+			imageID = strings.TrimSpace(surface.SurfaceImage)
+		}
+	}
+	for _, img := range doc.Images {
+		if img.ID != imageID {
+			continue
+		}
+		name := strings.TrimSpace(img.InitFrom.Path)
+		if ref := strings.TrimSpace(img.InitFrom.Ref); ref != "" {
+			name = ref
+		}
+		// Paths are URIs, usually relative, sometimes file URLs
+		name = strings.TrimPrefix(name, "file://")
+		name = strings.ReplaceAll(strings.ReplaceAll(name, "%20", " "), "\\", "/")
+		if name == "" {
+			return nil, ""
+		}
+		return readTextureFile(imp.Resources, strings.TrimPrefix(name, "./")), name
+	}
+	return nil, ""
+}
+
+// hasColors reports whether any primitive of the geometry has vertex colors.
+func (g *daeGeometry) hasColors() bool {
+	if g.Mesh == nil {
+		return false
+	}
+	for _, in := range g.Mesh.Vertices.Inputs {
+		if in.Semantic == "COLOR" {
+			return true
+		}
+	}
+	for _, list := range [][]daePrimitive{g.Mesh.Triangles, g.Mesh.Polylists, g.Mesh.Polygons} {
+		for _, prim := range list {
+			for _, in := range prim.Inputs {
+				if in.Semantic == "COLOR" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// daeStream is a source bound to a primitive input: the elements it holds and
+// the position of its index within each vertex's index tuple.
+type daeStream struct {
+	values []float64
+	stride int
+	count  int
+	offset int
+}
+
+// lookup returns the element a vertex's index tuple selects, or false when the
+// stream is nil or the index is out of range.
+func (s *daeStream) lookup(tuple []int64) ([]float64, bool) {
+	if s == nil {
+		return nil, false
+	}
+	i := tuple[s.offset]
+	if i < 0 || i >= int64(s.count) {
+		return nil, false
+	}
+	return s.values[int(i)*s.stride : int(i+1)*s.stride], true
+}
+
+// addDAEGeometry adds a mesh geometry's primitives, triangulated, with one
+// vertex per index tuple. Material maps the primitives' material symbols to
+// mesh materials.
+func addDAEGeometry(mesh *Mesh, g *daeGeometry, transform gltfMatrix, material func(string) int) error {
+	sources := make(map[string]*daeSource)
+	for i := range g.Mesh.Sources {
+		sources[g.Mesh.Sources[i].ID] = &g.Mesh.Sources[i]
+	}
+	parsed := make(map[string][]float64)
+	stream := func(in daeInput, minStride int) (*daeStream, error) {
+		src := sources[strings.TrimPrefix(in.Source, "#")]
+		if src == nil {
+			return nil, fmt.Errorf("%s source %q not found", in.Semantic, in.Source)
+		}
+		values, ok := parsed[src.ID]
+		if !ok {
+			var err error
+			if values, err = daeFloats(src.FloatArray); err != nil {
+				return nil, fmt.Errorf("source %q: %w", src.ID, err)
+			}
+			parsed[src.ID] = values
+		}
+		stride := max(1, src.Accessor.Stride)
+		if stride < minStride {
+			return nil, fmt.Errorf("%s source %q has %d components, need %d", in.Semantic, src.ID, stride, minStride)
+		}
+		values = values[min(max(0, src.Accessor.Offset), len(values)):]
+		count := len(values) / stride
+		if src.Accessor.Count > 0 && src.Accessor.Count < count {
+			count = src.Accessor.Count
+		}
+		return &daeStream{values: values, stride: stride, count: count, offset: in.Offset}, nil
+	}
+
+	mirrored := transform.mirrors()
+	addPrimitive := func(prim daePrimitive, kind string) error {
+		// Resolve the inputs; the vertices element bundles per-position inputs
+		// under the VERTEX input's index
+		var position, normal, texCoord, color *daeStream
+		tuple := 1
+		for _, in := range prim.Inputs {
+			if in.Offset < 0 {
+				return fmt.Errorf("%s input has negative offset %d", in.Semantic, in.Offset)
+			}
+			tuple = max(tuple, in.Offset+1)
+			inputs := []daeInput{in}
+			if in.Semantic == "VERTEX" {
+				inputs = inputs[:0]
+				for _, v := range g.Mesh.Vertices.Inputs {
+					inputs = append(inputs, daeInput{Semantic: v.Semantic, Source: v.Source, Offset: in.Offset, Set: v.Set})
+				}
+			}
+			for _, in := range inputs {
+				var err error
+				switch {
+				case in.Semantic == "POSITION":
+					position, err = stream(in, 3)
+				case in.Semantic == "NORMAL":
+					normal, err = stream(in, 3)
+				case in.Semantic == "TEXCOORD" && texCoord == nil:
+					texCoord, err = stream(in, 2)
+				case in.Semantic == "COLOR":
+					color, err = stream(in, 3)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if position == nil {
+			return fmt.Errorf("%s without positions", kind)
+		}
+
+		// Triangles have 3 vertices each, polylists list their counts and each
+		// p of polygons is one polygon
+		var indices []int64
+		var counts []int
+		ps := append([]string(nil), prim.P...)
+		for _, ph := range prim.PH {
+			ps = append(ps, ph.P)
+		}
+		for _, p := range ps {
+			values, err := daeFloats(p)
+			if err != nil {
+				return fmt.Errorf("%s: invalid indices: %w", kind, err)
+			}
+			for _, v := range values {
+				indices = append(indices, int64(v))
+			}
+			counts = append(counts, len(values)/tuple)
+		}
+		switch kind {
+		case "triangles":
+			counts = make([]int, len(indices)/tuple/3)
+			for i := range counts {
+				counts[i] = 3
+			}
+		case "polylist":
+			values, err := daeFloats(prim.VCount)
+			if err != nil {
+				return fmt.Errorf("%s: invalid vertex counts: %w", kind, err)
+			}
+			counts = counts[:0]
+			for _, v := range values {
+				counts = append(counts, int(v))
+			}
+		}
+
+		materialIndex := material(prim.Material)
+		next := 0
+		for _, n := range counts {
+			if n < 0 || (next+n)*tuple > len(indices) {
+				return fmt.Errorf("%s: vertex counts exceed the indices", kind)
+			}
+			polygon := make([]int, 0, n)
+			for k := 0; k < n; k++ {
+				index := indices[(next+k)*tuple : (next+k+1)*tuple]
+				p, ok := position.lookup(index)
+				if !ok {
+					return fmt.Errorf("%s: position index %d out of range", kind, index[position.offset])
+				}
+				vertex := Vertex{Position: transform.transformPoint([3]float64{p[0], p[1], p[2]})}
+				if n, ok := normal.lookup(index); ok {
+					vertex.Normal = transform.transformNormal([3]float64{n[0], n[1], n[2]})
+				}
+				if uv, ok := texCoord.lookup(index); ok {
+					vertex.TexCoord = [2]float64{uv[0], 1 - uv[1]} // COLLADA puts V = 0 at the bottom
+				}
+				if c, ok := color.lookup(index); ok {
+					vertex.Color = [3]float64{c[0], c[1], c[2]}
+				} else if mesh.HasVertexColors && materialIndex >= 0 {
+					vertex.Color = mesh.Materials[materialIndex].DiffuseColor
+				} else if mesh.HasVertexColors {
+					vertex.Color = [3]float64{0.5, 0.5, 0.5}
+				}
+				polygon = append(polygon, len(mesh.Vertices))
+				mesh.Vertices = append(mesh.Vertices, vertex)
+			}
+			next += n
+			if n < 3 {
+				continue
+			}
+			for _, tri := range triangulatePolygon(mesh.Vertices, polygon) {
+				if mirrored {
+					tri[1], tri[2] = tri[2], tri[1]
+				}
+				mesh.Faces = append(mesh.Faces, Face{VertexIndices: []int{tri[0], tri[1], tri[2]}, MaterialIndex: materialIndex})
+			}
+		}
+		return nil
+	}
+
+	for _, prim := range g.Mesh.Triangles {
+		if err := addPrimitive(prim, "triangles"); err != nil {
+			return err
+		}
+	}
+	for _, prim := range g.Mesh.Polylists {
+		if err := addPrimitive(prim, "polylist"); err != nil {
+			return err
+		}
+	}
+	for _, prim := range g.Mesh.Polygons {
+		if err := addPrimitive(prim, "polygons"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
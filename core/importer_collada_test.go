@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testDAE is a Z-up document with a textured quad and a red triangle, placed by
+// nested nodes.
+const testDAE = `<?xml version="1.0" encoding="utf-8"?>
+<COLLADA xmlns="http://www.collada.org/2005/11/COLLADASchema" version="1.4.1">
+  <asset><up_axis>Z_UP</up_axis></asset>
+  <library_images>
+    <image id="wood-image"><init_from>textures/wood.png</init_from></image>
+  </library_images>
+  <library_effects>
+    <effect id="wood-effect"><profile_COMMON>
+      <newparam sid="wood-surface"><surface type="2D"><init_from>wood-image</init_from></surface></newparam>
+      <newparam sid="wood-sampler"><sampler2D><source>wood-surface</source></sampler2D></newparam>
+      <technique sid="common"><lambert>
+        <diffuse><texture texture="wood-sampler" texcoord="UVMap"/></diffuse>
+      </lambert></technique>
+    </profile_COMMON></effect>
+    <effect id="red-effect"><profile_COMMON>
+      <technique sid="common"><phong>
+        <emission><color>0.5 0 0 1</color></emission>
+        <diffuse><color>1 0 0 1</color></diffuse>
+        <transparent opaque="A_ONE"><color>1 1 1 1</color></transparent>
+        <transparency><float>0.25</float></transparency>
+      </phong></technique>
+    </profile_COMMON></effect>
+  </library_effects>
+  <library_materials>
+    <material id="wood-material" name="Wood"><instance_effect url="#wood-effect"/></material>
+    <material id="red-material" name="Red"><instance_effect url="#red-effect"/></material>
+  </library_materials>
+  <library_geometries>
+    <geometry id="quad-mesh"><mesh>
+      <source id="quad-positions">
+        <float_array id="quad-positions-array" count="12">0 0 0 1 0 0 1 1 0 0 1 0</float_array>
+        <technique_common><accessor source="#quad-positions-array" count="4" stride="3"/></technique_common>
+      </source>
+      <source id="quad-uvs">
+        <float_array id="quad-uvs-array" count="8">0 0 1 0 1 1 0 1</float_array>
+        <technique_common><accessor source="#quad-uvs-array" count="4" stride="2"/></technique_common>
+      </source>
+      <vertices id="quad-vertices"><input semantic="POSITION" source="#quad-positions"/></vertices>
+      <polylist material="wood-symbol" count="1">
+        <input semantic="VERTEX" source="#quad-vertices" offset="0"/>
+        <input semantic="TEXCOORD" source="#quad-uvs" offset="1" set="0"/>
+        <vcount>4</vcount>
+        <p>0 0 1 1 2 2 3 3</p>
+      </polylist>
+      <triangles material="red-material" count="1">
+        <input semantic="VERTEX" source="#quad-vertices" offset="0"/>
+        <p>0 1 3</p>
+      </triangles>
+    </mesh></geometry>
+  </library_geometries>
+  <library_visual_scenes>
+    <visual_scene id="scene">
+      <node id="parent">
+        <translate>10 0 0</translate>
+        <node id="child">
+          <rotate>0 0 1 90</rotate>
+          <scale>2 2 2</scale>
+          <instance_geometry url="#quad-mesh">
+            <bind_material><technique_common>
+              <instance_material symbol="wood-symbol" target="#wood-material"/>
+            </technique_common></bind_material>
+          </instance_geometry>
+        </node>
+      </node>
+    </visual_scene>
+  </library_visual_scenes>
+  <scene><instance_visual_scene url="#scene"/></scene>
+</COLLADA>`
+
+func TestColladaImporter(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.NRGBA{120, 80, 40, 255})
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+	imp := NewColladaImporter()
+	imp.SetResources(fstest.MapFS{"textures/wood.png": {Data: pngData.Bytes()}})
+
+	mesh, err := imp.Import(strings.NewReader(testDAE))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(mesh.Vertices) != 7 || len(mesh.Faces) != 3 || len(mesh.Materials) != 2 {
+		t.Fatalf("got %d vertices, %d faces, %d materials", len(mesh.Vertices), len(mesh.Faces), len(mesh.Materials))
+	}
+
+	// Triangles come first, then the quad. Quad corner (1,1,0) is scaled to
+	// (2,2,0), rotated to (-2,2,0), moved to (8,2,0) and turned Y up to (8,0,-2)
+	want := [3]float64{8, 0, -2}
+	for a, got := range mesh.Vertices[5].Position {
+		if math.Abs(got-want[a]) > 1e-9 {
+			t.Fatalf("quad corner at %v, want %v", mesh.Vertices[5].Position, want)
+		}
+	}
+	if got := mesh.Vertices[6].TexCoord; got != [2]float64{0, 0} {
+		t.Errorf("texture coordinate = %v, want V flipped", got)
+	}
+
+	wood, red := mesh.Materials[0], mesh.Materials[1]
+	if wood.Name != "Wood" || wood.Texture == nil || wood.TexturePath != "textures/wood.png" {
+		t.Errorf("wood material = %+v", wood)
+	}
+	if red.DiffuseColor != [3]float64{1, 0, 0} || red.EmissiveColor != [3]float64{0.5, 0, 0} || red.Opacity != 0.25 {
+		t.Errorf("red material = %+v", red)
+	}
+	for i, want := range []int{1, 0, 0} {
+		if got := mesh.Faces[i].MaterialIndex; got != want {
+			t.Errorf("face %d material %d, want %d", i, got, want)
+		}
+	}
+
+	var formatErr *FormatError
+	if _, err := NewColladaImporter().Import(strings.NewReader(testDAE[:200])); !errors.As(err, &formatErr) {
+		t.Errorf("truncated file: expected FormatError, got %v", err)
+	}
+}
@@ -0,0 +1,610 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+)
+
+// FBXImporter implements MeshImporter for Autodesk FBX 7.x files, binary or
+// ASCII. It reads the mesh geometry of every model, placed by the model
+// hierarchy's transforms and turned Y up by the file's axis settings, with
+// normals, texture coordinates and vertex colors, and the models' materials with
+// their diffuse and emissive textures. Cameras, lights, skinning and animation
+// are ignored.
+type FBXImporter struct {
+	// Resources holds the texture files materials name without embedding them,
+	// with paths relative to the FBX file. When nil, such textures keep only
+	// Material.TexturePath.
+	Resources fs.FS
+}
+
+// NewFBXImporter creates a new FBX importer.
+func NewFBXImporter() *FBXImporter {
+	return &FBXImporter{}
+}
+
+// SetResources implements ResourceMeshImporter.
+func (imp *FBXImporter) SetResources(fsys fs.FS) {
+	imp.Resources = fsys
+}
+
+// Import reads and parses an FBX mesh from the given reader.
+func (imp *FBXImporter) Import(r io.Reader) (*Mesh, error) {
+	return imp.ImportCtx(context.Background(), r)
+}
+
+// ImportCtx is like Import but stops early when ctx is done.
+func (imp *FBXImporter) ImportCtx(ctx context.Context, r io.Reader) (*Mesh, error) {
+	// Binary records point at absolute offsets, so the file is read whole
+	counter := &countingReader{r: r}
+	data, err := io.ReadAll(&contextReader{ctx: ctx, r: counter})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, &FormatError{Format: "fbx", Offset: counter.n, Err: err}
+	}
+	nodes, err := parseFBX(data)
+	if err != nil {
+		return nil, err
+	}
+	scene := newFBXScene(nodes)
+
+	mesh := &Mesh{Vertices: []Vertex{}, Faces: []Face{}, Materials: []Material{}}
+	materials := make(map[int64]int)
+	for _, obj := range scene.objects {
+		if obj.name == "Material" {
+			materials[scene.id(obj)] = len(mesh.Materials)
+			mesh.Materials = append(mesh.Materials, imp.material(scene, obj))
+		}
+	}
+
+	// Each model places the mesh geometries attached to it, with the materials
+	// attached to it in order as the geometries' material slots
+	type instance struct {
+		geometry  *fbxNode
+		transform gltfMatrix
+		slots     []int
+	}
+	var instances []instance
+	axes := scene.axisMatrix()
+	for _, obj := range scene.objects {
+		if obj.name != "Model" {
+			continue
+		}
+		id := scene.id(obj)
+		var geometries []*fbxNode
+		var slots []int
+		for _, c := range scene.children[id] {
+			child := scene.byID[c.id]
+			switch {
+			case child == nil:
+			case child.name == "Geometry" && fbxString(child.prop(2)) == "Mesh":
+				geometries = append(geometries, child)
+			case child.name == "Material":
+				slots = append(slots, materials[c.id])
+			}
+		}
+		if len(geometries) == 0 {
+			continue
+		}
+		world, err := scene.worldMatrix(id)
+		if err != nil {
+			return nil, &FormatError{Format: "fbx", Offset: -1, Msg: "invalid model hierarchy", Err: err}
+		}
+		transform := axes.mul(world).mul(fbxGeometricMatrix(obj))
+		for _, geometry := range geometries {
+			instances = append(instances, instance{geometry, transform, slots})
+		}
+	}
+
+	// Vertex colors apply to the whole mesh, so geometries without them take
+	// their materials' colors instead
+	for _, inst := range instances {
+		if colors := newFBXLayer(inst.geometry, "LayerElementColor", "Colors", "ColorIndex", 4); colors != nil && !colors.white() {
+			mesh.HasVertexColors = true
+		}
+	}
+	for _, inst := range instances {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := addFBXGeometry(mesh, inst.geometry, inst.transform, inst.slots); err != nil {
+			return nil, &FormatError{Format: "fbx", Offset: -1, Msg: fmt.Sprintf("geometry %d", scene.id(inst.geometry)), Err: err}
+		}
+	}
+
+	mesh.CalculateBounds()
+	return mesh, nil
+}
+
+// SupportedFormats returns the list of supported file extensions.
+func (imp *FBXImporter) SupportedFormats() []string {
+	return []string{".fbx"}
+}
+
+// fbxScene indexes the objects of an FBX document and the connections between
+// them.
+type fbxScene struct {
+	root     []*fbxNode
+	objects  []*fbxNode // In document order
+	byID     map[int64]*fbxNode
+	parents  map[int64][]fbxConnection
+	children map[int64][]fbxConnection
+}
+
+// fbxConnection links an object to another, optionally through one of the
+// other's properties.
+type fbxConnection struct {
+	id       int64
+	property string
+}
+
+func newFBXScene(root []*fbxNode) *fbxScene {
+	s := &fbxScene{
+		root:     root,
+		byID:     make(map[int64]*fbxNode),
+		parents:  make(map[int64][]fbxConnection),
+		children: make(map[int64][]fbxConnection),
+	}
+	for _, n := range root {
+		switch n.name {
+		case "Objects":
+			for _, obj := range n.children {
+				if id, ok := fbxInt(obj.prop(0)); ok {
+					s.objects = append(s.objects, obj)
+					s.byID[id] = obj
+				}
+			}
+		case "Connections":
+			for _, c := range n.children {
+				child, okChild := fbxInt(c.prop(1))
+				parent, okParent := fbxInt(c.prop(2))
+				if c.name != "C" || !okChild || !okParent {
+					continue
+				}
+				property := fbxString(c.prop(3))
+				s.parents[child] = append(s.parents[child], fbxConnection{parent, property})
+				s.children[parent] = append(s.children[parent], fbxConnection{child, property})
+			}
+		}
+	}
+	return s
+}
+
+// id returns an object's ID.
+func (s *fbxScene) id(obj *fbxNode) int64 {
+	id, _ := fbxInt(obj.prop(0))
+	return id
+}
+
+// fbxObjectName strips the class from an object name, stored as
+// "Name\x00\x01Class" in binary files and as "Class::Name" in ASCII ones.
+func fbxObjectName(name string) string {
+	if i := strings.Index(name, "\x00\x01"); i >= 0 {
+		return name[:i]
+	}
+	if i := strings.Index(name, "::"); i >= 0 {
+		return name[i+2:]
+	}
+	return name
+}
+
+// fbxProperty returns the values of one of an object's Properties70 entries,
+// which follow its name, type, label and flags.
+func fbxProperty(obj *fbxNode, name string) []interface{} {
+	if props := obj.child("Properties70"); props != nil {
+		for _, p := range props.children {
+			if p.name == "P" && len(p.props) >= 4 && fbxString(p.props[0]) == name {
+				return p.props[4:]
+			}
+		}
+	}
+	return nil
+}
+
+// fbxScalar returns a numeric property, or def when the object lacks it.
+func fbxScalar(obj *fbxNode, name string, def float64) float64 {
+	if values := fbxProperty(obj, name); len(values) > 0 {
+		if v, ok := fbxFloat(values[0]); ok {
+			return v
+		}
+	}
+	return def
+}
+
+// fbxVector returns a three-component property, or def when the object lacks it.
+func fbxVector(obj *fbxNode, name string, def [3]float64) [3]float64 {
+	values := fbxProperty(obj, name)
+	if len(values) < 3 {
+		return def
+	}
+	var v [3]float64
+	for i := range v {
+		f, ok := fbxFloat(values[i])
+		if !ok {
+			return def
+		}
+		v[i] = f
+	}
+	return v
+}
+
+// axisMatrix returns the transform turning the file's axes, given by its global
+// settings, into Y up, X right and Z front.
+func (s *fbxScene) axisMatrix() gltfMatrix {
+	var settings *fbxNode
+	for _, n := range s.root {
+		if n.name == "GlobalSettings" {
+			settings = n
+		}
+	}
+	if settings == nil {
+		return identityGLTFMatrix
+	}
+	var m gltfMatrix
+	seen := [3]bool{}
+	for row, name := range [3]string{"CoordAxis", "UpAxis", "FrontAxis"} {
+		def := float64(row)
+		axis := int(fbxScalar(settings, name, def))
+		if axis < 0 || axis > 2 || seen[axis] {
+			return identityGLTFMatrix
+		}
+		seen[axis] = true
+		m[axis*4+row] = 1
+		if fbxScalar(settings, name+"Sign", 1) < 0 {
+			m[axis*4+row] = -1
+		}
+	}
+	m[15] = 1
+	return m
+}
+
+// worldMatrix returns a model's transform, combined with its ancestors'.
+func (s *fbxScene) worldMatrix(id int64) (gltfMatrix, error) {
+	world := identityGLTFMatrix
+	for depth := 0; ; depth++ {
+		model := s.byID[id]
+		if model == nil || model.name != "Model" {
+			return world, nil
+		}
+		// Models form a forest, so a path longer than the object count has a cycle
+		if depth > len(s.objects) {
+			return world, fmt.Errorf("model %d is its own ancestor", id)
+		}
+		world = fbxLocalMatrix(model).mul(world)
+		parent := int64(0)
+		for _, c := range s.parents[id] {
+			if p := s.byID[c.id]; p != nil && p.name == "Model" {
+				parent = c.id
+				break
+			}
+		}
+		id = parent
+	}
+}
+
+// fbxRotationOrders gives the Euler rotation order of each RotationOrder value,
+// the axis rotated about first coming first.
+var fbxRotationOrders = [...]string{"XYZ", "XZY", "YZX", "YXZ", "ZXY", "ZYX", "XYZ"}
+
+// fbxLocalMatrix returns a model's transform relative to its parent:
+// T * Roff * Rp * Rpre * R * Rpost^-1 * Rp^-1 * Soff * Sp * S * Sp^-1.
+func fbxLocalMatrix(model *fbxNode) gltfMatrix {
+	zero, one := [3]float64{}, [3]float64{1, 1, 1}
+	order := fbxRotationOrders[0]
+	if o := int(fbxScalar(model, "RotationOrder", 0)); o >= 0 && o < len(fbxRotationOrders) {
+		order = fbxRotationOrders[o]
+	}
+	pivot := fbxVector(model, "RotationPivot", zero)
+	scalePivot := fbxVector(model, "ScalingPivot", zero)
+	return fbxTranslation(fbxVector(model, "Lcl Translation", zero)).
+		mul(fbxTranslation(fbxVector(model, "RotationOffset", zero))).
+		mul(fbxTranslation(pivot)).
+		mul(fbxEuler(fbxVector(model, "PreRotation", zero), "XYZ", false)).
+		mul(fbxEuler(fbxVector(model, "Lcl Rotation", zero), order, false)).
+		mul(fbxEuler(fbxVector(model, "PostRotation", zero), "XYZ", true)).
+		mul(fbxTranslation([3]float64{-pivot[0], -pivot[1], -pivot[2]})).
+		mul(fbxTranslation(fbxVector(model, "ScalingOffset", zero))).
+		mul(fbxTranslation(scalePivot)).
+		mul(fbxScaling(fbxVector(model, "Lcl Scaling", one))).
+		mul(fbxTranslation([3]float64{-scalePivot[0], -scalePivot[1], -scalePivot[2]}))
+}
+
+// fbxGeometricMatrix returns a model's geometric transform, which applies to
+// its own geometry but not to its children.
+func fbxGeometricMatrix(model *fbxNode) gltfMatrix {
+	zero, one := [3]float64{}, [3]float64{1, 1, 1}
+	return fbxTranslation(fbxVector(model, "GeometricTranslation", zero)).
+		mul(fbxEuler(fbxVector(model, "GeometricRotation", zero), "XYZ", false)).
+		mul(fbxScaling(fbxVector(model, "GeometricScaling", one)))
+}
+
+func fbxTranslation(t [3]float64) gltfMatrix {
+	m := identityGLTFMatrix
+	m[12], m[13], m[14] = t[0], t[1], t[2]
+	return m
+}
+
+func fbxScaling(s [3]float64) gltfMatrix {
+	m := identityGLTFMatrix
+	m[0], m[5], m[10] = s[0], s[1], s[2]
+	return m
+}
+
+// fbxEuler returns the rotation by Euler angles in degrees, rotating about the
+// axes in the given order, or its inverse.
+func fbxEuler(degrees [3]float64, order string, inverse bool) gltfMatrix {
+	m := identityGLTFMatrix
+	for i := range order {
+		axis := int(order[i] - 'X')
+		angle := degrees[axis] * math.Pi / 180
+		if inverse {
+			axis = int(order[len(order)-1-i] - 'X')
+			angle = -degrees[axis] * math.Pi / 180
+		}
+		if angle == 0 {
+			continue
+		}
+		sin, cos := math.Sincos(angle)
+		r := identityGLTFMatrix
+		u, v := (axis+1)%3, (axis+2)%3
+		r[u*4+u], r[u*4+v] = cos, sin
+		r[v*4+u], r[v*4+v] = -sin, cos
+		m = r.mul(m)
+	}
+	return m
+}
+
+// material converts an FBX material with the textures connected to its diffuse
+// and emissive colors.
+func (imp *FBXImporter) material(s *fbxScene, obj *fbxNode) Material {
+	diffuse := fbxVector(obj, "DiffuseColor", fbxVector(obj, "Diffuse", [3]float64{0.8, 0.8, 0.8}))
+	factor := fbxScalar(obj, "DiffuseFactor", 1)
+	emissive := fbxVector(obj, "EmissiveColor", fbxVector(obj, "Emissive", [3]float64{}))
+	emissiveFactor := fbxScalar(obj, "EmissiveFactor", 1)
+	opacity := 1.0
+	if transparent := fbxVector(obj, "TransparentColor", [3]float64{}); transparent != [3]float64{} {
+		opacity = 1 - fbxScalar(obj, "TransparencyFactor", 0)*(transparent[0]+transparent[1]+transparent[2])/3
+	}
+	opacity = fbxScalar(obj, "Opacity", opacity)
+
+	material := Material{
+		Name:          fbxObjectName(fbxString(obj.prop(1))),
+		SpecularColor: fbxVector(obj, "SpecularColor", [3]float64{}),
+		AmbientColor:  fbxVector(obj, "AmbientColor", [3]float64{}),
+		Opacity:       math.Max(0, math.Min(1, opacity)),
+	}
+	for c := 0; c < 3; c++ {
+		material.DiffuseColor[c] = diffuse[c] * factor
+		material.EmissiveColor[c] = emissive[c] * emissiveFactor
+	}
+
+	for _, c := range s.children[s.id(obj)] {
+		texture := s.byID[c.id]
+		if texture == nil || texture.name != "Texture" {
+			continue
+		}
+		switch property := strings.ToLower(c.property); {
+		case property == "" || property == "diffusecolor" || strings.Contains(property, "base_color") || strings.Contains(property, "basecolor"):
+			if material.Texture != nil || material.TexturePath != "" {
+				continue
+			}
+			material.Texture, material.TexturePath = imp.texture(s, texture)
+			if material.Texture != nil {
+				// The texture replaces the color, scaled by the factor alone
+				material.DiffuseColor = [3]float64{factor, factor, factor}
+			}
+		case property == "emissivecolor":
+			material.EmissiveTexture, _ = imp.texture(s, texture)
+			if material.EmissiveTexture != nil && emissive == [3]float64{} {
+				material.EmissiveColor = [3]float64{emissiveFactor, emissiveFactor, emissiveFactor}
+			}
+		}
+	}
+	return material
+}
+
+// texture decodes a texture's image, embedded in a connected video object or
+// read from Resources. Unreadable or unsupported images leave the image nil; the
+// file name is returned when the image is not embedded.
+func (imp *FBXImporter) texture(s *fbxScene, texture *fbxNode) (img image.Image, name string) {
+	for _, c := range s.children[s.id(texture)] {
+		video := s.byID[c.id]
+		if video == nil || video.name != "Video" {
+			continue
+		}
+		if content, ok := video.child("Content").prop(0).([]byte); ok && len(content) > 0 {
+			if img, err := decodeTexture(content); err == nil && img != nil {
+				return img, ""
+			}
+		}
+	}
+
+	name = fbxString(texture.child("RelativeFilename").prop(0))
+	if name == "" {
+		name = fbxString(texture.child("FileName").prop(0))
+	}
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" {
+		return nil, ""
+	}
+	return readTextureFile(imp.Resources, name), name
+}
+
+// readTextureFile decodes a texture file named relative to fsys. Missing,
+// unreadable and unsupported files give a nil image, as does a nil fsys.
+func readTextureFile(fsys fs.FS, name string) image.Image {
+	if fsys == nil {
+		return nil
+	}
+	data, err := fs.ReadFile(fsys, path.Clean(name))
+	if err != nil {
+		return nil
+	}
+	img, _ := decodeTexture(data)
+	return img
+}
+
+// fbxLayer is a per-vertex attribute of a geometry (a layer element): values
+// mapped to control points, polygon vertices, polygons or the whole geometry,
+// directly or through an index array.
+type fbxLayer struct {
+	values  []float64
+	index   []int64
+	mapping string
+	stride  int
+}
+
+// newFBXLayer returns a geometry's first layer element of a kind, or nil.
+func newFBXLayer(geometry *fbxNode, kind, valuesName, indexName string, stride int) *fbxLayer {
+	element := geometry.child(kind)
+	if element == nil {
+		return nil
+	}
+	layer := &fbxLayer{
+		values:  fbxFloats(element.child(valuesName).prop(0)),
+		mapping: fbxString(element.child("MappingInformationType").prop(0)),
+		stride:  stride,
+	}
+	switch fbxString(element.child("ReferenceInformationType").prop(0)) {
+	case "IndexToDirect", "Index":
+		layer.index = fbxInts(element.child(indexName).prop(0))
+	}
+	if len(layer.values) < stride {
+		return nil
+	}
+	return layer
+}
+
+// at returns the values for a polygon vertex, given its position in the
+// polygon vertex list, its control point and its polygon.
+func (l *fbxLayer) at(polygonVertex, point, polygon int) ([]float64, bool) {
+	if l == nil {
+		return nil, false
+	}
+	var i int
+	switch l.mapping {
+	case "ByPolygonVertex":
+		i = polygonVertex
+	case "ByVertex", "ByVertice", "ByControlPoint":
+		i = point
+	case "ByPolygon":
+		i = polygon
+	case "AllSame":
+		i = 0
+	default:
+		return nil, false
+	}
+	if l.index != nil {
+		if i >= len(l.index) {
+			return nil, false
+		}
+		i = int(l.index[i])
+	}
+	if i < 0 || (i+1)*l.stride > len(l.values) {
+		return nil, false
+	}
+	return l.values[i*l.stride : (i+1)*l.stride], true
+}
+
+// white reports whether every color of a color layer is white, as in the
+// layers some exporters write by default.
+func (l *fbxLayer) white() bool {
+	for i := 0; i+2 < len(l.values); i += l.stride {
+		if l.values[i] < 1 || l.values[i+1] < 1 || l.values[i+2] < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// addFBXGeometry adds a mesh geometry's polygons, triangulated, with one vertex
+// per polygon vertex. Slots maps the geometry's material numbers to mesh
+// materials.
+func addFBXGeometry(mesh *Mesh, geometry *fbxNode, transform gltfMatrix, slots []int) error {
+	positions := fbxFloats(geometry.child("Vertices").prop(0))
+	indices := fbxInts(geometry.child("PolygonVertexIndex").prop(0))
+	if len(positions)%3 != 0 {
+		return fmt.Errorf("%d vertex coordinates are not a multiple of 3", len(positions))
+	}
+	points := len(positions) / 3
+	normals := newFBXLayer(geometry, "LayerElementNormal", "Normals", "NormalsIndex", 3)
+	uvs := newFBXLayer(geometry, "LayerElementUV", "UV", "UVIndex", 2)
+	colors := newFBXLayer(geometry, "LayerElementColor", "Colors", "ColorIndex", 4)
+	if colors != nil && colors.white() {
+		colors = nil
+	}
+	materialLayer := geometry.child("LayerElementMaterial")
+	materialIDs := fbxInts(materialLayer.child("Materials").prop(0))
+	byPolygon := fbxString(materialLayer.child("MappingInformationType").prop(0)) == "ByPolygon"
+
+	mirrored := transform.mirrors()
+	var polygon []int
+	materialIndex := -1
+	polygonIndex := 0
+	for pv, raw := range indices {
+		if len(polygon) == 0 {
+			slot := int64(0)
+			if byPolygon && polygonIndex < len(materialIDs) {
+				slot = materialIDs[polygonIndex]
+			} else if !byPolygon && len(materialIDs) > 0 {
+				slot = materialIDs[0]
+			}
+			materialIndex = -1
+			if slot >= 0 && slot < int64(len(slots)) {
+				materialIndex = slots[slot]
+			}
+		}
+		// The last vertex of each polygon is stored as its bitwise complement
+		point, last := raw, raw < 0
+		if last {
+			point = ^raw
+		}
+		if point >= int64(points) {
+			return fmt.Errorf("polygon vertex %d: control point %d out of range", pv, point)
+		}
+		p := positions[3*point : 3*point+3]
+		vertex := Vertex{Position: transform.transformPoint([3]float64{p[0], p[1], p[2]})}
+		if n, ok := normals.at(pv, int(point), polygonIndex); ok {
+			vertex.Normal = transform.transformNormal([3]float64{n[0], n[1], n[2]})
+		}
+		if uv, ok := uvs.at(pv, int(point), polygonIndex); ok {
+			vertex.TexCoord = [2]float64{uv[0], 1 - uv[1]} // FBX puts V = 0 at the bottom
+		}
+		if c, ok := colors.at(pv, int(point), polygonIndex); ok {
+			vertex.Color = [3]float64{c[0], c[1], c[2]}
+		} else if mesh.HasVertexColors && materialIndex >= 0 {
+			vertex.Color = mesh.Materials[materialIndex].DiffuseColor
+		} else if mesh.HasVertexColors {
+			vertex.Color = [3]float64{0.5, 0.5, 0.5}
+		}
+		polygon = append(polygon, len(mesh.Vertices))
+		mesh.Vertices = append(mesh.Vertices, vertex)
+		if !last {
+			continue
+		}
+
+		// Points and lines have no area to voxelize
+		if len(polygon) >= 3 {
+			for _, tri := range triangulatePolygon(mesh.Vertices, polygon) {
+				if mirrored {
+					tri[1], tri[2] = tri[2], tri[1]
+				}
+				mesh.Faces = append(mesh.Faces, Face{VertexIndices: []int{tri[0], tri[1], tri[2]}, MaterialIndex: materialIndex})
+			}
+		}
+		polygon = polygon[:0]
+		polygonIndex++
+	}
+	if len(polygon) > 0 {
+		return fmt.Errorf("polygon %d is not closed", polygonIndex)
+	}
+	return nil
+}
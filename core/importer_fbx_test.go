@@ -0,0 +1,266 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+)
+
+// fbxTestNode is a node written by writeTestFBX.
+type fbxTestNode struct {
+	name     string
+	props    []interface{}
+	children []fbxTestNode
+}
+
+// writeTestFBX encodes nodes as a binary FBX 7.4 file. Properties are written by
+// Go type: int32 as I, int64 as L, float64 as D, string as S, []byte as R,
+// []float64 as a d array and []int32 as a compressed i array.
+func writeTestFBX(t *testing.T, nodes []fbxTestNode) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString(fbxBinaryMagic + "\x1a\x00")
+	binary.Write(&buf, binary.LittleEndian, uint32(7400))
+	var write func(n fbxTestNode)
+	write = func(n fbxTestNode) {
+		var props bytes.Buffer
+		le := binary.LittleEndian
+		for _, p := range n.props {
+			switch p := p.(type) {
+			case int32:
+				props.WriteByte('I')
+				binary.Write(&props, le, p)
+			case int64:
+				props.WriteByte('L')
+				binary.Write(&props, le, p)
+			case float64:
+				props.WriteByte('D')
+				binary.Write(&props, le, p)
+			case string:
+				props.WriteByte('S')
+				binary.Write(&props, le, uint32(len(p)))
+				props.WriteString(p)
+			case []byte:
+				props.WriteByte('R')
+				binary.Write(&props, le, uint32(len(p)))
+				props.Write(p)
+			case []float64:
+				props.WriteByte('d')
+				binary.Write(&props, le, [3]uint32{uint32(len(p)), 0, uint32(8 * len(p))})
+				binary.Write(&props, le, p)
+			case []int32:
+				var raw bytes.Buffer
+				zw := zlib.NewWriter(&raw)
+				binary.Write(zw, le, p)
+				zw.Close()
+				props.WriteByte('i')
+				binary.Write(&props, le, [3]uint32{uint32(len(p)), 1, uint32(raw.Len())})
+				props.Write(raw.Bytes())
+			default:
+				t.Fatalf("unsupported property %T", p)
+			}
+		}
+		start := buf.Len()
+		binary.Write(&buf, le, [3]uint32{0, uint32(len(n.props)), uint32(props.Len())})
+		buf.WriteByte(byte(len(n.name)))
+		buf.WriteString(n.name)
+		buf.Write(props.Bytes())
+		for _, c := range n.children {
+			write(c)
+		}
+		if len(n.children) > 0 {
+			buf.Write(make([]byte, 13))
+		}
+		le.PutUint32(buf.Bytes()[start:], uint32(buf.Len()))
+	}
+	for _, n := range nodes {
+		write(n)
+	}
+	buf.Write(make([]byte, 13))
+	return buf.Bytes()
+}
+
+// fbxTestProperty returns a Properties70 entry.
+func fbxTestProperty(name string, values ...interface{}) fbxTestNode {
+	return fbxTestNode{name: "P", props: append([]interface{}{name, "", "", "A"}, values...)}
+}
+
+func TestFBXImporterBinary(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	img.Set(1, 0, color.NRGBA{0, 0, 255, 255})
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+
+	// A unit quad, moved by 10 along X and doubled in size
+	data := writeTestFBX(t, []fbxTestNode{
+		{name: "Objects", children: []fbxTestNode{
+			{name: "Geometry", props: []interface{}{int64(1), "Quad\x00\x01Geometry", "Mesh"}, children: []fbxTestNode{
+				{name: "Vertices", props: []interface{}{[]float64{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}}},
+				{name: "PolygonVertexIndex", props: []interface{}{[]int32{0, 1, 2, -4}}},
+				{name: "LayerElementUV", props: []interface{}{int32(0)}, children: []fbxTestNode{
+					{name: "MappingInformationType", props: []interface{}{"ByVertice"}},
+					{name: "ReferenceInformationType", props: []interface{}{"Direct"}},
+					{name: "UV", props: []interface{}{[]float64{0.25, 0, 0.75, 0, 0.75, 1, 0.25, 1}}},
+				}},
+			}},
+			{name: "Model", props: []interface{}{int64(2), "Quad\x00\x01Model", "Mesh"}, children: []fbxTestNode{
+				{name: "Properties70", children: []fbxTestNode{
+					fbxTestProperty("Lcl Translation", 10.0, 0.0, 0.0),
+					fbxTestProperty("Lcl Scaling", 2.0, 2.0, 2.0),
+				}},
+			}},
+			{name: "Material", props: []interface{}{int64(3), "Paint\x00\x01Material", ""}, children: []fbxTestNode{
+				{name: "Properties70", children: []fbxTestNode{
+					fbxTestProperty("DiffuseColor", 0.5, 0.5, 0.5),
+					fbxTestProperty("EmissiveColor", 0.0, 1.0, 0.0),
+				}},
+			}},
+			{name: "Texture", props: []interface{}{int64(4), "Albedo\x00\x01Texture", ""}, children: []fbxTestNode{
+				{name: "RelativeFilename", props: []interface{}{"textures\\albedo.png"}},
+			}},
+			{name: "Video", props: []interface{}{int64(5), "Albedo\x00\x01Video", "Clip"}, children: []fbxTestNode{
+				{name: "Content", props: []interface{}{pngData.Bytes()}},
+			}},
+		}},
+		{name: "Connections", children: []fbxTestNode{
+			{name: "C", props: []interface{}{"OO", int64(2), int64(0)}},
+			{name: "C", props: []interface{}{"OO", int64(1), int64(2)}},
+			{name: "C", props: []interface{}{"OO", int64(3), int64(2)}},
+			{name: "C", props: []interface{}{"OP", int64(4), int64(3), "DiffuseColor"}},
+			{name: "C", props: []interface{}{"OO", int64(5), int64(4)}},
+		}},
+	})
+
+	mesh, err := NewFBXImporter().Import(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(mesh.Vertices) != 4 || len(mesh.Faces) != 2 || len(mesh.Materials) != 1 {
+		t.Fatalf("got %d vertices, %d faces, %d materials", len(mesh.Vertices), len(mesh.Faces), len(mesh.Materials))
+	}
+	if got := mesh.Vertices[2].Position; got != [3]float64{12, 2, 0} {
+		t.Errorf("third vertex at %v, want [12 2 0]", got)
+	}
+	if got := mesh.Vertices[0].TexCoord; got != [2]float64{0.25, 1} {
+		t.Errorf("first texture coordinate = %v, want V flipped", got)
+	}
+	mat := mesh.Materials[0]
+	if mat.Name != "Paint" || mat.Texture == nil || mat.DiffuseColor != [3]float64{1, 1, 1} || mat.EmissiveColor != [3]float64{0, 1, 0} {
+		t.Errorf("material = %+v", mat)
+	}
+	for _, face := range mesh.Faces {
+		if face.MaterialIndex != 0 {
+			t.Errorf("face material %d, want 0", face.MaterialIndex)
+		}
+	}
+
+	var formatErr *FormatError
+	if _, err := NewFBXImporter().Import(bytes.NewReader(data[:len(data)/2])); !errors.As(err, &formatErr) {
+		t.Errorf("truncated file: expected FormatError, got %v", err)
+	}
+}
+
+func TestFBXImporterASCII(t *testing.T) {
+	// A Z-up triangle on a child model rotated 90 degrees about Z
+	input := `; FBX 7.4.0 project file
+GlobalSettings:  {
+	Properties70:  {
+		P: "UpAxis", "int", "Integer", "",2
+		P: "FrontAxis", "int", "Integer", "",1
+		P: "FrontAxisSign", "int", "Integer", "",-1
+	}
+}
+Objects:  {
+	Geometry: 10, "Geometry::Tri", "Mesh" {
+		Vertices: *9 {
+			a: 1,0,0,
+			0,1,0,0,0,1
+		}
+		PolygonVertexIndex: *3 {
+			a: 0,1,-3
+		}
+		LayerElementColor: 0 {
+			MappingInformationType: "ByPolygon"
+			ReferenceInformationType: "IndexToDirect"
+			Colors: *4 {
+				a: 1,0.5,0,1
+			}
+			ColorIndex: *1 {
+				a: 0
+			}
+		}
+	}
+	Model: 20, "Model::Parent", "Null" {
+		Properties70:  {
+			P: "Lcl Rotation", "Lcl Rotation", "", "A",0,0,90
+		}
+	}
+	Model: 21, "Model::Tri", "Mesh" {
+	}
+}
+Connections:  {
+	C: "OO",20,0
+	C: "OO",21,20
+	C: "OO",10,21
+}
+`
+	mesh, err := NewFBXImporter().Import(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(mesh.Vertices) != 3 || len(mesh.Faces) != 1 || !mesh.HasVertexColors {
+		t.Fatalf("got %d vertices, %d faces, colors %v", len(mesh.Vertices), len(mesh.Faces), mesh.HasVertexColors)
+	}
+	// (1,0,0) turns to (0,1,0) about Z, which is (0,0,-1) once Y is up
+	want := [][3]float64{{0, 0, -1}, {-1, 0, 0}, {0, 1, 0}}
+	for i, w := range want {
+		p := mesh.Vertices[i].Position
+		for a := range p {
+			if math.Abs(p[a]-w[a]) > 1e-9 {
+				t.Errorf("vertex %d at %v, want %v", i, p, w)
+				break
+			}
+		}
+	}
+	if got := mesh.Vertices[1].Color; got != [3]float64{1, 0.5, 0} {
+		t.Errorf("vertex color = %v", got)
+	}
+
+	// A lone point has no area; it is skipped rather than triangulated
+	degenerate := `Objects:  {
+	Geometry: 10, "Geometry::Point", "Mesh" {
+		Vertices: *9 {
+			a: 1,0,0,0,1,0,0,0,1
+		}
+		PolygonVertexIndex: *4 {
+			a: -1,0,1,-3
+		}
+	}
+	Model: 20, "Model::Point", "Mesh" {
+	}
+}
+Connections:  {
+	C: "OO",10,20
+}
+`
+	if mesh, err = NewFBXImporter().Import(strings.NewReader(degenerate)); err != nil {
+		t.Fatalf("import with a degenerate polygon: %v", err)
+	}
+	if len(mesh.Faces) != 1 {
+		t.Errorf("got %d faces with a degenerate polygon, want 1", len(mesh.Faces))
+	}
+
+	if _, err := NewFBXImporter().Import(strings.NewReader("Objects: {\n")); err == nil {
+		t.Error("expected an error for an unclosed node")
+	}
+}
@@ -1,28 +1,61 @@
 package core
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/jpeg" // Decoders for embedded textures
+	_ "image/png"
 	"io"
+	"math"
 
 	"github.com/qmuntal/gltf"
 	"github.com/qmuntal/gltf/modeler"
 )
 
-// GLTFImporter implements MeshImporter for glTF format.
-type GLTFImporter struct{}
+// GLTFImporter implements MeshImporter for glTF format, including VRM avatars,
+// which are GLB files. Skinned meshes are baked in the pose of their joint
+// nodes by linear blend skinning. COLOR_0 vertex colors, times the material's
+// base color, are kept on the vertices.
+type GLTFImporter struct {
+	// Animate poses the scene with animation Animation sampled at
+	// AnimationTime seconds instead of the nodes' rest transforms.
+	Animate       bool
+	Animation     int
+	AnimationTime float64
+}
 
 // NewGLTFImporter creates a new glTF importer.
 func NewGLTFImporter() *GLTFImporter {
 	return &GLTFImporter{}
 }
 
+// SetAnimation poses imported scenes with the given animation at seconds into
+// it; a negative index keeps the rest pose.
+func (imp *GLTFImporter) SetAnimation(animation int, seconds float64) {
+	imp.Animate = animation >= 0
+	imp.Animation, imp.AnimationTime = animation, seconds
+}
+
 // Import reads and parses a glTF mesh from the given reader.
 func (imp *GLTFImporter) Import(r io.Reader) (*Mesh, error) {
+	return imp.ImportCtx(context.Background(), r)
+}
+
+// ImportCtx is like Import but stops early when ctx is done.
+func (imp *GLTFImporter) ImportCtx(ctx context.Context, r io.Reader) (*Mesh, error) {
 	// Parse glTF
 	doc := gltf.NewDocument()
-	decoder := gltf.NewDecoder(r)
+	counter := &countingReader{r: r}
+	decoder := gltf.NewDecoder(&contextReader{ctx: ctx, r: counter})
 	if err := decoder.Decode(doc); err != nil {
-		return nil, fmt.Errorf("failed to parse glTF: %w", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, &FormatError{Format: "gltf", Offset: counter.n, Err: err}
 	}
 	
 	mesh := &Mesh{
@@ -31,31 +64,95 @@ func (imp *GLTFImporter) Import(r io.Reader) (*Mesh, error) {
 		Materials: []Material{},
 	}
 	
-	// Extract materials
-	for _, mat := range doc.Materials {
+	// Extract materials; images shared by several materials are decoded once
+	images := make(map[int]image.Image)
+	for i, mat := range doc.Materials {
 		material := Material{
-			Name: mat.Name,
+			Name:          mat.Name,
+			DiffuseColor:  [3]float64{1, 1, 1}, // glTF's default base color factor
+			EmissiveColor: mat.EmissiveFactor,
+			Opacity:       1,
 		}
 		
 		if mat.PBRMetallicRoughness != nil {
 			pbr := mat.PBRMetallicRoughness
-			if len(pbr.BaseColorFactor) >= 3 {
+			if pbr.BaseColorFactor != nil {
 				material.DiffuseColor = [3]float64{
 					float64(pbr.BaseColorFactor[0]),
 					float64(pbr.BaseColorFactor[1]),
 					float64(pbr.BaseColorFactor[2]),
 				}
+				if mat.AlphaMode == gltf.AlphaBlend {
+					material.Opacity = pbr.BaseColorFactor[3]
+				}
+			}
+			// A metallic-roughness texture varies the factors across the
+			// surface; without one they hold everywhere
+			if pbr.MetallicRoughnessTexture == nil {
+				material.Metallic = pbr.MetallicFactorOrDefault()
+				material.Roughness = pbr.RoughnessFactorOrDefault()
+			}
+			if pbr.BaseColorTexture != nil {
+				img, uri, err := imp.loadTexture(doc, pbr.BaseColorTexture.Index, images)
+				if err != nil {
+					return nil, &FormatError{Format: "gltf", Offset: -1, Msg: fmt.Sprintf("material %d base color texture", i), Err: err}
+				}
+				material.Texture, material.TexturePath = img, uri
+			}
+		}
+		if mat.EmissiveTexture != nil {
+			img, _, err := imp.loadTexture(doc, mat.EmissiveTexture.Index, images)
+			if err != nil {
+				return nil, &FormatError{Format: "gltf", Offset: -1, Msg: fmt.Sprintf("material %d emissive texture", i), Err: err}
 			}
+			material.EmissiveTexture = img
 		}
+		var strength gltfEmissiveStrength
+		if err := gltfExtension(mat.Extensions, "KHR_materials_emissive_strength", &strength); err != nil {
+			return nil, &FormatError{Format: "gltf", Offset: -1, Msg: fmt.Sprintf("material %d emissive strength", i), Err: err}
+		}
+		if strength.EmissiveStrength != nil {
+			for c := range material.EmissiveColor {
+				material.EmissiveColor[c] *= *strength.EmissiveStrength
+			}
+		}
+		var transmission gltfTransmission
+		if err := gltfExtension(mat.Extensions, "KHR_materials_transmission", &transmission); err != nil {
+			return nil, &FormatError{Format: "gltf", Offset: -1, Msg: fmt.Sprintf("material %d transmission", i), Err: err}
+		}
+		material.Transmission = math.Max(0, math.Min(1, transmission.TransmissionFactor))
 		
 		mesh.Materials = append(mesh.Materials, material)
 	}
 	
-	// Extract geometry from all meshes
-	for _, gltfMesh := range doc.Meshes {
-		for _, primitive := range gltfMesh.Primitives {
-			if err := imp.extractPrimitive(doc, primitive, mesh); err != nil {
-				return nil, fmt.Errorf("failed to extract primitive: %w", err)
+	// Extract geometry from every mesh instance in the scene, or from every mesh
+	// as it is when the file has no scenes
+	locals := gltfNodeLocals(doc)
+	if imp.Animate {
+		var err error
+		if locals, err = gltfAnimatedLocals(doc, imp.Animation, imp.AnimationTime); err != nil {
+			return nil, &FormatError{Format: "gltf", Offset: -1, Msg: "invalid animation", Err: err}
+		}
+	}
+	instances, err := gltfMeshInstances(doc, locals)
+	if err != nil {
+		return nil, &FormatError{Format: "gltf", Offset: -1, Msg: "invalid scene graph", Err: err}
+	}
+	for _, instance := range instances {
+		for _, primitive := range doc.Meshes[instance.mesh].Primitives {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			first := len(mesh.Faces)
+			if err := imp.extractPrimitive(doc, primitive, instance, mesh); err != nil {
+				return nil, &FormatError{Format: "gltf", Offset: -1, Msg: "failed to extract primitive", Err: err}
+			}
+			group := -1
+			if instance.name != "" {
+				group = mesh.groupIndex(instance.name)
+			}
+			for i := first; i < len(mesh.Faces); i++ {
+				mesh.Faces[i].Group = group
 			}
 		}
 	}
@@ -64,8 +161,362 @@ func (imp *GLTFImporter) Import(r io.Reader) (*Mesh, error) {
 	return mesh, nil
 }
 
-// extractPrimitive extracts geometry from a glTF primitive.
-func (imp *GLTFImporter) extractPrimitive(doc *gltf.Document, primitive *gltf.Primitive, mesh *Mesh) error {
+// gltfInstance is a mesh placed in the scene by a node.
+type gltfInstance struct {
+	mesh      int
+	name      string // The node's name, or the mesh's when it has none
+	transform gltfMatrix
+	
+	// joints holds the skinning matrices of a skinned mesh, each joint's world
+	// transform times its inverse bind matrix. Skinned meshes are placed by their
+	// joints alone, so transform is then the identity.
+	joints []gltfMatrix
+}
+
+// gltfMeshInstances walks the default scene (or the first one) and returns each
+// node's mesh with the world transform built from the nodes' local transforms. A mesh referenced by several nodes
+// is returned once per node. Without a scene placing any mesh, every mesh is
+// returned untransformed.
+func gltfMeshInstances(doc *gltf.Document, locals []gltfMatrix) ([]gltfInstance, error) {
+	instances, err := gltfSceneInstances(doc, locals)
+	if err != nil || len(instances) > 0 {
+		return instances, err
+	}
+	for i := range doc.Meshes {
+		instances = append(instances, gltfInstance{mesh: i, name: doc.Meshes[i].Name, transform: identityGLTFMatrix})
+	}
+	return instances, nil
+}
+
+// gltfSceneInstances returns the mesh instances of the document's scene graph.
+func gltfSceneInstances(doc *gltf.Document, locals []gltfMatrix) ([]gltfInstance, error) {
+	if len(doc.Scenes) == 0 {
+		return nil, nil
+	}
+	
+	sceneIndex := 0
+	if doc.Scene != nil {
+		sceneIndex = *doc.Scene
+	}
+	if sceneIndex < 0 || sceneIndex >= len(doc.Scenes) {
+		return nil, fmt.Errorf("scene index %d out of range", sceneIndex)
+	}
+	
+	worlds, err := gltfNodeWorlds(doc, locals)
+	if err != nil {
+		return nil, err
+	}
+	
+	var instances []gltfInstance
+	var visit func(node int, depth int) error
+	visit = func(node int, depth int) error {
+		if node < 0 || node >= len(doc.Nodes) {
+			return fmt.Errorf("node index %d out of range", node)
+		}
+		if depth > len(doc.Nodes) {
+			return fmt.Errorf("node %d is its own ancestor", node)
+		}
+		n := doc.Nodes[node]
+		if n.Mesh != nil {
+			if *n.Mesh < 0 || *n.Mesh >= len(doc.Meshes) {
+				return fmt.Errorf("node %d: mesh index %d out of range", node, *n.Mesh)
+			}
+			instance := gltfInstance{mesh: *n.Mesh, name: n.Name, transform: worlds[node]}
+			if instance.name == "" {
+				instance.name = doc.Meshes[*n.Mesh].Name
+			}
+			if n.Skin != nil {
+				joints, err := gltfJointMatrices(doc, *n.Skin, worlds)
+				if err != nil {
+					return fmt.Errorf("node %d: %w", node, err)
+				}
+				instance.transform, instance.joints = identityGLTFMatrix, joints
+			}
+			instances = append(instances, instance)
+		}
+		for _, child := range n.Children {
+			if err := visit(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, root := range doc.Scenes[sceneIndex].Nodes {
+		if err := visit(root, 0); err != nil {
+			return nil, err
+		}
+	}
+	return instances, nil
+}
+
+// gltfNodeWorlds returns the world transform of every node, scene or not, as
+// skins may use joints outside the scene.
+func gltfNodeWorlds(doc *gltf.Document, locals []gltfMatrix) ([]gltfMatrix, error) {
+	parents := make([]int, len(doc.Nodes))
+	for i := range parents {
+		parents[i] = -1
+	}
+	for i, n := range doc.Nodes {
+		for _, child := range n.Children {
+			if child < 0 || child >= len(doc.Nodes) {
+				return nil, fmt.Errorf("node %d: child index %d out of range", i, child)
+			}
+			if parents[child] >= 0 {
+				return nil, fmt.Errorf("node %d has several parents", child)
+			}
+			parents[child] = i
+		}
+	}
+	
+	worlds := make([]gltfMatrix, len(doc.Nodes))
+	done := make([]bool, len(doc.Nodes))
+	var world func(node, depth int) error
+	world = func(node, depth int) error {
+		if done[node] {
+			return nil
+		}
+		// Nodes form a forest, so a path longer than the node count has a cycle
+		if depth > len(doc.Nodes) {
+			return fmt.Errorf("node %d is its own ancestor", node)
+		}
+		worlds[node] = locals[node]
+		if parent := parents[node]; parent >= 0 {
+			if err := world(parent, depth+1); err != nil {
+				return err
+			}
+			worlds[node] = worlds[parent].mul(worlds[node])
+		}
+		done[node] = true
+		return nil
+	}
+	for i := range doc.Nodes {
+		if err := world(i, 0); err != nil {
+			return nil, err
+		}
+	}
+	return worlds, nil
+}
+
+// gltfJointMatrices returns a skin's skinning matrices, which map the mesh's
+// bind pose to the pose of the joint nodes.
+func gltfJointMatrices(doc *gltf.Document, skinIndex int, worlds []gltfMatrix) ([]gltfMatrix, error) {
+	if skinIndex < 0 || skinIndex >= len(doc.Skins) {
+		return nil, fmt.Errorf("skin index %d out of range", skinIndex)
+	}
+	skin := doc.Skins[skinIndex]
+	var inverseBinds [][4][4]float32
+	if skin.InverseBindMatrices != nil {
+		accessor, err := gltfAccessor(doc, *skin.InverseBindMatrices)
+		if err != nil {
+			return nil, fmt.Errorf("skin %d: %w", skinIndex, err)
+		}
+		if inverseBinds, err = modeler.ReadInverseBindMatrices(doc, accessor, nil); err != nil {
+			return nil, fmt.Errorf("skin %d: failed to read inverse bind matrices: %w", skinIndex, err)
+		}
+		if len(inverseBinds) < len(skin.Joints) {
+			return nil, fmt.Errorf("skin %d: %d inverse bind matrices for %d joints", skinIndex, len(inverseBinds), len(skin.Joints))
+		}
+	}
+	
+	joints := make([]gltfMatrix, len(skin.Joints))
+	for j, node := range skin.Joints {
+		if node < 0 || node >= len(worlds) {
+			return nil, fmt.Errorf("skin %d: joint node %d out of range", skinIndex, node)
+		}
+		inverseBind := identityGLTFMatrix
+		if inverseBinds != nil {
+			for c := 0; c < 4; c++ {
+				for r := 0; r < 4; r++ {
+					inverseBind[c*4+r] = float64(inverseBinds[j][c][r])
+				}
+			}
+		}
+		joints[j] = worlds[node].mul(inverseBind)
+	}
+	return joints, nil
+}
+
+// gltfAccessor returns an accessor by index.
+func gltfAccessor(doc *gltf.Document, index int) (*gltf.Accessor, error) {
+	if index < 0 || index >= len(doc.Accessors) {
+		return nil, fmt.Errorf("accessor index %d out of range", index)
+	}
+	return doc.Accessors[index], nil
+}
+
+// gltfMatrix is a 4x4 affine transform in glTF's column-major order.
+type gltfMatrix [16]float64
+
+var identityGLTFMatrix = gltfMatrix(gltf.DefaultMatrix)
+
+// nodeMatrix returns a node's local transform, given either as a matrix or as
+// translation, rotation and scale.
+func nodeMatrix(n *gltf.Node) gltfMatrix {
+	if m := n.MatrixOrDefault(); m != gltf.DefaultMatrix {
+		return gltfMatrix(m)
+	}
+	return trsMatrix(n.TranslationOrDefault(), n.RotationOrDefault(), n.ScaleOrDefault())
+}
+
+// trsMatrix returns the transform scaling by s, rotating by quaternion q and
+// then translating by t.
+func trsMatrix(t [3]float64, q [4]float64, s [3]float64) gltfMatrix {
+	x, y, z, w := q[0], q[1], q[2], q[3]
+	return gltfMatrix{
+		(1 - 2*(y*y+z*z)) * s[0], 2 * (x*y + z*w) * s[0], 2 * (x*z - y*w) * s[0], 0,
+		2 * (x*y - z*w) * s[1], (1 - 2*(x*x+z*z)) * s[1], 2 * (y*z + x*w) * s[1], 0,
+		2 * (x*z + y*w) * s[2], 2 * (y*z - x*w) * s[2], (1 - 2*(x*x+y*y)) * s[2], 0,
+		t[0], t[1], t[2], 1,
+	}
+}
+
+// mul returns m*n, the transform applying n first.
+func (m gltfMatrix) mul(n gltfMatrix) gltfMatrix {
+	var out gltfMatrix
+	for col := 0; col < 4; col++ {
+		for row := 0; row < 4; row++ {
+			for k := 0; k < 4; k++ {
+				out[col*4+row] += m[k*4+row] * n[col*4+k]
+			}
+		}
+	}
+	return out
+}
+
+func (m gltfMatrix) transformPoint(p [3]float64) [3]float64 {
+	return [3]float64{
+		m[0]*p[0] + m[4]*p[1] + m[8]*p[2] + m[12],
+		m[1]*p[0] + m[5]*p[1] + m[9]*p[2] + m[13],
+		m[2]*p[0] + m[6]*p[1] + m[10]*p[2] + m[14],
+	}
+}
+
+// transformNormal transforms a normal by the inverse transpose of m's linear part,
+// computed as its cofactor matrix, and renormalizes it.
+func (m gltfMatrix) transformNormal(n [3]float64) [3]float64 {
+	c0 := cross3([3]float64{m[4], m[5], m[6]}, [3]float64{m[8], m[9], m[10]})
+	c1 := cross3([3]float64{m[8], m[9], m[10]}, [3]float64{m[0], m[1], m[2]})
+	c2 := cross3([3]float64{m[0], m[1], m[2]}, [3]float64{m[4], m[5], m[6]})
+	out := [3]float64{
+		c0[0]*n[0] + c1[0]*n[1] + c2[0]*n[2],
+		c0[1]*n[0] + c1[1]*n[1] + c2[1]*n[2],
+		c0[2]*n[0] + c1[2]*n[1] + c2[2]*n[2],
+	}
+	if m.mirrors() {
+		out = [3]float64{-out[0], -out[1], -out[2]}
+	}
+	length := math.Sqrt(dot3(out, out))
+	if length == 0 {
+		return n
+	}
+	return [3]float64{out[0] / length, out[1] / length, out[2] / length}
+}
+
+// mirrors reports whether m flips handedness, which reverses triangle winding.
+func (m gltfMatrix) mirrors() bool {
+	return dot3([3]float64{m[0], m[1], m[2]}, cross3([3]float64{m[4], m[5], m[6]}, [3]float64{m[8], m[9], m[10]})) < 0
+}
+
+// maxTexturePixels bounds the size of a decoded texture; larger textures are left
+// undecoded and their materials use the base color factor alone.
+const maxTexturePixels = 1 << 26
+
+// loadTexture returns the image of the given texture, decoded from a buffer view
+// or a data URI. For an image stored in an external file it returns only the
+// file's URI, and for images in formats other than PNG and JPEG nothing.
+func (imp *GLTFImporter) loadTexture(doc *gltf.Document, textureIndex int, images map[int]image.Image) (image.Image, string, error) {
+	if textureIndex < 0 || textureIndex >= len(doc.Textures) {
+		return nil, "", fmt.Errorf("texture index %d out of range", textureIndex)
+	}
+	source := doc.Textures[textureIndex].Source
+	if source == nil {
+		return nil, "", nil // Only provided by an extension such as KHR_texture_basisu
+	}
+	if *source < 0 || *source >= len(doc.Images) {
+		return nil, "", fmt.Errorf("image index %d out of range", *source)
+	}
+	if img, ok := images[*source]; ok {
+		return img, "", nil
+	}
+	
+	gltfImage := doc.Images[*source]
+	var data []byte
+	var err error
+	switch {
+	case gltfImage.BufferView != nil:
+		if *gltfImage.BufferView < 0 || *gltfImage.BufferView >= len(doc.BufferViews) {
+			return nil, "", fmt.Errorf("image %d: buffer view index %d out of range", *source, *gltfImage.BufferView)
+		}
+		data, err = modeler.ReadBufferView(doc, doc.BufferViews[*gltfImage.BufferView])
+	case gltfImage.IsEmbeddedResource():
+		data, err = gltfImage.MarshalData()
+	default:
+		return nil, gltfImage.URI, nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("image %d: %w", *source, err)
+	}
+	
+	img, err := decodeTexture(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("image %d: %w", *source, err)
+	}
+	if img != nil {
+		images[*source] = img
+	}
+	return img, "", nil
+}
+
+// decodeTexture decodes a PNG or JPEG texture. It returns a nil image for other
+// formats and for textures over maxTexturePixels.
+func decodeTexture(data []byte) (image.Image, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) || (err == nil && format != "png" && format != "jpeg") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > maxTexturePixels {
+		return nil, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// gltfExtension decodes the named extension of exts into v, leaving v alone when
+// it is absent. Extensions the gltf package does not know stay raw JSON.
+func gltfExtension(exts gltf.Extensions, name string, v any) error {
+	ext, ok := exts[name]
+	if !ok {
+		return nil
+	}
+	data, ok := ext.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(ext); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, v)
+}
+
+// gltfEmissiveStrength is the KHR_materials_emissive_strength extension, which
+// scales the emissive factor past 1 for physically based lighting.
+type gltfEmissiveStrength struct {
+	EmissiveStrength *float64 `json:"emissiveStrength"`
+}
+
+// gltfTransmission is the KHR_materials_transmission extension, which makes a
+// surface pass light through, as glass does.
+type gltfTransmission struct {
+	TransmissionFactor float64 `json:"transmissionFactor"`
+}
+
+// extractPrimitive extracts geometry from a glTF primitive, placed by the
+// instance's transform or, for skinned meshes, posed by its joints.
+func (imp *GLTFImporter) extractPrimitive(doc *gltf.Document, primitive *gltf.Primitive, instance gltfInstance, mesh *Mesh) error {
+	transform := instance.transform
 	// Get position accessor
 	posAccessor, ok := primitive.Attributes[gltf.POSITION]
 	if !ok {
@@ -95,25 +546,78 @@ func (imp *GLTFImporter) extractPrimitive(doc *gltf.Document, primitive *gltf.Pr
 		}
 	}
 	
+	// Read vertex colors if available. Exporters often write plain white ones,
+	// which would hide textures, so those are ignored.
+	var colors [][4]uint16
+	if colorAccessor, ok := primitive.Attributes[gltf.COLOR_0]; ok {
+		colors, err = modeler.ReadColor64(doc, doc.Accessors[colorAccessor], nil)
+		if err != nil {
+			return fmt.Errorf("failed to read vertex colors: %w", err)
+		}
+		white := true
+		for _, c := range colors {
+			if c[0] != 0xffff || c[1] != 0xffff || c[2] != 0xffff {
+				white = false
+				break
+			}
+		}
+		if white {
+			colors = nil
+		} else {
+			mesh.HasVertexColors = true
+		}
+	}
+	
+	// COLOR_0 multiplies the base color, white without a material. Vertices
+	// without colors take the color faces without vertex colors get, so such
+	// primitives keep it in a vertex-colored mesh.
+	baseColor, plainColor := [3]float64{1, 1, 1}, [3]float64{0.5, 0.5, 0.5}
+	if primitive.Material != nil && *primitive.Material >= 0 && *primitive.Material < len(mesh.Materials) {
+		baseColor = mesh.Materials[*primitive.Material].DiffuseColor
+		plainColor = baseColor
+	}
+	
+	// Read the joint influences of skinned meshes
+	var skin *gltfSkinWeights
+	if instance.joints != nil {
+		if skin, err = readSkinWeights(doc, primitive, len(positions)); err != nil {
+			return err
+		}
+	}
+	
 	// Add vertices
 	vertexOffset := len(mesh.Vertices)
 	for i, pos := range positions {
+		m := transform
+		if skin != nil {
+			if m, err = skin.matrix(i, instance.joints); err != nil {
+				return err
+			}
+		}
 		vertex := Vertex{
-			Position: [3]float64{float64(pos[0]), float64(pos[1]), float64(pos[2])},
+			Position: m.transformPoint([3]float64{float64(pos[0]), float64(pos[1]), float64(pos[2])}),
+			Color:    plainColor,
 		}
 		
 		if i < len(normals) {
-			vertex.Normal = [3]float64{float64(normals[i][0]), float64(normals[i][1]), float64(normals[i][2])}
+			vertex.Normal = m.transformNormal([3]float64{float64(normals[i][0]), float64(normals[i][1]), float64(normals[i][2])})
 		}
 		
 		if i < len(texCoords) {
 			vertex.TexCoord = [2]float64{float64(texCoords[i][0]), float64(texCoords[i][1])}
 		}
 		
+		if i < len(colors) {
+			for c := range vertex.Color {
+				vertex.Color[c] = baseColor[c] * float64(colors[i][c]) / 0xffff
+			}
+		}
+		
 		mesh.Vertices = append(mesh.Vertices, vertex)
 	}
 	
 	// Read indices
+	firstFace := len(mesh.Faces)
 	if primitive.Indices != nil {
 		indices, err := modeler.ReadIndices(doc, doc.Accessors[*primitive.Indices], nil)
 		if err != nil {
@@ -159,10 +663,90 @@ func (imp *GLTFImporter) extractPrimitive(doc *gltf.Document, primitive *gltf.Pr
 		}
 	}
 	
+	// A mirroring transform reverses the winding; swap it back so faces keep facing out
+	if transform.mirrors() {
+		for _, face := range mesh.Faces[firstFace:] {
+			face.VertexIndices[1], face.VertexIndices[2] = face.VertexIndices[2], face.VertexIndices[1]
+		}
+	}
+	
 	return nil
 }
 
+// gltfSkinWeights holds the joint influences of a skinned primitive's vertices,
+// from its JOINTS_n and WEIGHTS_n attribute sets.
+type gltfSkinWeights struct {
+	joints  [][][4]uint16 // Per set, per vertex
+	weights [][][4]float32
+}
+
+// readSkinWeights reads a skinned primitive's joint influences.
+func readSkinWeights(doc *gltf.Document, primitive *gltf.Primitive, count int) (*gltfSkinWeights, error) {
+	skin := &gltfSkinWeights{}
+	for set := 0; ; set++ {
+		jointsIndex, okJoints := primitive.Attributes[fmt.Sprintf("JOINTS_%d", set)]
+		weightsIndex, okWeights := primitive.Attributes[fmt.Sprintf("WEIGHTS_%d", set)]
+		if !okJoints || !okWeights {
+			break
+		}
+		jointsAccessor, err := gltfAccessor(doc, jointsIndex)
+		if err != nil {
+			return nil, err
+		}
+		weightsAccessor, err := gltfAccessor(doc, weightsIndex)
+		if err != nil {
+			return nil, err
+		}
+		joints, err := modeler.ReadJoints(doc, jointsAccessor, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read joints: %w", err)
+		}
+		weights, err := modeler.ReadWeights(doc, weightsAccessor, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read weights: %w", err)
+		}
+		if len(joints) < count || len(weights) < count {
+			return nil, fmt.Errorf("skin attribute set %d has fewer entries than the %d vertices", set, count)
+		}
+		skin.joints = append(skin.joints, joints)
+		skin.weights = append(skin.weights, weights)
+	}
+	if len(skin.joints) == 0 {
+		return nil, fmt.Errorf("skinned primitive missing JOINTS_0 or WEIGHTS_0 attribute")
+	}
+	return skin, nil
+}
+
+// matrix blends the skinning matrices of a vertex's joints by their weights,
+// normalized to sum to one.
+func (s *gltfSkinWeights) matrix(vertex int, joints []gltfMatrix) (gltfMatrix, error) {
+	var m gltfMatrix
+	total := 0.0
+	for set := range s.joints {
+		for k, joint := range s.joints[set][vertex] {
+			weight := float64(s.weights[set][vertex][k])
+			if weight <= 0 {
+				continue
+			}
+			if int(joint) >= len(joints) {
+				return m, fmt.Errorf("vertex %d: joint %d out of range", vertex, joint)
+			}
+			for e := range m {
+				m[e] += weight * joints[joint][e]
+			}
+			total += weight
+		}
+	}
+	if total == 0 {
+		return identityGLTFMatrix, nil // Unweighted vertices stay in the bind pose
+	}
+	for e := range m {
+		m[e] /= total
+	}
+	return m, nil
+}
+
 // SupportedFormats returns the list of supported file extensions.
 func (imp *GLTFImporter) SupportedFormats() []string {
-	return []string{".gltf", ".glb"}
+	return []string{".gltf", ".glb", ".vrm"}
 }
@@ -0,0 +1,390 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// texturedGLB returns a GLB holding one triangle whose material embeds a 2x1 PNG,
+// red on the left and blue on the right, tinted by factor and then changed by
+// edits.
+func texturedGLB(t *testing.T, factor *[4]float64, edits ...func(*gltf.Material)) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	img.Set(1, 0, color.NRGBA{0, 0, 255, 255})
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := gltf.NewDocument()
+	imageIndex, err := modeler.WriteImage(doc, "albedo", "image/png", &pngData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Textures = []*gltf.Texture{{Source: gltf.Index(imageIndex)}}
+	doc.Materials = []*gltf.Material{{
+		Name: "textured",
+		PBRMetallicRoughness: &gltf.PBRMetallicRoughness{
+			BaseColorFactor:  factor,
+			BaseColorTexture: &gltf.TextureInfo{Index: 0},
+		},
+	}}
+	doc.Meshes = []*gltf.Mesh{{Primitives: []*gltf.Primitive{{
+		Attributes: gltf.PrimitiveAttributes{
+			gltf.POSITION:   modeler.WritePosition(doc, [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}),
+			gltf.TEXCOORD_0: modeler.WriteTextureCoord(doc, [][2]float32{{0.1, 0.5}, {0.9, 0.5}, {0.1, 0.5}}),
+		},
+		Indices:  gltf.Index(modeler.WriteIndices(doc, []uint16{0, 1, 2})),
+		Material: gltf.Index(0),
+	}}}}
+	doc.Nodes = []*gltf.Node{{Mesh: gltf.Index(0)}}
+	doc.Scenes[0].Nodes = []int{0}
+	for _, edit := range edits {
+		edit(doc.Materials[0])
+	}
+
+	var buf bytes.Buffer
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGLTFEmbeddedTexture(t *testing.T) {
+	mesh, err := NewGLTFImporter().Import(bytes.NewReader(texturedGLB(t, &[4]float64{0.5, 1, 1, 1})))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(mesh.Materials) != 1 || mesh.Materials[0].Texture == nil {
+		t.Fatalf("expected one textured material, got %+v", mesh.Materials)
+	}
+	if b := mesh.Materials[0].Texture.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Errorf("texture size = %v, want 2x1", b)
+	}
+
+	shading := newFaceShading(mesh, mesh.Faces[0])
+	if c := shading.colorAt([3]float64{1, 0, 0}); c != [3]uint8{128, 0, 0} {
+		t.Errorf("left texel = %v, want red halved by the base color factor", c)
+	}
+	if c := shading.colorAt([3]float64{0, 1, 0}); c != [3]uint8{0, 0, 255} {
+		t.Errorf("right texel = %v, want blue", c)
+	}
+}
+
+func TestGLTFDefaultBaseColor(t *testing.T) {
+	mesh, err := NewGLTFImporter().Import(bytes.NewReader(texturedGLB(t, nil)))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if got := mesh.Materials[0].DiffuseColor; got != [3]float64{1, 1, 1} {
+		t.Errorf("diffuse color = %v, want the default white factor", got)
+	}
+}
+
+func TestGLTFEmissiveTransmission(t *testing.T) {
+	glb := texturedGLB(t, nil, func(mat *gltf.Material) {
+		mat.EmissiveFactor = [3]float64{0.25, 0, 0}
+		mat.EmissiveTexture = &gltf.TextureInfo{Index: 0}
+		mat.Extensions = gltf.Extensions{
+			"KHR_materials_emissive_strength": map[string]float64{"emissiveStrength": 2},
+			"KHR_materials_transmission":      map[string]float64{"transmissionFactor": 0.5},
+		}
+	})
+	mesh, err := NewGLTFImporter().Import(bytes.NewReader(glb))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	mat := &mesh.Materials[0]
+	if mat.EmissiveColor != [3]float64{0.5, 0, 0} || mat.EmissiveTexture == nil || mat.Transmission != 0.5 {
+		t.Fatalf("emissive %v, texture %v, transmission %v", mat.EmissiveColor, mat.EmissiveTexture != nil, mat.Transmission)
+	}
+	if m := voxelMaterialOf(mat); m == nil || m.Opacity != 0.5 {
+		t.Errorf("voxel material = %+v, want opacity 0.5", m)
+	}
+
+	// The red texel emits through the red emissive factor, the blue one not at all
+	face := mesh.Faces[0]
+	a, b, c := mesh.Vertices[face.VertexIndices[0]].Position, mesh.Vertices[face.VertexIndices[1]].Position, mesh.Vertices[face.VertexIndices[2]].Position
+	shading := newFaceShading(mesh, face)
+	if m := shading.materialAt(a, a, b, c); m == nil || m.Emission != 0.5 {
+		t.Errorf("left material = %+v, want emission 0.5", m)
+	}
+	if m := shading.materialAt(b, a, b, c); m == nil || m.Emissive() || !m.Translucent() {
+		t.Errorf("right material = %+v, want translucent without emission", m)
+	}
+}
+
+func TestSampleTextureWraps(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(1, 1, color.NRGBA{0, 255, 0, 255})
+	for _, uv := range [][2]float64{{0.75, 0.75}, {1.75, -0.25}, {-1.25, 2.75}} {
+		if c := sampleTexture(img, uv); c != [3]uint8{0, 255, 0} {
+			t.Errorf("sample at %v = %v, want green", uv, c)
+		}
+	}
+}
+
+// sceneGLB returns a GLB with a single-triangle mesh and the given nodes, all of
+// them roots of the default scene unless they are another node's child.
+func sceneGLB(t *testing.T, nodes []*gltf.Node) []byte {
+	t.Helper()
+	doc := gltf.NewDocument()
+	doc.Meshes = []*gltf.Mesh{{Primitives: []*gltf.Primitive{{
+		Attributes: gltf.PrimitiveAttributes{
+			gltf.POSITION: modeler.WritePosition(doc, [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}),
+			gltf.NORMAL:   modeler.WriteNormal(doc, [][3]float32{{0, 0, 1}, {0, 0, 1}, {0, 0, 1}}),
+		},
+	}}}}
+	doc.Nodes = nodes
+	isChild := make(map[int]bool)
+	for _, n := range nodes {
+		for _, c := range n.Children {
+			isChild[c] = true
+		}
+	}
+	for i := range nodes {
+		if !isChild[i] {
+			doc.Scenes[0].Nodes = append(doc.Scenes[0].Nodes, i)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func assertNear(t *testing.T, what string, got, want [3]float64) {
+	t.Helper()
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Errorf("%s = %v, want %v", what, got, want)
+			return
+		}
+	}
+}
+
+func TestGLTFNodeTransforms(t *testing.T) {
+	// Node 0 moves its child 10 along x; node 1 turns the mesh 90 degrees about z
+	// and doubles it. Node 2 instances the same mesh again through a matrix.
+	s := math.Sqrt(0.5)
+	nodes := []*gltf.Node{
+		{Translation: [3]float64{10, 0, 0}, Children: []int{1}},
+		{Mesh: gltf.Index(0), Rotation: [4]float64{0, 0, s, s}, Scale: [3]float64{2, 2, 2}},
+		{Mesh: gltf.Index(0), Matrix: [16]float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 5, 1}},
+	}
+	mesh, err := NewGLTFImporter().Import(bytes.NewReader(sceneGLB(t, nodes)))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(mesh.Vertices) != 6 || len(mesh.Faces) != 2 {
+		t.Fatalf("got %d vertices and %d faces, want 6 and 2", len(mesh.Vertices), len(mesh.Faces))
+	}
+
+	assertNear(t, "rotated vertex 1", mesh.Vertices[1].Position, [3]float64{10, 2, 0})
+	assertNear(t, "rotated vertex 2", mesh.Vertices[2].Position, [3]float64{8, 0, 0})
+	assertNear(t, "rotated normal", mesh.Vertices[1].Normal, [3]float64{0, 0, 1})
+	assertNear(t, "instanced vertex 1", mesh.Vertices[4].Position, [3]float64{1, 0, 5})
+}
+
+func TestGLTFMirroredNode(t *testing.T) {
+	nodes := []*gltf.Node{{Mesh: gltf.Index(0), Scale: [3]float64{-1, 1, 1}}}
+	mesh, err := NewGLTFImporter().Import(bytes.NewReader(sceneGLB(t, nodes)))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	// The mirrored triangle keeps facing +z once its winding is restored
+	face := mesh.Faces[0].VertexIndices
+	a, b, c := mesh.Vertices[face[0]].Position, mesh.Vertices[face[1]].Position, mesh.Vertices[face[2]].Position
+	if n := cross3(sub3(b, a), sub3(c, a)); n[2] <= 0 {
+		t.Errorf("face normal = %v, want +z", n)
+	}
+	assertNear(t, "mirrored normal", mesh.Vertices[0].Normal, [3]float64{0, 0, 1})
+}
+
+func TestGLTFNodeCycle(t *testing.T) {
+	nodes := []*gltf.Node{{Children: []int{1}}, {Mesh: gltf.Index(0), Children: []int{0}}, {Children: []int{0}}}
+	_, err := NewGLTFImporter().Import(bytes.NewReader(sceneGLB(t, nodes)))
+	var formatErr *FormatError
+	if !errors.As(err, &formatErr) {
+		t.Errorf("expected FormatError for a node cycle, got %v", err)
+	}
+}
+
+func TestGLTFSkinnedMesh(t *testing.T) {
+	// Joint 2 was bound at y 2 and now sits at y 4, lifting vertex 1 by 2 and
+	// vertex 2, half bound to it, by 1. The skinned node's own translation is
+	// ignored.
+	doc := gltf.NewDocument()
+	doc.Meshes = []*gltf.Mesh{{Primitives: []*gltf.Primitive{{
+		Attributes: gltf.PrimitiveAttributes{
+			gltf.POSITION:  modeler.WritePosition(doc, [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}),
+			gltf.JOINTS_0:  modeler.WriteJoints(doc, [][4]uint8{{0, 0, 0, 0}, {1, 0, 0, 0}, {0, 1, 0, 0}}),
+			gltf.WEIGHTS_0: modeler.WriteWeights(doc, [][4]float32{{1, 0, 0, 0}, {1, 0, 0, 0}, {0.5, 0.5, 0, 0}}),
+		},
+		Indices: gltf.Index(modeler.WriteIndices(doc, []uint16{0, 1, 2})),
+	}}}}
+	identity := [4][4]float32{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}
+	bound := identity
+	bound[3] = [4]float32{0, -2, 0, 1}
+	doc.Skins = []*gltf.Skin{{
+		Joints:              []int{1, 2},
+		InverseBindMatrices: gltf.Index(modeler.WriteInverseBindMatrices(doc, [][4][4]float32{identity, bound})),
+	}}
+	doc.Nodes = []*gltf.Node{
+		{Mesh: gltf.Index(0), Skin: gltf.Index(0), Translation: [3]float64{100, 0, 0}},
+		{Children: []int{2}},
+		{Translation: [3]float64{0, 4, 0}},
+	}
+	doc.Scenes[0].Nodes = []int{0, 1}
+	var buf bytes.Buffer
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	mesh, err := NewGLTFImporter().Import(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	assertNear(t, "root vertex", mesh.Vertices[0].Position, [3]float64{0, 0, 0})
+	assertNear(t, "skinned vertex", mesh.Vertices[1].Position, [3]float64{1, 2, 0})
+	assertNear(t, "blended vertex", mesh.Vertices[2].Position, [3]float64{0, 2, 0})
+
+	doc.Skins[0].Joints = []int{1, 7}
+	buf.Reset()
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	var formatErr *FormatError
+	if _, err := NewGLTFImporter().Import(bytes.NewReader(buf.Bytes())); !errors.As(err, &formatErr) {
+		t.Errorf("expected FormatError for a missing joint, got %v", err)
+	}
+}
+
+func TestGLTFAnimation(t *testing.T) {
+	// The mesh node slides 4 along x over 2 seconds and turns 90 degrees about z
+	// at 1 second
+	s := math.Sqrt(0.5)
+	doc := gltf.NewDocument()
+	doc.Meshes = []*gltf.Mesh{{Primitives: []*gltf.Primitive{{
+		Attributes: gltf.PrimitiveAttributes{
+			gltf.POSITION: modeler.WritePosition(doc, [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}),
+		},
+	}}}}
+	doc.Nodes = []*gltf.Node{{Mesh: gltf.Index(0)}}
+	doc.Scenes[0].Nodes = []int{0}
+	doc.Animations = []*gltf.Animation{{
+		Samplers: []*gltf.AnimationSampler{
+			{
+				Input:  modeler.WriteAccessor(doc, gltf.TargetNone, []float32{0, 2}),
+				Output: modeler.WriteAccessor(doc, gltf.TargetNone, [][3]float32{{0, 0, 0}, {4, 0, 0}}),
+			},
+			{
+				Input:         modeler.WriteAccessor(doc, gltf.TargetNone, []float32{0, 1}),
+				Output:        modeler.WriteAccessor(doc, gltf.TargetNone, [][4]float32{{0, 0, 0, 1}, {0, 0, float32(s), float32(s)}}),
+				Interpolation: gltf.InterpolationStep,
+			},
+		},
+		Channels: []*gltf.AnimationChannel{
+			{Sampler: 0, Target: gltf.AnimationChannelTarget{Node: gltf.Index(0), Path: gltf.TRSTranslation}},
+			{Sampler: 1, Target: gltf.AnimationChannelTarget{Node: gltf.Index(0), Path: gltf.TRSRotation}},
+		},
+	}}
+	var buf bytes.Buffer
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		seconds float64
+		want    [3]float64
+	}{
+		{0.5, [3]float64{2, 0, 0}},
+		{1.5, [3]float64{3, 1, 0}},
+		{10, [3]float64{4, 1, 0}},
+	} {
+		imp := NewGLTFImporter()
+		imp.SetAnimation(0, tt.seconds)
+		mesh, err := imp.Import(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("import at %v s: %v", tt.seconds, err)
+		}
+		assertNear(t, fmt.Sprintf("vertex at %v s", tt.seconds), mesh.Vertices[1].Position, tt.want)
+	}
+
+	mesh, err := NewGLTFImporter().Import(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	assertNear(t, "vertex at rest", mesh.Vertices[1].Position, [3]float64{1, 0, 0})
+
+	imp := NewGLTFImporter()
+	imp.SetAnimation(3, 0)
+	var formatErr *FormatError
+	if _, err := imp.Import(bytes.NewReader(buf.Bytes())); !errors.As(err, &formatErr) {
+		t.Errorf("expected FormatError for a missing animation, got %v", err)
+	}
+}
+
+func TestGLTFVertexColors(t *testing.T) {
+	// The first primitive is painted red, green and blue under a half base color;
+	// the second has no COLOR_0 and keeps its material's color
+	doc := gltf.NewDocument()
+	doc.Materials = []*gltf.Material{
+		{PBRMetallicRoughness: &gltf.PBRMetallicRoughness{BaseColorFactor: &[4]float64{0.5, 0.5, 0.5, 1}}},
+		{PBRMetallicRoughness: &gltf.PBRMetallicRoughness{BaseColorFactor: &[4]float64{0, 0, 1, 1}}},
+	}
+	positions := [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}
+	doc.Meshes = []*gltf.Mesh{{Primitives: []*gltf.Primitive{
+		{
+			Attributes: gltf.PrimitiveAttributes{
+				gltf.POSITION: modeler.WritePosition(doc, positions),
+				gltf.COLOR_0:  modeler.WriteColor(doc, [][3]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}),
+			},
+			Material: gltf.Index(0),
+		},
+		{
+			Attributes: gltf.PrimitiveAttributes{gltf.POSITION: modeler.WritePosition(doc, positions)},
+			Material:   gltf.Index(1),
+		},
+	}}}
+	var buf bytes.Buffer
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	mesh, err := NewGLTFImporter().Import(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !mesh.HasVertexColors || len(mesh.Vertices) != 6 {
+		t.Fatalf("got %d vertices, colors %v", len(mesh.Vertices), mesh.HasVertexColors)
+	}
+	assertNear(t, "painted vertex", mesh.Vertices[1].Color, [3]float64{0, 0.5, 0})
+	assertNear(t, "unpainted vertex", mesh.Vertices[4].Color, [3]float64{0, 0, 1})
+
+	// Plain white vertex colors are ignored
+	doc.Meshes[0].Primitives[0].Attributes[gltf.COLOR_0] = modeler.WriteColor(doc, [][4]uint8{{255, 255, 255, 255}, {255, 255, 255, 255}, {255, 255, 255, 255}})
+	buf.Reset()
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	if mesh, err = NewGLTFImporter().Import(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if mesh.HasVertexColors {
+		t.Error("white vertex colors were kept")
+	}
+}
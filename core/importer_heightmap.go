@@ -0,0 +1,161 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+
+	_ "golang.org/x/image/tiff"
+)
+
+// HeightmapImporterImpl builds terrain from a grayscale heightmap image, one
+// voxel column per pixel: x runs along the image's rows and z down its columns,
+// so north is up in the image. An optional overlay image, such as satellite
+// imagery or a land-use map, colors the columns; it is stretched over the
+// heightmap when the sizes differ. PNG, JPEG and TIFF (including 8- and 16-bit
+// GeoTIFF, whose georeferencing is ignored) are read; floating-point or signed
+// elevation rasters must be converted to unsigned 16-bit first.
+type HeightmapImporterImpl struct {
+	Resolution int              // Columns along the image's longer side, averaging or repeating pixels (0 = one per pixel)
+	MaxHeight  int              // Height in voxels of the highest value (NewHeightmapImporter sets 64)
+	Normalize  bool             // Stretch the image's own lowest to highest value over MaxHeight, for rasters using a narrow range
+	Depth      int              // Voxels kept below the surface of each column (0 = down to the bottom)
+	Color      [3]uint8         // Terrain color without an overlay (NewHeightmapImporter sets light gray)
+	Progress   ProgressReporter // Optional progress callback
+}
+
+// NewHeightmapImporter creates a new heightmap importer building terrain up to
+// 64 voxels high.
+func NewHeightmapImporter() *HeightmapImporterImpl {
+	return &HeightmapImporterImpl{MaxHeight: 64, Color: [3]uint8{200, 200, 200}}
+}
+
+// Import decodes a heightmap and an optional color overlay (nil for none) and
+// builds the terrain.
+func (imp *HeightmapImporterImpl) Import(heightmap, overlay io.Reader) (*VoxelGrid, error) {
+	heights, _, err := image.Decode(heightmap)
+	if err != nil {
+		return nil, &FormatError{Format: "heightmap", Msg: "cannot decode image", Err: err}
+	}
+	var colors image.Image
+	if overlay != nil {
+		if colors, _, err = image.Decode(overlay); err != nil {
+			return nil, &FormatError{Format: "heightmap", Msg: "cannot decode overlay image", Err: err}
+		}
+	}
+	return imp.ImportImages(heights, colors)
+}
+
+// ImportImages builds terrain from decoded images; overlay may be nil. Each
+// column is filled from the bottom, or Depth voxels below its surface, up to
+// the height its pixels' average gray level gives, and is at least one voxel
+// high.
+func (imp *HeightmapImporterImpl) ImportImages(heightmap, overlay image.Image) (*VoxelGrid, error) {
+	if imp.MaxHeight <= 0 {
+		return nil, fmt.Errorf("%w: heightmap max height must be positive, got %d", ErrInvalidConfig, imp.MaxHeight)
+	}
+	if imp.Resolution < 0 || imp.Depth < 0 {
+		return nil, fmt.Errorf("%w: heightmap resolution and depth must not be negative", ErrInvalidConfig)
+	}
+	bounds := heightmap.Bounds()
+	width, length := bounds.Dx(), bounds.Dy()
+	if width == 0 || length == 0 {
+		return nil, &FormatError{Format: "heightmap", Msg: "empty image"}
+	}
+	sizeX, sizeZ := width, length
+	if imp.Resolution > 0 {
+		scale := float64(imp.Resolution) / float64(max(width, length))
+		sizeX = max(1, int(math.Round(float64(width)*scale)))
+		sizeZ = max(1, int(math.Round(float64(length)*scale)))
+	}
+
+	tracker := startStage(imp.Progress, StageImport, int64(sizeZ))
+	levels := make([]float64, sizeX*sizeZ)
+	lo, hi := 0.0, float64(math.MaxUint16)
+	if imp.Normalize {
+		lo, hi = math.Inf(1), math.Inf(-1)
+	}
+	for z := 0; z < sizeZ; z++ {
+		for x := 0; x < sizeX; x++ {
+			var sum float64
+			n := forEachCellPixel(heightmap, x, z, sizeX, sizeZ, func(c color.Color) {
+				sum += float64(color.Gray16Model.Convert(c).(color.Gray16).Y)
+			})
+			v := sum / float64(n)
+			levels[x+z*sizeX] = v
+			if imp.Normalize {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+		tracker.add(1)
+	}
+	tracker.finish()
+	if hi <= lo {
+		hi = lo + 1 // A flat image gives flat terrain one voxel high
+	}
+
+	tops := make([]int, len(levels))
+	total := int64(0)
+	for i, v := range levels {
+		tops[i] = int(math.Round((v - lo) / (hi - lo) * float64(imp.MaxHeight-1)))
+		total += int64(tops[i] + 1)
+		if imp.Depth > 0 {
+			total -= int64(max(0, tops[i]+1-imp.Depth))
+		}
+	}
+	vg := NewVoxelGridFor(sizeX, imp.MaxHeight, sizeZ, total, StorageConfig{})
+	for z := 0; z < sizeZ; z++ {
+		for x := 0; x < sizeX; x++ {
+			c := imp.Color
+			if overlay != nil {
+				var sum [3]float64
+				n := forEachCellPixel(overlay, x, z, sizeX, sizeZ, func(c color.Color) {
+					r, g, b, _ := c.RGBA()
+					sum[0], sum[1], sum[2] = sum[0]+float64(r>>8), sum[1]+float64(g>>8), sum[2]+float64(b>>8)
+				})
+				for i := range c {
+					c[i] = uint8(math.Round(sum[i] / float64(n)))
+				}
+			}
+			top := tops[x+z*sizeX]
+			bottom := 0
+			if imp.Depth > 0 {
+				bottom = max(0, top+1-imp.Depth)
+			}
+			for y := bottom; y <= top; y++ {
+				vg.SetVoxel(x, y, z, c)
+			}
+		}
+	}
+	return vg, nil
+}
+
+// forEachCellPixel calls fn for the pixels of img under column (x, z) of a
+// sizeX by sizeZ grid stretched over the image, at least one, and returns how
+// many there were.
+func forEachCellPixel(img image.Image, x, z, sizeX, sizeZ int, fn func(color.Color)) int {
+	b := img.Bounds()
+	x0, x1 := cellSpan(b.Min.X, b.Dx(), x, sizeX)
+	y0, y1 := cellSpan(b.Min.Y, b.Dy(), z, sizeZ)
+	for py := y0; py < y1; py++ {
+		for px := x0; px < x1; px++ {
+			fn(img.At(px, py))
+		}
+	}
+	return (x1 - x0) * (y1 - y0)
+}
+
+// cellSpan returns the pixels [lo, hi) of an image axis of n pixels starting at
+// start under cell i of size cells, at least one.
+func cellSpan(start, n, i, size int) (int, int) {
+	lo := i * n / size
+	hi := (i + 1) * n / size
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return start + lo, start + hi
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+func TestHeightmapImport(t *testing.T) {
+	// A 4x2 ramp: column x has gray level x/3 of full scale
+	heights := image.NewGray16(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			heights.SetGray16(x, y, color.Gray16{Y: uint16(x * 0xffff / 3)})
+		}
+	}
+	overlay := image.NewRGBA(image.Rect(0, 0, 2, 1)) // Stretched over the heightmap
+	overlay.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	overlay.Set(1, 0, color.RGBA{0, 0, 255, 255})
+	var heightData, overlayData bytes.Buffer
+	if err := tiff.Encode(&heightData, heights, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&overlayData, overlay); err != nil {
+		t.Fatal(err)
+	}
+
+	imp := NewHeightmapImporter()
+	imp.MaxHeight = 4
+	vg, err := imp.Import(&heightData, &overlayData)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if vg.SizeX != 4 || vg.SizeY != 4 || vg.SizeZ != 2 {
+		t.Fatalf("size = %dx%dx%d, want 4x4x2", vg.SizeX, vg.SizeY, vg.SizeZ)
+	}
+	if vg.Count() != 2*(1+2+3+4) {
+		t.Errorf("%d voxels, want 20", vg.Count())
+	}
+	for x := 0; x < 4; x++ {
+		if !vg.HasVoxel(x, x, 1) || vg.HasVoxel(x, x+1, 1) {
+			t.Errorf("column %d is not %d voxels high", x, x+1)
+		}
+	}
+	if c, _ := vg.ColorAt(0, 0, 0); c != [3]uint8{255, 0, 0} {
+		t.Errorf("left color = %v, want red", c)
+	}
+	if c, _ := vg.ColorAt(3, 3, 0); c != [3]uint8{0, 0, 255} {
+		t.Errorf("right color = %v, want blue", c)
+	}
+
+	// Halving the resolution averages pairs of columns; a depth of 1 keeps
+	// only the surface, and normalizing stretches the narrower range
+	imp.Resolution, imp.Depth, imp.Normalize = 2, 1, true
+	vg, err = imp.ImportImages(heights, nil)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if vg.SizeX != 2 || vg.SizeZ != 1 || vg.Count() != 2 {
+		t.Fatalf("size %dx%dx%d with %d voxels, want 2x4x1 with 2", vg.SizeX, vg.SizeY, vg.SizeZ, vg.Count())
+	}
+	if c, ok := vg.ColorAt(1, 3, 0); !ok || c != imp.Color || !vg.HasVoxel(0, 0, 0) {
+		t.Errorf("surface voxels missing or not %v", imp.Color)
+	}
+}
+
+func TestHeightmapErrors(t *testing.T) {
+	_, err := NewHeightmapImporter().Import(bytes.NewReader([]byte("not an image")), nil)
+	var formatErr *FormatError
+	if !errors.As(err, &formatErr) || formatErr.Format != "heightmap" {
+		t.Errorf("expected heightmap FormatError, got %v", err)
+	}
+	imp := NewHeightmapImporter()
+	imp.MaxHeight = 0
+	if _, err := imp.ImportImages(image.NewGray(image.Rect(0, 0, 1, 1)), nil); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
@@ -0,0 +1,462 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// OBJImporter implements MeshImporter for Wavefront OBJ files, with materials
+// from the MTL libraries they reference.
+type OBJImporter struct {
+	// Resources holds the material libraries named by mtllib statements, with
+	// paths relative to the OBJ file. When nil, or when a library is missing,
+	// faces keep their usemtl assignment but use the default color.
+	Resources fs.FS
+}
+
+// NewOBJImporter creates a new OBJ importer.
+func NewOBJImporter() *OBJImporter {
+	return &OBJImporter{}
+}
+
+// SetResources implements ResourceMeshImporter.
+func (imp *OBJImporter) SetResources(fsys fs.FS) {
+	imp.Resources = fsys
+}
+
+// Import reads and parses an OBJ mesh from the given reader.
+func (imp *OBJImporter) Import(r io.Reader) (*Mesh, error) {
+	return imp.ImportCtx(context.Background(), r)
+}
+
+// ImportCtx is like Import but stops early when ctx is done.
+func (imp *OBJImporter) ImportCtx(ctx context.Context, r io.Reader) (*Mesh, error) {
+	p := &objParser{
+		imp:       imp,
+		mesh:      &Mesh{Vertices: []Vertex{}, Faces: []Face{}, Materials: []Material{}},
+		vertices:  make(map[[3]int]int),
+		materials: make(map[string]int),
+		material:  -1,
+		group:     -1,
+	}
+
+	br := bufio.NewReader(r)
+	var offset int64
+	for lines := 0; ; lines++ {
+		if lines%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		start := offset
+		line, err := readLogicalLine(br, &offset)
+		if err != nil && err != io.EOF {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, &FormatError{Format: "obj", Offset: offset, Err: err}
+		}
+		if perr := p.parseLine(line); perr != nil {
+			return nil, &FormatError{Format: "obj", Offset: start, Msg: perr.Error()}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	p.mesh.CalculateBounds()
+	return p.mesh, nil
+}
+
+// SupportedFormats returns the list of supported file extensions.
+func (imp *OBJImporter) SupportedFormats() []string {
+	return []string{".obj"}
+}
+
+// objParser holds the state of one OBJ import.
+type objParser struct {
+	imp       *OBJImporter
+	mesh      *Mesh
+	positions [][3]float64
+	texCoords [][2]float64
+	normals   [][3]float64
+	vertices  map[[3]int]int // (position, texcoord, normal) indices to mesh vertex
+	materials map[string]int // Material name to index in mesh.Materials
+	material  int            // Current usemtl material, or -1
+	group     int            // Current o or g group, or -1
+}
+
+// readLogicalLine reads one line, joining lines that end in a backslash, and
+// advances offset past it.
+func readLogicalLine(br *bufio.Reader, offset *int64) (string, error) {
+	var sb strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		*offset += int64(len(line))
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasSuffix(line, "\\") && err == nil {
+			sb.WriteString(line[:len(line)-1])
+			sb.WriteByte(' ')
+			continue
+		}
+		sb.WriteString(line)
+		return sb.String(), err
+	}
+}
+
+func (p *objParser) parseLine(line string) error {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	switch fields[0] {
+	case "v":
+		v, err := parseFloats(fields[1:], 3, 3)
+		if err != nil {
+			return fmt.Errorf("vertex: %w", err)
+		}
+		p.positions = append(p.positions, [3]float64{v[0], v[1], v[2]})
+	case "vt":
+		v, err := parseFloats(fields[1:], 1, 2)
+		if err != nil {
+			return fmt.Errorf("texture coordinate: %w", err)
+		}
+		var uv [2]float64
+		copy(uv[:], v)
+		p.texCoords = append(p.texCoords, uv)
+	case "vn":
+		v, err := parseFloats(fields[1:], 3, 3)
+		if err != nil {
+			return fmt.Errorf("normal: %w", err)
+		}
+		p.normals = append(p.normals, [3]float64{v[0], v[1], v[2]})
+	case "f":
+		return p.parseFace(fields[1:])
+	case "usemtl":
+		p.material = -1
+		if len(fields) > 1 {
+			if index, ok := p.materials[strings.Join(fields[1:], " ")]; ok {
+				p.material = index
+			}
+		}
+	case "o", "g":
+		p.group = -1
+		if len(fields) > 1 {
+			p.group = p.mesh.groupIndex(strings.Join(fields[1:], " "))
+		}
+	case "mtllib":
+		for _, name := range fields[1:] {
+			p.loadMaterialLib(name)
+		}
+	}
+	// Smoothing groups, lines and points do not affect voxelization
+	return nil
+}
+
+// parseFace adds a face, triangulating quads and larger polygons.
+func (p *objParser) parseFace(refs []string) error {
+	if len(refs) < 3 {
+		return fmt.Errorf("face has %d vertices, need at least 3", len(refs))
+	}
+	indices := make([]int, len(refs))
+	for i, ref := range refs {
+		key, err := p.resolveRef(ref)
+		if err != nil {
+			return fmt.Errorf("face vertex %q: %w", ref, err)
+		}
+		index, ok := p.vertices[key]
+		if !ok {
+			index = len(p.mesh.Vertices)
+			vertex := Vertex{Position: p.positions[key[0]]}
+			if key[1] >= 0 {
+				vertex.TexCoord = p.texCoords[key[1]]
+			}
+			if key[2] >= 0 {
+				vertex.Normal = p.normals[key[2]]
+			}
+			p.mesh.Vertices = append(p.mesh.Vertices, vertex)
+			p.vertices[key] = index
+		}
+		indices[i] = index
+	}
+
+	for _, tri := range triangulatePolygon(p.mesh.Vertices, indices) {
+		p.mesh.Faces = append(p.mesh.Faces, Face{
+			VertexIndices: []int{tri[0], tri[1], tri[2]},
+			MaterialIndex: p.material,
+			Group:         p.group,
+		})
+	}
+	return nil
+}
+
+// resolveRef parses a v, v/vt, v//vn or v/vt/vn reference into zero-based
+// indices, with -1 for an absent texture coordinate or normal.
+func (p *objParser) resolveRef(ref string) ([3]int, error) {
+	key := [3]int{-1, -1, -1}
+	parts := strings.Split(ref, "/")
+	if len(parts) > 3 {
+		return key, errors.New("too many components")
+	}
+	counts := [3]int{len(p.positions), len(p.texCoords), len(p.normals)}
+	for i, part := range parts {
+		if part == "" {
+			if i == 0 {
+				return key, errors.New("missing position index")
+			}
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return key, fmt.Errorf("invalid index %q", part)
+		}
+		// Negative indices count back from the most recent element
+		if n < 0 {
+			n += counts[i]
+		} else {
+			n--
+		}
+		if n < 0 || n >= counts[i] {
+			return key, fmt.Errorf("index %s out of range", part)
+		}
+		key[i] = n
+	}
+	return key, nil
+}
+
+// loadMaterialLib adds the materials of an MTL library. Missing or unreadable
+// libraries are skipped so the geometry still imports.
+func (p *objParser) loadMaterialLib(name string) {
+	if p.imp.Resources == nil {
+		return
+	}
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	f, err := p.imp.Resources.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	materials, err := parseMTL(f, path.Dir(name))
+	if err != nil {
+		return
+	}
+	for _, mat := range materials {
+		if index, ok := p.materials[mat.Name]; ok {
+			p.mesh.Materials[index] = mat
+			continue
+		}
+		p.materials[mat.Name] = len(p.mesh.Materials)
+		p.mesh.Materials = append(p.mesh.Materials, mat)
+	}
+}
+
+// mapOptionArgs is the number of arguments taken by each texture map option.
+var mapOptionArgs = map[string]int{
+	"-blendu": 1, "-blendv": 1, "-boost": 1, "-cc": 1, "-clamp": 1, "-imfchan": 1,
+	"-mm": 2, "-o": 3, "-s": 3, "-t": 3, "-texres": 1, "-bm": 1,
+}
+
+// parseMTL reads the materials of an MTL library. Texture paths are made relative
+// to the OBJ by joining them with dir, the library's directory.
+func parseMTL(r io.Reader, dir string) ([]Material, error) {
+	var materials []Material
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "newmtl" {
+			materials = append(materials, Material{
+				Name:         strings.Join(fields[1:], " "),
+				DiffuseColor: [3]float64{0.8, 0.8, 0.8},
+				Opacity:      1,
+			})
+			continue
+		}
+		if len(materials) == 0 {
+			continue
+		}
+		mat := &materials[len(materials)-1]
+
+		switch fields[0] {
+		case "Kd":
+			mat.DiffuseColor = parseMTLColor(fields[1:], mat.DiffuseColor)
+		case "Ka":
+			mat.AmbientColor = parseMTLColor(fields[1:], mat.AmbientColor)
+		case "Ks":
+			mat.SpecularColor = parseMTLColor(fields[1:], mat.SpecularColor)
+		case "Ke":
+			mat.EmissiveColor = parseMTLColor(fields[1:], mat.EmissiveColor)
+		case "d":
+			if v, err := parseFloats(fields[1:], 1, 1); err == nil {
+				mat.Opacity = v[0]
+			}
+		case "Tr":
+			if v, err := parseFloats(fields[1:], 1, 1); err == nil {
+				mat.Opacity = 1 - v[0]
+			}
+		case "Pm":
+			if v, err := parseFloats(fields[1:], 1, 1); err == nil {
+				mat.Metallic = v[0]
+			}
+		case "Pr":
+			if v, err := parseFloats(fields[1:], 1, 1); err == nil {
+				mat.Roughness = v[0]
+			}
+		case "map_Kd":
+			args := fields[1:]
+			for len(args) > 1 && strings.HasPrefix(args[0], "-") {
+				args = args[1+mapOptionArgs[args[0]]:]
+			}
+			if len(args) > 0 {
+				mat.TexturePath = path.Join(dir, strings.ReplaceAll(strings.Join(args, " "), "\\", "/"))
+			}
+		}
+	}
+	return materials, scanner.Err()
+}
+
+// parseMTLColor reads an "r g b" color (a single value means gray), keeping def
+// for spectral or XYZ colors and malformed values.
+func parseMTLColor(fields []string, def [3]float64) [3]float64 {
+	v, err := parseFloats(fields, 1, 3)
+	if err != nil {
+		return def
+	}
+	if len(v) == 1 {
+		return [3]float64{v[0], v[0], v[0]}
+	}
+	if len(v) < 3 {
+		return def
+	}
+	return [3]float64{v[0], v[1], v[2]}
+}
+
+// parseFloats parses at least min leading numbers, ignoring any beyond max (such
+// as the optional w of a vertex, or vertex colors).
+func parseFloats(fields []string, min, max int) ([]float64, error) {
+	if len(fields) < min {
+		return nil, fmt.Errorf("expected %d values, got %d", min, len(fields))
+	}
+	if len(fields) > max {
+		fields = fields[:max]
+	}
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", f)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// triangulatePolygon splits a polygon into triangles by ear clipping in the plane
+// of its Newell normal, which handles concave polygons. Degenerate polygons fall
+// back to a fan.
+func triangulatePolygon(vertices []Vertex, polygon []int) [][3]int {
+	if len(polygon) == 3 {
+		return [][3]int{{polygon[0], polygon[1], polygon[2]}}
+	}
+
+	// Project onto the plane whose normal has the largest component dropped
+	var normal [3]float64
+	for i := range polygon {
+		a := vertices[polygon[i]].Position
+		b := vertices[polygon[(i+1)%len(polygon)]].Position
+		normal[0] += (a[1] - b[1]) * (a[2] + b[2])
+		normal[1] += (a[2] - b[2]) * (a[0] + b[0])
+		normal[2] += (a[0] - b[0]) * (a[1] + b[1])
+	}
+	axis := 0
+	for i := 1; i < 3; i++ {
+		if math.Abs(normal[i]) > math.Abs(normal[axis]) {
+			axis = i
+		}
+	}
+	if normal[axis] == 0 {
+		return fanTriangles(polygon)
+	}
+	u, v := (axis+1)%3, (axis+2)%3
+	sign := 1.0
+	if normal[axis] < 0 {
+		sign = -1
+	}
+	point := func(i int) [2]float64 {
+		p := vertices[i].Position
+		return [2]float64{p[u], p[v] * sign}
+	}
+
+	remaining := append([]int(nil), polygon...)
+	triangles := make([][3]int, 0, len(polygon)-2)
+	for len(remaining) > 3 {
+		clipped := false
+		for i := range remaining {
+			prev := remaining[(i+len(remaining)-1)%len(remaining)]
+			cur := remaining[i]
+			next := remaining[(i+1)%len(remaining)]
+			a, b, c := point(prev), point(cur), point(next)
+			if cross2(a, b, c) <= 0 {
+				continue // reflex or collinear corner
+			}
+			ear := true
+			for _, other := range remaining {
+				if other == prev || other == cur || other == next {
+					continue
+				}
+				if pointInTriangle(point(other), a, b, c) {
+					ear = false
+					break
+				}
+			}
+			if !ear {
+				continue
+			}
+			triangles = append(triangles, [3]int{prev, cur, next})
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			clipped = true
+			break
+		}
+		if !clipped {
+			// Self-intersecting or degenerate remainder
+			return append(triangles, fanTriangles(remaining)...)
+		}
+	}
+	return append(triangles, [3]int{remaining[0], remaining[1], remaining[2]})
+}
+
+func fanTriangles(polygon []int) [][3]int {
+	triangles := make([][3]int, 0, len(polygon)-2)
+	for i := 1; i+1 < len(polygon); i++ {
+		triangles = append(triangles, [3]int{polygon[0], polygon[i], polygon[i+1]})
+	}
+	return triangles
+}
+
+// cross2 returns the z component of (b-a) x (c-b).
+func cross2(a, b, c [2]float64) float64 {
+	return (b[0]-a[0])*(c[1]-b[1]) - (b[1]-a[1])*(c[0]-b[0])
+}
+
+func pointInTriangle(p, a, b, c [2]float64) bool {
+	return cross2(a, b, p) >= 0 && cross2(b, c, p) >= 0 && cross2(c, a, p) >= 0
+}
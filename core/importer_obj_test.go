@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testOBJ = `# A red quad and a concave blue L
+mtllib materials/scene.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+vn 0 0 1
+
+o base
+usemtl red
+f 1/1/1 2/2/1 3/3/1 4/4/1
+
+v 0 0 1
+v 2 0 1
+v 2 1 1
+v 1 1 1
+v 1 2 1
+v 0 2 1
+g hand
+usemtl blue
+f -6 -5 -4 \
+  -3 -2 -1
+`
+
+const testMTL = `newmtl red
+Kd 1 0 0
+map_Kd -s 1 1 1 textures/red.png
+
+newmtl blue
+Kd 0 0 1
+d 0.5
+`
+
+func TestOBJImporter(t *testing.T) {
+	imp := NewOBJImporter()
+	imp.SetResources(fstest.MapFS{"materials/scene.mtl": {Data: []byte(testMTL)}})
+	mesh, err := imp.Import(strings.NewReader(testOBJ))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if len(mesh.Vertices) != 10 {
+		t.Errorf("got %d vertices, want 10", len(mesh.Vertices))
+	}
+	// Quad -> 2 triangles, L-shaped hexagon -> 4 triangles
+	if len(mesh.Faces) != 6 {
+		t.Fatalf("got %d faces, want 6", len(mesh.Faces))
+	}
+	if v := mesh.Vertices[2]; v.TexCoord != [2]float64{1, 1} || v.Normal != [3]float64{0, 0, 1} {
+		t.Errorf("vertex attributes = %+v", v)
+	}
+	if mesh.Bounds.Max != [3]float64{2, 2, 1} {
+		t.Errorf("bounds max = %v", mesh.Bounds.Max)
+	}
+
+	if len(mesh.Materials) != 2 {
+		t.Fatalf("got %d materials, want 2", len(mesh.Materials))
+	}
+	red, blue := mesh.Materials[0], mesh.Materials[1]
+	if red.DiffuseColor != [3]float64{1, 0, 0} || red.TexturePath != "materials/textures/red.png" {
+		t.Errorf("red material = %+v", red)
+	}
+	if blue.DiffuseColor != [3]float64{0, 0, 1} || blue.Opacity != 0.5 {
+		t.Errorf("blue material = %+v", blue)
+	}
+	if mesh.Faces[0].MaterialIndex != 0 || mesh.Faces[5].MaterialIndex != 1 {
+		t.Errorf("material indices = %d, %d", mesh.Faces[0].MaterialIndex, mesh.Faces[5].MaterialIndex)
+	}
+	if strings.Join(mesh.Groups, ",") != "base,hand" || mesh.Faces[0].Group != 0 || mesh.Faces[5].Group != 1 {
+		t.Errorf("groups = %q, face groups %d, %d", mesh.Groups, mesh.Faces[0].Group, mesh.Faces[5].Group)
+	}
+
+	// The triangles of the concave polygon must cover exactly its area (3)
+	var area float64
+	for _, f := range mesh.Faces[2:] {
+		a, b, c := mesh.Vertices[f.VertexIndices[0]].Position, mesh.Vertices[f.VertexIndices[1]].Position, mesh.Vertices[f.VertexIndices[2]].Position
+		area += ((b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])) / 2
+	}
+	if area != 3 {
+		t.Errorf("triangulated area = %v, want 3", area)
+	}
+}
+
+func TestOBJImporterWithoutMaterials(t *testing.T) {
+	mesh, err := NewOBJImporter().Import(strings.NewReader(testOBJ))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if len(mesh.Materials) != 0 || mesh.Faces[0].MaterialIndex != -1 {
+		t.Errorf("missing library should leave faces unassigned, got %d materials", len(mesh.Materials))
+	}
+}
+
+func TestOBJImporterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		offset int64
+	}{
+		{"bad number", "v 0 0 0\nv 1 x 0\n", 8},
+		{"index out of range", "v 0 0 0\nv 1 0 0\nf 1 2 3\n", 16},
+		{"too few vertices", "v 0 0 0\nf 1 1\n", 8},
+		{"missing position", "v 0 0 0\nf /1 1 1\n", 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewOBJImporter().Import(strings.NewReader(tt.input))
+			var formatErr *FormatError
+			if !errors.As(err, &formatErr) {
+				t.Fatalf("err = %v, want FormatError", err)
+			}
+			if formatErr.Format != "obj" || formatErr.Offset != tt.offset {
+				t.Errorf("FormatError = %+v, want obj at offset %d", formatErr, tt.offset)
+			}
+		})
+	}
+}
+
+func TestOBJPipeline(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "materials"), 0o755)
+	os.WriteFile(filepath.Join(dir, "materials", "scene.mtl"), []byte(testMTL), 0o644)
+	path := filepath.Join(dir, "scene.obj")
+	os.WriteFile(path, []byte(testOBJ), 0o644)
+
+	pipeline, err := NewPipeline(WithInputFile(path), WithVoxelization(VoxelizationConfig{Resolution: 8}))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mesh, err := pipeline.ImportMeshCtx(context.Background(), f, pipeline.Config)
+	if err != nil {
+		t.Fatalf("ImportMesh failed: %v", err)
+	}
+	if len(mesh.Materials) != 2 {
+		t.Errorf("pipeline importer did not load the material library: %d materials", len(mesh.Materials))
+	}
+}
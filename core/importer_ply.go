@@ -0,0 +1,383 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PLYImporter implements MeshImporter for Stanford PLY files in ASCII and binary
+// (little- and big-endian) encodings. Per-vertex colors are kept, so meshes from
+// photogrammetry and scanning tools voxelize with their scanned colors.
+type PLYImporter struct{}
+
+// NewPLYImporter creates a new PLY importer.
+func NewPLYImporter() *PLYImporter {
+	return &PLYImporter{}
+}
+
+// Import reads and parses a PLY mesh from the given reader.
+func (imp *PLYImporter) Import(r io.Reader) (*Mesh, error) {
+	return imp.ImportCtx(context.Background(), r)
+}
+
+// ImportCtx is like Import but stops early when ctx is done.
+func (imp *PLYImporter) ImportCtx(ctx context.Context, r io.Reader) (*Mesh, error) {
+	counter := &countingReader{r: r}
+	br := bufio.NewReader(counter)
+	offset := func() int64 { return counter.n - int64(br.Buffered()) }
+
+	header, err := readPLYHeader(br)
+	if err != nil {
+		return nil, &FormatError{Format: "ply", Offset: offset(), Msg: "invalid header", Err: err}
+	}
+
+	var values plyValueReader
+	switch header.format {
+	case "ascii":
+		values = &plyASCIIReader{r: br}
+	case "binary_little_endian":
+		values = &plyBinaryReader{r: br, order: binary.LittleEndian}
+	case "binary_big_endian":
+		values = &plyBinaryReader{r: br, order: binary.BigEndian}
+	}
+
+	mesh := &Mesh{Vertices: []Vertex{}, Faces: []Face{}, Materials: []Material{}}
+	for _, element := range header.elements {
+		layout := newPLYLayout(element)
+		if element.name == "vertex" {
+			mesh.HasVertexColors = layout.color[0] >= 0 && layout.color[1] >= 0 && layout.color[2] >= 0
+		}
+
+		row := make([]float64, len(element.properties))
+		lists := make([][]float64, len(element.properties))
+		for i := int64(0); i < element.count; i++ {
+			if i%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+			}
+			start := offset()
+			if err := readPLYRow(values, element, row, lists); err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return nil, ctxErr
+				}
+				return nil, &FormatError{Format: "ply", Offset: start, Msg: fmt.Sprintf("%s %d", element.name, i), Err: err}
+			}
+
+			switch element.name {
+			case "vertex":
+				mesh.Vertices = append(mesh.Vertices, layout.vertex(element, row))
+			case "face":
+				if layout.indices < 0 {
+					continue
+				}
+				if err := addPLYFace(mesh, lists[layout.indices]); err != nil {
+					return nil, &FormatError{Format: "ply", Offset: start, Msg: fmt.Sprintf("face %d", i), Err: err}
+				}
+			}
+		}
+	}
+
+	mesh.CalculateBounds()
+	return mesh, nil
+}
+
+// SupportedFormats returns the list of supported file extensions.
+func (imp *PLYImporter) SupportedFormats() []string {
+	return []string{".ply"}
+}
+
+// plyProperty is a scalar or list property of a PLY element.
+type plyProperty struct {
+	name      string
+	valueType string
+	countType string // Non-empty for list properties
+}
+
+type plyElement struct {
+	name       string
+	count      int64
+	properties []plyProperty
+}
+
+type plyHeader struct {
+	format   string
+	elements []plyElement
+}
+
+// plyTypeSizes maps PLY scalar type names to their size in bytes.
+var plyTypeSizes = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+func readPLYHeader(br *bufio.Reader) (*plyHeader, error) {
+	magic, err := br.ReadString('\n')
+	if err != nil || strings.TrimRight(magic, "\r\n") != "ply" {
+		return nil, errors.New("missing \"ply\" magic")
+	}
+
+	header := &plyHeader{}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, errors.New("unexpected end of header")
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return nil, errors.New("format line has no encoding")
+			}
+			switch fields[1] {
+			case "ascii", "binary_little_endian", "binary_big_endian":
+				header.format = fields[1]
+			default:
+				return nil, fmt.Errorf("unsupported format %q", fields[1])
+			}
+		case "element":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("malformed element line %q", strings.TrimSpace(line))
+			}
+			count, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("invalid element count %q", fields[2])
+			}
+			header.elements = append(header.elements, plyElement{name: fields[1], count: count})
+		case "property":
+			if len(header.elements) == 0 {
+				return nil, errors.New("property before any element")
+			}
+			var prop plyProperty
+			switch {
+			case len(fields) == 5 && fields[1] == "list":
+				prop = plyProperty{countType: fields[2], valueType: fields[3], name: fields[4]}
+				if _, ok := plyTypeSizes[prop.countType]; !ok {
+					return nil, fmt.Errorf("unknown property type %q", prop.countType)
+				}
+			case len(fields) == 3:
+				prop = plyProperty{valueType: fields[1], name: fields[2]}
+			default:
+				return nil, fmt.Errorf("malformed property line %q", strings.TrimSpace(line))
+			}
+			if _, ok := plyTypeSizes[prop.valueType]; !ok {
+				return nil, fmt.Errorf("unknown property type %q", prop.valueType)
+			}
+			element := &header.elements[len(header.elements)-1]
+			element.properties = append(element.properties, prop)
+		case "end_header":
+			if header.format == "" {
+				return nil, errors.New("missing format line")
+			}
+			return header, nil
+		}
+		// comment and obj_info lines carry no data
+	}
+}
+
+// plyValueReader reads the next value of a given PLY type as a float64.
+type plyValueReader interface {
+	read(valueType string) (float64, error)
+}
+
+type plyASCIIReader struct {
+	r      *bufio.Reader
+	tokens []string
+}
+
+func (pr *plyASCIIReader) read(valueType string) (float64, error) {
+	for len(pr.tokens) == 0 {
+		line, err := pr.r.ReadString('\n')
+		pr.tokens = strings.Fields(line)
+		if len(pr.tokens) == 0 && err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	token := pr.tokens[0]
+	pr.tokens = pr.tokens[1:]
+	v, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q", valueType, token)
+	}
+	return v, nil
+}
+
+type plyBinaryReader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+func (pr *plyBinaryReader) read(valueType string) (float64, error) {
+	b := pr.buf[:plyTypeSizes[valueType]]
+	if _, err := io.ReadFull(pr.r, b); err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	switch valueType {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(pr.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(pr.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(pr.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(pr.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(pr.order.Uint32(b))), nil
+	default:
+		return math.Float64frombits(pr.order.Uint64(b)), nil
+	}
+}
+
+// maxPLYListLength bounds list properties so a corrupt count cannot exhaust memory.
+const maxPLYListLength = 1 << 16
+
+// readPLYRow reads one element instance into row (scalars) and lists.
+func readPLYRow(values plyValueReader, element plyElement, row []float64, lists [][]float64) error {
+	for i, prop := range element.properties {
+		if prop.countType == "" {
+			v, err := values.read(prop.valueType)
+			if err != nil {
+				return fmt.Errorf("property %s: %w", prop.name, err)
+			}
+			row[i] = v
+			continue
+		}
+
+		n, err := values.read(prop.countType)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", prop.name, err)
+		}
+		if n < 0 || n > maxPLYListLength || n != math.Trunc(n) {
+			return fmt.Errorf("property %s: invalid list length %v", prop.name, n)
+		}
+		lists[i] = lists[i][:0]
+		for j := 0; j < int(n); j++ {
+			v, err := values.read(prop.valueType)
+			if err != nil {
+				return fmt.Errorf("property %s: %w", prop.name, err)
+			}
+			lists[i] = append(lists[i], v)
+		}
+	}
+	return nil
+}
+
+// plyLayout locates the properties the importer uses within an element (-1 if absent).
+type plyLayout struct {
+	position [3]int
+	normal   [3]int
+	color    [3]int
+	texCoord [2]int
+	indices  int
+}
+
+func newPLYLayout(element plyElement) plyLayout {
+	layout := plyLayout{
+		position: [3]int{-1, -1, -1},
+		normal:   [3]int{-1, -1, -1},
+		color:    [3]int{-1, -1, -1},
+		texCoord: [2]int{-1, -1},
+		indices:  -1,
+	}
+	for i, prop := range element.properties {
+		switch prop.name {
+		case "x":
+			layout.position[0] = i
+		case "y":
+			layout.position[1] = i
+		case "z":
+			layout.position[2] = i
+		case "nx":
+			layout.normal[0] = i
+		case "ny":
+			layout.normal[1] = i
+		case "nz":
+			layout.normal[2] = i
+		case "red", "r", "diffuse_red":
+			layout.color[0] = i
+		case "green", "g", "diffuse_green":
+			layout.color[1] = i
+		case "blue", "b", "diffuse_blue":
+			layout.color[2] = i
+		case "s", "u", "texture_u":
+			layout.texCoord[0] = i
+		case "t", "v", "texture_v":
+			layout.texCoord[1] = i
+		case "vertex_indices", "vertex_index":
+			if prop.countType != "" {
+				layout.indices = i
+			}
+		}
+	}
+	return layout
+}
+
+// vertex builds a vertex from a row, scaling integer colors to [0,1].
+func (l plyLayout) vertex(element plyElement, row []float64) Vertex {
+	var v Vertex
+	for axis := 0; axis < 3; axis++ {
+		if i := l.position[axis]; i >= 0 {
+			v.Position[axis] = row[i]
+		}
+		if i := l.normal[axis]; i >= 0 {
+			v.Normal[axis] = row[i]
+		}
+		if i := l.color[axis]; i >= 0 {
+			v.Color[axis] = plyColorScale(element.properties[i].valueType, row[i])
+		}
+	}
+	for axis := 0; axis < 2; axis++ {
+		if i := l.texCoord[axis]; i >= 0 {
+			v.TexCoord[axis] = row[i]
+		}
+	}
+	return v
+}
+
+func plyColorScale(valueType string, v float64) float64 {
+	switch valueType {
+	case "uchar", "uint8", "char", "int8":
+		return v / 255
+	case "ushort", "uint16", "short", "int16":
+		return v / 65535
+	case "uint", "uint32", "int", "int32":
+		return v / math.MaxUint32
+	}
+	return v
+}
+
+// addPLYFace appends a polygon's triangles to the mesh.
+func addPLYFace(mesh *Mesh, list []float64) error {
+	if len(list) < 3 {
+		return fmt.Errorf("face has %d vertices, need at least 3", len(list))
+	}
+	polygon := make([]int, len(list))
+	for i, v := range list {
+		if v < 0 || v >= float64(len(mesh.Vertices)) || v != math.Trunc(v) {
+			return fmt.Errorf("vertex index %v out of range", v)
+		}
+		polygon[i] = int(v)
+	}
+	for _, tri := range triangulatePolygon(mesh.Vertices, polygon) {
+		mesh.Faces = append(mesh.Faces, Face{VertexIndices: []int{tri[0], tri[1], tri[2]}, MaterialIndex: -1})
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testPLYHeader = `ply
+format %s 1.0
+comment colored quad
+element vertex 4
+property float x
+property float y
+property float z
+property uchar red
+property uchar green
+property uchar blue
+element face 1
+property list uchar int vertex_indices
+end_header
+`
+
+var testPLYVertices = []struct {
+	pos [3]float32
+	rgb [3]uint8
+}{
+	{[3]float32{0, 0, 0}, [3]uint8{255, 0, 0}},
+	{[3]float32{4, 0, 0}, [3]uint8{255, 0, 0}},
+	{[3]float32{4, 4, 0}, [3]uint8{0, 0, 255}},
+	{[3]float32{0, 4, 0}, [3]uint8{0, 0, 255}},
+}
+
+func testPLY(format string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(strings.Replace(testPLYHeader, "%s", format, 1))
+	if format == "ascii" {
+		for _, v := range testPLYVertices {
+			fmt.Fprintf(&buf, "%g %g %g %d %d %d\n", v.pos[0], v.pos[1], v.pos[2], v.rgb[0], v.rgb[1], v.rgb[2])
+		}
+		buf.WriteString("4 0 1 2 3\n")
+		return buf.Bytes()
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if format == "binary_big_endian" {
+		order = binary.BigEndian
+	}
+	for _, v := range testPLYVertices {
+		binary.Write(&buf, order, v.pos)
+		buf.Write(v.rgb[:])
+	}
+	buf.WriteByte(4)
+	binary.Write(&buf, order, []int32{0, 1, 2, 3})
+	return buf.Bytes()
+}
+
+func TestPLYImporter(t *testing.T) {
+	for _, format := range []string{"ascii", "binary_little_endian", "binary_big_endian"} {
+		t.Run(format, func(t *testing.T) {
+			mesh, err := NewPLYImporter().Import(bytes.NewReader(testPLY(format)))
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if len(mesh.Vertices) != 4 || len(mesh.Faces) != 2 {
+				t.Fatalf("got %d vertices and %d faces, want 4 and 2", len(mesh.Vertices), len(mesh.Faces))
+			}
+			if !mesh.HasVertexColors {
+				t.Error("HasVertexColors not set")
+			}
+			if v := mesh.Vertices[2]; v.Position != [3]float64{4, 4, 0} || v.Color != [3]float64{0, 0, 1} {
+				t.Errorf("vertex 2 = %+v", v)
+			}
+			if mesh.Bounds.Max != [3]float64{4, 4, 0} {
+				t.Errorf("bounds max = %v", mesh.Bounds.Max)
+			}
+		})
+	}
+}
+
+func TestPLYVertexColorsVoxelize(t *testing.T) {
+	mesh, err := NewPLYImporter().Import(bytes.NewReader(testPLY("ascii")))
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	// Give the grid one layer of depth with voxel centers on the quad's plane
+	mesh.Vertices = append(mesh.Vertices, Vertex{Position: [3]float64{0, 0, -0.25}})
+	mesh.CalculateBounds()
+
+	grid, err := NewSurfaceVoxelizer().Voxelize(mesh, VoxelizationConfig{Resolution: 8})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	bottom, okBottom := grid.ColorAt(4, 0, 0)
+	top, okTop := grid.ColorAt(4, 7, 0)
+	if !okBottom || !okTop {
+		t.Fatal("expected voxels along the quad")
+	}
+	if bottom[0] <= bottom[2] || top[2] <= top[0] {
+		t.Errorf("colors not interpolated from vertices: bottom %v, top %v", bottom, top)
+	}
+}
+
+func TestPLYImporterErrors(t *testing.T) {
+	valid := string(testPLY("ascii"))
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing magic", "plx\n"},
+		{"unsupported format", "ply\nformat binary_middle_endian 1.0\nend_header\n"},
+		{"unknown type", "ply\nformat ascii 1.0\nelement vertex 1\nproperty half x\nend_header\n"},
+		{"truncated body", valid[:len(valid)-12]},
+		{"bad index", strings.Replace(valid, "4 0 1 2 3", "4 0 1 2 9", 1)},
+		{"bad number", strings.Replace(valid, "4 4 0 0 0 255", "4 x 0 0 0 255", 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPLYImporter().Import(strings.NewReader(tt.input))
+			var formatErr *FormatError
+			if !errors.As(err, &formatErr) || formatErr.Format != "ply" {
+				t.Errorf("err = %v, want ply FormatError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// XYZImporter implements MeshImporter for XYZ point clouds: text files with one
+// point per line as "x y z" or "x y z r g b", separated by spaces, tabs or
+// commas. Colors are 0-255, or 0-1 when no channel in the file exceeds 1; further
+// columns are ignored, as are blank lines, lines starting with "#" or "//" and a
+// leading header line of column names. Points import as vertices without faces,
+// for PointCloudVoxelizer.
+type XYZImporter struct{}
+
+// NewXYZImporter creates a new XYZ importer.
+func NewXYZImporter() *XYZImporter {
+	return &XYZImporter{}
+}
+
+// Import reads and parses an XYZ point cloud from the given reader.
+func (imp *XYZImporter) Import(r io.Reader) (*Mesh, error) {
+	return imp.ImportCtx(context.Background(), r)
+}
+
+// ImportCtx is like Import but stops early when ctx is done.
+func (imp *XYZImporter) ImportCtx(ctx context.Context, r io.Reader) (*Mesh, error) {
+	mesh := &Mesh{Vertices: []Vertex{}, Faces: []Face{}, Materials: []Material{}}
+	br := bufio.NewReader(r)
+	var offset int64
+	colored, maxChannel := true, 0.0
+	headerAllowed := true
+	for lines := 0; ; lines++ {
+		if lines%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		start := offset
+		line, err := br.ReadString('\n')
+		offset += int64(len(line))
+		if err != nil && err != io.EOF {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, &FormatError{Format: "xyz", Offset: offset, Err: err}
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ',' || r == ';' || r == '\r' || r == '\n'
+		})
+		if len(fields) > 0 && !strings.HasPrefix(fields[0], "#") && !strings.HasPrefix(fields[0], "//") {
+			var values [6]float64
+			n := min(len(fields), 6)
+			var perr error
+			for i := 0; i < n && perr == nil; i++ {
+				values[i], perr = strconv.ParseFloat(fields[i], 64)
+			}
+			switch {
+			case (n < 3 || perr != nil) && headerAllowed:
+				// A header line of column names or a point count
+				headerAllowed = false
+				continue
+			case n < 3:
+				return nil, &FormatError{Format: "xyz", Offset: start, Msg: fmt.Sprintf("point needs 3 coordinates, got %d", n)}
+			case perr != nil:
+				return nil, &FormatError{Format: "xyz", Offset: start, Msg: "invalid number", Err: perr}
+			}
+			headerAllowed = false
+			vertex := Vertex{Position: [3]float64{values[0], values[1], values[2]}}
+			if n < 6 {
+				colored = false
+			}
+			for i := range vertex.Color {
+				vertex.Color[i] = values[3+i]
+				maxChannel = math.Max(maxChannel, values[3+i])
+			}
+			mesh.Vertices = append(mesh.Vertices, vertex)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(mesh.Vertices) == 0 {
+		return nil, &FormatError{Format: "xyz", Offset: offset, Msg: "no points"}
+	}
+
+	if colored {
+		mesh.HasVertexColors = true
+		if maxChannel > 1 {
+			for i := range mesh.Vertices {
+				for c := range mesh.Vertices[i].Color {
+					mesh.Vertices[i].Color[c] = math.Max(0, math.Min(1, mesh.Vertices[i].Color[c]/255))
+				}
+			}
+		}
+	}
+	mesh.CalculateBounds()
+	return mesh, nil
+}
+
+// SupportedFormats returns the list of supported file extensions.
+func (imp *XYZImporter) SupportedFormats() []string {
+	return []string{".xyz"}
+}
+
+// LASImporter implements MeshImporter for ASPRS LAS point clouds (versions 1.0
+// to 1.4), the common format of LiDAR scans. Colors are kept for the point
+// formats storing them (2, 3, 5, 7, 8 and 10). Coordinates are kept as stored,
+// which is usually Z up. Compressed LAZ files are not supported.
+type LASImporter struct{}
+
+// NewLASImporter creates a new LAS importer.
+func NewLASImporter() *LASImporter {
+	return &LASImporter{}
+}
+
+// Import reads and parses a LAS point cloud from the given reader.
+func (imp *LASImporter) Import(r io.Reader) (*Mesh, error) {
+	return imp.ImportCtx(context.Background(), r)
+}
+
+// lasHeaderSize is the size of the header fields read, up to the LAS 1.4
+// 64-bit point count.
+const lasHeaderSize = 255
+
+// lasColorOffsets gives the offset of the RGB fields within a point record,
+// for the point formats storing colors.
+var lasColorOffsets = map[byte]int{2: 20, 3: 28, 5: 28, 7: 30, 8: 30, 10: 30}
+
+// lasRecordSizes gives the minimum point record length of each point format.
+var lasRecordSizes = [...]int{20, 28, 26, 34, 57, 63, 30, 36, 38, 59, 67}
+
+// ImportCtx is like Import but stops early when ctx is done.
+func (imp *LASImporter) ImportCtx(ctx context.Context, r io.Reader) (*Mesh, error) {
+	counter := &countingReader{r: r}
+	br := bufio.NewReader(counter)
+	offset := func() int64 { return counter.n - int64(br.Buffered()) }
+
+	header := make([]byte, lasHeaderSize)
+	if _, err := io.ReadFull(br, header[:227]); err != nil {
+		return nil, &FormatError{Format: "las", Offset: offset(), Msg: "truncated header", Err: err}
+	}
+	if string(header[:4]) != "LASF" {
+		return nil, &FormatError{Format: "las", Offset: 0, Msg: "not a LAS file"}
+	}
+	le := binary.LittleEndian
+	headerSize := int64(le.Uint16(header[94:]))
+	dataOffset := int64(le.Uint32(header[96:]))
+	format := header[104]
+	recordSize := int(le.Uint16(header[105:]))
+	count := uint64(le.Uint32(header[107:]))
+	if format&0xc0 != 0 {
+		return nil, &FormatError{Format: "las", Offset: 104, Msg: "compressed LAZ data is not supported"}
+	}
+	if int(format) >= len(lasRecordSizes) {
+		return nil, &FormatError{Format: "las", Offset: 104, Msg: fmt.Sprintf("unknown point format %d", format)}
+	}
+	if recordSize < lasRecordSizes[format] {
+		return nil, &FormatError{Format: "las", Offset: 105, Msg: fmt.Sprintf("point format %d needs %d-byte records, got %d", format, lasRecordSizes[format], recordSize)}
+	}
+	if headerSize < 227 || dataOffset < headerSize {
+		return nil, &FormatError{Format: "las", Offset: 94, Msg: fmt.Sprintf("invalid header size %d or point data offset %d", headerSize, dataOffset)}
+	}
+	if headerSize >= lasHeaderSize {
+		if _, err := io.ReadFull(br, header[227:]); err != nil {
+			return nil, &FormatError{Format: "las", Offset: offset(), Msg: "truncated header", Err: err}
+		}
+		if count == 0 {
+			count = le.Uint64(header[247:]) // LAS 1.4 files may leave the legacy count zero
+		}
+	}
+	// Skip the rest of the header and the variable length records
+	if _, err := io.CopyN(io.Discard, br, dataOffset-offset()); err != nil {
+		return nil, &FormatError{Format: "las", Offset: offset(), Msg: "truncated variable length records", Err: err}
+	}
+
+	var scale, shift [3]float64
+	for i := 0; i < 3; i++ {
+		scale[i] = math.Float64frombits(le.Uint64(header[131+8*i:]))
+		shift[i] = math.Float64frombits(le.Uint64(header[155+8*i:]))
+	}
+	colorOffset, colored := lasColorOffsets[format]
+	// Don't trust the count for more than a modest preallocation
+	capacity := count
+	if capacity > 1<<20 {
+		capacity = 1 << 20
+	}
+	mesh := &Mesh{
+		Vertices:        make([]Vertex, 0, capacity),
+		Faces:           []Face{},
+		Materials:       []Material{},
+		HasVertexColors: colored,
+	}
+	record := make([]byte, recordSize)
+	maxChannel := uint16(0)
+	for i := uint64(0); i < count; i++ {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		start := offset()
+		if _, err := io.ReadFull(br, record); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, &FormatError{Format: "las", Offset: start, Msg: fmt.Sprintf("point %d", i), Err: err}
+		}
+		var vertex Vertex
+		for a := 0; a < 3; a++ {
+			vertex.Position[a] = float64(int32(le.Uint32(record[4*a:])))*scale[a] + shift[a]
+		}
+		if colored {
+			for c := 0; c < 3; c++ {
+				channel := le.Uint16(record[colorOffset+2*c:])
+				vertex.Color[c] = float64(channel)
+				if channel > maxChannel {
+					maxChannel = channel
+				}
+			}
+		}
+		mesh.Vertices = append(mesh.Vertices, vertex)
+	}
+	if len(mesh.Vertices) == 0 {
+		return nil, &FormatError{Format: "las", Offset: offset(), Msg: "no points"}
+	}
+
+	// Colors are 16-bit, though some writers store 8-bit values unscaled
+	if colored {
+		full := 65535.0
+		if maxChannel <= 255 {
+			full = 255
+		}
+		for i := range mesh.Vertices {
+			for c := range mesh.Vertices[i].Color {
+				mesh.Vertices[i].Color[c] /= full
+			}
+		}
+	}
+	mesh.CalculateBounds()
+	return mesh, nil
+}
+
+// SupportedFormats returns the list of supported file extensions.
+func (imp *LASImporter) SupportedFormats() []string {
+	return []string{".las"}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestXYZImporter(t *testing.T) {
+	input := "// X Y Z R G B\n0 0 0 255 0 0\n1.5,2,3,0,0,255\n\n# comment\n4\t4\t4\t0\t255\t0"
+	mesh, err := NewXYZImporter().Import(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(mesh.Vertices) != 3 || len(mesh.Faces) != 0 || !mesh.HasVertexColors {
+		t.Fatalf("got %d points, %d faces, colors %v", len(mesh.Vertices), len(mesh.Faces), mesh.HasVertexColors)
+	}
+	if v := mesh.Vertices[1]; v.Position != [3]float64{1.5, 2, 3} || v.Color != [3]float64{0, 0, 1} {
+		t.Errorf("second point = %+v", v)
+	}
+	if mesh.Bounds.Max != [3]float64{4, 4, 4} {
+		t.Errorf("bounds = %+v", mesh.Bounds)
+	}
+
+	// Column names, no colors
+	mesh, err = NewXYZImporter().Import(strings.NewReader("x y z\n0 0 0\n1 1 1\n"))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(mesh.Vertices) != 2 || mesh.HasVertexColors {
+		t.Errorf("got %d points, colors %v", len(mesh.Vertices), mesh.HasVertexColors)
+	}
+
+	var formatErr *FormatError
+	if _, err := NewXYZImporter().Import(strings.NewReader("0 0 0\n1 x 1\n")); !errors.As(err, &formatErr) || formatErr.Offset != 6 {
+		t.Errorf("expected FormatError at offset 6, got %v", err)
+	}
+}
+
+// testLAS returns a LAS 1.2 file of point format 2 with two colored points.
+func testLAS() []byte {
+	le := binary.LittleEndian
+	header := make([]byte, 227)
+	copy(header, "LASF")
+	header[24], header[25] = 1, 2
+	le.PutUint16(header[94:], 227)
+	le.PutUint32(header[96:], 227+4) // Padding standing in for variable length records
+	header[104] = 2
+	le.PutUint16(header[105:], 26)
+	le.PutUint32(header[107:], 2)
+	for i, v := range []float64{0.01, 0.01, 0.01, 100, 200, 0} {
+		le.PutUint64(header[131+8*i:], math.Float64bits(v))
+	}
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.Write(make([]byte, 4))
+	for _, p := range []struct {
+		pos [3]int32
+		rgb [3]uint16
+	}{
+		{[3]int32{0, 0, 0}, [3]uint16{65535, 0, 0}},
+		{[3]int32{150, 250, 350}, [3]uint16{0, 0, 65535}},
+	} {
+		record := make([]byte, 26)
+		for a := 0; a < 3; a++ {
+			le.PutUint32(record[4*a:], uint32(p.pos[a]))
+			le.PutUint16(record[20+2*a:], p.rgb[a])
+		}
+		buf.Write(record)
+	}
+	return buf.Bytes()
+}
+
+func TestLASImporter(t *testing.T) {
+	mesh, err := NewLASImporter().Import(bytes.NewReader(testLAS()))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(mesh.Vertices) != 2 || !mesh.HasVertexColors {
+		t.Fatalf("got %d points, colors %v", len(mesh.Vertices), mesh.HasVertexColors)
+	}
+	if v := mesh.Vertices[1]; v.Position != [3]float64{101.5, 202.5, 3.5} || v.Color != [3]float64{0, 0, 1} {
+		t.Errorf("second point = %+v", v)
+	}
+
+	var formatErr *FormatError
+	data := testLAS()
+	data[104] |= 0x80 // LAZ
+	if _, err := NewLASImporter().Import(bytes.NewReader(data)); !errors.As(err, &formatErr) {
+		t.Errorf("expected FormatError for LAZ, got %v", err)
+	}
+	if _, err := NewLASImporter().Import(bytes.NewReader(testLAS()[:260])); !errors.As(err, &formatErr) {
+		t.Errorf("expected FormatError for truncated points, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package core
+
+// legacyBlock is a pre-flattening (Minecraft 1.12 and earlier) block: a numeric
+// block ID and a 4-bit data value.
+type legacyBlock struct {
+	ID   uint8
+	Data uint8
+}
+
+// legacyColors lists the dye colors in the order of their legacy data values.
+var legacyColors = []string{
+	"white", "orange", "magenta", "light_blue", "yellow", "lime", "pink", "gray",
+	"light_gray", "cyan", "purple", "blue", "brown", "green", "red", "black",
+}
+
+// legacyBlocks maps modern block IDs to their 1.12 id:data pairs. It covers the
+// full solid blocks useful for voxel art; blocks missing from it are not used by
+// the MCEdit exporter.
+var legacyBlocks = buildLegacyBlocks()
+
+func buildLegacyBlocks() map[string]legacyBlock {
+	blocks := map[string]legacyBlock{
+		"minecraft:air":                    {0, 0},
+		"minecraft:stone":                  {1, 0},
+		"minecraft:granite":                {1, 1},
+		"minecraft:polished_granite":       {1, 2},
+		"minecraft:diorite":                {1, 3},
+		"minecraft:polished_diorite":       {1, 4},
+		"minecraft:andesite":               {1, 5},
+		"minecraft:polished_andesite":      {1, 6},
+		"minecraft:grass_block":            {2, 0},
+		"minecraft:dirt":                   {3, 0},
+		"minecraft:coarse_dirt":            {3, 1},
+		"minecraft:podzol":                 {3, 2},
+		"minecraft:cobblestone":            {4, 0},
+		"minecraft:oak_planks":             {5, 0},
+		"minecraft:spruce_planks":          {5, 1},
+		"minecraft:birch_planks":           {5, 2},
+		"minecraft:jungle_planks":          {5, 3},
+		"minecraft:acacia_planks":          {5, 4},
+		"minecraft:dark_oak_planks":        {5, 5},
+		"minecraft:bedrock":                {7, 0},
+		"minecraft:sand":                   {12, 0},
+		"minecraft:red_sand":               {12, 1},
+		"minecraft:gravel":                 {13, 0},
+		"minecraft:gold_ore":               {14, 0},
+		"minecraft:iron_ore":               {15, 0},
+		"minecraft:coal_ore":               {16, 0},
+		"minecraft:oak_log":                {17, 0},
+		"minecraft:spruce_log":             {17, 1},
+		"minecraft:birch_log":              {17, 2},
+		"minecraft:jungle_log":             {17, 3},
+		"minecraft:oak_leaves":             {18, 0},
+		"minecraft:spruce_leaves":          {18, 1},
+		"minecraft:birch_leaves":           {18, 2},
+		"minecraft:jungle_leaves":          {18, 3},
+		"minecraft:sponge":                 {19, 0},
+		"minecraft:wet_sponge":             {19, 1},
+		"minecraft:glass":                  {20, 0},
+		"minecraft:lapis_ore":              {21, 0},
+		"minecraft:lapis_block":            {22, 0},
+		"minecraft:sandstone":              {24, 0},
+		"minecraft:chiseled_sandstone":     {24, 1},
+		"minecraft:cut_sandstone":          {24, 2},
+		"minecraft:note_block":             {25, 0},
+		"minecraft:gold_block":             {41, 0},
+		"minecraft:iron_block":             {42, 0},
+		"minecraft:smooth_stone":           {43, 8},
+		"minecraft:bricks":                 {45, 0},
+		"minecraft:tnt":                    {46, 0},
+		"minecraft:bookshelf":              {47, 0},
+		"minecraft:mossy_cobblestone":      {48, 0},
+		"minecraft:obsidian":               {49, 0},
+		"minecraft:diamond_ore":            {56, 0},
+		"minecraft:diamond_block":          {57, 0},
+		"minecraft:crafting_table":         {58, 0},
+		"minecraft:redstone_ore":           {73, 0},
+		"minecraft:ice":                    {79, 0},
+		"minecraft:snow_block":             {80, 0},
+		"minecraft:clay":                   {82, 0},
+		"minecraft:jukebox":                {84, 0},
+		"minecraft:pumpkin":                {86, 0},
+		"minecraft:netherrack":             {87, 0},
+		"minecraft:soul_sand":              {88, 0},
+		"minecraft:glowstone":              {89, 0},
+		"minecraft:stone_bricks":           {98, 0},
+		"minecraft:mossy_stone_bricks":     {98, 1},
+		"minecraft:cracked_stone_bricks":   {98, 2},
+		"minecraft:chiseled_stone_bricks":  {98, 3},
+		"minecraft:melon":                  {103, 0},
+		"minecraft:mycelium":               {110, 0},
+		"minecraft:nether_bricks":          {112, 0},
+		"minecraft:end_stone":              {121, 0},
+		"minecraft:redstone_lamp":          {123, 0},
+		"minecraft:emerald_ore":            {129, 0},
+		"minecraft:emerald_block":          {133, 0},
+		"minecraft:redstone_block":         {152, 0},
+		"minecraft:nether_quartz_ore":      {153, 0},
+		"minecraft:quartz_block":           {155, 0},
+		"minecraft:chiseled_quartz_block":  {155, 1},
+		"minecraft:quartz_pillar":          {155, 2},
+		"minecraft:acacia_log":             {162, 0},
+		"minecraft:dark_oak_log":           {162, 1},
+		"minecraft:slime_block":            {165, 0},
+		"minecraft:prismarine":             {168, 0},
+		"minecraft:prismarine_bricks":      {168, 1},
+		"minecraft:dark_prismarine":        {168, 2},
+		"minecraft:sea_lantern":            {169, 0},
+		"minecraft:hay_block":              {170, 0},
+		"minecraft:terracotta":             {172, 0},
+		"minecraft:coal_block":             {173, 0},
+		"minecraft:packed_ice":             {174, 0},
+		"minecraft:red_sandstone":          {179, 0},
+		"minecraft:chiseled_red_sandstone": {179, 1},
+		"minecraft:cut_red_sandstone":      {179, 2},
+		"minecraft:purpur_block":           {201, 0},
+		"minecraft:purpur_pillar":          {202, 0},
+		"minecraft:end_stone_bricks":       {206, 0},
+		"minecraft:magma_block":            {213, 0},
+		"minecraft:nether_wart_block":      {214, 0},
+		"minecraft:red_nether_bricks":      {215, 0},
+		"minecraft:bone_block":             {216, 0},
+	}
+	for data, color := range legacyColors {
+		blocks["minecraft:"+color+"_wool"] = legacyBlock{35, uint8(data)}
+		blocks["minecraft:"+color+"_stained_glass"] = legacyBlock{95, uint8(data)}
+		blocks["minecraft:"+color+"_terracotta"] = legacyBlock{159, uint8(data)}
+		blocks["minecraft:"+color+"_glazed_terracotta"] = legacyBlock{uint8(235 + data), 0}
+		blocks["minecraft:"+color+"_concrete"] = legacyBlock{251, uint8(data)}
+		blocks["minecraft:"+color+"_concrete_powder"] = legacyBlock{252, uint8(data)}
+	}
+	return blocks
+}
+
+// legacyBlockFor returns the 1.12 block for a modern block state such as
+// "minecraft:oak_log[axis=y]", ignoring the state's properties.
+func legacyBlockFor(blockState string) (legacyBlock, bool) {
+	if block, ok := legacyBlocks[blockState]; ok {
+		return block, true
+	}
+	block, ok := legacyBlocks[parseBlockState(blockState).Name]
+	return block, ok
+}
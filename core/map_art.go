@@ -0,0 +1,293 @@
+package core
+
+import "fmt"
+
+// Map art shades: a map darkens a block's base color by how its height
+// compares with the block to the north.
+const (
+	MapShadeLow  = 0 // Lower than the block to the north
+	MapShadeFlat = 1 // Level with it
+	MapShadeHigh = 2 // Higher
+)
+
+// MapSize is the number of blocks across a map, and so across each tile of map
+// art.
+const MapSize = 128
+
+// mapShadeFactors are the shades' multipliers of the base color, out of 255.
+var mapShadeFactors = [3]int{180, 220, 255}
+
+// mapBaseColors are the base colors a map shows blocks in (Java Edition 1.17+).
+var mapBaseColors = map[string][3]uint8{
+	"grass": {127, 178, 56}, "sand": {247, 233, 163}, "wool": {199, 199, 199},
+	"fire": {255, 0, 0}, "ice": {160, 160, 255}, "metal": {167, 167, 167},
+	"plant": {0, 124, 0}, "snow": {255, 255, 255}, "clay": {164, 168, 184},
+	"dirt": {151, 109, 77}, "stone": {112, 112, 112}, "wood": {143, 119, 72},
+	"quartz": {255, 252, 245}, "orange": {216, 127, 51}, "magenta": {178, 76, 216},
+	"light_blue": {102, 153, 216}, "yellow": {229, 229, 51}, "lime": {127, 204, 25},
+	"pink": {242, 127, 165}, "gray": {76, 76, 76}, "light_gray": {153, 153, 153},
+	"cyan": {76, 127, 153}, "purple": {127, 63, 178}, "blue": {51, 76, 178},
+	"brown": {102, 76, 51}, "green": {102, 127, 51}, "red": {153, 51, 51},
+	"black": {25, 25, 25}, "gold": {250, 238, 77}, "diamond": {92, 219, 213},
+	"lapis": {74, 128, 255}, "emerald": {0, 217, 58}, "podzol": {129, 86, 49},
+	"nether": {112, 2, 0}, "white_terracotta": {209, 177, 161},
+	"orange_terracotta": {159, 82, 36}, "magenta_terracotta": {149, 87, 108},
+	"light_blue_terracotta": {112, 108, 138}, "yellow_terracotta": {186, 133, 36},
+	"lime_terracotta": {103, 117, 53}, "pink_terracotta": {160, 77, 78},
+	"gray_terracotta": {57, 41, 35}, "light_gray_terracotta": {135, 107, 98},
+	"cyan_terracotta": {87, 92, 92}, "purple_terracotta": {122, 73, 88},
+	"blue_terracotta": {76, 62, 92}, "brown_terracotta": {76, 50, 35},
+	"green_terracotta": {76, 82, 42}, "red_terracotta": {142, 60, 46},
+	"black_terracotta": {37, 22, 16}, "crimson_nylium": {189, 48, 49},
+	"crimson_stem": {148, 63, 97}, "crimson_hyphae": {92, 25, 29},
+	"warped_nylium": {22, 126, 134}, "warped_stem": {58, 142, 140},
+	"warped_hyphae": {86, 44, 62}, "warped_wart_block": {20, 180, 133},
+	"deepslate": {100, 100, 100}, "raw_iron": {216, 175, 147}, "glow_lichen": {127, 167, 150},
+}
+
+// mapColorBlocks maps full blocks to the base color a map shows them in. It
+// covers blocks useful for map art; palette blocks missing from it are not
+// used for map art.
+var mapColorBlocks = buildMapColorBlocks()
+
+func buildMapColorBlocks() map[string]string {
+	blocks := map[string]string{
+		"minecraft:grass_block":         "grass",
+		"minecraft:slime_block":         "grass",
+		"minecraft:sand":                "sand",
+		"minecraft:sandstone":           "sand",
+		"minecraft:birch_planks":        "sand",
+		"minecraft:end_stone":           "sand",
+		"minecraft:glowstone":           "sand",
+		"minecraft:bone_block":          "sand",
+		"minecraft:mushroom_stem":       "wool",
+		"minecraft:redstone_block":      "fire",
+		"minecraft:tnt":                 "fire",
+		"minecraft:packed_ice":          "ice",
+		"minecraft:blue_ice":            "ice",
+		"minecraft:iron_block":          "metal",
+		"minecraft:oak_leaves":          "plant",
+		"minecraft:snow_block":          "snow",
+		"minecraft:white_wool":          "snow",
+		"minecraft:white_concrete":      "snow",
+		"minecraft:clay":                "clay",
+		"minecraft:dirt":                "dirt",
+		"minecraft:coarse_dirt":         "dirt",
+		"minecraft:granite":             "dirt",
+		"minecraft:jungle_planks":       "dirt",
+		"minecraft:stone":               "stone",
+		"minecraft:cobblestone":         "stone",
+		"minecraft:andesite":            "stone",
+		"minecraft:stone_bricks":        "stone",
+		"minecraft:oak_planks":          "wood",
+		"minecraft:oak_log":             "wood",
+		"minecraft:quartz_block":        "quartz",
+		"minecraft:diorite":             "quartz",
+		"minecraft:sea_lantern":         "quartz",
+		"minecraft:acacia_planks":       "orange",
+		"minecraft:pumpkin":             "orange",
+		"minecraft:red_sandstone":       "orange",
+		"minecraft:purpur_block":        "magenta",
+		"minecraft:hay_block":           "yellow",
+		"minecraft:melon":               "lime",
+		"minecraft:gold_block":          "gold",
+		"minecraft:diamond_block":       "diamond",
+		"minecraft:prismarine_bricks":   "diamond",
+		"minecraft:lapis_block":         "lapis",
+		"minecraft:emerald_block":       "emerald",
+		"minecraft:spruce_planks":       "podzol",
+		"minecraft:podzol":              "podzol",
+		"minecraft:netherrack":          "nether",
+		"minecraft:nether_bricks":       "nether",
+		"minecraft:crimson_nylium":      "crimson_nylium",
+		"minecraft:crimson_planks":      "crimson_stem",
+		"minecraft:crimson_hyphae":      "crimson_hyphae",
+		"minecraft:warped_nylium":       "warped_nylium",
+		"minecraft:warped_planks":       "warped_stem",
+		"minecraft:warped_hyphae":       "warped_hyphae",
+		"minecraft:warped_wart_block":   "warped_wart_block",
+		"minecraft:cobbled_deepslate":   "deepslate",
+		"minecraft:deepslate_bricks":    "deepslate",
+		"minecraft:raw_iron_block":      "raw_iron",
+		"minecraft:verdant_froglight":   "glow_lichen",
+		"minecraft:dark_oak_planks":     "brown",
+		"minecraft:soul_sand":           "brown",
+		"minecraft:coal_block":          "black",
+		"minecraft:obsidian":            "black",
+		"minecraft:terracotta":          "orange",
+		"minecraft:light_gray_wool":     "light_gray",
+		"minecraft:light_gray_concrete": "light_gray",
+	}
+	for _, color := range legacyColors {
+		if color != "white" && color != "light_gray" {
+			blocks["minecraft:"+color+"_wool"] = color
+			blocks["minecraft:"+color+"_concrete"] = color
+		}
+		blocks["minecraft:"+color+"_terracotta"] = color + "_terracotta"
+	}
+	return blocks
+}
+
+// mapArtBlocks are the blocks of MapColorPalette, one for each base color:
+// full blocks that neither fall nor change once placed.
+var mapArtBlocks = buildMapArtBlocks()
+
+func buildMapArtBlocks() []string {
+	blocks := []string{
+		"minecraft:grass_block", "minecraft:sandstone", "minecraft:mushroom_stem",
+		"minecraft:redstone_block", "minecraft:packed_ice", "minecraft:iron_block",
+		"minecraft:oak_leaves[persistent=true]", "minecraft:white_concrete", "minecraft:clay",
+		"minecraft:granite", "minecraft:cobblestone", "minecraft:oak_planks", "minecraft:diorite",
+	}
+	for _, color := range legacyColors[1:] {
+		blocks = append(blocks, "minecraft:"+color+"_concrete")
+	}
+	blocks = append(blocks,
+		"minecraft:gold_block", "minecraft:prismarine_bricks", "minecraft:lapis_block",
+		"minecraft:emerald_block", "minecraft:spruce_planks", "minecraft:netherrack",
+	)
+	for _, color := range legacyColors {
+		blocks = append(blocks, "minecraft:"+color+"_terracotta")
+	}
+	return append(blocks,
+		"minecraft:crimson_nylium", "minecraft:crimson_planks", "minecraft:crimson_hyphae",
+		"minecraft:warped_nylium", "minecraft:warped_planks", "minecraft:warped_hyphae",
+		"minecraft:warped_wart_block", "minecraft:cobbled_deepslate", "minecraft:raw_iron_block",
+		"minecraft:verdant_froglight",
+	)
+}
+
+// MapColorPalette returns a palette of one block for every base color a map
+// shows, colored as the map shows it flat. It covers the whole map color range
+// without a block palette to draw from; pass it to MapArtPalette for matching.
+func MapColorPalette() *Palette {
+	palette := &Palette{Colors: make([]PaletteColor, len(mapArtBlocks))}
+	for i, blockID := range mapArtBlocks {
+		name, _ := mapColorFor(blockID)
+		rgb := mapShade(mapBaseColors[name], MapShadeFlat)
+		palette.Colors[i] = PaletteColor{
+			Name:     blockID,
+			RGB:      rgb,
+			LAB:      RGBToLAB(rgb),
+			Metadata: map[string]interface{}{"block_id": blockID},
+		}
+	}
+	return palette
+}
+
+// mapColorFor returns the base color name of a block state, ignoring its
+// properties.
+func mapColorFor(blockState string) (string, bool) {
+	if color, ok := mapColorBlocks[blockState]; ok {
+		return color, true
+	}
+	color, ok := mapColorBlocks[parseBlockState(blockState).Name]
+	return color, ok
+}
+
+// mapShade returns a base color darkened to a shade.
+func mapShade(base [3]uint8, shade int) [3]uint8 {
+	var c [3]uint8
+	for i := range c {
+		c[i] = uint8(int(base[i]) * mapShadeFactors[shade] / 255)
+	}
+	return c
+}
+
+// MapArtPalette returns the colors palette's blocks show on a map, for matching
+// an image against before building it with BuildMapArt. Each base color
+// comes from the first block of palette showing it; blocks that maps show in no
+// known color are dropped. Flat map art uses the flat shade only, and staircase
+// map art all three, tripling the colors. Every entry records its shade under
+// the "map_shade" metadata key.
+func MapArtPalette(palette *Palette, staircase bool) (*Palette, error) {
+	if palette == nil {
+		return nil, fmt.Errorf("%w: map art needs a palette", ErrInvalidConfig)
+	}
+	shades := []int{MapShadeFlat}
+	if staircase {
+		shades = []int{MapShadeLow, MapShadeFlat, MapShadeHigh}
+	}
+	result := &Palette{}
+	seen := make(map[string]bool)
+	for i := range palette.Colors {
+		blockID := paletteBlockID(&palette.Colors[i])
+		name, ok := mapColorFor(blockID)
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		for _, shade := range shades {
+			rgb := mapShade(mapBaseColors[name], shade)
+			result.Colors = append(result.Colors, PaletteColor{
+				RGB:      rgb,
+				LAB:      RGBToLAB(rgb),
+				Weight:   palette.Colors[i].Weight,
+				Cost:     palette.Colors[i].Cost,
+				Metadata: map[string]interface{}{"block_id": blockID, "map_color": name, "map_shade": shade},
+			})
+		}
+	}
+	if len(result.Colors) == 0 {
+		return nil, fmt.Errorf("%w: no palette block has a known map color", ErrInvalidConfig)
+	}
+	return result, nil
+}
+
+// BuildMapArt builds map art from a flat grid (SizeY 1, north at z 0)
+// matched against a MapArtPalette: every block is raised or lowered against the
+// block north of it to produce its color's shade, and each column is shifted so
+// its lowest block is at y 0. A row of blocks is added to the north, as the
+// first row's shade depends on it too; it is not on the map. Colors that are not
+// entries of palette are treated as flat.
+func BuildMapArt(vg *VoxelGrid, palette *Palette) (*VoxelGrid, error) {
+	if vg.SizeY != 1 {
+		return nil, fmt.Errorf("%w: map art needs a flat grid, got height %d", ErrInvalidConfig, vg.SizeY)
+	}
+	shades := make(map[[3]uint8]int, len(palette.Colors))
+	for _, c := range palette.Colors {
+		if shade, ok := c.Metadata["map_shade"].(int); ok {
+			shades[c.RGB] = shade
+		}
+	}
+
+	// Heights of each column from north to south, the added row first
+	heights := make([]int, vg.SizeX*(vg.SizeZ+1))
+	sizeY := 1
+	for x := 0; x < vg.SizeX; x++ {
+		column := heights[x*(vg.SizeZ+1) : (x+1)*(vg.SizeZ+1)]
+		lowest, highest := 0, 0
+		for z := 0; z < vg.SizeZ; z++ {
+			step := 0
+			if color, ok := vg.ColorAt(x, 0, z); ok {
+				if shade, ok := shades[color]; ok {
+					step = shade - MapShadeFlat
+				}
+			}
+			column[z+1] = column[z] + step
+			lowest, highest = min(lowest, column[z+1]), max(highest, column[z+1])
+		}
+		for z := range column {
+			column[z] -= lowest
+		}
+		sizeY = max(sizeY, highest-lowest+1)
+	}
+
+	result := NewVoxelGridFor(vg.SizeX, sizeY, vg.SizeZ+1, int64(vg.Count()+vg.SizeX), StorageConfig{})
+	result.Scale, result.Origin = vg.Scale, vg.Origin
+	for x := 0; x < vg.SizeX; x++ {
+		column := heights[x*(vg.SizeZ+1) : (x+1)*(vg.SizeZ+1)]
+		for z := 0; z < vg.SizeZ; z++ {
+			color, ok := vg.ColorAt(x, 0, z)
+			if !ok {
+				continue
+			}
+			result.SetVoxel(x, column[z+1], z+1, color)
+			if z == 0 || !result.HasVoxel(x, column[z], z) {
+				// The block north of this one sets its shade; reuse its color
+				result.SetVoxel(x, column[z], z, color)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestImageGrid(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		img.Set(x, 0, color.NRGBA{255, 0, 0, 255})
+		img.Set(x, 1, color.NRGBA{0, 0, 255, 255})
+	}
+	img.Set(3, 1, color.NRGBA{0, 0, 255, 0}) // Transparent
+
+	wall, err := NewImageGrid(img, ImageGridConfig{})
+	if err != nil {
+		t.Fatalf("wall: %v", err)
+	}
+	if wall.SizeX != 4 || wall.SizeY != 2 || wall.SizeZ != 1 || wall.Count() != 7 {
+		t.Fatalf("wall is %dx%dx%d with %d voxels", wall.SizeX, wall.SizeY, wall.SizeZ, wall.Count())
+	}
+	if c, _ := wall.ColorAt(0, 1, 0); c != [3]uint8{255, 0, 0} {
+		t.Errorf("top row = %v, want red", c)
+	}
+
+	floor, err := NewImageGrid(img, ImageGridConfig{Orientation: ImageFloor, Width: 2})
+	if err != nil {
+		t.Fatalf("floor: %v", err)
+	}
+	if floor.SizeX != 2 || floor.SizeY != 1 || floor.SizeZ != 1 {
+		t.Fatalf("floor is %dx%dx%d", floor.SizeX, floor.SizeY, floor.SizeZ)
+	}
+	// Each voxel averages a 2x2 block of red over blue
+	if c, _ := floor.ColorAt(0, 0, 0); c != [3]uint8{128, 0, 128} {
+		t.Errorf("averaged color = %v, want [128 0 128]", c)
+	}
+
+	tall, err := NewImageGrid(img, ImageGridConfig{Height: 4})
+	if err != nil {
+		t.Fatalf("tall: %v", err)
+	}
+	if tall.SizeX != 8 || tall.SizeY != 4 {
+		t.Errorf("tall wall is %dx%d, want 8x4", tall.SizeX, tall.SizeY)
+	}
+	stretched, err := NewImageGrid(img, ImageGridConfig{Orientation: ImageFloor, Width: 3, Height: 5})
+	if err != nil {
+		t.Fatalf("stretched: %v", err)
+	}
+	if stretched.SizeX != 3 || stretched.SizeZ != 5 {
+		t.Errorf("stretched floor is %dx%d, want 3x5", stretched.SizeX, stretched.SizeZ)
+	}
+
+	if _, err := NewImageGrid(img, ImageGridConfig{Orientation: "ceiling"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestMapArt(t *testing.T) {
+	palette := &Palette{Colors: []PaletteColor{
+		{RGB: [3]uint8{230, 230, 230}, Metadata: map[string]interface{}{"block_id": "minecraft:white_wool"}},
+		{RGB: [3]uint8{240, 240, 240}, Metadata: map[string]interface{}{"block_id": "minecraft:white_concrete"}}, // Same map color
+		{RGB: [3]uint8{125, 125, 125}, Metadata: map[string]interface{}{"block_id": "minecraft:stone"}},
+		{RGB: [3]uint8{40, 30, 35}, Metadata: map[string]interface{}{"block_id": "minecraft:blackstone"}}, // No known map color
+	}}
+	flat, err := MapArtPalette(palette, false)
+	if err != nil {
+		t.Fatalf("flat palette: %v", err)
+	}
+	if len(flat.Colors) != 2 || flat.Colors[0].RGB != [3]uint8{220, 220, 220} {
+		t.Errorf("flat palette = %+v", flat.Colors)
+	}
+	stairs, err := MapArtPalette(palette, true)
+	if err != nil {
+		t.Fatalf("staircase palette: %v", err)
+	}
+	if len(stairs.Colors) != 6 {
+		t.Fatalf("%d staircase colors, want 6", len(stairs.Colors))
+	}
+
+	// One column, north to south: high, low, flat
+	white := func(shade int) [3]uint8 { return mapShade(mapBaseColors["snow"], shade) }
+	vg := NewVoxelGrid(1, 1, 3)
+	vg.SetVoxel(0, 0, 0, white(MapShadeHigh))
+	vg.SetVoxel(0, 0, 1, white(MapShadeLow))
+	vg.SetVoxel(0, 0, 2, white(MapShadeFlat))
+	art, err := BuildMapArt(vg, stairs)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if art.SizeY != 2 || art.SizeZ != 4 || art.Count() != 4 {
+		t.Fatalf("map art is %dx%dx%d with %d blocks", art.SizeX, art.SizeY, art.SizeZ, art.Count())
+	}
+	for z, y := range []int{0, 1, 0, 0} { // The added north row, then the image
+		if !art.HasVoxel(0, y, z) {
+			t.Errorf("no block at z %d, y %d", z, y)
+		}
+	}
+
+	if _, err := MapArtPalette(&Palette{Colors: palette.Colors[3:]}, true); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestMapColorPalette(t *testing.T) {
+	palette := MapColorPalette()
+	seen := make(map[string]bool)
+	for _, c := range palette.Colors {
+		name, ok := mapColorFor(paletteBlockID(&c))
+		if !ok {
+			t.Fatalf("%s has no map color", paletteBlockID(&c))
+		}
+		if seen[name] {
+			t.Errorf("map color %s has more than one block", name)
+		}
+		seen[name] = true
+		if c.RGB != mapShade(mapBaseColors[name], MapShadeFlat) {
+			t.Errorf("%s is %v, want the flat shade of %s", c.Name, c.RGB, name)
+		}
+	}
+	if len(seen) != len(mapBaseColors) {
+		t.Errorf("palette covers %d of %d map colors", len(seen), len(mapBaseColors))
+	}
+
+	stairs, err := MapArtPalette(palette, true)
+	if err != nil {
+		t.Fatalf("staircase palette: %v", err)
+	}
+	if len(stairs.Colors) != 3*len(mapBaseColors) {
+		t.Errorf("%d staircase colors, want %d", len(stairs.Colors), 3*len(mapBaseColors))
+	}
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Survival inventory sizes used to express block counts.
+const (
+	itemsPerStack       = 64
+	stacksPerShulkerBox = 27
+)
+
+// MaterialCount is one line of a MaterialReport.
+type MaterialCount struct {
+	Block        string  `json:"block"`
+	Count        int     `json:"count"`
+	Stacks       int     `json:"stacks"`         // Full stacks of 64
+	Remainder    int     `json:"remainder"`      // Blocks beyond the full stacks
+	ShulkerBoxes int     `json:"shulker_boxes"`  // Shulker boxes needed to carry them all
+	Cost         float64 `json:"cost,omitempty"` // Count times the palette's cost of the block
+}
+
+// MaterialReport lists the blocks a build needs, most used first.
+type MaterialReport struct {
+	Materials []MaterialCount `json:"materials"`
+	Total     int             `json:"total"`
+	TotalCost float64         `json:"total_cost,omitempty"` // Estimated cost of every block, from the palette's costs
+}
+
+// MaterialReporter is implemented by exporters that count the blocks they write,
+// and by the schematic importer, which counts the blocks it reads.
+type MaterialReporter interface {
+	// Materials returns the blocks of the last export or import, or nil before the first.
+	Materials() *MaterialReport
+}
+
+// newMaterialReport builds a report from per-block counts, indexed like ids.
+// Air and unused blocks are left out.
+func newMaterialReport(ids []string, counts []int) *MaterialReport {
+	report := &MaterialReport{}
+	for i, count := range counts {
+		if count == 0 || ids[i] == "minecraft:air" {
+			continue
+		}
+		report.Materials = append(report.Materials, MaterialCount{
+			Block:        ids[i],
+			Count:        count,
+			Stacks:       count / itemsPerStack,
+			Remainder:    count % itemsPerStack,
+			ShulkerBoxes: (count + itemsPerStack*stacksPerShulkerBox - 1) / (itemsPerStack * stacksPerShulkerBox),
+		})
+		report.Total += count
+	}
+	sort.Slice(report.Materials, func(i, j int) bool {
+		a, b := report.Materials[i], report.Materials[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Block < b.Block
+	})
+	return report
+}
+
+// paletteCosts returns the cost of one block of each palette block name, from
+// the first entry of the block with a cost. Costs go by name, so the block's
+// rotated and detail states cost the same.
+func paletteCosts(palette *Palette) map[string]float64 {
+	if palette == nil {
+		return nil
+	}
+	costs := make(map[string]float64)
+	for i := range palette.Colors {
+		color := &palette.Colors[i]
+		if color.Cost <= 0 {
+			continue
+		}
+		name := parseBlockState(paletteBlockID(color)).Name
+		if _, ok := costs[name]; !ok {
+			costs[name] = color.Cost
+		}
+	}
+	return costs
+}
+
+// addCosts prices the report's blocks by name, totaling them in TotalCost.
+func (r *MaterialReport) addCosts(costs map[string]float64) {
+	for i := range r.Materials {
+		m := &r.Materials[i]
+		m.Cost = float64(m.Count) * costs[parseBlockState(m.Block).Name]
+		r.TotalCost += m.Cost
+	}
+}
+
+// WriteCSV writes the report as CSV with a header row. A cost column follows
+// when the blocks have costs.
+func (r *MaterialReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"block", "count", "stacks", "remainder", "shulker_boxes"}
+	if r.TotalCost > 0 {
+		header = append(header, "cost")
+	}
+	cw.Write(header)
+	for _, m := range r.Materials {
+		row := []string{
+			m.Block,
+			strconv.Itoa(m.Count),
+			strconv.Itoa(m.Stacks),
+			strconv.Itoa(m.Remainder),
+			strconv.Itoa(m.ShulkerBoxes),
+		}
+		if r.TotalCost > 0 {
+			row = append(row, strconv.FormatFloat(m.Cost, 'f', -1, 64))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write material list: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *MaterialReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("failed to write material list: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewMaterialReport(t *testing.T) {
+	ids := []string{"minecraft:air", "minecraft:stone", "minecraft:dirt", "minecraft:glass"}
+	report := newMaterialReport(ids, []int{10, 100, 64*27 + 1, 0})
+
+	want := []MaterialCount{
+		{Block: "minecraft:dirt", Count: 1729, Stacks: 27, Remainder: 1, ShulkerBoxes: 2},
+		{Block: "minecraft:stone", Count: 100, Stacks: 1, Remainder: 36, ShulkerBoxes: 1},
+	}
+	if len(report.Materials) != len(want) {
+		t.Fatalf("got %+v, want %+v", report.Materials, want)
+	}
+	for i := range want {
+		if report.Materials[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, report.Materials[i], want[i])
+		}
+	}
+	if report.Total != 1829 {
+		t.Errorf("Total = %d, want 1829", report.Total)
+	}
+
+	var csv bytes.Buffer
+	if err := report.WriteCSV(&csv); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	wantCSV := "block,count,stacks,remainder,shulker_boxes\n" +
+		"minecraft:dirt,1729,27,1,2\n" +
+		"minecraft:stone,100,1,36,1\n"
+	if csv.String() != wantCSV {
+		t.Errorf("CSV =\n%s\nwant\n%s", csv.String(), wantCSV)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded MaterialReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSON does not decode: %v", err)
+	}
+	if decoded.Total != report.Total || len(decoded.Materials) != 2 || decoded.Materials[0] != want[0] {
+		t.Errorf("JSON round trip = %+v, want %+v", decoded, report)
+	}
+}
+
+func TestSchematicMaterials(t *testing.T) {
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:red_wool", RGB: [3]uint8{160, 39, 34}},
+		{ID: "minecraft:white_wool", RGB: [3]uint8{233, 236, 236}},
+	})
+	vg := NewVoxelGrid(3, 1, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{160, 39, 34})
+	vg.SetVoxel(1, 0, 0, [3]uint8{150, 40, 40}) // Matched to red
+	vg.SetVoxel(2, 0, 0, [3]uint8{233, 236, 236})
+
+	exporter := NewSchematicExporter(2)
+	if exporter.Materials() != nil {
+		t.Error("Materials before the first export should be nil")
+	}
+	var buf bytes.Buffer
+	if err := exporter.Export(vg, palette, DitherConfig{}, &buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	report := exporter.Materials()
+	var lines []string
+	for _, m := range report.Materials {
+		lines = append(lines, m.Block)
+	}
+	if got := strings.Join(lines, ","); got != "minecraft:red_wool,minecraft:white_wool" || report.Total != 3 {
+		t.Errorf("materials = %s (total %d), want red_wool then white_wool, 3 blocks", got, report.Total)
+	}
+
+	// Importing the schematic counts the same blocks
+	importer := NewSchematicImporter()
+	if _, err := importer.Import(&buf); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if got := importer.Materials(); got == nil || !reflect.DeepEqual(got, report) {
+		t.Errorf("imported materials = %+v, want %+v", got, report)
+	}
+
+	// The registered exporter reports through MaterialReporter
+	p, err := NewPipeline(WithPalette(palette), WithExporterName("schematic"))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	if err := p.ExportGrid(vg, &bytes.Buffer{}); err != nil {
+		t.Fatalf("ExportGrid failed: %v", err)
+	}
+	reporter, ok := p.Exporter.(MaterialReporter)
+	if !ok || reporter.Materials() == nil || reporter.Materials().Total != 3 {
+		t.Error("schematic grid exporter does not report materials")
+	}
+}
+
+func TestMaterialCosts(t *testing.T) {
+	palette := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:oak_log", RGB: [3]uint8{109, 85, 50}, Properties: map[string]string{"axis": "x"}},
+		{ID: "minecraft:white_wool", RGB: [3]uint8{233, 236, 236}},
+	})
+	palette.Colors[0].Cost = 0.5
+	vg := NewVoxelGrid(3, 1, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{109, 85, 50})
+	vg.SetVoxel(1, 0, 0, [3]uint8{109, 85, 50})
+	vg.SetVoxel(2, 0, 0, [3]uint8{233, 236, 236})
+
+	exporter := NewSchematicExporter(2)
+	exporter.Rotation = 1 // Turned logs cost the same
+	if err := exporter.Export(vg, palette, DitherConfig{}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	report := exporter.Materials()
+	if report.TotalCost != 1 || report.Materials[0].Cost != 1 || report.Materials[1].Cost != 0 {
+		t.Errorf("costs = %+v (total %g), want 1 for the logs and none for the wool", report.Materials, report.TotalCost)
+	}
+
+	var csv bytes.Buffer
+	if err := report.WriteCSV(&csv); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	if !strings.HasPrefix(csv.String(), "block,count,stacks,remainder,shulker_boxes,cost\nminecraft:oak_log[axis=z],2,0,2,1,1\n") {
+		t.Errorf("CSV =\n%s", csv.String())
+	}
+}
@@ -1,6 +1,10 @@
 package core
 
-import "io"
+import (
+	"image"
+	"io"
+	"io/fs"
+)
 
 // Mesh represents a 3D polygon mesh with vertices, faces, and optional materials.
 type Mesh struct {
@@ -8,13 +12,22 @@ type Mesh struct {
 	Faces     []Face
 	Materials []Material
 	Bounds    BoundingBox
+	
+	// HasVertexColors reports that Vertex.Color is set; voxels then take colors
+	// interpolated across each face instead of the face's material color.
+	HasVertexColors bool
+	
+	// Groups names the parts of the model faces belong to, such as OBJ objects
+	// and groups or glTF nodes, by Face.Group.
+	Groups []string
 }
 
-// Vertex represents a 3D point with optional normal and texture coordinates.
+// Vertex represents a 3D point with optional normal, texture coordinates and color.
 type Vertex struct {
 	Position [3]float64
 	Normal   [3]float64
 	TexCoord [2]float64
+	Color    [3]float64 // RGB [0,1], used when Mesh.HasVertexColors is set
 }
 
 // Face represents a polygon face with vertex indices and material reference.
@@ -23,6 +36,7 @@ type Face struct {
 	NormalIndices   []int
 	TexCoordIndices []int
 	MaterialIndex   int
+	Group           int // Index in Mesh.Groups, ignored when out of range
 }
 
 // Material represents surface properties including color and texture.
@@ -31,9 +45,25 @@ type Material struct {
 	DiffuseColor  [3]float64 // RGB [0,1]
 	AmbientColor  [3]float64
 	SpecularColor [3]float64
-	EmissiveColor [3]float64
-	Opacity       float64
+	EmissiveColor [3]float64 // Light emitted; may exceed 1 for bright emitters
+	Opacity       float64 // 1 = opaque
+	Metallic      float64 // PBR metalness [0,1]
+	Roughness     float64 // PBR roughness [0,1], used with Metallic
 	TexturePath   string
+	
+	// Texture is the decoded base color texture. Voxels on faces using the material
+	// sample it at the interpolated vertex texture coordinates, with (0,0) at the
+	// image's top-left corner and wrapping outside [0,1], and multiply the sample
+	// by DiffuseColor.
+	Texture image.Image
+	
+	// EmissiveTexture, when set, varies the light emitted across faces using the
+	// material: samples, taken like Texture's, multiply EmissiveColor.
+	EmissiveTexture image.Image
+	
+	// Transmission is the fraction of light passing through the surface [0,1], as
+	// through glass, on top of any Opacity.
+	Transmission float64
 }
 
 // BoundingBox represents axis-aligned bounding box.
@@ -51,6 +81,38 @@ type MeshImporter interface {
 	SupportedFormats() []string
 }
 
+// ResourceMeshImporter is implemented by importers whose input refers to companion
+// files, such as the material libraries of an OBJ file.
+type ResourceMeshImporter interface {
+	MeshImporter
+	
+	// SetResources sets the file system companion files are opened from, rooted at
+	// the input file's directory.
+	SetResources(fsys fs.FS)
+}
+
+// AnimatedMeshImporter is implemented by importers that can pose a model as it
+// is at a moment of one of its animations.
+type AnimatedMeshImporter interface {
+	MeshImporter
+	
+	// SetAnimation poses imported models with the animation of the given index at
+	// seconds into it. A negative index keeps the rest pose.
+	SetAnimation(animation int, seconds float64)
+}
+
+// groupIndex returns the index of the group named name in m.Groups, adding it
+// when missing.
+func (m *Mesh) groupIndex(name string) int {
+	for i, group := range m.Groups {
+		if group == name {
+			return i
+		}
+	}
+	m.Groups = append(m.Groups, name)
+	return len(m.Groups) - 1
+}
+
 // CalculateBounds computes the bounding box of the mesh.
 func (m *Mesh) CalculateBounds() {
 	if len(m.Vertices) == 0 {
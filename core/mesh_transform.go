@@ -0,0 +1,134 @@
+package core
+
+import "math"
+
+// Up axes accepted by VoxelizationConfig.UpAxis. Voxel grids are Y-up and
+// right-handed like Minecraft and glTF, with z pointing south.
+const (
+	UpAxisY = "y" // Y up: glTF, Minecraft and most game engines (the default)
+	UpAxisZ = "z" // Z up: Blender scenes, 3ds Max, CAD and scanning tools
+	UpAxisX = "x" // X up
+)
+
+// upAxes lists the accepted VoxelizationConfig.UpAxis values.
+var upAxes = []string{UpAxisY, UpAxisZ, UpAxisX}
+
+// UpAxes returns the names accepted by VoxelizationConfig.UpAxis. An empty axis
+// selects UpAxisY.
+func UpAxes() []string {
+	return append([]string(nil), upAxes...)
+}
+
+// orientation returns the matrix taking mesh coordinates with the given up axis
+// into the grid's Y-up frame, mirrored along z when flipZ is set, and whether it
+// differs from the identity. The rotations keep the mesh's handedness.
+func orientation(upAxis string, flipZ bool) ([3][3]float64, bool) {
+	m := identityMatrix()
+	switch upAxis {
+	case UpAxisZ:
+		// (x, y, z) -> (x, z, -y): +z becomes up and +y north
+		m = [3][3]float64{{1, 0, 0}, {0, 0, 1}, {0, -1, 0}}
+	case UpAxisX:
+		// (x, y, z) -> (-y, x, z)
+		m = [3][3]float64{{0, -1, 0}, {1, 0, 0}, {0, 0, 1}}
+	}
+	if flipZ {
+		m[2] = [3]float64{-m[2][0], -m[2][1], -m[2][2]}
+	}
+	return m, upAxis != "" && upAxis != UpAxisY || flipZ
+}
+
+// TransformConfig moves an imported mesh before voxelization. The mesh is
+// scaled, then rotated about x, y and z in turn, then translated, all in the
+// grid's Y-up frame after VoxelizationConfig.UpAxis and FlipZ apply. The grid is
+// fitted around the result, so translation only shows in VoxelGrid.Origin, and
+// with an automatic resolution only the ratios between the scale factors change
+// the output.
+type TransformConfig struct {
+	Scale     [3]float64 // Per-axis scale factors; zero components mean 1
+	Rotate    [3]float64 // Euler angles in degrees about x, y and z, applied in that order
+	Translate [3]float64 // Offset in mesh units
+}
+
+// matrices returns the linear part of the transform for positions and for
+// normals, which take the inverse scale.
+func (t TransformConfig) matrices() (m, normal [3][3]float64) {
+	var scale, inverse [3][3]float64
+	for i, s := range t.Scale {
+		if s == 0 {
+			s = 1
+		}
+		scale[i][i], inverse[i][i] = s, 1/s
+	}
+	rotation := identityMatrix()
+	for axis, degrees := range t.Rotate {
+		if degrees != 0 {
+			rotation = mulMatrix(axisRotation(axis, degrees*math.Pi/180), rotation)
+		}
+	}
+	return mulMatrix(rotation, scale), mulMatrix(rotation, inverse)
+}
+
+// axisRotation returns the counterclockwise rotation by radians about an axis
+// (0 for x, 1 for y, 2 for z), looking from its positive end.
+func axisRotation(axis int, radians float64) [3][3]float64 {
+	m := identityMatrix()
+	sin, cos := math.Sincos(radians)
+	i, j := (axis+1)%3, (axis+2)%3
+	m[i][i], m[i][j] = cos, -sin
+	m[j][i], m[j][j] = sin, cos
+	return m
+}
+
+// placeMesh returns mesh turned so that config.Voxelization.UpAxis points up,
+// mirrored when FlipZ is set and moved by config.Transform, or mesh itself when
+// none of them apply. The original mesh is left unchanged; the copy shares its
+// faces and materials.
+func placeMesh(mesh *Mesh, config PipelineConfig) *Mesh {
+	orient, oriented := orientation(config.Voxelization.UpAxis, config.Voxelization.FlipZ)
+	t := config.Transform
+	if !oriented && t == (TransformConfig{}) {
+		return mesh
+	}
+	m, normal := t.matrices()
+	m, normal = mulMatrix(m, orient), mulMatrix(normal, orient)
+
+	placed := *mesh
+	placed.Vertices = make([]Vertex, len(mesh.Vertices))
+	for i, v := range mesh.Vertices {
+		v.Position = mulMatrixVec(m, v.Position)
+		for k := range v.Position {
+			v.Position[k] += t.Translate[k]
+		}
+		n := mulMatrixVec(normal, v.Normal)
+		if length := math.Sqrt(dot3(n, n)); length > 0 {
+			v.Normal = [3]float64{n[0] / length, n[1] / length, n[2] / length}
+		}
+		placed.Vertices[i] = v
+	}
+	placed.Bounds = BoundingBox{}
+	placed.CalculateBounds()
+	return &placed
+}
+
+func identityMatrix() [3][3]float64 {
+	return [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+}
+
+func mulMatrix(a, b [3][3]float64) [3][3]float64 {
+	var out [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			out[i][j] = a[i][0]*b[0][j] + a[i][1]*b[1][j] + a[i][2]*b[2][j]
+		}
+	}
+	return out
+}
+
+func mulMatrixVec(m [3][3]float64, v [3]float64) [3]float64 {
+	var out [3]float64
+	for i := 0; i < 3; i++ {
+		out[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPlaceMesh(t *testing.T) {
+	// A triangle standing 10 units tall along z, as a Z-up tool exports it
+	mesh := &Mesh{
+		Vertices: []Vertex{
+			{Position: [3]float64{0, 0, 0}},
+			{Position: [3]float64{2, 1, 0}},
+			{Position: [3]float64{0, 0, 10}, Normal: [3]float64{0, 1, 0}},
+		},
+		Faces:     []Face{{VertexIndices: []int{0, 1, 2}}},
+		Materials: []Material{{DiffuseColor: [3]float64{1, 1, 1}}},
+	}
+
+	if got := placeMesh(mesh, PipelineConfig{Voxelization: VoxelizationConfig{UpAxis: UpAxisY}}); got != mesh {
+		t.Error("Y-up mesh was copied")
+	}
+
+	oriented := placeMesh(mesh, PipelineConfig{Voxelization: VoxelizationConfig{UpAxis: UpAxisZ}})
+	if got := oriented.Vertices[2].Position; got != [3]float64{0, 10, 0} {
+		t.Errorf("top vertex = %v, want [0 10 0]", got)
+	}
+	if got := oriented.Vertices[1].Position; got != [3]float64{2, 0, -1} {
+		t.Errorf("vertex 1 = %v, want [2 0 -1]", got)
+	}
+	if got := oriented.Vertices[2].Normal; got != [3]float64{0, 0, -1} {
+		t.Errorf("normal = %v, want [0 0 -1]", got)
+	}
+	if oriented.Bounds.Max[1] != 10 || oriented.Bounds.Min[2] != -1 {
+		t.Errorf("bounds = %+v", oriented.Bounds)
+	}
+	if mesh.Vertices[2].Position != [3]float64{0, 0, 10} {
+		t.Error("original mesh changed")
+	}
+
+	mirrored := placeMesh(mesh, PipelineConfig{Voxelization: VoxelizationConfig{UpAxis: UpAxisZ, FlipZ: true}})
+	if got := mirrored.Vertices[1].Position; got[2] != 1 {
+		t.Errorf("mirrored vertex 1 = %v, want z = 1", got)
+	}
+
+	p, err := NewPipeline(WithVoxelization(VoxelizationConfig{Resolution: 10, UpAxis: UpAxisZ}))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	vg, err := p.VoxelizeMeshCtx(context.Background(), mesh, p.Config)
+	if err != nil {
+		t.Fatalf("voxelize: %v", err)
+	}
+	if vg.SizeY != 10 {
+		t.Errorf("grid is %dx%dx%d, want 10 voxels tall", vg.SizeX, vg.SizeY, vg.SizeZ)
+	}
+
+	if _, err := NewPipeline(WithVoxelization(VoxelizationConfig{Resolution: 10, UpAxis: "w"})); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unknown up axis, got %v", err)
+	}
+}
+
+func TestMeshTransform(t *testing.T) {
+	// A 2x2x2 cube
+	mesh := &Mesh{Materials: []Material{{DiffuseColor: [3]float64{1, 1, 1}}}}
+	for i := 0; i < 8; i++ {
+		mesh.Vertices = append(mesh.Vertices, Vertex{
+			Position: [3]float64{float64(i & 1 * 2), float64(i >> 1 & 1 * 2), float64(i >> 2 * 2)},
+			Normal:   [3]float64{1, 0, 0},
+		})
+	}
+	for _, f := range [][3]int{{0, 1, 3}, {0, 3, 2}, {4, 7, 5}, {4, 6, 7}, {0, 4, 5}, {0, 5, 1},
+		{2, 3, 7}, {2, 7, 6}, {0, 2, 6}, {0, 6, 4}, {1, 5, 7}, {1, 7, 3}} {
+		mesh.Faces = append(mesh.Faces, Face{VertexIndices: f[:]})
+	}
+
+	config := PipelineConfig{
+		Voxelization: VoxelizationConfig{UpAxis: UpAxisZ},
+		Transform: TransformConfig{
+			Scale:     [3]float64{3, 1, 0},
+			Rotate:    [3]float64{0, 0, 90},
+			Translate: [3]float64{10, 0, 0},
+		},
+	}
+	placed := placeMesh(mesh, config)
+	// Z-up turns the cube within the same box, scaling stretches x to 6, the
+	// quarter turn about z swings it up onto y and translation moves it along x
+	bounds := placed.Bounds
+	want := BoundingBox{Min: [3]float64{8, 0, -2}, Max: [3]float64{10, 6, 0}}
+	for i := 0; i < 3; i++ {
+		if math.Abs(bounds.Min[i]-want.Min[i]) > 1e-9 || math.Abs(bounds.Max[i]-want.Max[i]) > 1e-9 {
+			t.Fatalf("bounds = %+v, want %+v", bounds, want)
+		}
+	}
+	if n := placed.Vertices[0].Normal; math.Abs(n[1]-1) > 1e-9 {
+		t.Errorf("normal = %v, want [0 1 0]", n)
+	}
+
+	p, err := NewPipeline(WithVoxelization(VoxelizationConfig{Resolution: 12}), WithTransform(config.Transform))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+	vg, err := p.VoxelizeMeshCtx(context.Background(), mesh, p.Config)
+	if err != nil {
+		t.Fatalf("voxelize: %v", err)
+	}
+	if vg.SizeY != 12 || vg.SizeX > 5 {
+		t.Errorf("grid is %dx%dx%d, want 12 voxels tall and a third as wide", vg.SizeX, vg.SizeY, vg.SizeZ)
+	}
+
+	bad := TransformConfig{Rotate: [3]float64{math.NaN(), 0, 0}}
+	if _, err := NewPipeline(WithTransform(bad)); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for a NaN angle, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import "sort"
+
+// faceSlice identifies the plane holding voxel faces that point along one axis.
+type faceSlice struct {
+	axis  int // Axis the faces point along
+	dir   int // -1 or +1
+	plane int // Coordinate of the plane along axis
+}
+
+// GreedyMesh converts a voxel grid to a polygon mesh of its visible faces. Adjacent
+// faces of the same color are merged into rectangles, and each quad gets its own
+// four vertices with the face normal and the voxel color, so the mesh has flat
+// per-face colors. Positions are in voxel units with the grid's (0, 0, 0) corner at
+// the origin, and quads wind counter-clockwise seen from outside.
+func GreedyMesh(vg *VoxelGrid) *Mesh {
+	return greedyMesh(vg, nil)
+}
+
+func greedyMesh(vg *VoxelGrid, progress ProgressReporter) *Mesh {
+	// Collect the faces not covered by a neighbouring voxel, by plane
+	slices := make(map[faceSlice]map[[2]int][3]uint8)
+	tracker := startStage(progress, StageExport, int64(vg.Count()))
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		tracker.add(1)
+		p := [3]int{x, y, z}
+		for axis := 0; axis < 3; axis++ {
+			for _, dir := range [2]int{-1, 1} {
+				n := p
+				n[axis] += dir
+				if vg.HasVoxel(n[0], n[1], n[2]) {
+					continue
+				}
+				key := faceSlice{axis: axis, dir: dir, plane: p[axis]}
+				if dir > 0 {
+					key.plane++
+				}
+				cells := slices[key]
+				if cells == nil {
+					cells = make(map[[2]int][3]uint8)
+					slices[key] = cells
+				}
+				cells[[2]int{p[(axis+1)%3], p[(axis+2)%3]}] = color
+			}
+		}
+		return true
+	})
+	tracker.finish()
+
+	// Visit the planes in a fixed order so the output is deterministic
+	keys := make([]faceSlice, 0, len(slices))
+	for key := range slices {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.axis != b.axis {
+			return a.axis < b.axis
+		}
+		if a.dir != b.dir {
+			return a.dir < b.dir
+		}
+		return a.plane < b.plane
+	})
+
+	mesh := &Mesh{
+		Materials:       []Material{{Name: "voxel", DiffuseColor: [3]float64{1, 1, 1}, Opacity: 1}},
+		HasVertexColors: true,
+	}
+	for _, key := range keys {
+		mergeFaces(mesh, key, slices[key])
+	}
+	mesh.CalculateBounds()
+	return mesh
+}
+
+// mergeFaces greedily covers the faces of one plane with rectangles of a single
+// color and appends them to mesh as quads. cells is emptied.
+func mergeFaces(mesh *Mesh, key faceSlice, cells map[[2]int][3]uint8) {
+	starts := make([][2]int, 0, len(cells))
+	for cell := range cells {
+		starts = append(starts, cell)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		if starts[i][1] != starts[j][1] {
+			return starts[i][1] < starts[j][1]
+		}
+		return starts[i][0] < starts[j][0]
+	})
+
+	u, v := (key.axis+1)%3, (key.axis+2)%3
+	var normal [3]float64
+	normal[key.axis] = float64(key.dir)
+	for _, start := range starts {
+		color, ok := cells[start]
+		if !ok {
+			continue // Covered by an earlier rectangle
+		}
+		// Grow along u, then add rows along v while they match in full
+		width := 1
+		for c, ok := cells[[2]int{start[0] + width, start[1]}]; ok && c == color; c, ok = cells[[2]int{start[0] + width, start[1]}] {
+			width++
+		}
+		height := 1
+	rows:
+		for {
+			for du := 0; du < width; du++ {
+				if c, ok := cells[[2]int{start[0] + du, start[1] + height}]; !ok || c != color {
+					break rows
+				}
+			}
+			height++
+		}
+		for dv := 0; dv < height; dv++ {
+			for du := 0; du < width; du++ {
+				delete(cells, [2]int{start[0] + du, start[1] + dv})
+			}
+		}
+
+		corners := [4][2]int{
+			{start[0], start[1]},
+			{start[0] + width, start[1]},
+			{start[0] + width, start[1] + height},
+			{start[0], start[1] + height},
+		}
+		if key.dir < 0 {
+			corners[1], corners[3] = corners[3], corners[1]
+		}
+		rgb := [3]float64{float64(color[0]) / 255, float64(color[1]) / 255, float64(color[2]) / 255}
+		face := Face{VertexIndices: make([]int, 4)}
+		for i, corner := range corners {
+			var pos [3]float64
+			pos[key.axis] = float64(key.plane)
+			pos[u] = float64(corner[0])
+			pos[v] = float64(corner[1])
+			face.VertexIndices[i] = len(mesh.Vertices)
+			mesh.Vertices = append(mesh.Vertices, Vertex{Position: pos, Normal: normal, Color: rgb})
+		}
+		mesh.Faces = append(mesh.Faces, face)
+	}
+}
@@ -0,0 +1,88 @@
+package core
+
+import "testing"
+
+// quadArea returns the area of a quad and checks that its winding matches the
+// vertex normals.
+func quadArea(t *testing.T, mesh *Mesh, face Face) float64 {
+	t.Helper()
+	if len(face.VertexIndices) != 4 {
+		t.Fatalf("face has %d vertices, want 4", len(face.VertexIndices))
+	}
+	p := func(i int) [3]float64 { return mesh.Vertices[face.VertexIndices[i]].Position }
+	a, b, c := p(0), p(1), p(2)
+	e1 := [3]float64{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+	e2 := [3]float64{c[0] - b[0], c[1] - b[1], c[2] - b[2]}
+	cross := [3]float64{
+		e1[1]*e2[2] - e1[2]*e2[1],
+		e1[2]*e2[0] - e1[0]*e2[2],
+		e1[0]*e2[1] - e1[1]*e2[0],
+	}
+	normal := mesh.Vertices[face.VertexIndices[0]].Normal
+	area := cross[0]*normal[0] + cross[1]*normal[1] + cross[2]*normal[2]
+	if area <= 0 {
+		t.Errorf("face %v winds against its normal %v", face.VertexIndices, normal)
+	}
+	return area
+}
+
+func TestGreedyMesh(t *testing.T) {
+	red, blue := [3]uint8{255, 0, 0}, [3]uint8{0, 0, 255}
+
+	tests := []struct {
+		name  string
+		cells map[[3]int][3]uint8
+		quads int
+		area  float64
+	}{
+		{"single voxel", map[[3]int][3]uint8{{0, 0, 0}: red}, 6, 6},
+		{"merged bar", map[[3]int][3]uint8{{0, 0, 0}: red, {1, 0, 0}: red, {2, 0, 0}: red}, 6, 14},
+		{"two colors", map[[3]int][3]uint8{{0, 0, 0}: red, {1, 0, 0}: blue}, 10, 10},
+		{"slab", map[[3]int][3]uint8{{0, 0, 0}: red, {1, 0, 0}: red, {0, 0, 1}: red, {1, 0, 1}: red}, 6, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vg := NewVoxelGrid(3, 2, 2)
+			for p, color := range tt.cells {
+				vg.SetVoxel(p[0], p[1], p[2], color)
+			}
+			mesh := GreedyMesh(vg)
+			if len(mesh.Faces) != tt.quads {
+				t.Errorf("got %d quads, want %d", len(mesh.Faces), tt.quads)
+			}
+			var area float64
+			for _, face := range mesh.Faces {
+				area += quadArea(t, mesh, face)
+			}
+			if area != tt.area {
+				t.Errorf("surface area = %g, want %g", area, tt.area)
+			}
+		})
+	}
+}
+
+func TestGreedyMeshColors(t *testing.T) {
+	vg := NewVoxelGrid(2, 1, 1)
+	vg.SetVoxel(0, 0, 0, [3]uint8{255, 0, 0})
+	vg.SetVoxel(1, 0, 0, [3]uint8{0, 0, 255})
+	mesh := GreedyMesh(vg)
+	if !mesh.HasVertexColors {
+		t.Fatal("HasVertexColors not set")
+	}
+	for _, face := range mesh.Faces {
+		// No faces lie between the voxels, so each face is on one side of x = 1
+		var centerX float64
+		for _, i := range face.VertexIndices {
+			centerX += mesh.Vertices[i].Position[0] / 4
+		}
+		want := [3]float64{1, 0, 0}
+		if centerX > 1 {
+			want = [3]float64{0, 0, 1}
+		}
+		for _, i := range face.VertexIndices {
+			if mesh.Vertices[i].Color != want {
+				t.Errorf("face %v has color %v, want %v", face.VertexIndices, mesh.Vertices[i].Color, want)
+			}
+		}
+	}
+}
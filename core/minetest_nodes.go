@@ -0,0 +1,81 @@
+package core
+
+// minetestColors maps dye colors to Minetest Game's wool colors. Light blue has
+// no counterpart and is left out.
+var minetestColors = map[string]string{
+	"white": "white", "orange": "orange", "magenta": "magenta", "yellow": "yellow",
+	"lime": "green", "pink": "pink", "gray": "dark_grey", "light_gray": "grey",
+	"cyan": "cyan", "purple": "violet", "blue": "blue", "brown": "brown",
+	"green": "dark_green", "red": "red", "black": "black",
+}
+
+// minetestNodes maps Minecraft block IDs to the nearest Minetest Game (or Luanti)
+// nodes. It covers full solid blocks with a close counterpart; palette entries
+// missing from it, and without a "minetest_node" metadata entry, are not used
+// by the Minetest exporter.
+var minetestNodes = buildMinetestNodes()
+
+func buildMinetestNodes() map[string]string {
+	nodes := map[string]string{
+		"minecraft:air":               "air",
+		"minecraft:stone":             "default:stone",
+		"minecraft:smooth_stone":      "default:stone_block",
+		"minecraft:cobblestone":       "default:cobble",
+		"minecraft:mossy_cobblestone": "default:mossycobble",
+		"minecraft:stone_bricks":      "default:stonebrick",
+		"minecraft:dirt":              "default:dirt",
+		"minecraft:grass_block":       "default:dirt_with_grass",
+		"minecraft:podzol":            "default:dirt_with_coniferous_litter",
+		"minecraft:sand":              "default:sand",
+		"minecraft:gravel":            "default:gravel",
+		"minecraft:clay":              "default:clay",
+		"minecraft:sandstone":         "default:sandstone",
+		"minecraft:cut_sandstone":     "default:sandstonebrick",
+		"minecraft:snow_block":        "default:snowblock",
+		"minecraft:ice":               "default:ice",
+		"minecraft:obsidian":          "default:obsidian",
+		"minecraft:bricks":            "default:brick",
+		"minecraft:glass":             "default:glass",
+		"minecraft:bookshelf":         "default:bookshelf",
+		"minecraft:oak_planks":        "default:wood",
+		"minecraft:spruce_planks":     "default:pine_wood",
+		"minecraft:birch_planks":      "default:aspen_wood",
+		"minecraft:jungle_planks":     "default:junglewood",
+		"minecraft:acacia_planks":     "default:acacia_wood",
+		"minecraft:oak_log":           "default:tree",
+		"minecraft:spruce_log":        "default:pine_tree",
+		"minecraft:birch_log":         "default:aspen_tree",
+		"minecraft:jungle_log":        "default:jungletree",
+		"minecraft:acacia_log":        "default:acacia_tree",
+		"minecraft:oak_leaves":        "default:leaves",
+		"minecraft:coal_ore":          "default:stone_with_coal",
+		"minecraft:iron_ore":          "default:stone_with_iron",
+		"minecraft:gold_ore":          "default:stone_with_gold",
+		"minecraft:diamond_ore":       "default:stone_with_diamond",
+		"minecraft:coal_block":        "default:coalblock",
+		"minecraft:iron_block":        "default:steelblock",
+		"minecraft:gold_block":        "default:goldblock",
+		"minecraft:copper_block":      "default:copperblock",
+		"minecraft:diamond_block":     "default:diamondblock",
+		"minecraft:tnt":               "tnt:tnt",
+	}
+	for color, wool := range minetestColors {
+		nodes["minecraft:"+color+"_wool"] = "wool:" + wool
+	}
+	return nodes
+}
+
+// minetestNodeFor returns the Minetest node for a palette entry: its
+// "minetest_node" metadata entry, or the bundled mapping of its block ID,
+// ignoring the block state's properties.
+func minetestNodeFor(color *PaletteColor) (string, bool) {
+	if node, ok := color.Metadata["minetest_node"].(string); ok && node != "" {
+		return node, true
+	}
+	id := paletteBlockID(color)
+	if node, ok := minetestNodes[id]; ok {
+		return node, true
+	}
+	node, ok := minetestNodes[parseBlockState(id).Name]
+	return node, ok
+}
@@ -0,0 +1,159 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/Tnze/go-mc/nbt"
+)
+
+// NBT tag types written by nbtStream.
+const (
+	nbtEnd       = 0
+	nbtShort     = 2
+	nbtInt       = 3
+	nbtByteArray = 7
+	nbtString    = 8
+	nbtList      = 9
+	nbtCompound  = 10
+	nbtIntArray  = 11
+)
+
+// nbtZeros is written in chunks for runs of zero bytes, such as air in block data.
+var nbtZeros [4096]byte
+
+// nbtStream writes uncompressed NBT tag by tag, so large arrays can be written in
+// pieces instead of being held in memory whole. Write errors are sticky and
+// returned by flush.
+type nbtStream struct {
+	w       *bufio.Writer
+	scratch [binary.MaxVarintLen64]byte
+}
+
+// newNBTStream returns a stream writing to w through a buffer.
+func newNBTStream(w io.Writer) *nbtStream {
+	return &nbtStream{w: bufio.NewWriterSize(w, 64<<10)}
+}
+
+// tag writes a named tag header.
+func (s *nbtStream) tag(typ byte, name string) {
+	s.w.WriteByte(typ)
+	s.str(name)
+}
+
+// str writes a length-prefixed string payload.
+func (s *nbtStream) str(v string) {
+	s.u16(uint16(len(v)))
+	s.w.WriteString(v)
+}
+
+func (s *nbtStream) u16(v uint16) {
+	binary.BigEndian.PutUint16(s.scratch[:2], v)
+	s.w.Write(s.scratch[:2])
+}
+
+func (s *nbtStream) u32(v uint32) {
+	binary.BigEndian.PutUint32(s.scratch[:4], v)
+	s.w.Write(s.scratch[:4])
+}
+
+// beginCompound opens a named compound; endCompound closes it.
+func (s *nbtStream) beginCompound(name string) {
+	s.tag(nbtCompound, name)
+}
+
+func (s *nbtStream) endCompound() {
+	s.w.WriteByte(nbtEnd)
+}
+
+func (s *nbtStream) shortTag(name string, v int16) {
+	s.tag(nbtShort, name)
+	s.u16(uint16(v))
+}
+
+func (s *nbtStream) intTag(name string, v int32) {
+	s.tag(nbtInt, name)
+	s.u32(uint32(v))
+}
+
+func (s *nbtStream) stringTag(name, v string) {
+	s.tag(nbtString, name)
+	s.str(v)
+}
+
+func (s *nbtStream) intArrayTag(name string, v []int32) {
+	s.tag(nbtIntArray, name)
+	s.u32(uint32(len(v)))
+	for _, x := range v {
+		s.u32(uint32(x))
+	}
+}
+
+// beginList writes the header of a list of n elements of type typ; the caller then
+// writes their payloads, for compounds their entries followed by endCompound.
+func (s *nbtStream) beginList(name string, typ byte, n int) {
+	s.tag(nbtList, name)
+	s.w.WriteByte(typ)
+	s.u32(uint32(n))
+}
+
+// rawTag writes an already encoded tag payload under name.
+func (s *nbtStream) rawTag(name string, v nbt.RawMessage) {
+	s.tag(v.Type, name)
+	s.w.Write(v.Data)
+}
+
+// beginByteArray writes the header of a byte array of n bytes; the caller then
+// writes exactly n bytes with zeros and uvarint.
+func (s *nbtStream) beginByteArray(name string, n int) {
+	s.tag(nbtByteArray, name)
+	s.u32(uint32(n))
+}
+
+// zeros writes n zero bytes.
+func (s *nbtStream) zeros(n int) {
+	for n > 0 {
+		chunk := min(n, len(nbtZeros))
+		s.w.Write(nbtZeros[:chunk])
+		n -= chunk
+	}
+}
+
+// uvarint writes v as an unsigned varint.
+func (s *nbtStream) uvarint(v uint64) {
+	s.w.Write(binary.AppendUvarint(s.scratch[:0], v))
+}
+
+// flush writes out the buffer and returns the first write error.
+func (s *nbtStream) flush() error {
+	return s.w.Flush()
+}
+
+// uvarintLen returns the number of bytes v takes as an unsigned varint.
+func uvarintLen(v uint64) int {
+	n := 1
+	for ; v >= 0x80; v >>= 7 {
+		n++
+	}
+	return n
+}
+
+// sortedCompound is a compound tag whose entries are written sorted by name, where
+// the NBT encoder writes a plain map in map order, so output is the same from
+// run to run.
+type sortedCompound[V any] map[string]V
+
+func (c sortedCompound[V]) TagType() byte {
+	return nbt.TagCompound
+}
+
+func (c sortedCompound[V]) MarshalNBT(w io.Writer) error {
+	for _, key := range sortedKeys(c) {
+		if err := nbt.NewEncoder(w).Encode(c[key], key); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{nbt.TagEnd})
+	return err
+}
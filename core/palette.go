@@ -1,7 +1,14 @@
 package core
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"path"
+	"strconv"
+	"strings"
 
 	"github.com/vmihailenco/msgpack/v5"
 )
@@ -17,6 +24,8 @@ type PaletteColorData struct {
 	Name     string                 `msgpack:"name"`
 	RGB      [3]uint8               `msgpack:"rgb"`
 	LAB      [3]float64             `msgpack:"lab"`
+	Weight   float64                `msgpack:"weight,omitempty"`
+	Cost     float64                `msgpack:"cost,omitempty"`
 	Metadata map[string]interface{} `msgpack:"metadata,omitempty"`
 }
 
@@ -32,21 +41,25 @@ func ExportPalette(palette *Palette, w io.Writer) error {
 			Name:     color.Name,
 			RGB:      color.RGB,
 			LAB:      [3]float64{color.LAB.L, color.LAB.A, color.LAB.B},
+			Weight:   color.Weight,
+			Cost:     color.Cost,
 			Metadata: color.Metadata,
 		}
 	}
 	
 	encoder := msgpack.NewEncoder(w)
+	encoder.SetSortMapKeys(true) // Metadata maps, so the same palette encodes the same way
 	return encoder.Encode(&data)
 }
 
 // ImportPalette imports a palette from msgpack format.
 func ImportPalette(r io.Reader) (*Palette, error) {
 	var data PaletteData
-	decoder := msgpack.NewDecoder(r)
+	counter := &countingReader{r: r}
+	decoder := msgpack.NewDecoder(counter)
 	
 	if err := decoder.Decode(&data); err != nil {
-		return nil, err
+		return nil, &FormatError{Format: "palette", Offset: counter.n, Err: err}
 	}
 	
 	palette := &Palette{
@@ -58,6 +71,8 @@ func ImportPalette(r io.Reader) (*Palette, error) {
 			Name:     colorData.Name,
 			RGB:      colorData.RGB,
 			LAB:      LABColor{L: colorData.LAB[0], A: colorData.LAB[1], B: colorData.LAB[2]},
+			Weight:   colorData.Weight,
+			Cost:     colorData.Cost,
 			Metadata: colorData.Metadata,
 		}
 	}
@@ -65,6 +80,287 @@ func ImportPalette(r io.Reader) (*Palette, error) {
 	return palette, nil
 }
 
+// paletteJSON is the JSON form of a palette. It leaves out the CIELAB values,
+// which ImportPaletteJSON derives from the RGB colors so hand edits stay in sync.
+type paletteJSON struct {
+	Version string             `json:"version"`
+	Colors  []paletteJSONColor `json:"colors"`
+}
+
+type paletteJSONColor struct {
+	Name     string                 `json:"name,omitempty"`
+	RGB      hexRGB                 `json:"rgb"`
+	Weight   float64                `json:"weight,omitempty"`
+	Cost     float64                `json:"cost,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// hexRGB is a color written as "#rrggbb" in JSON. It also reads [r, g, b] arrays.
+type hexRGB [3]uint8
+
+func (c hexRGB) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2]))
+}
+
+func (c *hexRGB) UnmarshalJSON(data []byte) error {
+	var rgb [3]uint8
+	if err := json.Unmarshal(data, &rgb); err == nil {
+		*c = rgb
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("color must be \"#rrggbb\" or [r, g, b], got %s", data)
+	}
+	hex, ok := strings.CutPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if !ok || len(hex) != 6 || err != nil {
+		return fmt.Errorf("color must be \"#rrggbb\" or [r, g, b], got %q", s)
+	}
+	*c = hexRGB{uint8(v >> 16), uint8(v >> 8), uint8(v)}
+	return nil
+}
+
+// ExportPaletteJSON exports a palette as indented JSON, one object per color with
+// its name, "#rrggbb" color and metadata.
+func ExportPaletteJSON(palette *Palette, w io.Writer) error {
+	data := paletteJSON{
+		Version: "1.0",
+		Colors:  make([]paletteJSONColor, len(palette.Colors)),
+	}
+	for i, color := range palette.Colors {
+		data.Colors[i] = paletteJSONColor{Name: color.Name, RGB: hexRGB(color.RGB), Weight: color.Weight, Cost: color.Cost, Metadata: color.Metadata}
+	}
+	
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&data)
+}
+
+// ImportPaletteJSON imports a palette written by ExportPaletteJSON, computing the
+// CIELAB values from the RGB colors.
+func ImportPaletteJSON(r io.Reader) (*Palette, error) {
+	var data paletteJSON
+	counter := &countingReader{r: r}
+	if err := json.NewDecoder(counter).Decode(&data); err != nil {
+		return nil, &FormatError{Format: "palette", Offset: counter.n, Err: err}
+	}
+	
+	palette := &Palette{Colors: make([]PaletteColor, len(data.Colors))}
+	for i, colorData := range data.Colors {
+		palette.Colors[i] = PaletteColor{
+			Name:     colorData.Name,
+			RGB:      colorData.RGB,
+			LAB:      RGBToLAB(colorData.RGB),
+			Weight:   colorData.Weight,
+			Cost:     colorData.Cost,
+			Metadata: colorData.Metadata,
+		}
+	}
+	return palette, nil
+}
+
+// paletteCSVHeader names the columns of a CSV palette. Tags are separated by
+// spaces; block state properties go in the block ID ("minecraft:oak_slab[type=top]").
+var paletteCSVHeader = []string{"block_id", "red", "green", "blue", "tags"}
+
+// ExportPaletteCSV exports a palette as CSV with a header row, one block per line.
+// Only block IDs with their states, colors and tags are kept; per-face colors and
+// other metadata need the JSON or msgpack formats.
+func ExportPaletteCSV(palette *Palette, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(paletteCSVHeader); err != nil {
+		return err
+	}
+	for i := range palette.Colors {
+		color := &palette.Colors[i]
+		id := color.Name
+		if _, ok := color.Metadata["block_id"].(string); ok {
+			id = paletteBlockID(color)
+		}
+		record := []string{
+			id,
+			strconv.Itoa(int(color.RGB[0])),
+			strconv.Itoa(int(color.RGB[1])),
+			strconv.Itoa(int(color.RGB[2])),
+			strings.Join(metadataTags(color), " "),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportPaletteCSV imports a palette written by ExportPaletteCSV. The tags column
+// may be left out; the CIELAB values are computed from the colors.
+func ImportPaletteCSV(r io.Reader) (*Palette, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, &FormatError{Format: "palette", Offset: cr.InputOffset(), Msg: "missing CSV header", Err: err}
+	}
+	for i, name := range paletteCSVHeader[:4] {
+		if i >= len(header) || !strings.EqualFold(header[i], name) {
+			return nil, &FormatError{Format: "palette", Offset: 0,
+				Msg: fmt.Sprintf("CSV header must start with %s", strings.Join(paletteCSVHeader[:4], ","))}
+		}
+	}
+	
+	palette := &Palette{}
+	for {
+		offset := cr.InputOffset()
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &FormatError{Format: "palette", Offset: offset, Err: err}
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) < 4 || record[0] == "" {
+			return nil, &FormatError{Format: "palette", Offset: offset, Msg: fmt.Sprintf("line %d: expected a block ID and red, green and blue values", line)}
+		}
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(record[i+1], 10, 8)
+			if err != nil {
+				return nil, &FormatError{Format: "palette", Offset: offset, Msg: fmt.Sprintf("line %d: invalid %s value %q", line, paletteCSVHeader[i+1], record[i+1])}
+			}
+			rgb[i] = uint8(v)
+		}
+		color := PaletteColor{
+			Name:     record[0],
+			RGB:      rgb,
+			LAB:      RGBToLAB(rgb),
+			Metadata: map[string]interface{}{"block_id": record[0]},
+		}
+		if len(record) > 4 && strings.TrimSpace(record[4]) != "" {
+			color.Metadata["tags"] = strings.Fields(record[4])
+		}
+		palette.Colors = append(palette.Colors, color)
+	}
+	return palette, nil
+}
+
+// PaletteFilter selects palette entries by block ID, tag or block state property.
+//
+// A pattern is one of:
+//   - a block ID or glob ("stone", "*_wool", "minecraft:sand"); IDs without a
+//     namespace match any namespace
+//   - a tag prefixed with '#' ("#flammable"), matching the built-in tags listed by
+//     BlockTagNames and any tags in an entry's "tags" metadata
+//   - a block state property ("axis=y", "half=*"), matching entries whose
+//     "properties" metadata or bracketed block_id holds that value
+type PaletteFilter struct {
+	Include      []string // Keep only blocks matching one of these patterns (empty = all)
+	Exclude      []string // Drop blocks matching any of these patterns
+	SurvivalOnly bool     // Drop blocks tagged unobtainable in survival
+	OpaqueOnly   bool     // Drop blocks tagged translucent, which look different over what is behind them
+}
+
+// IsEmpty reports whether the filter keeps every block.
+func (f PaletteFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0 && !f.SurvivalOnly && !f.OpaqueOnly
+}
+
+// Apply returns a new palette holding the entries of palette selected by the filter.
+// It fails on malformed patterns, unknown tags and when no entries remain.
+func (f PaletteFilter) Apply(palette *Palette) (*Palette, error) {
+	exclude := append([]string{}, f.Exclude...)
+	if f.SurvivalOnly {
+		exclude = append(exclude, "#"+TagUnobtainable)
+	}
+	if f.OpaqueOnly {
+		exclude = append(exclude, "#"+TagTranslucent)
+	}
+	for _, pattern := range append(append([]string{}, f.Include...), exclude...) {
+		if err := validateBlockPattern(pattern, palette); err != nil {
+			return nil, err
+		}
+	}
+	
+	filtered := &Palette{}
+	for i := range palette.Colors {
+		color := &palette.Colors[i]
+		if len(f.Include) > 0 && !matchesBlock(color, f.Include) {
+			continue
+		}
+		if matchesBlock(color, exclude) {
+			continue
+		}
+		filtered.Colors = append(filtered.Colors, *color)
+	}
+	if len(filtered.Colors) == 0 {
+		return nil, fmt.Errorf("palette filter removed every block")
+	}
+	return filtered, nil
+}
+
+// validateBlockPattern checks a filter pattern's glob syntax and that a tag is
+// either built in or carried by some palette entry.
+func validateBlockPattern(pattern string, palette *Palette) error {
+	glob := pattern
+	if tag, ok := strings.CutPrefix(pattern, "#"); ok {
+		if _, builtin := blockTags[tag]; !builtin && !paletteHasTag(palette, tag) {
+			return fmt.Errorf("unknown block tag %q (built-in: %s)", pattern, strings.Join(BlockTagNames(), ", "))
+		}
+		return nil
+	}
+	if _, value, ok := strings.Cut(pattern, "="); ok {
+		glob = value
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return fmt.Errorf("invalid block pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// matchesBlock reports whether a palette entry matches any of the given patterns.
+func matchesBlock(color *PaletteColor, patterns []string) bool {
+	for _, pattern := range patterns {
+		if tag, ok := strings.CutPrefix(pattern, "#"); ok {
+			if hasBlockTag(color, tag) {
+				return true
+			}
+			continue
+		}
+		if key, value, ok := strings.Cut(pattern, "="); ok {
+			if actual, found := blockProperties(color)[key]; found {
+				if ok, _ := path.Match(value, actual); ok {
+					return true
+				}
+			}
+			continue
+		}
+		if matchesBlockID(color.Name, []string{pattern}) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesBlockID reports whether a block ID matches any of the given patterns.
+func matchesBlockID(id string, patterns []string) bool {
+	bare := id
+	if i := strings.LastIndex(id, ":"); i >= 0 {
+		bare = id[i+1:]
+	}
+	for _, pattern := range patterns {
+		target := id
+		if !strings.Contains(pattern, ":") {
+			target = bare
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // GenerateMinecraftPalette creates a palette from Minecraft block definitions.
 func GenerateMinecraftPalette(blocks []MinecraftBlock) *Palette {
 	palette := &Palette{
@@ -81,47 +377,20 @@ func GenerateMinecraftPalette(blocks []MinecraftBlock) *Palette {
 				"properties": block.Properties,
 			},
 		}
+		if len(block.Faces) > 0 {
+			faces := make(map[string][3]uint8, len(block.Faces))
+			for face, rgb := range block.Faces {
+				faces[string(face)] = rgb
+			}
+			palette.Colors[i].Metadata["face_colors"] = faces
+		}
+		if block.Translucency > 0 {
+			palette.Colors[i].Metadata["translucency"] = block.Translucency
+		}
+		if block.Noise > 0 {
+			palette.Colors[i].Metadata["noise"] = block.Noise
+		}
 	}
 	
 	return palette
 }
-
-// GetVanillaMinecraftBlocks returns a list of common vanilla Minecraft blocks with colors.
-// This is a basic set; users can extend or customize this.
-func GetVanillaMinecraftBlocks() []MinecraftBlock {
-	return []MinecraftBlock{
-		{ID: "minecraft:white_wool", RGB: [3]uint8{233, 236, 236}, Properties: map[string]string{}},
-		{ID: "minecraft:orange_wool", RGB: [3]uint8{240, 118, 19}, Properties: map[string]string{}},
-		{ID: "minecraft:magenta_wool", RGB: [3]uint8{189, 68, 179}, Properties: map[string]string{}},
-		{ID: "minecraft:light_blue_wool", RGB: [3]uint8{58, 175, 217}, Properties: map[string]string{}},
-		{ID: "minecraft:yellow_wool", RGB: [3]uint8{253, 221, 70}, Properties: map[string]string{}},
-		{ID: "minecraft:lime_wool", RGB: [3]uint8{112, 185, 25}, Properties: map[string]string{}},
-		{ID: "minecraft:pink_wool", RGB: [3]uint8{237, 141, 172}, Properties: map[string]string{}},
-		{ID: "minecraft:gray_wool", RGB: [3]uint8{62, 68, 71}, Properties: map[string]string{}},
-		{ID: "minecraft:light_gray_wool", RGB: [3]uint8{142, 142, 134}, Properties: map[string]string{}},
-		{ID: "minecraft:cyan_wool", RGB: [3]uint8{21, 137, 145}, Properties: map[string]string{}},
-		{ID: "minecraft:purple_wool", RGB: [3]uint8{121, 42, 172}, Properties: map[string]string{}},
-		{ID: "minecraft:blue_wool", RGB: [3]uint8{53, 57, 157}, Properties: map[string]string{}},
-		{ID: "minecraft:brown_wool", RGB: [3]uint8{114, 71, 40}, Properties: map[string]string{}},
-		{ID: "minecraft:green_wool", RGB: [3]uint8{85, 109, 27}, Properties: map[string]string{}},
-		{ID: "minecraft:red_wool", RGB: [3]uint8{160, 39, 34}, Properties: map[string]string{}},
-		{ID: "minecraft:black_wool", RGB: [3]uint8{20, 21, 25}, Properties: map[string]string{}},
-		// Concrete blocks
-		{ID: "minecraft:white_concrete", RGB: [3]uint8{207, 213, 214}, Properties: map[string]string{}},
-		{ID: "minecraft:orange_concrete", RGB: [3]uint8{224, 97, 1}, Properties: map[string]string{}},
-		{ID: "minecraft:magenta_concrete", RGB: [3]uint8{169, 48, 159}, Properties: map[string]string{}},
-		{ID: "minecraft:light_blue_concrete", RGB: [3]uint8{36, 137, 199}, Properties: map[string]string{}},
-		{ID: "minecraft:yellow_concrete", RGB: [3]uint8{240, 175, 21}, Properties: map[string]string{}},
-		{ID: "minecraft:lime_concrete", RGB: [3]uint8{94, 168, 24}, Properties: map[string]string{}},
-		{ID: "minecraft:pink_concrete", RGB: [3]uint8{213, 101, 143}, Properties: map[string]string{}},
-		{ID: "minecraft:gray_concrete", RGB: [3]uint8{54, 57, 61}, Properties: map[string]string{}},
-		{ID: "minecraft:light_gray_concrete", RGB: [3]uint8{125, 125, 115}, Properties: map[string]string{}},
-		{ID: "minecraft:cyan_concrete", RGB: [3]uint8{21, 119, 136}, Properties: map[string]string{}},
-		{ID: "minecraft:purple_concrete", RGB: [3]uint8{100, 32, 156}, Properties: map[string]string{}},
-		{ID: "minecraft:blue_concrete", RGB: [3]uint8{44, 46, 143}, Properties: map[string]string{}},
-		{ID: "minecraft:brown_concrete", RGB: [3]uint8{96, 59, 31}, Properties: map[string]string{}},
-		{ID: "minecraft:green_concrete", RGB: [3]uint8{73, 91, 36}, Properties: map[string]string{}},
-		{ID: "minecraft:red_concrete", RGB: [3]uint8{142, 32, 32}, Properties: map[string]string{}},
-		{ID: "minecraft:black_concrete", RGB: [3]uint8{8, 10, 15}, Properties: map[string]string{}},
-	}
-}
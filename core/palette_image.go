@@ -0,0 +1,226 @@
+package core
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"slices"
+)
+
+// Limits on the pixels and rounds GeneratePaletteFromImage spends.
+const (
+	paletteSamplePixels = 1 << 18 // Larger images are sampled on a grid
+	paletteKMeansRounds = 32
+)
+
+// GeneratePaletteFromColors builds an abstract palette, one entry per distinct
+// color in order, named by its "#rrggbb" hex form. Entries have no block ID, so
+// the palette suits the VOX path rather than Minecraft exports.
+func GeneratePaletteFromColors(colors [][3]uint8) (*Palette, error) {
+	palette := &Palette{}
+	seen := make(map[[3]uint8]bool, len(colors))
+	for _, rgb := range colors {
+		if seen[rgb] {
+			continue
+		}
+		seen[rgb] = true
+		palette.Colors = append(palette.Colors, PaletteColor{
+			Name: fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]),
+			RGB:  rgb,
+			LAB:  RGBToLAB(rgb),
+		})
+	}
+	if len(palette.Colors) == 0 {
+		return nil, fmt.Errorf("%w: no palette colors given", ErrInvalidConfig)
+	}
+	return palette, nil
+}
+
+// GeneratePaletteFromImage picks up to size colors representing an image by
+// k-means clustering of its pixels in CIELAB, most common first, as an
+// abstract palette like GeneratePaletteFromColors. Mostly transparent pixels
+// are ignored, and an image with no more than size distinct colors keeps them
+// all. The result is the same for the same image.
+func GeneratePaletteFromImage(img image.Image, size int) (*Palette, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("%w: palette size must be positive, got %d", ErrInvalidConfig, size)
+	}
+	counts := imageColorCounts(img)
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("%w: image has no opaque pixels", ErrInvalidConfig)
+	}
+	colors := colorsByCount(counts)
+	if len(colors) <= size {
+		return GeneratePaletteFromColors(colors)
+	}
+
+	labs, weights := countedLABs(colors, counts)
+	centers, clusterWeights, _ := kMeansLAB(labs, weights, size)
+
+	order := make([]int, len(centers))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case clusterWeights[a] > clusterWeights[b]:
+			return -1
+		case clusterWeights[a] < clusterWeights[b]:
+			return 1
+		}
+		return 0
+	})
+	result := make([][3]uint8, 0, len(centers))
+	for _, i := range order {
+		if clusterWeights[i] > 0 {
+			result = append(result, LABToRGB(centers[i]))
+		}
+	}
+	return GeneratePaletteFromColors(result)
+}
+
+// quantizeColors maps every counted color to one of at most k colors chosen by
+// k-means clustering in CIELAB, as GeneratePaletteFromImage chooses them.
+func quantizeColors(counts map[[3]uint8]int, k int) map[[3]uint8][3]uint8 {
+	colors := colorsByCount(counts)
+	remap := make(map[[3]uint8][3]uint8, len(colors))
+	if len(colors) <= k {
+		for _, rgb := range colors {
+			remap[rgb] = rgb
+		}
+		return remap
+	}
+	labs, weights := countedLABs(colors, counts)
+	centers, _, assign := kMeansLAB(labs, weights, k)
+	for i, rgb := range colors {
+		remap[rgb] = LABToRGB(centers[assign[i]])
+	}
+	return remap
+}
+
+// colorsByCount returns the counted colors, most common first, ties broken by
+// value so the order is the same on every run.
+func colorsByCount(counts map[[3]uint8]int) [][3]uint8 {
+	colors := make([][3]uint8, 0, len(counts))
+	for rgb := range counts {
+		colors = append(colors, rgb)
+	}
+	slices.SortFunc(colors, func(a, b [3]uint8) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return slices.Compare(a[:], b[:])
+	})
+	return colors
+}
+
+// countedLABs returns the CIELAB form of colors and their counts as weights.
+func countedLABs(colors [][3]uint8, counts map[[3]uint8]int) ([]LABColor, []float64) {
+	labs := make([]LABColor, len(colors))
+	weights := make([]float64, len(colors))
+	for i, rgb := range colors {
+		labs[i], weights[i] = RGBToLAB(rgb), float64(counts[rgb])
+	}
+	return labs, weights
+}
+
+// imageColorCounts counts the opaque pixels of each color in an image, sampling
+// at most paletteSamplePixels of them evenly.
+func imageColorCounts(img image.Image) map[[3]uint8]int {
+	bounds := img.Bounds()
+	step := 1
+	for bounds.Dx()/step*(bounds.Dy()/step) > paletteSamplePixels {
+		step++
+	}
+	counts := make(map[[3]uint8]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			// Undo the premultiplied alpha of RGBA
+			rgb := [3]uint8{
+				uint8(math.Round(float64(r) / float64(a) * 255)),
+				uint8(math.Round(float64(g) / float64(a) * 255)),
+				uint8(math.Round(float64(b) / float64(a) * 255)),
+			}
+			counts[rgb]++
+		}
+	}
+	return counts
+}
+
+// kMeansLAB clusters weighted colors into k clusters, returning each cluster's
+// center and total weight, and the cluster of every color. Centers start at the
+// heaviest color, then at the colors farthest from every chosen center,
+// weighted, so the result is deterministic; clusters can end up empty, with
+// zero weight.
+func kMeansLAB(labs []LABColor, weights []float64, k int) ([]LABColor, []float64, []int) {
+	centers := make([]LABColor, 0, k)
+	nearest := make([]float64, len(labs))
+	heaviest := 0
+	for i := range weights {
+		if weights[i] > weights[heaviest] {
+			heaviest = i
+		}
+		nearest[i] = math.Inf(1)
+	}
+	next := heaviest
+	for len(centers) < k {
+		center := labs[next]
+		centers = append(centers, center)
+		best := -1.0
+		for i := range labs {
+			nearest[i] = math.Min(nearest[i], labDistanceSq(labs[i], center))
+			if score := nearest[i] * weights[i]; score > best {
+				best, next = score, i
+			}
+		}
+		if best <= 0 {
+			break // Every color is a center already
+		}
+	}
+
+	assign := make([]int, len(labs))
+	clusterWeights := make([]float64, len(centers))
+	for round := 0; round < paletteKMeansRounds; round++ {
+		changed := false
+		for i := range labs {
+			closest, closestDist := 0, math.Inf(1)
+			for c := range centers {
+				if d := labDistanceSq(labs[i], centers[c]); d < closestDist {
+					closest, closestDist = c, d
+				}
+			}
+			if round == 0 || assign[i] != closest {
+				assign[i], changed = closest, true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([]LABColor, len(centers))
+		clear(clusterWeights)
+		for i, c := range assign {
+			w := weights[i]
+			sums[c].L += labs[i].L * w
+			sums[c].A += labs[i].A * w
+			sums[c].B += labs[i].B * w
+			clusterWeights[c] += w
+		}
+		for c := range centers {
+			if clusterWeights[c] > 0 {
+				centers[c] = LABColor{L: sums[c].L / clusterWeights[c], A: sums[c].A / clusterWeights[c], B: sums[c].B / clusterWeights[c]}
+			}
+		}
+	}
+	return centers, clusterWeights, assign
+}
+
+// labDistanceSq returns the squared Euclidean distance between two CIELAB colors.
+func labDistanceSq(a, b LABColor) float64 {
+	dl, da, db := a.L-b.L, a.A-b.A, a.B-b.B
+	return dl*dl + da*da + db*db
+}
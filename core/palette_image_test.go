@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestGeneratePaletteFromImage(t *testing.T) {
+	// Three bands of near-identical reds, greens and blues, red the widest
+	img := image.NewNRGBA(image.Rect(0, 0, 12, 4))
+	for x := 0; x < 12; x++ {
+		for y := 0; y < 4; y++ {
+			shift := uint8(y * 3)
+			switch {
+			case x < 6:
+				img.Set(x, y, color.NRGBA{200 + shift, 20, 20, 255})
+			case x < 10:
+				img.Set(x, y, color.NRGBA{20, 180 + shift, 20, 255})
+			default:
+				img.Set(x, y, color.NRGBA{20, 20, 190 + shift, 255})
+			}
+		}
+	}
+	img.Set(0, 0, color.NRGBA{255, 255, 255, 0}) // Transparent
+
+	palette, err := GeneratePaletteFromImage(img, 3)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(palette.Colors) != 3 {
+		t.Fatalf("%d colors, want 3", len(palette.Colors))
+	}
+	for i, want := range []int{0, 1, 2} { // Red, green, blue by pixel count
+		rgb := palette.Colors[i].RGB
+		if rgb[want] < 150 || palette.Colors[i].Metadata["block_id"] != nil {
+			t.Errorf("color %d = %v (%s), want mostly channel %d", i, rgb, palette.Colors[i].Name, want)
+		}
+	}
+
+	again, _ := GeneratePaletteFromImage(img, 3)
+	for i := range again.Colors {
+		if again.Colors[i].RGB != palette.Colors[i].RGB {
+			t.Errorf("second run gave %v, want %v", again.Colors[i].RGB, palette.Colors[i].RGB)
+		}
+	}
+
+	// Fewer distinct colors than asked for are kept as they are
+	all, err := GeneratePaletteFromImage(img, 64)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(all.Colors) != 12 {
+		t.Errorf("%d colors, want all 12", len(all.Colors))
+	}
+
+	if _, err := GeneratePaletteFromImage(img, 0); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for size 0, got %v", err)
+	}
+	if _, err := GeneratePaletteFromImage(image.NewNRGBA(image.Rect(0, 0, 2, 2)), 4); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for a transparent image, got %v", err)
+	}
+}
+
+func TestGeneratePaletteFromColors(t *testing.T) {
+	palette, err := GeneratePaletteFromColors([][3]uint8{{255, 0, 0}, {0, 128, 255}, {255, 0, 0}})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(palette.Colors) != 2 || palette.Colors[1].Name != "#0080ff" {
+		t.Errorf("palette = %+v", palette.Colors)
+	}
+	if _, err := GeneratePaletteFromColors(nil); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Conflict policies of MergePalettes, applied when palettes list the same block
+// with different colors.
+const (
+	MergeKeepFirst = "first" // Keep the entry seen first (the default)
+	MergeKeepLast  = "last"  // Later palettes override earlier ones
+	MergeError     = "error" // Fail on the first conflict
+)
+
+// mergePolicies lists the accepted MergePalettes policies besides "".
+var mergePolicies = []string{MergeKeepFirst, MergeKeepLast, MergeError}
+
+// MergePolicies returns the conflict policies accepted by MergePalettes.
+func MergePolicies() []string {
+	return append([]string(nil), mergePolicies...)
+}
+
+// paletteKey identifies the block of a palette entry: its block ID with the
+// block state in canonical order, or its name for entries without a block ID.
+// Entries with block entity templates, such as heads with different skins, are
+// told apart by their templates too.
+func paletteKey(color *PaletteColor) string {
+	key := color.Name
+	if _, ok := color.Metadata["block_id"].(string); ok {
+		key = blockStateKey(parseBlockState(paletteBlockID(color)))
+	}
+	if snbt := paletteBlockEntity(color); snbt != "" {
+		key += snbt
+	}
+	return key
+}
+
+// MergePalettes combines palettes into a new one, in order, keeping one entry
+// per block. Entries for the same block with the same color are duplicates and
+// dropped; with different colors, policy decides which one stays. A replaced
+// entry keeps the position of the first one.
+func MergePalettes(policy string, palettes ...*Palette) (*Palette, error) {
+	if policy == "" {
+		policy = MergeKeepFirst
+	}
+	if !containsString(mergePolicies, policy) {
+		return nil, fmt.Errorf("%w: unknown merge policy %q (supported: %s)",
+			ErrInvalidConfig, policy, strings.Join(mergePolicies, ", "))
+	}
+
+	merged := &Palette{}
+	index := make(map[string]int)
+	for _, palette := range palettes {
+		for _, color := range palette.Colors {
+			key := paletteKey(&color)
+			i, ok := index[key]
+			if !ok {
+				index[key] = len(merged.Colors)
+				merged.Colors = append(merged.Colors, color)
+				continue
+			}
+			if merged.Colors[i].RGB == color.RGB {
+				continue
+			}
+			switch policy {
+			case MergeKeepLast:
+				merged.Colors[i] = color
+			case MergeError:
+				old := merged.Colors[i].RGB
+				return nil, fmt.Errorf("palettes disagree on %s: #%02x%02x%02x and #%02x%02x%02x",
+					key, old[0], old[1], old[2], color.RGB[0], color.RGB[1], color.RGB[2])
+			}
+		}
+	}
+	return merged, nil
+}
+
+// PaletteDiff lists how one palette differs from another, by block.
+type PaletteDiff struct {
+	Added   []PaletteColor  // Blocks only in the second palette
+	Removed []PaletteColor  // Blocks only in the first palette
+	Changed []PaletteChange // Blocks in both with different colors
+}
+
+// PaletteChange is a block whose color differs between two palettes.
+type PaletteChange struct {
+	Old PaletteColor // Entry in the first palette
+	New PaletteColor // Entry in the second palette
+}
+
+// DiffPalettes compares palette b against a block by block, such as a resource
+// pack's palette against vanilla, listing entries in the order of the palette
+// they come from. Within each palette the first entry for a block counts.
+func DiffPalettes(a, b *Palette) PaletteDiff {
+	aIndex := firstEntries(a)
+	bIndex := firstEntries(b)
+
+	var diff PaletteDiff
+	for i, color := range a.Colors {
+		key := paletteKey(&color)
+		if aIndex[key] != i {
+			continue
+		}
+		j, ok := bIndex[key]
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, color)
+		case b.Colors[j].RGB != color.RGB:
+			diff.Changed = append(diff.Changed, PaletteChange{Old: color, New: b.Colors[j]})
+		}
+	}
+	for i, color := range b.Colors {
+		key := paletteKey(&color)
+		if _, ok := aIndex[key]; !ok && bIndex[key] == i {
+			diff.Added = append(diff.Added, color)
+		}
+	}
+	return diff
+}
+
+// firstEntries maps the block of each palette entry to its first index.
+func firstEntries(palette *Palette) map[string]int {
+	index := make(map[string]int, len(palette.Colors))
+	for i := range palette.Colors {
+		key := paletteKey(&palette.Colors[i])
+		if _, ok := index[key]; !ok {
+			index[key] = i
+		}
+	}
+	return index
+}
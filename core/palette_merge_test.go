@@ -0,0 +1,86 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMergePalettes(t *testing.T) {
+	vanilla := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:stone", RGB: [3]uint8{125, 125, 125}},
+		{ID: "minecraft:oak_slab", RGB: [3]uint8{162, 130, 78}, Properties: map[string]string{"type": "top"}},
+	})
+	pack := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:stone", RGB: [3]uint8{110, 110, 115}},
+		{ID: "minecraft:oak_slab[type=top]", RGB: [3]uint8{162, 130, 78}}, // Same block and color
+		{ID: "create:andesite_casing", RGB: [3]uint8{140, 138, 130}},
+	})
+
+	tests := []struct {
+		policy string
+		stone  [3]uint8
+	}{
+		{"", [3]uint8{125, 125, 125}},
+		{MergeKeepLast, [3]uint8{110, 110, 115}},
+	}
+	for _, tt := range tests {
+		merged, err := MergePalettes(tt.policy, vanilla, pack)
+		if err != nil {
+			t.Fatalf("policy %q: %v", tt.policy, err)
+		}
+		if len(merged.Colors) != 3 {
+			t.Fatalf("policy %q: %d colors, want 3", tt.policy, len(merged.Colors))
+		}
+		if merged.Colors[0].RGB != tt.stone {
+			t.Errorf("policy %q: stone = %v, want %v", tt.policy, merged.Colors[0].RGB, tt.stone)
+		}
+		if got := merged.Colors[2].Name; got != "create:andesite_casing" {
+			t.Errorf("policy %q: last entry = %q", tt.policy, got)
+		}
+	}
+
+	if _, err := MergePalettes(MergeError, vanilla, pack); err == nil {
+		t.Error("MergeError accepted conflicting stone colors")
+	}
+	if _, err := MergePalettes(MergeError, vanilla, vanilla); err != nil {
+		t.Errorf("MergeError rejected identical duplicates: %v", err)
+	}
+	if _, err := MergePalettes("newest", vanilla); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unknown policy, got %v", err)
+	}
+
+	// Heads with different skins are different entries
+	heads := &Palette{}
+	for _, skin := range []string{"a", "b"} {
+		heads.Colors = append(heads.Colors, PaletteColor{RGB: [3]uint8{skin[0], 0, 0}, Metadata: map[string]interface{}{
+			"block_id": "minecraft:player_head", "block_entity": `{profile:{name:"` + skin + `"}}`,
+		}})
+	}
+	if merged, err := MergePalettes(MergeError, vanilla, heads); err != nil || len(merged.Colors) != 4 {
+		t.Errorf("merging heads gave %v, %v; want 4 entries", merged, err)
+	}
+}
+
+func TestDiffPalettes(t *testing.T) {
+	vanilla := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:stone", RGB: [3]uint8{125, 125, 125}},
+		{ID: "minecraft:dirt", RGB: [3]uint8{134, 96, 67}},
+		{ID: "minecraft:sand", RGB: [3]uint8{219, 207, 163}},
+	})
+	pack := GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:stone", RGB: [3]uint8{110, 110, 115}},
+		{ID: "minecraft:dirt", RGB: [3]uint8{134, 96, 67}},
+		{ID: "create:andesite_casing", RGB: [3]uint8{140, 138, 130}},
+	})
+
+	diff := DiffPalettes(vanilla, pack)
+	if len(diff.Added) != 1 || diff.Added[0].Name != "create:andesite_casing" {
+		t.Errorf("added = %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "minecraft:sand" {
+		t.Errorf("removed = %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].New.RGB != [3]uint8{110, 110, 115} {
+		t.Errorf("changed = %+v", diff.Changed)
+	}
+}
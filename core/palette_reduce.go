@@ -0,0 +1,60 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// ReducePalette returns a palette of at most maxColors entries of palette that
+// represent it best, for palettes too large to match against quickly, such as
+// every block of a jar extraction. Entries are clustered by k-means in CIELAB,
+// and each cluster keeps its medoid: the entry closest to the others in it.
+// Entries keep their order, and a palette within maxColors is returned as a
+// copy.
+func ReducePalette(palette *Palette, maxColors int) (*Palette, error) {
+	if maxColors < 1 {
+		return nil, fmt.Errorf("%w: reduced palette size must be positive, got %d", ErrInvalidConfig, maxColors)
+	}
+	if palette == nil || len(palette.Colors) == 0 {
+		return nil, fmt.Errorf("%w: empty palette", ErrInvalidConfig)
+	}
+	if len(palette.Colors) <= maxColors {
+		return &Palette{Colors: append([]PaletteColor(nil), palette.Colors...)}, nil
+	}
+
+	labs := make([]LABColor, len(palette.Colors))
+	weights := make([]float64, len(palette.Colors))
+	for i := range palette.Colors {
+		labs[i], weights[i] = palette.Colors[i].LAB, 1
+	}
+	centers, _, assign := kMeansLAB(labs, weights, maxColors)
+
+	members := make([][]int, len(centers))
+	for i, c := range assign {
+		members[c] = append(members[c], i)
+	}
+	keep := make([]bool, len(palette.Colors))
+	for _, cluster := range members {
+		medoid, best := -1, math.Inf(1)
+		for _, i := range cluster {
+			sum := 0.0
+			for _, j := range cluster {
+				sum += labDistanceSq(labs[i], labs[j])
+			}
+			if sum < best {
+				medoid, best = i, sum
+			}
+		}
+		if medoid >= 0 {
+			keep[medoid] = true
+		}
+	}
+
+	reduced := &Palette{}
+	for i := range palette.Colors {
+		if keep[i] {
+			reduced.Colors = append(reduced.Colors, palette.Colors[i])
+		}
+	}
+	return reduced, nil
+}
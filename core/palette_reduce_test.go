@@ -0,0 +1,44 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReducePalette(t *testing.T) {
+	entry := func(id string, rgb [3]uint8) PaletteColor {
+		return PaletteColor{Name: id, RGB: rgb, LAB: RGBToLAB(rgb), Metadata: map[string]interface{}{"block_id": id}}
+	}
+	// Three near-identical whites, two reds and a black
+	palette := &Palette{Colors: []PaletteColor{
+		entry("minecraft:white_wool", [3]uint8{234, 236, 237}),
+		entry("minecraft:red_wool", [3]uint8{161, 39, 35}),
+		entry("minecraft:snow_block", [3]uint8{249, 254, 254}),
+		entry("minecraft:black_wool", [3]uint8{21, 21, 26}),
+		entry("minecraft:white_concrete", [3]uint8{207, 213, 214}),
+		entry("minecraft:red_concrete", [3]uint8{142, 33, 33}),
+		entry("minecraft:white_concrete_powder", [3]uint8{226, 227, 228}),
+	}}
+
+	reduced, err := ReducePalette(palette, 3)
+	if err != nil {
+		t.Fatalf("reduce: %v", err)
+	}
+	var ids []string
+	for i := range reduced.Colors {
+		ids = append(ids, paletteBlockID(&reduced.Colors[i]))
+	}
+	// White wool sits among the whites; of two reds the first is kept
+	want := []string{"minecraft:white_wool", "minecraft:red_wool", "minecraft:black_wool"}
+	if len(ids) != len(want) || ids[0] != want[0] || ids[1] != want[1] || ids[2] != want[2] {
+		t.Errorf("reduced to %v, want %v", ids, want)
+	}
+
+	same, err := ReducePalette(palette, 10)
+	if err != nil || len(same.Colors) != len(palette.Colors) {
+		t.Errorf("small palette reduced to %d colors (%v), want all %d", len(same.Colors), err, len(palette.Colors))
+	}
+	if _, err := ReducePalette(palette, 0); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
@@ -1,148 +1,763 @@
 package core
 
-import "io"
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
 
 // Pipeline represents the complete conversion pipeline.
+// Build one with NewPipeline; the stage methods taking a PipelineConfig also work on
+// pipelines wired by hand. A pipeline can run any number of conversions one after
+// another, reusing its components and pooled scratch buffers; use one pipeline per
+// goroutine for concurrent conversions.
 type Pipeline struct {
 	Importer  MeshImporter
 	Voxelizer Voxelizer
 	Matcher   ColorMatcher
+	Exporter  GridExporter   // Used by Convert and ExportGrid
+	Config    PipelineConfig // Used by Convert and ExportGrid
 }
 
 // PipelineConfig holds all configuration for the conversion pipeline.
 type PipelineConfig struct {
-	Voxelization VoxelizationConfig
-	Dithering    DitherConfig
-	Palette      *Palette
+	Voxelization     VoxelizationConfig
+	Transform        TransformConfig // Scale, rotation and translation applied to meshes before voxelization
+	Morphology       []MorphStep     // Morphology run on voxelized grids in order, such as closing pinholes
+	MinIsland        int             // Then delete components of fewer voxels, except the largest (0 = keep all)
+	PostScale        float64         // Rescales voxelized grids, such as by 2 or 0.5, without voxelizing again (0 = 1)
+	AmbientOcclusion float64         // Then darkens crevices of voxelized grids by up to this much, from 0 (off) to 1
+	Dithering        DitherConfig
+	Schematic        SchematicConfig
+	Function         FunctionConfig
+	Palette          *Palette
+	MatchWeights     MatchWeights      // Lightness and color weights of a WeightedMatcher (zero = equal)
+	Placement        *PlacementOptions // Validates block placement after matching (nil = skip)
+	Detail           string            // Surface detail pass run by block exporters (DetailNone, DetailStairsSlabs)
+	DetailRegions    []DetailRegion    // Parts of meshes voxelized finer, resolving their voxels into stairs and slabs
+	LightBlocks      bool              // Match emissive voxels against the palette's #light_source blocks only
+	Progress         ProgressReporter  // Optional progress callback for all stages
 }
 
 // MeshToVoxelGrid converts a mesh directly to a voxel grid.
 func (p *Pipeline) MeshToVoxelGrid(meshReader io.Reader, config PipelineConfig) (*VoxelGrid, error) {
-	// Import mesh
-	mesh, err := p.Importer.Import(meshReader)
+	return p.MeshToVoxelGridCtx(context.Background(), meshReader, config)
+}
+
+// MeshToVoxelGridCtx is like MeshToVoxelGrid but stops early when ctx is done.
+func (p *Pipeline) MeshToVoxelGridCtx(ctx context.Context, meshReader io.Reader, config PipelineConfig) (*VoxelGrid, error) {
+	mesh, err := p.ImportMeshCtx(ctx, meshReader, config)
 	if err != nil {
 		return nil, err
 	}
 	
-	// Voxelize
-	voxelGrid, err := p.Voxelizer.Voxelize(mesh, config.Voxelization)
+	return p.VoxelizeMeshCtx(ctx, mesh, config)
+}
+
+// ImportMeshCtx runs only the import stage of the pipeline.
+// Progress is reported in bytes read; the total is known when meshReader has a Len method.
+func (p *Pipeline) ImportMeshCtx(ctx context.Context, meshReader io.Reader, config PipelineConfig) (*Mesh, error) {
+	var total int64
+	if sized, ok := meshReader.(interface{ Len() int }); ok {
+		total = int64(sized.Len())
+	}
+	tracker := startStage(config.Progress, StageImport, total)
+	mesh, err := importMesh(ctx, p.Importer, &progressReader{r: meshReader, tracker: tracker})
 	if err != nil {
 		return nil, err
 	}
+	tracker.finish()
+	
+	return mesh, nil
+}
+
+// VoxelizeMeshCtx runs only the voxelize stage of the pipeline, on the mesh
+// turned to config.Voxelization.UpAxis and moved by config.Transform, refines
+// config.DetailRegions, then runs config.Morphology on the grid, removes islands smaller than config.MinIsland,
+// rescales it by config.PostScale and bakes in config.AmbientOcclusion.
+func (p *Pipeline) VoxelizeMeshCtx(ctx context.Context, mesh *Mesh, config PipelineConfig) (*VoxelGrid, error) {
+	return p.voxelizePlaced(ctx, placeMesh(mesh, config), config)
+}
+
+// VoxelizeFramesCtx is like VoxelizeMeshCtx for a series of meshes, such as the
+// frames of an animation. They are voxelized within the bounds of them all, so
+// the grids share their size, origin and scale and line up frame to frame.
+func (p *Pipeline) VoxelizeFramesCtx(ctx context.Context, meshes []*Mesh, config PipelineConfig) ([]*VoxelGrid, error) {
+	placed := make([]*Mesh, len(meshes))
+	var bounds BoundingBox
+	for i, mesh := range meshes {
+		// Copy the placed mesh so its bounds can be widened
+		frame := *placeMesh(mesh, config)
+		frame.CalculateBounds()
+		if len(frame.Vertices) > 0 {
+			if i == 0 {
+				bounds = frame.Bounds
+			}
+			for a := 0; a < 3; a++ {
+				bounds.Min[a] = math.Min(bounds.Min[a], frame.Bounds.Min[a])
+				bounds.Max[a] = math.Max(bounds.Max[a], frame.Bounds.Max[a])
+			}
+		}
+		placed[i] = &frame
+	}
 	
-	return voxelGrid, nil
+	grids := make([]*VoxelGrid, len(placed))
+	for i, frame := range placed {
+		frame.Bounds = bounds
+		vg, err := p.voxelizePlaced(ctx, frame, config)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i, err)
+		}
+		grids[i] = vg
+	}
+	return grids, nil
+}
+
+// voxelizePlaced runs the voxelize stage and the grid clean-up steps on a mesh
+// already placed by placeMesh.
+func (p *Pipeline) voxelizePlaced(ctx context.Context, mesh *Mesh, config PipelineConfig) (*VoxelGrid, error) {
+	if config.Voxelization.Progress == nil {
+		config.Voxelization.Progress = config.Progress
+	}
+	voxelization := config.Voxelization
+	if len(config.DetailRegions) > 0 {
+		voxelization.Hollow = 0 // Regions need the interior; hollow once they are refined
+	}
+	vg, err := voxelizeMesh(ctx, p.Voxelizer, mesh, voxelization)
+	if err != nil {
+		return nil, err
+	}
+	for _, region := range config.DetailRegions {
+		if err := p.refineRegion(ctx, vg, mesh, region, voxelization); err != nil {
+			return nil, err
+		}
+	}
+	if len(config.DetailRegions) > 0 && config.Voxelization.Hollow > 0 {
+		if _, err := vg.HollowCtx(ctx, config.Voxelization.Hollow); err != nil {
+			return nil, err
+		}
+	}
+	for _, step := range config.Morphology {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if vg, err = vg.Morph(step); err != nil {
+			return nil, err
+		}
+	}
+	if config.MinIsland > 0 {
+		vg.RemoveIslands(config.MinIsland)
+	}
+	if vg, err = vg.Rescale(config.PostScale); err != nil {
+		return nil, err
+	}
+	if config.AmbientOcclusion > 0 {
+		if _, err := vg.AmbientOcclusionCtx(ctx, config.AmbientOcclusion); err != nil {
+			return nil, err
+		}
+	}
+	return vg, nil
 }
 
 // MeshToVOX converts a mesh to VOX format.
 func (p *Pipeline) MeshToVOX(meshReader io.Reader, voxWriter io.Writer, config PipelineConfig) error {
-	voxelGrid, err := p.MeshToVoxelGrid(meshReader, config)
+	return p.MeshToVOXCtx(context.Background(), meshReader, voxWriter, config)
+}
+
+// MeshToVOXCtx is like MeshToVOX but stops early when ctx is done.
+func (p *Pipeline) MeshToVOXCtx(ctx context.Context, meshReader io.Reader, voxWriter io.Writer, config PipelineConfig) error {
+	voxelGrid, err := p.MeshToVoxelGridCtx(ctx, meshReader, config)
 	if err != nil {
 		return err
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	
 	exporter := NewVOXExporter()
-	return exporter.Export(voxelGrid, voxWriter)
+	exporter.Progress = config.Progress
+	return exportGrid(ctx, exporter, voxelGrid, voxWriter)
+}
+
+// Convert imports and voxelizes a mesh, matches it against the palette when one is
+// set, and writes it with the pipeline's exporter, all using p.Config.
+func (p *Pipeline) Convert(meshReader io.Reader, w io.Writer) error {
+	return p.ConvertCtx(context.Background(), meshReader, w)
+}
+
+// ConvertCtx is like Convert but stops early when ctx is done.
+func (p *Pipeline) ConvertCtx(ctx context.Context, meshReader io.Reader, w io.Writer) error {
+	voxelGrid, err := p.MeshToVoxelGridCtx(ctx, meshReader, p.Config)
+	if err != nil {
+		return err
+	}
+	
+	return p.ExportGridCtx(ctx, voxelGrid, w)
+}
+
+// ExportGrid matches a voxel grid against the palette when one is set and writes it
+// with the pipeline's exporter, using p.Config.
+func (p *Pipeline) ExportGrid(vg *VoxelGrid, w io.Writer) error {
+	return p.ExportGridCtx(context.Background(), vg, w)
+}
+
+// ExportGridCtx is like ExportGrid but stops early when ctx is done.
+func (p *Pipeline) ExportGridCtx(ctx context.Context, vg *VoxelGrid, w io.Writer) error {
+	if p.Exporter == nil {
+		return fmt.Errorf("pipeline has no exporter")
+	}
+	
+	vg, err := p.MatchColorsCtx(ctx, vg, p.Config)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	
+	return exportGrid(ctx, p.Exporter, vg, w)
+}
+
+// MatchColorsCtx replaces every voxel color with its closest palette color, dithering
+// if enabled, then runs ValidatePlacement when config.Placement is set. The grid is
+// returned unchanged when no palette or matcher is set.
+func (p *Pipeline) MatchColorsCtx(ctx context.Context, vg *VoxelGrid, config PipelineConfig) (*VoxelGrid, error) {
+	if config.Palette == nil || p.Matcher == nil {
+		return vg, nil
+	}
+	if weighted, ok := p.Matcher.(WeightedMatcher); ok {
+		weighted.SetWeights(config.MatchWeights)
+	}
+	p.Matcher.SetPalette(config.Palette)
+	
+	// Blocks whose faces differ are matched by the face each voxel shows, and
+	// textures by how noisy the voxel's surroundings are
+	m := voxelMatchers{labs: make(map[[3]uint8]LABColor)}
+	if faces, ok := p.Matcher.(FaceMatcher); ok && paletteHasFaceColors(config.Palette) {
+		m.faces = faces
+	}
+	if noise, ok := p.Matcher.(NoiseMatcher); ok && paletteHasNoise(config.Palette) {
+		m.noise = noise
+	}
+	if config.LightBlocks && gridHasEmission(vg) {
+		m.light = lightMatcher(config.Palette, config.MatchWeights)
+	}
+	
+	var err error
+	tracker := startStage(config.Progress, StageMatch, int64(vg.Count()))
+	if config.Dithering.Enabled {
+		if size := bayerSize(config.Dithering.Algorithm); size > 0 {
+			vg, err = p.applyOrderedDithering(ctx, vg, size, m, tracker)
+		} else {
+			vg, err = p.applyDithering(ctx, vg, config.Dithering, m, tracker)
+		}
+	} else {
+		vg, err = p.applyColorMatching(ctx, vg, m, tracker)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tracker.finish()
+	
+	if config.Placement != nil {
+		opts := *config.Placement
+		if opts.Matcher == nil {
+			opts.Matcher = p.Matcher
+		}
+		issues, err := ValidatePlacement(vg, config.Palette, opts)
+		if err != nil {
+			return nil, err
+		}
+		if opts.OnIssues != nil {
+			opts.OnIssues(issues)
+		}
+	}
+	
+	return vg, nil
 }
 
 // VoxelGridToSchematic converts a voxel grid to Minecraft schematic.
 func (p *Pipeline) VoxelGridToSchematic(vg *VoxelGrid, schematicWriter io.Writer, config PipelineConfig) error {
+	return p.VoxelGridToSchematicCtx(context.Background(), vg, schematicWriter, config)
+}
+
+// VoxelGridToSchematicCtx is like VoxelGridToSchematic but stops early when ctx is done.
+func (p *Pipeline) VoxelGridToSchematicCtx(ctx context.Context, vg *VoxelGrid, schematicWriter io.Writer, config PipelineConfig) error {
 	// Apply color matching and dithering
-	if config.Palette != nil && p.Matcher != nil {
-		p.Matcher.SetPalette(config.Palette)
-		
-		// Apply dithering if enabled
-		if config.Dithering.Enabled {
-			vg = p.applyDithering(vg, config.Dithering)
-		} else {
-			// Simple color matching without dithering
-			vg = p.applyColorMatching(vg)
-		}
+	vg, err := p.MatchColorsCtx(ctx, vg, config)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 	
 	// Export to schematic
-	exporter := NewSchematicExporter("1.13+")
-	return exporter.Export(vg, config.Palette, config.Dithering, schematicWriter)
+	exporter := NewSchematicExporter(config.Schematic.Version)
+	exporter.DataVersion = config.Schematic.DataVersion
+	exporter.Detail = config.Detail
+	exporter.AirMode = config.Schematic.AirMode
+	exporter.PasteOrigin = config.Schematic.PasteOrigin
+	exporter.Offset = config.Schematic.Offset
+	exporter.Rotation = config.Schematic.Rotation
+	exporter.Progress = config.Progress
+	return exporter.Export(vg, config.Palette, config.Dithering, &contextWriter{ctx: ctx, w: schematicWriter})
 }
 
 // MeshToSchematic converts a mesh directly to Minecraft schematic.
 func (p *Pipeline) MeshToSchematic(meshReader io.Reader, schematicWriter io.Writer, config PipelineConfig) error {
-	voxelGrid, err := p.MeshToVoxelGrid(meshReader, config)
+	return p.MeshToSchematicCtx(context.Background(), meshReader, schematicWriter, config)
+}
+
+// MeshToSchematicCtx is like MeshToSchematic but stops early when ctx is done.
+func (p *Pipeline) MeshToSchematicCtx(ctx context.Context, meshReader io.Reader, schematicWriter io.Writer, config PipelineConfig) error {
+	voxelGrid, err := p.MeshToVoxelGridCtx(ctx, meshReader, config)
 	if err != nil {
 		return err
 	}
 	
-	return p.VoxelGridToSchematic(voxelGrid, schematicWriter, config)
+	return p.VoxelGridToSchematicCtx(ctx, voxelGrid, schematicWriter, config)
 }
 
 // applyColorMatching applies color matching without dithering.
-func (p *Pipeline) applyColorMatching(vg *VoxelGrid) *VoxelGrid {
-	result := NewVoxelGrid(vg.SizeX, vg.SizeY, vg.SizeZ)
-	result.Scale = vg.Scale
-	result.Origin = vg.Origin
+func (p *Pipeline) applyColorMatching(ctx context.Context, vg *VoxelGrid, m voxelMatchers, tracker *progressTracker) (*VoxelGrid, error) {
+	result := vg.emptyLike()
 	
-	for pos, voxel := range vg.Voxels {
-		matched := p.Matcher.Match(voxel.Color)
+	i := 0
+	var err error
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		if i%ctxCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		i++
+		tracker.add(1)
+		
+		matched, _ := p.matchVoxel(vg, x, y, z, color, [3]float64{}, m)
 		if matched != nil {
-			result.SetVoxel(pos[0], pos[1], pos[2], matched.RGB)
+			result.SetVoxel(x, y, z, matched.RGB)
 		}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 	
-	return result
+	return result, nil
 }
 
 // applyDithering applies error diffusion dithering during color matching.
-func (p *Pipeline) applyDithering(vg *VoxelGrid, config DitherConfig) *VoxelGrid {
-	result := NewVoxelGrid(vg.SizeX, vg.SizeY, vg.SizeZ)
-	result.Scale = vg.Scale
-	result.Origin = vg.Origin
+func (p *Pipeline) applyDithering(ctx context.Context, vg *VoxelGrid, config DitherConfig, m voxelMatchers, tracker *progressTracker) (*VoxelGrid, error) {
+	algorithm := config.Algorithm
+	if algorithm == "" {
+		algorithm = ditherAlgorithms[0]
+	}
+	kernel, ok := ditherKernels[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown dithering algorithm %q (supported: %s)",
+			ErrInvalidConfig, config.Algorithm, strings.Join(ditherAlgorithms, ", "))
+	}
+	
+	result := vg.emptyLike()
 	
-	// Error buffer for dithering
-	errorBuffer := make(map[[3]int][3]float64)
+	// Error buffer for dithering, covering the z-planes the kernel reaches
+	errorBuffer := ditherBufferPool.Get().(*ditherBuffer)
+	defer putDitherBuffer(errorBuffer)
+	errorBuffer.reset(vg.SizeX, vg.SizeY, kernelDepth(kernel)+1)
 	
 	// Process voxels in order (for error diffusion)
 	for z := 0; z < vg.SizeZ; z++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		for y := 0; y < vg.SizeY; y++ {
 			for x := 0; x < vg.SizeX; x++ {
-				voxel := vg.GetVoxel(x, y, z)
-				if voxel == nil {
+				color, ok := vg.ColorAt(x, y, z)
+				if !ok {
 					continue
 				}
 				
-				pos := [3]int{x, y, z}
-				error := errorBuffer[pos]
+				tracker.add(1)
+				error := errorBuffer.at(x, y, z)
 				
-				matched, quantError := p.Matcher.MatchWithDithering(voxel.Color, error)
+				matched, quantError := p.matchVoxel(vg, x, y, z, color, error, m)
 				if matched != nil {
 					result.SetVoxel(x, y, z, matched.RGB)
 					
-					// Distribute error to neighbors (Floyd-Steinberg pattern)
-					p.distributeError(errorBuffer, x, y, z, quantError, config.Algorithm)
+					// Distribute error to the neighbors not visited yet
+					distributeError(errorBuffer, x, y, z, quantError, kernel)
 				}
 			}
 		}
+		errorBuffer.clearPlane(z)
+	}
+	
+	return result, nil
+}
+
+// voxelMatchers are the optional matcher interfaces the palette has data for.
+type voxelMatchers struct {
+	faces FaceMatcher           // Set when the palette has per-face colors
+	noise NoiseMatcher          // Set when the palette records texture noise
+	labs  map[[3]uint8]LABColor // CIELAB values of grid colors, for regionNoise
+	light ColorMatcher          // Matches emissive voxels, set with PipelineConfig.LightBlocks
+}
+
+// gridHasEmission reports whether any of the grid's materials emits light.
+func gridHasEmission(vg *VoxelGrid) bool {
+	for _, m := range vg.Materials {
+		if m.Emissive() {
+			return true
+		}
+	}
+	return false
+}
+
+// lightMatcher returns a matcher of the palette's #light_source blocks, or nil
+// when it has none.
+func lightMatcher(palette *Palette, weights MatchWeights) ColorMatcher {
+	lights := &Palette{}
+	for i := range palette.Colors {
+		if hasBlockTag(&palette.Colors[i], TagLightSource) {
+			lights.Colors = append(lights.Colors, palette.Colors[i])
+		}
+	}
+	if len(lights.Colors) == 0 {
+		return nil
+	}
+	matcher := &CIELABMatcher{}
+	matcher.SetWeights(weights)
+	matcher.SetPalette(lights)
+	return matcher
+}
+
+// matchVoxel matches one voxel's color plus the accumulated dithering error and
+// returns the quantization error. With a FaceMatcher the voxel is matched against
+// the face it shows, and the error is measured against that face's color; with a
+// NoiseMatcher, which takes precedence, the noise around the voxel counts too.
+// Emissive voxels matched with a light matcher take no part in dithering.
+func (p *Pipeline) matchVoxel(vg *VoxelGrid, x, y, z int, color [3]uint8, error [3]float64, m voxelMatchers) (*PaletteColor, [3]float64) {
+	if m.light != nil && vg.Materials[color].Emissive() {
+		return m.light.Match(color), [3]float64{}
+	}
+	face := BlockFace("")
+	if m.faces != nil {
+		face = visibleFace(vg, x, y, z)
+	}
+	if face == "" && m.noise == nil {
+		return p.Matcher.MatchWithDithering(color, error)
 	}
 	
-	return result
+	var adjusted [3]uint8
+	for c := 0; c < 3; c++ {
+		adjusted[c] = clampUint8(float64(color[c]) + error[c])
+	}
+	var matched *PaletteColor
+	if m.noise != nil {
+		matched = m.noise.MatchNoise(adjusted, face, regionNoise(vg, x, y, z, m.labs))
+	} else {
+		matched = m.faces.MatchFace(adjusted, face)
+	}
+	if matched == nil {
+		return nil, [3]float64{}
+	}
+	shown := matched.FaceRGB(face)
+	var quantError [3]float64
+	for c := 0; c < 3; c++ {
+		quantError[c] = float64(adjusted[c]) - float64(shown[c])
+	}
+	return matched, quantError
+}
+
+// regionNoise returns how noisy the colors around a voxel are: the RMS CIELAB
+// distance of the voxels in its 3x3x3 neighborhood from their mean, in the units
+// of PaletteColor.Noise. labs caches conversions between calls.
+func regionNoise(vg *VoxelGrid, x, y, z int, labs map[[3]uint8]LABColor) float64 {
+	var samples [27]LABColor
+	n := 0
+	var mean LABColor
+	for dz := -1; dz <= 1; dz++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				color, ok := vg.ColorAt(x+dx, y+dy, z+dz)
+				if !ok {
+					continue
+				}
+				lab, ok := labs[color]
+				if !ok {
+					lab = RGBToLAB(color)
+					labs[color] = lab
+				}
+				samples[n] = lab
+				n++
+				mean.L, mean.A, mean.B = mean.L+lab.L, mean.A+lab.A, mean.B+lab.B
+			}
+		}
+	}
+	return labRMS(samples[:n], mean)
+}
+
+// labRMS returns the RMS distance of colors from their mean, given their sum.
+func labRMS(colors []LABColor, sum LABColor) float64 {
+	if len(colors) == 0 {
+		return 0
+	}
+	n := float64(len(colors))
+	mean := [3]float64{sum.L / n, sum.A / n, sum.B / n}
+	var total float64
+	for _, c := range colors {
+		total += dist2([3]float64{c.L, c.A, c.B}, mean)
+	}
+	return math.Sqrt(total / n)
+}
+
+// visibleFace returns the block face a voxel most likely shows, judged by the
+// normal summed from the directions of its empty neighbors: the top or bottom
+// when that face is exposed and the normal points no more sideways than up or
+// down, otherwise a side. Voxels without an empty neighbor return "".
+func visibleFace(vg *VoxelGrid, x, y, z int) BlockFace {
+	var normal [3]int
+	var up, down, exposed bool
+	for axis := 0; axis < 3; axis++ {
+		for _, dir := range [2]int{-1, 1} {
+			n := [3]int{x, y, z}
+			n[axis] += dir
+			if vg.HasVoxel(n[0], n[1], n[2]) {
+				continue
+			}
+			normal[axis] += dir
+			exposed = true
+			if axis == 1 {
+				up, down = up || dir > 0, down || dir < 0
+			}
+		}
+	}
+	horizontal := max(abs(normal[0]), abs(normal[2]))
+	switch {
+	case up && normal[1] >= horizontal:
+		return FaceTop
+	case down && -normal[1] >= horizontal:
+		return FaceBottom
+	case exposed:
+		return FaceSide
+	}
+	return ""
 }
 
-// distributeError distributes quantization error to neighboring voxels.
-func (p *Pipeline) distributeError(buffer map[[3]int][3]float64, x, y, z int, error [3]float64, algorithm string) {
-	// Floyd-Steinberg coefficients
-	if algorithm == "floyd-steinberg" || algorithm == "" {
-		p.addError(buffer, x+1, y, z, error, 7.0/16.0)
-		p.addError(buffer, x-1, y+1, z, error, 3.0/16.0)
-		p.addError(buffer, x, y+1, z, error, 5.0/16.0)
-		p.addError(buffer, x+1, y+1, z, error, 1.0/16.0)
+// orderedDitherSpread is the range, in RGB units, of the offsets ordered dithering
+// adds to a color before matching: offsets run from -spread/2 to +spread/2.
+const orderedDitherSpread = 64
+
+// applyOrderedDithering matches each voxel after offsetting its color by a 3D Bayer
+// threshold of its position. Unlike error diffusion the result has no directional
+// streaks and does not depend on the order voxels are visited in.
+func (p *Pipeline) applyOrderedDithering(ctx context.Context, vg *VoxelGrid, size int, m voxelMatchers, tracker *progressTracker) (*VoxelGrid, error) {
+	result := vg.emptyLike()
+	thresholds := bayerMatrix3D(size)
+	mask := size - 1
+	
+	i := 0
+	var err error
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		if i%ctxCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		i++
+		tracker.add(1)
+		
+		t := thresholds[((z&mask)*size+(y&mask))*size+(x&mask)]
+		offset := (t - 0.5) * orderedDitherSpread
+		matched, _ := p.matchVoxel(vg, x, y, z, color, [3]float64{offset, offset, offset}, m)
+		if matched != nil {
+			result.SetVoxel(x, y, z, matched.RGB)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return result, nil
+}
+
+// bayerSize returns the matrix size of an ordered dithering algorithm, or 0 for
+// error diffusion algorithms.
+func bayerSize(algorithm string) int {
+	switch algorithm {
+	case "bayer4":
+		return 4
+	case "bayer8":
+		return 8
+	}
+	return 0
+}
+
+// bayerBase orders the corners of a 2x2x2 cube, indexed by x | y<<1 | z<<2, so that
+// consecutive thresholds sit on opposite corners, as the 2x2 Bayer matrix does.
+var bayerBase = [8]int{0, 2, 4, 7, 6, 5, 3, 1}
+
+// bayerMatrix3D returns the thresholds of a size^3 Bayer matrix, size a power of
+// two, indexed by (z*size+y)*size+x and spread evenly over (0, 1). The matrix is
+// built recursively: the lowest coordinate bits pick the most significant digit,
+// so neighbouring cells get thresholds far apart.
+func bayerMatrix3D(size int) []float64 {
+	levels := size * size * size
+	thresholds := make([]float64, levels)
+	for z := 0; z < size; z++ {
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				value := 0
+				for bit := 1; bit < size; bit <<= 1 {
+					corner := 0
+					if x&bit != 0 {
+						corner |= 1
+					}
+					if y&bit != 0 {
+						corner |= 2
+					}
+					if z&bit != 0 {
+						corner |= 4
+					}
+					// Coarser bits are less significant
+					value = value*8 + bayerBase[corner]
+				}
+				thresholds[(z*size+y)*size+x] = (float64(value) + 0.5) / float64(levels)
+			}
+		}
+	}
+	return thresholds
+}
+
+// ditherTap is one entry of an error diffusion kernel: the share of the error
+// passed to the voxel dx further along the row and dy rows ahead.
+type ditherTap struct {
+	dx, dy int
+	weight float64
+}
+
+// ditherKernels holds the classic 2D error diffusion kernels by name.
+var ditherKernels = map[string][]ditherTap{
+	"floyd-steinberg": {
+		{1, 0, 7.0 / 16}, {-1, 1, 3.0 / 16}, {0, 1, 5.0 / 16}, {1, 1, 1.0 / 16},
+	},
+	"jarvis": {
+		{1, 0, 7.0 / 48}, {2, 0, 5.0 / 48},
+		{-2, 1, 3.0 / 48}, {-1, 1, 5.0 / 48}, {0, 1, 7.0 / 48}, {1, 1, 5.0 / 48}, {2, 1, 3.0 / 48},
+		{-2, 2, 1.0 / 48}, {-1, 2, 3.0 / 48}, {0, 2, 5.0 / 48}, {1, 2, 3.0 / 48}, {2, 2, 1.0 / 48},
+	},
+	"stucki": {
+		{1, 0, 8.0 / 42}, {2, 0, 4.0 / 42},
+		{-2, 1, 2.0 / 42}, {-1, 1, 4.0 / 42}, {0, 1, 8.0 / 42}, {1, 1, 4.0 / 42}, {2, 1, 2.0 / 42},
+		{-2, 2, 1.0 / 42}, {-1, 2, 2.0 / 42}, {0, 2, 4.0 / 42}, {1, 2, 2.0 / 42}, {2, 2, 1.0 / 42},
+	},
+	// Atkinson passes on only 3/4 of the error, trading accuracy for contrast
+	"atkinson": {
+		{1, 0, 1.0 / 8}, {2, 0, 1.0 / 8},
+		{-1, 1, 1.0 / 8}, {0, 1, 1.0 / 8}, {1, 1, 1.0 / 8},
+		{0, 2, 1.0 / 8},
+	},
+	"sierra": {
+		{1, 0, 5.0 / 32}, {2, 0, 3.0 / 32},
+		{-2, 1, 2.0 / 32}, {-1, 1, 4.0 / 32}, {0, 1, 5.0 / 32}, {1, 1, 4.0 / 32}, {2, 1, 2.0 / 32},
+		{-1, 2, 2.0 / 32}, {0, 2, 3.0 / 32}, {1, 2, 2.0 / 32},
+	},
+}
+
+// kernelDepth returns how many rows ahead a kernel reaches.
+func kernelDepth(kernel []ditherTap) int {
+	depth := 0
+	for _, tap := range kernel {
+		depth = max(depth, tap.dy)
+	}
+	return depth
+}
+
+// distributeError distributes quantization error to the neighbors a kernel
+// reaches. The kernels are 2D, so the share of each tap on a following row is
+// split evenly between that row in the current z-plane and the same offset in the
+// plane that many steps ahead, spreading error through the volume rather than
+// only within planes.
+func distributeError(buffer *ditherBuffer, x, y, z int, error [3]float64, kernel []ditherTap) {
+	for _, tap := range kernel {
+		if tap.dy == 0 {
+			buffer.add(x+tap.dx, y, z, error, tap.weight)
+			continue
+		}
+		buffer.add(x+tap.dx, y+tap.dy, z, error, tap.weight/2)
+		buffer.add(x+tap.dx, y, z+tap.dy, error, tap.weight/2)
+	}
+}
+
+// ditherBuffer accumulates diffused quantization error for a sliding window of
+// z-planes. Its storage is reset and reused rather than reallocated per conversion.
+type ditherBuffer struct {
+	sizeX, sizeY int
+	planes       int
+	base         int // Lowest z-plane in the window
+	errors       [][3]float64
+}
+
+// reset sizes the buffer for planes z-planes of sizeX*sizeY cells and zeroes it.
+func (b *ditherBuffer) reset(sizeX, sizeY, planes int) {
+	n := sizeX * sizeY * planes
+	if cap(b.errors) < n {
+		b.errors = make([][3]float64, n)
+	} else {
+		b.errors = b.errors[:n]
+		for i := range b.errors {
+			b.errors[i] = [3]float64{}
+		}
+	}
+	b.sizeX, b.sizeY, b.planes, b.base = sizeX, sizeY, planes, 0
+}
+
+// index returns the slot for a position, or -1 if it is outside the grid or window.
+func (b *ditherBuffer) index(x, y, z int) int {
+	if x < 0 || x >= b.sizeX || y < 0 || y >= b.sizeY || z < b.base || z >= b.base+b.planes {
+		return -1
+	}
+	return ((z%b.planes)*b.sizeY+y)*b.sizeX + x
+}
+
+// at returns the error accumulated at a position.
+func (b *ditherBuffer) at(x, y, z int) [3]float64 {
+	if i := b.index(x, y, z); i >= 0 {
+		return b.errors[i]
+	}
+	return [3]float64{}
+}
+
+// add adds weighted error at a position; positions outside the window are dropped.
+func (b *ditherBuffer) add(x, y, z int, error [3]float64, weight float64) {
+	i := b.index(x, y, z)
+	if i < 0 {
+		return
+	}
+	for c := 0; c < 3; c++ {
+		b.errors[i][c] += error[c] * weight
+	}
+}
+
+// clearPlane zeroes plane z once it is finished so the slot can hold plane z+planes.
+func (b *ditherBuffer) clearPlane(z int) {
+	if z != b.base {
+		return
+	}
+	plane := b.sizeX * b.sizeY
+	start := (z % b.planes) * plane
+	for i := start; i < start+plane; i++ {
+		b.errors[i] = [3]float64{}
 	}
-	// Other algorithms can be added here
+	b.base++
 }
 
-// addError adds error to the buffer at the given position.
-func (p *Pipeline) addError(buffer map[[3]int][3]float64, x, y, z int, error [3]float64, weight float64) {
-	pos := [3]int{x, y, z}
-	current := buffer[pos]
-	for i := 0; i < 3; i++ {
-		current[i] += error[i] * weight
+func putDitherBuffer(b *ditherBuffer) {
+	if len(b.errors)*24 <= maxPooledBytes {
+		ditherBufferPool.Put(b)
 	}
-	buffer[pos] = current
 }
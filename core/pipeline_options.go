@@ -0,0 +1,409 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ditherAlgorithms lists the error diffusion kernels applyDithering understands,
+// followed by the ordered dithering matrices of applyOrderedDithering.
+var ditherAlgorithms = []string{"floyd-steinberg", "jarvis", "stucki", "atkinson", "sierra", "bayer4", "bayer8"}
+
+// DitherAlgorithms returns the names accepted by DitherConfig.Algorithm.
+// An empty algorithm selects the first entry.
+func DitherAlgorithms() []string {
+	return append([]string(nil), ditherAlgorithms...)
+}
+
+// PipelineOption configures a pipeline built by NewPipeline.
+type PipelineOption func(*pipelineOptions)
+
+// pipelineOptions collects explicit components, registry names and configuration
+// until NewPipeline resolves them.
+type pipelineOptions struct {
+	importer      MeshImporter
+	importerName  string
+	inputFile     string
+	animation     int
+	animationTime float64
+	voxelizer     Voxelizer
+	voxelizerName string
+	matcher       ColorMatcher
+	matcherName   string
+	exporter      GridExporter
+	exporterName  string
+	outputFile    string
+	config        PipelineConfig
+}
+
+// WithImporter uses the given mesh importer.
+func WithImporter(importer MeshImporter) PipelineOption {
+	return func(o *pipelineOptions) { o.importer = importer }
+}
+
+// WithFormat selects the importer registered under name (default "gltf").
+func WithFormat(name string) PipelineOption {
+	return func(o *pipelineOptions) { o.importerName = name }
+}
+
+// WithInputFile selects the importer registered for the file's extension. Importers
+// that read companion files (ResourceMeshImporter) open them from its directory.
+func WithInputFile(filename string) PipelineOption {
+	return func(o *pipelineOptions) { o.inputFile = filename }
+}
+
+// WithAnimation poses imported models with the animation of the given index at
+// seconds into it. The importer must implement AnimatedMeshImporter.
+func WithAnimation(animation int, seconds float64) PipelineOption {
+	return func(o *pipelineOptions) { o.animation, o.animationTime = animation, seconds }
+}
+
+// WithVoxelizer uses the given voxelizer.
+func WithVoxelizer(voxelizer Voxelizer) PipelineOption {
+	return func(o *pipelineOptions) { o.voxelizer = voxelizer }
+}
+
+// WithVoxelizerName selects the voxelizer registered under name (default "surface").
+func WithVoxelizerName(name string) PipelineOption {
+	return func(o *pipelineOptions) { o.voxelizerName = name }
+}
+
+// WithMatcher uses the given color matcher.
+func WithMatcher(matcher ColorMatcher) PipelineOption {
+	return func(o *pipelineOptions) { o.matcher = matcher }
+}
+
+// WithMatcherName selects the color matcher registered under name (default "cielab").
+func WithMatcherName(name string) PipelineOption {
+	return func(o *pipelineOptions) { o.matcherName = name }
+}
+
+// WithExporter uses the given grid exporter.
+func WithExporter(exporter GridExporter) PipelineOption {
+	return func(o *pipelineOptions) { o.exporter = exporter }
+}
+
+// WithExporterName selects the exporter registered under name.
+// Without it the pipeline writes schematics when a palette is set and VOX otherwise.
+func WithExporterName(name string) PipelineOption {
+	return func(o *pipelineOptions) { o.exporterName = name }
+}
+
+// WithOutputFile selects the exporter registered for the file's extension.
+func WithOutputFile(filename string) PipelineOption {
+	return func(o *pipelineOptions) { o.outputFile = filename }
+}
+
+// WithVoxelization sets the voxelization parameters.
+func WithVoxelization(config VoxelizationConfig) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Voxelization = config }
+}
+
+// WithTransform scales, rotates and translates meshes before voxelization.
+func WithTransform(t TransformConfig) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Transform = t }
+}
+
+// WithMorphology runs morphology steps, in order, on voxelized grids.
+func WithMorphology(steps ...MorphStep) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Morphology = steps }
+}
+
+// WithIslandRemoval deletes components of fewer than minSize voxels from
+// voxelized grids, keeping the largest.
+func WithIslandRemoval(minSize int) PipelineOption {
+	return func(o *pipelineOptions) { o.config.MinIsland = minSize }
+}
+
+// WithPostScale rescales voxelized grids by a whole factor or its reciprocal,
+// such as 2 or 0.5.
+func WithPostScale(scale float64) PipelineOption {
+	return func(o *pipelineOptions) { o.config.PostScale = scale }
+}
+
+// WithAmbientOcclusion darkens the crevices of voxelized grids by up to strength,
+// from 0 (off) to 1, before color matching.
+func WithAmbientOcclusion(strength float64) PipelineOption {
+	return func(o *pipelineOptions) { o.config.AmbientOcclusion = strength }
+}
+
+// WithDithering sets the dithering parameters. Enabling dithering requires a palette.
+func WithDithering(config DitherConfig) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Dithering = config }
+}
+
+// WithSchematic sets the schematic format options used by the schematic exporter.
+func WithSchematic(config SchematicConfig) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Schematic = config }
+}
+
+// WithFunction sets the options used by the mcfunction and datapack exporters.
+func WithFunction(config FunctionConfig) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Function = config }
+}
+
+// WithPalette sets the block palette used for color matching.
+func WithPalette(palette *Palette) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Palette = palette }
+}
+
+// WithMatchWeights weights lightness against color differences in matching.
+// The matcher must implement WeightedMatcher.
+func WithMatchWeights(weights MatchWeights) PipelineOption {
+	return func(o *pipelineOptions) { o.config.MatchWeights = weights }
+}
+
+// WithPlacement validates, and optionally fixes, block placement after color
+// matching. A nil opts skips validation.
+func WithPlacement(opts *PlacementOptions) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Placement = opts }
+}
+
+// WithDetail selects a surface detail pass, such as DetailStairsSlabs, run by the
+// schematic, structure and command exporters. It requires a palette.
+func WithDetail(mode string) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Detail = mode }
+}
+
+// WithDetailRegions voxelizes the given parts of meshes at finer resolutions,
+// resolving their voxels into stairs and slabs for the detail mode.
+func WithDetailRegions(regions ...DetailRegion) PipelineOption {
+	return func(o *pipelineOptions) { o.config.DetailRegions = append(o.config.DetailRegions, regions...) }
+}
+
+// WithLightBlocks matches voxels of emissive materials, such as glTF emissive
+// surfaces, against the palette's light-emitting blocks (#light_source) only:
+// glowstone, sea lanterns, froglights and the like.
+func WithLightBlocks(enabled bool) PipelineOption {
+	return func(o *pipelineOptions) { o.config.LightBlocks = enabled }
+}
+
+// WithProgress sets the progress reporter for all stages.
+func WithProgress(reporter ProgressReporter) PipelineOption {
+	return func(o *pipelineOptions) { o.config.Progress = reporter }
+}
+
+// NewPipeline assembles a pipeline from options, falling back to the registered
+// "gltf" importer, "surface" voxelizer and "cielab" matcher, and validates the
+// configuration before any work is done. The resolved configuration is stored in
+// Pipeline.Config for Convert and ExportGrid.
+func NewPipeline(opts ...PipelineOption) (*Pipeline, error) {
+	o := pipelineOptions{
+		importerName:  "gltf",
+		animation:     -1,
+		voxelizerName: "surface",
+		matcherName:   "cielab",
+		config: PipelineConfig{
+			Voxelization: VoxelizationConfig{Resolution: 128, Conservative: true},
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := o.config.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	p := &Pipeline{
+		Importer:  o.importer,
+		Voxelizer: o.voxelizer,
+		Matcher:   o.matcher,
+		Exporter:  o.exporter,
+		Config:    o.config,
+	}
+
+	var err error
+	if p.Importer == nil {
+		if o.inputFile != "" {
+			p.Importer, err = NewImporterForFile(o.inputFile)
+			if ri, ok := p.Importer.(ResourceMeshImporter); ok {
+				ri.SetResources(os.DirFS(filepath.Dir(o.inputFile)))
+			}
+		} else {
+			p.Importer, err = NewImporter(o.importerName)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.animation >= 0 {
+		ai, ok := p.Importer.(AnimatedMeshImporter)
+		if !ok {
+			return nil, fmt.Errorf("%w: importer %T does not support animations", ErrInvalidConfig, p.Importer)
+		}
+		ai.SetAnimation(o.animation, o.animationTime)
+	}
+	if p.Voxelizer == nil {
+		if p.Voxelizer, err = NewVoxelizerByName(o.voxelizerName); err != nil {
+			return nil, err
+		}
+	}
+	if p.Matcher == nil && o.config.Palette != nil {
+		if p.Matcher, err = NewMatcherByName(o.matcherName, o.config.Palette); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := p.Matcher.(WeightedMatcher); !ok && p.Matcher != nil && o.config.MatchWeights != (MatchWeights{}) {
+		return nil, fmt.Errorf("%w: matcher %T does not support match weights", ErrInvalidConfig, p.Matcher)
+	}
+	if p.Exporter == nil {
+		switch {
+		case o.outputFile != "":
+			p.Exporter, err = NewExporterForFile(o.outputFile, o.config)
+		case o.exporterName != "":
+			p.Exporter, err = NewExporter(o.exporterName, o.config)
+		case o.config.Palette != nil:
+			p.Exporter, err = NewExporter("schematic", o.config)
+		default:
+			p.Exporter, err = NewExporter("vox", o.config)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// validate reports configuration combinations that would fail or be silently ignored later.
+func (c PipelineConfig) validate() error {
+	for _, size := range c.Voxelization.TargetSize {
+		if size < 0 {
+			return fmt.Errorf("target size must not be negative, got %v", c.Voxelization.TargetSize)
+		}
+	}
+	if c.Voxelization.Resolution <= 0 && c.Voxelization.TargetSize == [3]int{} {
+		return fmt.Errorf("resolution must be positive, got %d", c.Voxelization.Resolution)
+	}
+	if c.Voxelization.Scale < 0 {
+		return fmt.Errorf("scale must not be negative, got %v", c.Voxelization.Scale)
+	}
+	if c.Voxelization.MaxCells < 0 {
+		return fmt.Errorf("cell limit must not be negative, got %d", c.Voxelization.MaxCells)
+	}
+	if c.Voxelization.MinPoints < 0 || c.Voxelization.HoleFill < 0 {
+		return fmt.Errorf("point density and hole fill passes must not be negative, got %d and %d", c.Voxelization.MinPoints, c.Voxelization.HoleFill)
+	}
+	if iso := c.Voxelization.IsoLevel; math.IsNaN(iso) || math.IsInf(iso, 0) {
+		return fmt.Errorf("iso level must be finite, got %v", iso)
+	}
+	if c.Voxelization.MinThickness < 0 {
+		return fmt.Errorf("minimum thickness must not be negative, got %d", c.Voxelization.MinThickness)
+	}
+	if c.Voxelization.Workers < 0 {
+		return fmt.Errorf("worker count must not be negative, got %d", c.Voxelization.Workers)
+	}
+	for _, v := range c.Voxelization.Lighting.Direction {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("light direction must be finite, got %v", c.Voxelization.Lighting.Direction)
+		}
+	}
+	if ambient := c.Voxelization.Lighting.Ambient; !(ambient >= 0 && ambient <= 1) {
+		return fmt.Errorf("ambient light must be between 0 and 1, got %v", ambient)
+	}
+	if axis := c.Voxelization.UpAxis; axis != "" && !containsString(upAxes, axis) {
+		return fmt.Errorf("unknown up axis %q (supported: %s)", axis, strings.Join(upAxes, ", "))
+	}
+	for _, v := range [][3]float64{c.Transform.Scale, c.Transform.Rotate, c.Transform.Translate} {
+		for _, x := range v {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return fmt.Errorf("transform values must be finite, got %v", v)
+			}
+		}
+	}
+	for _, step := range c.Morphology {
+		if err := step.validate(); err != nil {
+			return err
+		}
+	}
+	if c.MinIsland < 0 {
+		return fmt.Errorf("minimum island size must not be negative, got %d", c.MinIsland)
+	}
+	if !(c.AmbientOcclusion >= 0 && c.AmbientOcclusion <= 1) {
+		return fmt.Errorf("ambient occlusion strength must be between 0 and 1, got %v", c.AmbientOcclusion)
+	}
+	if _, _, err := rescaleFactors(c.PostScale); err != nil {
+		return err
+	}
+	if c.Voxelization.Samples < 0 {
+		return fmt.Errorf("color samples must not be negative, got %d", c.Voxelization.Samples)
+	}
+	if c.Voxelization.Hollow < 0 {
+		return fmt.Errorf("shell thickness must not be negative, got %d", c.Voxelization.Hollow)
+	}
+	if v := c.Schematic.Version; v != 0 && v != 2 && v != 3 {
+		return fmt.Errorf("unsupported schematic version %d (supported: 2, 3)", v)
+	}
+	if c.Schematic.DataVersion < 0 {
+		return fmt.Errorf("data version must not be negative, got %d", c.Schematic.DataVersion)
+	}
+	if mode := c.Schematic.AirMode; mode != AirModeDefault && !containsString(airModes, mode) {
+		return fmt.Errorf("unknown air mode %q (supported: %s)", mode, strings.Join(airModes, ", "))
+	}
+	if r := c.Schematic.Rotation; r < 0 || r > 3 {
+		return fmt.Errorf("rotation must be 0 to 3 quarter turns, got %d", r)
+	}
+	if origin := c.Schematic.PasteOrigin; origin != PasteOriginCorner {
+		if !containsString(pasteOrigins, origin) {
+			return fmt.Errorf("unknown paste origin %q (supported: %s)", origin, strings.Join(pasteOrigins, ", "))
+		}
+		if c.Schematic.Offset != [3]int{} {
+			return fmt.Errorf("an offset cannot be combined with the %q paste origin", origin)
+		}
+	}
+	if ns := c.Function.Namespace; ns != "" && !validNamespace.MatchString(ns) {
+		return fmt.Errorf("invalid datapack namespace %q (use a-z, 0-9, _, . and -)", ns)
+	}
+	if c.Function.MaxCommands < 0 {
+		return fmt.Errorf("commands per function must not be negative, got %d", c.Function.MaxCommands)
+	}
+	if w := c.MatchWeights; w.Lightness < 0 || w.Color < 0 || math.IsNaN(w.Lightness) || math.IsNaN(w.Color) ||
+		math.IsInf(w.Lightness, 0) || math.IsInf(w.Color, 0) {
+		return fmt.Errorf("match weights must be finite and not negative, got %v,%v", w.Lightness, w.Color)
+	}
+	if c.Dithering.Enabled && c.Palette == nil {
+		return fmt.Errorf("dithering is enabled but no palette is set")
+	}
+	if algo := c.Dithering.Algorithm; algo != "" && !containsString(ditherAlgorithms, algo) {
+		return fmt.Errorf("unknown dithering algorithm %q (supported: %s)",
+			algo, strings.Join(ditherAlgorithms, ", "))
+	}
+	if c.Palette != nil && len(c.Palette.Colors) == 0 {
+		return fmt.Errorf("palette is empty")
+	}
+	if c.Placement != nil {
+		if c.Palette == nil {
+			return fmt.Errorf("placement validation is enabled but no palette is set")
+		}
+		if fix := c.Placement.Fix; fix != FixNone && !containsString(placementFixes, fix) {
+			return fmt.Errorf("unknown placement fix %q (supported: %s)", fix, strings.Join(placementFixes, ", "))
+		}
+	}
+	for i, region := range c.DetailRegions {
+		if err := region.validate(); err != nil {
+			return fmt.Errorf("detail region %d: %w", i+1, err)
+		}
+	}
+	if c.Detail != DetailNone {
+		if !containsString(detailModes, c.Detail) {
+			return fmt.Errorf("unknown detail mode %q (supported: %s)", c.Detail, strings.Join(detailModes, ", "))
+		}
+		if c.Palette == nil {
+			return fmt.Errorf("detail %q is enabled but no palette is set", c.Detail)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
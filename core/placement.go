@@ -0,0 +1,189 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Placement fixes selected by PlacementOptions.Fix.
+const (
+	FixNone       = ""           // Report issues without changing the grid
+	FixSubstitute = "substitute" // Replace unsupported blocks with the closest stable palette block
+	FixSupport    = "support"    // Place the closest stable palette block beneath unsupported blocks
+)
+
+// placementFixes lists the accepted PlacementOptions.Fix values besides FixNone.
+var placementFixes = []string{FixSubstitute, FixSupport}
+
+// PlacementFixes returns the names accepted by PlacementOptions.Fix.
+func PlacementFixes() []string {
+	return append([]string(nil), placementFixes...)
+}
+
+// PlacementOptions configures ValidatePlacement.
+type PlacementOptions struct {
+	Fix      string                 // One of FixNone, FixSubstitute, FixSupport
+	Matcher  ColorMatcher           // Maps voxel colors that are not palette entries to blocks (nil = CIELAB)
+	OnIssues func([]PlacementIssue) // Optional; called with the issues found when run by a pipeline
+}
+
+// Reasons recorded in PlacementIssue.Reason.
+const (
+	ReasonFloating   = "floating"   // A #gravity block with nothing beneath it
+	ReasonUnattached = "unattached" // A #needs_support block with nothing beneath or beside it
+)
+
+// PlacementIssue is a block that cannot stay in place in game.
+type PlacementIssue struct {
+	X, Y, Z int
+	Block   string // Block ID
+	Reason  string // ReasonFloating or ReasonUnattached
+	Fixed   bool   // Whether the fix was applied
+}
+
+// ValidatePlacement finds blocks that would fall or break once placed: #gravity
+// blocks (sand, gravel, concrete powder) with an empty cell beneath them and
+// #needs_support blocks (torches, carpets, rails) with no filled cell beneath or
+// beside them. The bottom layer of the grid is assumed to rest on the ground.
+// Voxel colors are mapped to palette blocks as the schematic exporter maps them.
+//
+// With FixSubstitute or FixSupport the grid is changed in place; a fix is skipped
+// when the palette has no block that is neither tagged #gravity nor #needs_support.
+// Issues are returned bottom layer first.
+func ValidatePlacement(vg *VoxelGrid, palette *Palette, opts PlacementOptions) ([]PlacementIssue, error) {
+	if palette == nil || len(palette.Colors) == 0 {
+		return nil, fmt.Errorf("%w: placement validation needs a palette", ErrInvalidConfig)
+	}
+	if opts.Fix != FixNone && !containsString(placementFixes, opts.Fix) {
+		return nil, fmt.Errorf("%w: unknown placement fix %q (supported: %s)",
+			ErrInvalidConfig, opts.Fix, strings.Join(placementFixes, ", "))
+	}
+
+	blocks := newPlacementBlocks(palette, opts.Matcher)
+
+	// Collect first: fixes change the grid being ranged over
+	var issues []PlacementIssue
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		block := blocks.lookup(color)
+		if reason := blocks.problem(vg, block, x, y, z); reason != "" {
+			issues = append(issues, PlacementIssue{X: x, Y: y, Z: z, Block: paletteBlockID(&palette.Colors[block]), Reason: reason})
+		}
+		return true
+	})
+	sort.Slice(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		return a.X < b.X
+	})
+	if opts.Fix == FixNone {
+		return issues, nil
+	}
+
+	// Earlier fixes can support later blocks, which then need no fix
+	kept := issues[:0]
+	for _, issue := range issues {
+		color, _ := vg.ColorAt(issue.X, issue.Y, issue.Z)
+		block := blocks.lookup(color)
+		if blocks.problem(vg, block, issue.X, issue.Y, issue.Z) == "" {
+			continue
+		}
+		if stable := blocks.stableFor(block); stable != nil {
+			if opts.Fix == FixSubstitute {
+				vg.SetVoxel(issue.X, issue.Y, issue.Z, stable.RGB)
+			} else {
+				vg.SetVoxel(issue.X, issue.Y-1, issue.Z, stable.RGB)
+			}
+			issue.Fixed = true
+		}
+		kept = append(kept, issue)
+	}
+	return kept, nil
+}
+
+// placementBlocks maps voxel colors to palette entries and knows which entries
+// need support.
+type placementBlocks struct {
+	palette      *Palette
+	matcher      ColorMatcher
+	colorIndex   map[[3]uint8]int
+	gravity      []bool
+	needsSupport []bool
+	stable       *CIELABMatcher // Over the entries needing no support; nil if there are none
+}
+
+func newPlacementBlocks(palette *Palette, matcher ColorMatcher) *placementBlocks {
+	b := &placementBlocks{
+		palette:      palette,
+		matcher:      matcher,
+		colorIndex:   make(map[[3]uint8]int, len(palette.Colors)),
+		gravity:      make([]bool, len(palette.Colors)),
+		needsSupport: make([]bool, len(palette.Colors)),
+	}
+	if b.matcher == nil {
+		b.matcher = NewCIELABMatcher(palette)
+	}
+
+	stable := &Palette{}
+	for i := len(palette.Colors) - 1; i >= 0; i-- {
+		color := &palette.Colors[i]
+		b.colorIndex[color.RGB] = i // The first entry wins when two share an RGB value
+		b.gravity[i] = hasBlockTag(color, TagGravity)
+		b.needsSupport[i] = hasBlockTag(color, TagNeedsSupport)
+	}
+	for i := range palette.Colors {
+		if !b.gravity[i] && !b.needsSupport[i] {
+			stable.Colors = append(stable.Colors, palette.Colors[i])
+		}
+	}
+	if len(stable.Colors) > 0 {
+		b.stable = NewCIELABMatcher(stable)
+		if m, ok := b.matcher.(*CIELABMatcher); ok {
+			b.stable.SetWeights(m.Weights())
+		}
+	}
+	return b
+}
+
+// lookup returns the palette index of the block a voxel color stands for.
+func (b *placementBlocks) lookup(color [3]uint8) int {
+	if i, ok := b.colorIndex[color]; ok {
+		return i
+	}
+	i := 0
+	if matched := b.matcher.Match(color); matched != nil {
+		i = b.colorIndex[matched.RGB]
+	}
+	b.colorIndex[color] = i
+	return i
+}
+
+// problem returns why block cannot stay at (x, y, z), or "" if it can.
+func (b *placementBlocks) problem(vg *VoxelGrid, block, x, y, z int) string {
+	if y == 0 {
+		return ""
+	}
+	below := vg.HasVoxel(x, y-1, z)
+	switch {
+	case b.gravity[block] && !below:
+		return ReasonFloating
+	case b.needsSupport[block] && !below && !vg.HasVoxel(x-1, y, z) && !vg.HasVoxel(x+1, y, z) &&
+		!vg.HasVoxel(x, y, z-1) && !vg.HasVoxel(x, y, z+1):
+		return ReasonUnattached
+	}
+	return ""
+}
+
+// stableFor returns the palette block needing no support that looks most like
+// block, or nil if the palette has none.
+func (b *placementBlocks) stableFor(block int) *PaletteColor {
+	if b.stable == nil {
+		return nil
+	}
+	return b.stable.Match(b.palette.Colors[block].RGB)
+}
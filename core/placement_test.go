@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func placementPalette() *Palette {
+	return GenerateMinecraftPalette([]MinecraftBlock{
+		{ID: "minecraft:sand", RGB: [3]uint8{219, 207, 163}},
+		{ID: "minecraft:torch", RGB: [3]uint8{255, 200, 80}},
+		{ID: "minecraft:sandstone", RGB: [3]uint8{216, 203, 155}},
+		{ID: "minecraft:stone", RGB: [3]uint8{125, 125, 125}},
+	})
+}
+
+func TestValidatePlacement(t *testing.T) {
+	palette := placementPalette()
+	sand, torch := palette.Colors[0].RGB, palette.Colors[1].RGB
+	sandstone, stone := palette.Colors[2].RGB, palette.Colors[3].RGB
+
+	// newGrid builds a stone pillar at x=0 with a sand overhang beside its top, a
+	// sand block resting on the pillar, a torch on its side and a floating torch.
+	newGrid := func() *VoxelGrid {
+		vg := NewVoxelGrid(4, 4, 4)
+		vg.SetVoxel(0, 0, 0, stone)
+		vg.SetVoxel(0, 1, 0, stone)
+		vg.SetVoxel(0, 2, 0, sand) // Supported by the pillar
+		vg.SetVoxel(1, 1, 0, sand) // Floating overhang
+		vg.SetVoxel(1, 2, 0, sand) // Rests on the overhang
+		vg.SetVoxel(0, 1, 1, torch)
+		vg.SetVoxel(3, 3, 3, torch)
+		vg.SetVoxel(3, 0, 3, sand) // Bottom layer rests on the ground
+		return vg
+	}
+
+	vg := newGrid()
+	issues, err := ValidatePlacement(vg, palette, PlacementOptions{})
+	if err != nil {
+		t.Fatalf("ValidatePlacement failed: %v", err)
+	}
+	want := []PlacementIssue{
+		{X: 1, Y: 1, Z: 0, Block: "minecraft:sand", Reason: ReasonFloating},
+		{X: 3, Y: 3, Z: 3, Block: "minecraft:torch", Reason: ReasonUnattached},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("got issues %+v, want %+v", issues, want)
+	}
+	for i := range want {
+		if issues[i] != want[i] {
+			t.Errorf("issue %d = %+v, want %+v", i, issues[i], want[i])
+		}
+	}
+	if got, _ := vg.ColorAt(1, 1, 0); got != sand {
+		t.Error("reporting without a fix changed the grid")
+	}
+
+	vg = newGrid()
+	if issues, err = ValidatePlacement(vg, palette, PlacementOptions{Fix: FixSubstitute}); err != nil {
+		t.Fatalf("ValidatePlacement failed: %v", err)
+	}
+	if len(issues) != 2 || !issues[0].Fixed || !issues[1].Fixed {
+		t.Errorf("expected two fixed issues, got %+v", issues)
+	}
+	if got, _ := vg.ColorAt(1, 1, 0); got != sandstone {
+		t.Errorf("floating sand became %v, want sandstone", got)
+	}
+	if got, _ := vg.ColorAt(1, 2, 0); got != sand {
+		t.Errorf("sand resting on the fixed block became %v", got)
+	}
+
+	vg = newGrid()
+	if issues, err = ValidatePlacement(vg, palette, PlacementOptions{Fix: FixSupport}); err != nil {
+		t.Fatalf("ValidatePlacement failed: %v", err)
+	}
+	if len(issues) != 2 {
+		t.Errorf("expected two issues, got %+v", issues)
+	}
+	if got, ok := vg.ColorAt(1, 0, 0); !ok || got != sandstone {
+		t.Errorf("support under the overhang = %v, %v, want sandstone", got, ok)
+	}
+	if got, _ := vg.ColorAt(1, 1, 0); got != sand {
+		t.Errorf("supported sand became %v", got)
+	}
+
+	if _, err := ValidatePlacement(vg, nil, PlacementOptions{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig without a palette, got %v", err)
+	}
+	if _, err := ValidatePlacement(vg, palette, PlacementOptions{Fix: "glue"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unknown fix, got %v", err)
+	}
+}
+
+func TestPipelinePlacement(t *testing.T) {
+	palette := placementPalette()
+	var reported []PlacementIssue
+	p, err := NewPipeline(WithPalette(palette), WithPlacement(&PlacementOptions{
+		Fix:      FixSubstitute,
+		OnIssues: func(issues []PlacementIssue) { reported = issues },
+	}))
+	if err != nil {
+		t.Fatalf("NewPipeline failed: %v", err)
+	}
+
+	// A sand-colored voxel floating above the ground matches sand, then is fixed
+	vg := NewVoxelGrid(1, 3, 1)
+	vg.SetVoxel(0, 2, 0, [3]uint8{220, 208, 165})
+	result, err := p.MatchColorsCtx(context.Background(), vg, p.Config)
+	if err != nil {
+		t.Fatalf("MatchColorsCtx failed: %v", err)
+	}
+	if len(reported) != 1 || !reported[0].Fixed {
+		t.Errorf("reported issues %+v, want one fixed issue", reported)
+	}
+	if got, _ := result.ColorAt(0, 2, 0); got != palette.Colors[2].RGB {
+		t.Errorf("floating voxel = %v, want sandstone", got)
+	}
+
+	if _, err := NewPipeline(WithPlacement(&PlacementOptions{})); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for placement without a palette, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// Scratch buffers shared by all pipelines, so repeated conversions reuse memory
+// instead of allocating a fresh block array, encode buffer and compressor per job.
+var (
+	scratchBytesPool  sync.Pool // *[]byte
+	scratchBufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	gzipWriterPool    = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+	ditherBufferPool  = sync.Pool{New: func() interface{} { return new(ditherBuffer) }}
+)
+
+// maxPooledBytes keeps one huge conversion from pinning its buffers in the pools.
+const maxPooledBytes = 256 << 20
+
+// getScratchBytes returns a zeroed byte slice of length n.
+func getScratchBytes(n int) []byte {
+	if p, ok := scratchBytesPool.Get().(*[]byte); ok && cap(*p) >= n {
+		b := (*p)[:n]
+		for i := range b {
+			b[i] = 0
+		}
+		return b
+	}
+	return make([]byte, n)
+}
+
+func putScratchBytes(b []byte) {
+	if cap(b) <= maxPooledBytes {
+		scratchBytesPool.Put(&b)
+	}
+}
+
+// getScratchBuffer returns an empty buffer.
+func getScratchBuffer() *bytes.Buffer {
+	buf := scratchBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putScratchBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBytes {
+		scratchBufferPool.Put(buf)
+	}
+}
+
+// getGzipWriter returns a gzip writer reset to write to w.
+func getGzipWriter(w io.Writer) *gzip.Writer {
+	gz := gzipWriterPool.Get().(*gzip.Writer)
+	gz.Reset(w)
+	return gz
+}
+
+func putGzipWriter(gz *gzip.Writer) {
+	gz.Reset(io.Discard) // drop the reference to the caller's writer
+	gzipWriterPool.Put(gz)
+}
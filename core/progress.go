@@ -0,0 +1,108 @@
+package core
+
+import "io"
+
+// Pipeline stage names reported to a ProgressReporter.
+const (
+	StageImport   = "import"
+	StageVoxelize = "voxelize"
+	StageMatch    = "match"
+	StageExport   = "export"
+)
+
+// ProgressEventType distinguishes stage start, progress, and end events.
+type ProgressEventType int
+
+const (
+	StageStarted ProgressEventType = iota
+	StageProgress
+	StageFinished
+)
+
+// ProgressEvent describes the state of a pipeline stage.
+type ProgressEvent struct {
+	Type    ProgressEventType
+	Stage   string
+	Current int64 // Units processed so far (faces, voxels, bytes)
+	Total   int64 // Total units, or 0 if unknown
+}
+
+// Percent returns the completion percentage, or -1 if the total is unknown.
+func (e ProgressEvent) Percent() float64 {
+	if e.Total <= 0 {
+		return -1
+	}
+	return float64(e.Current) * 100 / float64(e.Total)
+}
+
+// ProgressReporter receives progress events from importers, voxelizers, matchers and exporters.
+// Implementations must be safe to call from the goroutine running the conversion.
+type ProgressReporter interface {
+	Report(event ProgressEvent)
+}
+
+// ProgressFunc adapts a function to the ProgressReporter interface.
+type ProgressFunc func(event ProgressEvent)
+
+// Report calls f(event).
+func (f ProgressFunc) Report(event ProgressEvent) {
+	f(event)
+}
+
+// progressTracker reports one stage, throttling updates to roughly one per percent.
+type progressTracker struct {
+	reporter ProgressReporter
+	stage    string
+	total    int64
+	current  int64
+	step     int64
+	next     int64
+}
+
+// startStage reports the start of a stage and returns a tracker for it.
+// A nil reporter yields a tracker that does nothing.
+func startStage(reporter ProgressReporter, stage string, total int64) *progressTracker {
+	t := &progressTracker{reporter: reporter, stage: stage, total: total, step: total / 100}
+	if t.step < 1 {
+		t.step = 1
+	}
+	t.next = t.step
+	if reporter != nil {
+		reporter.Report(ProgressEvent{Type: StageStarted, Stage: stage, Total: total})
+	}
+	return t
+}
+
+// add advances the stage by n units.
+func (t *progressTracker) add(n int64) {
+	t.current += n
+	if t.reporter == nil || t.current < t.next {
+		return
+	}
+	t.next = t.current + t.step
+	t.reporter.Report(ProgressEvent{Type: StageProgress, Stage: t.stage, Current: t.current, Total: t.total})
+}
+
+// finish reports the end of the stage.
+func (t *progressTracker) finish() {
+	if t.reporter == nil {
+		return
+	}
+	current := t.current
+	if t.total > 0 {
+		current = t.total
+	}
+	t.reporter.Report(ProgressEvent{Type: StageFinished, Stage: t.stage, Current: current, Total: t.total})
+}
+
+// progressReader reports bytes read through it to a tracker.
+type progressReader struct {
+	r       io.Reader
+	tracker *progressTracker
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.tracker.add(int64(n))
+	return n, err
+}
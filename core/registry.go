@@ -0,0 +1,407 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GridExporter writes a voxel grid to an output format.
+type GridExporter interface {
+	// Export writes the voxel grid to w.
+	Export(vg *VoxelGrid, w io.Writer) error
+}
+
+// ImporterFactory creates a mesh importer.
+type ImporterFactory func() MeshImporter
+
+// ExporterFactory creates a grid exporter for the given pipeline configuration.
+type ExporterFactory func(config PipelineConfig) GridExporter
+
+// VoxelizerFactory creates a voxelizer.
+type VoxelizerFactory func() Voxelizer
+
+// MatcherFactory creates a color matcher for the given palette.
+type MatcherFactory func(palette *Palette) ColorMatcher
+
+// registry holds named factories and the file extensions mapped to them.
+type registry struct {
+	mu           sync.RWMutex
+	importers    map[string]ImporterFactory
+	importerExts map[string]string
+	exporters    map[string]ExporterFactory
+	exporterExts map[string]string
+	voxelizers   map[string]VoxelizerFactory
+	matchers     map[string]MatcherFactory
+}
+
+var plugins = &registry{
+	importers:    make(map[string]ImporterFactory),
+	importerExts: make(map[string]string),
+	exporters:    make(map[string]ExporterFactory),
+	exporterExts: make(map[string]string),
+	voxelizers:   make(map[string]VoxelizerFactory),
+	matchers:     make(map[string]MatcherFactory),
+}
+
+func init() {
+	RegisterImporter("gltf", func() MeshImporter { return NewGLTFImporter() }, ".gltf", ".glb", ".vrm")
+	RegisterImporter("obj", func() MeshImporter { return NewOBJImporter() }, ".obj")
+	RegisterImporter("ply", func() MeshImporter { return NewPLYImporter() }, ".ply")
+	RegisterImporter("xyz", func() MeshImporter { return NewXYZImporter() }, ".xyz")
+	RegisterImporter("las", func() MeshImporter { return NewLASImporter() }, ".las")
+	RegisterImporter("fbx", func() MeshImporter { return NewFBXImporter() }, ".fbx")
+	RegisterImporter("collada", func() MeshImporter { return NewColladaImporter() }, ".dae")
+
+	RegisterExporter("vox", func(config PipelineConfig) GridExporter {
+		exporter := NewVOXExporter()
+		exporter.Progress = config.Progress
+		return exporter
+	}, ".vox")
+	RegisterExporter("qubicle", func(config PipelineConfig) GridExporter {
+		exporter := NewQubicleExporter()
+		exporter.Progress = config.Progress
+		return exporter
+	}, ".qb")
+	RegisterExporter("binvox", func(config PipelineConfig) GridExporter {
+		exporter := NewBinvoxExporter()
+		exporter.Progress = config.Progress
+		return exporter
+	}, ".binvox")
+	RegisterExporter("schematic", func(config PipelineConfig) GridExporter {
+		exporter := NewSchematicExporter(config.Schematic.Version)
+		exporter.DataVersion = config.Schematic.DataVersion
+		exporter.Detail = config.Detail
+		exporter.AirMode = config.Schematic.AirMode
+		exporter.PasteOrigin = config.Schematic.PasteOrigin
+		exporter.Offset = config.Schematic.Offset
+		exporter.Rotation = config.Schematic.Rotation
+		exporter.Progress = config.Progress
+		return &schematicGridExporter{exporter: exporter, palette: config.Palette, dithering: config.Dithering}
+	}, ".schem")
+	RegisterExporter("structure", func(config PipelineConfig) GridExporter {
+		exporter := NewStructureExporter()
+		exporter.DataVersion = config.Schematic.DataVersion
+		exporter.Detail = config.Detail
+		exporter.AirMode = config.Schematic.AirMode
+		exporter.Rotation = config.Schematic.Rotation
+		exporter.Progress = config.Progress
+		return &structureGridExporter{exporter: exporter, palette: config.Palette}
+	}, ".nbt")
+	RegisterExporter("mcedit", func(config PipelineConfig) GridExporter {
+		exporter := NewMCEditExporter()
+		exporter.AirMode = config.Schematic.AirMode
+		exporter.PasteOrigin = config.Schematic.PasteOrigin
+		exporter.Offset = config.Schematic.Offset
+		exporter.Progress = config.Progress
+		return &mceditGridExporter{exporter: exporter, palette: config.Palette}
+	}, ".schematic")
+	RegisterExporter("minetest", func(config PipelineConfig) GridExporter {
+		exporter := NewMinetestExporter()
+		exporter.AirMode = config.Schematic.AirMode
+		exporter.Progress = config.Progress
+		return &minetestGridExporter{exporter: exporter, palette: config.Palette}
+	}, ".mts")
+	RegisterExporter("mcfunction", func(config PipelineConfig) GridExporter {
+		exporter := NewFunctionExporter()
+		exporter.Namespace = config.Function.Namespace
+		exporter.MaxCommands = config.Function.MaxCommands
+		exporter.Boxes = config.Function.Boxes
+		exporter.Detail = config.Detail
+		exporter.Progress = config.Progress
+		return &functionGridExporter{exporter: exporter, palette: config.Palette}
+	}, ".mcfunction")
+	RegisterExporter("worldedit", func(config PipelineConfig) GridExporter {
+		exporter := NewWorldEditExporter(config.Function.Origin)
+		exporter.Detail = config.Detail
+		exporter.Progress = config.Progress
+		return &worldEditGridExporter{exporter: exporter, palette: config.Palette}
+	}, ".txt")
+	RegisterExporter("datapack", func(config PipelineConfig) GridExporter {
+		exporter := NewFunctionExporter()
+		exporter.Namespace = config.Function.Namespace
+		exporter.MaxCommands = config.Function.MaxCommands
+		exporter.Boxes = config.Function.Boxes
+		exporter.Detail = config.Detail
+		exporter.Progress = config.Progress
+		return &functionGridExporter{exporter: exporter, palette: config.Palette, datapack: true}
+	}, ".zip")
+	RegisterExporter("obj", func(config PipelineConfig) GridExporter {
+		exporter := NewOBJExporter()
+		exporter.Progress = config.Progress
+		return exporter
+	}, ".obj")
+	RegisterExporter("gltf", func(config PipelineConfig) GridExporter {
+		exporter := NewGLTFExporter(false)
+		exporter.Progress = config.Progress
+		return exporter
+	}, ".gltf")
+	RegisterExporter("glb", func(config PipelineConfig) GridExporter {
+		exporter := NewGLTFExporter(true)
+		exporter.Progress = config.Progress
+		return exporter
+	}, ".glb")
+
+	RegisterVoxelizer("surface", func() Voxelizer { return NewSurfaceVoxelizer() })
+	RegisterVoxelizer("solid", func() Voxelizer { return NewSolidVoxelizer() })
+	RegisterVoxelizer("points", func() Voxelizer { return NewPointCloudVoxelizer() })
+	RegisterVoxelizer("sdf", func() Voxelizer { return NewSDFVoxelizer() })
+
+	RegisterMatcher("cielab", func(palette *Palette) ColorMatcher { return NewCIELABMatcher(palette) })
+	RegisterMatcher("texture", func(palette *Palette) ColorMatcher { return NewTextureMatcher(palette) })
+}
+
+// RegisterImporter registers a mesh importer under a name and the file extensions it handles.
+// Registering an existing name or extension replaces the previous entry.
+func RegisterImporter(name string, factory ImporterFactory, extensions ...string) {
+	plugins.mu.Lock()
+	defer plugins.mu.Unlock()
+
+	plugins.importers[name] = factory
+	for _, ext := range extensions {
+		plugins.importerExts[normalizeExt(ext)] = name
+	}
+}
+
+// RegisterExporter registers a grid exporter under a name and the file extensions it writes.
+// Registering an existing name or extension replaces the previous entry.
+func RegisterExporter(name string, factory ExporterFactory, extensions ...string) {
+	plugins.mu.Lock()
+	defer plugins.mu.Unlock()
+
+	plugins.exporters[name] = factory
+	for _, ext := range extensions {
+		plugins.exporterExts[normalizeExt(ext)] = name
+	}
+}
+
+// RegisterVoxelizer registers a voxelization algorithm under a name.
+func RegisterVoxelizer(name string, factory VoxelizerFactory) {
+	plugins.mu.Lock()
+	defer plugins.mu.Unlock()
+
+	plugins.voxelizers[name] = factory
+}
+
+// RegisterMatcher registers a color matching algorithm under a name.
+func RegisterMatcher(name string, factory MatcherFactory) {
+	plugins.mu.Lock()
+	defer plugins.mu.Unlock()
+
+	plugins.matchers[name] = factory
+}
+
+// NewImporter creates the importer registered under name.
+func NewImporter(name string) (MeshImporter, error) {
+	plugins.mu.RLock()
+	factory, ok := plugins.importers[name]
+	plugins.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown importer: %s", name)
+	}
+	return factory(), nil
+}
+
+// NewImporterForFile creates the importer registered for the file's extension.
+func NewImporterForFile(filename string) (MeshImporter, error) {
+	ext := normalizeExt(filepath.Ext(filename))
+
+	plugins.mu.RLock()
+	name, ok := plugins.importerExts[ext]
+	plugins.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: no importer for %q", ErrUnsupportedFormat, ext)
+	}
+	return NewImporter(name)
+}
+
+// NewExporter creates the exporter registered under name.
+func NewExporter(name string, config PipelineConfig) (GridExporter, error) {
+	plugins.mu.RLock()
+	factory, ok := plugins.exporters[name]
+	plugins.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown exporter: %s", name)
+	}
+	return factory(config), nil
+}
+
+// NewExporterForFile creates the exporter registered for the file's extension.
+func NewExporterForFile(filename string, config PipelineConfig) (GridExporter, error) {
+	ext := normalizeExt(filepath.Ext(filename))
+
+	plugins.mu.RLock()
+	name, ok := plugins.exporterExts[ext]
+	plugins.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: no exporter for %q", ErrUnsupportedFormat, ext)
+	}
+	return NewExporter(name, config)
+}
+
+// NewVoxelizerByName creates the voxelizer registered under name.
+func NewVoxelizerByName(name string) (Voxelizer, error) {
+	plugins.mu.RLock()
+	factory, ok := plugins.voxelizers[name]
+	plugins.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown voxelizer: %s", name)
+	}
+	return factory(), nil
+}
+
+// NewMatcherByName creates the color matcher registered under name.
+func NewMatcherByName(name string, palette *Palette) (ColorMatcher, error) {
+	plugins.mu.RLock()
+	factory, ok := plugins.matchers[name]
+	plugins.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown matcher: %s", name)
+	}
+	return factory(palette), nil
+}
+
+// ImporterNames returns the registered importer names in sorted order.
+func ImporterNames() []string {
+	plugins.mu.RLock()
+	defer plugins.mu.RUnlock()
+	return sortedKeys(plugins.importers)
+}
+
+// ImporterExtensions returns the file extensions with a registered importer in sorted order.
+func ImporterExtensions() []string {
+	plugins.mu.RLock()
+	defer plugins.mu.RUnlock()
+	return sortedKeys(plugins.importerExts)
+}
+
+// ExporterNames returns the registered exporter names in sorted order.
+func ExporterNames() []string {
+	plugins.mu.RLock()
+	defer plugins.mu.RUnlock()
+	return sortedKeys(plugins.exporters)
+}
+
+// ExporterExtensions returns the file extensions with a registered exporter in sorted order.
+func ExporterExtensions() []string {
+	plugins.mu.RLock()
+	defer plugins.mu.RUnlock()
+	return sortedKeys(plugins.exporterExts)
+}
+
+// VoxelizerNames returns the registered voxelizer names in sorted order.
+func VoxelizerNames() []string {
+	plugins.mu.RLock()
+	defer plugins.mu.RUnlock()
+	return sortedKeys(plugins.voxelizers)
+}
+
+// MatcherNames returns the registered matcher names in sorted order.
+func MatcherNames() []string {
+	plugins.mu.RLock()
+	defer plugins.mu.RUnlock()
+	return sortedKeys(plugins.matchers)
+}
+
+// schematicGridExporter adapts SchematicExporterImpl to the GridExporter interface.
+type schematicGridExporter struct {
+	exporter  *SchematicExporterImpl
+	palette   *Palette
+	dithering DitherConfig
+}
+
+func (e *schematicGridExporter) Export(vg *VoxelGrid, w io.Writer) error {
+	return e.exporter.Export(vg, e.palette, e.dithering, w)
+}
+
+func (e *schematicGridExporter) Materials() *MaterialReport {
+	return e.exporter.Materials()
+}
+
+// structureGridExporter adapts StructureExporterImpl to the GridExporter interface.
+type structureGridExporter struct {
+	exporter *StructureExporterImpl
+	palette  *Palette
+}
+
+func (e *structureGridExporter) Export(vg *VoxelGrid, w io.Writer) error {
+	return e.exporter.Export(vg, e.palette, w)
+}
+
+// mceditGridExporter adapts MCEditExporterImpl to the GridExporter interface.
+type mceditGridExporter struct {
+	exporter *MCEditExporterImpl
+	palette  *Palette
+}
+
+func (e *mceditGridExporter) Export(vg *VoxelGrid, w io.Writer) error {
+	return e.exporter.Export(vg, e.palette, w)
+}
+
+// minetestGridExporter adapts MinetestExporterImpl to the GridExporter interface.
+type minetestGridExporter struct {
+	exporter *MinetestExporterImpl
+	palette  *Palette
+}
+
+func (e *minetestGridExporter) Export(vg *VoxelGrid, w io.Writer) error {
+	return e.exporter.Export(vg, e.palette, w)
+}
+
+// functionGridExporter adapts FunctionExporterImpl to the GridExporter interface.
+type functionGridExporter struct {
+	exporter *FunctionExporterImpl
+	palette  *Palette
+	datapack bool // Write a datapack zip rather than a single function
+}
+
+func (e *functionGridExporter) Export(vg *VoxelGrid, w io.Writer) error {
+	if e.datapack {
+		return e.exporter.ExportDatapack(vg, e.palette, w)
+	}
+	return e.exporter.Export(vg, e.palette, w)
+}
+
+func (e *functionGridExporter) CommandStats() CommandStats {
+	return e.exporter.CommandStats()
+}
+
+// worldEditGridExporter adapts WorldEditExporterImpl to the GridExporter interface.
+type worldEditGridExporter struct {
+	exporter *WorldEditExporterImpl
+	palette  *Palette
+}
+
+func (e *worldEditGridExporter) Export(vg *VoxelGrid, w io.Writer) error {
+	return e.exporter.Export(vg, e.palette, w)
+}
+
+func (e *worldEditGridExporter) CommandStats() CommandStats {
+	return e.exporter.CommandStats()
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"io"
+	"testing"
+)
+
+type stubImporter struct{}
+
+func (stubImporter) Import(r io.Reader) (*Mesh, error) { return &Mesh{}, nil }
+func (stubImporter) SupportedFormats() []string        { return []string{".stub"} }
+
+func TestRegistryDefaults(t *testing.T) {
+	if _, err := NewImporterForFile("model.GLB"); err != nil {
+		t.Errorf("glb importer not found: %v", err)
+	}
+	if _, err := NewExporterForFile("out.vox", PipelineConfig{}); err != nil {
+		t.Errorf("vox exporter not found: %v", err)
+	}
+	if _, err := NewVoxelizerByName("surface"); err != nil {
+		t.Errorf("surface voxelizer not found: %v", err)
+	}
+	if _, err := NewMatcherByName("cielab", GenerateMinecraftPalette(nil)); err != nil {
+		t.Errorf("cielab matcher not found: %v", err)
+	}
+}
+
+func TestRegistryUnknown(t *testing.T) {
+	if _, err := NewImporterForFile("model.abc"); err == nil {
+		t.Error("expected error for unknown extension")
+	}
+	if _, err := NewVoxelizerByName("missing"); err == nil {
+		t.Error("expected error for unknown voxelizer")
+	}
+	if _, err := NewMatcherByName("missing", nil); err == nil {
+		t.Error("expected error for unknown matcher")
+	}
+	if _, err := NewExporter("missing", PipelineConfig{}); err == nil {
+		t.Error("expected error for unknown exporter")
+	}
+}
+
+func TestRegisterImporter(t *testing.T) {
+	RegisterImporter("stub", func() MeshImporter { return stubImporter{} }, "STUB", ".stb")
+	t.Cleanup(func() {
+		plugins.mu.Lock()
+		delete(plugins.importers, "stub")
+		delete(plugins.importerExts, ".stub")
+		delete(plugins.importerExts, ".stb")
+		plugins.mu.Unlock()
+	})
+
+	for _, name := range []string{"a.stub", "a.STUB", "a.stb"} {
+		importer, err := NewImporterForFile(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, ok := importer.(stubImporter); !ok {
+			t.Errorf("%s: got %T, want stubImporter", name, importer)
+		}
+	}
+
+	if !containsName(ImporterNames(), "stub") {
+		t.Errorf("ImporterNames() = %v, missing stub", ImporterNames())
+	}
+	if !containsName(ImporterExtensions(), ".stub") {
+		t.Errorf("ImporterExtensions() = %v, missing .stub", ImporterExtensions())
+	}
+}
+
+func TestNormalizeExt(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{".GLB", ".glb"},
+		{"obj", ".obj"},
+		{".vox", ".vox"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeExt(tt.in); got != tt.want {
+			t.Errorf("normalizeExt(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
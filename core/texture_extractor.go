@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"image"
+	"io"
+	"math"
 	_ "image/jpeg"
 	_ "image/png"
 	"os"
@@ -13,7 +15,11 @@ import (
 )
 
 // TextureExtractor extracts block textures and calculates average colors.
+// Grass, leaves and water textures are grayscale and tinted in game; their
+// colors are tinted for Biome, read from the pack's colormaps when it has them.
 type TextureExtractor struct {
+	Biome string // Biome whose tints apply, one of Biomes() (default plains)
+	
 	blockModels map[string]BlockModel
 	textures    map[string]image.Image
 }
@@ -33,6 +39,7 @@ type BlockStateDefinition struct {
 // NewTextureExtractor creates a new texture extractor.
 func NewTextureExtractor() *TextureExtractor {
 	return &TextureExtractor{
+		Biome:       BiomePlains,
 		blockModels: make(map[string]BlockModel),
 		textures:    make(map[string]image.Image),
 	}
@@ -56,6 +63,16 @@ func (te *TextureExtractor) ExtractFromJar(jarPath string) ([]MinecraftBlock, er
 	return te.extractFromZip(jarPath)
 }
 
+// ExtractFromZip extracts blocks from a zip archive (jar or resource pack) of
+// the given size, such as an uploaded file.
+func (te *TextureExtractor) ExtractFromZip(r io.ReaderAt, size int64) ([]MinecraftBlock, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip: %w", err)
+	}
+	return te.extractFromZipReader(zr)
+}
+
 // extractFromZip extracts blocks from a zip file (jar or resource pack).
 func (te *TextureExtractor) extractFromZip(zipPath string) ([]MinecraftBlock, error) {
 	r, err := zip.OpenReader(zipPath)
@@ -63,10 +80,15 @@ func (te *TextureExtractor) extractFromZip(zipPath string) ([]MinecraftBlock, er
 		return nil, fmt.Errorf("failed to open zip: %w", err)
 	}
 	defer r.Close()
-	
+	return te.extractFromZipReader(&r.Reader)
+}
+
+// extractFromZipReader loads the textures and block models of an opened zip.
+func (te *TextureExtractor) extractFromZipReader(r *zip.Reader) ([]MinecraftBlock, error) {
 	// Load textures
 	for _, f := range r.File {
-		if strings.HasPrefix(f.Name, "assets/minecraft/textures/block/") && 
+		if (strings.HasPrefix(f.Name, "assets/minecraft/textures/block/") ||
+			strings.HasPrefix(f.Name, "assets/minecraft/textures/colormap/")) &&
 		   (strings.HasSuffix(f.Name, ".png") || strings.HasSuffix(f.Name, ".jpg")) {
 			
 			rc, err := f.Open()
@@ -118,10 +140,14 @@ func (te *TextureExtractor) extractFromZip(zipPath string) ([]MinecraftBlock, er
 
 // extractFromDirectory extracts blocks from a directory.
 func (te *TextureExtractor) extractFromDirectory(dirPath string) ([]MinecraftBlock, error) {
-	// Load textures
-	texturesDir := filepath.Join(dirPath, "assets", "minecraft", "textures", "block")
-	if _, err := os.Stat(texturesDir); err == nil {
-		err = filepath.Walk(texturesDir, func(path string, info os.FileInfo, err error) error {
+	// Load block textures and biome colormaps
+	for _, dir := range []string{"block", "colormap"} {
+		texturesDir := filepath.Join(dirPath, "assets", "minecraft", "textures", dir)
+		if _, err := os.Stat(texturesDir); err != nil {
+			continue
+		}
+		
+		err := filepath.Walk(texturesDir, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
@@ -202,6 +228,11 @@ func (te *TextureExtractor) extractFromDirectory(dirPath string) ([]MinecraftBlo
 
 // generateBlocksFromModels generates MinecraftBlock entries from loaded models and textures.
 func (te *TextureExtractor) generateBlocksFromModels() ([]MinecraftBlock, error) {
+	tints, err := te.tints()
+	if err != nil {
+		return nil, err
+	}
+	
 	var blocks []MinecraftBlock
 	
 	for modelName, model := range te.blockModels {
@@ -217,12 +248,30 @@ func (te *TextureExtractor) generateBlocksFromModels() ([]MinecraftBlock, error)
 		}
 		
 		// Calculate average color
-		avgColor := te.calculateAverageColor(img)
+		avgColor := te.textureColor(texturePath, img, tints)
 		
 		block := MinecraftBlock{
-			ID:         "minecraft:" + modelName,
-			RGB:        avgColor,
-			Properties: make(map[string]string),
+			ID:           "minecraft:" + modelName,
+			RGB:          avgColor,
+			Properties:   make(map[string]string),
+			Translucency: te.calculateTranslucency(img),
+			Noise:        te.calculateNoise(img),
+		}
+		
+		// Record faces whose texture differs, such as log ends and grass tops
+		for face, keys := range faceTextureKeys {
+			facePath := te.resolveTextureKeys(model, keys)
+			if facePath == "" || facePath == texturePath {
+				continue
+			}
+			if faceImg, ok := te.textures[facePath]; ok {
+				if faceColor := te.textureColor(facePath, faceImg, tints); faceColor != avgColor {
+					if block.Faces == nil {
+						block.Faces = make(map[BlockFace][3]uint8)
+					}
+					block.Faces[face] = faceColor
+				}
+			}
 		}
 		
 		blocks = append(blocks, block)
@@ -231,11 +280,23 @@ func (te *TextureExtractor) generateBlocksFromModels() ([]MinecraftBlock, error)
 	return blocks, nil
 }
 
+// faceTextureKeys lists, per block face, the model texture variables that cover it
+// in vanilla parent models (cube, cube_column, cube_bottom_top, orientable).
+var faceTextureKeys = map[BlockFace][]string{
+	FaceTop:    {"up", "top", "end"},
+	FaceSide:   {"side", "north", "front"},
+	FaceBottom: {"down", "bottom", "end"},
+}
+
 // resolveTexture resolves the primary texture path from a block model.
 func (te *TextureExtractor) resolveTexture(model BlockModel) string {
 	// Try common texture keys
-	keys := []string{"all", "texture", "particle", "side", "top", "front"}
-	
+	return te.resolveTextureKeys(model, []string{"all", "texture", "particle", "side", "top", "front"})
+}
+
+// resolveTextureKeys resolves the texture of the first of keys the model or its
+// parents define.
+func (te *TextureExtractor) resolveTextureKeys(model BlockModel, keys []string) string {
 	for _, key := range keys {
 		if texture, ok := model.Textures[key]; ok {
 			return te.resolveTextureReference(texture, model)
@@ -246,7 +307,7 @@ func (te *TextureExtractor) resolveTexture(model BlockModel) string {
 	if model.Parent != "" {
 		parentName := strings.TrimPrefix(model.Parent, "minecraft:block/")
 		if parent, ok := te.blockModels[parentName]; ok {
-			return te.resolveTexture(parent)
+			return te.resolveTextureKeys(parent, keys)
 		}
 	}
 	
@@ -269,11 +330,21 @@ func (te *TextureExtractor) resolveTextureReference(texture string, model BlockM
 	return texture
 }
 
-// calculateAverageColor calculates the average color of an image.
+// textureColor is the average color of a texture as it shows in game, tinted if
+// the texture is.
+func (te *TextureExtractor) textureColor(texture string, img image.Image, tints biomeTints) [3]uint8 {
+	avgColor := te.calculateAverageColor(img)
+	if tint, ok := tints.forTexture(texture); ok {
+		return multiplyColor(avgColor, tint)
+	}
+	return avgColor
+}
+
+// calculateAverageColor calculates the average color of an image, weighting
+// each pixel by its alpha so the frame of a glass pane counts for what it covers.
 func (te *TextureExtractor) calculateAverageColor(img image.Image) [3]uint8 {
 	bounds := img.Bounds()
-	var r, g, b uint64
-	var count uint64
+	var r, g, b, a uint64
 	
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
@@ -285,23 +356,62 @@ func (te *TextureExtractor) calculateAverageColor(img image.Image) [3]uint8 {
 				continue
 			}
 			
-			// Convert from 16-bit to 8-bit
-			r += uint64(pr >> 8)
-			g += uint64(pg >> 8)
-			b += uint64(pb >> 8)
-			count++
+			// Colors are premultiplied by alpha
+			r += uint64(pr)
+			g += uint64(pg)
+			b += uint64(pb)
+			a += uint64(pa)
 		}
 	}
 	
-	if count == 0 {
+	if a == 0 {
 		return [3]uint8{128, 128, 128}
 	}
 	
 	return [3]uint8{
-		uint8(r / count),
-		uint8(g / count),
-		uint8(b / count),
+		uint8(r * 255 / a),
+		uint8(g * 255 / a),
+		uint8(b * 255 / a),
+	}
+}
+
+// calculateNoise returns the RMS CIELAB distance of an image's visible pixels
+// from their mean, rounded to thousandths.
+func (te *TextureExtractor) calculateNoise(img image.Image) float64 {
+	bounds := img.Bounds()
+	var labs []LABColor
+	var sum LABColor
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pr, pg, pb, pa := img.At(x, y).RGBA()
+			if pa == 0 {
+				continue
+			}
+			// Undo the alpha premultiplication
+			lab := RGBToLAB([3]uint8{uint8(pr * 255 / pa), uint8(pg * 255 / pa), uint8(pb * 255 / pa)})
+			labs = append(labs, lab)
+			sum.L, sum.A, sum.B = sum.L+lab.L, sum.A+lab.A, sum.B+lab.B
+		}
+	}
+	return math.Round(labRMS(labs, sum)*1000) / 1000
+}
+
+// calculateTranslucency returns the fraction of light an image lets through:
+// one minus its mean alpha, rounded to hundredths.
+func (te *TextureExtractor) calculateTranslucency(img image.Image) float64 {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return 0
+	}
+	var alpha uint64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, pa := img.At(x, y).RGBA()
+			alpha += uint64(pa)
+		}
 	}
+	opacity := float64(alpha) / float64(uint64(bounds.Dx()*bounds.Dy())*0xffff)
+	return math.Round((1-opacity)*100) / 100
 }
 
 // LoadBlocksFromJSON loads block definitions from a JSON file.
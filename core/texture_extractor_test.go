@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"image"
 	"image/color"
 	"testing"
@@ -124,3 +125,161 @@ func TestResolveTexture(t *testing.T) {
 		t.Errorf("Expected 'block/wood', got '%s'", texture)
 	}
 }
+
+func TestExtractFaceColors(t *testing.T) {
+	te := NewTextureExtractor()
+	
+	solid := func(c color.RGBA) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 2; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		return img
+	}
+	te.textures["block/oak_log"] = solid(color.RGBA{100, 80, 50, 255})
+	te.textures["block/oak_log_top"] = solid(color.RGBA{160, 130, 80, 255})
+	te.textures["block/stone"] = solid(color.RGBA{125, 125, 125, 255})
+	te.blockModels["cube_column"] = BlockModel{Parent: "block/cube"}
+	te.blockModels["oak_log"] = BlockModel{
+		Parent:   "minecraft:block/cube_column",
+		Textures: map[string]string{"end": "minecraft:block/oak_log_top", "side": "minecraft:block/oak_log"},
+	}
+	te.blockModels["stone"] = BlockModel{Textures: map[string]string{"all": "block/stone"}}
+	
+	blocks, err := te.generateBlocksFromModels()
+	if err != nil {
+		t.Fatalf("generateBlocksFromModels failed: %v", err)
+	}
+	byID := make(map[string]MinecraftBlock)
+	for _, block := range blocks {
+		byID[block.ID] = block
+	}
+	
+	log := byID["minecraft:oak_log"]
+	if log.RGB != [3]uint8{100, 80, 50} {
+		t.Errorf("oak_log color = %v, want the side texture", log.RGB)
+	}
+	want := map[BlockFace][3]uint8{FaceTop: {160, 130, 80}, FaceBottom: {160, 130, 80}}
+	if len(log.Faces) != len(want) || log.Faces[FaceTop] != want[FaceTop] || log.Faces[FaceBottom] != want[FaceBottom] {
+		t.Errorf("oak_log faces = %v, want %v", log.Faces, want)
+	}
+	if stone := byID["minecraft:stone"]; stone.Faces != nil {
+		t.Errorf("stone has face colors %v, want none", stone.Faces)
+	}
+}
+
+func TestExtractBiomeTints(t *testing.T) {
+	solid := func(c color.RGBA) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 2; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		return img
+	}
+	gray := color.RGBA{255, 255, 255, 255}
+	extract := func(biome string, colormap image.Image) (map[string]MinecraftBlock, error) {
+		te := NewTextureExtractor()
+		te.Biome = biome
+		te.textures["block/oak_leaves"] = solid(gray)
+		te.textures["block/birch_leaves"] = solid(gray)
+		te.textures["block/water_still"] = solid(gray)
+		te.textures["block/stone"] = solid(color.RGBA{125, 125, 125, 255})
+		if colormap != nil {
+			te.textures["colormap/foliage"] = colormap
+		}
+		for _, name := range []string{"oak_leaves", "birch_leaves", "stone"} {
+			te.blockModels[name] = BlockModel{Textures: map[string]string{"all": "block/" + name}}
+		}
+		te.blockModels["water"] = BlockModel{Textures: map[string]string{"particle": "block/water_still"}}
+		
+		blocks, err := te.generateBlocksFromModels()
+		byID := make(map[string]MinecraftBlock)
+		for _, block := range blocks {
+			byID[block.ID] = block
+		}
+		return byID, err
+	}
+	
+	blocks, err := extract("", nil)
+	if err != nil {
+		t.Fatalf("generateBlocksFromModels failed: %v", err)
+	}
+	tests := []struct {
+		id   string
+		want [3]uint8
+	}{
+		{"minecraft:oak_leaves", plainsFoliage},
+		{"minecraft:birch_leaves", [3]uint8{0x80, 0xa7, 0x55}},
+		{"minecraft:water", defaultWater},
+		{"minecraft:stone", [3]uint8{125, 125, 125}},
+	}
+	for _, tt := range tests {
+		if got := blocks[tt.id].RGB; got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+	
+	// Desert sits in the bottom left corner of the colormap
+	colormap := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	colormap.Set(0, 255, color.RGBA{200, 180, 60, 255})
+	blocks, err = extract("desert", colormap)
+	if err != nil {
+		t.Fatalf("generateBlocksFromModels failed: %v", err)
+	}
+	if got, want := blocks["minecraft:oak_leaves"].RGB, [3]uint8{200, 180, 60}; got != want {
+		t.Errorf("desert oak_leaves = %v, want %v", got, want)
+	}
+	
+	if _, err := extract("nether", nil); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unknown biome, got %v", err)
+	}
+}
+
+func TestCalculateTranslucency(t *testing.T) {
+	te := NewTextureExtractor()
+	
+	// A pane: opaque frame pixels, a half transparent pixel and a hole
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.NRGBA{200, 100, 50, 255})
+	img.Set(1, 0, color.NRGBA{200, 100, 50, 255})
+	img.Set(0, 1, color.NRGBA{200, 100, 50, 128})
+	img.Set(1, 1, color.NRGBA{0, 0, 0, 0})
+	
+	if got := te.calculateTranslucency(img); got != 0.37 {
+		t.Errorf("translucency = %v, want 0.37", got)
+	}
+	// The half transparent pixel keeps its color rather than darkening the average
+	avg := te.calculateAverageColor(img)
+	for i, want := range [3]uint8{200, 100, 50} {
+		if diff := int(avg[i]) - int(want); diff < -1 || diff > 1 {
+			t.Errorf("average color = %v, want ~%v", avg, [3]uint8{200, 100, 50})
+			break
+		}
+	}
+	if got := te.calculateTranslucency(image.NewRGBA(image.Rect(0, 0, 0, 0))); got != 0 {
+		t.Errorf("empty image translucency = %v, want 0", got)
+	}
+}
+
+func TestCalculateNoise(t *testing.T) {
+	te := NewTextureExtractor()
+	
+	smooth := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	speckled := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			smooth.Set(x, y, color.RGBA{120, 120, 120, 255})
+			speckled.Set(x, y, color.RGBA{uint8(80 + 80*((x+y)%2)), 120, 120, 255})
+		}
+	}
+	if got := te.calculateNoise(smooth); got != 0 {
+		t.Errorf("smooth texture noise = %v, want 0", got)
+	}
+	if got := te.calculateNoise(speckled); got <= 0.01 {
+		t.Errorf("speckled texture noise = %v, want well above 0", got)
+	}
+}
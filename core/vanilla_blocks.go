@@ -0,0 +1,207 @@
+package core
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LatestMinecraftVersion is the newest Minecraft Java version whose blocks the
+// bundled vanilla palette covers.
+const LatestMinecraftVersion = "1.21.4"
+
+// oldestMinecraftVersion is the first version the bundled palette covers; it
+// is the last one before the flattening, which the block IDs follow.
+const oldestMinecraftVersion = "1.12"
+
+// vanillaBlocksCSV lists the bundled vanilla blocks as block_id, red, green,
+// blue and since columns, where since is the version that added the block.
+// Rows are ordered by since.
+//
+//go:embed vanilla_blocks.csv
+var vanillaBlocksCSV string
+
+// minecraftDataVersionsCSV lists the data versions of Minecraft releases as
+// version and data_version columns, oldest first.
+//
+//go:embed minecraft_data_versions.csv
+var minecraftDataVersionsCSV string
+
+// FlatteningDataVersion is the data version of Minecraft 1.13, the first whose
+// block IDs, such as minecraft:white_wool, Sponge schematics and structure
+// files hold.
+const FlatteningDataVersion = 1519
+
+// vanillaBlock is a bundled vanilla block and the version that added it.
+type vanillaBlock struct {
+	block MinecraftBlock
+	since [3]int
+}
+
+// minecraftDataVersion is a release and its data version.
+type minecraftDataVersion struct {
+	version     [3]int
+	dataVersion int
+}
+
+var minecraftDataVersions = sync.OnceValue(func() []minecraftDataVersion {
+	records, err := csv.NewReader(strings.NewReader(minecraftDataVersionsCSV)).ReadAll()
+	if err != nil {
+		panic(err) // The embedded data is checked by the tests
+	}
+	versions := make([]minecraftDataVersion, 0, len(records)-1)
+	for _, record := range records[1:] {
+		version, err := parseMinecraftVersion(record[0])
+		if err != nil {
+			panic(err)
+		}
+		dataVersion, err := strconv.Atoi(record[1])
+		if err != nil {
+			panic(err)
+		}
+		versions = append(versions, minecraftDataVersion{version, dataVersion})
+	}
+	return versions
+})
+
+var vanillaBlocks = sync.OnceValue(func() []vanillaBlock {
+	blocks, err := parseVanillaBlocks(vanillaBlocksCSV)
+	if err != nil {
+		panic(err) // The embedded data is checked by the tests
+	}
+	return blocks
+})
+
+// parseVanillaBlocks parses the bundled block color dataset.
+func parseVanillaBlocks(data string) ([]vanillaBlock, error) {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("vanilla blocks: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("vanilla blocks: no header")
+	}
+	blocks := make([]vanillaBlock, 0, len(records)-1)
+	for line, record := range records[1:] {
+		if len(record) != 5 {
+			return nil, fmt.Errorf("vanilla blocks: line %d: %d columns, want 5", line+2, len(record))
+		}
+		var rgb [3]uint8
+		for i := range rgb {
+			channel, err := strconv.ParseUint(record[1+i], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("vanilla blocks: line %d: %w", line+2, err)
+			}
+			rgb[i] = uint8(channel)
+		}
+		since, err := parseMinecraftVersion(record[4])
+		if err != nil {
+			return nil, fmt.Errorf("vanilla blocks: line %d: %w", line+2, err)
+		}
+		blocks = append(blocks, vanillaBlock{
+			block: MinecraftBlock{ID: record[0], RGB: rgb},
+			since: since,
+		})
+	}
+	return blocks, nil
+}
+
+// parseMinecraftVersion parses a "major.minor[.patch]" version number.
+func parseMinecraftVersion(version string) ([3]int, error) {
+	var parsed [3]int
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return parsed, fmt.Errorf("%w: Minecraft version %q is not major.minor[.patch]", ErrInvalidConfig, version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, fmt.Errorf("%w: Minecraft version %q is not major.minor[.patch]", ErrInvalidConfig, version)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
+
+// VanillaBlocks returns the bundled vanilla blocks present in a Minecraft Java
+// version, such as "1.16" or "1.20.4": wool, concrete, terracotta, planks,
+// stones, copper and other full blocks with their average colors. An empty
+// version or "latest" selects every block, as do versions newer than
+// LatestMinecraftVersion; versions before 1.12 are rejected.
+func VanillaBlocks(version string) ([]MinecraftBlock, error) {
+	if version == "" || version == "latest" {
+		version = LatestMinecraftVersion
+	}
+	target, err := parseMinecraftVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	oldest, _ := parseMinecraftVersion(oldestMinecraftVersion)
+	if slices.Compare(target[:], oldest[:]) < 0 {
+		return nil, fmt.Errorf("%w: Minecraft version %s is older than %s, the first with bundled blocks", ErrInvalidConfig, version, oldestMinecraftVersion)
+	}
+
+	var blocks []MinecraftBlock
+	for _, vanilla := range vanillaBlocks() {
+		if slices.Compare(vanilla.since[:], target[:]) <= 0 {
+			block := vanilla.block
+			block.Properties = map[string]string{}
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+// MinecraftVersions returns the versions that added bundled vanilla blocks,
+// oldest first; each is a meaningful argument to VanillaBlocks.
+func MinecraftVersions() []string {
+	var versions []string
+	var last [3]int
+	for _, vanilla := range vanillaBlocks() {
+		if vanilla.since == last {
+			continue
+		}
+		last = vanilla.since
+		version := fmt.Sprintf("%d.%d", last[0], last[1])
+		if last[2] > 0 {
+			version += fmt.Sprintf(".%d", last[2])
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// MinecraftDataVersion returns the data version files written for a Minecraft
+// Java version record: that of the newest listed release not after it, which
+// the game upgrades on load like any older data. An empty version or "latest"
+// selects LatestMinecraftVersion; versions before 1.12 are rejected.
+func MinecraftDataVersion(version string) (int, error) {
+	if version == "" || version == "latest" {
+		version = LatestMinecraftVersion
+	}
+	target, err := parseMinecraftVersion(version)
+	if err != nil {
+		return 0, err
+	}
+	dataVersion := 0
+	for _, release := range minecraftDataVersions() {
+		if slices.Compare(release.version[:], target[:]) <= 0 {
+			dataVersion = release.dataVersion
+		}
+	}
+	if dataVersion == 0 {
+		return 0, fmt.Errorf("%w: Minecraft version %s is older than %s, the first with a known data version", ErrInvalidConfig, version, oldestMinecraftVersion)
+	}
+	return dataVersion, nil
+}
+
+// GetVanillaMinecraftBlocks returns the bundled vanilla Minecraft blocks with
+// colors for the latest version; see VanillaBlocks to pick a version.
+func GetVanillaMinecraftBlocks() []MinecraftBlock {
+	blocks, _ := VanillaBlocks(LatestMinecraftVersion)
+	return blocks
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestVanillaBlocks(t *testing.T) {
+	blocks, err := parseVanillaBlocks(vanillaBlocksCSV)
+	if err != nil {
+		t.Fatalf("bundled data: %v", err)
+	}
+	seen := make(map[string]bool, len(blocks))
+	for i, vanilla := range blocks {
+		if seen[vanilla.block.ID] {
+			t.Errorf("%s listed twice", vanilla.block.ID)
+		}
+		seen[vanilla.block.ID] = true
+		if i > 0 && slices.Compare(vanilla.since[:], blocks[i-1].since[:]) < 0 {
+			t.Errorf("%s is out of version order", vanilla.block.ID)
+		}
+		// The MCEdit exporter needs every pre-flattening block mapped
+		if vanilla.since == [3]int{1, 12, 0} {
+			if _, ok := legacyBlocks[vanilla.block.ID]; !ok {
+				t.Errorf("1.12 block %s has no legacy mapping", vanilla.block.ID)
+			}
+		}
+	}
+
+	latest := GetVanillaMinecraftBlocks()
+	if len(latest) != len(blocks) {
+		t.Errorf("latest has %d blocks, want all %d", len(latest), len(blocks))
+	}
+	ids := func(version string) []string {
+		t.Helper()
+		blocks, err := VanillaBlocks(version)
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		var ids []string
+		for _, block := range blocks {
+			ids = append(ids, block.ID)
+		}
+		return ids
+	}
+	v112, v116, v117 := ids("1.12"), ids("1.16.5"), ids("1.17")
+	if !slices.Contains(v112, "minecraft:white_wool") || !slices.Contains(v112, "minecraft:black_glazed_terracotta") {
+		t.Error("1.12 is missing wool or glazed terracotta")
+	}
+	if slices.Contains(v112, "minecraft:smooth_stone") || slices.Contains(v116, "minecraft:waxed_copper_block") {
+		t.Error("a version lists blocks added after it")
+	}
+	if !slices.Contains(v116, "minecraft:netherite_block") || !slices.Contains(v117, "minecraft:waxed_copper_block") {
+		t.Error("a version is missing blocks it added")
+	}
+	if len(v112) >= len(v116) || len(v116) >= len(v117) || len(ids("99.0")) != len(blocks) {
+		t.Errorf("versions do not nest: %d, %d, %d blocks", len(v112), len(v116), len(v117))
+	}
+
+	versions := MinecraftVersions()
+	if versions[0] != "1.12" || versions[len(versions)-1] != LatestMinecraftVersion {
+		t.Errorf("versions = %v", versions)
+	}
+	for _, bad := range []string{"1.11", "1", "1.x", "1.2.3.4"} {
+		if _, err := VanillaBlocks(bad); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%q: expected ErrInvalidConfig, got %v", bad, err)
+		}
+	}
+}
+
+func TestMinecraftDataVersion(t *testing.T) {
+	releases := minecraftDataVersions()
+	for i := 1; i < len(releases); i++ {
+		if slices.Compare(releases[i].version[:], releases[i-1].version[:]) <= 0 || releases[i].dataVersion <= releases[i-1].dataVersion {
+			t.Errorf("release %v is out of order", releases[i].version)
+		}
+	}
+	for version, want := range map[string]int{
+		"1.12":   1139,
+		"1.13":   FlatteningDataVersion,
+		"1.16":   2566,
+		"1.16.5": 2586,
+		"1.20.2": 3465, // Newest listed release before it
+		"latest": 4189,
+		"":       4189,
+		"99.0":   4189,
+	} {
+		if got, err := MinecraftDataVersion(version); err != nil || got != want {
+			t.Errorf("MinecraftDataVersion(%q) = %d, %v; want %d", version, got, err, want)
+		}
+	}
+	if latest, _ := parseMinecraftVersion(LatestMinecraftVersion); releases[len(releases)-1].version != latest {
+		t.Errorf("newest listed release is %v, want %s", releases[len(releases)-1].version, LatestMinecraftVersion)
+	}
+	for _, bad := range []string{"1.11.2", "1.x"} {
+		if _, err := MinecraftDataVersion(bad); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%q: expected ErrInvalidConfig, got %v", bad, err)
+		}
+	}
+}
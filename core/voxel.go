@@ -9,16 +9,38 @@ type Voxel struct {
 // VoxelGrid represents a 3D grid of voxels.
 type VoxelGrid struct {
 	SizeX, SizeY, SizeZ int
-	Voxels              map[[3]int]*Voxel // Sparse representation
-	Scale               float64           // Scale factor from mesh units to voxels
-	Origin              [3]float64        // Origin in mesh space
+	Scale               float64    // Scale factor from mesh units to voxels
+	Origin              [3]float64 // Origin in mesh space
+	
+	// Materials holds the surface properties of emissive, metallic and
+	// translucent mesh materials by the voxel colors they produced (nil = none).
+	Materials map[[3]uint8]VoxelMaterial
+	
+	store  VoxelStore
+	shapes map[[3]int]voxelShape // Shapes detail regions resolved, by voxel (nil = none)
 }
 
 // VoxelizationConfig holds parameters for voxelization.
 type VoxelizationConfig struct {
-	Resolution   int     // Target resolution (voxels along longest axis)
-	Scale        float64 // Manual scale override (0 = auto)
-	Conservative bool    // Use conservative voxelization
+	Resolution   int            // Target resolution (voxels along longest axis)
+	TargetSize   [3]int         // Per-axis size caps in voxels, overriding Resolution when any is set (0 = uncapped)
+	Scale        float64        // Manual scale override (0 = auto)
+	UpAxis       string         // Mesh axis that points up in the grid: UpAxisY (default), UpAxisZ or UpAxisX
+	FlipZ        bool           // Mirror the mesh along the grid's z axis, for left-handed meshes
+	Conservative bool           // Dilate voxels by a quarter voxel when testing triangles, closing cracks
+	Fill         bool           // Fill the interior enclosed by the surface
+	Hollow       int            // Then keep only a shell this many voxels thick (0 = keep everything)
+	IsoLevel     float64        // Distance in voxels the sdf voxelizer offsets the surface by, growing the model when positive and shrinking it when negative (0 = the mesh surface)
+	MinThickness int            // Minimum thickness in voxels of every surface, sweeping triangles along their normals to both sides so thin walls and sails stay closed (0 or 1 = as voxelized)
+	Samples      int            // Color samples per voxel and triangle, averaging every triangle covering a voxel (0 or 1 = last triangle at the voxel center)
+	MaxCells     int            // Upper bound on SizeX*SizeY*SizeZ, not filled voxels (0 = unlimited)
+	Workers      int            // Goroutines rasterizing faces (0 = one per CPU)
+	Storage      StorageConfig  // Sparse or dense cell storage (default: chosen automatically)
+	MinPoints    int            // Points a voxel needs with the points voxelizer, dropping sparse noise (0 = 1)
+	HoleFill     int            // Passes of the points voxelizer filling empty voxels between filled neighbors on opposite sides (0 = none)
+	Lighting     LightingConfig // Darkens colors by surface normal, baking in directional light (default: off)
+	
+	Progress ProgressReporter // Optional progress callback
 }
 
 // Voxelizer is the interface for converting meshes to voxels.
@@ -30,36 +52,110 @@ type Voxelizer interface {
 	Name() string
 }
 
-// NewVoxelGrid creates a new empty voxel grid.
+// NewVoxelGrid creates a new empty voxel grid backed by a sparse store.
 func NewVoxelGrid(sizeX, sizeY, sizeZ int) *VoxelGrid {
+	return NewVoxelGridWithStore(sizeX, sizeY, sizeZ, newSparseStore())
+}
+
+// NewVoxelGridFor creates a new empty voxel grid whose store is chosen by config
+// for a grid expected to hold about expectedVoxels filled cells.
+func NewVoxelGridFor(sizeX, sizeY, sizeZ int, expectedVoxels int64, config StorageConfig) *VoxelGrid {
+	return NewVoxelGridWithStore(sizeX, sizeY, sizeZ, newStore(sizeX, sizeY, sizeZ, expectedVoxels, config))
+}
+
+// NewVoxelGridWithStore creates a new empty voxel grid backed by store.
+func NewVoxelGridWithStore(sizeX, sizeY, sizeZ int, store VoxelStore) *VoxelGrid {
 	return &VoxelGrid{
-		SizeX:  sizeX,
-		SizeY:  sizeY,
-		SizeZ:  sizeZ,
-		Voxels: make(map[[3]int]*Voxel),
-		Scale:  1.0,
+		SizeX: sizeX,
+		SizeY: sizeY,
+		SizeZ: sizeZ,
+		Scale: 1.0,
+		store: store,
 	}
 }
 
+// Store returns the grid's cell storage.
+func (vg *VoxelGrid) Store() VoxelStore {
+	return vg.store
+}
+
 // SetVoxel sets a voxel at the given position.
 func (vg *VoxelGrid) SetVoxel(x, y, z int, color [3]uint8) {
-	if x >= 0 && x < vg.SizeX && y >= 0 && y < vg.SizeY && z >= 0 && z < vg.SizeZ {
-		vg.Voxels[[3]int{x, y, z}] = &Voxel{X: x, Y: y, Z: z, Color: color}
+	if vg.inBounds(x, y, z) {
+		vg.store.Set(x, y, z, color)
 	}
 }
 
-// GetVoxel retrieves a voxel at the given position.
+// GetVoxel retrieves a copy of the voxel at the given position, or nil if the cell is empty.
+// Use SetVoxel to change it.
 func (vg *VoxelGrid) GetVoxel(x, y, z int) *Voxel {
-	return vg.Voxels[[3]int{x, y, z}]
+	color, ok := vg.ColorAt(x, y, z)
+	if !ok {
+		return nil
+	}
+	return &Voxel{X: x, Y: y, Z: z, Color: color}
+}
+
+// ColorAt returns the color at the given position and whether the cell is filled.
+// Unlike GetVoxel it does not allocate.
+func (vg *VoxelGrid) ColorAt(x, y, z int) ([3]uint8, bool) {
+	if !vg.inBounds(x, y, z) {
+		return [3]uint8{}, false
+	}
+	return vg.store.Get(x, y, z)
 }
 
 // HasVoxel checks if a voxel exists at the given position.
 func (vg *VoxelGrid) HasVoxel(x, y, z int) bool {
-	_, ok := vg.Voxels[[3]int{x, y, z}]
+	_, ok := vg.ColorAt(x, y, z)
 	return ok
 }
 
+// DeleteVoxel empties the cell at the given position.
+func (vg *VoxelGrid) DeleteVoxel(x, y, z int) {
+	if vg.inBounds(x, y, z) {
+		vg.store.Delete(x, y, z)
+	}
+}
+
 // Count returns the number of voxels in the grid.
 func (vg *VoxelGrid) Count() int {
-	return len(vg.Voxels)
+	return vg.store.Len()
+}
+
+// Range calls fn for every filled voxel until fn returns false. The order depends
+// on the store but not on the run: sparse and dense grids are visited in x, then
+// y, then z order, octree grids brick by brick.
+func (vg *VoxelGrid) Range(fn func(x, y, z int, color [3]uint8) bool) {
+	vg.store.Range(fn)
+}
+
+// WithStorage returns a copy of the grid in the store chosen by config, such as
+// a flat (dense) copy of an octree grid or the reverse. StorageAuto chooses by the
+// grid's size and voxel count.
+func (vg *VoxelGrid) WithStorage(config StorageConfig) *VoxelGrid {
+	result := NewVoxelGridFor(vg.SizeX, vg.SizeY, vg.SizeZ, int64(vg.Count()), config)
+	result.Scale = vg.Scale
+	result.Origin = vg.Origin
+	result.Materials = vg.Materials
+	result.shapes = vg.shapes
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		result.store.Set(x, y, z, color)
+		return true
+	})
+	return result
+}
+
+// emptyLike returns an empty grid with the same size, placement and kind of store.
+func (vg *VoxelGrid) emptyLike() *VoxelGrid {
+	result := NewVoxelGridWithStore(vg.SizeX, vg.SizeY, vg.SizeZ, newStoreLike(vg.store, vg.SizeX, vg.SizeY, vg.SizeZ))
+	result.Scale = vg.Scale
+	result.Origin = vg.Origin
+	result.Materials = vg.Materials
+	result.shapes = vg.shapes
+	return result
+}
+
+func (vg *VoxelGrid) inBounds(x, y, z int) bool {
+	return x >= 0 && x < vg.SizeX && y >= 0 && y < vg.SizeY && z >= 0 && z < vg.SizeZ
 }
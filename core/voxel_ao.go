@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+)
+
+const (
+	aoRays     = 48 // Rays cast from each voxel
+	aoDistance = 8  // Voxels a ray travels before it counts as open
+)
+
+// aoDirections are the unit vectors of the occlusion rays, spread evenly over
+// the sphere on a Fibonacci spiral.
+var aoDirections = func() [][3]float64 {
+	dirs := make([][3]float64, aoRays)
+	golden := math.Pi * (3 - math.Sqrt(5))
+	for i := range dirs {
+		y := 1 - 2*(float64(i)+0.5)/aoRays
+		r := math.Sqrt(1 - y*y)
+		sin, cos := math.Sincos(golden * float64(i))
+		dirs[i] = [3]float64{r * cos, y, r * sin}
+	}
+	return dirs
+}()
+
+// AmbientOcclusion darkens voxels in crevices, folds and inner corners, baking
+// in the soft shadows block lighting lacks. Rays are cast outward from each
+// exposed face of a voxel; the share of them running into the model within a
+// few voxels darkens it, by up to strength (0 = unchanged, 1 = black), so flat
+// open surfaces keep their color. A voxel takes its most open face facing out
+// of the model, so the inside of a hollow shell does not darken its outside.
+// Voxels hidden on all six sides are left alone. It returns the number of
+// voxels darkened.
+func (vg *VoxelGrid) AmbientOcclusion(strength float64) int {
+	darkened, _ := vg.AmbientOcclusionCtx(context.Background(), strength)
+	return darkened
+}
+
+// AmbientOcclusionCtx is like AmbientOcclusion but stops early when ctx is
+// done, leaving the grid unchanged. Rays are cast on several goroutines, which
+// read the grid's store concurrently.
+func (vg *VoxelGrid) AmbientOcclusionCtx(ctx context.Context, strength float64) (int, error) {
+	strength = math.Max(0, math.Min(1, strength))
+	if strength == 0 {
+		return 0, nil
+	}
+
+	// Occlusion is measured on the unchanged grid before any voxel darkens
+	var cells [][3]int
+	vg.Range(func(x, y, z int, _ [3]uint8) bool {
+		if !vg.HasVoxel(x-1, y, z) || !vg.HasVoxel(x+1, y, z) || !vg.HasVoxel(x, y-1, z) ||
+			!vg.HasVoxel(x, y+1, z) || !vg.HasVoxel(x, y, z-1) || !vg.HasVoxel(x, y, z+1) {
+			cells = append(cells, [3]int{x, y, z})
+		}
+		return true
+	})
+	outside, err := outsideCells(ctx, vg)
+	if err != nil {
+		return 0, err
+	}
+	brightness := make([]float64, len(cells))
+	workers := min(runtime.GOMAXPROCS(0), max(1, len(cells)/ctxCheckInterval))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				if i%ctxCheckInterval == 0 && ctx.Err() != nil {
+					return
+				}
+				brightness[i] = 1 - strength*vg.occlusion(cells[i], outside)
+			}
+		}(len(cells)*w/workers, len(cells)*(w+1)/workers)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	darkened := 0
+	for i, p := range cells {
+		color, _ := vg.ColorAt(p[0], p[1], p[2])
+		shaded := shade(color, brightness[i])
+		if shaded == color {
+			continue
+		}
+		vg.SetVoxel(p[0], p[1], p[2], shaded)
+		if m, ok := vg.Materials[color]; ok {
+			vg.setMaterial(shaded, m)
+		}
+		darkened++
+	}
+	return darkened, nil
+}
+
+// aoFaces are the normals of a voxel's six faces.
+var aoFaces = [6][3]int{{-1, 0, 0}, {1, 0, 0}, {0, -1, 0}, {0, 1, 0}, {0, 0, -1}, {0, 0, 1}}
+
+// occlusion returns the occlusion of voxel p's most open exposed face: the
+// share of rays cast over the face's hemisphere, from the empty cell in front
+// of it, that hit a voxel within aoDistance. Rays leaving the grid are open.
+// Faces toward cells outside the model are preferred over faces toward closed
+// cavities, such as the inside of a hollow shell.
+func (vg *VoxelGrid) occlusion(p [3]int, outside []uint64) float64 {
+	least, leastOutside := 1.0, -1.0
+	for _, n := range aoFaces {
+		start := [3]int{p[0] + n[0], p[1] + n[1], p[2] + n[2]}
+		if vg.HasVoxel(start[0], start[1], start[2]) {
+			continue
+		}
+		blocked, rays := 0, 0
+		for _, dir := range aoDirections {
+			if dir[0]*float64(n[0])+dir[1]*float64(n[1])+dir[2]*float64(n[2]) <= 0 {
+				continue
+			}
+			rays++
+			if vg.rayBlocked(start, dir) {
+				blocked++
+			}
+		}
+		occlusion := float64(blocked) / float64(rays)
+		least = math.Min(least, occlusion)
+		i := start[0] + vg.SizeX*(start[1]+vg.SizeY*start[2])
+		if !vg.inBounds(start[0], start[1], start[2]) || outside[i/64]&(1<<(i%64)) != 0 {
+			if leastOutside < 0 || occlusion < leastOutside {
+				leastOutside = occlusion
+			}
+		}
+	}
+	if leastOutside >= 0 {
+		return leastOutside
+	}
+	return least
+}
+
+// rayBlocked reports whether a ray from the center of cell p along dir hits a
+// voxel within aoDistance before leaving the grid.
+func (vg *VoxelGrid) rayBlocked(p [3]int, dir [3]float64) bool {
+	last := p
+	for step := 1; step <= 2*aoDistance; step++ {
+		t := float64(step) / 2 // Half-voxel steps
+		cell := [3]int{
+			int(math.Floor(float64(p[0]) + 0.5 + dir[0]*t)),
+			int(math.Floor(float64(p[1]) + 0.5 + dir[1]*t)),
+			int(math.Floor(float64(p[2]) + 0.5 + dir[2]*t)),
+		}
+		if cell == last {
+			continue
+		}
+		last = cell
+		if !vg.inBounds(cell[0], cell[1], cell[2]) {
+			return false
+		}
+		if vg.HasVoxel(cell[0], cell[1], cell[2]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package core
+
+import "testing"
+
+func TestAmbientOcclusion(t *testing.T) {
+	// A two-voxel-thick white floor with a wall standing on its left end
+	vg := NewVoxelGrid(24, 8, 9)
+	white := [3]uint8{200, 200, 200}
+	for x := 0; x < 24; x++ {
+		for z := 0; z < 9; z++ {
+			vg.SetVoxel(x, 0, z, white)
+			vg.SetVoxel(x, 1, z, white)
+		}
+	}
+	for y := 2; y < 8; y++ {
+		for z := 0; z < 9; z++ {
+			vg.SetVoxel(0, y, z, white)
+		}
+	}
+
+	if n := vg.AmbientOcclusion(1); n == 0 {
+		t.Fatal("no voxels darkened")
+	}
+	corner, _ := vg.ColorAt(1, 1, 4)
+	if corner[0] >= white[0] {
+		t.Errorf("floor voxel by the wall = %v, want darkened", corner)
+	}
+	if c, _ := vg.ColorAt(20, 1, 4); c != white {
+		t.Errorf("open floor voxel = %v, want unchanged", c)
+	}
+	if c, _ := vg.ColorAt(12, 0, 4); c != white {
+		t.Errorf("underside voxel = %v, want unchanged", c)
+	}
+
+	// A closed shell keeps its outside color despite its inner cavity
+	shell := NewVoxelGrid(5, 5, 5)
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 5; y++ {
+			for z := 0; z < 5; z++ {
+				if x == 0 || x == 4 || y == 0 || y == 4 || z == 0 || z == 4 {
+					shell.SetVoxel(x, y, z, white)
+				}
+			}
+		}
+	}
+	if n := shell.AmbientOcclusion(1); n != 0 {
+		t.Errorf("darkened %d voxels of a closed box", n)
+	}
+
+	// Flat and empty grids have nothing to occlude
+	plane, err := NewSurfaceVoxelizer().Voxelize(flatPlane(), VoxelizationConfig{Resolution: 64})
+	if err != nil {
+		t.Fatalf("voxelize: %v", err)
+	}
+	if n := plane.AmbientOcclusion(0.5); n != 0 {
+		t.Errorf("darkened %d voxels of a flat plane", n)
+	}
+	if n := NewVoxelGrid(4, 0, 4).AmbientOcclusion(1); n != 0 {
+		t.Errorf("darkened %d voxels of an empty grid", n)
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Component is a set of filled voxels connected through faces, edges or
+// corners.
+type Component struct {
+	Voxels   [][3]int // Positions, in no particular order
+	Min, Max [3]int   // Bounding box, inclusive
+}
+
+// Size returns the number of voxels in the component.
+func (c *Component) Size() int {
+	return len(c.Voxels)
+}
+
+// ConnectedComponents splits the filled voxels into components, two voxels
+// being connected when they share a face, edge or corner. Components are
+// returned largest first, equal sizes ordered by their minimum corner.
+func (vg *VoxelGrid) ConnectedComponents() []Component {
+	var components []Component
+	visited := make(map[[3]int]bool, vg.Count())
+	var queue [][3]int
+	vg.Range(func(x, y, z int, _ [3]uint8) bool {
+		start := [3]int{x, y, z}
+		if visited[start] {
+			return true
+		}
+		visited[start] = true
+		c := Component{Min: start, Max: start}
+		queue = append(queue[:0], start)
+		for len(queue) > 0 {
+			p := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			c.Voxels = append(c.Voxels, p)
+			for i := range p {
+				c.Min[i] = min(c.Min[i], p[i])
+				c.Max[i] = max(c.Max[i], p[i])
+			}
+			for dx := -1; dx <= 1; dx++ {
+				for dy := -1; dy <= 1; dy++ {
+					for dz := -1; dz <= 1; dz++ {
+						n := [3]int{p[0] + dx, p[1] + dy, p[2] + dz}
+						if !visited[n] && vg.HasVoxel(n[0], n[1], n[2]) {
+							visited[n] = true
+							queue = append(queue, n)
+						}
+					}
+				}
+			}
+		}
+		components = append(components, c)
+		return true
+	})
+
+	slices.SortFunc(components, func(a, b Component) int {
+		if c := cmp.Compare(b.Size(), a.Size()); c != 0 {
+			return c
+		}
+		for i := range a.Min {
+			if c := cmp.Compare(a.Min[i], b.Min[i]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return components
+}
+
+// RemoveIslands deletes the components smaller than minSize voxels, such as
+// the floating fragments sliver triangles leave, and returns the number of
+// voxels removed. The largest component always stays.
+func (vg *VoxelGrid) RemoveIslands(minSize int) int {
+	removed := 0
+	components := vg.ConnectedComponents()
+	for i := range components {
+		if i == 0 || components[i].Size() >= minSize {
+			continue
+		}
+		for _, p := range components[i].Voxels {
+			vg.store.Delete(p[0], p[1], p[2])
+		}
+		removed += components[i].Size()
+	}
+	return removed
+}
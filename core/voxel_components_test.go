@@ -0,0 +1,45 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConnectedComponents(t *testing.T) {
+	gray := [3]uint8{128, 128, 128}
+	vg := NewVoxelGrid(10, 10, 10)
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 3; y++ {
+			vg.SetVoxel(x, y, 0, gray)
+		}
+	}
+	vg.SetVoxel(4, 3, 1, gray) // Touches the slab at a corner only
+	vg.SetVoxel(8, 8, 8, gray)
+	vg.SetVoxel(8, 9, 8, gray)
+	vg.SetVoxel(0, 9, 9, gray)
+
+	components := vg.ConnectedComponents()
+	if len(components) != 3 {
+		t.Fatalf("%d components, want 3", len(components))
+	}
+	if got := []int{components[0].Size(), components[1].Size(), components[2].Size()}; got[0] != 13 || got[1] != 2 || got[2] != 1 {
+		t.Errorf("sizes = %v, want [13 2 1]", got)
+	}
+	if components[0].Min != [3]int{0, 0, 0} || components[0].Max != [3]int{4, 3, 1} {
+		t.Errorf("bounds = %v to %v", components[0].Min, components[0].Max)
+	}
+
+	if removed := vg.RemoveIslands(3); removed != 3 {
+		t.Errorf("removed %d voxels, want 3", removed)
+	}
+	if vg.Count() != 13 || vg.HasVoxel(8, 8, 8) {
+		t.Errorf("%d voxels left after removing islands", vg.Count())
+	}
+	if removed := vg.RemoveIslands(100); removed != 0 {
+		t.Errorf("removed the largest component (%d voxels)", removed)
+	}
+
+	if _, err := NewPipeline(WithIslandRemoval(-1)); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewPipeline accepted a negative island size: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"maps"
+	"math"
+)
+
+// VoxelMaterial describes how a voxel's surface renders beyond its color, for
+// formats that store materials such as VOX. The zero value is a plain diffuse
+// surface.
+type VoxelMaterial struct {
+	Emission  float64 // Light emitted [0,1], 0 = none
+	Metallic  float64 // Metalness [0,1]
+	Roughness float64 // Surface roughness [0,1], used with Metallic
+	Opacity   float64 // 1 = opaque; 0 is treated as opaque
+}
+
+// Emissive reports whether the material emits light.
+func (m VoxelMaterial) Emissive() bool {
+	return m.Emission > 0
+}
+
+// Translucent reports whether light passes through the material.
+func (m VoxelMaterial) Translucent() bool {
+	return m.Opacity > 0 && m.Opacity < 1
+}
+
+// voxelMaterialOf returns the voxel material of a mesh material, or nil when it
+// is a plain diffuse surface. Emission is the brightest emissive channel, and
+// transmission lowers the opacity.
+func voxelMaterialOf(mat *Material) *VoxelMaterial {
+	m := VoxelMaterial{
+		Emission:  maxChannel(mat.EmissiveColor),
+		Metallic:  mat.Metallic,
+		Roughness: mat.Roughness,
+		Opacity:   mat.Opacity,
+	}
+	if mat.Transmission > 0 {
+		if m.Opacity <= 0 {
+			m.Opacity = 1
+		}
+		// Keep fully transmissive surfaces just visible, as 0 means opaque
+		m.Opacity = math.Max(m.Opacity*(1-mat.Transmission), 0.01)
+	}
+	return m.orNil()
+}
+
+// orNil returns a copy of m with Emission clamped to 1, or nil when m is a plain
+// diffuse surface.
+func (m VoxelMaterial) orNil() *VoxelMaterial {
+	if !m.Emissive() && !m.Translucent() && m.Metallic <= 0 {
+		return nil
+	}
+	m.Emission = math.Min(m.Emission, 1)
+	return &m
+}
+
+// maxChannel returns the largest of three channels.
+func maxChannel(c [3]float64) float64 {
+	return math.Max(c[0], math.Max(c[1], c[2]))
+}
+
+// setMaterial records the material of the voxels of a color.
+func (vg *VoxelGrid) setMaterial(color [3]uint8, m VoxelMaterial) {
+	if vg.Materials == nil {
+		vg.Materials = make(map[[3]uint8]VoxelMaterial)
+	}
+	vg.Materials[color] = m
+}
+
+// addMaterials records materials, such as another grid's, in a fresh map so
+// grids sharing vg's map are left alone.
+func (vg *VoxelGrid) addMaterials(materials map[[3]uint8]VoxelMaterial) {
+	if len(materials) == 0 {
+		return
+	}
+	merged := maps.Clone(vg.Materials)
+	if merged == nil {
+		merged = make(map[[3]uint8]VoxelMaterial, len(materials))
+	}
+	maps.Copy(merged, materials)
+	vg.Materials = merged
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Shapes of a StructuringElement.
+const (
+	ElementCube   = "cube"   // Every cell within Radius along each axis
+	ElementSphere = "sphere" // Cells within Radius in straight-line distance
+	ElementCross  = "cross"  // Cells within Radius along one axis only
+)
+
+// elementShapes lists the accepted StructuringElement shapes besides "".
+var elementShapes = []string{ElementCube, ElementSphere, ElementCross}
+
+// ElementShapes returns the shapes accepted by StructuringElement.Shape.
+func ElementShapes() []string {
+	return append([]string(nil), elementShapes...)
+}
+
+// StructuringElement is the neighborhood morphology operators test around each
+// cell.
+type StructuringElement struct {
+	Shape  string // ElementCube (default, also used for unknown shapes), ElementSphere or ElementCross
+	Radius int    // Reach in cells; 0 leaves the grid unchanged
+}
+
+// offsets returns the element's cells relative to its center, excluding the
+// center, nearest first and in a fixed order among equally near cells.
+func (e StructuringElement) offsets() [][3]int {
+	r := e.Radius
+	var offsets [][3]int
+	for x := -r; x <= r; x++ {
+		for y := -r; y <= r; y++ {
+			for z := -r; z <= r; z++ {
+				d := x*x + y*y + z*z
+				if d == 0 {
+					continue
+				}
+				switch e.Shape {
+				case ElementSphere:
+					if d > r*r {
+						continue
+					}
+				case ElementCross:
+					if (x != 0 && y != 0) || (x != 0 && z != 0) || (y != 0 && z != 0) {
+						continue
+					}
+				}
+				offsets = append(offsets, [3]int{x, y, z})
+			}
+		}
+	}
+	slices.SortStableFunc(offsets, func(a, b [3]int) int {
+		return (a[0]*a[0] + a[1]*a[1] + a[2]*a[2]) - (b[0]*b[0] + b[1]*b[1] + b[2]*b[2])
+	})
+	return offsets
+}
+
+// Dilate returns a copy of vg grown by element: every empty cell with a filled
+// cell within the element fills, taking the color of the nearest one. The grid
+// keeps its size, so growth stops at its bounds.
+func (vg *VoxelGrid) Dilate(element StructuringElement) *VoxelGrid {
+	offsets := element.offsets()
+	result := vg.filter(func(x, y, z int) bool { return true })
+	candidates := make(map[[3]int]bool)
+	vg.Range(func(x, y, z int, _ [3]uint8) bool {
+		for _, o := range offsets {
+			cx, cy, cz := x+o[0], y+o[1], z+o[2]
+			if vg.inBounds(cx, cy, cz) && !vg.HasVoxel(cx, cy, cz) {
+				candidates[[3]int{cx, cy, cz}] = true
+			}
+		}
+		return true
+	})
+	for cell := range candidates {
+		// The element is symmetric, so its offsets also lead back to the sources
+		for _, o := range offsets {
+			if color, ok := vg.ColorAt(cell[0]+o[0], cell[1]+o[1], cell[2]+o[2]); ok {
+				result.store.Set(cell[0], cell[1], cell[2], color)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Erode returns a copy of vg shrunk by element: a voxel stays only when every
+// cell within the element is filled. Cells outside the grid count as empty.
+func (vg *VoxelGrid) Erode(element StructuringElement) *VoxelGrid {
+	offsets := element.offsets()
+	return vg.filter(func(x, y, z int) bool {
+		for _, o := range offsets {
+			if !vg.HasVoxel(x+o[0], y+o[1], z+o[2]) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Close dilates then erodes vg, filling pinholes and gaps narrower than the
+// element without growing the outline. Every voxel of vg stays with its color.
+func (vg *VoxelGrid) Close(element StructuringElement) *VoxelGrid {
+	closed := vg.Dilate(element).Erode(element)
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		closed.store.Set(x, y, z, color)
+		return true
+	})
+	return closed
+}
+
+// Open erodes then dilates vg, removing spikes and specks thinner than the
+// element. Only voxels of vg remain, with their colors.
+func (vg *VoxelGrid) Open(element StructuringElement) *VoxelGrid {
+	opened := vg.Erode(element).Dilate(element)
+	return vg.filter(func(x, y, z int) bool {
+		return opened.HasVoxel(x, y, z)
+	})
+}
+
+// Morphology operations of MorphStep.
+const (
+	MorphDilate = "dilate"
+	MorphErode  = "erode"
+	MorphClose  = "close"
+	MorphOpen   = "open"
+)
+
+// morphOps lists the accepted MorphStep operations.
+var morphOps = []string{MorphDilate, MorphErode, MorphClose, MorphOpen}
+
+// MorphOps returns the operations accepted by MorphStep.Op.
+func MorphOps() []string {
+	return append([]string(nil), morphOps...)
+}
+
+// MorphStep is one morphology operation applied to voxelized grids.
+type MorphStep struct {
+	Op      string // MorphDilate, MorphErode, MorphClose or MorphOpen
+	Element StructuringElement
+}
+
+// ParseMorphStep parses a step written as op[:radius[:shape]], such as "close",
+// "close:2" or "open:1:sphere". The radius defaults to 1 and the shape to a cube.
+func ParseMorphStep(spec string) (MorphStep, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 {
+		return MorphStep{}, fmt.Errorf("%w: morphology step %q is not op[:radius[:shape]]", ErrInvalidConfig, spec)
+	}
+	step := MorphStep{Op: parts[0], Element: StructuringElement{Radius: 1}}
+	if len(parts) > 1 {
+		radius, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return MorphStep{}, fmt.Errorf("%w: invalid radius %q in morphology step %q", ErrInvalidConfig, parts[1], spec)
+		}
+		step.Element.Radius = radius
+	}
+	if len(parts) > 2 {
+		step.Element.Shape = parts[2]
+	}
+	if err := step.validate(); err != nil {
+		return MorphStep{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return step, nil
+}
+
+// validate reports an unknown operation or shape or a negative radius.
+func (s MorphStep) validate() error {
+	if !containsString(morphOps, s.Op) {
+		return fmt.Errorf("unknown morphology operation %q (supported: %s)", s.Op, strings.Join(morphOps, ", "))
+	}
+	if shape := s.Element.Shape; shape != "" && !containsString(elementShapes, shape) {
+		return fmt.Errorf("unknown structuring element %q (supported: %s)", shape, strings.Join(elementShapes, ", "))
+	}
+	if s.Element.Radius < 0 {
+		return fmt.Errorf("structuring element radius must not be negative, got %d", s.Element.Radius)
+	}
+	return nil
+}
+
+// Morph applies the step to vg.
+func (vg *VoxelGrid) Morph(step MorphStep) (*VoxelGrid, error) {
+	if err := step.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	switch step.Op {
+	case MorphDilate:
+		return vg.Dilate(step.Element), nil
+	case MorphErode:
+		return vg.Erode(step.Element), nil
+	case MorphClose:
+		return vg.Close(step.Element), nil
+	}
+	return vg.Open(step.Element), nil
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDilateAndErode(t *testing.T) {
+	red, blue := [3]uint8{255, 0, 0}, [3]uint8{0, 0, 255}
+	vg := NewVoxelGrid(7, 7, 7)
+	vg.SetVoxel(2, 3, 3, red)
+	vg.SetVoxel(4, 3, 3, blue)
+
+	cross := vg.Dilate(StructuringElement{Shape: ElementCross, Radius: 1})
+	if got, want := cross.Count(), 2*7-1; got != want {
+		t.Errorf("cross dilation has %d voxels, want %d", got, want)
+	}
+	if c, _ := cross.ColorAt(1, 3, 3); c != red {
+		t.Errorf("dilated cell = %v, want its nearest voxel's red", c)
+	}
+	if got, want := vg.Dilate(StructuringElement{Radius: 1}).Count(), 27+27-9; got != want {
+		t.Errorf("cube dilation has %d voxels, want %d", got, want)
+	}
+	if sphere := vg.Dilate(StructuringElement{Shape: ElementSphere, Radius: 2}); !sphere.HasVoxel(2, 4, 4) || sphere.HasVoxel(0, 5, 5) {
+		t.Error("sphere dilation reached the wrong cells")
+	}
+
+	if eroded := cross.Erode(StructuringElement{Shape: ElementCross, Radius: 1}); eroded.Count() != 2 || !eroded.HasVoxel(2, 3, 3) {
+		t.Errorf("erosion left %d voxels, want the two it was dilated from", eroded.Count())
+	}
+}
+
+func TestCloseAndOpen(t *testing.T) {
+	gray := [3]uint8{128, 128, 128}
+	element := StructuringElement{Radius: 1}
+
+	// A one-voxel surface with a pinhole
+	plane := NewVoxelGrid(5, 5, 3)
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 5; y++ {
+			if x != 2 || y != 2 {
+				plane.SetVoxel(x, y, 1, gray)
+			}
+		}
+	}
+	closed := plane.Close(element)
+	if !closed.HasVoxel(2, 2, 1) {
+		t.Error("closing left the pinhole")
+	}
+	if closed.Count() != 25 {
+		t.Errorf("closing has %d voxels, want the 25 of the plane", closed.Count())
+	}
+
+	// A solid cube with a one-voxel spike
+	cube := NewVoxelGrid(7, 7, 7)
+	for x := 1; x <= 5; x++ {
+		for y := 1; y <= 5; y++ {
+			for z := 1; z <= 5; z++ {
+				cube.SetVoxel(x, y, z, gray)
+			}
+		}
+	}
+	cube.SetVoxel(6, 3, 3, [3]uint8{255, 0, 0})
+	opened := cube.Open(element)
+	if opened.HasVoxel(6, 3, 3) {
+		t.Error("opening kept the spike")
+	}
+	if opened.Count() != 125 {
+		t.Errorf("opening has %d voxels, want the 125 of the cube", opened.Count())
+	}
+}
+
+func TestParseMorphStep(t *testing.T) {
+	tests := []struct {
+		spec string
+		want MorphStep
+	}{
+		{"close", MorphStep{Op: MorphClose, Element: StructuringElement{Radius: 1}}},
+		{"open:2", MorphStep{Op: MorphOpen, Element: StructuringElement{Radius: 2}}},
+		{"dilate:1:sphere", MorphStep{Op: MorphDilate, Element: StructuringElement{Shape: ElementSphere, Radius: 1}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMorphStep(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMorphStep(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+		}
+	}
+	for _, spec := range []string{"blur:1", "close:x", "close:-1", "close:1:star", "close:1:cube:2"} {
+		if _, err := ParseMorphStep(spec); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("ParseMorphStep(%q): expected ErrInvalidConfig, got %v", spec, err)
+		}
+	}
+	if _, err := NewPipeline(WithMorphology(MorphStep{Op: "blur"})); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewPipeline accepted an unknown morphology step: %v", err)
+	}
+}
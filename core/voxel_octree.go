@@ -0,0 +1,124 @@
+package core
+
+import "math/bits"
+
+// octreeBrickBits is log2 of the side of an octree leaf brick; 4x4x4 bricks hold
+// their occupancy in a single uint64.
+const octreeBrickBits = 2
+
+// octreeStore keeps filled cells in 4x4x4 bricks hung from an octree, so memory
+// grows with the number of occupied bricks rather than the grid volume or the
+// number of voxels. A surface voxel costs around a third of a sparse map entry,
+// which makes resolutions of 1024 to 4096 practical. Access walks one node per
+// level (about ten at 4096), and Range skips empty subtrees, visiting bricks in
+// octant (Morton) order. Bricks emptied by Delete stay allocated.
+type octreeStore struct {
+	levels int          // Node levels above the bricks; the root spans 4<<levels cells per side
+	nodes  []octreeNode // nodes[0] is the root
+	bricks []octreeBrick
+	count  int
+}
+
+// octreeNode holds a node's children by octant: 0 for none, otherwise the index
+// plus one into nodes, or into bricks on the lowest level.
+type octreeNode [8]int32
+
+type octreeBrick struct {
+	occupied uint64
+	colors   [64][3]uint8
+}
+
+func newOctreeStore(sizeX, sizeY, sizeZ int) *octreeStore {
+	levels := 1
+	for side := max(sizeX, max(sizeY, sizeZ)); 1<<(octreeBrickBits+levels) < side; {
+		levels++
+	}
+	return &octreeStore{levels: levels, nodes: make([]octreeNode, 1)}
+}
+
+// brick returns the brick holding a cell and the cell's index in it, adding the
+// missing nodes when create is set. It returns nil for a cell in an empty subtree.
+func (s *octreeStore) brick(x, y, z int, create bool) (*octreeBrick, int) {
+	node := 0
+	for level := s.levels; ; level-- {
+		shift := octreeBrickBits + level - 1
+		octant := (x>>shift)&1 | (y>>shift)&1<<1 | (z>>shift)&1<<2
+		child := s.nodes[node][octant]
+		if child == 0 {
+			if !create {
+				return nil, 0
+			}
+			if level > 1 {
+				s.nodes = append(s.nodes, octreeNode{})
+				child = int32(len(s.nodes))
+			} else {
+				s.bricks = append(s.bricks, octreeBrick{})
+				child = int32(len(s.bricks))
+			}
+			s.nodes[node][octant] = child
+		}
+		if level == 1 {
+			return &s.bricks[child-1], x&3 | (y&3)<<2 | (z&3)<<4
+		}
+		node = int(child - 1)
+	}
+}
+
+func (s *octreeStore) Set(x, y, z int, color [3]uint8) {
+	b, i := s.brick(x, y, z, true)
+	if b.occupied&(1<<i) == 0 {
+		b.occupied |= 1 << i
+		s.count++
+	}
+	b.colors[i] = color
+}
+
+func (s *octreeStore) Get(x, y, z int) ([3]uint8, bool) {
+	b, i := s.brick(x, y, z, false)
+	if b == nil || b.occupied&(1<<i) == 0 {
+		return [3]uint8{}, false
+	}
+	return b.colors[i], true
+}
+
+func (s *octreeStore) Delete(x, y, z int) {
+	b, i := s.brick(x, y, z, false)
+	if b != nil && b.occupied&(1<<i) != 0 {
+		b.occupied &^= 1 << i
+		s.count--
+	}
+}
+
+func (s *octreeStore) Len() int {
+	return s.count
+}
+
+func (s *octreeStore) Range(fn func(x, y, z int, color [3]uint8) bool) {
+	s.rangeNode(0, s.levels, 0, 0, 0, fn)
+}
+
+// rangeNode visits the cells below a node whose corner is at (ox, oy, oz),
+// returning false once fn does.
+func (s *octreeStore) rangeNode(node, level, ox, oy, oz int, fn func(x, y, z int, color [3]uint8) bool) bool {
+	shift := octreeBrickBits + level - 1
+	for octant, child := range s.nodes[node] {
+		if child == 0 {
+			continue
+		}
+		cx, cy, cz := ox+(octant&1)<<shift, oy+(octant>>1&1)<<shift, oz+(octant>>2)<<shift
+		if level > 1 {
+			if !s.rangeNode(int(child-1), level-1, cx, cy, cz, fn) {
+				return false
+			}
+			continue
+		}
+		b := &s.bricks[child-1]
+		for set := b.occupied; set != 0; set &= set - 1 {
+			i := bits.TrailingZeros64(set)
+			if !fn(cx+i&3, cy+i>>2&3, cz+i>>4, b.colors[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operations of VoxelGrid.Combine.
+const (
+	GridUnion     = "union"     // Voxels of either grid; the first grid's colors win where both are filled
+	GridMerge     = "merge"     // Voxels of either grid; the second grid's colors win where both are filled
+	GridSubtract  = "subtract"  // Voxels of the first grid the second leaves empty
+	GridIntersect = "intersect" // Voxels of the first grid the second also fills
+)
+
+// gridOps lists the accepted VoxelGrid.Combine operations.
+var gridOps = []string{GridUnion, GridMerge, GridSubtract, GridIntersect}
+
+// GridOps returns the operations accepted by VoxelGrid.Combine.
+func GridOps() []string {
+	return append([]string(nil), gridOps...)
+}
+
+// Combine applies the named operation to vg and other, placed with its minimum
+// corner at offset in vg's cells.
+func (vg *VoxelGrid) Combine(op string, other *VoxelGrid, offset [3]int) (*VoxelGrid, error) {
+	switch op {
+	case GridUnion:
+		return vg.Union(other, offset), nil
+	case GridMerge:
+		return vg.Merge(other, offset), nil
+	case GridSubtract:
+		return vg.Subtract(other, offset), nil
+	case GridIntersect:
+		return vg.Intersect(other, offset), nil
+	}
+	return nil, fmt.Errorf("%w: unknown grid operation %q (supported: %s)",
+		ErrInvalidConfig, op, strings.Join(gridOps, ", "))
+}
+
+// Union returns a new grid holding the voxels of vg and of other, placed with
+// its minimum corner at offset in vg's cells. The grid grows to fit both; when
+// an offset is negative vg moves up that axis and Origin follows, so vg stays in
+// place in mesh space. Where both are filled, vg's color stays.
+func (vg *VoxelGrid) Union(other *VoxelGrid, offset [3]int) *VoxelGrid {
+	return vg.combine(other, offset, false)
+}
+
+// Merge is like Union, but other's colors win where both grids are filled, as
+// when placing a model on top of another.
+func (vg *VoxelGrid) Merge(other *VoxelGrid, offset [3]int) *VoxelGrid {
+	return vg.combine(other, offset, true)
+}
+
+// combine places vg and other, at offset, in a new grid covering both. Where
+// both are filled, other's color wins if overwrite is set.
+func (vg *VoxelGrid) combine(other *VoxelGrid, offset [3]int, overwrite bool) *VoxelGrid {
+	var lo, hi [3]int
+	sizes := [3]int{vg.SizeX, vg.SizeY, vg.SizeZ}
+	otherSizes := [3]int{other.SizeX, other.SizeY, other.SizeZ}
+	for i := range lo {
+		lo[i] = min(0, offset[i])
+		hi[i] = max(sizes[i], offset[i]+otherSizes[i])
+	}
+
+	result := NewVoxelGridFor(hi[0]-lo[0], hi[1]-lo[1], hi[2]-lo[2], int64(vg.Count()+other.Count()), StorageConfig{})
+	result.Scale = vg.Scale
+	result.Origin = vg.Origin
+	result.addMaterials(vg.Materials)
+	result.addMaterials(other.Materials)
+	if vg.Scale != 0 {
+		for i := range lo {
+			result.Origin[i] += float64(lo[i]) / vg.Scale
+		}
+	}
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		result.store.Set(x-lo[0], y-lo[1], z-lo[2], color)
+		return true
+	})
+	other.Range(func(x, y, z int, color [3]uint8) bool {
+		x, y, z = x+offset[0]-lo[0], y+offset[1]-lo[1], z+offset[2]-lo[2]
+		if overwrite || !result.HasVoxel(x, y, z) {
+			result.store.Set(x, y, z, color)
+		}
+		return true
+	})
+	return result
+}
+
+// Subtract returns a copy of vg without the voxels other, placed with its
+// minimum corner at offset in vg's cells, fills. It keeps vg's size, as when
+// cutting a doorway out of a wall.
+func (vg *VoxelGrid) Subtract(other *VoxelGrid, offset [3]int) *VoxelGrid {
+	return vg.filter(func(x, y, z int) bool {
+		return !other.HasVoxel(x-offset[0], y-offset[1], z-offset[2])
+	})
+}
+
+// Intersect returns a copy of vg with only the voxels other, placed with its
+// minimum corner at offset in vg's cells, also fills. It keeps vg's size and
+// colors.
+func (vg *VoxelGrid) Intersect(other *VoxelGrid, offset [3]int) *VoxelGrid {
+	return vg.filter(func(x, y, z int) bool {
+		return other.HasVoxel(x-offset[0], y-offset[1], z-offset[2])
+	})
+}
+
+// filter returns a copy of vg with the voxels keep accepts.
+func (vg *VoxelGrid) filter(keep func(x, y, z int) bool) *VoxelGrid {
+	result := vg.emptyLike()
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		if keep(x, y, z) {
+			result.store.Set(x, y, z, color)
+		}
+		return true
+	})
+	return result
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+// boxGrid returns a grid of the given size filled with color.
+func boxGrid(size [3]int, color [3]uint8) *VoxelGrid {
+	vg := NewVoxelGrid(size[0], size[1], size[2])
+	for x := 0; x < size[0]; x++ {
+		for y := 0; y < size[1]; y++ {
+			for z := 0; z < size[2]; z++ {
+				vg.SetVoxel(x, y, z, color)
+			}
+		}
+	}
+	return vg
+}
+
+func TestGridUnionAndMerge(t *testing.T) {
+	red, blue := [3]uint8{255, 0, 0}, [3]uint8{0, 0, 255}
+	a := boxGrid([3]int{4, 4, 4}, red)
+	a.Scale = 2
+	b := boxGrid([3]int{4, 4, 4}, blue)
+
+	union := a.Union(b, [3]int{2, 0, -2})
+	if union.SizeX != 6 || union.SizeY != 4 || union.SizeZ != 6 {
+		t.Fatalf("size = %dx%dx%d, want 6x4x6", union.SizeX, union.SizeY, union.SizeZ)
+	}
+	if got, want := union.Count(), 4*4*4*2-2*4*2; got != want {
+		t.Errorf("union has %d voxels, want %d", got, want)
+	}
+	if union.Origin != [3]float64{0, 0, -1} {
+		t.Errorf("origin = %v, want a's origin moved back one unit along z", union.Origin)
+	}
+	// a's cell (3,0,0) now sits at (3,0,2), inside the overlap
+	if c, _ := union.ColorAt(3, 0, 2); c != red {
+		t.Errorf("union overlap = %v, want a's red", c)
+	}
+	if c, _ := union.ColorAt(5, 0, 0); c != blue {
+		t.Errorf("union b-only cell = %v, want blue", c)
+	}
+
+	merged := a.Merge(b, [3]int{2, 0, -2})
+	if c, _ := merged.ColorAt(3, 0, 2); c != blue {
+		t.Errorf("merge overlap = %v, want b's blue", c)
+	}
+}
+
+func TestGridSubtractAndIntersect(t *testing.T) {
+	wall := boxGrid([3]int{8, 6, 1}, [3]uint8{128, 128, 128})
+	door := boxGrid([3]int{2, 3, 1}, [3]uint8{0, 0, 0})
+
+	cut := wall.Subtract(door, [3]int{3, 0, 0})
+	if cut.SizeX != 8 || cut.Count() != 8*6-2*3 {
+		t.Errorf("subtract left %d voxels in a %d-wide grid", cut.Count(), cut.SizeX)
+	}
+	if cut.HasVoxel(3, 0, 0) || !cut.HasVoxel(3, 3, 0) {
+		t.Error("subtract removed the wrong voxels")
+	}
+
+	common := wall.Intersect(door, [3]int{7, 0, 0})
+	if common.Count() != 3 {
+		t.Errorf("intersect kept %d voxels, want 3", common.Count())
+	}
+	if c, _ := common.ColorAt(7, 2, 0); c != [3]uint8{128, 128, 128} {
+		t.Errorf("intersect color = %v, want the wall's", c)
+	}
+
+	if _, err := wall.Combine("xor", door, [3]int{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unknown operation, got %v", err)
+	}
+	if got, err := wall.Combine(GridSubtract, door, [3]int{3, 0, 0}); err != nil || got.Count() != cut.Count() {
+		t.Errorf("Combine(subtract) = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// Downsample returns a grid factor times smaller along each axis, each cell
+// standing for a factor³ block of vg. A cell is filled when any voxel of its
+// block is, so thin shells stay closed, and takes their average color. Scale
+// shrinks by factor. A factor below 2 returns vg.
+func (vg *VoxelGrid) Downsample(factor int) *VoxelGrid {
+	return vg.downsample(factor, false)
+}
+
+// DownsampleMajority is like Downsample but gives each cell the most common
+// color of its block, keeping colors such as an already matched grid's exact.
+func (vg *VoxelGrid) DownsampleMajority(factor int) *VoxelGrid {
+	return vg.downsample(factor, true)
+}
+
+func (vg *VoxelGrid) downsample(factor int, majority bool) *VoxelGrid {
+	if factor < 2 {
+		return vg
+	}
+	sizeX := (vg.SizeX + factor - 1) / factor
+	sizeY := (vg.SizeY + factor - 1) / factor
+	sizeZ := (vg.SizeZ + factor - 1) / factor
+
+	sums := make(colorSums)
+	var counts map[[3]int]map[[3]uint8]int
+	if majority {
+		counts = make(map[[3]int]map[[3]uint8]int)
+	}
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		key := [3]int{x / factor, y / factor, z / factor}
+		if !majority {
+			sum := sums[key]
+			sum.add(color, 1)
+			sums[key] = sum
+			return true
+		}
+		if counts[key] == nil {
+			counts[key] = make(map[[3]uint8]int)
+		}
+		counts[key][color]++
+		return true
+	})
+
+	result := NewVoxelGridFor(sizeX, sizeY, sizeZ, int64(max(len(sums), len(counts))), StorageConfig{})
+	result.Scale = vg.Scale / float64(factor)
+	result.Origin = vg.Origin
+	result.Materials = vg.Materials
+	sums.resolve(result)
+	for key, colors := range counts {
+		result.SetVoxel(key[0], key[1], key[2], mostCommonColor(colors))
+	}
+	return result
+}
+
+// mostCommonColor returns the color counted most often, breaking ties by the
+// lowest color so the result does not depend on map order.
+func mostCommonColor(counts map[[3]uint8]int) [3]uint8 {
+	var best [3]uint8
+	bestCount := 0
+	for color, n := range counts {
+		if n > bestCount || n == bestCount && packRGB(color) < packRGB(best) {
+			best, bestCount = color, n
+		}
+	}
+	return best
+}
+
+// packRGB packs a color into one comparable integer.
+func packRGB(c [3]uint8) uint32 {
+	return uint32(c[0])<<16 | uint32(c[1])<<8 | uint32(c[2])
+}
+
+// Upsample returns a grid factor times larger along each axis, each voxel of vg
+// becoming a factor³ block of its color. Scale grows by factor. A factor below
+// 2 returns vg.
+func (vg *VoxelGrid) Upsample(factor int) *VoxelGrid {
+	if factor < 2 {
+		return vg
+	}
+	expected := int64(vg.Count()) * int64(factor) * int64(factor) * int64(factor)
+	result := NewVoxelGridFor(vg.SizeX*factor, vg.SizeY*factor, vg.SizeZ*factor, expected, StorageConfig{})
+	result.Scale = vg.Scale * float64(factor)
+	result.Origin = vg.Origin
+	result.Materials = vg.Materials
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		for dx := 0; dx < factor; dx++ {
+			for dy := 0; dy < factor; dy++ {
+				for dz := 0; dz < factor; dz++ {
+					result.store.Set(x*factor+dx, y*factor+dy, z*factor+dz, color)
+				}
+			}
+		}
+		return true
+	})
+	return result
+}
+
+// Rescale returns vg upsampled by scale when it is a whole number, downsampled
+// by 1/scale when that is, and vg itself for 0 or 1.
+func (vg *VoxelGrid) Rescale(scale float64) (*VoxelGrid, error) {
+	up, down, err := rescaleFactors(scale)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return vg.Upsample(up).Downsample(down), nil
+}
+
+// rescaleFactors splits a Rescale scale into its upsampling and downsampling
+// factors, one of which is 1.
+func rescaleFactors(scale float64) (up, down int, err error) {
+	const tolerance = 1e-6
+	switch {
+	case scale == 0 || scale == 1:
+		return 1, 1, nil
+	case scale > 1 && math.Abs(scale-math.Round(scale)) < tolerance:
+		return int(math.Round(scale)), 1, nil
+	case scale > 0 && scale < 1 && math.Abs(1/scale-math.Round(1/scale)) < tolerance:
+		return 1, int(math.Round(1 / scale)), nil
+	}
+	return 0, 0, fmt.Errorf("grid scale must be a whole number or the reciprocal of one, such as 2 or 0.5, got %v", scale)
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDownsample(t *testing.T) {
+	red, blue := [3]uint8{255, 0, 0}, [3]uint8{0, 0, 255}
+	vg := NewVoxelGrid(5, 4, 4)
+	vg.Scale = 4
+	vg.SetVoxel(0, 0, 0, red)
+	vg.SetVoxel(1, 0, 0, red)
+	vg.SetVoxel(0, 1, 1, red)
+	vg.SetVoxel(1, 1, 1, blue)
+	vg.SetVoxel(4, 3, 3, blue) // Alone in the last, partial block
+
+	down := vg.Downsample(2)
+	if down.SizeX != 3 || down.SizeY != 2 || down.SizeZ != 2 || down.Scale != 2 {
+		t.Fatalf("grid = %dx%dx%d at scale %g, want 3x2x2 at 2", down.SizeX, down.SizeY, down.SizeZ, down.Scale)
+	}
+	if down.Count() != 2 {
+		t.Errorf("%d voxels, want 2", down.Count())
+	}
+	if c, _ := down.ColorAt(0, 0, 0); c != [3]uint8{191, 0, 64} {
+		t.Errorf("average = %v, want three parts red to one part blue", c)
+	}
+	if c, _ := vg.DownsampleMajority(2).ColorAt(0, 0, 0); c != red {
+		t.Errorf("majority = %v, want red", c)
+	}
+	if c, _ := down.ColorAt(2, 1, 1); c != blue {
+		t.Errorf("partial block = %v, want blue", c)
+	}
+}
+
+func TestUpsampleAndRescale(t *testing.T) {
+	vg := NewVoxelGrid(2, 1, 1)
+	vg.SetVoxel(1, 0, 0, [3]uint8{10, 20, 30})
+
+	up := vg.Upsample(3)
+	if up.SizeX != 6 || up.SizeY != 3 || up.Count() != 27 || up.Scale != 3 {
+		t.Fatalf("grid = %dx%dx%d with %d voxels at scale %g", up.SizeX, up.SizeY, up.SizeZ, up.Count(), up.Scale)
+	}
+	if !up.HasVoxel(5, 2, 2) || up.HasVoxel(2, 0, 0) {
+		t.Error("upsampled voxel covers the wrong block")
+	}
+
+	back, err := up.Rescale(1.0 / 3)
+	if err != nil {
+		t.Fatalf("Rescale failed: %v", err)
+	}
+	if back.SizeX != 2 || back.Count() != 1 || !back.HasVoxel(1, 0, 0) {
+		t.Errorf("round trip = %dx%dx%d with %d voxels", back.SizeX, back.SizeY, back.SizeZ, back.Count())
+	}
+	if same, _ := vg.Rescale(0); same != vg {
+		t.Error("Rescale(0) copied the grid")
+	}
+	for _, scale := range []float64{1.5, 0.4, -2} {
+		if _, err := vg.Rescale(scale); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Rescale(%v): expected ErrInvalidConfig, got %v", scale, err)
+		}
+	}
+	if _, err := NewPipeline(WithPostScale(0.3)); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewPipeline accepted post scale 0.3: %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"math/bits"
+	"slices"
+	"strings"
+)
+
+// VoxelStore holds the filled cells of a VoxelGrid. Positions passed to a store
+// are always inside the grid bounds.
+type VoxelStore interface {
+	// Set fills the cell at the given position with color.
+	Set(x, y, z int, color [3]uint8)
+
+	// Get returns the color of the cell and whether it is filled.
+	Get(x, y, z int) ([3]uint8, bool)
+
+	// Delete empties the cell at the given position.
+	Delete(x, y, z int)
+
+	// Len returns the number of filled cells.
+	Len() int
+
+	// Range calls fn for every filled cell until fn returns false, in an order
+	// that is the same on every run for the same cells.
+	Range(fn func(x, y, z int, color [3]uint8) bool)
+}
+
+// StorageMode selects the VoxelStore implementation for a new grid.
+type StorageMode int
+
+const (
+	StorageAuto   StorageMode = iota // Pick by expected fill ratio and memory budget
+	StorageSparse                    // Hash map keyed by position
+	StorageDense                     // Flat color array with an occupancy bitset
+	StorageOctree                    // 4x4x4 bricks under an octree, for very high resolutions
+)
+
+// storageModeNames lists the StorageMode names in constant order.
+var storageModeNames = []string{"auto", "sparse", "dense", "octree"}
+
+// StorageModes returns the names accepted by StorageMode.UnmarshalText.
+func StorageModes() []string {
+	return append([]string(nil), storageModeNames...)
+}
+
+// String returns the mode's name.
+func (m StorageMode) String() string {
+	if m >= 0 && int(m) < len(storageModeNames) {
+		return storageModeNames[m]
+	}
+	return fmt.Sprintf("StorageMode(%d)", int(m))
+}
+
+// MarshalText encodes the mode as its name.
+func (m StorageMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText parses a mode name such as "octree".
+func (m *StorageMode) UnmarshalText(text []byte) error {
+	for i, name := range storageModeNames {
+		if name == string(text) {
+			*m = StorageMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown storage mode %q (supported: %s)",
+		ErrInvalidConfig, text, strings.Join(storageModeNames, ", "))
+}
+
+// StorageConfig controls how voxel grids store their cells.
+type StorageConfig struct {
+	Mode         StorageMode
+	MemoryBudget int64 // Bytes StorageAuto may spend on a dense array (0 = unlimited)
+}
+
+// Approximate memory costs used by StorageAuto.
+const (
+	sparseBytesPerVoxel = 64           // Map entry with key, value and bucket overhead
+	minDenseCells       = 32 * 32 * 32 // Below this the map never grows large enough to matter
+	minOctreeVoxels     = 1 << 22      // Above this a map takes hundreds of megabytes; the octree a fraction
+)
+
+// newStore chooses a store for a grid of the given size expected to hold about
+// expectedVoxels filled cells.
+func newStore(sizeX, sizeY, sizeZ int, expectedVoxels int64, config StorageConfig) VoxelStore {
+	switch config.Mode {
+	case StorageSparse:
+		return newSparseStore()
+	case StorageDense:
+		return newDenseStore(sizeX, sizeY, sizeZ)
+	case StorageOctree:
+		return newOctreeStore(sizeX, sizeY, sizeZ)
+	}
+
+	cells := int64(sizeX) * int64(sizeY) * int64(sizeZ)
+	if cells < minDenseCells {
+		return newSparseStore()
+	}
+	withinBudget := config.MemoryBudget <= 0 || denseStoreBytes(cells) <= config.MemoryBudget
+	if withinBudget && denseStoreBytes(cells) <= expectedVoxels*sparseBytesPerVoxel {
+		return newDenseStore(sizeX, sizeY, sizeZ)
+	}
+	if expectedVoxels >= minOctreeVoxels {
+		return newOctreeStore(sizeX, sizeY, sizeZ)
+	}
+	return newSparseStore()
+}
+
+// newStoreLike returns an empty store of the same kind as store.
+func newStoreLike(store VoxelStore, sizeX, sizeY, sizeZ int) VoxelStore {
+	switch store.(type) {
+	case *denseStore:
+		return newDenseStore(sizeX, sizeY, sizeZ)
+	case *octreeStore:
+		return newOctreeStore(sizeX, sizeY, sizeZ)
+	}
+	return newSparseStore()
+}
+
+// denseStoreBytes returns the memory a dense store needs for the given number of cells.
+func denseStoreBytes(cells int64) int64 {
+	return cells*3 + (cells+63)/64*8
+}
+
+// sparseStore keeps filled cells in a map; memory grows with the number of voxels.
+// Range visits cells in x, then y, then z order like denseStore, not in map order,
+// so exports do not change from run to run.
+type sparseStore struct {
+	cells map[[3]int][3]uint8
+}
+
+func newSparseStore() *sparseStore {
+	return &sparseStore{cells: make(map[[3]int][3]uint8)}
+}
+
+func (s *sparseStore) Set(x, y, z int, color [3]uint8) {
+	s.cells[[3]int{x, y, z}] = color
+}
+
+func (s *sparseStore) Get(x, y, z int) ([3]uint8, bool) {
+	color, ok := s.cells[[3]int{x, y, z}]
+	return color, ok
+}
+
+func (s *sparseStore) Delete(x, y, z int) {
+	delete(s.cells, [3]int{x, y, z})
+}
+
+func (s *sparseStore) Len() int {
+	return len(s.cells)
+}
+
+func (s *sparseStore) Range(fn func(x, y, z int, color [3]uint8) bool) {
+	keys := make([][3]int, 0, len(s.cells))
+	for pos := range s.cells {
+		keys = append(keys, pos)
+	}
+	slices.SortFunc(keys, func(a, b [3]int) int {
+		if c := cmp.Compare(a[2], b[2]); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a[1], b[1]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a[0], b[0])
+	})
+	for _, pos := range keys {
+		color, ok := s.cells[pos]
+		if !ok {
+			continue // Deleted by fn
+		}
+		if !fn(pos[0], pos[1], pos[2], color) {
+			return
+		}
+	}
+}
+
+// denseStore keeps three color bytes per cell plus an occupancy bit; memory grows
+// with the grid volume. Range visits cells in x, then y, then z order.
+type denseStore struct {
+	sizeX, sizeY int
+	colors       []uint8
+	occupied     []uint64
+	count        int
+}
+
+func newDenseStore(sizeX, sizeY, sizeZ int) *denseStore {
+	cells := sizeX * sizeY * sizeZ
+	return &denseStore{
+		sizeX:    sizeX,
+		sizeY:    sizeY,
+		colors:   make([]uint8, cells*3),
+		occupied: make([]uint64, (cells+63)/64),
+	}
+}
+
+func (s *denseStore) index(x, y, z int) int {
+	return x + s.sizeX*(y+s.sizeY*z)
+}
+
+func (s *denseStore) Set(x, y, z int, color [3]uint8) {
+	i := s.index(x, y, z)
+	word, bit := i/64, uint64(1)<<(i%64)
+	if s.occupied[word]&bit == 0 {
+		s.occupied[word] |= bit
+		s.count++
+	}
+	copy(s.colors[i*3:i*3+3], color[:])
+}
+
+func (s *denseStore) Get(x, y, z int) ([3]uint8, bool) {
+	i := s.index(x, y, z)
+	if s.occupied[i/64]&(1<<(i%64)) == 0 {
+		return [3]uint8{}, false
+	}
+	return [3]uint8{s.colors[i*3], s.colors[i*3+1], s.colors[i*3+2]}, true
+}
+
+func (s *denseStore) Delete(x, y, z int) {
+	i := s.index(x, y, z)
+	word, bit := i/64, uint64(1)<<(i%64)
+	if s.occupied[word]&bit != 0 {
+		s.occupied[word] &^= bit
+		s.count--
+	}
+}
+
+func (s *denseStore) Len() int {
+	return s.count
+}
+
+func (s *denseStore) Range(fn func(x, y, z int, color [3]uint8) bool) {
+	plane := s.sizeX * s.sizeY
+	for word, bitsSet := range s.occupied {
+		for bitsSet != 0 {
+			i := word*64 + bits.TrailingZeros64(bitsSet)
+			bitsSet &= bitsSet - 1
+
+			color := [3]uint8{s.colors[i*3], s.colors[i*3+1], s.colors[i*3+2]}
+			if !fn(i%s.sizeX, i%plane/s.sizeX, i/plane, color) {
+				return
+			}
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"image"
+	"image/color"
 	"math"
+	"runtime"
+	"sync"
 )
 
 // SurfaceVoxelizer implements basic surface voxelization.
@@ -15,8 +20,13 @@ func NewSurfaceVoxelizer() *SurfaceVoxelizer {
 
 // Voxelize converts a mesh to a voxel grid using surface voxelization.
 func (v *SurfaceVoxelizer) Voxelize(mesh *Mesh, config VoxelizationConfig) (*VoxelGrid, error) {
+	return v.VoxelizeCtx(context.Background(), mesh, config)
+}
+
+// VoxelizeCtx is like Voxelize but stops early when ctx is done.
+func (v *SurfaceVoxelizer) VoxelizeCtx(ctx context.Context, mesh *Mesh, config VoxelizationConfig) (*VoxelGrid, error) {
 	if len(mesh.Vertices) == 0 {
-		return nil, fmt.Errorf("mesh has no vertices")
+		return nil, fmt.Errorf("%w: no vertices", ErrEmptyMesh)
 	}
 	
 	// Calculate bounds if not already done
@@ -34,76 +44,378 @@ func (v *SurfaceVoxelizer) Voxelize(mesh *Mesh, config VoxelizationConfig) (*Vox
 	// Find longest dimension
 	maxDim := math.Max(dims[0], math.Max(dims[1], dims[2]))
 	if maxDim == 0 {
-		return nil, fmt.Errorf("mesh has zero size")
+		return nil, fmt.Errorf("%w: zero size", ErrEmptyMesh)
 	}
 	
 	// Calculate scale
-	scale := float64(config.Resolution) / maxDim
-	if config.Scale > 0 {
-		scale = config.Scale
+	scale := gridScale(dims, maxDim, config)
+	
+	// Calculate grid size; flat meshes still get one layer of voxels
+	sizeX := max(1, int(math.Ceil(dims[0]*scale)))
+	sizeY := max(1, int(math.Ceil(dims[1]*scale)))
+	sizeZ := max(1, int(math.Ceil(dims[2]*scale)))
+	if config.Scale <= 0 {
+		// Keep rounding error from pushing a fitted axis one voxel past its cap
+		sizeX, sizeY, sizeZ = capSize(sizeX, config.TargetSize[0]), capSize(sizeY, config.TargetSize[1]), capSize(sizeZ, config.TargetSize[2])
+	}
+	
+	// Center flat models in grids deep enough for their thickened surfaces
+	origin := mesh.Bounds.Min
+	if config.MinThickness > 1 {
+		for i, size := range [3]*int{&sizeX, &sizeY, &sizeZ} {
+			depth := config.MinThickness
+			if config.Scale <= 0 {
+				depth = capSize(depth, config.TargetSize[i])
+			}
+			if *size < depth {
+				origin[i] -= (float64(depth) - dims[i]*scale) / (2 * scale)
+				*size = depth
+			}
+		}
 	}
 	
-	// Calculate grid size
-	sizeX := int(math.Ceil(dims[0] * scale))
-	sizeY := int(math.Ceil(dims[1] * scale))
-	sizeZ := int(math.Ceil(dims[2] * scale))
+	// Enforce the cell limit before allocating anything. This bounds dense per-cell
+	// buffers such as the schematic block array; the sparse grid itself only pays
+	// for filled voxels.
+	if config.MaxCells > 0 && int64(sizeX)*int64(sizeY)*int64(sizeZ) > int64(config.MaxCells) {
+		return nil, fmt.Errorf("%w: %dx%dx%d grid exceeds %d cells", ErrGridTooLarge, sizeX, sizeY, sizeZ, config.MaxCells)
+	}
 	
-	// Create voxel grid
-	voxelGrid := NewVoxelGrid(sizeX, sizeY, sizeZ)
+	// Create voxel grid; a surface covers roughly two voxels per unit of area, a
+	// filled mesh a good part of its bounding box
+	expected := int64(meshSurfaceArea(mesh) * scale * scale * 2)
+	if cells := int64(sizeX) * int64(sizeY) * int64(sizeZ); config.Fill && cells/2 > expected {
+		expected = cells / 2
+	}
+	voxelGrid := NewVoxelGridFor(sizeX, sizeY, sizeZ, expected, config.Storage)
 	voxelGrid.Scale = scale
-	voxelGrid.Origin = mesh.Bounds.Min
+	voxelGrid.Origin = origin
 	
 	// Voxelize each face
-	for _, face := range mesh.Faces {
-		if len(face.VertexIndices) < 3 {
-			continue
+	tracker := startStage(config.Progress, StageVoxelize, int64(len(mesh.Faces)))
+	workers := config.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, (len(mesh.Faces)+minFacesPerWorker-1)/minFacesPerWorker)
+	sums := newColorSums(config.Samples)
+	if workers > 1 {
+		if err := v.rasterizeParallel(ctx, mesh, voxelGrid, sums, workers, &config, tracker); err != nil {
+			return nil, err
 		}
-		
-		// Get triangle vertices
-		v0 := mesh.Vertices[face.VertexIndices[0]].Position
-		v1 := mesh.Vertices[face.VertexIndices[1]].Position
-		v2 := mesh.Vertices[face.VertexIndices[2]].Position
-		
-		// Get material color
-		color := [3]uint8{128, 128, 128} // Default gray
-		if face.MaterialIndex >= 0 && face.MaterialIndex < len(mesh.Materials) {
-			mat := mesh.Materials[face.MaterialIndex]
-			color = [3]uint8{
-				uint8(mat.DiffuseColor[0] * 255),
-				uint8(mat.DiffuseColor[1] * 255),
-				uint8(mat.DiffuseColor[2] * 255),
+	} else {
+		for i := range mesh.Faces {
+			if i%ctxCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
 			}
+			tracker.add(1)
+			v.rasterizeFace(voxelGrid, sums, mesh, mesh.Faces[i], &config)
+		}
+	}
+	sums.resolve(voxelGrid)
+	tracker.finish()
+	
+	if config.Fill {
+		if err := fillInterior(ctx, voxelGrid); err != nil {
+			return nil, err
+		}
+	}
+	if config.Hollow > 0 {
+		if _, err := voxelGrid.HollowCtx(ctx, config.Hollow); err != nil {
+			return nil, err
 		}
-		
-		// Rasterize triangle
-		v.rasterizeTriangle(voxelGrid, v0, v1, v2, color, config.Conservative)
 	}
 	
 	return voxelGrid, nil
 }
 
-// rasterizeTriangle rasterizes a triangle into the voxel grid.
-func (v *SurfaceVoxelizer) rasterizeTriangle(grid *VoxelGrid, v0, v1, v2 [3]float64, color [3]uint8, conservative bool) {
+// minFacesPerWorker keeps small meshes from being split across more goroutines
+// than the merge is worth.
+const minFacesPerWorker = 4096
+
+// rasterizeParallel splits the faces into one contiguous run per worker, each
+// rasterized into its own sparse grid, and merges the grids in face order so the
+// result matches rasterizing sequentially. When supersampling, each worker
+// collects its own color samples instead, merged into sums. Progress is reported
+// from the calling goroutine.
+func (v *SurfaceVoxelizer) rasterizeParallel(ctx context.Context, mesh *Mesh, grid *VoxelGrid, sums colorSums, workers int, config *VoxelizationConfig, tracker *progressTracker) error {
+	partials := make([]*VoxelGrid, workers)
+	partialSums := make([]colorSums, workers)
+	progress := make(chan int64, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start, end := len(mesh.Faces)*w/workers, len(mesh.Faces)*(w+1)/workers
+		// Partials stay sparse rather than copying a dense store per worker, but an
+		// octree grid's partials are octrees too, so they stay small at high resolutions
+		partial := NewVoxelGrid(grid.SizeX, grid.SizeY, grid.SizeZ)
+		if _, ok := grid.store.(*octreeStore); ok {
+			partial = grid.emptyLike()
+		}
+		partial.Scale, partial.Origin = grid.Scale, grid.Origin
+		partials[w] = partial
+		partialSums[w] = newColorSums(config.Samples)
+		
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := start; i < end; i += ctxCheckInterval {
+				if ctx.Err() != nil {
+					return
+				}
+				batchEnd := min(i+ctxCheckInterval, end)
+				for _, face := range mesh.Faces[i:batchEnd] {
+					v.rasterizeFace(partial, partialSums[w], mesh, face, config)
+				}
+				progress <- int64(batchEnd - i)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(progress)
+	}()
+	for n := range progress {
+		tracker.add(n)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	
+	for w, partial := range partials {
+		partial.Range(func(x, y, z int, color [3]uint8) bool {
+			grid.SetVoxel(x, y, z, color)
+			return true
+		})
+		grid.addMaterials(partial.Materials)
+		if sums != nil {
+			sums.merge(partialSums[w])
+		}
+	}
+	return nil
+}
+
+// gridScale returns the voxels per mesh unit for a mesh of the given dimensions:
+// config.Scale when set, otherwise the largest scale keeping every capped axis
+// within config.TargetSize, otherwise the one fitting the longest axis to
+// config.Resolution. Flat axes fit any cap and are skipped.
+func gridScale(dims [3]float64, maxDim float64, config VoxelizationConfig) float64 {
+	if config.Scale > 0 {
+		return config.Scale
+	}
+	scale := 0.0
+	resolution := config.Resolution
+	for i, target := range config.TargetSize {
+		if target <= 0 {
+			continue
+		}
+		if config.Resolution <= 0 {
+			resolution = max(resolution, target) // In case only flat axes are capped
+		}
+		if dims[i] == 0 {
+			continue
+		}
+		if s := float64(target) / dims[i]; scale == 0 || s < scale {
+			scale = s
+		}
+	}
+	if scale == 0 {
+		scale = float64(resolution) / maxDim
+	}
+	return scale
+}
+
+// capSize limits size to target when target is positive.
+func capSize(size, target int) int {
+	if target > 0 && size > target {
+		return target
+	}
+	return size
+}
+
+// rasterizeFace rasterizes one face's first triangle into the grid, or into sums
+// when supersampling.
+func (v *SurfaceVoxelizer) rasterizeFace(grid *VoxelGrid, sums colorSums, mesh *Mesh, face Face, config *VoxelizationConfig) {
+	if len(face.VertexIndices) < 3 {
+		return
+	}
+	v0 := mesh.Vertices[face.VertexIndices[0]].Position
+	v1 := mesh.Vertices[face.VertexIndices[1]].Position
+	v2 := mesh.Vertices[face.VertexIndices[2]].Position
+	shading := newFaceShading(mesh, face)
+	shading.light(mesh, face, config.Lighting)
+	v.rasterizeTriangle(grid, sums, v0, v1, v2, shading, config)
+}
+
+// faceShading determines the colors of the voxels a face covers.
+type faceShading struct {
+	color        [3]uint8       // Flat color, used without vertex colors or texture
+	vertexColors *[3][3]float64 // Vertex colors interpolated across the face
+	texture      image.Image    // Sampled at the interpolated texCoords
+	texCoords    [3][2]float64
+	tint         [3]float64     // Multiplies texture samples
+	material     *VoxelMaterial // Recorded for the colors the face produces, nil for plain surfaces
+	emissive     image.Image    // Varies material's emission, sampled like texture
+	emission     [3]float64     // Multiplies emissive samples
+	base         VoxelMaterial  // The material without emission, when emissive is set
+	brightness   *[3]float64    // Baked lighting at the vertices, multiplying colors (nil = unlit)
+}
+
+// newFaceShading collects the coloring inputs of a triangle face.
+func newFaceShading(mesh *Mesh, face Face) *faceShading {
+	s := &faceShading{color: [3]uint8{128, 128, 128}} // Default gray
+	if face.MaterialIndex >= 0 && face.MaterialIndex < len(mesh.Materials) {
+		mat := &mesh.Materials[face.MaterialIndex]
+		s.color = [3]uint8{
+			uint8(mat.DiffuseColor[0] * 255),
+			uint8(mat.DiffuseColor[1] * 255),
+			uint8(mat.DiffuseColor[2] * 255),
+		}
+		if mat.Texture != nil {
+			s.texture = mat.Texture
+			s.tint = mat.DiffuseColor
+		}
+		s.material = voxelMaterialOf(mat)
+		if mat.EmissiveTexture != nil {
+			s.emissive, s.emission = mat.EmissiveTexture, mat.EmissiveColor
+			plain := *mat
+			plain.EmissiveColor = [3]float64{}
+			if m := voxelMaterialOf(&plain); m != nil {
+				s.base = *m
+			}
+		}
+	}
+	for i := 0; i < 3; i++ {
+		vertex := &mesh.Vertices[face.VertexIndices[i]]
+		s.texCoords[i] = vertex.TexCoord
+		if mesh.HasVertexColors {
+			if s.vertexColors == nil {
+				s.vertexColors = new([3][3]float64)
+			}
+			s.vertexColors[i] = vertex.Color
+		}
+	}
+	return s
+}
+
+// varies reports whether colors differ across the face, so each voxel needs its own.
+func (s *faceShading) varies() bool {
+	return s.vertexColors != nil || s.texture != nil || s.brightness != nil
+}
+
+// colorAt returns the color at barycentric coordinates w, darkened by baked
+// lighting.
+func (s *faceShading) colorAt(w [3]float64) [3]uint8 {
+	rgb := s.surfaceColorAt(w)
+	if s.brightness != nil {
+		rgb = shade(rgb, s.brightness[0]*w[0]+s.brightness[1]*w[1]+s.brightness[2]*w[2])
+	}
+	return rgb
+}
+
+// surfaceColorAt returns the unlit color at barycentric coordinates w. Vertex
+// colors take precedence over the texture.
+func (s *faceShading) surfaceColorAt(w [3]float64) [3]uint8 {
+	if s.vertexColors != nil {
+		return interpolateColor(s.vertexColors, w)
+	}
+	if s.texture == nil {
+		return s.color
+	}
+	var uv [2]float64
+	for i := 0; i < 3; i++ {
+		uv[0] += s.texCoords[i][0] * w[i]
+		uv[1] += s.texCoords[i][1] * w[i]
+	}
+	sample := sampleTexture(s.texture, uv)
+	var rgb [3]uint8
+	for c := 0; c < 3; c++ {
+		rgb[c] = uint8(math.Round(float64(sample[c]) * math.Max(0, math.Min(1, s.tint[c]))))
+	}
+	return rgb
+}
+
+// materialAt returns the material at point p, in the plane of the triangle abc,
+// sampling the emissive texture when there is one.
+func (s *faceShading) materialAt(p, a, b, c [3]float64) *VoxelMaterial {
+	if s.emissive == nil {
+		return s.material
+	}
+	w := barycentric(p, a, b, c)
+	var uv [2]float64
+	for i := 0; i < 3; i++ {
+		uv[0] += s.texCoords[i][0] * w[i]
+		uv[1] += s.texCoords[i][1] * w[i]
+	}
+	sample := sampleTexture(s.emissive, uv)
+	var emission [3]float64
+	for i := range emission {
+		emission[i] = float64(sample[i]) / 255 * s.emission[i]
+	}
+	m := s.base
+	m.Emission = maxChannel(emission)
+	return m.orNil()
+}
+
+// sampleTexture returns the texel nearest to uv, wrapping coordinates outside [0,1].
+func sampleTexture(img image.Image, uv [2]float64) [3]uint8 {
+	b := img.Bounds()
+	wrap := func(t float64, n int) int {
+		i := int(math.Floor((t - math.Floor(t)) * float64(n)))
+		return min(i, n-1)
+	}
+	x := b.Min.X + wrap(uv[0], b.Dx())
+	y := b.Min.Y + wrap(uv[1], b.Dy())
+	c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+	return [3]uint8{c.R, c.G, c.B}
+}
+
+// conservativeDilation is how far, in voxels, Conservative grows each voxel's box
+// before testing it against a triangle, so rounding never leaves cracks along
+// shared edges and thin features stay connected.
+const conservativeDilation = 0.25
+
+// rasterizeTriangle rasterizes a triangle into the voxel grid, setting every voxel
+// whose box overlaps it and coloring each as shading gives at the voxel center.
+// With sums, it adds config.Samples color samples per voxel to them instead and
+// leaves the grid alone.
+func (v *SurfaceVoxelizer) rasterizeTriangle(grid *VoxelGrid, sums colorSums, v0, v1, v2 [3]float64, shading *faceShading, config *VoxelizationConfig) {
 	// Transform vertices to voxel space
 	v0Voxel := v.worldToVoxel(v0, grid)
 	v1Voxel := v.worldToVoxel(v1, grid)
 	v2Voxel := v.worldToVoxel(v2, grid)
 	
-	// Calculate triangle bounds
-	minX := int(math.Floor(math.Min(v0Voxel[0], math.Min(v1Voxel[0], v2Voxel[0]))))
-	maxX := int(math.Ceil(math.Max(v0Voxel[0], math.Max(v1Voxel[0], v2Voxel[0]))))
-	minY := int(math.Floor(math.Min(v0Voxel[1], math.Min(v1Voxel[1], v2Voxel[1]))))
-	maxY := int(math.Ceil(math.Max(v0Voxel[1], math.Max(v1Voxel[1], v2Voxel[1]))))
-	minZ := int(math.Floor(math.Min(v0Voxel[2], math.Min(v1Voxel[2], v2Voxel[2]))))
-	maxZ := int(math.Ceil(math.Max(v0Voxel[2], math.Max(v1Voxel[2], v2Voxel[2]))))
+	halfSize := 0.5
+	if config.Conservative {
+		halfSize += conservativeDilation
+	}
+	
+	// MinThickness sweeps the triangle along its normal, offset to either side
+	var offset [3]float64
+	if config.MinThickness > 1 {
+		n := cross3(sub3(v1Voxel, v0Voxel), sub3(v2Voxel, v0Voxel))
+		if length := math.Sqrt(dot3(n, n)); length > 0 {
+			for i := range n {
+				offset[i] = n[i] / length * float64(config.MinThickness-1) / 2
+			}
+		}
+	}
+	
+	// Voxels whose (dilated) box can reach the triangle's (swept) bounds; voxel i
+	// spans [i+0.5-halfSize, i+0.5+halfSize]
+	var lo, hi [3]int
+	for i := 0; i < 3; i++ {
+		tMin := math.Min(v0Voxel[i], math.Min(v1Voxel[i], v2Voxel[i])) - math.Abs(offset[i])
+		tMax := math.Max(v0Voxel[i], math.Max(v1Voxel[i], v2Voxel[i])) + math.Abs(offset[i])
+		lo[i] = int(math.Floor(tMin - 0.5 - halfSize))
+		hi[i] = int(math.Ceil(tMax - 0.5 + halfSize))
+	}
 	
 	// Clamp to grid bounds
-	minX = max(0, minX)
-	maxX = min(grid.SizeX-1, maxX)
-	minY = max(0, minY)
-	maxY = min(grid.SizeY-1, maxY)
-	minZ = max(0, minZ)
-	maxZ = min(grid.SizeZ-1, maxZ)
+	minX, maxX := max(0, lo[0]), min(grid.SizeX-1, hi[0])
+	minY, maxY := max(0, lo[1]), min(grid.SizeY-1, hi[1])
+	minZ, maxZ := max(0, lo[2]), min(grid.SizeZ-1, hi[2])
 	
 	// Scan all voxels in the bounding box
 	for x := minX; x <= maxX; x++ {
@@ -115,9 +427,24 @@ func (v *SurfaceVoxelizer) rasterizeTriangle(grid *VoxelGrid, v0, v1, v2 [3]floa
 					float64(z) + 0.5,
 				}
 				
-				// Check if voxel intersects triangle
-				if v.voxelIntersectsTriangle(voxelCenter, v0Voxel, v1Voxel, v2Voxel, conservative) {
-					grid.SetVoxel(x, y, z, color)
+				if offset == ([3]float64{}) {
+					if !triangleIntersectsBox(voxelCenter, halfSize, v0Voxel, v1Voxel, v2Voxel) {
+						continue
+					}
+				} else if !prismIntersectsBox(voxelCenter, halfSize, v0Voxel, v1Voxel, v2Voxel, offset) {
+					continue
+				}
+				if sums != nil {
+					sums.addSamples(x, y, z, shading, v0Voxel, v1Voxel, v2Voxel, config.Samples)
+					continue
+				}
+				color := shading.color
+				if shading.varies() {
+					color = shading.colorAt(barycentric(voxelCenter, v0Voxel, v1Voxel, v2Voxel))
+				}
+				grid.SetVoxel(x, y, z, color)
+				if material := shading.materialAt(voxelCenter, v0Voxel, v1Voxel, v2Voxel); material != nil {
+					grid.setMaterial(color, *material)
 				}
 			}
 		}
@@ -133,48 +460,90 @@ func (v *SurfaceVoxelizer) worldToVoxel(world [3]float64, grid *VoxelGrid) [3]fl
 	}
 }
 
-// voxelIntersectsTriangle checks if a voxel intersects with a triangle.
-// This is a simplified check using barycentric coordinates.
-func (v *SurfaceVoxelizer) voxelIntersectsTriangle(voxel, v0, v1, v2 [3]float64, conservative bool) bool {
-	// Calculate triangle normal
-	edge1 := sub3(v1, v0)
-	edge2 := sub3(v2, v0)
-	normal := cross3(edge1, edge2)
+// triangleIntersectsBox reports whether a triangle overlaps the cube with the
+// given center and half size, using the separating axis test of Akenine-Möller:
+// the three box axes, the triangle normal and the nine cross products of box axes
+// and triangle edges. Touching counts as overlapping. The axes need not be
+// normalized since the projected radius scales with them.
+func triangleIntersectsBox(center [3]float64, halfSize float64, a, b, c [3]float64) bool {
+	v0, v1, v2 := sub3(a, center), sub3(b, center), sub3(c, center)
 	
-	// Calculate distance from voxel to triangle plane
-	d := dot3(normal, v0)
-	dist := math.Abs(dot3(normal, voxel) - d)
+	// separated reports whether the triangle's projection onto axis lies outside
+	// the box's
+	separated := func(axis [3]float64) bool {
+		p0, p1, p2 := dot3(axis, v0), dot3(axis, v1), dot3(axis, v2)
+		r := halfSize * (math.Abs(axis[0]) + math.Abs(axis[1]) + math.Abs(axis[2]))
+		return math.Min(p0, math.Min(p1, p2)) > r || math.Max(p0, math.Max(p1, p2)) < -r
+	}
 	
-	// Check if voxel is close to plane
-	threshold := 0.866 // sqrt(3)/2 for voxel diagonal
-	if conservative {
-		threshold *= 1.5
+	// Box face normals: the bounding boxes must overlap
+	for i := 0; i < 3; i++ {
+		var axis [3]float64
+		axis[i] = 1
+		if separated(axis) {
+			return false
+		}
 	}
 	
-	if dist > threshold {
+	// Triangle normal: the box must straddle the triangle's plane
+	edges := [3][3]float64{sub3(v1, v0), sub3(v2, v1), sub3(v0, v2)}
+	if separated(cross3(edges[0], edges[1])) {
 		return false
 	}
 	
-	// Check if projection is inside triangle using barycentric coordinates
-	// Simplified check: test if point is on same side of all edges
-	return v.pointInTriangle2D(voxel, v0, v1, v2)
+	// Edge cross products; a zero axis (degenerate edge) never separates
+	for _, edge := range edges {
+		for i := 0; i < 3; i++ {
+			var unit [3]float64
+			unit[i] = 1
+			if separated(cross3(unit, edge)) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
-// pointInTriangle2D checks if a point is inside a triangle using 2D projection.
-func (v *SurfaceVoxelizer) pointInTriangle2D(p, v0, v1, v2 [3]float64) bool {
-	// Use XY projection for simplicity
-	sign := func(p1, p2, p3 [3]float64) float64 {
-		return (p1[0]-p3[0])*(p2[1]-p3[1]) - (p2[0]-p3[0])*(p1[1]-p3[1])
+// prismIntersectsBox reports whether the prism a triangle sweeps moving from
+// -offset to +offset overlaps the cube with the given center and half size. Like
+// triangleIntersectsBox it tests separating axes: the box axes, the prism's cap
+// and side normals and the cross products of box axes and prism edges.
+func prismIntersectsBox(center [3]float64, halfSize float64, a, b, c, offset [3]float64) bool {
+	var corners [6][3]float64
+	for i, p := range [3][3]float64{a, b, c} {
+		p = sub3(p, center)
+		corners[2*i] = sub3(p, offset)
+		corners[2*i+1] = [3]float64{p[0] + offset[0], p[1] + offset[1], p[2] + offset[2]}
 	}
 	
-	d1 := sign(p, v0, v1)
-	d2 := sign(p, v1, v2)
-	d3 := sign(p, v2, v0)
-	
-	hasNeg := (d1 < 0) || (d2 < 0) || (d3 < 0)
-	hasPos := (d1 > 0) || (d2 > 0) || (d3 > 0)
+	separated := func(axis [3]float64) bool {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, p := range corners {
+			d := dot3(axis, p)
+			lo, hi = math.Min(lo, d), math.Max(hi, d)
+		}
+		r := halfSize * (math.Abs(axis[0]) + math.Abs(axis[1]) + math.Abs(axis[2]))
+		return lo > r || hi < -r
+	}
 	
-	return !(hasNeg && hasPos)
+	edges := [4][3]float64{sub3(b, a), sub3(c, b), sub3(a, c), offset}
+	axes := [][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}, cross3(edges[0], edges[1])}
+	for _, edge := range edges[:3] {
+		axes = append(axes, cross3(edge, offset))
+	}
+	for _, edge := range edges {
+		for i := 0; i < 3; i++ {
+			var unit [3]float64
+			unit[i] = 1
+			axes = append(axes, cross3(unit, edge))
+		}
+	}
+	for _, axis := range axes {
+		if separated(axis) {
+			return false
+		}
+	}
+	return true
 }
 
 // Name returns the algorithm name.
@@ -182,6 +551,68 @@ func (v *SurfaceVoxelizer) Name() string {
 	return "surface-voxelizer"
 }
 
+// barycentric returns the barycentric coordinates of p projected onto the
+// triangle's plane, clamped to the triangle.
+func barycentric(p, a, b, c [3]float64) [3]float64 {
+	w, ok := planeBarycentric(p, a, b, c)
+	if !ok {
+		return [3]float64{1.0 / 3, 1.0 / 3, 1.0 / 3}
+	}
+	
+	// Voxels near an edge can project outside the triangle
+	sum := 0.0
+	for i := range w {
+		w[i] = math.Max(w[i], 0)
+		sum += w[i]
+	}
+	for i := range w {
+		w[i] /= sum
+	}
+	return w
+}
+
+// planeBarycentric returns the barycentric coordinates of p projected onto the
+// triangle's plane, negative outside the triangle. It reports false for a
+// degenerate triangle.
+func planeBarycentric(p, a, b, c [3]float64) ([3]float64, bool) {
+	ab, ac, ap := sub3(b, a), sub3(c, a), sub3(p, a)
+	d00, d01, d11 := dot3(ab, ab), dot3(ab, ac), dot3(ac, ac)
+	d20, d21 := dot3(ap, ab), dot3(ap, ac)
+	denom := d00*d11 - d01*d01
+	if denom == 0 {
+		return [3]float64{}, false
+	}
+	w1 := (d11*d20 - d01*d21) / denom
+	w2 := (d00*d21 - d01*d20) / denom
+	return [3]float64{1 - w1 - w2, w1, w2}, true
+}
+
+// interpolateColor blends three [0,1] colors with barycentric weights.
+func interpolateColor(colors *[3][3]float64, w [3]float64) [3]uint8 {
+	var rgb [3]uint8
+	for c := 0; c < 3; c++ {
+		v := colors[0][c]*w[0] + colors[1][c]*w[1] + colors[2][c]*w[2]
+		rgb[c] = uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	return rgb
+}
+
+// meshSurfaceArea returns the total area of the mesh's triangles in mesh units.
+func meshSurfaceArea(mesh *Mesh) float64 {
+	area := 0.0
+	for _, face := range mesh.Faces {
+		if len(face.VertexIndices) < 3 {
+			continue
+		}
+		v0 := mesh.Vertices[face.VertexIndices[0]].Position
+		v1 := mesh.Vertices[face.VertexIndices[1]].Position
+		v2 := mesh.Vertices[face.VertexIndices[2]].Position
+		n := cross3(sub3(v1, v0), sub3(v2, v0))
+		area += math.Sqrt(dot3(n, n)) / 2
+	}
+	return area
+}
+
 // Helper functions
 func sub3(a, b [3]float64) [3]float64 {
 	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
@@ -212,3 +643,10 @@ func max(a, b int) int {
 	}
 	return b
 }
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
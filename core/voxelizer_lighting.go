@@ -0,0 +1,62 @@
+package core
+
+import "math"
+
+// LightingConfig bakes directional lighting into voxel colors, darkening
+// surfaces by how far their normals turn from the light, so models read with
+// depth in game, where every block face is lit alike.
+type LightingConfig struct {
+	Enabled   bool
+	Direction [3]float64 // Toward the light, in grid space with y up (zero = DefaultLightDirection)
+	Ambient   float64    // Brightness of surfaces facing away from the light, from 0 (black) to 1 (unlit)
+}
+
+// DefaultLightDirection lights models from above, slightly in front and to the
+// left, like a late morning sun over a build's front.
+var DefaultLightDirection = [3]float64{-1, 2, 1}
+
+// brightness returns the factor a surface with normal n is darkened by: 1 facing
+// the light, falling with the cosine of the angle to Ambient at right angles and
+// beyond. Surfaces without a normal are left unchanged.
+func (c LightingConfig) brightness(n [3]float64) float64 {
+	length := math.Sqrt(dot3(n, n))
+	light := c.Direction
+	if light == ([3]float64{}) {
+		light = DefaultLightDirection
+	}
+	lightLength := math.Sqrt(dot3(light, light))
+	if length == 0 || lightLength == 0 {
+		return 1
+	}
+	diffuse := math.Max(0, dot3(n, light)/(length*lightLength))
+	ambient := math.Max(0, math.Min(1, c.Ambient))
+	return ambient + (1-ambient)*diffuse
+}
+
+// light makes s darken its colors by config, by the brightness at each vertex's
+// normal, or at the face's normal for vertices without one.
+func (s *faceShading) light(mesh *Mesh, face Face, config LightingConfig) {
+	if !config.Enabled {
+		return
+	}
+	a := mesh.Vertices[face.VertexIndices[0]].Position
+	b := mesh.Vertices[face.VertexIndices[1]].Position
+	c := mesh.Vertices[face.VertexIndices[2]].Position
+	faceNormal := cross3(sub3(b, a), sub3(c, a))
+	s.brightness = new([3]float64)
+	for i := 0; i < 3; i++ {
+		n := mesh.Vertices[face.VertexIndices[i]].Normal
+		if n == ([3]float64{}) {
+			n = faceNormal
+		}
+		s.brightness[i] = config.brightness(n)
+	}
+}
+
+// shade darkens rgb by brightness.
+func shade(rgb [3]uint8, brightness float64) [3]uint8 {
+	for c := range rgb {
+		rgb[c] = uint8(math.Round(math.Max(0, math.Min(255, float64(rgb[c])*brightness))))
+	}
+	return rgb
+}
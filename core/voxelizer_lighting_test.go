@@ -0,0 +1,41 @@
+package core
+
+import "testing"
+
+func TestBakeLighting(t *testing.T) {
+	// A gray triangle facing up at y 0 and one facing down at y 4, lit from above
+	mesh := &Mesh{
+		Vertices: []Vertex{
+			{Position: [3]float64{0, 0, 0}}, {Position: [3]float64{0, 0, 1}}, {Position: [3]float64{1, 0, 0}},
+			{Position: [3]float64{0, 4, 0}}, {Position: [3]float64{1, 4, 0}}, {Position: [3]float64{0, 4, 1}},
+		},
+		Faces: []Face{
+			{VertexIndices: []int{0, 1, 2}, MaterialIndex: -1},
+			{VertexIndices: []int{3, 4, 5}, MaterialIndex: -1},
+		},
+	}
+	config := VoxelizationConfig{
+		Resolution: 4,
+		Lighting:   LightingConfig{Enabled: true, Direction: [3]float64{0, 1, 0}, Ambient: 0.25},
+	}
+	vg, err := NewSurfaceVoxelizer().Voxelize(mesh, config)
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	if c, _ := vg.ColorAt(0, 0, 0); c != [3]uint8{128, 128, 128} {
+		t.Errorf("lit voxel = %v, want unchanged gray", c)
+	}
+	if c, _ := vg.ColorAt(0, vg.SizeY-1, 0); c != [3]uint8{32, 32, 32} {
+		t.Errorf("shaded voxel = %v, want gray at ambient brightness", c)
+	}
+
+	// Points darken by their normals
+	mesh.Vertices[0].Normal = [3]float64{1, 0, 0}
+	vg, err = NewPointCloudVoxelizer().Voxelize(mesh, config)
+	if err != nil {
+		t.Fatalf("Voxelize points failed: %v", err)
+	}
+	if c, _ := vg.ColorAt(0, 0, 0); c[0] >= 128 {
+		t.Errorf("point voxel = %v, want darkened", c)
+	}
+}
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// PointCloudVoxelizer voxelizes the vertices of a mesh as a point cloud, such as
+// a LiDAR or photogrammetry scan, without meshing it first: each voxel holding
+// points takes their average color, and faces are ignored. Voxels holding fewer
+// than VoxelizationConfig.MinPoints points are dropped as noise, and
+// VoxelizationConfig.HoleFill closes the gaps sparse scans leave between points.
+type PointCloudVoxelizer struct{}
+
+// NewPointCloudVoxelizer creates a new point cloud voxelizer.
+func NewPointCloudVoxelizer() *PointCloudVoxelizer {
+	return &PointCloudVoxelizer{}
+}
+
+// Voxelize converts a mesh's vertices to a voxel grid.
+func (v *PointCloudVoxelizer) Voxelize(mesh *Mesh, config VoxelizationConfig) (*VoxelGrid, error) {
+	return v.VoxelizeCtx(context.Background(), mesh, config)
+}
+
+// VoxelizeCtx is like Voxelize but stops early when ctx is done.
+func (v *PointCloudVoxelizer) VoxelizeCtx(ctx context.Context, mesh *Mesh, config VoxelizationConfig) (*VoxelGrid, error) {
+	if len(mesh.Vertices) == 0 {
+		return nil, fmt.Errorf("%w: no points", ErrEmptyMesh)
+	}
+	if mesh.Bounds.Min == [3]float64{} && mesh.Bounds.Max == [3]float64{} {
+		mesh.CalculateBounds()
+	}
+	dims := [3]float64{
+		mesh.Bounds.Max[0] - mesh.Bounds.Min[0],
+		mesh.Bounds.Max[1] - mesh.Bounds.Min[1],
+		mesh.Bounds.Max[2] - mesh.Bounds.Min[2],
+	}
+	maxDim := math.Max(dims[0], math.Max(dims[1], dims[2]))
+	if maxDim == 0 {
+		return nil, fmt.Errorf("%w: zero size", ErrEmptyMesh)
+	}
+
+	scale := gridScale(dims, maxDim, config)
+	var size [3]int
+	for i := range size {
+		size[i] = max(1, int(math.Ceil(dims[i]*scale)))
+		if config.Scale <= 0 {
+			size[i] = capSize(size[i], config.TargetSize[i])
+		}
+	}
+	cells := int64(size[0]) * int64(size[1]) * int64(size[2])
+	if config.MaxCells > 0 && cells > int64(config.MaxCells) {
+		return nil, fmt.Errorf("%w: %dx%dx%d grid exceeds %d cells", ErrGridTooLarge, size[0], size[1], size[2], config.MaxCells)
+	}
+
+	// Bin the points; points on the bounds' far faces go in the last voxel
+	tracker := startStage(config.Progress, StageVoxelize, int64(len(mesh.Vertices)))
+	sums := make(map[[3]int]*pointSum)
+	for i := range mesh.Vertices {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			tracker.add(int64(min(ctxCheckInterval, len(mesh.Vertices)-i)))
+		}
+		vertex := &mesh.Vertices[i]
+		var cell [3]int
+		for a := range cell {
+			cell[a] = min(size[a]-1, max(0, int((vertex.Position[a]-mesh.Bounds.Min[a])*scale)))
+		}
+		sum := sums[cell]
+		if sum == nil {
+			sum = &pointSum{}
+			sums[cell] = sum
+		}
+		color := [3]float64{128, 128, 128} // Default gray
+		if mesh.HasVertexColors {
+			color = [3]float64{vertex.Color[0] * 255, vertex.Color[1] * 255, vertex.Color[2] * 255}
+		}
+		if config.Lighting.Enabled {
+			light := config.Lighting.brightness(vertex.Normal)
+			for c := range color {
+				color[c] *= light
+			}
+		}
+		for c := range color {
+			sum.rgb[c] += color[c]
+		}
+		sum.n++
+	}
+	tracker.finish()
+
+	minPoints := max(1, config.MinPoints)
+	expected := int64(len(sums))
+	if config.Fill && cells/2 > expected {
+		expected = cells / 2
+	}
+	voxelGrid := NewVoxelGridFor(size[0], size[1], size[2], expected, config.Storage)
+	voxelGrid.Scale = scale
+	voxelGrid.Origin = mesh.Bounds.Min
+	for cell, sum := range sums {
+		if sum.n < minPoints {
+			continue
+		}
+		var color [3]uint8
+		for c := range color {
+			color[c] = uint8(math.Round(math.Min(sum.rgb[c]/float64(sum.n), 255)))
+		}
+		voxelGrid.SetVoxel(cell[0], cell[1], cell[2], color)
+	}
+
+	for pass := 0; pass < config.HoleFill; pass++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if fillPointHoles(voxelGrid) == 0 {
+			break
+		}
+	}
+	if config.Fill {
+		if err := fillInterior(ctx, voxelGrid); err != nil {
+			return nil, err
+		}
+	}
+	if config.Hollow > 0 {
+		if _, err := voxelGrid.HollowCtx(ctx, config.Hollow); err != nil {
+			return nil, err
+		}
+	}
+	return voxelGrid, nil
+}
+
+// Name returns the algorithm name.
+func (v *PointCloudVoxelizer) Name() string {
+	return "point-cloud-voxelizer"
+}
+
+// pointSum accumulates the colors of the points binned into one voxel.
+type pointSum struct {
+	rgb [3]float64
+	n   int
+}
+
+// pointHoleDirections holds one offset of each pair of opposite neighbors in a
+// 3x3x3 block, face neighbors first.
+var pointHoleDirections = func() [][3]int {
+	var dirs [][3]int
+	for order := 1; order <= 3; order++ {
+		for dz := -1; dz <= 1; dz++ {
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					d := [3]int{dx, dy, dz}
+					if abs(dx)+abs(dy)+abs(dz) != order || dx+3*dy+9*dz < 0 {
+						continue
+					}
+					dirs = append(dirs, d)
+				}
+			}
+		}
+	}
+	return dirs
+}()
+
+// fillPointHoles runs one hole filling pass, filling every empty voxel lying
+// between two filled voxels on opposite sides of it, in their average color.
+// The first such pair in pointHoleDirections is used. It returns the voxels
+// filled.
+func fillPointHoles(vg *VoxelGrid) int {
+	type fill struct {
+		cell  [3]int
+		color [3]uint8
+	}
+	var fills []fill
+	seen := make(map[[3]int]bool)
+	vg.Range(func(x, y, z int, _ [3]uint8) bool {
+		for _, d := range pointHoleDirections {
+			for _, sign := range [2]int{1, -1} {
+				cell := [3]int{x + sign*d[0], y + sign*d[1], z + sign*d[2]}
+				if seen[cell] || !vg.inBounds(cell[0], cell[1], cell[2]) || vg.HasVoxel(cell[0], cell[1], cell[2]) {
+					continue
+				}
+				seen[cell] = true
+				if color, ok := pointHoleColor(vg, cell); ok {
+					fills = append(fills, fill{cell, color})
+				}
+			}
+		}
+		return true
+	})
+	for _, f := range fills {
+		vg.SetVoxel(f.cell[0], f.cell[1], f.cell[2], f.color)
+	}
+	return len(fills)
+}
+
+// pointHoleColor returns the average color of the first pair of filled voxels
+// on opposite sides of cell.
+func pointHoleColor(vg *VoxelGrid, cell [3]int) ([3]uint8, bool) {
+	for _, d := range pointHoleDirections {
+		a, okA := vg.ColorAt(cell[0]+d[0], cell[1]+d[1], cell[2]+d[2])
+		if !okA {
+			continue
+		}
+		b, okB := vg.ColorAt(cell[0]-d[0], cell[1]-d[1], cell[2]-d[2])
+		if !okB {
+			continue
+		}
+		var color [3]uint8
+		for c := range color {
+			color[c] = uint8((int(a[c]) + int(b[c]) + 1) / 2)
+		}
+		return color, true
+	}
+	return [3]uint8{}, false
+}
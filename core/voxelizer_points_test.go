@@ -0,0 +1,71 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+// pointLine returns a mesh of colored points along x from 0 to 8, leaving gaps
+// between the points and a lone point of noise at x 4.
+func pointLine() *Mesh {
+	mesh := &Mesh{HasVertexColors: true}
+	for _, x := range []float64{0, 0.2, 2, 2.2, 6, 6.2, 8} {
+		mesh.Vertices = append(mesh.Vertices, Vertex{Position: [3]float64{x, 0, 0}, Color: [3]float64{1, 0, 0}})
+	}
+	mesh.Vertices = append(mesh.Vertices, Vertex{Position: [3]float64{4, 1, 0}, Color: [3]float64{0, 0, 1}})
+	return mesh
+}
+
+func TestPointCloudVoxelizer(t *testing.T) {
+	vg, err := NewPointCloudVoxelizer().Voxelize(pointLine(), VoxelizationConfig{Resolution: 8})
+	if err != nil {
+		t.Fatalf("voxelize: %v", err)
+	}
+	if vg.SizeX != 8 || vg.SizeY != 1 || vg.SizeZ != 1 {
+		t.Fatalf("grid is %dx%dx%d, want 8x1x1", vg.SizeX, vg.SizeY, vg.SizeZ)
+	}
+	// The point at x 8 lies on the far face and goes in the last voxel
+	for _, x := range []int{0, 2, 4, 6, 7} {
+		if !vg.HasVoxel(x, 0, 0) {
+			t.Errorf("no voxel at x %d", x)
+		}
+	}
+	if c, _ := vg.ColorAt(0, 0, 0); c != [3]uint8{255, 0, 0} {
+		t.Errorf("color = %v, want red", c)
+	}
+	if vg.Count() != 5 {
+		t.Errorf("%d voxels, want 5", vg.Count())
+	}
+
+	dense, err := NewPointCloudVoxelizer().Voxelize(pointLine(), VoxelizationConfig{Resolution: 8, MinPoints: 2, HoleFill: 1})
+	if err != nil {
+		t.Fatalf("voxelize: %v", err)
+	}
+	// The noise and lone end point are dropped, and the hole between x 0 and 2
+	// filled; the gap to x 6 is too wide
+	for x := 0; x < 8; x++ {
+		want := x <= 2 || x == 6
+		if got := dense.HasVoxel(x, 0, 0); got != want {
+			t.Errorf("voxel at x %d: %v, want %v", x, got, want)
+		}
+	}
+
+	if _, err := NewPointCloudVoxelizer().Voxelize(&Mesh{}, VoxelizationConfig{Resolution: 8}); !errors.Is(err, ErrEmptyMesh) {
+		t.Errorf("expected ErrEmptyMesh, got %v", err)
+	}
+}
+
+func TestFillPointHoles(t *testing.T) {
+	vg := NewVoxelGrid(3, 3, 3)
+	vg.SetVoxel(0, 0, 0, [3]uint8{0, 0, 0})
+	vg.SetVoxel(2, 2, 2, [3]uint8{200, 100, 50})
+	if n := fillPointHoles(vg); n != 1 {
+		t.Fatalf("filled %d voxels, want the center only", n)
+	}
+	if c, _ := vg.ColorAt(1, 1, 1); c != [3]uint8{100, 50, 25} {
+		t.Errorf("center color = %v, want the average", c)
+	}
+	if len(pointHoleDirections) != 13 {
+		t.Errorf("%d directions, want 13", len(pointHoleDirections))
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import "math"
+
+// colorSum accumulates the color samples landing in one voxel.
+type colorSum struct {
+	rgb      [3]float64 // Weighted channel sums
+	weight   float64
+	material *VoxelMaterial // Of the last sampled face with one
+}
+
+// colorSums collects the color samples of every covered voxel when
+// VoxelizationConfig.Samples asks for supersampling, so each voxel ends up with
+// the average of all triangles covering it rather than the last one's color.
+type colorSums map[[3]int]colorSum
+
+// newColorSums returns an empty accumulator, or nil when samples keeps the
+// single center sample of the last triangle.
+func newColorSums(samples int) colorSums {
+	if samples <= 1 {
+		return nil
+	}
+	return make(colorSums)
+}
+
+// addSamples samples a triangle, in voxel coordinates, at n jittered points of
+// voxel (x, y, z). Points are projected onto the triangle's plane and only those
+// landing inside the triangle count, so a triangle grazing the voxel weighs less
+// than one crossing it. A triangle none of the points land on still counts with
+// the weight of one point, sampled at the voxel center.
+func (s colorSums) addSamples(x, y, z int, shading *faceShading, a, b, c [3]float64, n int) {
+	sum := s[[3]int{x, y, z}]
+	inside := 0
+	for i := 1; i <= n; i++ {
+		p := [3]float64{
+			float64(x) + halton(i, 2),
+			float64(y) + halton(i, 3),
+			float64(z) + halton(i, 5),
+		}
+		w, ok := planeBarycentric(p, a, b, c)
+		if !ok || w[0] < 0 || w[1] < 0 || w[2] < 0 {
+			continue
+		}
+		sum.add(shading.colorAt(w), 1)
+		inside++
+	}
+	center := [3]float64{float64(x) + 0.5, float64(y) + 0.5, float64(z) + 0.5}
+	if inside == 0 {
+		sum.add(shading.colorAt(barycentric(center, a, b, c)), 1)
+	}
+	if material := shading.materialAt(center, a, b, c); material != nil {
+		sum.material = material
+	}
+	s[[3]int{x, y, z}] = sum
+}
+
+// add adds a sample with the given weight.
+func (sum *colorSum) add(color [3]uint8, weight float64) {
+	for i := range color {
+		sum.rgb[i] += float64(color[i]) * weight
+	}
+	sum.weight += weight
+}
+
+// merge adds the samples of other, such as a worker's, to s.
+func (s colorSums) merge(other colorSums) {
+	for key, o := range other {
+		sum := s[key]
+		for i := range sum.rgb {
+			sum.rgb[i] += o.rgb[i]
+		}
+		sum.weight += o.weight
+		if o.material != nil {
+			sum.material = o.material
+		}
+		s[key] = sum
+	}
+}
+
+// resolve sets every sampled voxel of grid to its average color.
+func (s colorSums) resolve(grid *VoxelGrid) {
+	for key, sum := range s {
+		var rgb [3]uint8
+		for i := range rgb {
+			rgb[i] = uint8(math.Round(sum.rgb[i] / sum.weight))
+		}
+		grid.SetVoxel(key[0], key[1], key[2], rgb)
+		if sum.material != nil {
+			grid.setMaterial(rgb, *sum.material)
+		}
+	}
+}
+
+// halton returns the i-th element of the Halton sequence in the given prime
+// base, a deterministic jitter that spreads any number of samples evenly over
+// [0,1) and keeps the output reproducible.
+func halton(i, base int) float64 {
+	f, r := 1.0, 0.0
+	for ; i > 0; i /= base {
+		f /= float64(base)
+		r += f * float64(i%base)
+	}
+	return r
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+// tiledCube returns an n-unit cube mesh whose faces are tiled with unit quads,
+// colored red, leaving out the quad at (hole, hole) of the top face when hole
+// is not negative.
+func tiledCube(n, hole int) *Mesh {
+	mesh := &Mesh{Materials: []Material{{DiffuseColor: [3]float64{1, 0, 0}}}}
+	for axis := 0; axis < 3; axis++ {
+		for _, side := range []int{0, n} {
+			for u := 0; u < n; u++ {
+				for v := 0; v < n; v++ {
+					if axis == 1 && side == n && u == hole && v == hole {
+						continue
+					}
+					base := len(mesh.Vertices)
+					for _, corner := range [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+						var p [3]float64
+						p[axis] = float64(side)
+						p[(axis+1)%3] = float64(u + corner[0])
+						p[(axis+2)%3] = float64(v + corner[1])
+						mesh.Vertices = append(mesh.Vertices, Vertex{Position: p})
+					}
+					mesh.Faces = append(mesh.Faces,
+						Face{VertexIndices: []int{base, base + 1, base + 2}},
+						Face{VertexIndices: []int{base, base + 2, base + 3}})
+				}
+			}
+		}
+	}
+	return mesh
+}
+
+func TestSDFVoxelizer(t *testing.T) {
+	// A hole in the surface is sealed, and the cube comes out solid and red
+	vg, err := NewSDFVoxelizer().Voxelize(tiledCube(8, 3), VoxelizationConfig{Resolution: 8})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	if vg.Count() != 8*8*8 {
+		t.Errorf("%d voxels, want a solid %d", vg.Count(), 8*8*8)
+	}
+	if c, _ := vg.ColorAt(4, 4, 4); c != [3]uint8{255, 0, 0} {
+		t.Errorf("interior color = %v, want red", c)
+	}
+
+	// Offset outward, the cube grows by the iso level with rounded edges
+	const iso = 2
+	vg, err = NewSDFVoxelizer().Voxelize(tiledCube(8, -1), VoxelizationConfig{Resolution: 8, IsoLevel: iso})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	if vg.SizeX != 12 || vg.SizeY != 12 || vg.SizeZ != 12 {
+		t.Fatalf("offset grid %dx%dx%d, want 12x12x12", vg.SizeX, vg.SizeY, vg.SizeZ)
+	}
+	for x := 0; x < 12; x++ {
+		for y := 0; y < 12; y++ {
+			for z := 0; z < 12; z++ {
+				// Distance from the cell center to the cube [2, 10]^3
+				var d2 float64
+				for _, c := range []int{x, y, z} {
+					if out := math.Max(2-(float64(c)+0.5), float64(c)+0.5-10); out > 0 {
+						d2 += out * out
+					}
+				}
+				if want := d2 <= iso*iso; vg.HasVoxel(x, y, z) != want {
+					t.Errorf("offset cell (%d, %d, %d) filled = %v, want %v", x, y, z, !want, want)
+				}
+			}
+		}
+	}
+
+	// Hollowed, cells deeper than two voxels are removed
+	vg, err = NewSDFVoxelizer().Voxelize(tiledCube(8, -1), VoxelizationConfig{Resolution: 8, Hollow: 2})
+	if err != nil {
+		t.Fatalf("Voxelize failed: %v", err)
+	}
+	if got, want := vg.Count(), 8*8*8-4*4*4; got != want {
+		t.Errorf("hollowed: %d voxels, want %d", got, want)
+	}
+	if vg.HasVoxel(2, 4, 4) || !vg.HasVoxel(1, 4, 4) {
+		t.Error("hollowed: shell is not two voxels thick")
+	}
+
+	if v, err := NewVoxelizerByName("sdf"); err != nil || v.Name() != "sdf-voxelizer" {
+		t.Errorf("sdf voxelizer not registered: %v", err)
+	}
+}
+
+func TestClosestPointOnTriangle(t *testing.T) {
+	a, b, c := [3]float64{0, 0, 0}, [3]float64{4, 0, 0}, [3]float64{0, 4, 0}
+	tests := []struct {
+		p, want [3]float64
+	}{
+		{[3]float64{1, 1, 3}, [3]float64{1, 1, 0}},   // Above the face
+		{[3]float64{-1, -1, 0}, a},                   // Beyond a vertex
+		{[3]float64{6, -1, 0}, b},                    // Beyond b
+		{[3]float64{2, -3, 1}, [3]float64{2, 0, 0}},  // Beside edge ab
+		{[3]float64{3, 3, 0}, [3]float64{2, 2, 0}},   // Beyond the hypotenuse
+		{[3]float64{-2, 1, -1}, [3]float64{0, 1, 0}}, // Beside edge ca
+	}
+	for _, tt := range tests {
+		if got := closestPointOnTriangle(tt.p, a, b, c); got != tt.want {
+			t.Errorf("closest to %v = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
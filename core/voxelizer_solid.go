@@ -0,0 +1,223 @@
+package core
+
+import "context"
+
+// SolidVoxelizer voxelizes a mesh's surface like SurfaceVoxelizer, then fills the
+// cells it encloses so watertight meshes produce solid grids. It is equivalent to
+// SurfaceVoxelizer with VoxelizationConfig.Fill set.
+type SolidVoxelizer struct {
+	surface SurfaceVoxelizer
+}
+
+// NewSolidVoxelizer creates a new solid voxelizer.
+func NewSolidVoxelizer() *SolidVoxelizer {
+	return &SolidVoxelizer{}
+}
+
+// Voxelize converts a mesh to a filled voxel grid.
+func (v *SolidVoxelizer) Voxelize(mesh *Mesh, config VoxelizationConfig) (*VoxelGrid, error) {
+	return v.VoxelizeCtx(context.Background(), mesh, config)
+}
+
+// VoxelizeCtx is like Voxelize but stops early when ctx is done.
+func (v *SolidVoxelizer) VoxelizeCtx(ctx context.Context, mesh *Mesh, config VoxelizationConfig) (*VoxelGrid, error) {
+	config.Fill = true
+	return v.surface.VoxelizeCtx(ctx, mesh, config)
+}
+
+// Name returns the algorithm name.
+func (v *SolidVoxelizer) Name() string {
+	return "solid-voxelizer"
+}
+
+// fillInterior fills the empty cells of vg that cannot be reached from outside the
+// grid through other empty cells. Each takes the color of the nearest filled cell
+// before it along x, which is always part of the enclosing surface. A surface with
+// holes lets the outside leak in and stays hollow where it does.
+func fillInterior(ctx context.Context, vg *VoxelGrid) error {
+	sx, sy, sz := vg.SizeX, vg.SizeY, vg.SizeZ
+	outside, err := outsideCells(ctx, vg)
+	if err != nil {
+		return err
+	}
+	
+	// Everything else that is still empty is enclosed
+	for z := 0; z < sz; z++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for y := 0; y < sy; y++ {
+			var color [3]uint8
+			for x := 0; x < sx; x++ {
+				if c, ok := vg.ColorAt(x, y, z); ok {
+					color = c
+					continue
+				}
+				i := x + sx*(y+sy*z)
+				if outside[i/64]&(1<<(i%64)) == 0 {
+					vg.SetVoxel(x, y, z, color)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// outsideCells returns a bitset, indexed x + SizeX*(y + SizeY*z), of the empty
+// cells of vg connected to the grid boundary through other empty cells. A grid
+// with no cells has an empty bitset.
+func outsideCells(ctx context.Context, vg *VoxelGrid) ([]uint64, error) {
+	sx, sy, sz := vg.SizeX, vg.SizeY, vg.SizeZ
+	if sx <= 0 || sy <= 0 || sz <= 0 {
+		return nil, nil
+	}
+	outside := make([]uint64, (sx*sy*sz+63)/64)
+	var stack []int
+	
+	visit := func(x, y, z int) {
+		i := x + sx*(y+sy*z)
+		if outside[i/64]&(1<<(i%64)) != 0 || vg.HasVoxel(x, y, z) {
+			return
+		}
+		outside[i/64] |= 1 << (i % 64)
+		stack = append(stack, i)
+	}
+	
+	// Flood the empty space connected to the grid boundary
+	for z := 0; z < sz; z++ {
+		for y := 0; y < sy; y++ {
+			visit(0, y, z)
+			visit(sx-1, y, z)
+		}
+		for x := 0; x < sx; x++ {
+			visit(x, 0, z)
+			visit(x, sy-1, z)
+		}
+	}
+	for y := 0; y < sy; y++ {
+		for x := 0; x < sx; x++ {
+			visit(x, y, 0)
+			visit(x, y, sz-1)
+		}
+	}
+	for n := 0; len(stack) > 0; n++ {
+		if n%(ctxCheckInterval*64) == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		x, y, z := i%sx, i/sx%sy, i/(sx*sy)
+		if x > 0 {
+			visit(x-1, y, z)
+		}
+		if x < sx-1 {
+			visit(x+1, y, z)
+		}
+		if y > 0 {
+			visit(x, y-1, z)
+		}
+		if y < sy-1 {
+			visit(x, y+1, z)
+		}
+		if z > 0 {
+			visit(x, y, z-1)
+		}
+		if z < sz-1 {
+			visit(x, y, z+1)
+		}
+	}
+	
+	return outside, nil
+}
+
+// Hollow removes the filled voxels farther than thickness steps from the outside,
+// keeping a shell thickness voxels deep around every surface reachable from
+// outside the grid. Cavities sealed inside the model count as interior and lose
+// their lining. It returns the number of voxels removed; a thickness below 1
+// leaves the grid unchanged.
+func (vg *VoxelGrid) Hollow(thickness int) int {
+	removed, _ := vg.HollowCtx(context.Background(), thickness)
+	return removed
+}
+
+// HollowCtx is like Hollow but stops early when ctx is done, leaving the grid
+// unchanged unless removal has begun.
+func (vg *VoxelGrid) HollowCtx(ctx context.Context, thickness int) (int, error) {
+	if thickness < 1 || vg.Count() == 0 {
+		return 0, nil
+	}
+	sx, sy, sz := vg.SizeX, vg.SizeY, vg.SizeZ
+	outside, err := outsideCells(ctx, vg)
+	if err != nil {
+		return 0, err
+	}
+	
+	neighbors := func(x, y, z int, fn func(nx, ny, nz int)) {
+		fn(x-1, y, z)
+		fn(x+1, y, z)
+		fn(x, y-1, z)
+		fn(x, y+1, z)
+		fn(x, y, z-1)
+		fn(x, y, z+1)
+	}
+	
+	// The first layer touches outside cells or the grid boundary
+	shell := make([]uint64, len(outside))
+	var frontier []int
+	vg.Range(func(x, y, z int, _ [3]uint8) bool {
+		exposed := false
+		neighbors(x, y, z, func(nx, ny, nz int) {
+			if !vg.inBounds(nx, ny, nz) {
+				exposed = true
+			} else if i := nx + sx*(ny+sy*nz); outside[i/64]&(1<<(i%64)) != 0 {
+				exposed = true
+			}
+		})
+		if exposed {
+			i := x + sx*(y+sy*z)
+			shell[i/64] |= 1 << (i % 64)
+			frontier = append(frontier, i)
+		}
+		return true
+	})
+	
+	// Each further layer is the filled cells next to the previous one
+	for depth := 1; depth < thickness && len(frontier) > 0; depth++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		var next []int
+		for _, i := range frontier {
+			neighbors(i%sx, i/sx%sy, i/(sx*sy), func(nx, ny, nz int) {
+				if !vg.HasVoxel(nx, ny, nz) {
+					return
+				}
+				j := nx + sx*(ny+sy*nz)
+				if shell[j/64]&(1<<(j%64)) == 0 {
+					shell[j/64] |= 1 << (j % 64)
+					next = append(next, j)
+				}
+			})
+		}
+		frontier = next
+	}
+	
+	removed := 0
+	for z := 0; z < sz; z++ {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		for y := 0; y < sy; y++ {
+			for x := 0; x < sx; x++ {
+				i := x + sx*(y+sy*z)
+				if shell[i/64]&(1<<(i%64)) == 0 && vg.HasVoxel(x, y, z) {
+					vg.DeleteVoxel(x, y, z)
+					removed++
+				}
+			}
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// hollowBox returns an n^3 grid holding only the box's outer shell, red on the
+// x=0 face and blue elsewhere.
+func hollowBox(n int) *VoxelGrid {
+	vg := NewVoxelGrid(n, n, n)
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			for z := 0; z < n; z++ {
+				if x > 0 && x < n-1 && y > 0 && y < n-1 && z > 0 && z < n-1 {
+					continue
+				}
+				color := [3]uint8{0, 0, 255}
+				if x == 0 {
+					color = [3]uint8{255, 0, 0}
+				}
+				vg.SetVoxel(x, y, z, color)
+			}
+		}
+	}
+	return vg
+}
+
+func TestFillInterior(t *testing.T) {
+	vg := hollowBox(6)
+	if err := fillInterior(context.Background(), vg); err != nil {
+		t.Fatalf("fill: %v", err)
+	}
+	if vg.Count() != 6*6*6 {
+		t.Errorf("filled %d voxels, want %d", vg.Count(), 6*6*6)
+	}
+	if c, _ := vg.ColorAt(3, 3, 3); c != [3]uint8{255, 0, 0} {
+		t.Errorf("interior color = %v, want the x=0 face's red", c)
+	}
+}
+
+func TestFillInteriorLeaks(t *testing.T) {
+	vg := hollowBox(6)
+	vg.DeleteVoxel(3, 3, 5)
+	shell := vg.Count()
+	if err := fillInterior(context.Background(), vg); err != nil {
+		t.Fatalf("fill: %v", err)
+	}
+	if vg.Count() != shell {
+		t.Errorf("open shell grew from %d to %d voxels", shell, vg.Count())
+	}
+}
+
+func TestFillInteriorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := fillInterior(ctx, hollowBox(6)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+// flatPlane returns a unit square in the XZ plane, which has no height.
+func flatPlane() *Mesh {
+	mesh := &Mesh{
+		Vertices: []Vertex{
+			{Position: [3]float64{0, 0, 0}},
+			{Position: [3]float64{1, 0, 0}},
+			{Position: [3]float64{1, 0, 1}},
+			{Position: [3]float64{0, 0, 1}},
+		},
+		Faces: []Face{
+			{VertexIndices: []int{0, 1, 2}, MaterialIndex: -1},
+			{VertexIndices: []int{0, 2, 3}, MaterialIndex: -1},
+		},
+	}
+	mesh.CalculateBounds()
+	return mesh
+}
+
+func TestSolidVoxelizerFlatMesh(t *testing.T) {
+	vg, err := NewSolidVoxelizer().Voxelize(flatPlane(), VoxelizationConfig{Resolution: 8})
+	if err != nil {
+		t.Fatalf("voxelize: %v", err)
+	}
+	if vg.SizeX != 8 || vg.SizeY != 1 || vg.SizeZ != 8 || vg.Count() != 64 {
+		t.Errorf("flat plane gave a %dx%dx%d grid of %d voxels, want 8x1x8 of 64", vg.SizeX, vg.SizeY, vg.SizeZ, vg.Count())
+	}
+	if err := fillInterior(context.Background(), NewVoxelGrid(4, 0, 4)); err != nil {
+		t.Errorf("fill of an empty grid: %v", err)
+	}
+}
+
+// solidBox returns an n^3 grid with every cell filled.
+func solidBox(n int) *VoxelGrid {
+	vg := NewVoxelGrid(n, n, n)
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			for z := 0; z < n; z++ {
+				vg.SetVoxel(x, y, z, [3]uint8{0, 255, 0})
+			}
+		}
+	}
+	return vg
+}
+
+func TestHollow(t *testing.T) {
+	tests := []struct {
+		name      string
+		thickness int
+		kept      int
+	}{
+		{"Shell1", 1, 6*6*6 - 4*4*4},
+		{"Shell2", 2, 6*6*6 - 2*2*2},
+		{"ThickerThanModel", 4, 6 * 6 * 6},
+		{"Disabled", 0, 6 * 6 * 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vg := solidBox(6)
+			removed := vg.Hollow(tt.thickness)
+			if vg.Count() != tt.kept || removed != 6*6*6-tt.kept {
+				t.Errorf("kept %d voxels and removed %d, want %d kept", vg.Count(), removed, tt.kept)
+			}
+		})
+	}
+	
+	// A sealed cavity is not visible from outside, so its lining goes too
+	vg := solidBox(7)
+	vg.DeleteVoxel(3, 3, 3)
+	vg.Hollow(1)
+	if vg.Count() != 7*7*7-5*5*5 {
+		t.Errorf("kept %d voxels around a sealed cavity, want %d", vg.Count(), 7*7*7-5*5*5)
+	}
+	
+	// A tunnel to the outside exposes the voxels lining it
+	vg = solidBox(7)
+	for x := 0; x <= 3; x++ {
+		vg.DeleteVoxel(x, 3, 3)
+	}
+	vg.Hollow(1)
+	if !vg.HasVoxel(4, 3, 3) || !vg.HasVoxel(2, 4, 3) {
+		t.Error("voxels lining an open tunnel were removed")
+	}
+	if vg.HasVoxel(4, 4, 4) {
+		t.Error("interior voxel away from the tunnel was kept")
+	}
+}
+
+func TestHollowCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	vg := solidBox(6)
+	if _, err := vg.HollowCtx(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if vg.Count() != 6*6*6 {
+		t.Errorf("canceled hollowing changed the grid to %d voxels", vg.Count())
+	}
+}
@@ -0,0 +1,118 @@
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"syscall/js"
+	"time"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// yieldInterval is how long a conversion started by convert may hold the
+// JavaScript thread before handing it back to the event loop.
+const yieldInterval = 16 * time.Millisecond
+
+// convert runs a named conversion without blocking the caller
+// Args: conversion name (see capabilities().conversions), input data, options (object, optional)
+// Returns: Promise resolving to the output (Uint8Array, or base64 string with encoding "base64")
+// and rejecting with {code, stage, detail, message}; options.signal aborts it with ABORTED
+func convertAsync(this js.Value, args []js.Value) interface{} {
+	var executor js.Func
+	executor = js.FuncOf(func(this js.Value, promiseArgs []js.Value) interface{} {
+		executor.Release()
+		resolve, reject := promiseArgs[0], promiseArgs[1]
+		run, input, opts, err := parseAsyncArgs(args)
+		if err != nil {
+			reject.Invoke(errorObject(err))
+			return nil
+		}
+		go func() {
+			output, err := runAsync(run, input, opts)
+			if err != nil {
+				reject.Invoke(errorObject(err))
+				return
+			}
+			resolve.Invoke(opts.encodeOutput(output))
+		}()
+		return nil
+	})
+	return js.Global().Get("Promise").New(executor)
+}
+
+// parseAsyncArgs validates the arguments of convert.
+func parseAsyncArgs(args []js.Value) (conversionFunc, []byte, convertOptions, error) {
+	if len(args) < 2 || args[0].Type() != js.TypeString {
+		return nil, nil, convertOptions{}, newError(codeInvalidArgument, stageInput, "convert requires a conversion name, input data and an optional options object")
+	}
+	run, ok := conversions[args[0].String()]
+	if !ok {
+		return nil, nil, convertOptions{}, newError(codeInvalidArgument, stageInput, "unknown conversion %q", args[0].String())
+	}
+	input, err := extractBytes(args[1])
+	if err != nil {
+		return nil, nil, convertOptions{}, newError(codeInvalidArgument, stageInput, "failed to extract input data: %v", err)
+	}
+	opts, err := parseOptions(optionalArg(args, 2))
+	if err != nil {
+		return nil, nil, convertOptions{}, newError(codeInvalidArgument, stageOptions, "%v", err)
+	}
+	return run, input, opts, nil
+}
+
+// runAsync runs a conversion canceled by the options' abort signal. Progress
+// events double as yield points, so the signal, timers and rendering get to run
+// while the conversion is in progress.
+func runAsync(run conversionFunc, input []byte, opts convertOptions) ([]byte, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if !opts.Signal.IsUndefined() {
+		if opts.Signal.Get("aborted").Truthy() {
+			return nil, newError(codeAborted, "", "conversion aborted")
+		}
+		onAbort := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			cancel()
+			return nil
+		})
+		defer onAbort.Release()
+		opts.Signal.Call("addEventListener", "abort", onAbort)
+		defer opts.Signal.Call("removeEventListener", "abort", onAbort)
+	}
+	opts.Progress = &yieldingReporter{next: opts.Progress, last: time.Now()}
+
+	var output bytes.Buffer
+	if err := run(ctx, bytes.NewReader(input), &output, opts); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// yieldingReporter forwards progress events and, at most every yieldInterval,
+// waits for a zero-delay timer so the JavaScript event loop can run.
+type yieldingReporter struct {
+	next core.ProgressReporter
+	last time.Time
+}
+
+func (r *yieldingReporter) Report(event core.ProgressEvent) {
+	if r.next != nil {
+		r.next.Report(event)
+	}
+	if time.Since(r.last) < yieldInterval {
+		return
+	}
+
+	done := make(chan struct{})
+	var wake js.Func
+	wake = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		wake.Release()
+		close(done)
+		return nil
+	})
+	js.Global().Call("setTimeout", wake, 0)
+	<-done
+	r.last = time.Now()
+}
@@ -0,0 +1,123 @@
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"syscall/js"
+	"testing"
+	"time"
+)
+
+// await waits for a promise and returns its value and whether it resolved.
+func await(t *testing.T, promise js.Value) (js.Value, bool) {
+	t.Helper()
+	type settled struct {
+		value    js.Value
+		resolved bool
+	}
+	done := make(chan settled, 1)
+	onResolve := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done <- settled{args[0], true}
+		return nil
+	})
+	defer onResolve.Release()
+	onReject := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		done <- settled{args[0], false}
+		return nil
+	})
+	defer onReject.Release()
+	promise.Call("then", onResolve, onReject)
+
+	select {
+	case s := <-done:
+		return s.value, s.resolved
+	case <-time.After(10 * time.Second):
+		t.Fatal("promise did not settle")
+		return js.Undefined(), false
+	}
+}
+
+func TestConvertAsync(t *testing.T) {
+	var stages []string
+	onProgress := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if args[0].Get("type").String() == "start" {
+			stages = append(stages, args[0].Get("stage").String())
+		}
+		return nil
+	})
+	defer onProgress.Release()
+
+	promise := convertAsync(js.Undefined(), []js.Value{
+		js.ValueOf("meshToVox"),
+		toUint8Array(newTriangleGLB(t)),
+		js.ValueOf(map[string]interface{}{"resolution": 8, "onProgress": onProgress}),
+	}).(js.Value)
+	if !promise.InstanceOf(js.Global().Get("Promise")) {
+		t.Fatalf("convert returned %s, want a Promise", promise.Type())
+	}
+
+	value, ok := await(t, promise)
+	if !ok {
+		t.Fatalf("convert rejected: %v", value.Get("message"))
+	}
+	output := make([]byte, value.Length())
+	js.CopyBytesToGo(output, value)
+	if !bytes.HasPrefix(output, []byte("VOX ")) {
+		t.Errorf("Output is not a VOX file: % x", output[:min(len(output), 8)])
+	}
+	if len(stages) == 0 || stages[0] != "import" {
+		t.Errorf("Progress stages = %v, want import first", stages)
+	}
+}
+
+func TestConvertAsyncErrors(t *testing.T) {
+	controller := js.Global().Get("AbortController").New()
+	controller.Call("abort")
+
+	tests := []struct {
+		name string
+		args []interface{}
+		code string
+	}{
+		{"UnknownConversion", []interface{}{"meshToObj", toUint8Array([]byte{0})}, codeInvalidArgument},
+		{"MissingInput", []interface{}{"meshToVox"}, codeInvalidArgument},
+		{"BadSignal", []interface{}{"meshToVox", toUint8Array([]byte{0}), map[string]interface{}{"signal": true}}, codeInvalidArgument},
+		{"Aborted", []interface{}{"meshToVox", toUint8Array(newTriangleGLB(t)), map[string]interface{}{"signal": controller.Get("signal")}}, codeAborted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := make([]js.Value, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = js.ValueOf(arg)
+			}
+			value, ok := await(t, convertAsync(js.Undefined(), args).(js.Value))
+			if ok {
+				t.Fatal("Expected the promise to reject")
+			}
+			if code := value.Get("code").String(); code != tt.code {
+				t.Errorf("code = %s, want %s (%v)", code, tt.code, value.Get("message"))
+			}
+		})
+	}
+}
+
+func TestConvertAsyncAbortMidway(t *testing.T) {
+	controller := js.Global().Get("AbortController").New()
+	onProgress := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if args[0].Get("stage").String() == "voxelize" {
+			controller.Call("abort")
+		}
+		return nil
+	})
+	defer onProgress.Release()
+
+	value, ok := await(t, convertAsync(js.Undefined(), []js.Value{
+		js.ValueOf("meshToSchematic"),
+		toUint8Array(newTriangleGLB(t)),
+		js.ValueOf(map[string]interface{}{"resolution": 8, "onProgress": onProgress, "signal": controller.Get("signal")}),
+	}).(js.Value))
+	if ok || value.Get("code").String() != codeAborted {
+		t.Errorf("Expected ABORTED after aborting during voxelization, got %v", value)
+	}
+}
@@ -0,0 +1,55 @@
+// +build js,wasm
+
+package main
+
+import (
+	"sort"
+	"syscall/js"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// capabilities reports the formats and algorithms compiled into this build
+// Args: none
+// Returns: {inputFormats, outputFormats, voxelizers, matchers, ditherAlgorithms, minecraftVersions, schematicVersions, conversions, limits}
+func capabilities(this js.Value, args []js.Value) interface{} {
+	names := make([]string, 0, len(conversions))
+	for name := range conversions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return js.ValueOf(map[string]interface{}{
+		"version":           moduleVersion,
+		"inputFormats":      stringsToJS(core.ImporterExtensions()),
+		"outputFormats":     stringsToJS(core.ExporterExtensions()),
+		"voxelizers":        stringsToJS(core.VoxelizerNames()),
+		"matchers":          stringsToJS(core.MatcherNames()),
+		"ditherAlgorithms":  stringsToJS(core.DitherAlgorithms()),
+		"minecraftVersions": stringsToJS(core.MinecraftVersions()),
+		"schematicVersions": intsToJS(supportedSpongeVersions),
+		"conversions":       stringsToJS(names),
+		"limits": map[string]interface{}{
+			"defaultResolution": defaultOptions().Resolution,
+			"defaultMaxCells":   defaultMaxCells,
+		},
+	})
+}
+
+// intsToJS converts an int slice into a value accepted by js.ValueOf.
+func intsToJS(list []int) []interface{} {
+	result := make([]interface{}, len(list))
+	for i, n := range list {
+		result[i] = n
+	}
+	return result
+}
+
+// stringsToJS converts a string slice into a value accepted by js.ValueOf.
+func stringsToJS(list []string) []interface{} {
+	result := make([]interface{}, len(list))
+	for i, s := range list {
+		result[i] = s
+	}
+	return result
+}
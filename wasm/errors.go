@@ -0,0 +1,97 @@
+// +build js,wasm
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// Error codes returned to JavaScript in error.code.
+const (
+	codeInvalidArgument  = "INVALID_ARGUMENT"
+	codeInvalidInput     = "INVALID_INPUT"
+	codeGridTooLarge     = "GRID_TOO_LARGE"
+	codePaletteTooLarge  = "PALETTE_TOO_LARGE"
+	codeConversionFailed = "CONVERSION_FAILED"
+	codeAborted          = "ABORTED"
+	codeInternal         = "INTERNAL"
+)
+
+// Pipeline stages reported in error.stage.
+const (
+	stageOptions   = "options"
+	stageInput     = "input"
+	stageImport    = "import"
+	stageVoxelize  = "voxelize"
+	stageExport    = "export"
+	stageStreaming = "stream"
+)
+
+// bindingError is an error carrying the code and pipeline stage reported to JavaScript.
+type bindingError struct {
+	Code   string
+	Stage  string
+	Detail string
+}
+
+func (e *bindingError) Error() string {
+	if e.Stage == "" {
+		return e.Detail
+	}
+	return e.Stage + ": " + e.Detail
+}
+
+// newError creates a binding error with a formatted detail message.
+func newError(code, stage, format string, args ...interface{}) *bindingError {
+	return &bindingError{Code: code, Stage: stage, Detail: fmt.Sprintf(format, args...)}
+}
+
+// stageError classifies an error returned by a core pipeline stage.
+func stageError(stage string, err error) *bindingError {
+	var be *bindingError
+	if errors.As(err, &be) {
+		return be
+	}
+
+	var formatErr *core.FormatError
+	code := codeConversionFailed
+	switch {
+	case errors.Is(err, core.ErrGridTooLarge):
+		code = codeGridTooLarge
+	case errors.Is(err, core.ErrPaletteTooLarge):
+		code = codePaletteTooLarge
+	case errors.Is(err, core.ErrInvalidConfig):
+		code = codeInvalidArgument
+	case errors.Is(err, context.Canceled):
+		code = codeAborted
+	case stage == stageImport, errors.As(err, &formatErr),
+		errors.Is(err, core.ErrEmptyMesh), errors.Is(err, core.ErrUnsupportedFormat):
+		code = codeInvalidInput
+	}
+	return &bindingError{Code: code, Stage: stage, Detail: err.Error()}
+}
+
+// wrapError converts an error into the structured result object returned to JavaScript.
+func wrapError(err error) interface{} {
+	return js.ValueOf(map[string]interface{}{
+		"success": false,
+		"error":   errorObject(err),
+	})
+}
+
+// errorObject converts an error into the {code, stage, detail, message} object
+// reported to JavaScript.
+func errorObject(err error) map[string]interface{} {
+	be := stageError("", err)
+	return map[string]interface{}{
+		"code":    be.Code,
+		"stage":   be.Stage,
+		"detail":  be.Detail,
+		"message": be.Error(),
+	}
+}
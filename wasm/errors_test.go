@@ -0,0 +1,45 @@
+// +build js,wasm
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+func TestStageError(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage string
+		err   error
+		code  string
+	}{
+		{"GridTooLarge", stageVoxelize, fmt.Errorf("%w: 10x10x10", core.ErrGridTooLarge), codeGridTooLarge},
+		{"Canceled", stageExport, fmt.Errorf("match: %w", context.Canceled), codeAborted},
+		{"ImportFailure", stageImport, errors.New("failed to parse glTF"), codeInvalidInput},
+		{"EmptyMesh", stageVoxelize, fmt.Errorf("%w: zero size", core.ErrEmptyMesh), codeInvalidInput},
+		{"FormatError", stageExport, &core.FormatError{Format: "palette", Offset: -1}, codeInvalidInput},
+		{"PaletteTooLarge", stageExport, fmt.Errorf("%w: 300 colors", core.ErrPaletteTooLarge), codePaletteTooLarge},
+		{"InvalidConfig", stageOptions, fmt.Errorf("%w: bad", core.ErrInvalidConfig), codeInvalidArgument},
+		{"VoxelizeFailure", stageVoxelize, errors.New("voxelizer failed"), codeConversionFailed},
+		{"BindingError", stageExport, newError(codeInvalidArgument, stageOptions, "bad"), codeInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			be := stageError(tt.stage, tt.err)
+			if be.Code != tt.code {
+				t.Errorf("Code = %s, want %s", be.Code, tt.code)
+			}
+		})
+	}
+
+	// Binding errors keep the stage they were created with.
+	if be := stageError(stageExport, newError(codeInvalidArgument, stageOptions, "bad")); be.Stage != stageOptions {
+		t.Errorf("Stage = %s, want %s", be.Stage, stageOptions)
+	}
+}
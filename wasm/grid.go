@@ -0,0 +1,198 @@
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sort"
+	"syscall/js"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// meshToVoxelGrid voxelizes a mesh and returns the grid as typed arrays for editing
+// Args: meshData (Uint8Array, ArrayBuffer or base64), options (object, optional)
+// Returns: {size: [x, y, z], positions: Int32Array (x,y,z triples), colors: Uint8Array (r,g,b triples), scale, origin}
+func meshToVoxelGrid(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return wrapError(newError(codeInvalidArgument, stageInput, "meshToVoxelGrid requires meshData and an optional options object"))
+	}
+
+	meshData, err := extractBytes(args[0])
+	if err != nil {
+		return wrapError(newError(codeInvalidArgument, stageInput, "failed to extract mesh data: %v", err))
+	}
+
+	opts, err := parseOptions(optionalArg(args, 1))
+	if err != nil {
+		return wrapError(newError(codeInvalidArgument, stageOptions, "%v", err))
+	}
+
+	voxelGrid, err := voxelizeMesh(context.Background(), bytes.NewReader(meshData), opts)
+	if err != nil {
+		return wrapError(err)
+	}
+	return wrapSuccess(gridToJS(voxelGrid))
+}
+
+// voxelGridToVox exports an (edited) grid object to VOX
+// Args: grid (as returned by meshToVoxelGrid), options (object, optional)
+// Returns: voxData (Uint8Array, or base64 string with encoding "base64") or error
+func voxelGridToVox(this js.Value, args []js.Value) interface{} {
+	return exportGrid(args, func(vg *core.VoxelGrid, buf *bytes.Buffer, opts convertOptions) error {
+		return exportVox(context.Background(), vg, buf, opts)
+	})
+}
+
+// voxelGridToSchematic exports an (edited) grid object to a Minecraft schematic
+// Args: grid (as returned by meshToVoxelGrid), options (object, optional)
+// Returns: schematicData (Uint8Array, or base64 string with encoding "base64") or error
+func voxelGridToSchematic(this js.Value, args []js.Value) interface{} {
+	return exportGrid(args, func(vg *core.VoxelGrid, buf *bytes.Buffer, opts convertOptions) error {
+		return exportSchematic(context.Background(), vg, buf, opts)
+	})
+}
+
+// exportGrid parses the grid and options arguments and runs an exporter over them.
+func exportGrid(args []js.Value, export func(*core.VoxelGrid, *bytes.Buffer, convertOptions) error) interface{} {
+	if len(args) < 1 {
+		return wrapError(newError(codeInvalidArgument, stageInput, "a voxel grid object is required"))
+	}
+
+	opts, err := parseOptions(optionalArg(args, 1))
+	if err != nil {
+		return wrapError(newError(codeInvalidArgument, stageOptions, "%v", err))
+	}
+
+	voxelGrid, err := gridFromJS(args[0])
+	if err != nil {
+		return wrapError(err)
+	}
+	if err := checkGridCells(voxelGrid, opts); err != nil {
+		return wrapError(err)
+	}
+
+	var buf bytes.Buffer
+	if err := export(voxelGrid, &buf, opts); err != nil {
+		return wrapError(err)
+	}
+	return wrapSuccess(opts.encodeOutput(buf.Bytes()))
+}
+
+// checkGridCells rejects grids whose bounding box exceeds options.maxCells, since
+// the schematic exporter allocates one entry per cell.
+func checkGridCells(vg *core.VoxelGrid, opts convertOptions) error {
+	if cells := int64(vg.SizeX) * int64(vg.SizeY) * int64(vg.SizeZ); opts.MaxCells > 0 && cells > int64(opts.MaxCells) {
+		return newError(codeGridTooLarge, stageInput, "%dx%dx%d grid exceeds %d cells",
+			vg.SizeX, vg.SizeY, vg.SizeZ, opts.MaxCells)
+	}
+	return nil
+}
+
+// gridToJS converts a voxel grid into typed arrays, ordered by z, y, x for stable output.
+func gridToJS(vg *core.VoxelGrid) map[string]interface{} {
+	type cell struct {
+		pos   [3]int
+		color [3]uint8
+	}
+	cells := make([]cell, 0, vg.Count())
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		cells = append(cells, cell{[3]int{x, y, z}, color})
+		return true
+	})
+	sort.Slice(cells, func(i, j int) bool {
+		a, b := cells[i].pos, cells[j].pos
+		if a[2] != b[2] {
+			return a[2] < b[2]
+		}
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		return a[0] < b[0]
+	})
+
+	positionBytes := make([]byte, len(cells)*12)
+	colors := make([]byte, len(cells)*3)
+	for i, c := range cells {
+		for axis := 0; axis < 3; axis++ {
+			binary.LittleEndian.PutUint32(positionBytes[i*12+axis*4:], uint32(int32(c.pos[axis])))
+		}
+		copy(colors[i*3:], c.color[:])
+	}
+
+	return map[string]interface{}{
+		"size":      []interface{}{vg.SizeX, vg.SizeY, vg.SizeZ},
+		"positions": js.Global().Get("Int32Array").New(bytesToJS(positionBytes).Get("buffer")),
+		"colors":    bytesToJS(colors),
+		"scale":     vg.Scale,
+		"origin":    []interface{}{vg.Origin[0], vg.Origin[1], vg.Origin[2]},
+	}
+}
+
+// gridFromJS validates and converts a grid object back into a voxel grid.
+func gridFromJS(val js.Value) (*core.VoxelGrid, error) {
+	if val.Type() != js.TypeObject {
+		return nil, newError(codeInvalidArgument, stageInput, "grid must be an object, got %s", val.Type())
+	}
+
+	size := val.Get("size")
+	if !size.InstanceOf(js.Global().Get("Array")) || size.Length() != 3 {
+		return nil, newError(codeInvalidArgument, stageInput, "grid.size must be an array of 3 integers")
+	}
+	var dims [3]int
+	for i := range dims {
+		if size.Index(i).Type() != js.TypeNumber || size.Index(i).Int() <= 0 {
+			return nil, newError(codeInvalidArgument, stageInput, "grid.size[%d] must be a positive integer", i)
+		}
+		dims[i] = size.Index(i).Int()
+	}
+
+	positions := val.Get("positions")
+	if !positions.InstanceOf(js.Global().Get("Int32Array")) {
+		return nil, newError(codeInvalidArgument, stageInput, "grid.positions must be an Int32Array")
+	}
+	colors := val.Get("colors")
+	if !colors.InstanceOf(js.Global().Get("Uint8Array")) && !colors.InstanceOf(js.Global().Get("Uint8ClampedArray")) {
+		return nil, newError(codeInvalidArgument, stageInput, "grid.colors must be a Uint8Array")
+	}
+
+	count := positions.Length() / 3
+	if positions.Length()%3 != 0 || colors.Length() != count*3 {
+		return nil, newError(codeInvalidArgument, stageInput,
+			"grid.positions and grid.colors must hold the same number of triples (%d positions, %d colors)",
+			positions.Length(), colors.Length())
+	}
+
+	positionBytes := make([]byte, positions.Get("byteLength").Int())
+	js.CopyBytesToGo(positionBytes, js.Global().Get("Uint8Array").New(
+		positions.Get("buffer"), positions.Get("byteOffset"), positions.Get("byteLength")))
+	colorBytes := make([]byte, colors.Length())
+	js.CopyBytesToGo(colorBytes, js.Global().Get("Uint8Array").New(
+		colors.Get("buffer"), colors.Get("byteOffset"), colors.Get("byteLength")))
+
+	// Auto storage only picks a dense array when the voxels would cost more in a
+	// map, so a huge declared size with few voxels cannot force a large allocation.
+	vg := core.NewVoxelGridFor(dims[0], dims[1], dims[2], int64(count), core.StorageConfig{})
+	if scale := val.Get("scale"); scale.Type() == js.TypeNumber {
+		vg.Scale = scale.Float()
+	}
+	if origin := val.Get("origin"); origin.InstanceOf(js.Global().Get("Array")) && origin.Length() == 3 {
+		for i := range vg.Origin {
+			vg.Origin[i] = origin.Index(i).Float()
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		x := int(int32(binary.LittleEndian.Uint32(positionBytes[i*12:])))
+		y := int(int32(binary.LittleEndian.Uint32(positionBytes[i*12+4:])))
+		z := int(int32(binary.LittleEndian.Uint32(positionBytes[i*12+8:])))
+		if x < 0 || x >= dims[0] || y < 0 || y >= dims[1] || z < 0 || z >= dims[2] {
+			return nil, newError(codeInvalidArgument, stageInput, "voxel %d at (%d, %d, %d) is outside the grid", i, x, y, z)
+		}
+		vg.SetVoxel(x, y, z, [3]uint8{colorBytes[i*3], colorBytes[i*3+1], colorBytes[i*3+2]})
+	}
+
+	return vg, nil
+}
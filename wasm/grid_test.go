@@ -0,0 +1,67 @@
+// +build js,wasm
+
+package main
+
+import (
+	"errors"
+	"syscall/js"
+	"testing"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+func TestGridRoundTrip(t *testing.T) {
+	vg := core.NewVoxelGrid(4, 3, 2)
+	vg.Scale = 2.5
+	vg.Origin = [3]float64{1, -1, 0.5}
+	vg.SetVoxel(0, 0, 0, [3]uint8{255, 0, 0})
+	vg.SetVoxel(3, 2, 1, [3]uint8{0, 255, 0})
+	vg.SetVoxel(1, 2, 0, [3]uint8{0, 0, 255})
+
+	got, err := gridFromJS(js.ValueOf(gridToJS(vg)))
+	if err != nil {
+		t.Fatalf("gridFromJS failed: %v", err)
+	}
+
+	if got.SizeX != vg.SizeX || got.SizeY != vg.SizeY || got.SizeZ != vg.SizeZ {
+		t.Errorf("Size = %dx%dx%d, want %dx%dx%d", got.SizeX, got.SizeY, got.SizeZ, vg.SizeX, vg.SizeY, vg.SizeZ)
+	}
+	if got.Scale != vg.Scale || got.Origin != vg.Origin {
+		t.Errorf("Scale/origin = %v/%v, want %v/%v", got.Scale, got.Origin, vg.Scale, vg.Origin)
+	}
+	if got.Count() != vg.Count() {
+		t.Fatalf("Count = %d, want %d", got.Count(), vg.Count())
+	}
+	vg.Range(func(x, y, z int, color [3]uint8) bool {
+		if other, ok := got.ColorAt(x, y, z); !ok || other != color {
+			t.Errorf("Voxel at (%d, %d, %d) = %v, want %v", x, y, z, other, color)
+		}
+		return true
+	})
+}
+
+func TestGridFromJSOutOfBounds(t *testing.T) {
+	vg := core.NewVoxelGrid(2, 2, 2)
+	vg.SetVoxel(1, 1, 1, [3]uint8{10, 20, 30})
+	grid := js.ValueOf(gridToJS(vg))
+
+	grid.Get("positions").SetIndex(0, 2)
+
+	_, err := gridFromJS(grid)
+	var be *bindingError
+	if !errors.As(err, &be) || be.Code != codeInvalidArgument {
+		t.Fatalf("Expected INVALID_ARGUMENT, got %v", err)
+	}
+}
+
+func TestGridFromJSMismatchedArrays(t *testing.T) {
+	grid := js.ValueOf(map[string]interface{}{
+		"size":      []interface{}{2, 2, 2},
+		"positions": js.Global().Get("Int32Array").New(3),
+		"colors":    js.Global().Get("Uint8Array").New(6),
+	})
+
+	if _, err := gridFromJS(grid); err == nil {
+		t.Fatal("Expected error for mismatched positions and colors")
+	}
+}
@@ -0,0 +1,129 @@
+// Type definitions for the poly2block JavaScript wrapper.
+
+export interface DitheringOptions {
+    enabled?: boolean;
+    algorithm?: string;
+}
+
+export interface BlockFilters {
+    include?: string[];
+    exclude?: string[];
+    survivalOnly?: boolean;
+    opaqueOnly?: boolean;
+}
+
+export interface ConvertOptions {
+    resolution?: number;
+    maxCells?: number;
+    voxelizer?: string;
+    conservative?: boolean;
+    dithering?: boolean | DitheringOptions;
+    palette?: BinaryInput;
+    filters?: BlockFilters;
+    /** Minecraft version of the default palette and recorded data version, such as "1.16" or "latest". */
+    mcVersion?: string;
+    /** @deprecated Only "1.13+"; use mcVersion. */
+    version?: string;
+    /** Sponge schematic format version, 2 or 3. */
+    schematicVersion?: number;
+    /** Called synchronously for each stage event (direct WASM use only; the wrapper supplies its own). */
+    onProgress?: (event: ProgressEvent) => void;
+    /** Aborts poly2block.convert (direct WASM use only; pass CallOptions.signal to the wrapper). */
+    signal?: AbortSignal;
+}
+
+export interface ProgressEvent {
+    stage: string;
+    type: 'start' | 'progress' | 'end';
+    current: number;
+    total: number;
+    /** -1 when the total is unknown. */
+    percent: number;
+}
+
+export interface VoxelGrid {
+    size: [number, number, number];
+    positions: Int32Array;
+    colors: Uint8Array;
+    scale?: number;
+    origin?: [number, number, number];
+}
+
+export interface Capabilities {
+    version: string;
+    inputFormats: string[];
+    outputFormats: string[];
+    voxelizers: string[];
+    ditherAlgorithms: string[];
+    minecraftVersions: string[];
+    schematicVersions: number[];
+    conversions: string[];
+    limits: { defaultResolution: number; defaultMaxCells: number };
+}
+
+export interface Progress {
+    stage: string;
+    percent: number;
+}
+
+export interface CallOptions {
+    onProgress?: (progress: Progress) => void;
+    signal?: AbortSignal;
+}
+
+export interface ConverterOptions {
+    wasmUrl: string | URL;
+    wasmExecUrl: string | URL;
+    workerUrl?: string | URL;
+}
+
+export type ErrorCode =
+    | 'INVALID_ARGUMENT'
+    | 'INVALID_INPUT'
+    | 'GRID_TOO_LARGE'
+    | 'PALETTE_TOO_LARGE'
+    | 'CONVERSION_FAILED'
+    | 'INTERNAL'
+    | 'ABORTED';
+
+export class Poly2BlockError extends Error {
+    code: ErrorCode;
+    stage: string;
+    detail: string;
+}
+
+export type BinaryInput = Uint8Array | ArrayBuffer | ArrayBufferView | string;
+
+export type StreamConversion = 'meshToVox' | 'meshToSchematic' | 'voxToSchematic' | 'schematicToVox';
+
+export interface StreamChunk {
+    data: Uint8Array;
+    /** True once the output is exhausted. */
+    done: boolean;
+}
+
+export class ConversionStream {
+    write(chunk: BinaryInput): Promise<number>;
+    finish(): Promise<boolean>;
+    /** Must be called after finish(); rejects with INVALID_ARGUMENT otherwise. */
+    read(maxBytes?: number): Promise<StreamChunk>;
+    close(): Promise<void>;
+}
+
+export class Converter {
+    constructor(options: ConverterOptions);
+    init(): Promise<Capabilities>;
+    capabilities(): Promise<Capabilities>;
+    meshToVox(meshData: BinaryInput, options?: ConvertOptions, callOptions?: CallOptions): Promise<Uint8Array>;
+    meshToSchematic(meshData: BinaryInput, options?: ConvertOptions, callOptions?: CallOptions): Promise<Uint8Array>;
+    voxToSchematic(voxData: BinaryInput, options?: ConvertOptions, callOptions?: CallOptions): Promise<Uint8Array>;
+    schematicToVox(schematicData: BinaryInput, options?: ConvertOptions, callOptions?: CallOptions): Promise<Uint8Array>;
+    meshToVoxelGrid(meshData: BinaryInput, options?: ConvertOptions, callOptions?: CallOptions): Promise<VoxelGrid>;
+    voxelGridToVox(grid: VoxelGrid, options?: ConvertOptions, callOptions?: CallOptions): Promise<Uint8Array>;
+    voxelGridToSchematic(grid: VoxelGrid, options?: ConvertOptions, callOptions?: CallOptions): Promise<Uint8Array>;
+    generatePalette(callOptions?: CallOptions): Promise<Uint8Array>;
+    createStream(conversion: StreamConversion, options?: ConvertOptions, callOptions?: CallOptions): Promise<ConversionStream>;
+    terminate(): void;
+}
+
+export function createConverter(options: ConverterOptions): Promise<Converter>;
@@ -0,0 +1,314 @@
+// poly2block JavaScript wrapper
+//
+// Runs the poly2block WASM module inside a Web Worker and exposes a
+// promise-based API with progress callbacks and AbortSignal cancellation.
+
+const defaultWorkerUrl = new URL('./worker.js', import.meta.url);
+
+/**
+ * Error thrown when a conversion fails. Mirrors the structured error objects
+ * returned by the WASM bindings.
+ */
+export class Poly2BlockError extends Error {
+    constructor({ code, stage, detail, message }) {
+        super(message || detail || code);
+        this.name = 'Poly2BlockError';
+        this.code = code;
+        this.stage = stage;
+        this.detail = detail;
+    }
+}
+
+/**
+ * Converter owns one worker and queues calls to it. Go code cannot be
+ * interrupted mid-call, so aborting a call replaces the worker and resends the
+ * other queued calls to the new one. Open streams live in the worker and do not
+ * survive the restart.
+ */
+export class Converter {
+    constructor({ wasmUrl, wasmExecUrl, workerUrl = defaultWorkerUrl } = {}) {
+        if (!wasmUrl || !wasmExecUrl) {
+            throw new TypeError('wasmUrl and wasmExecUrl are required');
+        }
+        this.wasmUrl = String(wasmUrl);
+        this.wasmExecUrl = String(wasmExecUrl);
+        this.workerUrl = workerUrl;
+        this.worker = null;
+        this.ready = null;
+        this.pending = new Map();
+        this.listeners = new Map();
+        this.nextId = 1;
+        this.generation = 0;
+        this.info = null;
+    }
+
+    /** Starts the worker and loads the WASM module. Resolves to capabilities(). */
+    init() {
+        if (!this.ready) {
+            this._spawn();
+            this.ready = this._post('init', [], {}).then((info) => {
+                this.info = info;
+                return info;
+            });
+        }
+        return this.ready;
+    }
+
+    /** Returns the formats and algorithms supported by the loaded module. */
+    async capabilities() {
+        return this.init();
+    }
+
+    /** Converts glTF/GLB data to a VOX file. Resolves to a Uint8Array. */
+    meshToVox(meshData, options = {}, callOptions = {}) {
+        return this._call('meshToVox', [toBytes(meshData), options], callOptions);
+    }
+
+    /** Converts glTF/GLB data to a Minecraft schematic. Resolves to a Uint8Array. */
+    meshToSchematic(meshData, options = {}, callOptions = {}) {
+        return this._call('meshToSchematic', [toBytes(meshData), options], callOptions);
+    }
+
+    /** Converts a MagicaVoxel file to a Minecraft schematic. Resolves to a Uint8Array. */
+    voxToSchematic(voxData, options = {}, callOptions = {}) {
+        return this._call('voxToSchematic', [toBytes(voxData), options], callOptions);
+    }
+
+    /** Converts a Sponge schematic to a VOX file. Resolves to a Uint8Array. */
+    schematicToVox(schematicData, options = {}, callOptions = {}) {
+        return this._call('schematicToVox', [toBytes(schematicData), options], callOptions);
+    }
+
+    /** Voxelizes glTF/GLB data and resolves to an editable grid object. */
+    meshToVoxelGrid(meshData, options = {}, callOptions = {}) {
+        return this._call('meshToVoxelGrid', [toBytes(meshData), options], callOptions);
+    }
+
+    /** Exports a grid object to a VOX file. Resolves to a Uint8Array. */
+    voxelGridToVox(grid, options = {}, callOptions = {}) {
+        return this._call('voxelGridToVox', [grid, options], callOptions);
+    }
+
+    /** Exports a grid object to a Minecraft schematic. Resolves to a Uint8Array. */
+    voxelGridToSchematic(grid, options = {}, callOptions = {}) {
+        return this._call('voxelGridToSchematic', [grid, options], callOptions);
+    }
+
+    /** Generates the vanilla block palette. Resolves to a Uint8Array (msgpack). */
+    generatePalette(callOptions = {}) {
+        return this._call('generatePalette', [], callOptions);
+    }
+
+    /**
+     * Starts a streaming conversion (any name in capabilities().conversions)
+     * in the worker. Resolves to a ConversionStream; see the README for the protocol.
+     */
+    async createStream(conversion, options = {}, { onProgress, signal } = {}) {
+        if (signal && signal.aborted) {
+            throw abortError();
+        }
+        await this.init();
+        const generation = this.generation;
+        const { streamId, listenerId } = await this._post('createStream', [conversion, options], { onProgress, signal, keepProgress: true });
+        return new ConversionStream(this, streamId, listenerId, generation, signal);
+    }
+
+    /** Stops the worker and rejects all pending calls. */
+    terminate() {
+        if (this.worker) {
+            this.worker.terminate();
+        }
+        this._failAll('converter terminated', 'ABORTED');
+        this.listeners.clear();
+        this.worker = null;
+        this.ready = null;
+        this.generation++;
+    }
+
+    _spawn() {
+        this.worker = new Worker(this.workerUrl);
+        this.worker.onmessage = (event) => this._onMessage(event.data);
+        this.worker.onerror = (event) => this._failAll(event.message || 'worker error');
+    }
+
+    // _restart replaces a worker that is stuck in an aborted call and resends
+    // the calls still waiting for an answer, keeping their ids.
+    _restart() {
+        if (!this.worker) {
+            return;
+        }
+        this.worker.terminate();
+        this.listeners.clear();
+        this.generation++;
+        this._spawn();
+
+        const queued = [...this.pending.entries()];
+        if (!queued.some(([, entry]) => entry.method === 'init')) {
+            // The new worker still needs to load the module; nobody waits on this reply.
+            this._send(this.nextId++, { method: 'init', args: [] });
+        }
+        for (const [id, entry] of queued) {
+            if (isStreamMethod(entry.method)) {
+                this.pending.delete(id);
+                entry.reject(abortError());
+            } else {
+                this._send(id, entry);
+            }
+        }
+    }
+
+    async _call(method, args, { onProgress, signal } = {}) {
+        if (signal && signal.aborted) {
+            throw abortError();
+        }
+        await this.init();
+        return this._post(method, args, { onProgress, signal });
+    }
+
+    _post(method, args, { onProgress, signal, keepProgress = false }) {
+        const id = this.nextId++;
+        return new Promise((resolve, reject) => {
+            const entry = { method, args, resolve, reject, onProgress, keepProgress };
+            if (signal) {
+                entry.onAbort = () => {
+                    // Only this call is rejected; the others move to the replacement worker.
+                    this.pending.delete(id);
+                    reject(abortError());
+                    this._restart();
+                };
+                signal.addEventListener('abort', entry.onAbort, { once: true });
+                entry.signal = signal;
+            }
+            this.pending.set(id, entry);
+            this._send(id, entry);
+        });
+    }
+
+    _send(id, { method, args }) {
+        // Inputs are copied, not transferred, so callers keep ownership of their buffers
+        // and calls can be resent after a restart.
+        this.worker.postMessage({
+            id,
+            method,
+            args,
+            wasmUrl: this.wasmUrl,
+            wasmExecUrl: this.wasmExecUrl,
+        });
+    }
+
+    _onMessage({ id, type, value, error, stage, percent }) {
+        if (type === 'progress') {
+            const entry = this.pending.get(id);
+            const onProgress = entry ? entry.onProgress : this.listeners.get(id);
+            if (onProgress) {
+                onProgress({ stage, percent });
+            }
+            return;
+        }
+        const entry = this.pending.get(id);
+        if (!entry) {
+            return;
+        }
+        this.pending.delete(id);
+        if (entry.signal) {
+            entry.signal.removeEventListener('abort', entry.onAbort);
+        }
+        if (type === 'error') {
+            entry.reject(new Poly2BlockError(error));
+            return;
+        }
+        if (entry.keepProgress && entry.onProgress) {
+            // Streams report progress long after the call that created them resolved.
+            this.listeners.set(id, entry.onProgress);
+        }
+        entry.resolve(entry.keepProgress ? { streamId: value, listenerId: id } : value);
+    }
+
+    _failAll(message, code = 'INTERNAL') {
+        for (const entry of this.pending.values()) {
+            entry.reject(new Poly2BlockError({ code, stage: '', detail: message, message }));
+        }
+        this.pending.clear();
+    }
+}
+
+/**
+ * ConversionStream is the wrapper-side handle of a worker stream. Call write()
+ * for each input chunk, then finish(), then read() until done, then close().
+ * Operations fail with ABORTED once the worker has been restarted.
+ */
+export class ConversionStream {
+    constructor(converter, streamId, listenerId, generation, signal) {
+        this.converter = converter;
+        this.streamId = streamId;
+        this.listenerId = listenerId;
+        this.generation = generation;
+        this.signal = signal;
+        if (signal) {
+            this.onAbort = () => this.close().catch(() => {});
+            signal.addEventListener('abort', this.onAbort, { once: true });
+        }
+    }
+
+    /** Feeds the next input chunk. */
+    write(chunk) {
+        return this._call('streamWrite', [toBytes(chunk)]);
+    }
+
+    /** Signals the end of the input. */
+    finish() {
+        return this._call('streamFinish', []);
+    }
+
+    /** Resolves to { data: Uint8Array, done } with at most maxBytes of output. */
+    read(maxBytes) {
+        return this._call('streamRead', [maxBytes]);
+    }
+
+    /** Cancels the conversion if still running and releases the stream. */
+    async close() {
+        if (this.signal) {
+            this.signal.removeEventListener('abort', this.onAbort);
+        }
+        this.converter.listeners.delete(this.listenerId);
+        if (this.generation !== this.converter.generation) {
+            return;
+        }
+        await this.converter._post('streamClose', [this.streamId], {});
+    }
+
+    async _call(method, args) {
+        if (this.generation !== this.converter.generation) {
+            throw abortError();
+        }
+        return this.converter._post(method, [this.streamId, ...args], { signal: this.signal });
+    }
+}
+
+/** Creates a converter and waits for the WASM module to load. */
+export async function createConverter(options) {
+    const converter = new Converter(options);
+    await converter.init();
+    return converter;
+}
+
+function abortError() {
+    return new Poly2BlockError({ code: 'ABORTED', stage: '', detail: 'conversion aborted', message: 'conversion aborted' });
+}
+
+function isStreamMethod(method) {
+    return method === 'createStream' || method.startsWith('stream');
+}
+
+function toBytes(data) {
+    if (data instanceof Uint8Array || typeof data === 'string') {
+        return data;
+    }
+    if (data instanceof ArrayBuffer) {
+        return new Uint8Array(data);
+    }
+    if (ArrayBuffer.isView(data)) {
+        return new Uint8Array(data.buffer, data.byteOffset, data.byteLength);
+    }
+    throw new TypeError('expected Uint8Array, ArrayBuffer or base64 string');
+}
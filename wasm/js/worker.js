@@ -0,0 +1,147 @@
+// poly2block Web Worker
+//
+// Loads wasm_exec.js and the poly2block WASM module, then runs conversion
+// requests posted by the main-thread wrapper (index.js) so the page stays
+// responsive while Go code is running.
+
+/* eslint-env worker */
+/* global Go, poly2block */
+
+let ready = null;
+
+// Open streams created by createStream, keyed by the id handed to the wrapper.
+const streams = new Map();
+let nextStreamId = 1;
+
+function load(wasmUrl, wasmExecUrl) {
+    if (!ready) {
+        ready = (async () => {
+            importScripts(wasmExecUrl);
+            const go = new Go();
+            const response = fetch(wasmUrl);
+            let result;
+            if (WebAssembly.instantiateStreaming) {
+                result = await WebAssembly.instantiateStreaming(response, go.importObject);
+            } else {
+                const bytes = await (await response).arrayBuffer();
+                result = await WebAssembly.instantiate(bytes, go.importObject);
+            }
+            go.run(result.instance);
+            return poly2block.capabilities();
+        })();
+    }
+    return ready;
+}
+
+// Returns a binding result's data; outputs are Uint8Arrays, whose buffers are
+// transferred to the main thread without copying.
+function unwrap(result) {
+    if (!result.success) {
+        throw result.error;
+    }
+    return result.data;
+}
+
+function transferables(value) {
+    if (value instanceof Uint8Array) {
+        return [value.buffer];
+    }
+    if (value && value.data instanceof Uint8Array) {
+        return [value.data.buffer];
+    }
+    if (value && value.positions && value.colors) {
+        return [value.positions.buffer, value.colors.buffer];
+    }
+    return [];
+}
+
+function progress(id, stage, percent) {
+    postMessage({ id, type: 'progress', stage, percent });
+}
+
+// The wrapper always resolves to bytes, so base64 output is never requested.
+function withProgress(id, options) {
+    return Object.assign({}, options, {
+        encoding: 'bytes',
+        onProgress: (e) => progress(id, e.stage, e.percent),
+    });
+}
+
+function check(result) {
+    if (!result.success) {
+        throw result.error;
+    }
+    return result;
+}
+
+function getStream(streamId) {
+    const stream = streams.get(streamId);
+    if (!stream) {
+        throw { code: 'INVALID_ARGUMENT', stage: 'stream', detail: 'unknown stream ' + streamId, message: 'unknown stream ' + streamId };
+    }
+    return stream;
+}
+
+// Runs createStream and the per-stream operations forwarded by ConversionStream.
+// Progress for a stream is reported under the id of the createStream call.
+function runStream(id, method, args) {
+    switch (method) {
+    case 'createStream': {
+        const stream = check(poly2block.createStream(args[0], withProgress(id, args[1]))).data;
+        const streamId = nextStreamId++;
+        streams.set(streamId, stream);
+        return streamId;
+    }
+    case 'streamWrite':
+        return check(getStream(args[0]).write(args[1])).data;
+    case 'streamFinish':
+        return check(getStream(args[0]).finish()).data;
+    case 'streamRead': {
+        const result = check(getStream(args[0]).read(args[1]));
+        return { data: result.data, done: result.done };
+    }
+    case 'streamClose':
+        getStream(args[0]).close();
+        streams.delete(args[0]);
+        return true;
+    }
+    throw { code: 'INVALID_ARGUMENT', stage: 'input', detail: 'unknown method ' + method, message: 'unknown method ' + method };
+}
+
+self.onmessage = async (event) => {
+    const { id, method, args, wasmUrl, wasmExecUrl } = event.data;
+    try {
+        if (method === 'init') {
+            const capabilities = await load(wasmUrl, wasmExecUrl);
+            postMessage({ id, type: 'result', value: capabilities });
+            return;
+        }
+
+        await ready;
+        if (method === 'createStream' || method.startsWith('stream')) {
+            const value = runStream(id, method, args);
+            postMessage({ id, type: 'result', value }, transferables(value));
+            return;
+        }
+
+        const fn = poly2block[method];
+        if (typeof fn !== 'function') {
+            throw { code: 'INVALID_ARGUMENT', stage: 'input', detail: 'unknown method ' + method, message: 'unknown method ' + method };
+        }
+
+        // Options objects sit at index 1; inject a callback that forwards stage progress.
+        if (method !== 'generatePalette') {
+            args[1] = withProgress(id, args[1]);
+        }
+        const value = unwrap(fn(...args));
+        postMessage({ id, type: 'result', value }, transferables(value));
+    } catch (error) {
+        const structured = error && error.code ? error : {
+            code: 'INTERNAL',
+            stage: '',
+            detail: String(error && error.message || error),
+            message: String(error && error.message || error),
+        };
+        postMessage({ id, type: 'error', error: structured });
+    }
+};
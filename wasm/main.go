@@ -4,22 +4,35 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"syscall/js"
 
 	"github.com/billstark001/poly2block/core"
 )
 
+// moduleVersion is the version string reported to JavaScript.
+const moduleVersion = "0.1.0"
+
 func main() {
 	c := make(chan struct{}, 0)
 	
 	// Register functions to JavaScript
 	js.Global().Set("poly2block", js.ValueOf(map[string]interface{}{
-		"meshToVox":       js.FuncOf(meshToVox),
-		"meshToSchematic": js.FuncOf(meshToSchematic),
-		"generatePalette": js.FuncOf(generatePalette),
-		"version":         js.ValueOf("0.1.0"),
+		"meshToVox":            js.FuncOf(meshToVox),
+		"meshToSchematic":      js.FuncOf(meshToSchematic),
+		"voxToSchematic":       js.FuncOf(voxToSchematic),
+		"schematicToVox":       js.FuncOf(schematicToVox),
+		"meshToVoxelGrid":      js.FuncOf(meshToVoxelGrid),
+		"voxelGridToVox":       js.FuncOf(voxelGridToVox),
+		"voxelGridToSchematic": js.FuncOf(voxelGridToSchematic),
+		"generatePalette":      js.FuncOf(generatePalette),
+		"convert":              js.FuncOf(convertAsync),
+		"createStream":         js.FuncOf(createStream),
+		"capabilities":         js.FuncOf(capabilities),
+		"version":              js.ValueOf(moduleVersion),
 	}))
 	
 	fmt.Println("poly2block WASM module loaded")
@@ -27,162 +40,267 @@ func main() {
 }
 
 // meshToVox converts a mesh to VOX format
-// Args: meshData (base64 or Uint8Array), resolution (int), conservative (bool)
-// Returns: voxData (base64 string) or error
+// Args: meshData (Uint8Array, ArrayBuffer or base64), options (object, optional)
+// Returns: voxData (Uint8Array, or base64 string with encoding "base64") or error
 func meshToVox(this js.Value, args []js.Value) interface{} {
-	if len(args) < 3 {
-		return wrapError("meshToVox requires 3 arguments: meshData, resolution, conservative")
+	return convertBytes("meshToVox", runMeshToVox, args)
+}
+
+// meshToSchematic converts a mesh to Minecraft schematic
+// Args: meshData (Uint8Array, ArrayBuffer or base64), options (object, optional)
+// Returns: schematicData (Uint8Array, or base64 string with encoding "base64") or error
+func meshToSchematic(this js.Value, args []js.Value) interface{} {
+	return convertBytes("meshToSchematic", runMeshToSchematic, args)
+}
+
+// voxToSchematic converts a MagicaVoxel file to Minecraft schematic
+// Args: voxData (Uint8Array, ArrayBuffer or base64), options (object, optional)
+// Returns: schematicData (Uint8Array, or base64 string with encoding "base64") or error
+func voxToSchematic(this js.Value, args []js.Value) interface{} {
+	return convertBytes("voxToSchematic", runVoxToSchematic, args)
+}
+
+// schematicToVox converts a Sponge schematic to MagicaVoxel, coloring blocks from the palette
+// Args: schematicData (Uint8Array, ArrayBuffer or base64), options (object, optional)
+// Returns: voxData (Uint8Array, or base64 string with encoding "base64") or error
+func schematicToVox(this js.Value, args []js.Value) interface{} {
+	return convertBytes("schematicToVox", runSchematicToVox, args)
+}
+
+// conversionFunc reads input from r and writes the converted output to w, stopping early when ctx is done.
+type conversionFunc func(ctx context.Context, r io.Reader, w io.Writer, opts convertOptions) error
+
+// conversions maps JavaScript conversion names to their implementations.
+var conversions = map[string]conversionFunc{
+	"meshToVox":       runMeshToVox,
+	"meshToSchematic": runMeshToSchematic,
+	"voxToSchematic":  runVoxToSchematic,
+	"schematicToVox":  runSchematicToVox,
+}
+
+// convertBytes runs a conversion over a single in-memory input and returns the output.
+func convertBytes(name string, run conversionFunc, args []js.Value) interface{} {
+	if len(args) < 1 {
+		return wrapError(newError(codeInvalidArgument, stageInput, "%s requires input data and an optional options object", name))
 	}
 	
-	// Get mesh data
-	meshData, err := extractBytes(args[0])
+	// Get input data
+	data, err := extractBytes(args[0])
 	if err != nil {
-		return wrapError(fmt.Sprintf("failed to extract mesh data: %v", err))
+		return wrapError(newError(codeInvalidArgument, stageInput, "failed to extract input data: %v", err))
 	}
 	
-	resolution := args[1].Int()
-	conservative := args[2].Bool()
-	
-	// Create pipeline
-	importer := core.NewGLTFImporter()
-	voxelizer := core.NewSurfaceVoxelizer()
-	
-	pipeline := &core.Pipeline{
-		Importer:  importer,
-		Voxelizer: voxelizer,
+	opts, err := parseOptions(optionalArg(args, 1))
+	if err != nil {
+		return wrapError(newError(codeInvalidArgument, stageOptions, "%v", err))
 	}
 	
-	config := core.PipelineConfig{
-		Voxelization: core.VoxelizationConfig{
-			Resolution:   resolution,
-			Conservative: conservative,
-		},
+	var output bytes.Buffer
+	if err := run(context.Background(), bytes.NewReader(data), &output, opts); err != nil {
+		return wrapError(err)
 	}
 	
-	// Convert
-	meshReader := bytes.NewReader(meshData)
-	var voxWriter bytes.Buffer
+	return wrapSuccess(opts.encodeOutput(output.Bytes()))
+}
+
+// runMeshToVox converts a glTF/GLB mesh to VOX.
+func runMeshToVox(ctx context.Context, r io.Reader, w io.Writer, opts convertOptions) (err error) {
+	defer recoverError(&err)
 	
-	if err := pipeline.MeshToVOX(meshReader, &voxWriter, config); err != nil {
-		return wrapError(fmt.Sprintf("conversion failed: %v", err))
+	voxelGrid, err := voxelizeMesh(ctx, r, opts)
+	if err != nil {
+		return err
 	}
+	return exportVox(ctx, voxelGrid, w, opts)
+}
+
+// runMeshToSchematic converts a glTF/GLB mesh to a Minecraft schematic.
+func runMeshToSchematic(ctx context.Context, r io.Reader, w io.Writer, opts convertOptions) (err error) {
+	defer recoverError(&err)
 	
-	// Return as base64
-	result := base64.StdEncoding.EncodeToString(voxWriter.Bytes())
-	return wrapSuccess(result)
+	voxelGrid, err := voxelizeMesh(ctx, r, opts)
+	if err != nil {
+		return err
+	}
+	return exportSchematic(ctx, voxelGrid, w, opts)
 }
 
-// meshToSchematic converts a mesh to Minecraft schematic
-// Args: meshData, resolution, conservative, dither, paletteData (optional)
-func meshToSchematic(this js.Value, args []js.Value) interface{} {
-	if len(args) < 4 {
-		return wrapError("meshToSchematic requires at least 4 arguments: meshData, resolution, conservative, dither")
+// runVoxToSchematic converts a MagicaVoxel file to a Minecraft schematic.
+func runVoxToSchematic(ctx context.Context, r io.Reader, w io.Writer, opts convertOptions) (err error) {
+	defer recoverError(&err)
+	
+	voxelGrid, err := importGrid(r, core.NewVOXImporter(), opts)
+	if err != nil {
+		return err
 	}
+	return exportSchematic(ctx, voxelGrid, w, opts)
+}
+
+// runSchematicToVox converts a Sponge schematic to VOX, coloring each block with its
+// entry in the configured palette (or the vanilla palette).
+func runSchematicToVox(ctx context.Context, r io.Reader, w io.Writer, opts convertOptions) (err error) {
+	defer recoverError(&err)
 	
-	// Get mesh data
-	meshData, err := extractBytes(args[0])
+	palette, err := opts.resolvePalette()
+	if err != nil {
+		return newError(codeInvalidArgument, stageOptions, "%v", err)
+	}
+	voxelGrid, err := importGrid(r, &core.SchematicImporterImpl{Palette: palette}, opts)
+	if err != nil {
+		return err
+	}
+	return exportVox(ctx, voxelGrid, w, opts)
+}
+
+// gridImporter reads a voxel grid file (VOX or schematic).
+type gridImporter interface {
+	Import(r io.Reader) (*core.VoxelGrid, error)
+}
+
+// importGrid reads a voxel grid with imp and checks it against the cell limit.
+func importGrid(r io.Reader, imp gridImporter, opts convertOptions) (*core.VoxelGrid, error) {
+	voxelGrid, err := imp.Import(r)
 	if err != nil {
-		return wrapError(fmt.Sprintf("failed to extract mesh data: %v", err))
+		return nil, stageError(stageImport, err)
 	}
+	// As in voxelizeMesh, drain the input so a streaming caller's write() cannot block.
+	io.Copy(io.Discard, r)
 	
-	resolution := args[1].Int()
-	conservative := args[2].Bool()
-	dither := args[3].Bool()
+	if err := checkGridCells(voxelGrid, opts); err != nil {
+		return nil, err
+	}
+	return voxelGrid, nil
+}
+
+// exportVox writes a voxel grid as VOX.
+func exportVox(ctx context.Context, vg *core.VoxelGrid, w io.Writer, opts convertOptions) error {
+	pipeline, err := core.NewPipeline(opts.pipelineOptions("vox", nil)...)
+	if err != nil {
+		return newError(codeInvalidArgument, stageOptions, "%v", err)
+	}
+	if err := pipeline.ExportGridCtx(ctx, vg, w); err != nil {
+		return stageError(stageExport, err)
+	}
+	return nil
+}
+
+// exportSchematic matches a voxel grid against the configured palette and writes a schematic.
+func exportSchematic(ctx context.Context, vg *core.VoxelGrid, w io.Writer, opts convertOptions) error {
+	if dataVersion := opts.dataVersion(); dataVersion != 0 && dataVersion < core.FlatteningDataVersion {
+		return newError(codeInvalidArgument, stageOptions,
+			"options.mcVersion: Sponge schematics hold Minecraft 1.13+ block IDs, which %s cannot read", opts.MCVersion)
+	}
 	
 	// Get palette (use vanilla if not provided)
-	var palette *core.Palette
-	if len(args) >= 5 && !args[4].IsNull() && !args[4].IsUndefined() {
-		paletteData, err := extractBytes(args[4])
-		if err != nil {
-			return wrapError(fmt.Sprintf("failed to extract palette data: %v", err))
-		}
-		palette, err = core.ImportPalette(bytes.NewReader(paletteData))
-		if err != nil {
-			return wrapError(fmt.Sprintf("failed to import palette: %v", err))
-		}
-	} else {
-		blocks := core.GetVanillaMinecraftBlocks()
-		palette = core.GenerateMinecraftPalette(blocks)
+	palette, err := opts.resolvePalette()
+	if err != nil {
+		return newError(codeInvalidArgument, stageOptions, "%v", err)
 	}
 	
-	// Create pipeline
-	importer := core.NewGLTFImporter()
-	voxelizer := core.NewSurfaceVoxelizer()
-	matcher := core.NewCIELABMatcher(palette)
-	
-	pipeline := &core.Pipeline{
-		Importer:  importer,
-		Voxelizer: voxelizer,
-		Matcher:   matcher,
+	pipeline, err := core.NewPipeline(opts.pipelineOptions("schematic", palette)...)
+	if err != nil {
+		return newError(codeInvalidArgument, stageOptions, "%v", err)
 	}
-	
-	config := core.PipelineConfig{
-		Voxelization: core.VoxelizationConfig{
-			Resolution:   resolution,
-			Conservative: conservative,
-		},
-		Dithering: core.DitherConfig{
-			Enabled:   dither,
-			Algorithm: "floyd-steinberg",
-		},
-		Palette: palette,
+	if err := pipeline.ExportGridCtx(ctx, vg, w); err != nil {
+		return stageError(stageExport, err)
+	}
+	return nil
+}
+
+// voxelizeMesh imports and voxelizes a mesh with the registered importer and voxelizer
+// named in the options, reporting which stage failed.
+func voxelizeMesh(ctx context.Context, r io.Reader, opts convertOptions) (*core.VoxelGrid, error) {
+	pipeline, err := core.NewPipeline(opts.pipelineOptions("", nil)...)
+	if err != nil {
+		return nil, newError(codeInvalidArgument, stageOptions, "%v", err)
 	}
 	
-	// Convert
-	meshReader := bytes.NewReader(meshData)
-	var schematicWriter bytes.Buffer
-	
-	if err := pipeline.MeshToSchematic(meshReader, &schematicWriter, config); err != nil {
-		return wrapError(fmt.Sprintf("conversion failed: %v", err))
+	mesh, err := pipeline.ImportMeshCtx(ctx, r, pipeline.Config)
+	if err != nil {
+		return nil, stageError(stageImport, err)
 	}
+	// Consume anything the importer left unread (e.g. padding after a GLB's last
+	// chunk); a streaming caller's write() blocks until its chunk has been read.
+	io.Copy(io.Discard, r)
 	
-	// Return as base64
-	result := base64.StdEncoding.EncodeToString(schematicWriter.Bytes())
-	return wrapSuccess(result)
+	voxelGrid, err := pipeline.VoxelizeMeshCtx(ctx, mesh, pipeline.Config)
+	if err != nil {
+		return nil, stageError(stageVoxelize, err)
+	}
+	return voxelGrid, nil
+}
+
+// recoverError turns a panic inside a conversion into an INTERNAL error instead of killing the module.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = newError(codeInternal, "", "unexpected panic: %v", r)
+	}
 }
 
 // generatePalette generates a Minecraft block palette
-// Args: none (uses vanilla blocks)
-// Returns: paletteData (base64 string) or error
+// Args: options (object, optional; only encoding and mcVersion are used)
+// Returns: paletteData (Uint8Array, or base64 string with encoding "base64") or error
 func generatePalette(this js.Value, args []js.Value) interface{} {
-	blocks := core.GetVanillaMinecraftBlocks()
+	opts, err := parseOptions(optionalArg(args, 0))
+	if err != nil {
+		return wrapError(newError(codeInvalidArgument, stageOptions, "%v", err))
+	}
+	
+	blocks, err := core.VanillaBlocks(opts.MCVersion)
+	if err != nil {
+		return wrapError(newError(codeInvalidArgument, stageOptions, "options.mcVersion: %v", err))
+	}
 	palette := core.GenerateMinecraftPalette(blocks)
 	
 	var buf bytes.Buffer
 	if err := core.ExportPalette(palette, &buf); err != nil {
-		return wrapError(fmt.Sprintf("failed to export palette: %v", err))
+		return wrapError(stageError(stageExport, err))
 	}
 	
-	result := base64.StdEncoding.EncodeToString(buf.Bytes())
-	return wrapSuccess(result)
+	return wrapSuccess(opts.encodeOutput(buf.Bytes()))
 }
 
 // Helper functions
 
+// optionalArg returns args[i], or undefined if it was not passed.
+func optionalArg(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
+}
+
+// extractBytes copies binary input from a Uint8Array, an ArrayBuffer, any other
+// ArrayBuffer view (typed array or DataView), or a base64 string.
 func extractBytes(val js.Value) ([]byte, error) {
-	if val.Type() == js.TypeString {
+	uint8Array := js.Global().Get("Uint8Array")
+	switch {
+	case val.Type() == js.TypeString:
 		// Base64 encoded string
 		return base64.StdEncoding.DecodeString(val.String())
-	} else if val.InstanceOf(js.Global().Get("Uint8Array")) {
-		// Uint8Array
-		length := val.Get("length").Int()
-		data := make([]byte, length)
-		js.CopyBytesToGo(data, val)
-		return data, nil
+	case val.InstanceOf(js.Global().Get("ArrayBuffer")):
+		val = uint8Array.New(val)
+	case val.Type() == js.TypeObject && !val.InstanceOf(uint8Array) && js.Global().Get("ArrayBuffer").Call("isView", val).Bool():
+		val = uint8Array.New(val.Get("buffer"), val.Get("byteOffset"), val.Get("byteLength"))
+	case !val.InstanceOf(uint8Array):
+		return nil, fmt.Errorf("unsupported data type (want Uint8Array, ArrayBuffer or base64 string)")
 	}
-	return nil, fmt.Errorf("unsupported data type")
+	data := make([]byte, val.Get("length").Int())
+	js.CopyBytesToGo(data, val)
+	return data, nil
 }
 
-func wrapSuccess(data string) interface{} {
+// bytesToJS copies data into a new Uint8Array.
+func bytesToJS(data []byte) js.Value {
+	arr := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(arr, data)
+	return arr
+}
+
+func wrapSuccess(data interface{}) interface{} {
 	return js.ValueOf(map[string]interface{}{
 		"success": true,
 		"data":    data,
 	})
 }
 
-func wrapError(msg string) interface{} {
-	return js.ValueOf(map[string]interface{}{
-		"success": false,
-		"error":   msg,
-	})
-}
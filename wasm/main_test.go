@@ -0,0 +1,120 @@
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"syscall/js"
+	"testing"
+)
+
+func TestExtractBytes(t *testing.T) {
+	want := []byte{1, 2, 3, 4}
+	arr := toUint8Array(want)
+	inputs := map[string]js.Value{
+		"Uint8Array":  arr,
+		"ArrayBuffer": arr.Get("buffer"),
+		"DataView":    js.Global().Get("DataView").New(arr.Get("buffer")),
+		"base64":      js.ValueOf(base64.StdEncoding.EncodeToString(want)),
+	}
+	for name, val := range inputs {
+		got, err := extractBytes(val)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s: got %v, %v, want %v", name, got, err, want)
+		}
+	}
+
+	// A view over part of a buffer yields only its own bytes
+	sub := js.Global().Get("Uint16Array").New(arr.Get("buffer"), 2, 1)
+	if got, err := extractBytes(sub); err != nil || !bytes.Equal(got, want[2:]) {
+		t.Errorf("offset view: got %v, %v, want %v", got, err, want[2:])
+	}
+
+	if _, err := extractBytes(js.ValueOf(42)); err == nil {
+		t.Error("Expected error for a number")
+	}
+}
+
+func TestOutputEncoding(t *testing.T) {
+	mesh := toUint8Array(newTriangleGLB(t))
+
+	result := meshToVox(js.Undefined(), []js.Value{mesh, js.ValueOf(map[string]interface{}{"resolution": 8})}).(js.Value)
+	if !result.Get("success").Bool() {
+		t.Fatalf("meshToVox failed: %v", result.Get("error").Get("message"))
+	}
+	data := result.Get("data")
+	if !data.InstanceOf(js.Global().Get("Uint8Array")) {
+		t.Fatalf("Default output is %s, want a Uint8Array", data.Type())
+	}
+	output := make([]byte, data.Length())
+	js.CopyBytesToGo(output, data)
+	if !bytes.HasPrefix(output, []byte("VOX ")) {
+		t.Errorf("Output is not a VOX file: % x", output[:min(len(output), 8)])
+	}
+
+	result = meshToVox(js.Undefined(), []js.Value{mesh, js.ValueOf(map[string]interface{}{"resolution": 8, "encoding": "base64"})}).(js.Value)
+	if data := result.Get("data"); data.Type() != js.TypeString {
+		t.Fatalf("base64 output is %s, want a string", data.Type())
+	} else if decoded, err := base64.StdEncoding.DecodeString(data.String()); err != nil || !bytes.HasPrefix(decoded, []byte("VOX ")) {
+		t.Errorf("base64 output does not decode to a VOX file: %v", err)
+	}
+
+	palette := generatePalette(js.Undefined(), nil).(js.Value)
+	if !palette.Get("data").InstanceOf(js.Global().Get("Uint8Array")) || palette.Get("data").Length() == 0 {
+		t.Error("generatePalette did not return a Uint8Array")
+	}
+}
+
+func TestVoxSchematicConversions(t *testing.T) {
+	call := func(fn func(js.Value, []js.Value) interface{}, input js.Value) js.Value {
+		t.Helper()
+		result := fn(js.Undefined(), []js.Value{input, js.ValueOf(map[string]interface{}{"resolution": 8})}).(js.Value)
+		if !result.Get("success").Bool() {
+			t.Fatalf("conversion failed: %v", result.Get("error").Get("message"))
+		}
+		return result.Get("data")
+	}
+
+	vox := call(meshToVox, toUint8Array(newTriangleGLB(t)))
+	schematic := call(voxToSchematic, vox)
+	back := call(schematicToVox, schematic)
+
+	output := make([]byte, back.Length())
+	js.CopyBytesToGo(output, back)
+	if !bytes.HasPrefix(output, []byte("VOX ")) {
+		t.Errorf("schematicToVox output is not a VOX file: % x", output[:min(len(output), 8)])
+	}
+
+	// Sponge v3 nests the block data in a Blocks compound
+	v3 := voxToSchematic(js.Undefined(), []js.Value{vox, js.ValueOf(map[string]interface{}{"schematicVersion": 3})}).(js.Value)
+	if !v3.Get("success").Bool() {
+		t.Fatalf("v3 conversion failed: %v", v3.Get("error").Get("message"))
+	}
+	gz := make([]byte, v3.Get("data").Length())
+	js.CopyBytesToGo(gz, v3.Get("data"))
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		t.Fatalf("v3 output is not gzipped: %v", err)
+	}
+	nbt, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("v3 output: %v", err)
+	}
+	if !bytes.Contains(nbt, []byte("Blocks")) {
+		t.Error("schematicVersion 3 did not write a Sponge v3 schematic")
+	}
+
+	// Sponge schematics cannot target versions before the flattening
+	old := voxToSchematic(js.Undefined(), []js.Value{vox, js.ValueOf(map[string]interface{}{"mcVersion": "1.12"})}).(js.Value)
+	if old.Get("success").Bool() || old.Get("error").Get("code").String() != codeInvalidArgument {
+		t.Errorf("Expected INVALID_ARGUMENT for a 1.12 schematic, got %v", old.Get("error").Get("code"))
+	}
+
+	result := schematicToVox(js.Undefined(), []js.Value{toUint8Array([]byte("not a schematic"))}).(js.Value)
+	if result.Get("success").Bool() || result.Get("error").Get("code").String() != codeInvalidInput {
+		t.Errorf("Expected INVALID_INPUT for a bad schematic, got %v", result.Get("error").Get("code"))
+	}
+}
@@ -0,0 +1,387 @@
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"syscall/js"
+
+	"github.com/billstark001/poly2block/core"
+)
+
+// Supported option values exposed to JavaScript.
+var (
+	supportedSchematicVersions = []string{"1.13+"}
+	supportedSpongeVersions    = []int{2, 3}
+	supportedEncodings         = []string{"bytes", "base64"}
+)
+
+// defaultMaxCells bounds the grid's bounding box (SizeX*SizeY*SizeZ), which sizes the
+// schematic exporter's dense block array, so oversized requests fail before allocating it.
+const defaultMaxCells = 1 << 26
+
+// convertOptions holds the validated options object passed from JavaScript.
+type convertOptions struct {
+	Format          string
+	Resolution      int
+	MaxCells        int
+	Voxelizer       string
+	Matcher         string
+	Conservative    bool
+	Fill            bool
+	Dither          bool
+	DitherAlgorithm string
+	Palette         *core.Palette
+	Filter          core.PaletteFilter
+	Version         string // Validated only, for callers of the old API; MCVersion picks the version
+	MCVersion       string // Minecraft version of the vanilla palette and data version ("" = every block, data version 2975)
+	SchemVersion    int    // Sponge schematic format version, 2 or 3
+	Encoding        string // "bytes" returns outputs as Uint8Array, "base64" as strings
+	Progress        core.ProgressReporter
+	Signal          js.Value // AbortSignal honored by convert; undefined when not given
+}
+
+// defaultOptions returns the options used when a field is omitted.
+func defaultOptions() convertOptions {
+	return convertOptions{
+		Format:          "gltf",
+		Resolution:      128,
+		MaxCells:        defaultMaxCells,
+		Voxelizer:       "surface",
+		Matcher:         "cielab",
+		Conservative:    true,
+		Dither:          false,
+		DitherAlgorithm: "floyd-steinberg",
+		Version:         "1.13+",
+		SchemVersion:    2,
+		Encoding:        "bytes",
+	}
+}
+
+// parseOptions reads an options object, filling in defaults and validating every field.
+// A null or undefined value yields the defaults.
+func parseOptions(val js.Value) (convertOptions, error) {
+	opts := defaultOptions()
+	if val.IsUndefined() || val.IsNull() {
+		return opts, nil
+	}
+	if val.Type() != js.TypeObject {
+		return opts, fmt.Errorf("options must be an object, got %s", val.Type())
+	}
+
+	var err error
+	if opts.Format, err = optionString(val, "format", opts.Format); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if names := core.ImporterNames(); !containsString(names, opts.Format) {
+		return opts, fmt.Errorf("options.format: unsupported value %q (supported: %s)",
+			opts.Format, strings.Join(names, ", "))
+	}
+
+	if opts.Resolution, err = optionInt(val, "resolution", opts.Resolution); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if opts.Resolution <= 0 {
+		return opts, fmt.Errorf("options.resolution must be positive, got %d", opts.Resolution)
+	}
+
+	if opts.MaxCells, err = optionInt(val, "maxCells", opts.MaxCells); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if opts.MaxCells < 0 {
+		return opts, fmt.Errorf("options.maxCells must not be negative, got %d", opts.MaxCells)
+	}
+
+	if opts.Voxelizer, err = optionString(val, "voxelizer", opts.Voxelizer); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if names := core.VoxelizerNames(); !containsString(names, opts.Voxelizer) {
+		return opts, fmt.Errorf("options.voxelizer: unsupported value %q (supported: %s)",
+			opts.Voxelizer, strings.Join(names, ", "))
+	}
+
+	if opts.Matcher, err = optionString(val, "matcher", opts.Matcher); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if names := core.MatcherNames(); !containsString(names, opts.Matcher) {
+		return opts, fmt.Errorf("options.matcher: unsupported value %q (supported: %s)",
+			opts.Matcher, strings.Join(names, ", "))
+	}
+
+	if opts.Conservative, err = optionBool(val, "conservative", opts.Conservative); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if opts.Fill, err = optionBool(val, "fill", opts.Fill); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+
+	if err := parseDitherOption(val.Get("dithering"), &opts); err != nil {
+		return opts, err
+	}
+
+	if opts.Version, err = optionString(val, "version", opts.Version); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if !containsString(supportedSchematicVersions, opts.Version) {
+		return opts, fmt.Errorf("options.version: unsupported value %q (supported: %s)",
+			opts.Version, strings.Join(supportedSchematicVersions, ", "))
+	}
+
+	if opts.MCVersion, err = optionString(val, "mcVersion", opts.MCVersion); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if opts.MCVersion != "" {
+		if _, err := core.MinecraftDataVersion(opts.MCVersion); err != nil {
+			return opts, fmt.Errorf("options.mcVersion: %v (supported: %s or later, latest)", err, core.MinecraftVersions()[0])
+		}
+	}
+
+	if opts.SchemVersion, err = optionInt(val, "schematicVersion", opts.SchemVersion); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if !slices.Contains(supportedSpongeVersions, opts.SchemVersion) {
+		return opts, fmt.Errorf("options.schematicVersion: unsupported value %d (supported: 2, 3)", opts.SchemVersion)
+	}
+
+	if opts.Encoding, err = optionString(val, "encoding", opts.Encoding); err != nil {
+		return opts, fmt.Errorf("options.%v", err)
+	}
+	if !containsString(supportedEncodings, opts.Encoding) {
+		return opts, fmt.Errorf("options.encoding: unsupported value %q (supported: %s)",
+			opts.Encoding, strings.Join(supportedEncodings, ", "))
+	}
+
+	if palette := val.Get("palette"); !palette.IsUndefined() && !palette.IsNull() {
+		paletteData, err := extractBytes(palette)
+		if err != nil {
+			return opts, fmt.Errorf("options.palette: %v", err)
+		}
+		opts.Palette, err = core.ImportPalette(bytes.NewReader(paletteData))
+		if err != nil {
+			return opts, fmt.Errorf("options.palette: failed to import palette: %v", err)
+		}
+	}
+
+	if onProgress := val.Get("onProgress"); !onProgress.IsUndefined() && !onProgress.IsNull() {
+		if onProgress.Type() != js.TypeFunction {
+			return opts, fmt.Errorf("options.onProgress must be a function, got %s", onProgress.Type())
+		}
+		opts.Progress = jsProgressReporter(onProgress)
+	}
+
+	if signal := val.Get("signal"); !signal.IsUndefined() && !signal.IsNull() {
+		if signal.Type() != js.TypeObject || signal.Get("addEventListener").Type() != js.TypeFunction {
+			return opts, fmt.Errorf("options.signal must be an AbortSignal, got %s", signal.Type())
+		}
+		opts.Signal = signal
+	}
+
+	if filters := val.Get("filters"); !filters.IsUndefined() && !filters.IsNull() {
+		if filters.Type() != js.TypeObject {
+			return opts, fmt.Errorf("options.filters must be an object, got %s", filters.Type())
+		}
+		if opts.Filter.Include, err = optionStrings(filters, "include"); err != nil {
+			return opts, fmt.Errorf("options.filters.%v", err)
+		}
+		if opts.Filter.Exclude, err = optionStrings(filters, "exclude"); err != nil {
+			return opts, fmt.Errorf("options.filters.%v", err)
+		}
+		if opts.Filter.SurvivalOnly, err = optionBool(filters, "survivalOnly", false); err != nil {
+			return opts, fmt.Errorf("options.filters.%v", err)
+		}
+		if opts.Filter.OpaqueOnly, err = optionBool(filters, "opaqueOnly", false); err != nil {
+			return opts, fmt.Errorf("options.filters.%v", err)
+		}
+	}
+
+	return opts, nil
+}
+
+// parseDitherOption accepts either a boolean or an {enabled, algorithm} object.
+func parseDitherOption(val js.Value, opts *convertOptions) error {
+	switch val.Type() {
+	case js.TypeUndefined, js.TypeNull:
+		return nil
+	case js.TypeBoolean:
+		opts.Dither = val.Bool()
+	case js.TypeObject:
+		var err error
+		if opts.Dither, err = optionBool(val, "enabled", true); err != nil {
+			return fmt.Errorf("options.dithering.%v", err)
+		}
+		if opts.DitherAlgorithm, err = optionString(val, "algorithm", opts.DitherAlgorithm); err != nil {
+			return fmt.Errorf("options.dithering.%v", err)
+		}
+	default:
+		return fmt.Errorf("options.dithering must be a boolean or an object, got %s", val.Type())
+	}
+
+	if algorithms := core.DitherAlgorithms(); !containsString(algorithms, opts.DitherAlgorithm) {
+		return fmt.Errorf("options.dithering.algorithm: unsupported value %q (supported: %s)",
+			opts.DitherAlgorithm, strings.Join(algorithms, ", "))
+	}
+	return nil
+}
+
+// resolvePalette returns the configured palette (or the vanilla palette of
+// MCVersion) with block filters applied.
+func (o convertOptions) resolvePalette() (*core.Palette, error) {
+	palette := o.Palette
+	if palette == nil {
+		blocks, err := core.VanillaBlocks(o.MCVersion)
+		if err != nil {
+			return nil, fmt.Errorf("options.mcVersion: %v", err)
+		}
+		palette = core.GenerateMinecraftPalette(blocks)
+	}
+	if o.Filter.IsEmpty() {
+		return palette, nil
+	}
+	
+	filtered, err := o.Filter.Apply(palette)
+	if err != nil {
+		return nil, fmt.Errorf("options.filters: %v", err)
+	}
+	return filtered, nil
+}
+
+// dataVersion returns the data version of MCVersion, or 0 for the exporters'
+// default when it is not set.
+func (o convertOptions) dataVersion() int {
+	if o.MCVersion == "" {
+		return 0
+	}
+	dataVersion, _ := core.MinecraftDataVersion(o.MCVersion) // Validated by parseOptions
+	return dataVersion
+}
+
+// encodeOutput returns converted output as a Uint8Array, or as a base64 string
+// when the caller opted into the "base64" encoding.
+func (o convertOptions) encodeOutput(data []byte) interface{} {
+	if o.Encoding == "base64" {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+	return bytesToJS(data)
+}
+
+// pipelineOptions converts the options into core pipeline options. exporter names the
+// registered exporter ("" for the pipeline default); the matcher and dithering settings
+// are only applied when a palette is given, since they have no effect without one.
+func (o convertOptions) pipelineOptions(exporter string, palette *core.Palette) []core.PipelineOption {
+	opts := []core.PipelineOption{
+		core.WithFormat(o.Format),
+		core.WithVoxelizerName(o.Voxelizer),
+		core.WithVoxelization(core.VoxelizationConfig{
+			Resolution:   o.Resolution,
+			Conservative: o.Conservative,
+			Fill:         o.Fill,
+			MaxCells:     o.MaxCells,
+		}),
+		core.WithSchematic(core.SchematicConfig{Version: o.SchemVersion, DataVersion: o.dataVersion()}),
+		core.WithProgress(o.Progress),
+	}
+	if exporter != "" {
+		opts = append(opts, core.WithExporterName(exporter))
+	}
+	if palette != nil {
+		opts = append(opts,
+			core.WithPalette(palette),
+			core.WithMatcherName(o.Matcher),
+			core.WithDithering(core.DitherConfig{
+				Enabled:   o.Dither,
+				Algorithm: o.DitherAlgorithm,
+			}),
+		)
+	}
+	return opts
+}
+
+// jsProgressReporter forwards core progress events to a JavaScript callback as
+// {stage, type, current, total, percent} objects.
+func jsProgressReporter(fn js.Value) core.ProgressReporter {
+	types := map[core.ProgressEventType]string{
+		core.StageStarted:  "start",
+		core.StageProgress: "progress",
+		core.StageFinished: "end",
+	}
+	return core.ProgressFunc(func(e core.ProgressEvent) {
+		fn.Invoke(map[string]interface{}{
+			"stage":   e.Stage,
+			"type":    types[e.Type],
+			"current": e.Current,
+			"total":   e.Total,
+			"percent": e.Percent(),
+		})
+	})
+}
+
+// Option accessors
+
+func optionInt(obj js.Value, key string, def int) (int, error) {
+	v := obj.Get(key)
+	if v.IsUndefined() || v.IsNull() {
+		return def, nil
+	}
+	if v.Type() != js.TypeNumber {
+		return def, fmt.Errorf("%s must be a number, got %s", key, v.Type())
+	}
+	f := v.Float()
+	if f != float64(int(f)) {
+		return def, fmt.Errorf("%s must be an integer, got %v", key, f)
+	}
+	return int(f), nil
+}
+
+func optionBool(obj js.Value, key string, def bool) (bool, error) {
+	v := obj.Get(key)
+	if v.IsUndefined() || v.IsNull() {
+		return def, nil
+	}
+	if v.Type() != js.TypeBoolean {
+		return def, fmt.Errorf("%s must be a boolean, got %s", key, v.Type())
+	}
+	return v.Bool(), nil
+}
+
+func optionString(obj js.Value, key string, def string) (string, error) {
+	v := obj.Get(key)
+	if v.IsUndefined() || v.IsNull() {
+		return def, nil
+	}
+	if v.Type() != js.TypeString {
+		return def, fmt.Errorf("%s must be a string, got %s", key, v.Type())
+	}
+	return v.String(), nil
+}
+
+func optionStrings(obj js.Value, key string) ([]string, error) {
+	v := obj.Get(key)
+	if v.IsUndefined() || v.IsNull() {
+		return nil, nil
+	}
+	if !v.InstanceOf(js.Global().Get("Array")) {
+		return nil, fmt.Errorf("%s must be an array of strings", key)
+	}
+	result := make([]string, v.Length())
+	for i := range result {
+		item := v.Index(i)
+		if item.Type() != js.TypeString {
+			return nil, fmt.Errorf("%s[%d] must be a string, got %s", key, i, item.Type())
+		}
+		result[i] = item.String()
+	}
+	return result, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
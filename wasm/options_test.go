@@ -0,0 +1,128 @@
+// +build js,wasm
+
+package main
+
+import (
+	"strings"
+	"syscall/js"
+	"testing"
+)
+
+func TestParseOptionsDefaults(t *testing.T) {
+	for _, val := range []js.Value{js.Undefined(), js.Null(), js.ValueOf(map[string]interface{}{})} {
+		opts, err := parseOptions(val)
+		if err != nil {
+			t.Fatalf("parseOptions(%v) failed: %v", val, err)
+		}
+		want := defaultOptions()
+		if opts.Resolution != want.Resolution || opts.MaxCells != want.MaxCells ||
+			opts.Voxelizer != want.Voxelizer || opts.Matcher != want.Matcher ||
+			opts.Conservative != want.Conservative || opts.Dither != want.Dither {
+			t.Errorf("parseOptions(%v) = %+v, want defaults %+v", val, opts, want)
+		}
+	}
+}
+
+func TestParseOptionsValues(t *testing.T) {
+	opts, err := parseOptions(js.ValueOf(map[string]interface{}{
+		"resolution":       64,
+		"maxCells":         0,
+		"conservative":     false,
+		"dithering":        map[string]interface{}{"algorithm": "floyd-steinberg"},
+		"filters":          map[string]interface{}{"exclude": []interface{}{"*_wool"}},
+		"schematicVersion": 3,
+		"mcVersion":        "1.16",
+	}))
+	if err != nil {
+		t.Fatalf("parseOptions failed: %v", err)
+	}
+	if opts.Resolution != 64 || opts.MaxCells != 0 || opts.Conservative || !opts.Dither || opts.SchemVersion != 3 || opts.dataVersion() != 2566 {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+	if len(opts.Filter.Exclude) != 1 || opts.Filter.Exclude[0] != "*_wool" {
+		t.Errorf("Unexpected filter: %+v", opts.Filter)
+	}
+}
+
+func TestParseOptionsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		options interface{}
+		field   string
+	}{
+		{"NotObject", "fast", "options must be an object"},
+		{"NegativeResolution", map[string]interface{}{"resolution": -1}, "options.resolution"},
+		{"FractionalResolution", map[string]interface{}{"resolution": 1.5}, "options.resolution"},
+		{"StringResolution", map[string]interface{}{"resolution": "high"}, "options.resolution"},
+		{"NegativeMaxCells", map[string]interface{}{"maxCells": -1}, "options.maxCells"},
+		{"UnknownFormat", map[string]interface{}{"format": "abc"}, "options.format"},
+		{"UnknownVoxelizer", map[string]interface{}{"voxelizer": "abc"}, "options.voxelizer"},
+		{"UnknownMatcher", map[string]interface{}{"matcher": "rgb"}, "options.matcher"},
+		{"DitheringNumber", map[string]interface{}{"dithering": 3}, "options.dithering"},
+		{"UnknownDitherAlgorithm", map[string]interface{}{"dithering": map[string]interface{}{"algorithm": "bogus"}}, "options.dithering.algorithm"},
+		{"UnknownVersion", map[string]interface{}{"version": "1.12"}, "options.version"},
+		{"OldMCVersion", map[string]interface{}{"mcVersion": "1.8"}, "options.mcVersion"},
+		{"MalformedMCVersion", map[string]interface{}{"mcVersion": "1.13+"}, "options.mcVersion"},
+		{"UnknownSchematicVersion", map[string]interface{}{"schematicVersion": 4}, "options.schematicVersion"},
+		{"UnknownEncoding", map[string]interface{}{"encoding": "hex"}, "options.encoding"},
+		{"FilterNotArray", map[string]interface{}{"filters": map[string]interface{}{"exclude": "wool"}}, "options.filters.exclude"},
+		{"FilterNotString", map[string]interface{}{"filters": map[string]interface{}{"include": []interface{}{1}}}, "options.filters.include[0]"},
+		{"SurvivalOnlyNotBool", map[string]interface{}{"filters": map[string]interface{}{"survivalOnly": "yes"}}, "options.filters.survivalOnly"},
+		{"OpaqueOnlyNotBool", map[string]interface{}{"filters": map[string]interface{}{"opaqueOnly": 1}}, "options.filters.opaqueOnly"},
+		{"ProgressNotFunction", map[string]interface{}{"onProgress": true}, "options.onProgress"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseOptions(js.ValueOf(tt.options))
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if !strings.Contains(err.Error(), tt.field) {
+				t.Errorf("Error %q does not mention %q", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestResolvePalette(t *testing.T) {
+	opts := defaultOptions()
+	full, err := opts.resolvePalette()
+	if err != nil {
+		t.Fatalf("resolvePalette failed: %v", err)
+	}
+
+	opts.Filter.Exclude = []string{"*_wool"}
+	filtered, err := opts.resolvePalette()
+	if err != nil {
+		t.Fatalf("resolvePalette failed: %v", err)
+	}
+	for _, color := range filtered.Colors {
+		if strings.HasSuffix(color.Name, "_wool") {
+			t.Errorf("Excluded block %s still in palette", color.Name)
+		}
+	}
+	if len(filtered.Colors) == 0 || len(filtered.Colors) >= len(full.Colors) {
+		t.Errorf("Expected a strict subset, got %d of %d colors", len(filtered.Colors), len(full.Colors))
+	}
+
+	opts.MCVersion = "1.12"
+	old, err := opts.resolvePalette()
+	if err != nil {
+		t.Fatalf("resolvePalette failed: %v", err)
+	}
+	if len(old.Colors) == 0 || len(old.Colors) >= len(filtered.Colors) {
+		t.Errorf("Expected fewer blocks in 1.12, got %d of %d", len(old.Colors), len(filtered.Colors))
+	}
+	for _, color := range old.Colors {
+		if strings.Contains(color.Name, "copper") {
+			t.Errorf("1.12 palette has %s", color.Name)
+		}
+	}
+	opts.MCVersion = ""
+
+	opts.Filter.Include = []string{"glass"}
+	if _, err := opts.resolvePalette(); err == nil {
+		t.Error("Expected error when filters remove every block")
+	}
+}
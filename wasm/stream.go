@@ -0,0 +1,145 @@
+// +build js,wasm
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall/js"
+)
+
+// defaultReadSize is the chunk size returned by read() when no size is given.
+const defaultReadSize = 1 << 20
+
+// conversionStream connects chunked JavaScript input and output to a conversion
+// running in its own goroutine, so neither side needs the whole file in one buffer.
+type conversionStream struct {
+	inWriter  *io.PipeWriter
+	outReader *io.PipeReader
+	cancel    context.CancelFunc
+	finished  bool
+	funcs     []js.Func
+}
+
+// createStream starts a streaming conversion
+// Args: conversion name ("meshToVox" or "meshToSchematic"), options (object, optional)
+// Returns: stream object with write(chunk), finish(), read(maxBytes) and close()
+func createStream(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || args[0].Type() != js.TypeString {
+		return wrapError(newError(codeInvalidArgument, stageStreaming, "createStream requires a conversion name and an optional options object"))
+	}
+
+	name := args[0].String()
+	run, ok := conversions[name]
+	if !ok {
+		return wrapError(newError(codeInvalidArgument, stageStreaming, "unknown conversion %q", name))
+	}
+
+	opts, err := parseOptions(optionalArg(args, 1))
+	if err != nil {
+		return wrapError(newError(codeInvalidArgument, stageOptions, "%v", err))
+	}
+
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &conversionStream{inWriter: inWriter, outReader: outReader, cancel: cancel}
+
+	go func() {
+		defer cancel()
+		err := run(ctx, inReader, outWriter, opts)
+		// Unblock any pending writes; a nil error makes them fail with io.ErrClosedPipe.
+		inReader.CloseWithError(err)
+		outWriter.CloseWithError(err)
+	}()
+
+	return wrapSuccess(s.jsObject())
+}
+
+// jsObject builds the JavaScript handle for the stream.
+func (s *conversionStream) jsObject() js.Value {
+	obj := js.Global().Get("Object").New()
+	s.bind(obj, "write", s.write)
+	s.bind(obj, "finish", s.finish)
+	s.bind(obj, "read", s.read)
+	s.bind(obj, "close", s.close)
+	return obj
+}
+
+func (s *conversionStream) bind(obj js.Value, name string, fn func(args []js.Value) interface{}) {
+	f := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return fn(args)
+	})
+	s.funcs = append(s.funcs, f)
+	obj.Set(name, f)
+}
+
+// write feeds the next input chunk (Uint8Array, ArrayBuffer or base64 string).
+func (s *conversionStream) write(args []js.Value) interface{} {
+	if len(args) < 1 {
+		return wrapError(newError(codeInvalidArgument, stageStreaming, "write requires a data chunk"))
+	}
+	chunk, err := extractBytes(args[0])
+	if err != nil {
+		return wrapError(newError(codeInvalidArgument, stageStreaming, "failed to extract chunk: %v", err))
+	}
+
+	if s.finished {
+		return wrapError(newError(codeInvalidArgument, stageStreaming, "write called after finish"))
+	}
+
+	// Blocks until the conversion has consumed the chunk; it keeps reading until
+	// finish() even after the importer is done, so this cannot stall.
+	n, err := s.inWriter.Write(chunk)
+	if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		return wrapError(err)
+	}
+	return wrapSuccess(n)
+}
+
+// finish signals the end of the input.
+func (s *conversionStream) finish(args []js.Value) interface{} {
+	s.finished = true
+	s.inWriter.Close()
+	return wrapSuccess(true)
+}
+
+// read returns the next output chunk of at most maxBytes bytes as a Uint8Array.
+// It must be called after finish: the conversion cannot produce output before the
+// input is complete, and blocking here would stall the JavaScript thread for good.
+// Returns: {success, data, done}; done is true once the output is exhausted.
+func (s *conversionStream) read(args []js.Value) interface{} {
+	if !s.finished {
+		return wrapError(newError(codeInvalidArgument, stageStreaming, "read called before finish"))
+	}
+
+	size := defaultReadSize
+	if len(args) >= 1 && args[0].Type() == js.TypeNumber && args[0].Int() > 0 {
+		size = args[0].Int()
+	}
+
+	buf := make([]byte, size)
+	n, err := io.ReadFull(s.outReader, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return wrapError(err)
+	}
+
+	return js.ValueOf(map[string]interface{}{
+		"success": true,
+		"data":    bytesToJS(buf[:n]),
+		"done":    n < size,
+	})
+}
+
+// close aborts the conversion if still running and releases the stream's callbacks.
+func (s *conversionStream) close(args []js.Value) interface{} {
+	s.cancel()
+	s.inWriter.CloseWithError(io.ErrClosedPipe)
+	s.outReader.Close()
+	for _, f := range s.funcs {
+		f.Release()
+	}
+	s.funcs = nil
+	return wrapSuccess(true)
+}
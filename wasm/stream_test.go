@@ -0,0 +1,85 @@
+// +build js,wasm
+
+package main
+
+import (
+	"bytes"
+	"syscall/js"
+	"testing"
+
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// newTriangleGLB encodes a single-triangle mesh as GLB.
+func newTriangleGLB(t *testing.T) []byte {
+	t.Helper()
+
+	doc := gltf.NewDocument()
+	positions := modeler.WritePosition(doc, [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 1}})
+	indices := modeler.WriteIndices(doc, []uint16{0, 1, 2})
+	doc.Meshes = []*gltf.Mesh{{
+		Primitives: []*gltf.Primitive{{
+			Indices:    gltf.Index(indices),
+			Attributes: gltf.PrimitiveAttributes{gltf.POSITION: positions},
+		}},
+	}}
+
+	var buf bytes.Buffer
+	if err := gltf.NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func toUint8Array(data []byte) js.Value {
+	arr := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(arr, data)
+	return arr
+}
+
+func TestStreamConversion(t *testing.T) {
+	// Trailing bytes after the GLB are never read by the decoder; write() must not block on them.
+	input := append(newTriangleGLB(t), make([]byte, 4096)...)
+
+	result := createStream(js.Undefined(), []js.Value{js.ValueOf("meshToVox"), js.ValueOf(map[string]interface{}{"resolution": 8})}).(js.Value)
+	if !result.Get("success").Bool() {
+		t.Fatalf("createStream failed: %v", result.Get("error").Get("message"))
+	}
+	stream := result.Get("data")
+	defer stream.Call("close")
+
+	if res := stream.Call("read"); res.Get("success").Bool() || res.Get("error").Get("code").String() != codeInvalidArgument {
+		t.Fatal("Expected read before finish to fail with INVALID_ARGUMENT")
+	}
+
+	for len(input) > 0 {
+		n := 1000
+		if n > len(input) {
+			n = len(input)
+		}
+		if res := stream.Call("write", toUint8Array(input[:n])); !res.Get("success").Bool() {
+			t.Fatalf("write failed: %v", res.Get("error").Get("message"))
+		}
+		input = input[n:]
+	}
+	stream.Call("finish")
+
+	var output []byte
+	for {
+		res := stream.Call("read", 64)
+		if !res.Get("success").Bool() {
+			t.Fatalf("read failed: %v", res.Get("error").Get("message"))
+		}
+		chunk := make([]byte, res.Get("data").Length())
+		js.CopyBytesToGo(chunk, res.Get("data"))
+		output = append(output, chunk...)
+		if res.Get("done").Bool() {
+			break
+		}
+	}
+
+	if !bytes.HasPrefix(output, []byte("VOX ")) {
+		t.Errorf("Output is not a VOX file: % x", output[:min(len(output), 8)])
+	}
+}